@@ -8,22 +8,47 @@ import (
 	"go-wiki-app/internal/cache"
 	"go-wiki-app/internal/config"
 	"go-wiki-app/internal/data"
+	"go-wiki-app/internal/export"
 	"go-wiki-app/internal/handler"
 	"go-wiki-app/internal/logger"
 	"go-wiki-app/internal/middleware"
 	"go-wiki-app/internal/service"
+	"go-wiki-app/internal/session"
 	"go-wiki-app/internal/view"
 	"go-wiki-app/web"
 	"net/http"
 	"os"
 	"os/signal"
+	"runtime/debug"
 	"syscall"
 	"time"
 
-	"github.com/alexedwards/scs/mysqlstore"
 	"github.com/alexedwards/scs/v2"
 )
 
+// version and commit identify the build on the /status endpoint. They are set
+// at build time with -ldflags "-X main.version=... -X main.commit=...".
+var (
+	version = "dev"
+	commit  = ""
+)
+
+// buildCommit returns the commit set at build time, or the VCS revision Go
+// stamped into the binary if none was set.
+func buildCommit() string {
+	if commit != "" {
+		return commit
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				return setting.Value
+			}
+		}
+	}
+	return ""
+}
+
 func main() {
 	// --- Configuration Loading ---
 	cfg, err := config.LoadConfig()
@@ -42,7 +67,7 @@ func main() {
 
 	// --- Database Initialization and Migration ---
 	log.Info("Applying database migrations...")
-	if err := data.ApplyMigrations(cfg.DB.DSN, "migrations"); err != nil {
+	if err := data.ApplyMigrations(cfg.DB.Driver, cfg.DB.DSN, "migrations"); err != nil {
 		log.Fatal(err, "Failed to apply migrations")
 	}
 	log.Info("Migrations applied successfully.")
@@ -55,11 +80,22 @@ func main() {
 	defer db.Close()
 	log.Info("Database connection successful.")
 
+	if err := data.BackfillSearchColumns(context.Background(), db); err != nil {
+		log.Fatal(err, "Failed to index pages for search")
+	}
+
 	// --- Session Management Setup ---
+	sessionStore, closeSessionStore, err := session.NewStore(cfg.Session, db.DB)
+	if err != nil {
+		log.Fatal(err, "Failed to initialize session store")
+	}
+	defer closeSessionStore()
 	sessionManager := scs.New()
-	sessionManager.Store = mysqlstore.New(db.DB)
-	sessionManager.Lifetime = time.Duration(cfg.Session.Lifetime) * time.Hour
-	sessionManager.Cookie.Persist = true
+	sessionManager.Store = sessionStore
+	// The store keeps sessions for the longest lifetime; shorter, non-remembered
+	// sessions are expired by middleware.SessionExpiry.
+	sessionManager.Lifetime = time.Duration(max(cfg.Session.Lifetime, cfg.Session.RememberLifetime)) * time.Hour
+	sessionManager.Cookie.Persist = false
 	sessionManager.Cookie.SameSite = http.SameSiteLaxMode
 	sessionManager.Cookie.Secure = cfg.Server.TLS.Enabled
 
@@ -69,16 +105,16 @@ func main() {
 	if err != nil {
 		log.Fatal(err, "Failed to initialize authenticator")
 	}
-	enforcer, err := auth.NewEnforcer("mysql", cfg.DB.DSN, "auth_model.conf")
+	enforcer, err := auth.NewEnforcer(cfg.DB.Driver, cfg.DB.DSN, "auth_model.conf")
 	if err != nil {
 		log.Fatal(err, "Failed to initialize enforcer")
 	}
-	auth.SeedDefaultPolicies(enforcer, log) // Call the new function
+	auth.SeedDefaultPolicies(enforcer, log, cfg.Content.AllowAnonymousEdit)
 	log.Info("Auth components initialized and policies seeded.")
 
 	// --- View Template Initialization ---
 	log.Info("Initializing view templates...")
-	viewService, err := view.New(web.TemplateFS)
+	viewService, err := view.New(web.TemplateFS, view.WithSiteName(cfg.Site.Name), view.WithCSRFToken(middleware.CSRFToken))
 	if err != nil {
 		log.Fatal(err, "Failed to initialize view templates")
 	}
@@ -91,21 +127,80 @@ func main() {
 		log.Fatal(err, "Failed to initialize cache")
 	}
 	defer cache.Close()
+	cache.StartJanitor(time.Duration(cfg.Cache.JanitorIntervalSeconds) * time.Second)
 	log.Info("Cache initialized.")
 
 	// --- Dependency Injection and Handler Initialization ---
 	pageRepository := data.NewSQLPageRepository(db)
 	categoryRepository := data.NewCategoryRepository(db)
-	pageService := service.NewPageService(pageRepository, categoryRepository, cache)
-	pageHandler := handler.NewPageHandler(pageService, viewService, log)
-	authHandler := handler.NewAuthHandler(authenticator, sessionManager, enforcer)
-	seoHandler := handler.NewSeoHandler(pageService)
+	revisionRepository := data.NewSQLRevisionRepository(db)
+	userService := service.NewUserService(data.NewSQLUserRepository(db), cache)
+	pageService := service.NewPageService(pageRepository, categoryRepository, cache,
+		service.WithRevisions(revisionRepository),
+		service.WithRevisionRetention(cfg.Revisions),
+		service.WithContentConfig(cfg.Content),
+		service.WithMarkdownConfig(cfg.Markdown),
+		service.WithLinkCheck(cfg.LinkCheck),
+		service.WithModeration(data.NewSQLPendingEditRepository(db), cfg.Moderation),
+		service.WithPageACLs(data.NewSQLPageACLRepository(db), enforcer),
+		service.WithLogger(log),
+	)
+	highlightCSS, err := service.HighlightCSS(cfg.Markdown.HighlightTheme)
+	if err != nil {
+		log.Fatal(err, "Failed to build the syntax highlighting stylesheet")
+	}
+	handlerOptions := []handler.Option{
+		handler.WithEditorConfig(cfg.Editor),
+		handler.WithLanguage(cfg.Content.Language),
+		handler.WithSlugTransliteration(cfg.Content.SlugTransliterate),
+		handler.WithMaxBatchSize(cfg.API.MaxBatchSize),
+		handler.WithHighlightCSS(highlightCSS),
+		handler.WithBaseURL(cfg.Site.BaseURL),
+		handler.WithWelcomePageIndexing(cfg.Site.IndexWelcomePage),
+		handler.WithRoles(enforcer),
+	}
+	if cfg.API.IdempotencyKeyTTLMinutes > 0 {
+		handlerOptions = append(handlerOptions, handler.WithIdempotencyStore(cache, time.Duration(cfg.API.IdempotencyKeyTTLMinutes)*time.Minute))
+	}
+	if cfg.Features.PDFExport {
+		handlerOptions = append(handlerOptions, handler.WithPDFExport(export.NewWkhtmltopdf(cfg.Export.WkhtmltopdfPath)))
+	}
+	pageHandler := handler.NewPageHandler(pageService, viewService, log, enforcer, handlerOptions...)
+	authHandler := handler.NewAuthHandler(authenticator, sessionManager, enforcer, userService, cfg.Session,
+		handler.WithDefaultRole(cfg.Auth.DefaultRole, log),
+		handler.WithSessionEpochs(userService),
+	)
+	seoHandler := handler.NewSeoHandler(pageService, cfg.Site)
+	adminOptions := []handler.AdminOption{
+		handler.WithLockdown(userService),
+		handler.WithBuildInfo(version, buildCommit()),
+		handler.WithDatabaseDriver(cfg.DB.Driver),
+	}
+	if cfg.Moderation.RequireReview {
+		adminOptions = append(adminOptions, handler.WithReviewQueue(pageService))
+	}
+	adminHandler := handler.NewAdminHandler(pageService, viewService, log, adminOptions...)
 
-	authzMiddleware := middleware.Authorizer(enforcer, sessionManager)
+	authzMiddleware := middleware.Authorizer(enforcer, sessionManager, pageService.PageOwner, userService.GetUser)
 	errorMiddleware := middleware.Error(log, viewService)
+	sessionExpiry := middleware.SessionExpiry(sessionManager, time.Duration(cfg.Session.IdleTimeoutMinutes)*time.Minute)
+	sessionEpoch := middleware.SessionEpoch(sessionManager, userService.SessionEpoch)
+	// Sessions that outlived a lockdown are ended after the expired ones.
+	sessionExpiryMiddleware := func(next http.Handler) http.Handler {
+		return sessionExpiry(sessionEpoch(next))
+	}
+	securityHeadersMiddleware := middleware.SecurityHeaders(cfg.Server.ContentSecurityPolicy, cfg.Server.TLS.Enabled)
+	authRateLimitMiddleware := middleware.RateLimit(cfg.Auth.LoginRatePerMinute/60, cfg.Auth.LoginBurst)
 
 	// --- Router Setup ---
-	router := handler.NewRouter(pageHandler, authHandler, seoHandler, authzMiddleware, errorMiddleware, sessionManager)
+	router := handler.NewRouter(pageHandler, authHandler, seoHandler, adminHandler, authzMiddleware, errorMiddleware, sessionExpiryMiddleware, securityHeadersMiddleware, authRateLimitMiddleware, sessionManager)
+
+	// --- Background Jobs ---
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	if cfg.Revisions.PruneIntervalMinutes > 0 {
+		pageService.StartRevisionPruning(jobsCtx, time.Duration(cfg.Revisions.PruneIntervalMinutes)*time.Minute)
+	}
 
 	// --- Server Initialization and Graceful Shutdown ---
 	server := &http.Server{
@@ -134,5 +229,8 @@ func main() {
 	if err := server.Shutdown(ctx); err != nil {
 		log.Fatal(err, "Server forced to shutdown")
 	}
+	if err := pageService.WaitForBackups(ctx); err != nil {
+		log.Error(err, "Gave up waiting for page backups to finish")
+	}
 	log.Info("Server exiting")
 }
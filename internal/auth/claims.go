@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"fmt"
+)
+
+const (
+	// DefaultSubjectClaim identifies users when no subject claim is configured.
+	DefaultSubjectClaim = "sub"
+	// DefaultDisplayNameClaim names users when no display name claim is configured.
+	DefaultDisplayNameClaim = "displayName"
+	// fallbackDisplayNameClaim is used when the configured display name claim is empty.
+	fallbackDisplayNameClaim = "name"
+)
+
+// Identity is the user described by an ID token.
+type Identity struct {
+	// Subject identifies the user in sessions, policies and as the author of their edits.
+	Subject     string
+	DisplayName string
+	Email       string
+	// Picture is the URL of the user's avatar, empty if the provider sent none.
+	Picture string
+	Roles   []string
+}
+
+// IdentityFromClaims extracts the user's identity from the decoded ID token
+// claims, reading the subject and display name from the given claims. The
+// display name falls back to the standard "name" claim. Roles are read from
+// the "roles" claim, as names or as objects with a "name" field (as Casdoor sends them).
+func IdentityFromClaims(claims map[string]interface{}, subjectClaim, displayNameClaim string) (*Identity, error) {
+	if subjectClaim == "" {
+		subjectClaim = DefaultSubjectClaim
+	}
+	if displayNameClaim == "" {
+		displayNameClaim = DefaultDisplayNameClaim
+	}
+	subject := stringClaim(claims, subjectClaim)
+	if subject == "" {
+		return nil, fmt.Errorf("ID token has no %q claim", subjectClaim)
+	}
+	displayName := stringClaim(claims, displayNameClaim)
+	if displayName == "" {
+		displayName = stringClaim(claims, fallbackDisplayNameClaim)
+	}
+
+	identity := &Identity{
+		Subject:     subject,
+		DisplayName: displayName,
+		Email:       stringClaim(claims, "email"),
+		Picture:     stringClaim(claims, "picture"),
+	}
+	roles, _ := claims["roles"].([]interface{})
+	for _, role := range roles {
+		switch role := role.(type) {
+		case string:
+			identity.Roles = append(identity.Roles, role)
+		case map[string]interface{}:
+			if name, _ := role["name"].(string); name != "" {
+				identity.Roles = append(identity.Roles, name)
+			}
+		}
+	}
+	return identity, nil
+}
+
+// stringClaim returns a string or numeric claim as a string, or "" if it is missing.
+func stringClaim(claims map[string]interface{}, name string) string {
+	switch value := claims[name].(type) {
+	case string:
+		return value
+	case float64:
+		return fmt.Sprintf("%.0f", value)
+	default:
+		return ""
+	}
+}
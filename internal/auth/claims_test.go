@@ -0,0 +1,44 @@
+package auth
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIdentityFromClaims(t *testing.T) {
+	claims := map[string]interface{}{
+		"sub":                "f81d4fae-7dec",
+		"name":               "Alice Liddell",
+		"preferred_username": "alice",
+		"email":              "alice@example.com",
+		"roles":              []interface{}{map[string]interface{}{"name": "editor"}, "admin"},
+	}
+
+	t.Run("defaults", func(t *testing.T) {
+		identity, err := IdentityFromClaims(claims, "", "")
+		if err != nil {
+			t.Fatalf("IdentityFromClaims failed: %v", err)
+		}
+		// displayName is missing, so the name claim is used.
+		want := &Identity{Subject: "f81d4fae-7dec", DisplayName: "Alice Liddell", Email: "alice@example.com", Roles: []string{"editor", "admin"}}
+		if !reflect.DeepEqual(identity, want) {
+			t.Errorf("expected %+v, got %+v", want, identity)
+		}
+	})
+
+	t.Run("custom claims", func(t *testing.T) {
+		identity, err := IdentityFromClaims(claims, "email", "preferred_username")
+		if err != nil {
+			t.Fatalf("IdentityFromClaims failed: %v", err)
+		}
+		if identity.Subject != "alice@example.com" || identity.DisplayName != "alice" {
+			t.Errorf("expected subject alice@example.com named alice, got %+v", identity)
+		}
+	})
+
+	t.Run("missing subject claim", func(t *testing.T) {
+		if _, err := IdentityFromClaims(claims, "upn", ""); err == nil {
+			t.Error("expected an error when the subject claim is missing")
+		}
+	})
+}
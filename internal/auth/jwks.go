@@ -0,0 +1,129 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/go-jose/go-jose/v4"
+)
+
+const (
+	// defaultJWKSRefreshInterval is how long fetched keys are used when no interval is configured.
+	defaultJWKSRefreshInterval = time.Hour
+	// defaultJWKSMinRefreshInterval limits forced refreshes when no limit is configured.
+	defaultJWKSMinRefreshInterval = 30 * time.Second
+)
+
+// CachingKeySet verifies ID token signatures against a provider's JSON Web Key
+// Set. The keys are fetched once and reused until the refresh interval has
+// passed. When a token fails verification, e.g. because the provider has
+// rotated its keys, the keys are refreshed once and the token is checked again.
+// Such forced refreshes happen at most once per minimum refresh interval, so
+// invalid tokens cannot make the wiki flood the provider with requests.
+type CachingKeySet struct {
+	jwksURL            string
+	client             *http.Client
+	refreshInterval    time.Duration
+	minRefreshInterval time.Duration
+	now                func() time.Time
+
+	mu        sync.Mutex
+	keys      *oidc.StaticKeySet
+	fetchedAt time.Time // time of the last fetch attempt, successful or not
+}
+
+var _ oidc.KeySet = (*CachingKeySet)(nil)
+
+// NewCachingKeySet creates a key set for the JWKS at jwksURL. Intervals that
+// are not positive fall back to the defaults.
+func NewCachingKeySet(jwksURL string, refreshInterval, minRefreshInterval time.Duration) *CachingKeySet {
+	if refreshInterval <= 0 {
+		refreshInterval = defaultJWKSRefreshInterval
+	}
+	if minRefreshInterval <= 0 {
+		minRefreshInterval = defaultJWKSMinRefreshInterval
+	}
+	return &CachingKeySet{
+		jwksURL:            jwksURL,
+		client:             http.DefaultClient,
+		refreshInterval:    refreshInterval,
+		minRefreshInterval: minRefreshInterval,
+		now:                time.Now,
+	}
+}
+
+// VerifySignature checks the token's signature and returns its payload.
+func (k *CachingKeySet) VerifySignature(ctx context.Context, jwt string) ([]byte, error) {
+	keys, _, err := k.keySet(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := keys.VerifySignature(ctx, jwt)
+	if err == nil {
+		return payload, nil
+	}
+	// The provider may have rotated its keys since they were fetched.
+	keys, refreshed, refreshErr := k.keySet(ctx, true)
+	if refreshErr != nil || !refreshed {
+		return nil, err
+	}
+	return keys.VerifySignature(ctx, jwt)
+}
+
+// keySet returns the cached keys, fetching them first if they are missing or
+// stale, or if force is set and the last fetch is old enough. It reports
+// whether the keys were fetched. If a refresh fails, the cached keys are kept.
+func (k *CachingKeySet) keySet(ctx context.Context, force bool) (*oidc.StaticKeySet, bool, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	age := k.now().Sub(k.fetchedAt)
+	stale := k.keys == nil || age >= k.refreshInterval || (force && age >= k.minRefreshInterval)
+	if !stale {
+		return k.keys, false, nil
+	}
+	k.fetchedAt = k.now()
+	keys, err := k.fetch(ctx)
+	if err != nil {
+		if k.keys != nil {
+			return k.keys, false, nil
+		}
+		return nil, false, err
+	}
+	k.keys = keys
+	return keys, true, nil
+}
+
+// fetch downloads the provider's signing keys.
+func (k *CachingKeySet) fetch(ctx context.Context) (*oidc.StaticKeySet, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, k.jwksURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create JWKS request: %w", err)
+	}
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch JWKS: %s", resp.Status)
+	}
+
+	var jwks jose.JSONWebKeySet
+	if err := json.NewDecoder(resp.Body).Decode(&jwks); err != nil {
+		return nil, fmt.Errorf("failed to decode JWKS: %w", err)
+	}
+	keys := &oidc.StaticKeySet{}
+	for _, key := range jwks.Keys {
+		if key.Use == "enc" {
+			continue
+		}
+		keys.PublicKeys = append(keys.PublicKeys, key.Key)
+	}
+	return keys, nil
+}
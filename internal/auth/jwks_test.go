@@ -0,0 +1,112 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+)
+
+// rotatingJWKS serves a key set whose signing key can be rotated, counting fetches.
+type rotatingJWKS struct {
+	mu      sync.Mutex
+	key     *rsa.PrivateKey
+	keyID   string
+	fetches int
+}
+
+func (s *rotatingJWKS) rotate(t *testing.T, keyID string) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.key, s.keyID = key, keyID
+}
+
+func (s *rotatingJWKS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fetches++
+	json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+		{Key: &s.key.PublicKey, KeyID: s.keyID, Algorithm: string(jose.RS256), Use: "sig"},
+	}})
+}
+
+func (s *rotatingJWKS) sign(t *testing.T, payload string) string {
+	t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: jose.JSONWebKey{Key: s.key, KeyID: s.keyID}}, nil)
+	if err != nil {
+		t.Fatalf("failed to create signer: %v", err)
+	}
+	jws, err := signer.Sign([]byte(payload))
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+	token, err := jws.CompactSerialize()
+	if err != nil {
+		t.Fatalf("failed to serialize: %v", err)
+	}
+	return token
+}
+
+func (s *rotatingJWKS) fetchCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.fetches
+}
+
+func TestCachingKeySet_RefreshesAfterKeyRotation(t *testing.T) {
+	jwks := &rotatingJWKS{}
+	jwks.rotate(t, "key-1")
+	server := httptest.NewServer(jwks)
+	defer server.Close()
+
+	now := time.Now()
+	keySet := NewCachingKeySet(server.URL, time.Hour, time.Minute)
+	keySet.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	if payload, err := keySet.VerifySignature(ctx, jwks.sign(t, `{"sub":"alice"}`)); err != nil || string(payload) != `{"sub":"alice"}` {
+		t.Fatalf("expected the token to verify, got %q (err %v)", payload, err)
+	}
+	if _, err := keySet.VerifySignature(ctx, jwks.sign(t, `{"sub":"alice"}`)); err != nil {
+		t.Fatalf("expected the token to verify with cached keys: %v", err)
+	}
+	if n := jwks.fetchCount(); n != 1 {
+		t.Fatalf("expected the keys to be fetched once, got %d", n)
+	}
+
+	// The provider rotates its key: the first token signed with it forces a refresh.
+	now = now.Add(2 * time.Minute)
+	jwks.rotate(t, "key-2")
+	if _, err := keySet.VerifySignature(ctx, jwks.sign(t, `{"sub":"bob"}`)); err != nil {
+		t.Fatalf("expected the token signed with the rotated key to verify: %v", err)
+	}
+	if n := jwks.fetchCount(); n != 2 {
+		t.Errorf("expected one refresh after rotation, got %d fetches", n)
+	}
+
+	// Tokens that never verify do not refresh again within the minimum interval.
+	stranger := &rotatingJWKS{}
+	stranger.rotate(t, "unknown")
+	for i := 0; i < 3; i++ {
+		if _, err := keySet.VerifySignature(ctx, stranger.sign(t, `{"sub":"mallory"}`)); err == nil {
+			t.Fatal("expected a token signed with an unknown key to be rejected")
+		}
+	}
+	if n := jwks.fetchCount(); n != 2 {
+		t.Errorf("expected forced refreshes to be rate limited, got %d fetches", n)
+	}
+}
@@ -2,7 +2,9 @@ package auth
 
 import (
 	"context"
+	"fmt"
 	"go-wiki-app/internal/config"
+	"time"
 
 	"github.com/coreos/go-oidc/v3/oidc"
 	"golang.org/x/oauth2"
@@ -13,6 +15,8 @@ type Authenticator struct {
 	*oidc.Provider
 	*oauth2.Config
 	*oidc.IDTokenVerifier
+	subjectClaim     string
+	displayNameClaim string
 }
 
 // NewAuthenticator creates a new Authenticator by setting up the OIDC provider
@@ -24,8 +28,19 @@ func NewAuthenticator(cfg *config.OIDCConfig) (*Authenticator, error) {
 		return nil, err
 	}
 
-	// Create an OIDC ID token verifier.
-	verifier := provider.Verifier(&oidc.Config{ClientID: cfg.ClientID})
+	// Create an OIDC ID token verifier whose signing keys are cached and
+	// refreshed when the provider rotates them.
+	var discovery struct {
+		Issuer  string `json:"issuer"`
+		JWKSURL string `json:"jwks_uri"`
+	}
+	if err := provider.Claims(&discovery); err != nil {
+		return nil, fmt.Errorf("failed to read provider configuration: %w", err)
+	}
+	keySet := NewCachingKeySet(discovery.JWKSURL,
+		time.Duration(cfg.JWKSRefreshMinutes)*time.Minute,
+		time.Duration(cfg.JWKSMinRefreshSeconds)*time.Second)
+	verifier := oidc.NewVerifier(discovery.Issuer, keySet, &oidc.Config{ClientID: cfg.ClientID})
 
 	// Create a new OAuth2 config with the credentials and endpoints from the provider.
 	oauth2Config := &oauth2.Config{
@@ -41,8 +56,19 @@ func NewAuthenticator(cfg *config.OIDCConfig) (*Authenticator, error) {
 	}
 
 	return &Authenticator{
-		Provider:        provider,
-		Config:          oauth2Config,
-		IDTokenVerifier: verifier,
+		Provider:         provider,
+		Config:           oauth2Config,
+		IDTokenVerifier:  verifier,
+		subjectClaim:     cfg.SubjectClaim,
+		displayNameClaim: cfg.DisplayNameClaim,
 	}, nil
 }
+
+// Identity reads the user's identity from a verified ID token, using the configured claims.
+func (a *Authenticator) Identity(idToken *oidc.IDToken) (*Identity, error) {
+	var claims map[string]interface{}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, err
+	}
+	return IdentityFromClaims(claims, a.subjectClaim, a.displayNameClaim)
+}
@@ -10,7 +10,10 @@ import (
 // SeedDefaultPolicies ensures that the application has a baseline set of authorization rules.
 // It checks if each default policy exists before adding it, making the operation idempotent
 // and safe to run on every application start.
-func SeedDefaultPolicies(e casbin.IEnforcer, log logger.Logger) {
+//
+// When allowAnonymousEdit is set, anonymous users may also edit and save pages;
+// otherwise those grants are removed in case an earlier start added them.
+func SeedDefaultPolicies(e casbin.IEnforcer, log logger.Logger, allowAnonymousEdit bool) {
 	log.Info("Seeding default authorization policies...")
 
 	// Default policies grant basic access to anonymous users and content management
@@ -18,21 +21,93 @@ func SeedDefaultPolicies(e casbin.IEnforcer, log logger.Logger) {
 	policies := [][]string{
 		// Anonymous users can view pages and access login/callback routes.
 		{"anonymous", "/view/*", "GET"},
+		{"anonymous", "/sse/page/*", "GET"},
+		{"anonymous", "/history/*", "GET"},
+		{"anonymous", "/diff/*", "GET"},
 		{"anonymous", "/auth/login", "GET"},
 		{"anonymous", "/auth/callback", "GET"},
 		{"anonymous", "/categories", "GET"},
+		{"anonymous", "/categories/export", "GET"},
 		{"anonymous", "/category/*", "GET"},
+		{"anonymous", "/changes", "GET"},
+		{"anonymous", "/my/pages", "GET"},
+		{"anonymous", "/stubs", "GET"},
+		{"anonymous", "/search", "GET"},
+		{"anonymous", "/export/*", "GET"},
 		{"anonymous", "/api/search/categories", "GET"},
+		{"anonymous", "/api/search/pages", "GET"},
+		{"anonymous", "/api/pages/batch", "POST"},
+		{"anonymous", "/api/v1/pages", "GET"},
+		{"anonymous", "/api/v1/pages/*", "GET"},
+		{"anonymous", "/api/v1/pages/batch", "POST"},
 
-		// Editors can do everything anonymous users can, plus edit, save, and list pages.
+		// Editors can do everything anonymous users can, plus edit, save, list and delete pages, and rename categories.
 		{"editor", "/edit/*", "GET"},
 		{"editor", "/save/*", "POST"},
+		{"editor", "/create/*", "POST"},
+		{"editor", "/rollback/*", "POST"},
 		{"editor", "/list", "GET"},
+		{"editor", "/api/v1/pages", "POST"},
+		{"editor", "/api/v1/entries", "POST"},
+		{"editor", "/api/v1/pages/*", "PUT"},
+		{"editor", "/api/v1/pages/*", "DELETE"},
+		{"editor", "/archived", "GET"},
+		{"editor", "/delete/*", "POST"},
+		{"editor", "/move/*", "POST"},
+		{"editor", "/trash", "GET"},
+		{"editor", "/trash/restore/*", "POST"},
+		{"editor", "/categories/*/rename", "POST"},
+
+		// Moderators can additionally review the edits held for approval.
+		{"moderator", "/admin/review", "GET"},
+		{"moderator", "/admin/review/*", "POST"},
+
+		// Admins can additionally see the dashboard and run maintenance.
+		{"admin", "/admin", "GET"},
+		{"admin", "/status", "GET"},
+		{"admin", "/admin/revisions/prune", "POST"},
+		{"admin", "/admin/resanitize", "POST"},
+		{"admin", "/admin/lockdown", "POST"},
+		{"admin", "/admin/contributions/*", "GET"},
+		{"admin", "/admin/contributions/*", "POST"},
+		{"admin", "/admin/check-links", "POST"},
+		{"admin", "/admin/dead-external-links", "GET"},
+		{"admin", "/admin/pages/*", "POST"},
+		{"admin", "/admin/categories/merge", "POST"},
+		{"admin", "/admin/categories/order", "POST"},
+		{"admin", "/categories/*", "DELETE"},
+		{"admin", "/trash/purge/*", "POST"},
 	}
 	for _, p := range policies {
 		if has, _ := e.HasPolicy(p); !has {
 			if _, err := e.AddPolicy(p); err != nil {
 				log.Error(err, fmt.Sprintf("Failed to add policy %v", p))
+			} else {
+				log.Debug(fmt.Sprintf("Added policy %v", p))
+			}
+		}
+	}
+
+	// Open-wiki mode lets anonymous users edit and create pages.
+	anonymousEdit := [][]string{
+		{"anonymous", "/edit/*", "GET"},
+		{"anonymous", "/save/*", "POST"},
+		{"anonymous", "/create/*", "POST"},
+	}
+	for _, p := range anonymousEdit {
+		has, _ := e.HasPolicy(p)
+		switch {
+		case allowAnonymousEdit && !has:
+			if _, err := e.AddPolicy(p); err != nil {
+				log.Error(err, fmt.Sprintf("Failed to add policy %v", p))
+			} else {
+				log.Debug(fmt.Sprintf("Added policy %v", p))
+			}
+		case !allowAnonymousEdit && has:
+			if _, err := e.RemovePolicy(p); err != nil {
+				log.Error(err, fmt.Sprintf("Failed to remove policy %v", p))
+			} else {
+				log.Debug(fmt.Sprintf("Removed policy %v", p))
 			}
 		}
 	}
@@ -43,5 +118,22 @@ func SeedDefaultPolicies(e casbin.IEnforcer, log logger.Logger) {
 			log.Error(err, "Failed to add role 'editor' -> 'anonymous'")
 		}
 	}
+	// Granting the 'moderator' role all permissions of the 'editor' role.
+	if has, _ := e.HasRoleForUser("moderator", "editor"); !has {
+		if _, err := e.AddRoleForUser("moderator", "editor"); err != nil {
+			log.Error(err, "Failed to add role 'moderator' -> 'editor'")
+		}
+	}
+	// Granting the 'admin' role all permissions of the 'editor' and 'moderator' roles.
+	if has, _ := e.HasRoleForUser("admin", "editor"); !has {
+		if _, err := e.AddRoleForUser("admin", "editor"); err != nil {
+			log.Error(err, "Failed to add role 'admin' -> 'editor'")
+		}
+	}
+	if has, _ := e.HasRoleForUser("admin", "moderator"); !has {
+		if _, err := e.AddRoleForUser("admin", "moderator"); err != nil {
+			log.Error(err, "Failed to add role 'admin' -> 'moderator'")
+		}
+	}
 	log.Info("Policy seeding complete.")
 }
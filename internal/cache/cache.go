@@ -1,24 +1,62 @@
 package cache
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"go-wiki-app/internal/config"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
 	"github.com/jmoiron/sqlx"
 	_ "modernc.org/sqlite"
 )
 
+// Version is the format of the cached values. Every key is stored under a
+// "v<Version>:" prefix, so bumping it when the shape of a cached value changes,
+// such as a field added to data.Page, turns the entries written by older
+// builds into misses instead of decoding them into the wrong shape.
+const Version = 6
+
 // Cache provides a SQLite-based caching mechanism.
 type Cache struct {
-	db *sqlx.DB
+	db     *sqlx.DB
+	prefix string // the version namespace prepended to every key
+	hits   atomic.Int64
+	misses atomic.Int64
+
+	janitorMu   sync.Mutex
+	stopJanitor context.CancelFunc
+	janitorDone chan struct{}
+}
+
+// Stats counts cache lookups since the cache was opened.
+type Stats struct {
+	Hits   int64
+	Misses int64
+}
+
+// HitRate returns the fraction of lookups that were hits, or 0 if there were none.
+func (s Stats) HitRate() float64 {
+	if total := s.Hits + s.Misses; total > 0 {
+		return float64(s.Hits) / float64(total)
+	}
+	return 0
 }
 
 // New creates a new Cache instance.
-// It opens the SQLite database at the given file path and ensures the
-// cache table is created.
+// It opens the SQLite database at the given file path, ensures the
+// cache table is created and purges entries written under another Version.
 func New(cfg config.CacheConfig) (*Cache, error) {
+	return open(cfg, Version)
+}
+
+// open creates a Cache whose keys are namespaced under the given version.
+func open(cfg config.CacheConfig, version int) (*Cache, error) {
 	db, err := sqlx.Connect("sqlite", cfg.FilePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to sqlite cache: %w", err)
@@ -49,7 +87,13 @@ func New(cfg config.CacheConfig) (*Cache, error) {
 		return nil, fmt.Errorf("failed to create cache schema: %w", err)
 	}
 
-	return &Cache{db: db}, nil
+	c := &Cache{db: db, prefix: "v" + strconv.Itoa(version) + ":"}
+	// Entries of other versions could never be read again, so they are dropped
+	// rather than left for the janitor to expire.
+	if _, err := db.Exec(`DELETE FROM cache WHERE substr(key, 1, ?) != ?`, len(c.prefix), c.prefix); err != nil {
+		return nil, fmt.Errorf("failed to purge old cache entries: %w", err)
+	}
+	return c, nil
 }
 
 // Get retrieves an item from the cache. It returns nil if the item is not found or is expired.
@@ -59,9 +103,10 @@ func (c *Cache) Get(key string) ([]byte, error) {
 		ExpiresAt int64  `db:"expires_at"`
 	}
 	query := `SELECT value, expires_at FROM cache WHERE key = ?`
-	err := c.db.Get(&item, query, key)
+	err := c.db.Get(&item, query, c.prefix+key)
 	if err != nil {
 		if err == sql.ErrNoRows {
+			c.misses.Add(1)
 			return nil, nil // Not found is not an error for a cache miss.
 		}
 		return nil, fmt.Errorf("failed to get item from cache: %w", err)
@@ -71,9 +116,11 @@ func (c *Cache) Get(key string) ([]byte, error) {
 	if time.Now().Unix() > item.ExpiresAt {
 		// Item has expired, delete it from the cache (best effort)
 		_ = c.Delete(key)
+		c.misses.Add(1)
 		return nil, nil // Treat as a cache miss
 	}
 
+	c.hits.Add(1)
 	return item.Value, nil
 }
 
@@ -81,7 +128,7 @@ func (c *Cache) Get(key string) ([]byte, error) {
 func (c *Cache) Set(key string, value []byte, ttl time.Duration) error {
 	expiresAt := time.Now().Add(ttl).Unix()
 	query := `INSERT OR REPLACE INTO cache (key, value, expires_at) VALUES (?, ?, ?)`
-	_, err := c.db.Exec(query, key, value, expiresAt)
+	_, err := c.db.Exec(query, c.prefix+key, value, expiresAt)
 	if err != nil {
 		return fmt.Errorf("failed to set item in cache: %w", err)
 	}
@@ -91,14 +138,85 @@ func (c *Cache) Set(key string, value []byte, ttl time.Duration) error {
 // Delete removes an item from the cache.
 func (c *Cache) Delete(key string) error {
 	query := `DELETE FROM cache WHERE key = ?`
-	_, err := c.db.Exec(query, key)
+	_, err := c.db.Exec(query, c.prefix+key)
 	if err != nil {
 		return fmt.Errorf("failed to delete item from cache: %w", err)
 	}
 	return nil
 }
 
-// Close closes the database connection.
+// likeEscaper escapes the LIKE wildcards, and the escape character itself.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// DeleteByPrefix removes every item whose key starts with prefix, such as all
+// "dashboard:" counts. The prefix is matched literally and case-sensitively.
+func (c *Cache) DeleteByPrefix(prefix string) error {
+	// SQLite's LIKE ignores ASCII case, so the prefix is compared exactly as well.
+	prefix = c.prefix + prefix
+	query := `DELETE FROM cache WHERE key LIKE ? ESCAPE '\' AND substr(key, 1, ?) = ?`
+	_, err := c.db.Exec(query, likeEscaper.Replace(prefix)+"%", utf8.RuneCountInString(prefix), prefix)
+	if err != nil {
+		return fmt.Errorf("failed to delete items from cache: %w", err)
+	}
+	return nil
+}
+
+// Stats returns the number of hits and misses recorded by Get.
+func (c *Cache) Stats() Stats {
+	return Stats{Hits: c.hits.Load(), Misses: c.misses.Load()}
+}
+
+// StartJanitor deletes expired entries every interval in the background, so
+// keys that are never read again do not accumulate. It does nothing if the
+// interval is not positive or the janitor is already running.
+func (c *Cache) StartJanitor(interval time.Duration) {
+	c.janitorMu.Lock()
+	defer c.janitorMu.Unlock()
+	if interval <= 0 || c.stopJanitor != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	c.stopJanitor = cancel
+	c.janitorDone = make(chan struct{})
+
+	go func() {
+		defer close(c.janitorDone)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				_, _ = c.sweep() // best effort; the next tick tries again
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the janitor, if it is running, and waits for it to finish.
+func (c *Cache) Stop() {
+	c.janitorMu.Lock()
+	defer c.janitorMu.Unlock()
+	if c.stopJanitor == nil {
+		return
+	}
+	c.stopJanitor()
+	<-c.janitorDone
+	c.stopJanitor = nil
+}
+
+// sweep deletes every expired entry and returns how many were deleted.
+func (c *Cache) sweep() (int64, error) {
+	result, err := c.db.Exec(`DELETE FROM cache WHERE expires_at < ?`, time.Now().Unix())
+	if err != nil {
+		return 0, fmt.Errorf("failed to sweep expired cache items: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// Close stops the janitor and closes the database connection.
 func (c *Cache) Close() error {
+	c.Stop()
 	return c.db.Close()
 }
@@ -0,0 +1,151 @@
+//go:build unit
+
+package cache
+
+import (
+	"go-wiki-app/internal/config"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCache_SweepDeletesExpiredEntries(t *testing.T) {
+	c, err := New(config.CacheConfig{FilePath: filepath.Join(t.TempDir(), "cache.db")})
+	if err != nil {
+		t.Fatalf("failed to create cache: %v", err)
+	}
+	defer c.Close()
+
+	if err := c.Set("stale", []byte("old"), -time.Minute); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := c.Set("fresh", []byte("new"), time.Minute); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	deleted, err := c.sweep()
+	if err != nil {
+		t.Fatalf("sweep failed: %v", err)
+	}
+	if deleted != 1 {
+		t.Errorf("expected 1 expired entry to be deleted, got %d", deleted)
+	}
+	var rows int
+	if err := c.db.Get(&rows, `SELECT COUNT(*) FROM cache WHERE key = ?`, c.prefix+"stale"); err != nil {
+		t.Fatalf("failed to count rows: %v", err)
+	}
+	if rows != 0 {
+		t.Error("expected the expired row to be gone")
+	}
+	if value, _ := c.Get("fresh"); string(value) != "new" {
+		t.Errorf("expected the fresh entry to be kept, got %q", value)
+	}
+}
+
+func TestCache_JanitorStopsOnClose(t *testing.T) {
+	c, err := New(config.CacheConfig{FilePath: filepath.Join(t.TempDir(), "cache.db")})
+	if err != nil {
+		t.Fatalf("failed to create cache: %v", err)
+	}
+	if err := c.Set("stale", []byte("old"), -time.Minute); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	c.StartJanitor(10 * time.Millisecond)
+	c.StartJanitor(10 * time.Millisecond) // a second start is a no-op
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		var rows int
+		if err := c.db.Get(&rows, `SELECT COUNT(*) FROM cache`); err != nil {
+			t.Fatalf("failed to count rows: %v", err)
+		}
+		if rows == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the janitor to delete the expired entry")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		c.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected Close to stop the janitor")
+	}
+}
+
+func TestCache_DeleteByPrefix(t *testing.T) {
+	c, err := New(config.CacheConfig{FilePath: filepath.Join(t.TempDir(), "cache.db")})
+	if err != nil {
+		t.Fatalf("failed to create cache: %v", err)
+	}
+	defer c.Close()
+
+	keys := []string{"page:Home", "page:Guide", "pages:all", "Page:Upper", "100%:done", "100x:other", "a_b:one", "axb:two", "user:alice"}
+	for _, key := range keys {
+		if err := c.Set(key, []byte("v"), time.Minute); err != nil {
+			t.Fatalf("Set(%q) failed: %v", key, err)
+		}
+	}
+
+	for _, prefix := range []string{"page:", "100%", "a_b"} {
+		if err := c.DeleteByPrefix(prefix); err != nil {
+			t.Fatalf("DeleteByPrefix(%q) failed: %v", prefix, err)
+		}
+	}
+
+	deleted := map[string]bool{"page:Home": true, "page:Guide": true, "100%:done": true, "a_b:one": true}
+	for _, key := range keys {
+		value, err := c.Get(key)
+		if err != nil {
+			t.Fatalf("Get(%q) failed: %v", key, err)
+		}
+		if deleted[key] && value != nil {
+			t.Errorf("expected %q to be deleted", key)
+		}
+		if !deleted[key] && value == nil {
+			t.Errorf("expected %q to be kept", key)
+		}
+	}
+}
+
+func TestCache_VersionBumpIgnoresOldEntries(t *testing.T) {
+	cfg := config.CacheConfig{FilePath: filepath.Join(t.TempDir(), "cache.db")}
+	old, err := open(cfg, Version-1)
+	if err != nil {
+		t.Fatalf("failed to create cache: %v", err)
+	}
+	if err := old.Set("page:Home", []byte(`{"title":"Home"}`), time.Minute); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	old.Close()
+
+	c, err := New(cfg)
+	if err != nil {
+		t.Fatalf("failed to reopen cache: %v", err)
+	}
+	defer c.Close()
+	if value, err := c.Get("page:Home"); err != nil || value != nil {
+		t.Errorf("expected an entry of the previous version to be a miss, got %q (err %v)", value, err)
+	}
+	var rows int
+	if err := c.db.Get(&rows, `SELECT COUNT(*) FROM cache`); err != nil {
+		t.Fatalf("failed to count rows: %v", err)
+	}
+	if rows != 0 {
+		t.Errorf("expected the old entry to be purged on startup, found %d rows", rows)
+	}
+
+	if err := c.Set("page:Home", []byte("new"), time.Minute); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if value, _ := c.Get("page:Home"); string(value) != "new" {
+		t.Errorf("expected entries of the current version to be read back, got %q", value)
+	}
+}
@@ -1,10 +1,21 @@
 package data
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 	"github.com/jmoiron/sqlx"
 )
 
+// ErrCategoryExists is returned when a category would take the name of another
+// category under the same parent.
+var ErrCategoryExists = errors.New("a category with this name already exists")
+
+// ErrCategoryHasSubcategories is returned when a category that still has
+// subcategories is deleted.
+var ErrCategoryHasSubcategories = errors.New("category has subcategories")
+
 // CategoryRepository handles database operations for categories.
 type CategoryRepository struct {
 	DB *sqlx.DB
@@ -22,10 +33,10 @@ func (r *CategoryRepository) FindByName(name string, parentID *int64) (*Category
 	query := "SELECT id, name, parent_id FROM categories WHERE name = ? AND parent_id "
 	if parentID == nil {
 		query += "IS NULL"
-		err = r.DB.Get(&category, query, name)
+		err = r.DB.Get(&category, r.DB.Rebind(query), name)
 	} else {
 		query += "= ?"
-		err = r.DB.Get(&category, query, name, *parentID)
+		err = r.DB.Get(&category, r.DB.Rebind(query), name, *parentID)
 	}
 
 	if err != nil {
@@ -37,20 +48,22 @@ func (r *CategoryRepository) FindByName(name string, parentID *int64) (*Category
 	return &category, nil
 }
 
-// SearchByName searches for categories by name.
+// SearchByName searches for categories by name, ignoring case and accents.
 func (r *CategoryRepository) SearchByName(query string) ([]*Category, error) {
 	var categories []*Category
-	err := r.DB.Select(&categories, "SELECT id, name, parent_id FROM categories WHERE name LIKE ?", "%"+query+"%")
+	pattern := "%" + likeEscaper.Replace(normalizeForSearch(query)) + "%"
+	err := r.DB.Select(&categories, r.DB.Rebind("SELECT id, name, parent_id FROM categories WHERE search_name LIKE ? ESCAPE '!'"), pattern)
 	if err != nil {
 		return nil, err
 	}
 	return categories, nil
 }
 
-// GetAll retrieves all categories from the database.
+// GetAll retrieves all categories from the database, ordered by weight and
+// then by name.
 func (r *CategoryRepository) GetAll() ([]*Category, error) {
 	var categories []*Category
-	err := r.DB.Select(&categories, "SELECT id, name, parent_id FROM categories ORDER BY name")
+	err := r.DB.Select(&categories, "SELECT id, name, parent_id, order_weight FROM categories ORDER BY order_weight, name")
 	if err != nil {
 		return nil, err
 	}
@@ -59,21 +72,18 @@ func (r *CategoryRepository) GetAll() ([]*Category, error) {
 
 // Save creates a new category and returns its ID.
 func (r *CategoryRepository) Save(category *Category) (int64, error) {
-	res, err := r.DB.NamedExec("INSERT INTO categories (name, parent_id) VALUES (:name, :parent_id)", category)
-	if err != nil {
-		return 0, err
-	}
-	id, err := res.LastInsertId()
-	if err != nil {
-		return 0, err
-	}
-	return id, nil
+	return insertReturningID(context.Background(), r.DB, "INSERT INTO categories (name, parent_id, search_name) VALUES (:name, :parent_id, :search_name)",
+		map[string]interface{}{
+			"name":        category.Name,
+			"parent_id":   category.ParentID,
+			"search_name": normalizeForSearch(category.Name),
+		})
 }
 
 // GetByID finds a category by its ID.
 func (r *CategoryRepository) GetByID(id int64) (*Category, error) {
 	var category Category
-	err := r.DB.Get(&category, "SELECT id, name, parent_id FROM categories WHERE id = ?", id)
+	err := r.DB.Get(&category, r.DB.Rebind("SELECT id, name, parent_id FROM categories WHERE id = ?"), id)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil // Not found is not an error
@@ -82,3 +92,223 @@ func (r *CategoryRepository) GetByID(id int64) (*Category, error) {
 	}
 	return &category, nil
 }
+
+// SetPageCategories replaces the categories a page is tagged with.
+func (r *CategoryRepository) SetPageCategories(ctx context.Context, pageID int64, categoryIDs []int64) error {
+	tx, err := r.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin tagging page %d: %w", pageID, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, tx.Rebind("DELETE FROM page_categories WHERE page_id = ?"), pageID); err != nil {
+		return fmt.Errorf("failed to clear the categories of page %d: %w", pageID, err)
+	}
+	tagged := make(map[int64]bool)
+	for _, id := range categoryIDs {
+		if tagged[id] {
+			continue
+		}
+		tagged[id] = true
+		if _, err := tx.ExecContext(ctx, tx.Rebind("INSERT INTO page_categories (page_id, category_id) VALUES (?, ?)"), pageID, id); err != nil {
+			return fmt.Errorf("failed to tag page %d with category %d: %w", pageID, id, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit the categories of page %d: %w", pageID, err)
+	}
+	return nil
+}
+
+// GetCategoriesForPage retrieves the categories a page is tagged with, ordered by name.
+func (r *CategoryRepository) GetCategoriesForPage(ctx context.Context, pageID int64) ([]*Category, error) {
+	categories := []*Category{}
+	query := `SELECT c.id, c.name, c.parent_id FROM categories c
+		JOIN page_categories pc ON pc.category_id = c.id
+		WHERE pc.page_id = ? ORDER BY c.name, c.id`
+	if err := r.DB.SelectContext(ctx, &categories, r.DB.Rebind(query), pageID); err != nil {
+		return nil, fmt.Errorf("failed to get the categories of page %d: %w", pageID, err)
+	}
+	return categories, nil
+}
+
+// UpdateOrder sets the order weights of the given categories, keyed by ID, in
+// one transaction. Categories left out keep their weight.
+func (r *CategoryRepository) UpdateOrder(ctx context.Context, weights map[int64]int) error {
+	tx, err := r.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin category reordering: %w", err)
+	}
+	defer tx.Rollback()
+
+	for id, weight := range weights {
+		if _, err := tx.ExecContext(ctx, tx.Rebind("UPDATE categories SET order_weight = ? WHERE id = ?"), weight, id); err != nil {
+			return fmt.Errorf("failed to set the order weight of category %d: %w", id, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit category reordering: %w", err)
+	}
+	return nil
+}
+
+// Rename changes the name of a category, keeping its pages and subcategories.
+// It fails with ErrCategoryExists if a sibling already has the new name, and
+// with an error wrapping sql.ErrNoRows if there is no such category.
+func (r *CategoryRepository) Rename(ctx context.Context, id int64, newName string) error {
+	tx, err := r.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin category rename: %w", err)
+	}
+	defer tx.Rollback()
+
+	var category Category
+	if err := tx.GetContext(ctx, &category, tx.Rebind("SELECT id, name, parent_id FROM categories WHERE id = ?"), id); err != nil {
+		return fmt.Errorf("failed to get category %d: %w", id, err)
+	}
+	// The unique key does not cover top-level categories, whose parent is NULL,
+	// so siblings are checked here.
+	query := "SELECT COUNT(*) FROM categories WHERE name = ? AND id <> ? AND parent_id "
+	args := []interface{}{newName, id}
+	if category.ParentID == nil {
+		query += "IS NULL"
+	} else {
+		query += "= ?"
+		args = append(args, *category.ParentID)
+	}
+	var siblings int
+	if err := tx.GetContext(ctx, &siblings, tx.Rebind(query), args...); err != nil {
+		return fmt.Errorf("failed to check the siblings of category %d: %w", id, err)
+	}
+	if siblings > 0 {
+		return fmt.Errorf("%w: %q", ErrCategoryExists, newName)
+	}
+	if _, err := tx.ExecContext(ctx, tx.Rebind("UPDATE categories SET name = ?, search_name = ? WHERE id = ?"), newName, normalizeForSearch(newName), id); err != nil {
+		return fmt.Errorf("failed to rename category %d: %w", id, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit category rename: %w", err)
+	}
+	return nil
+}
+
+// Delete removes a category without subcategories in one transaction, first
+// moving its pages, including those in the trash, to the category targetID.
+// Pages only tagged with it are retagged with the target when retag is set and
+// untagged otherwise. It fails with ErrCategoryHasSubcategories if the category
+// still has subcategories.
+func (r *CategoryRepository) Delete(ctx context.Context, id, targetID int64, retag bool) error {
+	tx, err := r.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin category deletion: %w", err)
+	}
+	defer tx.Rollback()
+
+	var children int
+	if err := tx.GetContext(ctx, &children, tx.Rebind("SELECT COUNT(*) FROM categories WHERE parent_id = ?"), id); err != nil {
+		return fmt.Errorf("failed to count subcategories of category %d: %w", id, err)
+	}
+	if children > 0 {
+		return fmt.Errorf("%w: category %d has %d", ErrCategoryHasSubcategories, id, children)
+	}
+	if retag {
+		if err := movePages(ctx, tx, id, targetID); err != nil {
+			return err
+		}
+	} else {
+		if _, err := tx.ExecContext(ctx, tx.Rebind("UPDATE pages SET category_id = ? WHERE category_id = ?"), targetID, id); err != nil {
+			return fmt.Errorf("failed to move pages from category %d to %d: %w", id, targetID, err)
+		}
+		// The moved pages stay tagged with their own category.
+		tagOwn := `INSERT INTO page_categories (page_id, category_id)
+			SELECT page_id, ? FROM page_categories WHERE category_id = ?
+			AND page_id IN (SELECT id FROM pages WHERE category_id = ?)
+			AND page_id NOT IN (SELECT page_id FROM page_categories WHERE category_id = ?)`
+		if _, err := tx.ExecContext(ctx, tx.Rebind(tagOwn), targetID, id, targetID, targetID); err != nil {
+			return fmt.Errorf("failed to retag pages from category %d to %d: %w", id, targetID, err)
+		}
+		if _, err := tx.ExecContext(ctx, tx.Rebind("DELETE FROM page_categories WHERE category_id = ?"), id); err != nil {
+			return fmt.Errorf("failed to untag pages from category %d: %w", id, err)
+		}
+	}
+	result, err := tx.ExecContext(ctx, tx.Rebind("DELETE FROM categories WHERE id = ?"), id)
+	if err != nil {
+		return fmt.Errorf("failed to delete category %d: %w", id, err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("failed to delete category %d: %w", id, sql.ErrNoRows)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit category deletion: %w", err)
+	}
+	return nil
+}
+
+// Merge moves the pages and subcategories of the category source into target
+// and deletes source, all in one transaction. A subcategory of source named
+// like a subcategory of target is merged into it in turn rather than moved,
+// since names are unique under a parent.
+func (r *CategoryRepository) Merge(ctx context.Context, sourceID, targetID int64) error {
+	tx, err := r.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin category merge: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := mergeCategory(ctx, tx, sourceID, targetID); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit category merge: %w", err)
+	}
+	return nil
+}
+
+// mergeCategory merges the category source into target within the transaction.
+func mergeCategory(ctx context.Context, tx *sqlx.Tx, sourceID, targetID int64) error {
+	var children []*Category
+	if err := tx.SelectContext(ctx, &children, tx.Rebind("SELECT id, name, parent_id FROM categories WHERE parent_id = ?"), sourceID); err != nil {
+		return fmt.Errorf("failed to list subcategories of category %d: %w", sourceID, err)
+	}
+	for _, child := range children {
+		var existingID int64
+		err := tx.GetContext(ctx, &existingID, tx.Rebind("SELECT id FROM categories WHERE name = ? AND parent_id = ?"), child.Name, targetID)
+		switch {
+		case err == nil:
+			if err := mergeCategory(ctx, tx, child.ID, existingID); err != nil {
+				return err
+			}
+		case errors.Is(err, sql.ErrNoRows):
+			if _, err := tx.ExecContext(ctx, tx.Rebind("UPDATE categories SET parent_id = ? WHERE id = ?"), targetID, child.ID); err != nil {
+				return fmt.Errorf("failed to move subcategory %d: %w", child.ID, err)
+			}
+		default:
+			return fmt.Errorf("failed to look up subcategory %q of category %d: %w", child.Name, targetID, err)
+		}
+	}
+	if err := movePages(ctx, tx, sourceID, targetID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, tx.Rebind("DELETE FROM categories WHERE id = ?"), sourceID); err != nil {
+		return fmt.Errorf("failed to delete merged category %d: %w", sourceID, err)
+	}
+	return nil
+}
+
+// movePages moves every page, including those in the trash, from one category
+// to another, along with the pages only tagged with it.
+func movePages(ctx context.Context, tx *sqlx.Tx, fromID, toID int64) error {
+	if _, err := tx.ExecContext(ctx, tx.Rebind("UPDATE pages SET category_id = ? WHERE category_id = ?"), toID, fromID); err != nil {
+		return fmt.Errorf("failed to move pages from category %d to %d: %w", fromID, toID, err)
+	}
+	retag := `INSERT INTO page_categories (page_id, category_id)
+		SELECT page_id, ? FROM page_categories WHERE category_id = ?
+		AND page_id NOT IN (SELECT page_id FROM page_categories WHERE category_id = ?)`
+	if _, err := tx.ExecContext(ctx, tx.Rebind(retag), toID, fromID, toID); err != nil {
+		return fmt.Errorf("failed to retag pages from category %d to %d: %w", fromID, toID, err)
+	}
+	if _, err := tx.ExecContext(ctx, tx.Rebind("DELETE FROM page_categories WHERE category_id = ?"), fromID); err != nil {
+		return fmt.Errorf("failed to untag pages from category %d: %w", fromID, err)
+	}
+	return nil
+}
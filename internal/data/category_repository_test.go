@@ -3,6 +3,10 @@
 package data
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 	"testing"
 
 	"github.com/jmoiron/sqlx"
@@ -32,6 +36,8 @@ func setupCategoryTest(t *testing.T) (*CategoryRepository, func()) {
 		id INTEGER PRIMARY KEY,
 		name TEXT NOT NULL,
 		parent_id INTEGER,
+		search_name TEXT,
+		order_weight INTEGER NOT NULL DEFAULT 0,
 		FOREIGN KEY (parent_id) REFERENCES categories(id) ON DELETE CASCADE,
 		UNIQUE (name, parent_id)
 	);`
@@ -193,3 +199,302 @@ func TestCategoryRepository_SearchByName(t *testing.T) {
 		t.Errorf("expected 2 results, got %d", len(results))
 	}
 }
+
+func TestCategoryRepository_SearchByName_IgnoresCaseAndAccents(t *testing.T) {
+	repo, teardown := setupCategoryTest(t)
+	defer teardown()
+
+	if _, err := repo.Save(&Category{Name: "Économie"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Save(&Category{Name: "economics"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, query := range []string{"econom", "ÉCONOM", "Économ"} {
+		results, err := repo.SearchByName(query)
+		if err != nil {
+			t.Fatalf("SearchByName(%q) failed: %v", query, err)
+		}
+		if len(results) != 2 {
+			t.Errorf("SearchByName(%q): expected 2 results, got %d", query, len(results))
+		}
+	}
+}
+
+func TestCategoryRepository_Merge(t *testing.T) {
+	repo, teardown := setupCategoryTest(t)
+	defer teardown()
+	repo.DB.MustExec(`CREATE TABLE pages (
+		id INTEGER PRIMARY KEY,
+		title TEXT NOT NULL,
+		category_id INTEGER,
+		FOREIGN KEY (category_id) REFERENCES categories(id) ON DELETE SET NULL
+	)`)
+	repo.DB.MustExec(`CREATE TABLE page_categories (
+		page_id INTEGER NOT NULL,
+		category_id INTEGER NOT NULL,
+		PRIMARY KEY (page_id, category_id)
+	)`)
+	save := func(name string, parentID *int64) int64 {
+		id, err := repo.Save(&Category{Name: name, ParentID: parentID})
+		if err != nil {
+			t.Fatalf("Save(%q) failed: %v", name, err)
+		}
+		return id
+	}
+	source := save("JS", nil)
+	target := save("JavaScript", nil)
+	sourceFrameworks := save("Frameworks", &source)
+	basics := save("Basics", &source)
+	targetFrameworks := save("Frameworks", &target)
+	save("Tools", &target)
+	// Frameworks/UI exists under both, so it is merged one level further down.
+	sourceUI := save("UI", &sourceFrameworks)
+	targetUI := save("UI", &targetFrameworks)
+	pages := map[string]int64{"React": sourceFrameworks, "Closures": basics, "Vue": targetFrameworks, "Overview": source, "Svelte": sourceUI}
+	for title, categoryID := range pages {
+		repo.DB.MustExec(`INSERT INTO pages (title, category_id) VALUES (?, ?)`, title, categoryID)
+	}
+
+	if err := repo.Merge(context.Background(), source, target); err != nil {
+		t.Fatalf("Merge failed: %v", err)
+	}
+
+	categoryOf := func(title string) int64 {
+		var id int64
+		if err := repo.DB.Get(&id, `SELECT category_id FROM pages WHERE title = ?`, title); err != nil {
+			t.Fatalf("failed to get the category of %q: %v", title, err)
+		}
+		return id
+	}
+	want := map[string]int64{"React": targetFrameworks, "Closures": basics, "Vue": targetFrameworks, "Overview": target, "Svelte": targetUI}
+	for title, id := range want {
+		if got := categoryOf(title); got != id {
+			t.Errorf("%s: expected category %d, got %d", title, id, got)
+		}
+	}
+	for _, id := range []int64{source, sourceFrameworks, sourceUI} {
+		if c, _ := repo.GetByID(id); c != nil {
+			t.Errorf("expected category %d to be deleted, got %+v", id, c)
+		}
+	}
+	if moved, _ := repo.GetByID(basics); moved == nil || moved.ParentID == nil || *moved.ParentID != target {
+		t.Errorf("expected Basics to move under JavaScript, got %+v", moved)
+	}
+	var children []string
+	if err := repo.DB.Select(&children, `SELECT name FROM categories WHERE parent_id = ? ORDER BY name`, target); err != nil {
+		t.Fatalf("failed to list subcategories: %v", err)
+	}
+	if fmt.Sprint(children) != "[Basics Frameworks Tools]" {
+		t.Errorf("expected the subcategories to be merged, got %v", children)
+	}
+}
+
+func TestCategoryRepository_Rename(t *testing.T) {
+	repo, teardown := setupCategoryTest(t)
+	defer teardown()
+	ctx := context.Background()
+	save := func(name string, parentID *int64) int64 {
+		id, err := repo.Save(&Category{Name: name, ParentID: parentID})
+		if err != nil {
+			t.Fatalf("Save(%q) failed: %v", name, err)
+		}
+		return id
+	}
+	js := save("JS", nil)
+	save("Go", nil)
+	frameworks := save("Frameworks", &js)
+	save("Tools", &js)
+	// A category of the same name under another parent is no collision.
+	save("Libraries", nil)
+
+	t.Run("success", func(t *testing.T) {
+		if err := repo.Rename(ctx, js, "JavaScript"); err != nil {
+			t.Fatalf("Rename failed: %v", err)
+		}
+		if err := repo.Rename(ctx, frameworks, "Libraries"); err != nil {
+			t.Fatalf("Rename failed: %v", err)
+		}
+		renamed, err := repo.GetByID(js)
+		if err != nil || renamed.Name != "JavaScript" {
+			t.Fatalf("expected JS to be renamed to JavaScript, got %+v, %v", renamed, err)
+		}
+		child, err := repo.FindByName("Libraries", &js)
+		if err != nil || child == nil || child.ID != frameworks {
+			t.Errorf("expected Frameworks to be renamed in place, got %+v, %v", child, err)
+		}
+		if results, _ := repo.SearchByName("javascript"); len(results) != 1 {
+			t.Errorf("expected the new name to be searchable, got %+v", results)
+		}
+	})
+
+	t.Run("collision with a sibling", func(t *testing.T) {
+		for _, tc := range []struct {
+			id   int64
+			name string
+		}{
+			{js, "Go"},            // top-level siblings
+			{frameworks, "Tools"}, // siblings under JavaScript
+		} {
+			if err := repo.Rename(ctx, tc.id, tc.name); !errors.Is(err, ErrCategoryExists) {
+				t.Errorf("Rename(%d, %q): expected ErrCategoryExists, got %v", tc.id, tc.name, err)
+			}
+		}
+		if c, _ := repo.GetByID(js); c.Name != "JavaScript" {
+			t.Errorf("expected the name to be kept after a collision, got %q", c.Name)
+		}
+	})
+
+	t.Run("missing category", func(t *testing.T) {
+		if err := repo.Rename(ctx, 999, "Anything"); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("expected sql.ErrNoRows, got %v", err)
+		}
+	})
+}
+
+func TestCategoryRepository_Delete(t *testing.T) {
+	setup := func(t *testing.T) (repo *CategoryRepository, ids map[string]int64, teardown func()) {
+		repo, teardown = setupCategoryTest(t)
+		repo.DB.MustExec(`CREATE TABLE pages (
+			id INTEGER PRIMARY KEY,
+			title TEXT NOT NULL,
+			category_id INTEGER,
+			FOREIGN KEY (category_id) REFERENCES categories(id) ON DELETE SET NULL
+		)`)
+		repo.DB.MustExec(`CREATE TABLE page_categories (
+			page_id INTEGER NOT NULL,
+			category_id INTEGER NOT NULL,
+			PRIMARY KEY (page_id, category_id)
+		)`)
+		ids = make(map[string]int64)
+		for _, c := range []struct{ name, parent string }{
+			{"JS", ""}, {"Frameworks", "JS"}, {"Legacy", "JS"}, {"Modern", "JS"}, {"NoCategory", ""}, {"NoSubCategory", "NoCategory"},
+		} {
+			var parentID *int64
+			if c.parent != "" {
+				parent := ids[c.parent]
+				parentID = &parent
+			}
+			id, err := repo.Save(&Category{Name: c.name, ParentID: parentID})
+			if err != nil {
+				t.Fatalf("Save(%q) failed: %v", c.name, err)
+			}
+			ids[c.name] = id
+		}
+		// jQuery is filed under Legacy; Backbone is filed under Modern and also tagged with Legacy.
+		repo.DB.MustExec(`INSERT INTO pages (id, title, category_id) VALUES (1, 'jQuery', ?), (2, 'Backbone', ?)`, ids["Legacy"], ids["Modern"])
+		repo.DB.MustExec(`INSERT INTO page_categories (page_id, category_id) VALUES (1, ?), (2, ?), (2, ?)`, ids["Legacy"], ids["Modern"], ids["Legacy"])
+		return repo, ids, teardown
+	}
+	categoryOf := func(t *testing.T, repo *CategoryRepository, title string) int64 {
+		var id int64
+		if err := repo.DB.Get(&id, `SELECT category_id FROM pages WHERE title = ?`, title); err != nil {
+			t.Fatalf("failed to get the category of %q: %v", title, err)
+		}
+		return id
+	}
+	tagsOf := func(t *testing.T, repo *CategoryRepository, pageID int64) []int64 {
+		var ids []int64
+		if err := repo.DB.Select(&ids, `SELECT category_id FROM page_categories WHERE page_id = ? ORDER BY category_id`, pageID); err != nil {
+			t.Fatalf("failed to get the tags of page %d: %v", pageID, err)
+		}
+		return ids
+	}
+	ctx := context.Background()
+
+	t.Run("reassign", func(t *testing.T) {
+		repo, ids, teardown := setup(t)
+		defer teardown()
+		if err := repo.Delete(ctx, ids["Legacy"], ids["Frameworks"], true); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+		if got := categoryOf(t, repo, "jQuery"); got != ids["Frameworks"] {
+			t.Errorf("expected jQuery to move to Frameworks, got category %d", got)
+		}
+		if got := tagsOf(t, repo, 2); fmt.Sprint(got) != fmt.Sprint([]int64{ids["Frameworks"], ids["Modern"]}) {
+			t.Errorf("expected Backbone's Legacy tag to move to Frameworks, got %v", got)
+		}
+		if c, _ := repo.GetByID(ids["Legacy"]); c != nil {
+			t.Errorf("expected Legacy to be deleted, got %+v", c)
+		}
+	})
+
+	t.Run("orphan", func(t *testing.T) {
+		repo, ids, teardown := setup(t)
+		defer teardown()
+		if err := repo.Delete(ctx, ids["Legacy"], ids["NoSubCategory"], false); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+		if got := categoryOf(t, repo, "jQuery"); got != ids["NoSubCategory"] {
+			t.Errorf("expected jQuery to move to the default category, got category %d", got)
+		}
+		if got := tagsOf(t, repo, 1); fmt.Sprint(got) != fmt.Sprint([]int64{ids["NoSubCategory"]}) {
+			t.Errorf("expected jQuery to be tagged with its new category, got %v", got)
+		}
+		if got := tagsOf(t, repo, 2); fmt.Sprint(got) != fmt.Sprint([]int64{ids["Modern"]}) {
+			t.Errorf("expected Backbone to lose its Legacy tag, got %v", got)
+		}
+		if c, _ := repo.GetByID(ids["Legacy"]); c != nil {
+			t.Errorf("expected Legacy to be deleted, got %+v", c)
+		}
+	})
+
+	t.Run("blocked with subcategories", func(t *testing.T) {
+		repo, ids, teardown := setup(t)
+		defer teardown()
+		if err := repo.Delete(ctx, ids["JS"], ids["NoSubCategory"], false); !errors.Is(err, ErrCategoryHasSubcategories) {
+			t.Fatalf("expected ErrCategoryHasSubcategories, got %v", err)
+		}
+		if c, _ := repo.GetByID(ids["JS"]); c == nil {
+			t.Error("expected JS to be kept")
+		}
+	})
+
+	t.Run("missing category", func(t *testing.T) {
+		repo, ids, teardown := setup(t)
+		defer teardown()
+		if err := repo.Delete(ctx, 999, ids["NoSubCategory"], false); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("expected sql.ErrNoRows, got %v", err)
+		}
+	})
+}
+
+func TestCategoryRepository_UpdateOrder(t *testing.T) {
+	repo, teardown := setupCategoryTest(t)
+	defer teardown()
+	ids := make(map[string]int64)
+	for _, name := range []string{"Advanced", "Getting Started", "Reference", "FAQ"} {
+		id, err := repo.Save(&Category{Name: name})
+		if err != nil {
+			t.Fatalf("Save(%q) failed: %v", name, err)
+		}
+		ids[name] = id
+	}
+	names := func() string {
+		categories, err := repo.GetAll()
+		if err != nil {
+			t.Fatalf("GetAll failed: %v", err)
+		}
+		var names []string
+		for _, c := range categories {
+			names = append(names, c.Name)
+		}
+		return fmt.Sprint(names)
+	}
+	if got := names(); got != "[Advanced FAQ Getting Started Reference]" {
+		t.Fatalf("expected alphabetical order before weighting, got %v", got)
+	}
+
+	// Reference and FAQ share a weight, so they stay alphabetical among themselves.
+	weights := map[int64]int{ids["Getting Started"]: -10, ids["Advanced"]: 20, ids["Reference"]: 5, ids["FAQ"]: 5}
+	if err := repo.UpdateOrder(context.Background(), weights); err != nil {
+		t.Fatalf("UpdateOrder failed: %v", err)
+	}
+	if got := names(); got != "[Getting Started FAQ Reference Advanced]" {
+		t.Errorf("expected the weights to override alphabetical order, got %v", got)
+	}
+	if c, _ := repo.GetAll(); c[0].OrderWeight != -10 {
+		t.Errorf("expected the weight to be loaded, got %d", c[0].OrderWeight)
+	}
+}
@@ -15,8 +15,93 @@ type Page struct {
 	CreatedAt       time.Time     `db:"created_at"`
 	UpdatedAt       time.Time     `db:"updated_at"`
 	CategoryID      *int64        `db:"category_id"`
+	ExpiresAt       *time.Time    `db:"expires_at"`    // when the page is archived, nil if it never expires
+	OwnerSubject    string        `db:"owner_subject"` // may always edit the page, empty if it has no owner
+	DeletedAt       *time.Time    `db:"deleted_at"`    // when the page was moved to the trash, nil if it was not
+	TrustedHTML     bool          `db:"trusted_html"`  // only admins wrote the kept content, so rendered with the trusted HTML allow-list
 	CategoryName    string        `db:"-"`
-	SubcategoryName string        `db:"-"`
+	SubcategoryName string        `db:"-"` // slash-delimited below the second level, e.g. "Physics/Quantum"
+	// Breadcrumb lists the page's category and its ancestors, top-level category first.
+	Breadcrumb []CategoryCrumb `db:"-"`
+	// ExtraCategories lists the other categories the page is tagged with.
+	ExtraCategories []CategoryCrumb `db:"-"`
+	// IsStub is set when the page is shorter than the configured stub threshold.
+	IsStub bool `db:"-" json:"-"`
+	// WordCount and ReadingMinutes are derived from the content, without its
+	// code blocks and HTML, when the page is viewed.
+	WordCount      int `db:"-" json:"-"`
+	ReadingMinutes int `db:"-" json:"-"`
+	// TableOfContents is derived from the page's headings when it is rendered.
+	TableOfContents []*TOCEntry `db:"-" json:"-"`
+	// SeeAlso lists pages named in the page's [[WikiLinks]] that the body does not already link to.
+	SeeAlso []string `db:"-" json:"-"`
+	// Metadata holds the page's structured key-value fields, such as its owner or status.
+	Metadata map[string]string `db:"-"`
+	// RequiredRole is the role needed to view the page, empty if anyone may view it.
+	RequiredRole string `db:"-"`
+}
+
+// IsArchived reports whether the page has passed its expiry date at the given time.
+// Archived pages are kept, but hidden from readers who cannot edit them.
+func (p *Page) IsArchived(now time.Time) bool {
+	return p.ExpiresAt != nil && !now.Before(*p.ExpiresAt)
+}
+
+// DeadLink is an external URL on a page that failed the last link check.
+type DeadLink struct {
+	URL        string    `db:"url"`
+	PageID     int64     `db:"page_id"`
+	PageTitle  string    `db:"page_title"`
+	StatusCode int       `db:"status_code"` // zero if the request failed without a response
+	Error      string    `db:"error"`       // why the link is considered dead
+	CheckedAt  time.Time `db:"checked_at"`
+}
+
+// User is the profile of a user who has logged in, as last reported by the identity provider.
+type User struct {
+	Subject     string    `db:"subject"`
+	DisplayName string    `db:"display_name"`
+	Email       string    `db:"email"`
+	Picture     string    `db:"picture"` // avatar URL, empty if the provider sent none
+	UpdatedAt   time.Time `db:"updated_at"`
+}
+
+// TOCEntry is a heading in a page's table of contents.
+type TOCEntry struct {
+	ID       string
+	Text     string
+	Level    int
+	Children []*TOCEntry
+}
+
+// Revision is a snapshot of a page's content as it was saved at a point in time.
+type Revision struct {
+	ID          int64     `db:"id"`
+	PageID      int64     `db:"page_id"`
+	Title       string    `db:"title"`
+	Content     string    `db:"content"`
+	AuthorID    string    `db:"author_id"`
+	AuthorIP    string    `db:"author_ip"`    // client address of anonymous edits, kept for abuse tracking
+	Minor       bool      `db:"minor"`        // a small fix, such as a typo, that watchers are not notified of
+	TrustedHTML bool      `db:"trusted_html"` // every change kept in the content was made by an admin
+	CreatedAt   time.Time `db:"created_at"`
+}
+
+// PendingEdit is an edit to a page held for review by a moderator. It is
+// applied to the page when approved and discarded when rejected. PageID is 0
+// for a new page, which is only created when the edit is approved.
+type PendingEdit struct {
+	ID              int64     `db:"id"`
+	PageID          int64     `db:"page_id"`
+	Title           string    `db:"title"`
+	Content         string    `db:"content"`
+	CategoryName    string    `db:"category_name"`
+	SubcategoryName string    `db:"subcategory_name"`
+	AuthorID        string    `db:"author_id"`
+	AuthorIP        string    `db:"author_ip"` // client address of anonymous edits, kept for abuse tracking
+	Minor           bool      `db:"minor"`
+	Entry           bool      `db:"entry"` // a log entry, appended to the page when approved instead of replacing its content
+	CreatedAt       time.Time `db:"created_at"`
 }
 
 // Category represents a category for wiki pages.
@@ -24,4 +109,93 @@ type Category struct {
 	ID       int64  `db:"id"`
 	Name     string `db:"name"`
 	ParentID *int64 `db:"parent_id"`
+	// OrderWeight places the category among its siblings: lighter first, then by name.
+	OrderWeight int `db:"order_weight"`
+}
+
+// CategoryCrumb is one category in the breadcrumb leading to a page's category.
+type CategoryCrumb struct {
+	Name string
+	Path string // slash-delimited path from the top-level category, e.g. "Science/Physics"
+}
+
+// Activity actions recorded in the activity log.
+const (
+	ActivityCreate = "create"
+	ActivityUpdate = "update"
+	ActivityDelete = "delete"
+	// ActivityRevert records an administrator reverting a user's contributions.
+	ActivityRevert = "revert"
+	// ActivityRollback records an editor restoring an earlier revision of a page.
+	ActivityRollback = "rollback"
+	// ActivityRestore records an editor taking a page out of the trash.
+	ActivityRestore = "restore"
+)
+
+// ContentStats summarizes how much content the wiki holds.
+type ContentStats struct {
+	PageCount    int64 `db:"page_count"`
+	ContentBytes int64 `db:"content_bytes"`
+}
+
+// Activity represents a single change event in the wiki-wide activity log.
+type Activity struct {
+	ID        int64     `db:"id"`
+	PageID    *int64    `db:"page_id"`
+	PageTitle string    `db:"page_title"`
+	Action    string    `db:"action"`
+	AuthorID  string    `db:"author_id"`
+	AuthorIP  string    `db:"author_ip"` // client address of anonymous edits, kept for abuse tracking
+	Minor     bool      `db:"minor"`     // a small fix, such as a typo, that watchers are not notified of
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// SearchSort selects the order of search results.
+type SearchSort string
+
+const (
+	SearchSortRelevance SearchSort = "relevance" // best match first, ranked by the service
+	SearchSortUpdated   SearchSort = "updated"   // most recently updated first
+	SearchSortTitle     SearchSort = "title"     // alphabetical by title
+)
+
+// SearchFilter narrows down the pages returned by SearchPages. Zero values are ignored.
+type SearchFilter struct {
+	// Query is matched against titles and content; every word must appear.
+	Query         string
+	Category      string
+	Subcategory   string
+	AuthorID      string
+	UpdatedAfter  time.Time
+	UpdatedBefore time.Time
+	Sort          SearchSort
+	Limit         int
+	// CategoryIDs, when not nil, replaces Category and Subcategory: only pages
+	// filed under or tagged with one of these categories match.
+	CategoryIDs []int64
+}
+
+// PageListFilter selects a slice of the page list. The list is ordered by ID,
+// so a cursor keeps its place when pages are added or removed. At most one of
+// AfterID and BeforeID is set; with neither, the list starts at the beginning.
+type PageListFilter struct {
+	AfterID  int64 // list the pages following this ID
+	BeforeID int64 // list the pages preceding this ID
+	Limit    int
+}
+
+// PageList is a slice of the page list. Its pages are loaded without content.
+type PageList struct {
+	Pages   []*Page
+	Total   int  // the number of pages in the wiki
+	HasNext bool // more pages follow the last one
+	HasPrev bool // more pages precede the first one
+}
+
+// ActivityFilter narrows down the activity returned by GetRecentActivity.
+// Zero values are ignored.
+type ActivityFilter struct {
+	AuthorID string
+	Since    time.Time
+	Until    time.Time
 }
@@ -0,0 +1,74 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// SQLPageACLRepository handles database operations for the roles pages are
+// restricted to.
+type SQLPageACLRepository struct {
+	db *sqlx.DB
+}
+
+// NewSQLPageACLRepository creates a new SQLPageACLRepository.
+func NewSQLPageACLRepository(db *sqlx.DB) *SQLPageACLRepository {
+	return &SQLPageACLRepository{db: db}
+}
+
+// GetRequiredRole returns the role needed to view the page, or an empty string
+// if the page is not restricted.
+func (r *SQLPageACLRepository) GetRequiredRole(ctx context.Context, pageID int64) (string, error) {
+	var role string
+	query := `SELECT required_role FROM page_acls WHERE page_id = ?`
+	if err := r.db.GetContext(ctx, &role, r.db.Rebind(query), pageID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to get required role of page %d: %w", pageID, err)
+	}
+	return role, nil
+}
+
+// GetRequiredRoles returns the roles of all restricted pages, keyed by page ID.
+func (r *SQLPageACLRepository) GetRequiredRoles(ctx context.Context) (map[int64]string, error) {
+	var rows []struct {
+		PageID       int64  `db:"page_id"`
+		RequiredRole string `db:"required_role"`
+	}
+	if err := r.db.SelectContext(ctx, &rows, `SELECT page_id, required_role FROM page_acls`); err != nil {
+		return nil, fmt.Errorf("failed to get required roles: %w", err)
+	}
+	roles := make(map[int64]string, len(rows))
+	for _, row := range rows {
+		roles[row.PageID] = row.RequiredRole
+	}
+	return roles, nil
+}
+
+// SetRequiredRole restricts the page to users holding role. An empty role
+// lifts the restriction.
+func (r *SQLPageACLRepository) SetRequiredRole(ctx context.Context, pageID int64, role string) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin page ACL transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, tx.Rebind(`DELETE FROM page_acls WHERE page_id = ?`), pageID); err != nil {
+		return fmt.Errorf("failed to replace required role of page %d: %w", pageID, err)
+	}
+	if role != "" {
+		if _, err := tx.ExecContext(ctx, tx.Rebind(`INSERT INTO page_acls (page_id, required_role) VALUES (?, ?)`), pageID, role); err != nil {
+			return fmt.Errorf("failed to set required role of page %d: %w", pageID, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit page ACL: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+//go:build integration
+
+package data
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSQLPageACLRepository_RequiredRole(t *testing.T) {
+	_, db, teardown := setupPageTest(t)
+	defer teardown()
+	db.MustExec(`CREATE TABLE page_acls (
+		page_id INTEGER PRIMARY KEY,
+		required_role TEXT NOT NULL
+	)`)
+	repo := NewSQLPageACLRepository(db)
+	ctx := context.Background()
+
+	if role, err := repo.GetRequiredRole(ctx, 1); err != nil || role != "" {
+		t.Fatalf("expected an unrestricted page, got %q (err %v)", role, err)
+	}
+	for _, want := range []string{"editor", "admin"} {
+		if err := repo.SetRequiredRole(ctx, 1, want); err != nil {
+			t.Fatalf("SetRequiredRole failed: %v", err)
+		}
+		if role, err := repo.GetRequiredRole(ctx, 1); err != nil || role != want {
+			t.Errorf("expected required role %q, got %q (err %v)", want, role, err)
+		}
+	}
+	if err := repo.SetRequiredRole(ctx, 2, "hr"); err != nil {
+		t.Fatalf("SetRequiredRole failed: %v", err)
+	}
+	if roles, err := repo.GetRequiredRoles(ctx); err != nil || len(roles) != 2 || roles[1] != "admin" || roles[2] != "hr" {
+		t.Errorf("expected the roles of both restricted pages, got %v (err %v)", roles, err)
+	}
+	if err := repo.SetRequiredRole(ctx, 1, ""); err != nil {
+		t.Fatalf("SetRequiredRole failed: %v", err)
+	}
+	if role, _ := repo.GetRequiredRole(ctx, 1); role != "" {
+		t.Errorf("expected an empty role to lift the restriction, got %q", role)
+	}
+}
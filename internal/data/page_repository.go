@@ -3,7 +3,13 @@ package data
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
 
 	"github.com/jmoiron/sqlx"
 )
@@ -11,6 +17,10 @@ import (
 // SQLPageRepository is a concrete implementation of the PageRepository interface using sqlx.
 type SQLPageRepository struct {
 	db *sqlx.DB
+
+	// fullText records whether MySQL has a FULLTEXT index to search pages with, checked once.
+	fullTextOnce sync.Once
+	fullText     bool
 }
 
 // NewSQLPageRepository creates a new SQLPageRepository.
@@ -18,42 +28,64 @@ func NewSQLPageRepository(db *sqlx.DB) *SQLPageRepository {
 	return &SQLPageRepository{db: db}
 }
 
-// CreatePage inserts a new page into the database.
-// Note: MariaDB (MySQL) does not support a RETURNING clause for inserts in the same
-// way as PostgreSQL. This function inserts the data and assumes the database
-// will correctly handle auto-incrementing IDs and default timestamps.
-// The provided 'page' object is not updated with DB-generated values post-insert.
+// searchablePage binds a page together with its normalized search text.
+type searchablePage struct {
+	*Page
+	SearchText string `db:"search_text"`
+}
+
+// CreatePage inserts a new page into the database and sets the page's ID
+// to the auto-incremented value generated by the database.
 func (r *SQLPageRepository) CreatePage(ctx context.Context, page *Page) error {
-	query := `INSERT INTO pages (title, content, author_id, category_id) VALUES (:title, :content, :author_id, :category_id)`
-	_, err := r.db.NamedExecContext(ctx, query, page)
+	query := `INSERT INTO pages (title, content, author_id, owner_subject, category_id, search_text, trusted_html)
+		VALUES (:title, :content, :author_id, :owner_subject, :category_id, :search_text, :trusted_html)`
+	id, err := insertReturningID(ctx, r.db, query, searchablePage{page, pageSearchText(page)})
 	if err != nil {
 		return fmt.Errorf("failed to execute create page query: %w", err)
 	}
-	// To get the ID, a separate SELECT would be needed, but for now, we assume
-	// the caller doesn't need the ID immediately after creation.
+	page.ID = id
 	return nil
 }
 
 // GetPageByTitle retrieves a single page from the database by its title.
 func (r *SQLPageRepository) GetPageByTitle(ctx context.Context, title string) (*Page, error) {
 	var page Page
-	query := `SELECT id, title, content, author_id, created_at, updated_at, category_id FROM pages WHERE title = ?`
-	if err := r.db.GetContext(ctx, &page, query, title); err != nil {
+	query := `SELECT id, title, content, author_id, created_at, updated_at, category_id, expires_at, owner_subject, trusted_html FROM pages WHERE title = ? AND deleted_at IS NULL`
+	if err := r.db.GetContext(ctx, &page, r.db.Rebind(query), title); err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("page with title '%s' not found", title)
+			return nil, fmt.Errorf("page with title '%s' not found: %w", title, err)
 		}
 		return nil, fmt.Errorf("failed to get page by title: %w", err)
 	}
 	return &page, nil
 }
 
-// GetPageByID retrieves a single page from the database by its ID.
+// GetPagesByTitles retrieves the pages with the given titles in a single query.
+// Titles without a page are left out of the result.
+func (r *SQLPageRepository) GetPagesByTitles(ctx context.Context, titles []string) ([]*Page, error) {
+	pages := []*Page{}
+	if len(titles) == 0 {
+		return pages, nil
+	}
+	query, args, err := sqlx.In(`SELECT id, title, content, author_id, created_at, updated_at, category_id, expires_at, owner_subject, trusted_html FROM pages
+		WHERE title IN (?) AND deleted_at IS NULL`, titles)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build pages by titles query: %w", err)
+	}
+	if err := r.db.SelectContext(ctx, &pages, r.db.Rebind(query), args...); err != nil {
+		return nil, fmt.Errorf("failed to get pages by titles: %w", err)
+	}
+	return pages, nil
+}
+
+// GetPageByID retrieves a single page from the database by its ID, including
+// pages in the trash, which have DeletedAt set.
 func (r *SQLPageRepository) GetPageByID(ctx context.Context, id int64) (*Page, error) {
 	var page Page
-	query := `SELECT id, title, content, author_id, created_at, updated_at, category_id FROM pages WHERE id = ?`
-	if err := r.db.GetContext(ctx, &page, query, id); err != nil {
+	query := `SELECT id, title, content, author_id, created_at, updated_at, category_id, expires_at, owner_subject, trusted_html, deleted_at FROM pages WHERE id = ?`
+	if err := r.db.GetContext(ctx, &page, r.db.Rebind(query), id); err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("page with id %d not found", id)
+			return nil, fmt.Errorf("page with id %d not found: %w", id, err)
 		}
 		return nil, fmt.Errorf("failed to get page by id: %w", err)
 	}
@@ -62,8 +94,13 @@ func (r *SQLPageRepository) GetPageByID(ctx context.Context, id int64) (*Page, e
 
 // UpdatePage updates an existing page in the database.
 func (r *SQLPageRepository) UpdatePage(ctx context.Context, page *Page) error {
-	query := `UPDATE pages SET title = :title, content = :content, updated_at = :updated_at, category_id = :category_id WHERE id = :id`
-	result, err := r.db.NamedExecContext(ctx, query, page)
+	return updatePage(ctx, r.db, page)
+}
+
+// updatePage writes the page's editable fields using db, which may be a transaction.
+func updatePage(ctx context.Context, db sqlx.ExtContext, page *Page) error {
+	query := `UPDATE pages SET title = :title, content = :content, updated_at = :updated_at, category_id = :category_id, search_text = :search_text, trusted_html = :trusted_html WHERE id = :id`
+	result, err := sqlx.NamedExecContext(ctx, db, query, searchablePage{page, pageSearchText(page)})
 	if err != nil {
 		return fmt.Errorf("failed to update page: %w", err)
 	}
@@ -77,11 +114,14 @@ func (r *SQLPageRepository) UpdatePage(ctx context.Context, page *Page) error {
 	return nil
 }
 
-// GetPagesByCategoryID retrieves all pages associated with a given category ID.
+// GetPagesByCategoryID retrieves all pages filed under or tagged with a given
+// category ID. Pages are listed under their own category even if they are not
+// tagged with it, so listings keep working before page_categories is backfilled.
 func (r *SQLPageRepository) GetPagesByCategoryID(ctx context.Context, categoryID int64) ([]*Page, error) {
 	var pages []*Page
-	query := `SELECT id, title, content, author_id, created_at, updated_at, category_id FROM pages WHERE category_id = ?`
-	if err := r.db.SelectContext(ctx, &pages, query, categoryID); err != nil {
+	query := `SELECT id, title, content, author_id, created_at, updated_at, category_id, expires_at, owner_subject, trusted_html FROM pages
+		WHERE (category_id = ? OR id IN (SELECT page_id FROM page_categories WHERE category_id = ?)) AND deleted_at IS NULL`
+	if err := r.db.SelectContext(ctx, &pages, r.db.Rebind(query), categoryID, categoryID); err != nil {
 		return nil, fmt.Errorf("failed to get pages by category id: %w", err)
 	}
 	return pages, nil
@@ -90,26 +130,497 @@ func (r *SQLPageRepository) GetPagesByCategoryID(ctx context.Context, categoryID
 // GetAllPages retrieves all pages from the database.
 func (r *SQLPageRepository) GetAllPages(ctx context.Context) ([]*Page, error) {
 	var pages []*Page
-	query := `SELECT id, title, content, author_id, created_at, updated_at, category_id FROM pages`
+	query := `SELECT id, title, content, author_id, created_at, updated_at, category_id, expires_at, owner_subject, trusted_html FROM pages WHERE deleted_at IS NULL`
 	if err := r.db.SelectContext(ctx, &pages, query); err != nil {
 		return nil, fmt.Errorf("failed to get all pages: %w", err)
 	}
 	return pages, nil
 }
 
-// DeletePage removes a page from the database by its ID.
-func (r *SQLPageRepository) DeletePage(ctx context.Context, id int64) error {
-	query := `DELETE FROM pages WHERE id = ?`
-	result, err := r.db.ExecContext(ctx, query, id)
+// GetPagesPaged returns one page of the page list, ordered by title, without
+// page content. An offset past the last page yields an empty slice.
+func (r *SQLPageRepository) GetPagesPaged(ctx context.Context, limit, offset int) ([]*Page, error) {
+	pages := []*Page{}
+	query := `SELECT id, title, author_id, created_at, updated_at, category_id, expires_at, owner_subject, trusted_html FROM pages
+		WHERE deleted_at IS NULL ORDER BY title ASC, id ASC LIMIT ? OFFSET ?`
+	if err := r.db.SelectContext(ctx, &pages, r.db.Rebind(query), limit, offset); err != nil {
+		return nil, fmt.Errorf("failed to get pages: %w", err)
+	}
+	return pages, nil
+}
+
+// CountPages returns the number of pages outside the trash.
+func (r *SQLPageRepository) CountPages(ctx context.Context) (int, error) {
+	var count int
+	if err := r.db.GetContext(ctx, &count, `SELECT COUNT(*) FROM pages WHERE deleted_at IS NULL`); err != nil {
+		return 0, fmt.Errorf("failed to count pages: %w", err)
+	}
+	return count, nil
+}
+
+// GetMaxUpdatedAt returns when a page outside the trash was last updated, or
+// the zero time if there are no pages. It reads the newest row rather than
+// using MAX() so the driver still knows the column is a timestamp.
+func (r *SQLPageRepository) GetMaxUpdatedAt(ctx context.Context) (time.Time, error) {
+	var updatedAt time.Time
+	query := `SELECT updated_at FROM pages WHERE deleted_at IS NULL ORDER BY updated_at DESC LIMIT 1`
+	if err := r.db.GetContext(ctx, &updatedAt, query); err != nil {
+		if err == sql.ErrNoRows {
+			return time.Time{}, nil
+		}
+		return time.Time{}, fmt.Errorf("failed to get last page update: %w", err)
+	}
+	return updatedAt, nil
+}
+
+// GetLatestArchivedAt returns the most recent expiry date that has passed at
+// now among the pages outside the trash, or the zero time if no page is archived.
+func (r *SQLPageRepository) GetLatestArchivedAt(ctx context.Context, now time.Time) (time.Time, error) {
+	var archivedAt time.Time
+	query := `SELECT expires_at FROM pages WHERE expires_at IS NOT NULL AND expires_at <= ? AND deleted_at IS NULL ORDER BY expires_at DESC LIMIT 1`
+	if err := r.db.GetContext(ctx, &archivedAt, r.db.Rebind(query), now.UTC()); err != nil {
+		if err == sql.ErrNoRows {
+			return time.Time{}, nil
+		}
+		return time.Time{}, fmt.Errorf("failed to get last page archival: %w", err)
+	}
+	return archivedAt, nil
+}
+
+// GetDeletedPages returns the pages in the trash, most recently deleted first.
+func (r *SQLPageRepository) GetDeletedPages(ctx context.Context) ([]*Page, error) {
+	pages := []*Page{}
+	query := `SELECT id, title, content, author_id, created_at, updated_at, category_id, expires_at, owner_subject, trusted_html, deleted_at FROM pages
+		WHERE deleted_at IS NOT NULL ORDER BY deleted_at DESC, id DESC`
+	if err := r.db.SelectContext(ctx, &pages, query); err != nil {
+		return nil, fmt.Errorf("failed to get deleted pages: %w", err)
+	}
+	return pages, nil
+}
+
+// RestorePage takes a page out of the trash.
+func (r *SQLPageRepository) RestorePage(ctx context.Context, id int64) error {
+	result, err := r.db.ExecContext(ctx, r.db.Rebind(`UPDATE pages SET deleted_at = NULL WHERE id = ? AND deleted_at IS NOT NULL`), id)
 	if err != nil {
-		return fmt.Errorf("failed to delete page: %w", err)
+		return fmt.Errorf("failed to restore page: %w", err)
+	}
+	return requireRow(result, fmt.Sprintf("no page in the trash with id %d", id))
+}
+
+// PurgePage permanently removes a page in the trash from the database.
+func (r *SQLPageRepository) PurgePage(ctx context.Context, id int64) error {
+	result, err := r.db.ExecContext(ctx, r.db.Rebind(`DELETE FROM pages WHERE id = ? AND deleted_at IS NOT NULL`), id)
+	if err != nil {
+		return fmt.Errorf("failed to purge page: %w", err)
 	}
+	return requireRow(result, fmt.Sprintf("no page in the trash with id %d", id))
+}
+
+// requireRow returns an error with the given message if the statement affected no rows.
+func requireRow(result sql.Result, message string) error {
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
 		return fmt.Errorf("failed to get rows affected: %w", err)
 	}
 	if rowsAffected == 0 {
-		return fmt.Errorf("no page found to delete with id %d", id)
+		return errors.New(message)
+	}
+	return nil
+}
+
+// ListPages returns a slice of the pages ordered by ID, without their content.
+// The side of the slice the cursor points back to is assumed to have pages.
+func (r *SQLPageRepository) ListPages(ctx context.Context, filter PageListFilter) (*PageList, error) {
+	total, err := r.CountPages(ctx)
+	if err != nil {
+		return nil, err
+	}
+	list := &PageList{Pages: []*Page{}, Total: total}
+
+	query := `SELECT id, title, author_id, created_at, updated_at, category_id, expires_at, owner_subject, trusted_html FROM pages WHERE deleted_at IS NULL`
+	var args []interface{}
+	order := "id ASC"
+	if filter.BeforeID > 0 {
+		// Walk backwards from the cursor, then put the slice back in order.
+		query += ` AND id < ?`
+		args = append(args, filter.BeforeID)
+		order = "id DESC"
+	} else if filter.AfterID > 0 {
+		query += ` AND id > ?`
+		args = append(args, filter.AfterID)
+	}
+	// Fetch one extra page to learn whether there are more.
+	query += ` ORDER BY ` + order + ` LIMIT ?`
+	args = append(args, filter.Limit+1)
+	if err := r.db.SelectContext(ctx, &list.Pages, r.db.Rebind(query), args...); err != nil {
+		return nil, fmt.Errorf("failed to list pages: %w", err)
+	}
+
+	more := len(list.Pages) > filter.Limit
+	if more {
+		list.Pages = list.Pages[:filter.Limit]
+	}
+	if filter.BeforeID > 0 {
+		slices.Reverse(list.Pages)
+		list.HasPrev, list.HasNext = more, true
+	} else {
+		list.HasNext, list.HasPrev = more, filter.AfterID > 0
+	}
+	return list, nil
+}
+
+// DeletePage moves a page to the trash by setting its deleted_at timestamp.
+func (r *SQLPageRepository) DeletePage(ctx context.Context, id int64) error {
+	query := `UPDATE pages SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL`
+	result, err := r.db.ExecContext(ctx, r.db.Rebind(query), time.Now().UTC(), id)
+	if err != nil {
+		return fmt.Errorf("failed to delete page: %w", err)
+	}
+	return requireRow(result, fmt.Sprintf("no page found to delete with id %d", id))
+}
+
+// RecordActivity appends an entry to the activity log.
+func (r *SQLPageRepository) RecordActivity(ctx context.Context, activity *Activity) error {
+	if activity.CreatedAt.IsZero() {
+		activity.CreatedAt = time.Now().UTC()
+	}
+	query := `INSERT INTO activity (page_id, page_title, action, author_id, author_ip, minor, created_at) VALUES (:page_id, :page_title, :action, :author_id, :author_ip, :minor, :created_at)`
+	if _, err := r.db.NamedExecContext(ctx, query, activity); err != nil {
+		return fmt.Errorf("failed to record activity: %w", err)
+	}
+	return nil
+}
+
+// GetRecentActivity retrieves activity log entries, newest first, matching the given filter.
+func (r *SQLPageRepository) GetRecentActivity(ctx context.Context, filter ActivityFilter, limit, offset int) ([]*Activity, error) {
+	var conditions []string
+	var args []interface{}
+	if filter.AuthorID != "" {
+		conditions = append(conditions, "author_id = ?")
+		args = append(args, filter.AuthorID)
+	}
+	if !filter.Since.IsZero() {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, filter.Since.UTC())
+	}
+	if !filter.Until.IsZero() {
+		conditions = append(conditions, "created_at < ?")
+		args = append(args, filter.Until.UTC())
+	}
+
+	query := `SELECT id, page_id, page_title, action, author_id, author_ip, minor, created_at FROM activity`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY created_at DESC, id DESC LIMIT ? OFFSET ?"
+	args = append(args, limit, offset)
+
+	activity := []*Activity{}
+	if err := r.db.SelectContext(ctx, &activity, r.db.Rebind(query), args...); err != nil {
+		return nil, fmt.Errorf("failed to get recent activity: %w", err)
+	}
+	return activity, nil
+}
+
+// GetPageMetadata retrieves all metadata entries of a page as a key-value map.
+func (r *SQLPageRepository) GetPageMetadata(ctx context.Context, pageID int64) (map[string]string, error) {
+	var rows []struct {
+		Key   string `db:"meta_key"`
+		Value string `db:"meta_value"`
+	}
+	query := `SELECT meta_key, meta_value FROM page_meta WHERE page_id = ?`
+	if err := r.db.SelectContext(ctx, &rows, r.db.Rebind(query), pageID); err != nil {
+		return nil, fmt.Errorf("failed to get page metadata: %w", err)
+	}
+	meta := make(map[string]string, len(rows))
+	for _, row := range rows {
+		meta[row.Key] = row.Value
+	}
+	return meta, nil
+}
+
+// SetPageMetadata stores a metadata value for a page, replacing any existing value for the key.
+func (r *SQLPageRepository) SetPageMetadata(ctx context.Context, pageID int64, key, value string) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin page metadata transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, tx.Rebind(`DELETE FROM page_meta WHERE page_id = ? AND meta_key = ?`), pageID, key); err != nil {
+		return fmt.Errorf("failed to replace page metadata: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, tx.Rebind(`INSERT INTO page_meta (page_id, meta_key, meta_value) VALUES (?, ?, ?)`), pageID, key, value); err != nil {
+		return fmt.Errorf("failed to set page metadata: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit page metadata: %w", err)
+	}
+	return nil
+}
+
+// DeletePageMetadata removes a metadata entry from a page. Deleting a missing key is not an error.
+func (r *SQLPageRepository) DeletePageMetadata(ctx context.Context, pageID int64, key string) error {
+	query := `DELETE FROM page_meta WHERE page_id = ? AND meta_key = ?`
+	if _, err := r.db.ExecContext(ctx, r.db.Rebind(query), pageID, key); err != nil {
+		return fmt.Errorf("failed to delete page metadata: %w", err)
+	}
+	return nil
+}
+
+// GetContentStats counts the pages and the total size of their content in bytes.
+func (r *SQLPageRepository) GetContentStats(ctx context.Context) (*ContentStats, error) {
+	var stats ContentStats
+	query := `SELECT COUNT(*) AS page_count, COALESCE(SUM(LENGTH(content)), 0) AS content_bytes FROM pages WHERE deleted_at IS NULL`
+	if err := r.db.GetContext(ctx, &stats, query); err != nil {
+		return nil, fmt.Errorf("failed to get content stats: %w", err)
+	}
+	return &stats, nil
+}
+
+// searchOrderBy maps the supported sort keys to their ORDER BY clause. Only
+// these fixed clauses are ever added to the query.
+var searchOrderBy = map[SearchSort]string{
+	// Relevance is ranked by the caller; candidates are fetched newest first.
+	SearchSortRelevance: "p.updated_at DESC, p.id DESC",
+	SearchSortUpdated:   "p.updated_at DESC, p.id DESC",
+	SearchSortTitle:     "p.title ASC",
+}
+
+// likeEscaper escapes LIKE wildcards so search terms match literally, using '!' as the escape character.
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+// fullTextMinTermLength is the shortest term looked up in the FULLTEXT index,
+// matching MySQL's default innodb_ft_min_token_size. Shorter terms use LIKE.
+const fullTextMinTermLength = 3
+
+// SearchPages finds pages matching the filter. Every condition is passed as a
+// query parameter, and the sort key must be one of the supported keys.
+//
+// Search terms are matched against the normalized search text. On MySQL, when
+// pages.search_text has a FULLTEXT index, words of at least three letters or
+// digits are matched as word prefixes with MATCH ... AGAINST in boolean mode.
+// All other terms, and every term on SQLite or without the index, are matched
+// as substrings with LIKE, with wildcard characters taken literally.
+func (r *SQLPageRepository) SearchPages(ctx context.Context, filter SearchFilter) ([]*Page, error) {
+	sort := filter.Sort
+	if sort == "" {
+		sort = SearchSortUpdated
+	}
+	orderBy, ok := searchOrderBy[sort]
+	if !ok {
+		return nil, fmt.Errorf("unsupported search sort %q", filter.Sort)
+	}
+
+	conditions := []string{"p.deleted_at IS NULL"}
+	var args []interface{}
+	useFullText := r.hasFullTextIndex(ctx)
+	var fullTextTerms []string
+	for _, term := range strings.Fields(normalizeForSearch(filter.Query)) {
+		if useFullText && isFullTextTerm(term) {
+			fullTextTerms = append(fullTextTerms, "+"+term+"*")
+			continue
+		}
+		conditions = append(conditions, "p.search_text LIKE ? ESCAPE '!'")
+		args = append(args, "%"+likeEscaper.Replace(term)+"%")
+	}
+	if len(fullTextTerms) > 0 {
+		conditions = append(conditions, "MATCH (p.search_text) AGAINST (? IN BOOLEAN MODE)")
+		args = append(args, strings.Join(fullTextTerms, " "))
+	}
+	if filter.CategoryIDs != nil {
+		if len(filter.CategoryIDs) == 0 {
+			return []*Page{}, nil
+		}
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(filter.CategoryIDs)), ", ")
+		conditions = append(conditions, "(p.category_id IN ("+placeholders+") OR p.id IN (SELECT page_id FROM page_categories WHERE category_id IN ("+placeholders+")))")
+		for range 2 {
+			for _, id := range filter.CategoryIDs {
+				args = append(args, id)
+			}
+		}
+	} else {
+		if filter.Category != "" {
+			conditions = append(conditions, "parent.name = ?")
+			args = append(args, filter.Category)
+		}
+		if filter.Subcategory != "" {
+			conditions = append(conditions, "sub.name = ?")
+			args = append(args, filter.Subcategory)
+		}
+	}
+	if filter.AuthorID != "" {
+		conditions = append(conditions, "p.author_id = ?")
+		args = append(args, filter.AuthorID)
+	}
+	if !filter.UpdatedAfter.IsZero() {
+		conditions = append(conditions, "p.updated_at >= ?")
+		args = append(args, filter.UpdatedAfter.UTC())
+	}
+	if !filter.UpdatedBefore.IsZero() {
+		conditions = append(conditions, "p.updated_at < ?")
+		args = append(args, filter.UpdatedBefore.UTC())
+	}
+
+	query := `SELECT p.id, p.title, p.content, p.author_id, p.created_at, p.updated_at, p.category_id, p.expires_at, p.owner_subject, p.trusted_html
+		FROM pages p
+		LEFT JOIN categories sub ON sub.id = p.category_id
+		LEFT JOIN categories parent ON parent.id = sub.parent_id
+		WHERE ` + strings.Join(conditions, " AND ")
+	query += " ORDER BY " + orderBy
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	pages := []*Page{}
+	if err := r.db.SelectContext(ctx, &pages, r.db.Rebind(query), args...); err != nil {
+		return nil, fmt.Errorf("failed to search pages: %w", err)
+	}
+	return pages, nil
+}
+
+// hasFullTextIndex reports whether the database is MySQL with a FULLTEXT index
+// on pages.search_text. The index is optional, so it is looked up once on first use.
+func (r *SQLPageRepository) hasFullTextIndex(ctx context.Context) bool {
+	r.fullTextOnce.Do(func() {
+		if r.db.DriverName() != "mysql" {
+			return
+		}
+		var count int
+		query := `SELECT COUNT(*) FROM information_schema.statistics
+			WHERE table_schema = DATABASE() AND table_name = 'pages' AND column_name = 'search_text' AND index_type = 'FULLTEXT'`
+		if err := r.db.GetContext(ctx, &count, query); err == nil {
+			r.fullText = count > 0
+		}
+	})
+	return r.fullText
+}
+
+// isFullTextTerm reports whether the term can be looked up in the FULLTEXT
+// index: a word long enough to be indexed, without characters that the index
+// treats as separators or boolean operators.
+func isFullTextTerm(term string) bool {
+	n := 0
+	for _, r := range term {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			return false
+		}
+		n++
+	}
+	return n >= fullTextMinTermLength
+}
+
+// CountAuthors returns the number of distinct authors of pages.
+func (r *SQLPageRepository) CountAuthors(ctx context.Context) (int, error) {
+	var count int
+	if err := r.db.GetContext(ctx, &count, `SELECT COUNT(DISTINCT author_id) FROM pages WHERE deleted_at IS NULL`); err != nil {
+		return 0, fmt.Errorf("failed to count authors: %w", err)
+	}
+	return count, nil
+}
+
+// Ping checks that the database is reachable.
+func (r *SQLPageRepository) Ping(ctx context.Context) error {
+	if err := r.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("database unreachable: %w", err)
+	}
+	return nil
+}
+
+// GetPagesEditedBy returns the pages the subject created, has a revision of or owns,
+// most recently edited first. Owned pages count as edited when they were last updated.
+func (r *SQLPageRepository) GetPagesEditedBy(ctx context.Context, subject string, limit int) ([]*Page, error) {
+	pages := []*Page{}
+	query := `
+		SELECT p.id, p.title, p.content, p.author_id, p.created_at, p.updated_at, p.category_id, p.expires_at, p.owner_subject, p.trusted_html
+		FROM pages p
+		JOIN (
+			SELECT id AS page_id, created_at AS edited_at FROM pages WHERE author_id = ?
+			UNION ALL
+			SELECT page_id, created_at AS edited_at FROM revisions WHERE author_id = ?
+			UNION ALL
+			SELECT id AS page_id, updated_at AS edited_at FROM pages WHERE owner_subject = ?
+		) edits ON edits.page_id = p.id
+		WHERE p.deleted_at IS NULL
+		GROUP BY p.id, p.title, p.content, p.author_id, p.created_at, p.updated_at, p.category_id, p.expires_at, p.owner_subject, p.trusted_html
+		ORDER BY MAX(edits.edited_at) DESC, p.id DESC
+		LIMIT ?`
+	if err := r.db.SelectContext(ctx, &pages, r.db.Rebind(query), subject, subject, subject, limit); err != nil {
+		return nil, fmt.Errorf("failed to get pages edited by %s: %w", subject, err)
+	}
+	return pages, nil
+}
+
+// SetPageExpiry sets the time at which a page is archived, or clears it if expiresAt is nil.
+func (r *SQLPageRepository) SetPageExpiry(ctx context.Context, pageID int64, expiresAt *time.Time) error {
+	if expiresAt != nil {
+		utc := expiresAt.UTC()
+		expiresAt = &utc
+	}
+	if _, err := r.db.ExecContext(ctx, r.db.Rebind(`UPDATE pages SET expires_at = ? WHERE id = ?`), expiresAt, pageID); err != nil {
+		return fmt.Errorf("failed to set page expiry: %w", err)
+	}
+	return nil
+}
+
+// GetArchivedPages returns the pages whose expiry is at or before now, most recently archived first.
+func (r *SQLPageRepository) GetArchivedPages(ctx context.Context, now time.Time) ([]*Page, error) {
+	pages := []*Page{}
+	query := `SELECT id, title, content, author_id, created_at, updated_at, category_id, expires_at, owner_subject, trusted_html FROM pages
+		WHERE expires_at IS NOT NULL AND expires_at <= ? AND deleted_at IS NULL ORDER BY expires_at DESC, id DESC`
+	if err := r.db.SelectContext(ctx, &pages, r.db.Rebind(query), now.UTC()); err != nil {
+		return nil, fmt.Errorf("failed to get archived pages: %w", err)
+	}
+	return pages, nil
+}
+
+// ReplaceDeadLinks replaces the stored external link report with the given dead links.
+func (r *SQLPageRepository) ReplaceDeadLinks(ctx context.Context, links []*DeadLink) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin dead link transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM dead_external_links`); err != nil {
+		return fmt.Errorf("failed to clear dead links: %w", err)
+	}
+	query := `INSERT INTO dead_external_links (url, page_id, page_title, status_code, error, checked_at)
+		VALUES (:url, :page_id, :page_title, :status_code, :error, :checked_at)`
+	for _, link := range links {
+		if _, err := tx.NamedExecContext(ctx, query, link); err != nil {
+			return fmt.Errorf("failed to store dead link: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit dead links: %w", err)
+	}
+	return nil
+}
+
+// GetDeadLinks returns the stored external link report, ordered by page and URL.
+func (r *SQLPageRepository) GetDeadLinks(ctx context.Context) ([]*DeadLink, error) {
+	links := []*DeadLink{}
+	query := `SELECT url, page_id, page_title, status_code, error, checked_at FROM dead_external_links ORDER BY page_title, url`
+	if err := r.db.SelectContext(ctx, &links, query); err != nil {
+		return nil, fmt.Errorf("failed to get dead links: %w", err)
+	}
+	return links, nil
+}
+
+// SetPageOwner makes subject the owner of a page. An empty subject leaves the page without an owner.
+func (r *SQLPageRepository) SetPageOwner(ctx context.Context, pageID int64, subject string) error {
+	if _, err := r.db.ExecContext(ctx, r.db.Rebind(`UPDATE pages SET owner_subject = ? WHERE id = ?`), subject, pageID); err != nil {
+		return fmt.Errorf("failed to set page owner: %w", err)
+	}
+	return nil
+}
+
+// SetPageCategory files a page under another category without touching its content.
+func (r *SQLPageRepository) SetPageCategory(ctx context.Context, pageID int64, categoryID *int64) error {
+	if _, err := r.db.ExecContext(ctx, r.db.Rebind(`UPDATE pages SET category_id = ? WHERE id = ?`), categoryID, pageID); err != nil {
+		return fmt.Errorf("failed to set page category: %w", err)
 	}
 	return nil
 }
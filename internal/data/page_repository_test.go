@@ -0,0 +1,706 @@
+//go:build integration
+
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// setupPageTest creates a new in-memory SQLite database with the page-related
+// tables and returns a SQLPageRepository for testing along with a teardown function.
+func setupPageTest(t *testing.T) (*SQLPageRepository, *sqlx.DB, func()) {
+	t.Helper()
+
+	db, err := sqlx.Connect("sqlite3", "file::memory:")
+	if err != nil {
+		t.Fatalf("Failed to connect to sqlite test database: %v", err)
+	}
+
+	schema := `
+	CREATE TABLE categories (
+		id INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		parent_id INTEGER,
+		search_name TEXT,
+		order_weight INTEGER NOT NULL DEFAULT 0,
+		FOREIGN KEY (parent_id) REFERENCES categories(id) ON DELETE CASCADE,
+		UNIQUE (name, parent_id)
+	);
+	CREATE TABLE pages (
+		id INTEGER PRIMARY KEY,
+		title TEXT NOT NULL UNIQUE,
+		content TEXT NOT NULL,
+		author_id TEXT NOT NULL,
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		category_id INTEGER,
+		search_text TEXT,
+		expires_at DATETIME,
+		owner_subject TEXT NOT NULL DEFAULT '',
+		deleted_at DATETIME,
+		trusted_html BOOLEAN NOT NULL DEFAULT FALSE
+	);
+	CREATE TABLE page_categories (
+		page_id INTEGER NOT NULL,
+		category_id INTEGER NOT NULL,
+		PRIMARY KEY (page_id, category_id)
+	);
+	CREATE TABLE activity (
+		id INTEGER PRIMARY KEY,
+		page_id INTEGER,
+		page_title TEXT NOT NULL,
+		action TEXT NOT NULL,
+		author_id TEXT NOT NULL,
+		author_ip TEXT NOT NULL DEFAULT '',
+		minor BOOLEAN NOT NULL DEFAULT FALSE,
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE TABLE revisions (
+		id INTEGER PRIMARY KEY,
+		page_id INTEGER NOT NULL,
+		title TEXT NOT NULL,
+		content TEXT NOT NULL,
+		author_id TEXT NOT NULL,
+		author_ip TEXT NOT NULL DEFAULT '',
+		minor BOOLEAN NOT NULL DEFAULT FALSE,
+		trusted_html BOOLEAN NOT NULL DEFAULT FALSE,
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE TABLE page_meta (
+		page_id INTEGER NOT NULL,
+		meta_key TEXT NOT NULL,
+		meta_value TEXT NOT NULL,
+		PRIMARY KEY (page_id, meta_key)
+	);`
+	db.MustExec(schema)
+
+	teardown := func() {
+		db.Close()
+	}
+	return NewSQLPageRepository(db), db, teardown
+}
+
+func TestSQLPageRepository_GetRecentActivity_Filters(t *testing.T) {
+	repo, _, teardown := setupPageTest(t)
+	defer teardown()
+	ctx := context.Background()
+
+	base := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	entries := []*Activity{
+		{PageTitle: "Alpha", Action: ActivityCreate, AuthorID: "alice", CreatedAt: base},
+		{PageTitle: "Beta", Action: ActivityCreate, AuthorID: "bob", CreatedAt: base.Add(24 * time.Hour)},
+		{PageTitle: "Alpha", Action: ActivityUpdate, AuthorID: "alice", CreatedAt: base.Add(48 * time.Hour)},
+	}
+	for _, a := range entries {
+		if err := repo.RecordActivity(ctx, a); err != nil {
+			t.Fatalf("RecordActivity failed: %v", err)
+		}
+	}
+
+	t.Run("no filter returns newest first", func(t *testing.T) {
+		got, err := repo.GetRecentActivity(ctx, ActivityFilter{}, 10, 0)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(got) != 3 {
+			t.Fatalf("expected 3 entries, got %d", len(got))
+		}
+		if got[0].Action != ActivityUpdate || got[0].PageTitle != "Alpha" {
+			t.Errorf("expected newest entry to be the Alpha update, got %+v", got[0])
+		}
+	})
+
+	t.Run("by author", func(t *testing.T) {
+		got, err := repo.GetRecentActivity(ctx, ActivityFilter{AuthorID: "alice"}, 10, 0)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(got) != 2 {
+			t.Fatalf("expected 2 entries for alice, got %d", len(got))
+		}
+		for _, a := range got {
+			if a.AuthorID != "alice" {
+				t.Errorf("expected only alice's activity, got author %s", a.AuthorID)
+			}
+		}
+	})
+
+	t.Run("by date range", func(t *testing.T) {
+		filter := ActivityFilter{Since: base.Add(12 * time.Hour), Until: base.Add(36 * time.Hour)}
+		got, err := repo.GetRecentActivity(ctx, filter, 10, 0)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(got) != 1 || got[0].PageTitle != "Beta" {
+			t.Fatalf("expected only the Beta entry, got %+v", got)
+		}
+	})
+
+	t.Run("by author and date", func(t *testing.T) {
+		filter := ActivityFilter{AuthorID: "alice", Since: base.Add(time.Hour)}
+		got, err := repo.GetRecentActivity(ctx, filter, 10, 0)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(got) != 1 || got[0].Action != ActivityUpdate {
+			t.Fatalf("expected only alice's update, got %+v", got)
+		}
+	})
+}
+
+func TestSQLPageRepository_GetRecentActivity_Pagination(t *testing.T) {
+	repo, _, teardown := setupPageTest(t)
+	defer teardown()
+	ctx := context.Background()
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		a := &Activity{PageTitle: "Page", Action: ActivityUpdate, AuthorID: "alice", CreatedAt: base.Add(time.Duration(i) * time.Hour)}
+		if err := repo.RecordActivity(ctx, a); err != nil {
+			t.Fatalf("RecordActivity failed: %v", err)
+		}
+	}
+
+	first, err := repo.GetRecentActivity(ctx, ActivityFilter{}, 2, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	second, err := repo.GetRecentActivity(ctx, ActivityFilter{}, 2, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	last, err := repo.GetRecentActivity(ctx, ActivityFilter{}, 2, 4)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(first) != 2 || len(second) != 2 || len(last) != 1 {
+		t.Fatalf("unexpected page sizes: %d, %d, %d", len(first), len(second), len(last))
+	}
+	if !first[1].CreatedAt.After(second[0].CreatedAt) {
+		t.Errorf("expected pages to be ordered newest first without overlap")
+	}
+
+	beyond, err := repo.GetRecentActivity(ctx, ActivityFilter{}, 2, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(beyond) != 0 {
+		t.Errorf("expected no entries beyond the end, got %d", len(beyond))
+	}
+}
+
+func TestSQLPageRepository_CreatePage_SetsID(t *testing.T) {
+	repo, _, teardown := setupPageTest(t)
+	defer teardown()
+	ctx := context.Background()
+
+	first := &Page{Title: "First", Content: "One", AuthorID: "alice"}
+	second := &Page{Title: "Second", Content: "Two", AuthorID: "alice"}
+	for _, page := range []*Page{first, second} {
+		if err := repo.CreatePage(ctx, page); err != nil {
+			t.Fatalf("CreatePage failed: %v", err)
+		}
+	}
+	if first.ID == 0 || second.ID == first.ID {
+		t.Fatalf("expected distinct generated IDs, got %d and %d", first.ID, second.ID)
+	}
+
+	saved, err := repo.GetPageByID(ctx, second.ID)
+	if err != nil {
+		t.Fatalf("GetPageByID failed: %v", err)
+	}
+	if saved.Title != "Second" || saved.Content != "Two" {
+		t.Errorf("expected the row of the second page, got %+v", saved)
+	}
+}
+
+func TestSQLPageRepository_PageMetadata(t *testing.T) {
+	repo, _, teardown := setupPageTest(t)
+	defer teardown()
+	ctx := context.Background()
+
+	page := &Page{Title: "Runbook", Content: "Steps", AuthorID: "alice"}
+	if err := repo.CreatePage(ctx, page); err != nil {
+		t.Fatalf("CreatePage failed: %v", err)
+	}
+
+	if err := repo.SetPageMetadata(ctx, page.ID, "owner", "alice"); err != nil {
+		t.Fatalf("SetPageMetadata failed: %v", err)
+	}
+	if err := repo.SetPageMetadata(ctx, page.ID, "status", "draft"); err != nil {
+		t.Fatalf("SetPageMetadata failed: %v", err)
+	}
+	// Setting an existing key replaces its value.
+	if err := repo.SetPageMetadata(ctx, page.ID, "status", "reviewed"); err != nil {
+		t.Fatalf("SetPageMetadata update failed: %v", err)
+	}
+
+	meta, err := repo.GetPageMetadata(ctx, page.ID)
+	if err != nil {
+		t.Fatalf("GetPageMetadata failed: %v", err)
+	}
+	if len(meta) != 2 || meta["owner"] != "alice" || meta["status"] != "reviewed" {
+		t.Errorf("unexpected metadata: %v", meta)
+	}
+
+	if err := repo.DeletePageMetadata(ctx, page.ID, "owner"); err != nil {
+		t.Fatalf("DeletePageMetadata failed: %v", err)
+	}
+	meta, err = repo.GetPageMetadata(ctx, page.ID)
+	if err != nil {
+		t.Fatalf("GetPageMetadata failed: %v", err)
+	}
+	if _, ok := meta["owner"]; ok || meta["status"] != "reviewed" {
+		t.Errorf("expected only status to remain, got %v", meta)
+	}
+}
+
+func TestSQLPageRepository_PageCategories(t *testing.T) {
+	repo, db, teardown := setupPageTest(t)
+	defer teardown()
+	categories := NewCategoryRepository(db)
+	ctx := context.Background()
+
+	science, _ := categories.Save(&Category{Name: "Science"})
+	history, _ := categories.Save(&Category{Name: "History"})
+	page := &Page{Title: "Galileo", Content: "Astronomer", AuthorID: "alice", CategoryID: &science}
+	if err := repo.CreatePage(ctx, page); err != nil {
+		t.Fatalf("CreatePage failed: %v", err)
+	}
+
+	// Duplicate IDs are stored once.
+	if err := categories.SetPageCategories(ctx, page.ID, []int64{science, history, history}); err != nil {
+		t.Fatalf("SetPageCategories failed: %v", err)
+	}
+	tagged, err := categories.GetCategoriesForPage(ctx, page.ID)
+	if err != nil {
+		t.Fatalf("GetCategoriesForPage failed: %v", err)
+	}
+	if len(tagged) != 2 || tagged[0].Name != "History" || tagged[1].Name != "Science" {
+		t.Errorf("expected History and Science, got %+v", tagged)
+	}
+	for _, id := range []int64{science, history} {
+		pages, err := repo.GetPagesByCategoryID(ctx, id)
+		if err != nil {
+			t.Fatalf("GetPagesByCategoryID failed: %v", err)
+		}
+		if len(pages) != 1 || pages[0].Title != "Galileo" {
+			t.Errorf("expected Galileo under category %d, got %+v", id, pages)
+		}
+	}
+
+	// Setting the categories again replaces the previous ones.
+	if err := categories.SetPageCategories(ctx, page.ID, []int64{science}); err != nil {
+		t.Fatalf("SetPageCategories failed: %v", err)
+	}
+	if pages, _ := repo.GetPagesByCategoryID(ctx, history); len(pages) != 0 {
+		t.Errorf("expected no pages under History, got %+v", pages)
+	}
+}
+
+func TestSQLPageRepository_GetContentStats(t *testing.T) {
+	repo, _, teardown := setupPageTest(t)
+	defer teardown()
+	ctx := context.Background()
+
+	stats, err := repo.GetContentStats(ctx)
+	if err != nil {
+		t.Fatalf("GetContentStats failed: %v", err)
+	}
+	if stats.PageCount != 0 || stats.ContentBytes != 0 {
+		t.Errorf("expected empty stats for an empty wiki, got %+v", stats)
+	}
+
+	for _, p := range []*Page{
+		{Title: "A", Content: "12345", AuthorID: "alice"},
+		{Title: "B", Content: "123", AuthorID: "bob"},
+	} {
+		if err := repo.CreatePage(ctx, p); err != nil {
+			t.Fatalf("CreatePage failed: %v", err)
+		}
+	}
+	stats, err = repo.GetContentStats(ctx)
+	if err != nil {
+		t.Fatalf("GetContentStats failed: %v", err)
+	}
+	if stats.PageCount != 2 || stats.ContentBytes != 8 {
+		t.Errorf("expected 2 pages and 8 bytes, got %+v", stats)
+	}
+}
+
+func TestSQLPageRepository_GetPagesPaged(t *testing.T) {
+	repo, _, teardown := setupPageTest(t)
+	defer teardown()
+	ctx := context.Background()
+
+	for _, title := range []string{"Charlie", "Alpha", "Bravo"} {
+		if err := repo.CreatePage(ctx, &Page{Title: title, Content: "Content", AuthorID: "alice"}); err != nil {
+			t.Fatalf("CreatePage failed: %v", err)
+		}
+	}
+
+	count, err := repo.CountPages(ctx)
+	if err != nil || count != 3 {
+		t.Fatalf("expected 3 pages, got %d (%v)", count, err)
+	}
+
+	first, err := repo.GetPagesPaged(ctx, 2, 0)
+	if err != nil {
+		t.Fatalf("GetPagesPaged failed: %v", err)
+	}
+	if len(first) != 2 || first[0].Title != "Alpha" || first[1].Title != "Bravo" {
+		t.Errorf("expected Alpha and Bravo on the first page, got %+v", first)
+	}
+	second, err := repo.GetPagesPaged(ctx, 2, 2)
+	if err != nil {
+		t.Fatalf("GetPagesPaged failed: %v", err)
+	}
+	if len(second) != 1 || second[0].Title != "Charlie" {
+		t.Errorf("expected Charlie on the second page, got %+v", second)
+	}
+
+	beyond, err := repo.GetPagesPaged(ctx, 2, 10)
+	if err != nil {
+		t.Fatalf("expected no error for an offset past the last row, got %v", err)
+	}
+	if beyond == nil || len(beyond) != 0 {
+		t.Errorf("expected an empty slice for an offset past the last row, got %#v", beyond)
+	}
+}
+
+func TestSQLPageRepository_GetMaxUpdatedAt(t *testing.T) {
+	repo, _, teardown := setupPageTest(t)
+	defer teardown()
+	ctx := context.Background()
+
+	if updatedAt, err := repo.GetMaxUpdatedAt(ctx); err != nil || !updatedAt.IsZero() {
+		t.Fatalf("expected the zero time without pages, got %v (%v)", updatedAt, err)
+	}
+
+	older := &Page{Title: "Older", Content: "Content", AuthorID: "alice"}
+	newer := &Page{Title: "Newer", Content: "Content", AuthorID: "alice"}
+	for _, page := range []*Page{older, newer} {
+		if err := repo.CreatePage(ctx, page); err != nil {
+			t.Fatalf("CreatePage failed: %v", err)
+		}
+	}
+	newer.UpdatedAt = time.Now().UTC().Add(time.Hour).Truncate(time.Second)
+	if err := repo.UpdatePage(ctx, newer); err != nil {
+		t.Fatalf("UpdatePage failed: %v", err)
+	}
+
+	updatedAt, err := repo.GetMaxUpdatedAt(ctx)
+	if err != nil {
+		t.Fatalf("GetMaxUpdatedAt failed: %v", err)
+	}
+	if !updatedAt.Equal(newer.UpdatedAt) {
+		t.Errorf("expected the newest update %v, got %v", newer.UpdatedAt, updatedAt)
+	}
+}
+
+func TestSQLPageRepository_GetLatestArchivedAt(t *testing.T) {
+	repo, _, teardown := setupPageTest(t)
+	defer teardown()
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+
+	if archivedAt, err := repo.GetLatestArchivedAt(ctx, now); err != nil || !archivedAt.IsZero() {
+		t.Fatalf("expected the zero time without archived pages, got %v (%v)", archivedAt, err)
+	}
+
+	expiries := map[string]time.Time{
+		"Older":  now.Add(-2 * time.Hour),
+		"Newer":  now.Add(-time.Hour),
+		"Future": now.Add(time.Hour),
+	}
+	for title, expiresAt := range expiries {
+		page := &Page{Title: title, Content: "Content", AuthorID: "alice"}
+		if err := repo.CreatePage(ctx, page); err != nil {
+			t.Fatalf("CreatePage failed: %v", err)
+		}
+		if err := repo.SetPageExpiry(ctx, page.ID, &expiresAt); err != nil {
+			t.Fatalf("SetPageExpiry failed: %v", err)
+		}
+	}
+
+	archivedAt, err := repo.GetLatestArchivedAt(ctx, now)
+	if err != nil {
+		t.Fatalf("GetLatestArchivedAt failed: %v", err)
+	}
+	if !archivedAt.Equal(expiries["Newer"]) {
+		t.Errorf("expected the most recent passed expiry %v, got %v", expiries["Newer"], archivedAt)
+	}
+}
+
+func TestSQLPageRepository_GetPagesByTitles(t *testing.T) {
+	repo, _, teardown := setupPageTest(t)
+	defer teardown()
+	ctx := context.Background()
+
+	for _, title := range []string{"Alpha", "Beta", "Gamma"} {
+		if err := repo.CreatePage(ctx, &Page{Title: title, Content: title + " content", AuthorID: "alice"}); err != nil {
+			t.Fatalf("CreatePage failed: %v", err)
+		}
+	}
+
+	pages, err := repo.GetPagesByTitles(ctx, []string{"Alpha", "Gamma", "Missing"})
+	if err != nil {
+		t.Fatalf("GetPagesByTitles failed: %v", err)
+	}
+	got := map[string]string{}
+	for _, page := range pages {
+		got[page.Title] = page.Content
+	}
+	if len(got) != 2 || got["Alpha"] != "Alpha content" || got["Gamma"] != "Gamma content" {
+		t.Errorf("expected Alpha and Gamma only, got %v", got)
+	}
+
+	if pages, err := repo.GetPagesByTitles(ctx, nil); err != nil || len(pages) != 0 {
+		t.Errorf("expected no pages for no titles, got %v (%v)", pages, err)
+	}
+}
+
+func TestSQLPageRepository_Trash(t *testing.T) {
+	repo, _, teardown := setupPageTest(t)
+	defer teardown()
+	ctx := context.Background()
+
+	page := &Page{Title: "Doomed", Content: "Soon gone", AuthorID: "alice"}
+	if err := repo.CreatePage(ctx, page); err != nil {
+		t.Fatalf("CreatePage failed: %v", err)
+	}
+	if err := repo.DeletePage(ctx, page.ID); err != nil {
+		t.Fatalf("DeletePage failed: %v", err)
+	}
+
+	if _, err := repo.GetPageByTitle(ctx, "Doomed"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected a deleted page to be hidden from GetPageByTitle, got %v", err)
+	}
+	if pages, _ := repo.GetAllPages(ctx); len(pages) != 0 {
+		t.Errorf("expected a deleted page to be hidden from GetAllPages, got %d pages", len(pages))
+	}
+	trash, err := repo.GetDeletedPages(ctx)
+	if err != nil {
+		t.Fatalf("GetDeletedPages failed: %v", err)
+	}
+	if len(trash) != 1 || trash[0].Title != "Doomed" || trash[0].DeletedAt == nil {
+		t.Fatalf("expected the page in the trash with its deletion time, got %+v", trash)
+	}
+	if err := repo.DeletePage(ctx, page.ID); err == nil {
+		t.Error("expected deleting a page already in the trash to fail")
+	}
+
+	if err := repo.RestorePage(ctx, page.ID); err != nil {
+		t.Fatalf("RestorePage failed: %v", err)
+	}
+	if _, err := repo.GetPageByTitle(ctx, "Doomed"); err != nil {
+		t.Errorf("expected the restored page to be found, got %v", err)
+	}
+	if err := repo.PurgePage(ctx, page.ID); err == nil {
+		t.Error("expected purging a page that is not in the trash to fail")
+	}
+
+	if err := repo.DeletePage(ctx, page.ID); err != nil {
+		t.Fatalf("DeletePage failed: %v", err)
+	}
+	if err := repo.PurgePage(ctx, page.ID); err != nil {
+		t.Fatalf("PurgePage failed: %v", err)
+	}
+	if _, err := repo.GetPageByID(ctx, page.ID); err == nil {
+		t.Error("expected the purged page to be gone")
+	}
+}
+
+func TestSQLPageRepository_SearchPages_Filters(t *testing.T) {
+	repo, db, teardown := setupPageTest(t)
+	defer teardown()
+	ctx := context.Background()
+
+	db.MustExec(`INSERT INTO categories (id, name, parent_id) VALUES (1, 'Ops', NULL), (2, 'Deploy', 1), (3, 'Dev', NULL), (4, 'Deploy', 3)`)
+	jan := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	mar := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+	pages := []struct {
+		title, content, author string
+		categoryID             int64
+		updated                time.Time
+	}{
+		{"Release checklist", "How to deploy a release", "alice", 2, jan},
+		{"Rollback", "Undo a deploy", "bob", 2, mar},
+		{"Local setup", "Deploy to your laptop", "alice", 4, mar},
+		{"Coding style", "Formatting rules, 100% enforced", "carol", 4, jan},
+	}
+	for _, p := range pages {
+		page := &Page{Title: p.title, Content: p.content, AuthorID: p.author, CategoryID: &p.categoryID}
+		if err := repo.CreatePage(ctx, page); err != nil {
+			t.Fatalf("CreatePage failed: %v", err)
+		}
+		db.MustExec(`UPDATE pages SET updated_at = ? WHERE id = ?`, p.updated, page.ID)
+	}
+
+	titles := func(pages []*Page) []string {
+		var out []string
+		for _, p := range pages {
+			out = append(out, p.Title)
+		}
+		return out
+	}
+
+	tests := []struct {
+		name   string
+		filter SearchFilter
+		want   []string
+	}{
+		{"query only", SearchFilter{Query: "deploy"}, []string{"Local setup", "Rollback", "Release checklist"}},
+		{"blank query matches every page", SearchFilter{Query: "  ", Sort: SearchSortTitle}, []string{"Coding style", "Local setup", "Release checklist", "Rollback"}},
+		{"all words must match", SearchFilter{Query: "deploy release"}, []string{"Release checklist"}},
+		{"wildcards match literally", SearchFilter{Query: "100%"}, []string{"Coding style"}},
+		{"underscore is not a wildcard", SearchFilter{Query: "de_loy"}, nil},
+		{"category", SearchFilter{Category: "Ops"}, []string{"Rollback", "Release checklist"}},
+		{"subcategory across categories", SearchFilter{Subcategory: "Deploy", Sort: SearchSortTitle}, []string{"Coding style", "Local setup", "Release checklist", "Rollback"}},
+		{"author", SearchFilter{AuthorID: "alice"}, []string{"Local setup", "Release checklist"}},
+		{"updated after", SearchFilter{UpdatedAfter: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)}, []string{"Local setup", "Rollback"}},
+		{"updated before", SearchFilter{UpdatedBefore: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), Sort: SearchSortTitle}, []string{"Coding style", "Release checklist"}},
+		{"query and category", SearchFilter{Query: "deploy", Category: "Dev"}, []string{"Local setup"}},
+		{"query and author", SearchFilter{Query: "deploy", AuthorID: "bob"}, []string{"Rollback"}},
+		{"category and author", SearchFilter{Category: "Ops", AuthorID: "alice"}, []string{"Release checklist"}},
+		{"category and date range", SearchFilter{Category: "Dev", UpdatedAfter: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), UpdatedBefore: time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)}, []string{"Local setup"}},
+		{"all filters", SearchFilter{Query: "deploy", Category: "Ops", Subcategory: "Deploy", AuthorID: "alice", UpdatedAfter: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), UpdatedBefore: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)}, []string{"Release checklist"}},
+		{"limit", SearchFilter{Sort: SearchSortTitle, Limit: 2}, []string{"Coding style", "Local setup"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := repo.SearchPages(ctx, tt.filter)
+			if err != nil {
+				t.Fatalf("SearchPages failed: %v", err)
+			}
+			if fmt.Sprint(titles(got)) != fmt.Sprint(tt.want) {
+				t.Errorf("want %v; got %v", tt.want, titles(got))
+			}
+		})
+	}
+
+	// Category IDs match the pages filed under or tagged with the categories.
+	db.MustExec(`INSERT INTO page_categories (page_id, category_id) VALUES (4, 2)`)
+	got, err := repo.SearchPages(ctx, SearchFilter{CategoryIDs: []int64{2}, Sort: SearchSortTitle})
+	if err != nil {
+		t.Fatalf("SearchPages failed: %v", err)
+	}
+	if want := []string{"Coding style", "Release checklist", "Rollback"}; fmt.Sprint(titles(got)) != fmt.Sprint(want) {
+		t.Errorf("want %v; got %v", want, titles(got))
+	}
+	if got, _ := repo.SearchPages(ctx, SearchFilter{CategoryIDs: []int64{}}); len(got) != 0 {
+		t.Errorf("expected no matches for an empty set of categories, got %v", titles(got))
+	}
+
+	if _, err := repo.SearchPages(ctx, SearchFilter{Sort: "id; DROP TABLE pages"}); err == nil {
+		t.Error("expected an unsupported sort key to be rejected")
+	}
+}
+
+func TestSQLPageRepository_GetPagesEditedBy(t *testing.T) {
+	repo, db, teardown := setupPageTest(t)
+	defer teardown()
+	ctx := context.Background()
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	// alice created Old and Created; bob created Edited, which alice edited last.
+	for _, p := range []struct {
+		id      int64
+		title   string
+		author  string
+		created time.Time
+	}{
+		{1, "Old", "alice", base},
+		{2, "Edited", "bob", base.Add(time.Hour)},
+		{3, "Created", "alice", base.Add(2 * time.Hour)},
+		{4, "Untouched", "bob", base.Add(4 * time.Hour)},
+	} {
+		db.MustExec(`INSERT INTO pages (id, title, content, author_id, created_at, updated_at) VALUES (?, ?, '', ?, ?, ?)`,
+			p.id, p.title, p.author, p.created, p.created)
+	}
+	for _, r := range []struct {
+		pageID  int64
+		author  string
+		created time.Time
+	}{
+		{1, "alice", base},
+		{2, "bob", base.Add(time.Hour)},
+		{2, "alice", base.Add(3 * time.Hour)},
+		{4, "bob", base.Add(4 * time.Hour)},
+	} {
+		db.MustExec(`INSERT INTO revisions (page_id, title, content, author_id, created_at) VALUES (?, '', '', ?, ?)`,
+			r.pageID, r.author, r.created)
+	}
+
+	pages, err := repo.GetPagesEditedBy(ctx, "alice", 10)
+	if err != nil {
+		t.Fatalf("GetPagesEditedBy failed: %v", err)
+	}
+	var titles []string
+	for _, p := range pages {
+		titles = append(titles, p.Title)
+	}
+	if got := fmt.Sprint(titles); got != "[Edited Created Old]" {
+		t.Errorf("expected alice's pages by most recent edit, got %s", got)
+	}
+
+	if pages, _ := repo.GetPagesEditedBy(ctx, "alice", 1); len(pages) != 1 || pages[0].Title != "Edited" {
+		t.Errorf("expected the limit to keep only the most recent page, got %v", pages)
+	}
+	if pages, _ := repo.GetPagesEditedBy(ctx, "nobody", 10); len(pages) != 0 {
+		t.Errorf("expected no pages for a user without edits, got %d", len(pages))
+	}
+}
+
+func TestSQLPageRepository_SearchPages_IgnoresCaseAndAccents(t *testing.T) {
+	repo, db, teardown := setupPageTest(t)
+	defer teardown()
+	ctx := context.Background()
+
+	for _, page := range []*Page{
+		{Title: "Café menu", Content: "Crème brûlée", AuthorID: "alice"},
+		{Title: "Cafe hours", Content: "Open daily", AuthorID: "alice"},
+		{Title: "Tea", Content: "Green tea", AuthorID: "alice"},
+	} {
+		if err := repo.CreatePage(ctx, page); err != nil {
+			t.Fatalf("CreatePage failed: %v", err)
+		}
+	}
+	// Pages saved before the search column existed are picked up by the backfill.
+	db.MustExec(`INSERT INTO pages (title, content, author_id) VALUES ('Straße', 'Über die Straße', 'bob')`)
+	if err := BackfillSearchColumns(ctx, db); err != nil {
+		t.Fatalf("BackfillSearchColumns failed: %v", err)
+	}
+
+	testCases := []struct {
+		query string
+		want  string
+	}{
+		{"cafe", "[Cafe hours Café menu]"},
+		{"CAFÉ", "[Cafe hours Café menu]"},
+		{"creme brulee", "[Café menu]"},
+		{"strasse uber", "[Straße]"},
+	}
+	for _, tc := range testCases {
+		pages, err := repo.SearchPages(ctx, SearchFilter{Query: tc.query, Sort: SearchSortTitle})
+		if err != nil {
+			t.Fatalf("SearchPages(%q) failed: %v", tc.query, err)
+		}
+		var titles []string
+		for _, p := range pages {
+			titles = append(titles, p.Title)
+		}
+		if got := fmt.Sprint(titles); got != tc.want {
+			t.Errorf("SearchPages(%q) = %s, want %s", tc.query, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// SQLPendingEditRepository handles database operations for the queue of edits
+// awaiting review.
+type SQLPendingEditRepository struct {
+	db *sqlx.DB
+}
+
+// NewSQLPendingEditRepository creates a new SQLPendingEditRepository.
+func NewSQLPendingEditRepository(db *sqlx.DB) *SQLPendingEditRepository {
+	return &SQLPendingEditRepository{db: db}
+}
+
+// CreatePendingEdit adds an edit to the review queue and sets its ID. An edit
+// without a PageID queues a new page.
+func (r *SQLPendingEditRepository) CreatePendingEdit(ctx context.Context, edit *PendingEdit) error {
+	if edit.CreatedAt.IsZero() {
+		edit.CreatedAt = time.Now().UTC()
+	}
+	query := `INSERT INTO page_pending_edits (page_id, title, content, category_name, subcategory_name, author_id, author_ip, minor, entry, created_at)
+		VALUES (NULLIF(:page_id, 0), :title, :content, :category_name, :subcategory_name, :author_id, :author_ip, :minor, :entry, :created_at)`
+	id, err := insertReturningID(ctx, r.db, query, edit)
+	if err != nil {
+		return fmt.Errorf("failed to queue edit: %w", err)
+	}
+	edit.ID = id
+	return nil
+}
+
+// GetPendingEdit retrieves a queued edit by its ID. The returned error wraps
+// sql.ErrNoRows if there is no such edit.
+func (r *SQLPendingEditRepository) GetPendingEdit(ctx context.Context, id int64) (*PendingEdit, error) {
+	var edit PendingEdit
+	query := `SELECT id, COALESCE(page_id, 0) AS page_id, title, content, category_name, subcategory_name, author_id, author_ip, minor, entry, created_at FROM page_pending_edits WHERE id = ?`
+	if err := r.db.GetContext(ctx, &edit, r.db.Rebind(query), id); err != nil {
+		return nil, fmt.Errorf("failed to get pending edit %d: %w", id, err)
+	}
+	return &edit, nil
+}
+
+// GetPendingEdits retrieves every queued edit, oldest first.
+func (r *SQLPendingEditRepository) GetPendingEdits(ctx context.Context) ([]*PendingEdit, error) {
+	edits := []*PendingEdit{}
+	query := `SELECT id, COALESCE(page_id, 0) AS page_id, title, content, category_name, subcategory_name, author_id, author_ip, minor, entry, created_at FROM page_pending_edits ORDER BY created_at, id`
+	if err := r.db.SelectContext(ctx, &edits, query); err != nil {
+		return nil, fmt.Errorf("failed to get pending edits: %w", err)
+	}
+	return edits, nil
+}
+
+// DeletePendingEdit removes an edit from the review queue.
+func (r *SQLPendingEditRepository) DeletePendingEdit(ctx context.Context, id int64) error {
+	if _, err := r.db.ExecContext(ctx, r.db.Rebind(`DELETE FROM page_pending_edits WHERE id = ?`), id); err != nil {
+		return fmt.Errorf("failed to delete pending edit %d: %w", id, err)
+	}
+	return nil
+}
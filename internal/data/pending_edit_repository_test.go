@@ -0,0 +1,81 @@
+//go:build integration
+
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSQLPendingEditRepository_Queue(t *testing.T) {
+	_, db, teardown := setupPageTest(t)
+	defer teardown()
+	db.MustExec(`CREATE TABLE page_pending_edits (
+		id INTEGER PRIMARY KEY,
+		page_id INTEGER,
+		title TEXT NOT NULL,
+		content TEXT NOT NULL,
+		category_name TEXT NOT NULL DEFAULT '',
+		subcategory_name TEXT NOT NULL DEFAULT '',
+		author_id TEXT NOT NULL,
+		author_ip TEXT NOT NULL DEFAULT '',
+		minor BOOLEAN NOT NULL DEFAULT FALSE,
+		entry BOOLEAN NOT NULL DEFAULT FALSE,
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`)
+	repo := NewSQLPendingEditRepository(db)
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	second := &PendingEdit{PageID: 1, Title: "Runbook", Content: "second", AuthorID: "anonymous", AuthorIP: "192.0.2.7", CreatedAt: now}
+	first := &PendingEdit{PageID: 2, Title: "Guide", Content: "first", CategoryName: "Docs", AuthorID: "alice", Minor: true, CreatedAt: now.Add(-time.Minute)}
+	for _, edit := range []*PendingEdit{second, first} {
+		if err := repo.CreatePendingEdit(ctx, edit); err != nil {
+			t.Fatalf("CreatePendingEdit failed: %v", err)
+		}
+		if edit.ID == 0 {
+			t.Fatal("expected CreatePendingEdit to set the ID")
+		}
+	}
+
+	edits, err := repo.GetPendingEdits(ctx)
+	if err != nil {
+		t.Fatalf("GetPendingEdits failed: %v", err)
+	}
+	if len(edits) != 2 || edits[0].Content != "first" || edits[1].Content != "second" {
+		t.Fatalf("expected both edits, oldest first, got %+v", edits)
+	}
+	got, err := repo.GetPendingEdit(ctx, second.ID)
+	if err != nil {
+		t.Fatalf("GetPendingEdit failed: %v", err)
+	}
+	if got.AuthorIP != "192.0.2.7" || got.PageID != 1 {
+		t.Errorf("expected the anonymous edit of page 1, got %+v", got)
+	}
+
+	if err := repo.DeletePendingEdit(ctx, second.ID); err != nil {
+		t.Fatalf("DeletePendingEdit failed: %v", err)
+	}
+	if _, err := repo.GetPendingEdit(ctx, second.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected sql.ErrNoRows for a deleted edit, got %v", err)
+	}
+	if edits, _ := repo.GetPendingEdits(ctx); len(edits) != 1 {
+		t.Errorf("expected one edit left in the queue, got %d", len(edits))
+	}
+
+	newPage := &PendingEdit{Title: "Draft", Content: "new", AuthorID: "bob", Entry: true}
+	if err := repo.CreatePendingEdit(ctx, newPage); err != nil {
+		t.Fatalf("CreatePendingEdit failed for a new page: %v", err)
+	}
+	var pageID sql.NullInt64
+	db.Get(&pageID, `SELECT page_id FROM page_pending_edits WHERE id = ?`, newPage.ID)
+	if pageID.Valid {
+		t.Errorf("expected a new page to be queued without a page_id, got %d", pageID.Int64)
+	}
+	if got, err := repo.GetPendingEdit(ctx, newPage.ID); err != nil || got.PageID != 0 || got.Title != "Draft" || !got.Entry {
+		t.Errorf("expected the queued new page without a page ID, got %+v (%v)", got, err)
+	}
+}
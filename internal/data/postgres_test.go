@@ -0,0 +1,56 @@
+//go:build integration && postgres
+
+package data
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"go-wiki-app/internal/config"
+)
+
+// setupPostgresTest connects to the PostgreSQL database named by
+// WIKI_TEST_POSTGRES_DSN and brings its schema up to date. The test is skipped
+// if the variable is not set. The database should be empty and disposable.
+func setupPostgresTest(t *testing.T) *SQLPageRepository {
+	t.Helper()
+
+	dsn := os.Getenv("WIKI_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("WIKI_TEST_POSTGRES_DSN is not set")
+	}
+	if err := ApplyMigrations(DriverPostgres, dsn, "../../migrations"); err != nil {
+		t.Fatalf("Failed to apply postgres migrations: %v", err)
+	}
+	db, err := NewDB(config.DBConfig{Driver: DriverPostgres, DSN: dsn, MaxOpenConns: 2, MaxIdleConns: 2})
+	if err != nil {
+		t.Fatalf("Failed to connect to postgres: %v", err)
+	}
+	t.Cleanup(func() {
+		db.MustExec(`TRUNCATE pages, categories, activity RESTART IDENTITY CASCADE`)
+		db.Close()
+	})
+	return NewSQLPageRepository(db)
+}
+
+func TestPostgres_CreateAndGetPage(t *testing.T) {
+	repo := setupPostgresTest(t)
+	ctx := context.Background()
+
+	page := &Page{Title: "Postgres Page", Content: "Stored in PostgreSQL.", AuthorID: "alice"}
+	if err := repo.CreatePage(ctx, page); err != nil {
+		t.Fatalf("CreatePage failed: %v", err)
+	}
+	if page.ID == 0 {
+		t.Fatal("expected CreatePage to set the ID from RETURNING")
+	}
+
+	got, err := repo.GetPageByTitle(ctx, "Postgres Page")
+	if err != nil {
+		t.Fatalf("GetPageByTitle failed: %v", err)
+	}
+	if got.ID != page.ID || got.Content != page.Content {
+		t.Errorf("expected the created page back, got %+v", got)
+	}
+}
@@ -0,0 +1,128 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// SQLRevisionRepository handles database operations for page revisions.
+type SQLRevisionRepository struct {
+	db *sqlx.DB
+}
+
+// NewSQLRevisionRepository creates a new SQLRevisionRepository.
+func NewSQLRevisionRepository(db *sqlx.DB) *SQLRevisionRepository {
+	return &SQLRevisionRepository{db: db}
+}
+
+// CreateRevision stores a snapshot of a page's content and sets the revision's ID.
+func (r *SQLRevisionRepository) CreateRevision(ctx context.Context, revision *Revision) error {
+	return createRevision(ctx, r.db, revision)
+}
+
+// UpdatePageWithRevision records the revision and saves the page in a single
+// transaction, so a failed update never leaves a revision of content that was
+// not saved.
+func (r *SQLRevisionRepository) UpdatePageWithRevision(ctx context.Context, page *Page, revision *Revision) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin page update transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := createRevision(ctx, tx, revision); err != nil {
+		return err
+	}
+	if err := updatePage(ctx, tx, page); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit page update: %w", err)
+	}
+	return nil
+}
+
+// createRevision inserts the revision using db, which may be a transaction.
+func createRevision(ctx context.Context, db sqlx.ExtContext, revision *Revision) error {
+	if revision.CreatedAt.IsZero() {
+		revision.CreatedAt = time.Now().UTC()
+	}
+	query := `INSERT INTO revisions (page_id, title, content, author_id, author_ip, minor, trusted_html, created_at) VALUES (:page_id, :title, :content, :author_id, :author_ip, :minor, :trusted_html, :created_at)`
+	id, err := insertReturningID(ctx, db, query, revision)
+	if err != nil {
+		return fmt.Errorf("failed to create revision: %w", err)
+	}
+	revision.ID = id
+	return nil
+}
+
+// GetRevision retrieves a single revision by its ID. The returned error wraps
+// sql.ErrNoRows if there is no such revision.
+func (r *SQLRevisionRepository) GetRevision(ctx context.Context, id int64) (*Revision, error) {
+	var revision Revision
+	query := `SELECT id, page_id, title, content, author_id, author_ip, minor, trusted_html, created_at FROM revisions WHERE id = ?`
+	if err := r.db.GetContext(ctx, &revision, r.db.Rebind(query), id); err != nil {
+		return nil, fmt.Errorf("failed to get revision %d: %w", id, err)
+	}
+	return &revision, nil
+}
+
+// GetRevisions retrieves all revisions of a page, newest first.
+func (r *SQLRevisionRepository) GetRevisions(ctx context.Context, pageID int64) ([]*Revision, error) {
+	revisions := []*Revision{}
+	query := `SELECT id, page_id, title, content, author_id, author_ip, minor, trusted_html, created_at FROM revisions WHERE page_id = ? ORDER BY created_at DESC, id DESC`
+	if err := r.db.SelectContext(ctx, &revisions, r.db.Rebind(query), pageID); err != nil {
+		return nil, fmt.Errorf("failed to get revisions for page: %w", err)
+	}
+	return revisions, nil
+}
+
+// GetRevisionPageIDs returns the IDs of all pages that have revisions.
+func (r *SQLRevisionRepository) GetRevisionPageIDs(ctx context.Context) ([]int64, error) {
+	ids := []int64{}
+	if err := r.db.SelectContext(ctx, &ids, `SELECT DISTINCT page_id FROM revisions ORDER BY page_id`); err != nil {
+		return nil, fmt.Errorf("failed to get pages with revisions: %w", err)
+	}
+	return ids, nil
+}
+
+// DeleteRevisions deletes the revisions with the given IDs and returns how many were removed.
+func (r *SQLRevisionRepository) DeleteRevisions(ctx context.Context, ids []int64) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	query, args, err := sqlx.In(`DELETE FROM revisions WHERE id IN (?)`, ids)
+	if err != nil {
+		return 0, fmt.Errorf("failed to build revision delete: %w", err)
+	}
+	result, err := r.db.ExecContext(ctx, r.db.Rebind(query), args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete revisions: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// GetBySubject retrieves all revisions made by the subject, newest first. The
+// subject matches either the author or, for anonymous edits, the client address.
+func (r *SQLRevisionRepository) GetBySubject(ctx context.Context, subject string) ([]*Revision, error) {
+	revisions := []*Revision{}
+	query := `SELECT id, page_id, title, content, author_id, author_ip, minor, trusted_html, created_at FROM revisions WHERE author_id = ? OR author_ip = ? ORDER BY created_at DESC, id DESC`
+	if err := r.db.SelectContext(ctx, &revisions, r.db.Rebind(query), subject, subject); err != nil {
+		return nil, fmt.Errorf("failed to get revisions by subject: %w", err)
+	}
+	return revisions, nil
+}
+
+// GetRecentEdits returns up to limit revisions created since the given time,
+// newest first. Only the page, author and time of each revision are loaded.
+func (r *SQLRevisionRepository) GetRecentEdits(ctx context.Context, since time.Time, limit int) ([]*Revision, error) {
+	revisions := []*Revision{}
+	query := `SELECT id, page_id, author_id, created_at FROM revisions WHERE created_at >= ? ORDER BY created_at DESC, id DESC LIMIT ?`
+	if err := r.db.SelectContext(ctx, &revisions, r.db.Rebind(query), since.UTC(), limit); err != nil {
+		return nil, fmt.Errorf("failed to get recent edits: %w", err)
+	}
+	return revisions, nil
+}
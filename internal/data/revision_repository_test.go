@@ -0,0 +1,49 @@
+//go:build integration
+
+package data
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSQLRevisionRepository_UpdatePageWithRevision(t *testing.T) {
+	pages, db, teardown := setupPageTest(t)
+	defer teardown()
+	revisions := NewSQLRevisionRepository(db)
+	ctx := context.Background()
+
+	page := &Page{Title: "Runbook", Content: "v1", AuthorID: "alice"}
+	if err := pages.CreatePage(ctx, page); err != nil {
+		t.Fatalf("CreatePage failed: %v", err)
+	}
+
+	page.Content = "v2"
+	if err := revisions.UpdatePageWithRevision(ctx, page, &Revision{PageID: page.ID, Title: page.Title, Content: page.Content, AuthorID: "bob"}); err != nil {
+		t.Fatalf("UpdatePageWithRevision failed: %v", err)
+	}
+	saved, err := pages.GetPageByID(ctx, page.ID)
+	if err != nil || saved.Content != "v2" {
+		t.Fatalf("expected the page to be updated to v2, got %+v (err %v)", saved, err)
+	}
+	history, err := revisions.GetRevisions(ctx, page.ID)
+	if err != nil {
+		t.Fatalf("GetRevisions failed: %v", err)
+	}
+	if len(history) != 1 || history[0].Content != "v2" || history[0].AuthorID != "bob" {
+		t.Fatalf("expected one revision of v2 by bob, got %+v", history)
+	}
+	revision, err := revisions.GetRevision(ctx, history[0].ID)
+	if err != nil || revision.Content != "v2" {
+		t.Errorf("expected GetRevision to return the revision, got %+v (err %v)", revision, err)
+	}
+
+	// A failed update must not leave a revision behind.
+	missing := &Page{ID: page.ID + 100, Title: "Missing", Content: "orphan"}
+	if err := revisions.UpdatePageWithRevision(ctx, missing, &Revision{PageID: missing.ID, Title: missing.Title, Content: missing.Content, AuthorID: "bob"}); err == nil {
+		t.Fatal("expected updating a missing page to fail")
+	}
+	if orphans, _ := revisions.GetRevisions(ctx, missing.ID); len(orphans) != 0 {
+		t.Errorf("expected the revision to be rolled back, got %d", len(orphans))
+	}
+}
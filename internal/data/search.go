@@ -0,0 +1,57 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"unicode"
+
+	"github.com/jmoiron/sqlx"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// searchFolder strips accents by decomposing text and dropping the combining marks.
+var searchFolder = transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+
+// normalizeForSearch folds case and strips accents, so "Café" and "CAFE" both
+// become "cafe". It is applied both to the stored search columns and to search
+// terms, which makes matching independent of the database's collation.
+func normalizeForSearch(s string) string {
+	folded, _, err := transform.String(searchFolder, cases.Fold().String(s))
+	if err != nil {
+		return cases.Fold().String(s)
+	}
+	return folded
+}
+
+// pageSearchText is the normalized text stored in pages.search_text.
+func pageSearchText(page *Page) string {
+	return normalizeForSearch(page.Title + "\n" + page.Content)
+}
+
+// BackfillSearchColumns fills the normalized search columns of pages and
+// categories that were saved before the columns existed.
+func BackfillSearchColumns(ctx context.Context, db *sqlx.DB) error {
+	var pages []*Page
+	if err := db.SelectContext(ctx, &pages, `SELECT id, title, content FROM pages WHERE search_text IS NULL`); err != nil {
+		return fmt.Errorf("failed to find pages to index: %w", err)
+	}
+	for _, page := range pages {
+		if _, err := db.ExecContext(ctx, db.Rebind(`UPDATE pages SET search_text = ? WHERE id = ?`), pageSearchText(page), page.ID); err != nil {
+			return fmt.Errorf("failed to index page %d: %w", page.ID, err)
+		}
+	}
+
+	var categories []*Category
+	if err := db.SelectContext(ctx, &categories, `SELECT id, name, parent_id FROM categories WHERE search_name IS NULL`); err != nil {
+		return fmt.Errorf("failed to find categories to index: %w", err)
+	}
+	for _, category := range categories {
+		if _, err := db.ExecContext(ctx, db.Rebind(`UPDATE categories SET search_name = ? WHERE id = ?`), normalizeForSearch(category.Name), category.ID); err != nil {
+			return fmt.Errorf("failed to index category %d: %w", category.ID, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// SQLUserRepository stores the profiles of users who have logged in.
+type SQLUserRepository struct {
+	db *sqlx.DB
+}
+
+// NewSQLUserRepository creates a new SQLUserRepository.
+func NewSQLUserRepository(db *sqlx.DB) *SQLUserRepository {
+	return &SQLUserRepository{db: db}
+}
+
+// Upsert stores the user's profile, replacing the one stored for the same subject.
+func (r *SQLUserRepository) Upsert(ctx context.Context, user *User) error {
+	if user.UpdatedAt.IsZero() {
+		user.UpdatedAt = time.Now().UTC()
+	}
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin user transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, tx.Rebind(`DELETE FROM users WHERE subject = ?`), user.Subject); err != nil {
+		return fmt.Errorf("failed to replace user: %w", err)
+	}
+	query := `INSERT INTO users (subject, display_name, email, picture, updated_at) VALUES (:subject, :display_name, :email, :picture, :updated_at)`
+	if _, err := tx.NamedExecContext(ctx, query, user); err != nil {
+		return fmt.Errorf("failed to store user: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit user: %w", err)
+	}
+	return nil
+}
+
+// GetBySubject retrieves a user's profile. The returned error wraps sql.ErrNoRows
+// if the user has never logged in.
+func (r *SQLUserRepository) GetBySubject(ctx context.Context, subject string) (*User, error) {
+	var user User
+	query := `SELECT subject, display_name, email, picture, updated_at FROM users WHERE subject = ?`
+	if err := r.db.GetContext(ctx, &user, r.db.Rebind(query), subject); err != nil {
+		return nil, fmt.Errorf("failed to get user %s: %w", subject, err)
+	}
+	return &user, nil
+}
+
+// GetSessionEpoch returns the current session epoch. Login sessions created in
+// an earlier epoch are no longer valid.
+func (r *SQLUserRepository) GetSessionEpoch(ctx context.Context) (int64, error) {
+	var epoch int64
+	if err := r.db.GetContext(ctx, &epoch, `SELECT epoch FROM session_epoch WHERE id = 1`); err != nil {
+		return 0, fmt.Errorf("failed to get session epoch: %w", err)
+	}
+	return epoch, nil
+}
+
+// BumpSessionEpoch starts a new session epoch, invalidating every existing
+// login session, and returns it.
+func (r *SQLUserRepository) BumpSessionEpoch(ctx context.Context) (int64, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin session epoch transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE session_epoch SET epoch = epoch + 1, updated_at = ? WHERE id = 1`
+	if _, err := tx.ExecContext(ctx, tx.Rebind(query), time.Now().UTC()); err != nil {
+		return 0, fmt.Errorf("failed to bump session epoch: %w", err)
+	}
+	var epoch int64
+	if err := tx.GetContext(ctx, &epoch, `SELECT epoch FROM session_epoch WHERE id = 1`); err != nil {
+		return 0, fmt.Errorf("failed to get session epoch: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit session epoch: %w", err)
+	}
+	return epoch, nil
+}
@@ -0,0 +1,69 @@
+//go:build integration
+
+package data
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSQLUserRepository_Upsert(t *testing.T) {
+	_, db, teardown := setupPageTest(t)
+	defer teardown()
+	db.MustExec(`CREATE TABLE users (
+		subject TEXT PRIMARY KEY,
+		display_name TEXT NOT NULL DEFAULT '',
+		email TEXT NOT NULL DEFAULT '',
+		picture TEXT NOT NULL DEFAULT '',
+		updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`)
+	repo := NewSQLUserRepository(db)
+	ctx := context.Background()
+
+	if err := repo.Upsert(ctx, &User{Subject: "alice", DisplayName: "Alice", Email: "alice@example.com"}); err != nil {
+		t.Fatalf("Upsert failed: %v", err)
+	}
+	if err := repo.Upsert(ctx, &User{Subject: "alice", DisplayName: "Alice L.", Email: "alice@example.org", Picture: "https://example.com/a.png"}); err != nil {
+		t.Fatalf("second Upsert failed: %v", err)
+	}
+
+	user, err := repo.GetBySubject(ctx, "alice")
+	if err != nil {
+		t.Fatalf("GetBySubject failed: %v", err)
+	}
+	if user.DisplayName != "Alice L." || user.Email != "alice@example.org" || user.Picture != "https://example.com/a.png" {
+		t.Errorf("expected the latest profile, got %+v", user)
+	}
+	if _, err := repo.GetBySubject(ctx, "bob"); err == nil {
+		t.Error("expected an error for a user who never logged in")
+	}
+}
+
+func TestSQLUserRepository_SessionEpoch(t *testing.T) {
+	_, db, teardown := setupPageTest(t)
+	defer teardown()
+	db.MustExec(`CREATE TABLE session_epoch (
+		id INTEGER PRIMARY KEY,
+		epoch INTEGER NOT NULL DEFAULT 0,
+		updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`)
+	db.MustExec(`INSERT INTO session_epoch (id, epoch) VALUES (1, 0)`)
+	repo := NewSQLUserRepository(db)
+	ctx := context.Background()
+
+	if epoch, err := repo.GetSessionEpoch(ctx); err != nil || epoch != 0 {
+		t.Fatalf("expected epoch 0 initially, got %d (%v)", epoch, err)
+	}
+	for want := int64(1); want <= 2; want++ {
+		epoch, err := repo.BumpSessionEpoch(ctx)
+		if err != nil {
+			t.Fatalf("BumpSessionEpoch failed: %v", err)
+		}
+		if epoch != want {
+			t.Errorf("expected epoch %d after a bump, got %d", want, epoch)
+		}
+	}
+	if epoch, err := repo.GetSessionEpoch(ctx); err != nil || epoch != 2 {
+		t.Errorf("expected epoch 2 to be stored, got %d (%v)", epoch, err)
+	}
+}
@@ -0,0 +1,174 @@
+// Package diff computes line-level differences between two texts.
+package diff
+
+import "strings"
+
+// Op is the kind of change a line represents.
+type Op int
+
+const (
+	// Equal lines appear in both texts.
+	Equal Op = iota
+	// Insert lines appear only in the new text.
+	Insert
+	// Delete lines appear only in the old text.
+	Delete
+)
+
+// String returns "equal", "insert" or "delete".
+func (o Op) String() string {
+	switch o {
+	case Insert:
+		return "insert"
+	case Delete:
+		return "delete"
+	default:
+		return "equal"
+	}
+}
+
+// maxEdits bounds the work spent looking for a minimal diff. Texts that differ
+// by more lines than this get a correct but coarser diff.
+const maxEdits = 2000
+
+// Line is a single line of a diff.
+type Line struct {
+	Op   Op
+	Text string
+}
+
+// Lines returns the line-level changes that turn a into b, in order.
+func Lines(a, b string) []Line {
+	return diffLines(splitLines(a), splitLines(b))
+}
+
+// HasChanges reports whether any line of the diff was inserted or deleted.
+func HasChanges(lines []Line) bool {
+	for _, l := range lines {
+		if l.Op != Equal {
+			return true
+		}
+	}
+	return false
+}
+
+// splitLines splits text into lines, treating \r\n and \n alike.
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+func diffLines(a, b []string) []Line {
+	// Common prefixes and suffixes are unchanged and need no search.
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	lines := make([]Line, 0, len(a)+len(b))
+	for _, text := range a[:prefix] {
+		lines = append(lines, Line{Op: Equal, Text: text})
+	}
+	lines = append(lines, myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, text := range a[len(a)-suffix:] {
+		lines = append(lines, Line{Op: Equal, Text: text})
+	}
+	return lines
+}
+
+// myers finds a shortest edit script with Myers' O(ND) algorithm. If more than
+// maxEdits edits are needed it gives up and replaces a with b wholesale.
+func myers(a, b []string) []Line {
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 {
+		return replaceAll(a, b)
+	}
+	limit := min(n+m, maxEdits)
+	off := limit + 1
+	v := make([]int, 2*limit+3)
+	// trace[d] holds v[k] for k in [-d, d] after d edits.
+	var trace [][]int
+
+	for d := 0; d <= limit; d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[off+k-1] < v[off+k+1]) {
+				x = v[off+k+1]
+			} else {
+				x = v[off+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[off+k] = x
+			if x >= n && y >= m {
+				trace = append(trace, append([]int(nil), v[off-d:off+d+1]...))
+				return backtrack(a, b, trace)
+			}
+		}
+		trace = append(trace, append([]int(nil), v[off-d:off+d+1]...))
+	}
+	return replaceAll(a, b)
+}
+
+// backtrack walks the trace from the end of both texts back to the start.
+func backtrack(a, b []string, trace [][]int) []Line {
+	var reversed []Line
+	x, y := len(a), len(b)
+	for d := len(trace) - 1; d > 0; d-- {
+		prev := trace[d-1]
+		at := func(k int) int { return prev[k+d-1] }
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			reversed = append(reversed, Line{Op: Equal, Text: a[x]})
+		}
+		if x == prevX {
+			y--
+			reversed = append(reversed, Line{Op: Insert, Text: b[y]})
+		} else {
+			x--
+			reversed = append(reversed, Line{Op: Delete, Text: a[x]})
+		}
+	}
+	for x > 0 && y > 0 {
+		x--
+		y--
+		reversed = append(reversed, Line{Op: Equal, Text: a[x]})
+	}
+
+	lines := make([]Line, len(reversed))
+	for i, l := range reversed {
+		lines[len(reversed)-1-i] = l
+	}
+	return lines
+}
+
+func replaceAll(a, b []string) []Line {
+	lines := make([]Line, 0, len(a)+len(b))
+	for _, text := range a {
+		lines = append(lines, Line{Op: Delete, Text: text})
+	}
+	for _, text := range b {
+		lines = append(lines, Line{Op: Insert, Text: text})
+	}
+	return lines
+}
@@ -0,0 +1,91 @@
+package diff
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// render formats a diff as "+line", "-line" and " line" entries.
+func render(lines []Line) string {
+	var sb strings.Builder
+	for _, l := range lines {
+		switch l.Op {
+		case Insert:
+			sb.WriteString("+")
+		case Delete:
+			sb.WriteString("-")
+		default:
+			sb.WriteString(" ")
+		}
+		sb.WriteString(l.Text)
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// apply rebuilds both texts from a diff.
+func apply(lines []Line) (old, new []string) {
+	for _, l := range lines {
+		if l.Op != Insert {
+			old = append(old, l.Text)
+		}
+		if l.Op != Delete {
+			new = append(new, l.Text)
+		}
+	}
+	return old, new
+}
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{"identical", "one\ntwo\n", "one\ntwo", " one\n two\n"},
+		{"both empty", "", "", ""},
+		{"insertion", "one\nthree", "one\ntwo\nthree", " one\n+two\n three\n"},
+		{"deletion", "one\ntwo\nthree", "one\nthree", " one\n-two\n three\n"},
+		{"replacement", "one\ntwo\nthree", "one\n2\nthree", " one\n-two\n+2\n three\n"},
+		{"from empty", "", "new", "+new\n"},
+		{"to empty", "old", "", "-old\n"},
+		{"crlf", "a\r\nb\r\n", "a\nb\n", " a\n b\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := render(Lines(tt.a, tt.b)); got != tt.want {
+				t.Errorf("Lines(%q, %q) =\n%s\nwant\n%s", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLines_ReconstructsBothTexts(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	words := []string{"a", "b", "c", "d"}
+	randomText := func() []string {
+		lines := make([]string, rng.Intn(30))
+		for i := range lines {
+			lines[i] = words[rng.Intn(len(words))]
+		}
+		return lines
+	}
+	for i := 0; i < 200; i++ {
+		a, b := randomText(), randomText()
+		old, new := apply(Lines(strings.Join(a, "\n"), strings.Join(b, "\n")))
+		if fmt.Sprint(old) != fmt.Sprint(a) || fmt.Sprint(new) != fmt.Sprint(b) {
+			t.Fatalf("diff of %q and %q does not reconstruct them: got %q and %q", a, b, old, new)
+		}
+	}
+}
+
+func TestHasChanges(t *testing.T) {
+	if HasChanges(Lines("same", "same")) {
+		t.Error("expected no changes for identical text")
+	}
+	if !HasChanges(Lines("old", "new")) {
+		t.Error("expected changes for different text")
+	}
+}
@@ -0,0 +1,96 @@
+package events
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrTooManySubscribers is returned when a topic already has the maximum
+// number of subscribers allowed by the broker.
+var ErrTooManySubscribers = errors.New("too many subscribers for topic")
+
+// subscriberBuffer is the number of events buffered per subscriber. Events are
+// dropped for subscribers that fall further behind than this.
+const subscriberBuffer = 8
+
+// Event describes a change notification delivered to subscribers.
+type Event struct {
+	Type      string    `json:"type"`
+	Title     string    `json:"title"`
+	Author    string    `json:"author,omitempty"`
+	Minor     bool      `json:"minor,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Broker is a simple in-process publish/subscribe hub keyed by topic.
+// It is safe for concurrent use.
+type Broker struct {
+	mu          sync.Mutex
+	maxPerTopic int
+	topics      map[string]map[chan Event]struct{}
+}
+
+// NewBroker creates a new Broker. maxPerTopic limits the number of concurrent
+// subscribers for a single topic; a value <= 0 means unlimited.
+func NewBroker(maxPerTopic int) *Broker {
+	return &Broker{
+		maxPerTopic: maxPerTopic,
+		topics:      make(map[string]map[chan Event]struct{}),
+	}
+}
+
+// Subscribe registers a new subscriber for the given topic. It returns a channel
+// on which events are delivered and a function that must be called to release
+// the subscription. The channel is closed once the subscription is released.
+func (b *Broker) Subscribe(topic string) (<-chan Event, func(), error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	subs := b.topics[topic]
+	if b.maxPerTopic > 0 && len(subs) >= b.maxPerTopic {
+		return nil, nil, ErrTooManySubscribers
+	}
+	if subs == nil {
+		subs = make(map[chan Event]struct{})
+		b.topics[topic] = subs
+	}
+	ch := make(chan Event, subscriberBuffer)
+	subs[ch] = struct{}{}
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			if subs, ok := b.topics[topic]; ok {
+				delete(subs, ch)
+				if len(subs) == 0 {
+					delete(b.topics, topic)
+				}
+			}
+			close(ch)
+		})
+	}
+	return ch, unsubscribe, nil
+}
+
+// Publish delivers an event to every subscriber of the topic. Delivery never
+// blocks: subscribers whose buffers are full miss the event.
+func (b *Broker) Publish(topic string, ev Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.topics[topic] {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+// SubscriberCount returns the number of active subscribers for a topic.
+func (b *Broker) SubscriberCount(topic string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.topics[topic])
+}
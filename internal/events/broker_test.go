@@ -0,0 +1,50 @@
+//go:build unit
+
+package events
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestBroker_SubscriberLimit(t *testing.T) {
+	b := NewBroker(1)
+
+	_, unsubscribe, err := b.Subscribe("Page")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, _, err := b.Subscribe("Page"); !errors.Is(err, ErrTooManySubscribers) {
+		t.Errorf("expected ErrTooManySubscribers, got %v", err)
+	}
+
+	// Other topics are limited independently.
+	if _, _, err := b.Subscribe("Other"); err != nil {
+		t.Errorf("unexpected error for a different topic: %v", err)
+	}
+
+	// Releasing the subscription frees the slot.
+	unsubscribe()
+	if b.SubscriberCount("Page") != 0 {
+		t.Errorf("expected 0 subscribers after unsubscribe, got %d", b.SubscriberCount("Page"))
+	}
+	if _, _, err := b.Subscribe("Page"); err != nil {
+		t.Errorf("expected subscribe to succeed after unsubscribe, got %v", err)
+	}
+}
+
+func TestBroker_UnsubscribeClosesChannel(t *testing.T) {
+	b := NewBroker(0)
+	ch, unsubscribe, err := b.Subscribe("Page")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	unsubscribe()
+	unsubscribe() // Calling twice must be safe.
+
+	if _, ok := <-ch; ok {
+		t.Error("expected channel to be closed after unsubscribe")
+	}
+	// Publishing after all subscribers left must not panic.
+	b.Publish("Page", Event{Type: "updated"})
+}
@@ -0,0 +1,84 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	epub "github.com/go-shiori/go-epub"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Chapter is a single page of an exported book.
+type Chapter struct {
+	Title string
+	// HTML is the chapter's rendered, sanitized body.
+	HTML string
+}
+
+// Book is a collection of chapters exported as one document.
+type Book struct {
+	Title    string
+	Language string
+	Chapters []Chapter
+}
+
+// EPUBWriter writes a book as an EPUB document.
+type EPUBWriter interface {
+	WriteEPUB(w io.Writer, book Book) error
+}
+
+// GoEPUB writes EPUB 3 documents with a table of contents listing every chapter.
+type GoEPUB struct{}
+
+// NewGoEPUB creates a new GoEPUB writer.
+func NewGoEPUB() *GoEPUB {
+	return &GoEPUB{}
+}
+
+// WriteEPUB assembles the chapters into XHTML sections and writes the EPUB.
+func (GoEPUB) WriteEPUB(w io.Writer, book Book) error {
+	e, err := epub.NewEpub(book.Title)
+	if err != nil {
+		return fmt.Errorf("failed to create epub: %w", err)
+	}
+	if book.Language != "" {
+		e.SetLang(book.Language)
+	}
+	for i, chapter := range book.Chapters {
+		body, err := toXHTML(chapter.HTML)
+		if err != nil {
+			return fmt.Errorf("failed to convert chapter %q: %w", chapter.Title, err)
+		}
+		body = "<h1>" + html.EscapeString(chapter.Title) + "</h1>\n" + body
+		filename := fmt.Sprintf("chapter%04d.xhtml", i+1)
+		if _, err := e.AddSection(body, chapter.Title, filename, ""); err != nil {
+			return fmt.Errorf("failed to add chapter %q: %w", chapter.Title, err)
+		}
+	}
+	if _, err := e.WriteTo(w); err != nil {
+		return fmt.Errorf("failed to write epub: %w", err)
+	}
+	return nil
+}
+
+// toXHTML re-serializes an HTML fragment so it is well-formed XHTML, as
+// EPUB readers require: void elements are self-closed and entities resolved.
+func toXHTML(fragment string) (string, error) {
+	nodes, err := html.ParseFragment(strings.NewReader(fragment), &html.Node{
+		Type:     html.ElementNode,
+		Data:     "body",
+		DataAtom: atom.Body,
+	})
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for _, n := range nodes {
+		if err := html.Render(&b, n); err != nil {
+			return "", err
+		}
+	}
+	return b.String(), nil
+}
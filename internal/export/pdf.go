@@ -0,0 +1,42 @@
+// Package export converts wiki content into downloadable document formats.
+package export
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+)
+
+// PDFConverter turns a standalone HTML document into a PDF.
+type PDFConverter interface {
+	ConvertHTML(ctx context.Context, html []byte) ([]byte, error)
+}
+
+// Wkhtmltopdf converts HTML to PDF by running the wkhtmltopdf binary.
+// The binary is only needed when PDF export is enabled.
+type Wkhtmltopdf struct {
+	path string
+}
+
+// NewWkhtmltopdf creates a converter that runs the binary at the given path,
+// or "wkhtmltopdf" from $PATH when the path is empty.
+func NewWkhtmltopdf(path string) *Wkhtmltopdf {
+	if path == "" {
+		path = "wkhtmltopdf"
+	}
+	return &Wkhtmltopdf{path: path}
+}
+
+// ConvertHTML pipes the document through wkhtmltopdf and returns the PDF it writes.
+func (c *Wkhtmltopdf) ConvertHTML(ctx context.Context, html []byte) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, c.path, "--quiet", "--encoding", "utf-8", "-", "-")
+	cmd.Stdin = bytes.NewReader(html)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("wkhtmltopdf failed: %w: %s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return stdout.Bytes(), nil
+}
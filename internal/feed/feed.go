@@ -0,0 +1,85 @@
+// Package feed generates Atom feeds (RFC 4287).
+package feed
+
+import (
+	"encoding/xml"
+	"io"
+	"time"
+)
+
+// ContentType is the media type feeds are served with.
+const ContentType = "application/atom+xml; charset=utf-8"
+
+// LinkType is the type of links pointing at a feed, as used in
+// <link rel="alternate"> discovery tags.
+const LinkType = "application/atom+xml"
+
+const namespace = "http://www.w3.org/2005/Atom"
+
+// Link points at a related resource, such as the feed itself ("self") or the
+// page it describes ("alternate").
+type Link struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+// Person is the author of an entry.
+type Person struct {
+	Name string `xml:"name"`
+}
+
+// Entry is a single item of a feed.
+type Entry struct {
+	ID      string `xml:"id"`
+	Title   string `xml:"title"`
+	Updated string `xml:"updated"`
+	Author  Person `xml:"author"`
+	Links   []Link `xml:"link"`
+	Summary string `xml:"summary"`
+}
+
+// Feed is an Atom feed document.
+type Feed struct {
+	XMLName xml.Name `xml:"feed"`
+	Xmlns   string   `xml:"xmlns,attr"`
+	ID      string   `xml:"id"`
+	Title   string   `xml:"title"`
+	Updated string   `xml:"updated"`
+	Links   []Link   `xml:"link"`
+	Entries []Entry  `xml:"entry"`
+}
+
+// New creates an empty feed whose ID is its own URL, with a "self" link to
+// that URL and an "alternate" link to the HTML page it follows.
+func New(selfURL, title, htmlURL string, updated time.Time) *Feed {
+	return &Feed{
+		Xmlns:   namespace,
+		ID:      selfURL,
+		Title:   title,
+		Updated: Timestamp(updated),
+		Links: []Link{
+			{Href: selfURL, Rel: "self", Type: LinkType},
+			{Href: htmlURL, Rel: "alternate", Type: "text/html"},
+		},
+		Entries: []Entry{},
+	}
+}
+
+// Timestamp formats t as an Atom date, in UTC.
+func Timestamp(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// Write encodes the feed as an indented XML document.
+func (f *Feed) Write(w io.Writer) error {
+	out, err := xml.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	_, err = w.Write(out)
+	return err
+}
@@ -0,0 +1,47 @@
+//go:build unit
+
+package feed
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFeed_Write(t *testing.T) {
+	updated := time.Date(2024, 5, 1, 12, 0, 0, 0, time.FixedZone("CEST", 2*60*60))
+	f := New("https://wiki.example.com/view/Home/feed.xml", "History of Home", "https://wiki.example.com/view/Home", updated)
+	f.Entries = append(f.Entries, Entry{ID: "https://wiki.example.com/view/Home#revision-1", Title: "Revision 1 of Home", Updated: Timestamp(updated)})
+
+	var buf bytes.Buffer
+	if err := f.Write(&buf); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if !strings.HasPrefix(buf.String(), xml.Header) {
+		t.Errorf("expected an XML declaration, got %q", buf.String())
+	}
+
+	var parsed struct {
+		XMLName xml.Name
+		Updated string  `xml:"updated"`
+		Links   []Link  `xml:"link"`
+		Entries []Entry `xml:"entry"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &parsed); err != nil {
+		t.Fatalf("failed to parse feed: %v", err)
+	}
+	if parsed.XMLName.Space != namespace || parsed.XMLName.Local != "feed" {
+		t.Errorf("expected an Atom feed element, got %v", parsed.XMLName)
+	}
+	if parsed.Updated != "2024-05-01T10:00:00Z" {
+		t.Errorf("expected the updated date in UTC, got %q", parsed.Updated)
+	}
+	if len(parsed.Links) != 2 || parsed.Links[0].Rel != "self" || parsed.Links[0].Type != LinkType {
+		t.Errorf("expected a self link to the feed, got %+v", parsed.Links)
+	}
+	if len(parsed.Entries) != 1 {
+		t.Errorf("expected one entry, got %d", len(parsed.Entries))
+	}
+}
@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"go-wiki-app/internal/data"
+	"go-wiki-app/internal/middleware"
+	"html/template"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// changesPerPage is the number of activity entries shown per page on /changes.
+	changesPerPage = 50
+	// changesDateFormat is the format accepted by the date filters on /changes.
+	changesDateFormat = "2006-01-02"
+)
+
+// changesHandler displays the wiki-wide list of recent changes.
+// It supports filtering by author (?author=) and date range (?from=&to=, inclusive),
+// and is paginated with ?page=.
+func (h *PageHandler) changesHandler(w http.ResponseWriter, r *http.Request) *middleware.AppError {
+	query := r.URL.Query()
+	filter := data.ActivityFilter{AuthorID: query.Get("author")}
+
+	if from := query.Get("from"); from != "" {
+		since, err := time.Parse(changesDateFormat, from)
+		if err != nil {
+			return &middleware.AppError{Error: err, Message: "Invalid 'from' date, expected YYYY-MM-DD", Code: http.StatusBadRequest}
+		}
+		filter.Since = since
+	}
+	if to := query.Get("to"); to != "" {
+		until, err := time.Parse(changesDateFormat, to)
+		if err != nil {
+			return &middleware.AppError{Error: err, Message: "Invalid 'to' date, expected YYYY-MM-DD", Code: http.StatusBadRequest}
+		}
+		// The "to" date is inclusive, so include the whole day.
+		filter.Until = until.AddDate(0, 0, 1)
+	}
+
+	pageNum, appErr := pageNumber(r)
+	if appErr != nil {
+		return appErr
+	}
+
+	// Fetch one extra entry to find out whether there is a next page.
+	offset := (pageNum - 1) * changesPerPage
+	activity, err := h.pageService.GetRecentActivity(r.Context(), filter, changesPerPage+1, offset)
+	if err != nil {
+		return &middleware.AppError{Error: err, Message: "Failed to retrieve recent changes", Code: http.StatusInternalServerError}
+	}
+	hasNext := len(activity) > changesPerPage
+	if hasNext {
+		activity = activity[:changesPerPage]
+	}
+
+	// Hide activity on pages the current user is not allowed to see, including
+	// pages restricted to a role they lack.
+	userInfo := middleware.GetUserInfo(r.Context())
+	visible := make([]*data.Activity, 0, len(activity))
+	for _, a := range activity {
+		if !h.canView(r, a.PageTitle) {
+			continue
+		}
+		if a.PageID != nil && !h.pageService.CanView(r.Context(), &data.Page{ID: *a.PageID, Title: a.PageTitle}, userInfo) {
+			continue
+		}
+		visible = append(visible, a)
+	}
+
+	templateData := h.newTemplateData(r)
+	templateData["Activity"] = visible
+	templateData["Author"] = query.Get("author")
+	templateData["From"] = query.Get("from")
+	templateData["To"] = query.Get("to")
+	if pageNum > 1 {
+		templateData["PrevURL"] = changesPageURL(r, pageNum-1)
+	}
+	if hasNext {
+		templateData["NextURL"] = changesPageURL(r, pageNum+1)
+	}
+	if err := h.view.Render(w, r, "pages/changes.html", templateData); err != nil {
+		return &middleware.AppError{Error: err, Message: "Failed to render recent changes", Code: http.StatusInternalServerError}
+	}
+	return nil
+}
+
+// changesPageURL returns the /changes URL for the current filters with the page number replaced.
+func changesPageURL(r *http.Request, page int) template.URL {
+	q := r.URL.Query()
+	q.Set("page", strconv.Itoa(page))
+	return template.URL("/changes?" + q.Encode())
+}
@@ -0,0 +1,359 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-wiki-app/internal/data"
+	"go-wiki-app/internal/logger"
+	"go-wiki-app/internal/middleware"
+	"go-wiki-app/internal/service"
+	"go-wiki-app/internal/view"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// dashboardActivityLimit is the number of recent changes listed on the dashboard.
+const dashboardActivityLimit = 10
+
+// dashboardHealthTimeout bounds the database health check.
+const dashboardHealthTimeout = 2 * time.Second
+
+// AdminHandler holds dependencies for the administration pages.
+type AdminHandler struct {
+	dashboard service.AdminServicer
+	epochs    SessionEpochs
+	review    ReviewQueue
+	view      *view.View
+	log       logger.Logger
+
+	// started, version, commit and databaseDriver are reported on /status.
+	started        time.Time
+	version        string
+	commit         string
+	databaseDriver string
+}
+
+// NewAdminHandler creates a new AdminHandler.
+func NewAdminHandler(ds service.AdminServicer, v *view.View, log logger.Logger, opts ...AdminOption) *AdminHandler {
+	h := &AdminHandler{dashboard: ds, view: v, log: log, started: time.Now()}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// logFor returns the logger for the request in ctx, tagged with its ID by
+// middleware.RequestLogger, or the handler's logger outside of a request.
+func (h *AdminHandler) logFor(ctx context.Context) logger.Logger {
+	if log := logger.FromContext(ctx); log != nil {
+		return log
+	}
+	return h.log
+}
+
+// dashboardHandler renders the admin dashboard. A widget whose data cannot be
+// loaded is shown as unavailable rather than failing the whole page.
+func (h *AdminHandler) dashboardHandler(w http.ResponseWriter, r *http.Request) *middleware.AppError {
+	ctx := r.Context()
+	unavailable := make(map[string]bool)
+	templateData := map[string]interface{}{
+		"UserInfo":    middleware.GetUserInfo(ctx),
+		"IsBasicMode": middleware.IsBasicMode(ctx),
+		"Unavailable": unavailable,
+	}
+	if pruned := r.URL.Query().Get("pruned"); pruned != "" {
+		templateData["Pruned"] = pruned
+	}
+	templateData["CanLockdown"] = h.epochs != nil
+	templateData["CanReview"] = h.review != nil
+
+	if stats, err := h.dashboard.PageStats(ctx); err != nil {
+		h.log.Error(err, "Dashboard: failed to load page stats")
+		unavailable["pages"] = true
+	} else {
+		templateData["PageStats"] = stats
+	}
+	if n, err := h.dashboard.CategoryCount(ctx); err != nil {
+		h.log.Error(err, "Dashboard: failed to count categories")
+		unavailable["categories"] = true
+	} else {
+		templateData["CategoryCount"] = n
+	}
+	if n, err := h.dashboard.AuthorCount(ctx); err != nil {
+		h.log.Error(err, "Dashboard: failed to count authors")
+		unavailable["users"] = true
+	} else {
+		templateData["AuthorCount"] = n
+	}
+	if activity, err := h.dashboard.GetRecentActivity(ctx, data.ActivityFilter{}, dashboardActivityLimit, 0); err != nil {
+		h.log.Error(err, "Dashboard: failed to load recent activity")
+		unavailable["activity"] = true
+	} else {
+		templateData["Activity"] = activity
+	}
+	if tree, err := h.dashboard.GetCategoryTree(ctx); err != nil {
+		h.log.Error(err, "Dashboard: failed to load categories")
+		unavailable["categories"] = true
+	} else {
+		templateData["CategoryTree"] = tree
+	}
+	cacheStats := h.dashboard.CacheStats()
+	templateData["Cache"] = cacheStats
+	templateData["CacheHitRate"] = fmt.Sprintf("%.1f%%", cacheStats.HitRate()*100)
+
+	healthCtx, cancel := context.WithTimeout(ctx, dashboardHealthTimeout)
+	defer cancel()
+	if err := h.dashboard.DatabaseHealth(healthCtx); err != nil {
+		h.log.Error(err, "Dashboard: database health check failed")
+		templateData["DatabaseError"] = err.Error()
+	}
+
+	if err := h.view.Render(w, r, "pages/admin.html", templateData); err != nil {
+		return &middleware.AppError{Error: err, Message: "Failed to render dashboard", Code: http.StatusInternalServerError}
+	}
+	return nil
+}
+
+// pruneRevisionsHandler applies the revision retention policy on demand and
+// returns to the dashboard, which reports how many revisions were removed.
+func (h *AdminHandler) pruneRevisionsHandler(w http.ResponseWriter, r *http.Request) *middleware.AppError {
+	n, err := h.dashboard.PruneRevisions(r.Context())
+	if err != nil {
+		return &middleware.AppError{Error: err, Message: "Failed to prune revisions", Code: http.StatusInternalServerError}
+	}
+	http.Redirect(w, r, "/admin?pruned="+strconv.Itoa(n), http.StatusSeeOther)
+	return nil
+}
+
+// resanitizeHandler re-sanitizes the raw HTML in every page's content with
+// the current sanitizer policy and reports how many pages were changed.
+func (h *AdminHandler) resanitizeHandler(w http.ResponseWriter, r *http.Request) *middleware.AppError {
+	changed, err := h.dashboard.ResanitizeAll(r.Context())
+	if err != nil {
+		return &middleware.AppError{Error: err, Message: "Failed to re-sanitize pages", Code: http.StatusInternalServerError}
+	}
+	h.log.Info(fmt.Sprintf("%s re-sanitized stored page content, changing %d pages", middleware.GetUserInfo(r.Context()).Subject, changed))
+	return writeJSON(w, http.StatusOK, map[string]int{"changed": changed})
+}
+
+// contributionsHandler lists every revision made by a user or anonymous IP address.
+func (h *AdminHandler) contributionsHandler(w http.ResponseWriter, r *http.Request) *middleware.AppError {
+	return h.renderContributions(w, r, nil)
+}
+
+// revertContributionsHandler reverts a user's edits across all pages and lists
+// what was done to each page.
+func (h *AdminHandler) revertContributionsHandler(w http.ResponseWriter, r *http.Request) *middleware.AppError {
+	subject := chi.URLParam(r, "subject")
+	results, err := h.dashboard.RevertContributionsBy(r.Context(), subject)
+	if err != nil {
+		return &middleware.AppError{Error: err, Message: "Failed to revert contributions", Code: http.StatusInternalServerError}
+	}
+	h.log.Warn(fmt.Sprintf("%s reverted the contributions of %s on %d pages",
+		middleware.GetUserInfo(r.Context()).Subject, subject, len(results)))
+	return h.renderContributions(w, r, results)
+}
+
+func (h *AdminHandler) renderContributions(w http.ResponseWriter, r *http.Request, results []*service.RevertResult) *middleware.AppError {
+	subject := chi.URLParam(r, "subject")
+	contributions, err := h.dashboard.GetContributions(r.Context(), subject)
+	if err != nil {
+		return &middleware.AppError{Error: err, Message: "Failed to load contributions", Code: http.StatusInternalServerError}
+	}
+	templateData := map[string]interface{}{
+		"UserInfo":      middleware.GetUserInfo(r.Context()),
+		"IsBasicMode":   middleware.IsBasicMode(r.Context()),
+		"Subject":       subject,
+		"Contributions": contributions,
+		"Results":       results,
+	}
+	if err := h.view.Render(w, r, "pages/contributions.html", templateData); err != nil {
+		return &middleware.AppError{Error: err, Message: "Failed to render contributions", Code: http.StatusInternalServerError}
+	}
+	return nil
+}
+
+// checkLinksHandler starts checking the wiki's external links in the background
+// and sends the admin to the report, which shows the check's progress.
+func (h *AdminHandler) checkLinksHandler(w http.ResponseWriter, r *http.Request) *middleware.AppError {
+	if h.dashboard.StartExternalLinkCheck() {
+		h.log.Info(fmt.Sprintf("%s started an external link check", middleware.GetUserInfo(r.Context()).Subject))
+	}
+	http.Redirect(w, r, "/admin/dead-external-links", http.StatusSeeOther)
+	return nil
+}
+
+// deadLinksHandler shows the dead external links found by the last link check.
+func (h *AdminHandler) deadLinksHandler(w http.ResponseWriter, r *http.Request) *middleware.AppError {
+	links, err := h.dashboard.GetDeadLinks(r.Context())
+	if err != nil {
+		return &middleware.AppError{Error: err, Message: "Failed to load the link report", Code: http.StatusInternalServerError}
+	}
+	templateData := map[string]interface{}{
+		"UserInfo":    middleware.GetUserInfo(r.Context()),
+		"IsBasicMode": middleware.IsBasicMode(r.Context()),
+		"Links":       links,
+		"Status":      h.dashboard.LinkCheckStatus(),
+	}
+	if err := h.view.Render(w, r, "pages/dead_links.html", templateData); err != nil {
+		return &middleware.AppError{Error: err, Message: "Failed to render the link report", Code: http.StatusInternalServerError}
+	}
+	return nil
+}
+
+// setPageOwnerHandler hands a page over to the subject in the "owner" form field.
+// An empty owner leaves the page without one.
+func (h *AdminHandler) setPageOwnerHandler(w http.ResponseWriter, r *http.Request) *middleware.AppError {
+	title := chi.URLParam(r, "title")
+	owner := strings.TrimSpace(r.FormValue("owner"))
+	if err := h.dashboard.SetPageOwner(r.Context(), title, owner); err != nil {
+		return &middleware.AppError{Error: err, Message: "Failed to change the page owner", Code: http.StatusInternalServerError}
+	}
+	h.log.Info(fmt.Sprintf("%s made %q the owner of %s", middleware.GetUserInfo(r.Context()).Subject, owner, title))
+	http.Redirect(w, r, "/view/"+url.PathEscape(title), http.StatusSeeOther)
+	return nil
+}
+
+// mergeCategoriesHandler merges the category in the "source" form field into
+// the one in the "target" field, moving its pages and subcategories.
+func (h *AdminHandler) mergeCategoriesHandler(w http.ResponseWriter, r *http.Request) *middleware.AppError {
+	sourceID, err := strconv.ParseInt(r.FormValue("source"), 10, 64)
+	if err != nil {
+		return &middleware.AppError{Error: err, Message: "Invalid source category", Code: http.StatusBadRequest}
+	}
+	targetID, err := strconv.ParseInt(r.FormValue("target"), 10, 64)
+	if err != nil {
+		return &middleware.AppError{Error: err, Message: "Invalid target category", Code: http.StatusBadRequest}
+	}
+	if err := h.dashboard.MergeCategories(r.Context(), sourceID, targetID); err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidCategoryMerge):
+			return &middleware.AppError{Error: err, Message: "These categories cannot be merged", Code: http.StatusBadRequest}
+		case errors.Is(err, service.ErrCategoryNotFound):
+			return &middleware.AppError{Error: err, Message: "Category not found", Code: http.StatusNotFound}
+		}
+		return &middleware.AppError{Error: err, Message: "Failed to merge the categories", Code: http.StatusInternalServerError}
+	}
+	h.log.Info(fmt.Sprintf("%s merged category %d into category %d", middleware.GetUserInfo(r.Context()).Subject, sourceID, targetID))
+	http.Redirect(w, r, "/categories", http.StatusSeeOther)
+	return nil
+}
+
+// categoryWeightPrefix prefixes the category order form fields, one per
+// category, e.g. "weight_12" for the category with ID 12.
+const categoryWeightPrefix = "weight_"
+
+// categoryOrderHandler sets the order weights of the categories in the
+// category order form.
+func (h *AdminHandler) categoryOrderHandler(w http.ResponseWriter, r *http.Request) *middleware.AppError {
+	if err := r.ParseForm(); err != nil {
+		return &middleware.AppError{Error: err, Message: "Invalid form", Code: http.StatusBadRequest}
+	}
+	weights := make(map[int64]int)
+	for field := range r.PostForm {
+		idText, ok := strings.CutPrefix(field, categoryWeightPrefix)
+		if !ok {
+			continue
+		}
+		id, err := strconv.ParseInt(idText, 10, 64)
+		if err != nil {
+			return &middleware.AppError{Error: err, Message: "Invalid category", Code: http.StatusBadRequest}
+		}
+		weight, err := strconv.Atoi(strings.TrimSpace(r.PostForm.Get(field)))
+		if err != nil {
+			return &middleware.AppError{Error: err, Message: "Order weights must be whole numbers", Code: http.StatusBadRequest}
+		}
+		weights[id] = weight
+	}
+	if err := h.dashboard.UpdateCategoryOrder(r.Context(), weights); err != nil {
+		return &middleware.AppError{Error: err, Message: "Failed to reorder the categories", Code: http.StatusInternalServerError}
+	}
+	http.Redirect(w, r, "/categories", http.StatusSeeOther)
+	return nil
+}
+
+// lockdownHandler ends every login session, including the administrator's own,
+// by starting a new session epoch. Everyone has to log in again afterwards.
+func (h *AdminHandler) lockdownHandler(w http.ResponseWriter, r *http.Request) *middleware.AppError {
+	if h.epochs == nil {
+		return &middleware.AppError{Error: fmt.Errorf("session lockdown is not configured"), Message: "Lockdown is not available", Code: http.StatusNotFound}
+	}
+	epoch, err := h.epochs.Lockdown(r.Context())
+	if err != nil {
+		return &middleware.AppError{Error: err, Message: "Failed to end sessions", Code: http.StatusInternalServerError}
+	}
+	h.log.Warn(fmt.Sprintf("%s started a lockdown, ending all sessions (session epoch %d)",
+		middleware.GetUserInfo(r.Context()).Subject, epoch))
+	http.Redirect(w, r, "/auth/login", http.StatusSeeOther)
+	return nil
+}
+
+// reviewQueueHandler lists the edits awaiting review, oldest first, each with
+// its changes to the current version of the page.
+func (h *AdminHandler) reviewQueueHandler(w http.ResponseWriter, r *http.Request) *middleware.AppError {
+	if h.review == nil {
+		return &middleware.AppError{Error: fmt.Errorf("the review queue is not configured"), Message: "Edits are not reviewed on this wiki", Code: http.StatusNotFound}
+	}
+	reviews, err := h.review.GetPendingEditReviews(r.Context())
+	if err != nil {
+		return &middleware.AppError{Error: err, Message: "Failed to load the review queue", Code: http.StatusInternalServerError}
+	}
+	templateData := map[string]interface{}{
+		"UserInfo":    middleware.GetUserInfo(r.Context()),
+		"IsBasicMode": middleware.IsBasicMode(r.Context()),
+		"Reviews":     reviews,
+	}
+	if err := h.view.Render(w, r, "pages/review.html", templateData); err != nil {
+		return &middleware.AppError{Error: err, Message: "Failed to render the review queue", Code: http.StatusInternalServerError}
+	}
+	return nil
+}
+
+// reviewHandler approves or rejects a queued edit, as chosen by the "action"
+// form field. A rejection may give a "reason", which is logged.
+func (h *AdminHandler) reviewHandler(w http.ResponseWriter, r *http.Request) *middleware.AppError {
+	if h.review == nil {
+		return &middleware.AppError{Error: fmt.Errorf("the review queue is not configured"), Message: "Edits are not reviewed on this wiki", Code: http.StatusNotFound}
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		return &middleware.AppError{Error: err, Message: "Invalid edit", Code: http.StatusBadRequest}
+	}
+	moderator := middleware.GetUserInfo(r.Context()).Subject
+	switch action := r.FormValue("action"); action {
+	case "approve":
+		page, err := h.review.ApprovePendingEdit(r.Context(), id)
+		if err != nil {
+			return reviewError(err, "Failed to approve the edit")
+		}
+		h.log.Info(fmt.Sprintf("%s approved edit %d to %s", moderator, id, page.Title))
+	case "reject":
+		edit, err := h.review.RejectPendingEdit(r.Context(), id)
+		if err != nil {
+			return reviewError(err, "Failed to reject the edit")
+		}
+		message := fmt.Sprintf("%s rejected edit %d to %s by %s", moderator, id, edit.Title, edit.AuthorID)
+		if reason := strings.TrimSpace(r.FormValue("reason")); reason != "" {
+			message += ": " + reason
+		}
+		h.log.Info(message)
+	default:
+		return &middleware.AppError{Error: fmt.Errorf("unknown review action %q", action), Message: "Choose to approve or reject the edit", Code: http.StatusBadRequest}
+	}
+	http.Redirect(w, r, "/admin/review", http.StatusSeeOther)
+	return nil
+}
+
+// reviewError maps a failed review to a 404 for edits that are no longer queued.
+func reviewError(err error, message string) *middleware.AppError {
+	if errors.Is(err, service.ErrPendingEditNotFound) {
+		return &middleware.AppError{Error: err, Message: "This edit is no longer awaiting review", Code: http.StatusNotFound}
+	}
+	return &middleware.AppError{Error: err, Message: message, Code: http.StatusInternalServerError}
+}
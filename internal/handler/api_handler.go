@@ -0,0 +1,324 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-wiki-app/internal/data"
+	"go-wiki-app/internal/middleware"
+	"go-wiki-app/internal/service"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// maxAPIBodyBytes bounds the size of a JSON API request body.
+const maxAPIBodyBytes = 8 << 20
+
+// apiPageJSON is a page served by the JSON API: its raw markdown along with the
+// rendered HTML.
+type apiPageJSON struct {
+	pageJSON
+	HTML string `json:"html"`
+}
+
+// apiCreateRequest is the body of a POST /api/v1/pages request.
+type apiCreateRequest struct {
+	Title       string `json:"title"`
+	Content     string `json:"content"`
+	Category    string `json:"category"`
+	Subcategory string `json:"subcategory"`
+}
+
+// apiEntryRequest is the body of a POST /api/v1/entries request.
+type apiEntryRequest struct {
+	Collection string `json:"collection"`
+	Date       string `json:"date"` // YYYY-MM-DD
+	Body       string `json:"body"`
+}
+
+// apiUpdateRequest is the body of a PUT /api/v1/pages/{title} request. Fields
+// left out keep their current value.
+type apiUpdateRequest struct {
+	Title       *string `json:"title"`
+	Content     *string `json:"content"`
+	Category    *string `json:"category"`
+	Subcategory *string `json:"subcategory"`
+	Minor       bool    `json:"minor"`
+}
+
+// apiListPagesHandler serves the page list, walked with the same cursors as the
+// JSON representation of /list.
+func (h *PageHandler) apiListPagesHandler(w http.ResponseWriter, r *http.Request) *middleware.AppError {
+	if appErr := requireJSONAccept(r); appErr != nil {
+		return appErr
+	}
+	return h.listPagesJSON(w, r)
+}
+
+// apiGetPageHandler serves a single page with its markdown and rendered HTML.
+func (h *PageHandler) apiGetPageHandler(w http.ResponseWriter, r *http.Request) *middleware.AppError {
+	if appErr := requireJSONAccept(r); appErr != nil {
+		return appErr
+	}
+	page, appErr := h.apiVisiblePage(w, r, chi.URLParam(r, "title"))
+	if appErr != nil {
+		return appErr
+	}
+	etag := pageETag(page)
+	w.Header().Set("ETag", etag)
+	if etagMatches(r.Header.Get("If-None-Match"), etag, true) {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+	return writeJSON(w, http.StatusOK, newAPIPageJSON(page))
+}
+
+// apiCreatePageHandler creates a page and answers with 201 and its location.
+// A retry carrying the same Idempotency-Key gets the original response.
+func (h *PageHandler) apiCreatePageHandler(w http.ResponseWriter, r *http.Request) *middleware.AppError {
+	if appErr := requireJSONAccept(r); appErr != nil {
+		return appErr
+	}
+	return h.idempotent(w, r, h.createPageFromAPI)
+}
+
+func (h *PageHandler) createPageFromAPI(w http.ResponseWriter, r *http.Request) *middleware.AppError {
+	var req apiCreateRequest
+	if appErr := decodeJSONBody(w, r, &req); appErr != nil {
+		return appErr
+	}
+	req.Title = strings.TrimSpace(req.Title)
+	if req.Title == "" {
+		return &middleware.AppError{Error: errors.New("missing title"), Message: "A title is required", Code: http.StatusBadRequest}
+	}
+	if req.Title == "Home" {
+		return &middleware.AppError{Error: errors.New("home page is not editable"), Message: "The Home page cannot be edited.", Code: http.StatusForbidden}
+	}
+	if _, err := h.pageService.GetPage(r.Context(), req.Title); err == nil {
+		return &middleware.AppError{Error: fmt.Errorf("page %q already exists", req.Title), Message: "A page with this title already exists", Code: http.StatusConflict}
+	} else if !errors.Is(err, service.ErrPageNotFound) {
+		return &middleware.AppError{Error: err, Message: "Failed to load the page", Code: http.StatusInternalServerError}
+	}
+
+	authorID := middleware.GetUserInfo(r.Context()).Subject
+	created, err := h.pageService.CreatePage(r.Context(), req.Title, req.Content, authorID, req.Category, req.Subcategory)
+	if errors.Is(err, service.ErrPendingReview) {
+		// The page is only created once a moderator approves it.
+		return writeJSON(w, http.StatusAccepted, map[string]string{"status": "pending_review"})
+	}
+	if err != nil {
+		return apiSaveError(err, "Failed to create page")
+	}
+	page, err := h.pageService.ViewPage(r.Context(), created.Title)
+	if appErr := renderAborted(w, err); appErr != nil {
+		return appErr
+	}
+	if err != nil {
+		return &middleware.AppError{Error: err, Message: "Failed to load the created page", Code: http.StatusInternalServerError}
+	}
+	w.Header().Set("Location", "/api/v1/pages/"+url.PathEscape(page.Title))
+	w.Header().Set("ETag", pageETag(page))
+	return writeJSON(w, http.StatusCreated, newAPIPageJSON(page))
+}
+
+// apiAppendEntryHandler files a log entry on the page for its collection and
+// day, answering 201 when the entry starts the page and 200 when it is
+// appended to it. Like page creation, it honours Idempotency-Key so a retried
+// entry is not appended twice.
+func (h *PageHandler) apiAppendEntryHandler(w http.ResponseWriter, r *http.Request) *middleware.AppError {
+	if appErr := requireJSONAccept(r); appErr != nil {
+		return appErr
+	}
+	return h.idempotent(w, r, h.appendEntryFromAPI)
+}
+
+func (h *PageHandler) appendEntryFromAPI(w http.ResponseWriter, r *http.Request) *middleware.AppError {
+	var req apiEntryRequest
+	if appErr := decodeJSONBody(w, r, &req); appErr != nil {
+		return appErr
+	}
+	date, err := time.Parse("2006-01-02", strings.TrimSpace(req.Date))
+	if err != nil {
+		return &middleware.AppError{Error: err, Message: "The date must be given as YYYY-MM-DD", Code: http.StatusBadRequest}
+	}
+
+	// Entries are not added to a page the user cannot see; the response
+	// would show them its content.
+	existing, err := h.pageService.GetPage(r.Context(), service.EntryTitle(req.Collection, date))
+	switch {
+	case errors.Is(err, service.ErrPageNotFound):
+	case err != nil:
+		return &middleware.AppError{Error: err, Message: "Failed to load the page of the entry", Code: http.StatusInternalServerError}
+	case !h.canSee(r, existing):
+		return &middleware.AppError{Error: fmt.Errorf("page %q is hidden from the user", existing.Title), Message: "Page not found", Code: http.StatusNotFound}
+	}
+
+	authorID := middleware.GetUserInfo(r.Context()).Subject
+	saved, created, err := h.pageService.AppendEntry(r.Context(), req.Collection, date, req.Body, authorID)
+	switch {
+	case errors.Is(err, service.ErrInvalidEntry):
+		return &middleware.AppError{Error: err, Message: "An entry needs a body and a collection without slashes", Code: http.StatusBadRequest}
+	case errors.Is(err, service.ErrPendingReview):
+		// The entry is appended once a moderator approves it.
+		return writeJSON(w, http.StatusAccepted, map[string]string{"status": "pending_review"})
+	case err != nil:
+		return apiSaveError(err, "Failed to save the entry")
+	}
+	// The response is built from the saved page rather than a fresh render,
+	// which could fail after the entry was kept and make a retry append it
+	// again.
+	w.Header().Set("ETag", pageETag(saved))
+	if !created {
+		return writeJSON(w, http.StatusOK, newPageJSON(saved))
+	}
+	w.Header().Set("Location", "/api/v1/pages/"+url.PathEscape(saved.Title))
+	return writeJSON(w, http.StatusCreated, newPageJSON(saved))
+}
+
+// apiUpdatePageHandler saves a new version of a page. Clients may send the
+// page's ETag in If-Match so the update fails with 412 instead of overwriting
+// a change made since they read it.
+func (h *PageHandler) apiUpdatePageHandler(w http.ResponseWriter, r *http.Request) *middleware.AppError {
+	if appErr := requireJSONAccept(r); appErr != nil {
+		return appErr
+	}
+	title := chi.URLParam(r, "title")
+	if title == "Home" {
+		return &middleware.AppError{Error: errors.New("home page is not editable"), Message: "The Home page cannot be edited.", Code: http.StatusForbidden}
+	}
+	var req apiUpdateRequest
+	if appErr := decodeJSONBody(w, r, &req); appErr != nil {
+		return appErr
+	}
+	page, appErr := h.apiVisiblePage(w, r, title)
+	if appErr != nil {
+		return appErr
+	}
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" && !etagMatches(ifMatch, pageETag(page), false) {
+		return &middleware.AppError{
+			Error:   fmt.Errorf("page %q does not match If-Match %s", title, ifMatch),
+			Message: "This page was changed since you last read it. Reload it and try again.",
+			Code:    http.StatusPreconditionFailed,
+		}
+	}
+
+	newTitle, content, category, subcategory := page.Title, page.Content, page.CategoryName, page.SubcategoryName
+	if req.Title != nil {
+		newTitle = strings.TrimSpace(*req.Title)
+	}
+	if req.Content != nil {
+		content = *req.Content
+	}
+	if req.Category != nil {
+		category = *req.Category
+	}
+	if req.Subcategory != nil {
+		subcategory = *req.Subcategory
+	}
+	if newTitle == "" || newTitle == "Home" {
+		return &middleware.AppError{Error: fmt.Errorf("invalid new title %q", newTitle), Message: "Invalid title", Code: http.StatusBadRequest}
+	}
+
+	updated, err := h.pageService.UpdatePage(r.Context(), page.ID, newTitle, content, category, subcategory, req.Minor)
+	if errors.Is(err, service.ErrPendingReview) {
+		// The page is unchanged until a moderator approves the edit.
+		return writeJSON(w, http.StatusAccepted, map[string]string{"status": "pending_review"})
+	}
+	if err != nil {
+		return apiSaveError(err, "Failed to update page")
+	}
+	page, err = h.pageService.ViewPage(r.Context(), updated.Title)
+	if err != nil {
+		return &middleware.AppError{Error: err, Message: "Failed to load the updated page", Code: http.StatusInternalServerError}
+	}
+	w.Header().Set("ETag", pageETag(page))
+	return writeJSON(w, http.StatusOK, newAPIPageJSON(page))
+}
+
+// apiDeletePageHandler moves a page to the trash and answers with 204.
+func (h *PageHandler) apiDeletePageHandler(w http.ResponseWriter, r *http.Request) *middleware.AppError {
+	title := chi.URLParam(r, "title")
+	if title == "Home" {
+		return &middleware.AppError{Error: errors.New("home page cannot be deleted"), Message: "The Home page cannot be deleted.", Code: http.StatusForbidden}
+	}
+	page, appErr := h.apiVisiblePage(w, r, title)
+	if appErr != nil {
+		return appErr
+	}
+	if err := h.pageService.DeletePage(r.Context(), page.ID); err != nil {
+		return &middleware.AppError{Error: err, Message: "Failed to delete page", Code: http.StatusInternalServerError}
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// apiVisiblePage loads a page the current user may see, answering 404 for
+// pages that do not exist and for those the user may not see alike.
+func (h *PageHandler) apiVisiblePage(w http.ResponseWriter, r *http.Request, title string) (*data.Page, *middleware.AppError) {
+	page, err := h.pageService.ViewPage(r.Context(), title)
+	if appErr := renderAborted(w, err); appErr != nil {
+		return nil, appErr
+	}
+	if err != nil || page.ID == 0 {
+		if err == nil {
+			err = fmt.Errorf("page %q does not exist", title)
+		}
+		return nil, &middleware.AppError{Error: err, Message: "Page not found", Code: http.StatusNotFound}
+	}
+	if !h.canSee(r, page) {
+		return nil, &middleware.AppError{Error: fmt.Errorf("page %q is hidden from the user", title), Message: "Page not found", Code: http.StatusNotFound}
+	}
+	return page, nil
+}
+
+// apiSaveError maps the errors of creating or updating a page to API responses.
+func apiSaveError(err error, message string) *middleware.AppError {
+	switch {
+	case errors.Is(err, service.ErrWikiFull):
+		return &middleware.AppError{Error: err, Message: "This wiki has reached its page or storage limit. Please contact an administrator.", Code: http.StatusInsufficientStorage}
+	case errors.Is(err, service.ErrQuotaExceeded):
+		return &middleware.AppError{Error: err, Message: "You have created too many pages recently. Please try again later.", Code: http.StatusTooManyRequests}
+	case errors.Is(err, service.ErrPageInTrash):
+		return &middleware.AppError{Error: err, Message: pageInTrashMessage, Code: http.StatusConflict}
+	case errors.Is(err, service.ErrTitlePathTooDeep):
+		return &middleware.AppError{Error: err, Message: titlePathTooDeepMessage, Code: http.StatusBadRequest}
+	case errors.Is(err, service.ErrInvalidTitlePath):
+		return &middleware.AppError{Error: err, Message: invalidTitlePathMessage, Code: http.StatusBadRequest}
+	case errors.Is(err, service.ErrTitlePathTaken):
+		return &middleware.AppError{Error: err, Message: titlePathTakenMessage, Code: http.StatusConflict}
+	}
+	return &middleware.AppError{Error: err, Message: message, Code: http.StatusInternalServerError}
+}
+
+// newAPIPageJSON builds the API representation of a rendered page.
+func newAPIPageJSON(page *data.Page) apiPageJSON {
+	return apiPageJSON{pageJSON: newPageJSON(page), HTML: string(page.HTMLContent)}
+}
+
+// requireJSONAccept answers 406 to clients that do not accept JSON, the only
+// representation the API serves.
+func requireJSONAccept(r *http.Request) *middleware.AppError {
+	if acceptsJSON(r.Header.Get("Accept")) {
+		return nil
+	}
+	return &middleware.AppError{Error: fmt.Errorf("unacceptable Accept header %q", r.Header.Get("Accept")), Message: "This API only serves application/json", Code: http.StatusNotAcceptable}
+}
+
+// decodeJSONBody reads a JSON request body into dst, answering 415 for other
+// content types and 400 for malformed or oversized bodies.
+func decodeJSONBody(w http.ResponseWriter, r *http.Request, dst interface{}) *middleware.AppError {
+	if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mediaType != formatJSON {
+		return &middleware.AppError{Error: fmt.Errorf("unsupported content type %q", r.Header.Get("Content-Type")), Message: "Request bodies must be application/json", Code: http.StatusUnsupportedMediaType}
+	}
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAPIBodyBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(dst); err != nil {
+		return &middleware.AppError{Error: err, Message: "Invalid request body", Code: http.StatusBadRequest}
+	}
+	return nil
+}
@@ -1,30 +1,62 @@
 package handler
 
 import (
+	"context"
 	"crypto/rand"
+	"database/sql"
 	"encoding/base64"
+	"errors"
+	"fmt"
 	"go-wiki-app/internal/auth"
+	"go-wiki-app/internal/config"
+	"go-wiki-app/internal/data"
+	"go-wiki-app/internal/logger"
+	"go-wiki-app/internal/middleware"
 	"go-wiki-app/internal/session"
 	"io"
 	"net/http"
+	"slices"
+	"time"
 
 	"github.com/casbin/casbin/v2"
 )
 
+// UserRepository stores the profiles of users as they log in. GetUser returns
+// an error wrapping sql.ErrNoRows for users who have never logged in.
+type UserRepository interface {
+	GetUser(ctx context.Context, subject string) (*data.User, error)
+	Upsert(ctx context.Context, user *data.User) error
+}
+
 // AuthHandler holds the dependencies for the authentication handlers.
 type AuthHandler struct {
-	auth     *auth.Authenticator
-	session  session.Manager
-	enforcer casbin.IEnforcer
+	auth             *auth.Authenticator
+	session          session.Manager
+	enforcer         casbin.IEnforcer
+	users            UserRepository
+	defaultRole      string
+	epochs           SessionEpochs
+	log              logger.Logger
+	lifetime         time.Duration
+	rememberLifetime time.Duration
 }
 
-// NewAuthHandler creates a new AuthHandler.
-func NewAuthHandler(a *auth.Authenticator, sm session.Manager, e casbin.IEnforcer) *AuthHandler {
-	return &AuthHandler{
-		auth:     a,
-		session:  sm,
-		enforcer: e,
+// NewAuthHandler creates a new AuthHandler. Sessions last for the configured
+// lifetime, or the longer remember-me lifetime when the user asks to be remembered.
+// The profiles of users logging in are stored in users, if it is non-nil.
+func NewAuthHandler(a *auth.Authenticator, sm session.Manager, e casbin.IEnforcer, users UserRepository, cfg config.SessionConfig, opts ...AuthOption) *AuthHandler {
+	h := &AuthHandler{
+		auth:             a,
+		session:          sm,
+		enforcer:         e,
+		users:            users,
+		lifetime:         time.Duration(cfg.Lifetime) * time.Hour,
+		rememberLifetime: time.Duration(cfg.RememberLifetime) * time.Hour,
+	}
+	for _, opt := range opts {
+		opt(h)
 	}
+	return h
 }
 
 // handleLogin redirects the user to the OIDC provider to log in.
@@ -35,6 +67,9 @@ func (h *AuthHandler) handleLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	h.session.Put(r.Context(), "state", state)
+	if r.URL.Query().Get("remember") == "1" {
+		h.session.Put(r.Context(), "remember_me", "1")
+	}
 
 	http.Redirect(w, r, h.auth.AuthCodeURL(state), http.StatusFound)
 }
@@ -69,42 +104,99 @@ func (h *AuthHandler) handleCallback(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 4. Parse custom claims from the ID Token.
+	// 4. Read the user's identity from the configured claims of the ID Token.
 	// We expect the OIDC provider (e.g., Casdoor) to be configured to send these claims.
-	var claims struct {
-		DisplayName string `json:"displayName"`
-		Name        string `json:"name"`
-		Roles       []struct {
-			Name string `json:"name"`
-		} `json:"roles"`
-	}
-	if err := idToken.Claims(&claims); err != nil {
+	identity, err := h.auth.Identity(idToken)
+	if err != nil {
 		http.Error(w, "Failed to parse claims: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	// 5. Synchronize user roles with Casbin.
+	// 5-7. Record the user, synchronize their roles and establish their session.
+	if err := h.login(r.Context(), identity, rawIDToken); err != nil {
+		http.Error(w, "Failed to log in: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+// login completes the login of a user whose ID token has been verified.
+func (h *AuthHandler) login(ctx context.Context, identity *auth.Identity, rawIDToken string) error {
+	// 5. Store the user's profile, so their email and avatar can be shown and looked up.
+	// Without stored profiles, a user who holds no roles counts as new.
+	firstLogin := false
+	if h.users != nil {
+		if _, err := h.users.GetUser(ctx, identity.Subject); errors.Is(err, sql.ErrNoRows) {
+			firstLogin = true
+		} else if err != nil {
+			return err
+		}
+		user := &data.User{
+			Subject:     identity.Subject,
+			DisplayName: identity.DisplayName,
+			Email:       identity.Email,
+			Picture:     identity.Picture,
+		}
+		if err := h.users.Upsert(ctx, user); err != nil {
+			return err
+		}
+	}
+
+	// 6. Synchronize user roles with Casbin.
 	// This ensures that the user's permissions are always up-to-date with the OIDC provider.
 	// First, remove any existing roles for this user to handle role changes.
-	h.enforcer.DeleteRolesForUser(idToken.Subject)
+	previous, _ := h.enforcer.GetRolesForUser(identity.Subject)
+	if h.users == nil {
+		firstLogin = len(previous) == 0
+	}
+	h.enforcer.DeleteRolesForUser(identity.Subject)
 	// Then, grant the new roles from the token.
-	for _, role := range claims.Roles {
-		h.enforcer.AddRoleForUser(idToken.Subject, role.Name)
+	for _, role := range identity.Roles {
+		h.enforcer.AddRoleForUser(identity.Subject, role)
+	}
+	// Users the provider grants no roles get the configured default role, if any,
+	// on their first login. They keep it on later logins until an admin removes it.
+	if len(identity.Roles) == 0 && h.defaultRole != "" {
+		switch {
+		case firstLogin:
+			h.enforcer.AddRoleForUser(identity.Subject, h.defaultRole)
+			if h.log != nil {
+				h.log.Info(fmt.Sprintf("Granted default role %q to user %q", h.defaultRole, identity.Subject))
+			}
+		case slices.Contains(previous, h.defaultRole):
+			h.enforcer.AddRoleForUser(identity.Subject, h.defaultRole)
+		}
 	}
 
-	// 6. Establish the user's session.
-	// Determine the best display name to use, falling back from displayName to name.
-	var displayName string
-	if claims.DisplayName != "" {
-		displayName = claims.DisplayName
-	} else {
-		displayName = claims.Name
+	// 7. Establish the user's session.
+	if h.epochs != nil {
+		epoch, err := h.epochs.SessionEpoch(ctx)
+		if err != nil {
+			return err
+		}
+		h.session.Put(ctx, middleware.SessionEpochKey, epoch)
 	}
-	h.session.Put(r.Context(), "raw_id_token", rawIDToken)
-	h.session.Put(r.Context(), "user_subject", idToken.Subject)
-	h.session.Put(r.Context(), "user_display_name", displayName)
+	h.session.Put(ctx, "raw_id_token", rawIDToken)
+	h.session.Put(ctx, "user_subject", identity.Subject)
+	h.session.Put(ctx, "user_display_name", identity.DisplayName)
+	h.setSessionDeadline(ctx, h.session.PopString(ctx, "remember_me") == "1")
+	return nil
+}
 
-	http.Redirect(w, r, "/", http.StatusFound)
+// setSessionDeadline sets when the new login session expires. Remembered sessions
+// use the longer lifetime and a persistent cookie; others end with the browser
+// session or after the default lifetime, whichever comes first.
+func (h *AuthHandler) setSessionDeadline(ctx context.Context, remember bool) {
+	lifetime := h.lifetime
+	if remember && h.rememberLifetime > lifetime {
+		lifetime = h.rememberLifetime
+	}
+	h.session.RememberMe(ctx, remember)
+	h.session.Put(ctx, middleware.LastActivityKey, time.Now())
+	if lifetime > 0 {
+		h.session.Put(ctx, middleware.SessionDeadlineKey, time.Now().Add(lifetime))
+	}
 }
 
 // handleLogout destroys the user's session and redirects to the home page.
@@ -4,10 +4,19 @@ package handler
 
 import (
 	"context"
+	"database/sql"
+	"fmt"
+	"go-wiki-app/internal/auth"
+	"go-wiki-app/internal/config"
+	"go-wiki-app/internal/data"
+	"go-wiki-app/internal/middleware"
 	"go-wiki-app/internal/session"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
+
+	"github.com/casbin/casbin/v2"
 )
 
 // mockSessionManager is a mock implementation of the session.Manager interface.
@@ -15,6 +24,8 @@ type mockSessionManager struct {
 	destroyCalled bool
 	putKey        string
 	putValue      interface{}
+	values        map[string]interface{}
+	rememberMe    bool
 }
 
 // Ensure mockSessionManager implements the session.Manager interface.
@@ -24,10 +35,23 @@ func (m *mockSessionManager) LoadAndSave(next http.Handler) http.Handler { retur
 func (m *mockSessionManager) Put(ctx context.Context, key string, val interface{}) {
 	m.putKey = key
 	m.putValue = val
+	if m.values == nil {
+		m.values = make(map[string]interface{})
+	}
+	m.values[key] = val
 }
 func (m *mockSessionManager) GetString(ctx context.Context, key string) string   { return "" }
 func (m *mockSessionManager) PopString(ctx context.Context, key string) string   { return "" }
 func (m *mockSessionManager) Remove(ctx context.Context, key string)             {}
+func (m *mockSessionManager) GetTime(ctx context.Context, key string) time.Time {
+	t, _ := m.values[key].(time.Time)
+	return t
+}
+func (m *mockSessionManager) GetInt64(ctx context.Context, key string) int64 {
+	n, _ := m.values[key].(int64)
+	return n
+}
+func (m *mockSessionManager) RememberMe(ctx context.Context, val bool) { m.rememberMe = val }
 func (m *mockSessionManager) Destroy(ctx context.Context) error {
 	m.destroyCalled = true
 	return nil
@@ -37,7 +61,7 @@ func TestLogoutHandler(t *testing.T) {
 	// Arrange
 	mockSession := &mockSessionManager{}
 	// We pass nil for the authenticator and enforcer as they are not used by the logout handler.
-	authHandler := NewAuthHandler(nil, mockSession, nil)
+	authHandler := NewAuthHandler(nil, mockSession, nil, nil, config.SessionConfig{})
 
 	req := httptest.NewRequest("GET", "/auth/logout", nil)
 	rr := httptest.NewRecorder()
@@ -62,3 +86,144 @@ func TestLogoutHandler(t *testing.T) {
 		t.Errorf("want redirect to '/'; got '%s'", location.Path)
 	}
 }
+
+func TestSetSessionDeadline_RememberMe(t *testing.T) {
+	cfg := config.SessionConfig{Lifetime: 24, RememberLifetime: 720}
+
+	shortSession := &mockSessionManager{}
+	NewAuthHandler(nil, shortSession, nil, nil, cfg).setSessionDeadline(context.Background(), false)
+	rememberedSession := &mockSessionManager{}
+	NewAuthHandler(nil, rememberedSession, nil, nil, cfg).setSessionDeadline(context.Background(), true)
+
+	shortDeadline := shortSession.GetTime(context.Background(), middleware.SessionDeadlineKey)
+	rememberedDeadline := rememberedSession.GetTime(context.Background(), middleware.SessionDeadlineKey)
+	if shortDeadline.IsZero() || rememberedDeadline.IsZero() {
+		t.Fatal("expected a session deadline to be set")
+	}
+	if got := time.Until(shortDeadline); got > 24*time.Hour || got < 23*time.Hour {
+		t.Errorf("expected default session to expire in ~24h, got %v", got)
+	}
+	if got := time.Until(rememberedDeadline); got < 719*time.Hour {
+		t.Errorf("expected remembered session to expire in ~720h, got %v", got)
+	}
+	if shortSession.rememberMe || !rememberedSession.rememberMe {
+		t.Error("expected only the remembered session to get a persistent cookie")
+	}
+}
+
+// mockUserRepository records the users stored by the auth handler.
+type mockUserRepository struct {
+	users map[string]*data.User
+}
+
+func (m *mockUserRepository) GetUser(ctx context.Context, subject string) (*data.User, error) {
+	if user, ok := m.users[subject]; ok {
+		return user, nil
+	}
+	return nil, fmt.Errorf("failed to get user %s: %w", subject, sql.ErrNoRows)
+}
+
+func (m *mockUserRepository) Upsert(ctx context.Context, user *data.User) error {
+	if m.users == nil {
+		m.users = make(map[string]*data.User)
+	}
+	m.users[user.Subject] = user
+	return nil
+}
+
+func TestLogin_UpsertsUser(t *testing.T) {
+	enforcer, err := casbin.NewEnforcer("../../auth_model.conf")
+	if err != nil {
+		t.Fatalf("failed to create enforcer: %v", err)
+	}
+	users := &mockUserRepository{}
+	sessionManager := &mockSessionManager{}
+	h := NewAuthHandler(nil, sessionManager, enforcer, users, config.SessionConfig{Lifetime: 24})
+
+	identity := &auth.Identity{Subject: "alice", DisplayName: "Alice", Email: "alice@example.com", Picture: "https://example.com/alice.png", Roles: []string{"editor"}}
+	if err := h.login(context.Background(), identity, "raw-token"); err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	user := users.users["alice"]
+	if user == nil || user.Email != "alice@example.com" || user.Picture != "https://example.com/alice.png" {
+		t.Fatalf("expected the user to be stored with their email and avatar, got %+v", user)
+	}
+
+	// Logging in again replaces the stored profile.
+	identity.Email = "alice@example.org"
+	if err := h.login(context.Background(), identity, "raw-token"); err != nil {
+		t.Fatalf("second login failed: %v", err)
+	}
+	if got := users.users["alice"].Email; got != "alice@example.org" {
+		t.Errorf("expected the email to be updated, got %q", got)
+	}
+	if sessionManager.values["user_subject"] != "alice" {
+		t.Errorf("expected a session for alice, got %v", sessionManager.values["user_subject"])
+	}
+}
+
+func TestLogin_GrantsDefaultRole(t *testing.T) {
+	enforcer, err := casbin.NewEnforcer("../../auth_model.conf")
+	if err != nil {
+		t.Fatalf("failed to create enforcer: %v", err)
+	}
+	h := NewAuthHandler(nil, &mockSessionManager{}, enforcer, &mockUserRepository{}, config.SessionConfig{Lifetime: 24}, WithDefaultRole("reader", nil))
+
+	// A first-time user without roles in their token gets the default role,
+	// and logging in again does not grant it twice.
+	newcomer := &auth.Identity{Subject: "bob", DisplayName: "Bob"}
+	for i := 0; i < 2; i++ {
+		if err := h.login(context.Background(), newcomer, "raw-token"); err != nil {
+			t.Fatalf("login failed: %v", err)
+		}
+	}
+	if roles, _ := enforcer.GetRolesForUser("bob"); len(roles) != 1 || roles[0] != "reader" {
+		t.Errorf("expected bob to have only the default role, got %v", roles)
+	}
+
+	// Once an admin removes the default role, logging in does not grant it again.
+	enforcer.DeleteRoleForUser("bob", "reader")
+	if err := h.login(context.Background(), newcomer, "raw-token"); err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	if roles, _ := enforcer.GetRolesForUser("bob"); len(roles) != 0 {
+		t.Errorf("expected the removed default role to stay removed, got %v", roles)
+	}
+
+	// A user whose token carries roles keeps them and is not given the default.
+	editor := &auth.Identity{Subject: "alice", DisplayName: "Alice", Roles: []string{"editor"}}
+	if err := h.login(context.Background(), editor, "raw-token"); err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	if roles, _ := enforcer.GetRolesForUser("alice"); len(roles) != 1 || roles[0] != "editor" {
+		t.Errorf("expected alice to keep only the editor role, got %v", roles)
+	}
+}
+
+// mockSessionEpochs hands out a fixed session epoch.
+type mockSessionEpochs struct {
+	epoch int64
+}
+
+func (m *mockSessionEpochs) SessionEpoch(ctx context.Context) (int64, error) { return m.epoch, nil }
+func (m *mockSessionEpochs) Lockdown(ctx context.Context) (int64, error) {
+	m.epoch++
+	return m.epoch, nil
+}
+
+func TestLogin_RecordsSessionEpoch(t *testing.T) {
+	enforcer, err := casbin.NewEnforcer("../../auth_model.conf")
+	if err != nil {
+		t.Fatalf("failed to create enforcer: %v", err)
+	}
+	sessionManager := &mockSessionManager{}
+	h := NewAuthHandler(nil, sessionManager, enforcer, nil, config.SessionConfig{Lifetime: 24},
+		WithSessionEpochs(&mockSessionEpochs{epoch: 3}))
+
+	if err := h.login(context.Background(), &auth.Identity{Subject: "alice", Roles: []string{"editor"}}, "raw-token"); err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	if got := sessionManager.GetInt64(context.Background(), middleware.SessionEpochKey); got != 3 {
+		t.Errorf("expected the session to record epoch 3, got %d", got)
+	}
+}
@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"go-wiki-app/internal/middleware"
+	"net/http"
+	"strings"
+)
+
+const (
+	// defaultMaxBatchSize caps a batch request when no limit is configured.
+	defaultMaxBatchSize = 100
+	// maxBatchBodyBytes bounds the size of a batch request body.
+	maxBatchBodyBytes = 1 << 20
+)
+
+// batchRequest is the body of a POST /api/v1/pages/batch request.
+type batchRequest struct {
+	Titles []string `json:"titles"`
+}
+
+// batchResult is the outcome for one requested title: the page, or a 404
+// status when there is no page the caller may see.
+type batchResult struct {
+	Status int       `json:"status"`
+	Page   *pageJSON `json:"page,omitempty"`
+}
+
+// batchPagesHandler serves several pages at once, keyed by the requested
+// titles, so sync tools need one round trip instead of one per page. Pages that
+// do not exist and pages the caller may not see are both reported as 404.
+func (h *PageHandler) batchPagesHandler(w http.ResponseWriter, r *http.Request) *middleware.AppError {
+	var req batchRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchBodyBytes)).Decode(&req); err != nil {
+		return &middleware.AppError{Error: err, Message: "Invalid batch request", Code: http.StatusBadRequest}
+	}
+
+	seen := make(map[string]bool, len(req.Titles))
+	var titles []string
+	for _, title := range req.Titles {
+		title = strings.TrimSpace(title)
+		if title == "" || seen[title] {
+			continue
+		}
+		seen[title] = true
+		titles = append(titles, title)
+	}
+	if len(titles) == 0 {
+		return &middleware.AppError{Error: fmt.Errorf("empty batch request"), Message: "No titles given", Code: http.StatusBadRequest}
+	}
+	if len(titles) > h.maxBatchSize {
+		return &middleware.AppError{
+			Error:   fmt.Errorf("batch of %d titles exceeds limit of %d", len(titles), h.maxBatchSize),
+			Message: fmt.Sprintf("At most %d titles may be requested at once", h.maxBatchSize),
+			Code:    http.StatusBadRequest,
+		}
+	}
+
+	pages, err := h.pageService.GetPagesByTitles(r.Context(), titles)
+	if err != nil {
+		return &middleware.AppError{Error: err, Message: "Failed to retrieve pages", Code: http.StatusInternalServerError}
+	}
+
+	out := make(map[string]batchResult, len(titles))
+	for _, title := range titles {
+		page, ok := pages[title]
+		if !ok || !h.canSee(r, page) {
+			out[title] = batchResult{Status: http.StatusNotFound}
+			continue
+		}
+		p := newPageJSON(page)
+		out[title] = batchResult{Status: http.StatusOK, Page: &p}
+	}
+
+	body, err := json.Marshal(out)
+	if err != nil {
+		return &middleware.AppError{Error: err, Message: "Failed to encode pages", Code: http.StatusInternalServerError}
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(body)
+	return nil
+}
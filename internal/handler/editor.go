@@ -0,0 +1,40 @@
+package handler
+
+import "strings"
+
+// editorAutosave mirrors EasyMDE's autosave option.
+type editorAutosave struct {
+	Enabled  bool   `json:"enabled"`
+	UniqueID string `json:"uniqueId"`
+	Delay    int    `json:"delay"` // milliseconds
+}
+
+// editorOptions is the EasyMDE configuration embedded as JSON in the edit page.
+type editorOptions struct {
+	Toolbar      []string        `json:"toolbar"`
+	SpellChecker bool            `json:"spellChecker"`
+	InputStyle   string          `json:"inputStyle,omitempty"`
+	Autosave     *editorAutosave `json:"autosave,omitempty"`
+}
+
+// editorConfigFor builds the editor configuration for editing the page with the given title.
+func (h *PageHandler) editorConfigFor(title string) editorOptions {
+	opts := editorOptions{
+		Toolbar:      h.editor.Toolbar,
+		SpellChecker: h.editor.SpellCheck,
+	}
+	// EasyMDE's bundled spell checker only knows English. For other languages,
+	// fall back to the browser's native spellcheck, which honours the lang attribute.
+	if h.editor.SpellCheck && !strings.HasPrefix(strings.ToLower(h.language), "en") {
+		opts.SpellChecker = false
+		opts.InputStyle = "contenteditable"
+	}
+	if h.editor.AutosaveSeconds > 0 {
+		opts.Autosave = &editorAutosave{
+			Enabled:  true,
+			UniqueID: "wiki-page-" + title,
+			Delay:    h.editor.AutosaveSeconds * 1000,
+		}
+	}
+	return opts
+}
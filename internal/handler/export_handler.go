@@ -0,0 +1,262 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"go-wiki-app/internal/data"
+	"go-wiki-app/internal/export"
+	"go-wiki-app/internal/middleware"
+	"go-wiki-app/internal/service"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// errRenderPanic is returned by viewPageIsolated when rendering a page panicked.
+var errRenderPanic = errors.New("page rendering panicked")
+
+// renderFailedPlaceholder stands in for a page that could not be rendered.
+const renderFailedPlaceholder = "<p><em>This page could not be rendered.</em></p>"
+
+type opmlOutline struct {
+	Text     string        `xml:"text,attr"`
+	Type     string        `xml:"type,attr,omitempty"`
+	URL      string        `xml:"url,attr,omitempty"`
+	Outlines []opmlOutline `xml:"outline"`
+}
+
+type opmlHead struct {
+	Title       string `xml:"title"`
+	DateCreated string `xml:"dateCreated"`
+}
+
+type opmlBody struct {
+	Outlines []opmlOutline `xml:"outline"`
+}
+
+type opmlDocument struct {
+	XMLName xml.Name `xml:"opml"`
+	Version string   `xml:"version,attr"`
+	Head    opmlHead `xml:"head"`
+	Body    opmlBody `xml:"body"`
+}
+
+// categoryOutline builds the category tree with the titles of the pages the
+// current user may view under each subcategory.
+func (h *PageHandler) categoryOutline(r *http.Request) ([]opmlOutline, error) {
+	tree, err := h.pageService.GetCategoryTree(r.Context())
+	if err != nil {
+		return nil, err
+	}
+	outline := make([]opmlOutline, 0, len(tree))
+	for _, node := range tree {
+		category := opmlOutline{Text: node.Category.Name}
+		for _, child := range node.Children {
+			subcategory, err := h.subcategoryOutline(r, node.Category.Name, child)
+			if err != nil {
+				return nil, err
+			}
+			category.Outlines = append(category.Outlines, subcategory)
+		}
+		outline = append(outline, category)
+	}
+	return outline, nil
+}
+
+// subcategoryOutline lists the pages of a subcategory the current user may
+// view, followed by its own subcategories.
+func (h *PageHandler) subcategoryOutline(r *http.Request, categoryName string, node *service.CategoryNode) (opmlOutline, error) {
+	subcategory := opmlOutline{Text: node.Category.Name}
+	pages, err := h.pageService.GetPagesForSubcategory(r.Context(), categoryName, strings.TrimPrefix(node.Path, categoryName+"/"))
+	if err != nil {
+		return subcategory, err
+	}
+	baseURL := h.siteURL(r)
+	for _, page := range pages {
+		if !h.canSee(r, page) {
+			continue
+		}
+		subcategory.Outlines = append(subcategory.Outlines, opmlOutline{
+			Text: page.Title,
+			Type: "link",
+			URL:  baseURL + "/view/" + url.PathEscape(page.Title),
+		})
+	}
+	for _, child := range node.Children {
+		outline, err := h.subcategoryOutline(r, categoryName, child)
+		if err != nil {
+			return subcategory, err
+		}
+		subcategory.Outlines = append(subcategory.Outlines, outline)
+	}
+	return subcategory, nil
+}
+
+// categoriesExportHandler serves the category tree, with page titles under each
+// subcategory, as OPML (default) or as a nested markdown list (?format=md).
+func (h *PageHandler) categoriesExportHandler(w http.ResponseWriter, r *http.Request) *middleware.AppError {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "opml"
+	}
+	if format != "opml" && format != "md" {
+		return &middleware.AppError{Error: fmt.Errorf("unsupported export format %q", format), Message: "Unsupported export format", Code: http.StatusBadRequest}
+	}
+
+	outline, err := h.categoryOutline(r)
+	if err != nil {
+		return &middleware.AppError{Error: err, Message: "Failed to retrieve category tree", Code: http.StatusInternalServerError}
+	}
+
+	if format == "md" {
+		var b strings.Builder
+		writeMarkdownOutline(&b, outline, 0)
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="categories.md"`)
+		w.Write([]byte(b.String()))
+		return nil
+	}
+
+	doc := opmlDocument{
+		Version: "2.0",
+		Head: opmlHead{
+			Title:       "Wiki categories",
+			DateCreated: time.Now().UTC().Format(time.RFC1123Z),
+		},
+		Body: opmlBody{Outlines: outline},
+	}
+	out, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return &middleware.AppError{Error: err, Message: "Failed to generate OPML", Code: http.StatusInternalServerError}
+	}
+	w.Header().Set("Content-Type", "text/x-opml; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="categories.opml"`)
+	w.Write([]byte(xml.Header))
+	w.Write(out)
+	return nil
+}
+
+// markdownEscaper escapes characters that would break a markdown link label.
+var markdownEscaper = strings.NewReplacer(`\`, `\\`, `[`, `\[`, `]`, `\]`, `*`, `\*`, `_`, `\_`)
+
+// writeMarkdownOutline renders the outline as a nested markdown list.
+func writeMarkdownOutline(b *strings.Builder, outline []opmlOutline, depth int) {
+	indent := strings.Repeat("  ", depth)
+	for _, item := range outline {
+		if item.URL != "" {
+			fmt.Fprintf(b, "%s- [%s](<%s>)\n", indent, markdownEscaper.Replace(item.Text), item.URL)
+		} else {
+			fmt.Fprintf(b, "%s- %s\n", indent, markdownEscaper.Replace(item.Text))
+		}
+		writeMarkdownOutline(b, item.Outlines, depth+1)
+	}
+}
+
+// pagePDFHandler renders a page as a standalone HTML document, with its URL and
+// the export date in the footer, and converts it to PDF.
+func (h *PageHandler) pagePDFHandler(w http.ResponseWriter, r *http.Request) *middleware.AppError {
+	if h.pdf == nil {
+		return &middleware.AppError{Error: errors.New("pdf export is disabled"), Message: "Page not found", Code: http.StatusNotFound}
+	}
+	// The route takes the whole last segment, since a {title}.pdf pattern
+	// would stop at the first dot of titles like "Node.js".
+	title, ok := strings.CutSuffix(chi.URLParam(r, "title"), ".pdf")
+	if !ok || title == "" {
+		return &middleware.AppError{Error: fmt.Errorf("export %q is not a pdf", chi.URLParam(r, "title")), Message: "Page not found", Code: http.StatusNotFound}
+	}
+	if !h.canView(r, title) {
+		return &middleware.AppError{Error: fmt.Errorf("not allowed to view %q", title), Message: "Forbidden", Code: http.StatusForbidden}
+	}
+	page, err := h.pageService.ViewPage(r.Context(), title)
+	if appErr := renderAborted(w, err); appErr != nil {
+		return appErr
+	}
+	if err != nil {
+		return &middleware.AppError{Error: err, Message: "Page not found", Code: http.StatusNotFound}
+	}
+	if !h.canSee(r, page) {
+		return &middleware.AppError{Error: fmt.Errorf("page %q is archived", title), Message: "Page not found", Code: http.StatusNotFound}
+	}
+
+	templateData := h.newTemplateData(r)
+	templateData["Page"] = page
+	templateData["PageURL"] = h.siteURL(r) + "/view/" + url.PathEscape(page.Title)
+	templateData["ExportedAt"] = time.Now()
+	var doc bytes.Buffer
+	if err := h.view.Render(&doc, r, "pages/export/page.html", templateData); err != nil {
+		return &middleware.AppError{Error: err, Message: "Failed to render page for export", Code: http.StatusInternalServerError}
+	}
+
+	pdf, err := h.pdf.ConvertHTML(r.Context(), doc.Bytes())
+	if err != nil {
+		return &middleware.AppError{Error: err, Message: "Failed to generate PDF", Code: http.StatusInternalServerError}
+	}
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.pdf"`, service.Slugify(page.Title, h.slugTransliterate)))
+	w.Write(pdf)
+	return nil
+}
+
+// categoryEPUBHandler bundles the pages of a category and its subcategories
+// that the current user may view into an EPUB, one chapter per page.
+func (h *PageHandler) categoryEPUBHandler(w http.ResponseWriter, r *http.Request) *middleware.AppError {
+	categoryName := chi.URLParam(r, "categoryName")
+	pages, err := h.pageService.GetPagesForCategory(r.Context(), categoryName)
+	if err != nil {
+		return &middleware.AppError{Error: err, Message: "Failed to get pages for category", Code: http.StatusNotFound}
+	}
+	sort.SliceStable(pages, func(i, j int) bool {
+		return strings.ToLower(pages[i].Title) < strings.ToLower(pages[j].Title)
+	})
+
+	book := export.Book{Title: categoryName, Language: h.language}
+	for _, p := range pages {
+		if !h.canSee(r, p) {
+			continue
+		}
+		// ViewPage renders and sanitizes the content just like the page view.
+		page, err := h.viewPageIsolated(r.Context(), p.Title)
+		if errors.Is(err, errRenderPanic) {
+			// One bad page should not cost the reader the whole book.
+			h.logFor(r.Context()).Error(err, "Skipping page that failed to render in EPUB export")
+			book.Chapters = append(book.Chapters, export.Chapter{Title: p.Title, HTML: renderFailedPlaceholder})
+			continue
+		}
+		if appErr := renderAborted(w, err); appErr != nil {
+			return appErr
+		}
+		if err != nil {
+			return &middleware.AppError{Error: err, Message: "Failed to render page for export", Code: http.StatusInternalServerError}
+		}
+		book.Chapters = append(book.Chapters, export.Chapter{Title: page.Title, HTML: string(page.HTMLContent)})
+	}
+
+	var buf bytes.Buffer
+	if err := h.epub.WriteEPUB(&buf, book); err != nil {
+		return &middleware.AppError{Error: err, Message: "Failed to generate EPUB", Code: http.StatusInternalServerError}
+	}
+	w.Header().Set("Content-Type", "application/epub+zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.epub"`, service.Slugify(categoryName, h.slugTransliterate)))
+	w.Write(buf.Bytes())
+	return nil
+}
+
+// viewPageIsolated loads and renders a page like ViewPage, but turns a panic
+// while doing so into an error wrapping errRenderPanic. Views built from many
+// pages use it to skip a page whose content breaks the renderer instead of
+// failing the whole response.
+func (h *PageHandler) viewPageIsolated(ctx context.Context, title string) (page *data.Page, err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			page, err = nil, fmt.Errorf("%w: %q: %v", errRenderPanic, title, rec)
+		}
+	}()
+	return h.pageService.ViewPage(ctx, title)
+}
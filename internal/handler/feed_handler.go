@@ -0,0 +1,150 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"go-wiki-app/internal/feed"
+	"go-wiki-app/internal/middleware"
+	"go-wiki-app/internal/service"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// requestBaseURL derives the scheme and host of the current request, e.g. "https://wiki.example.com".
+func requestBaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// siteURL returns the configured public address of the wiki, or the one the
+// current request was made to if none is configured.
+func (h *PageHandler) siteURL(r *http.Request) string {
+	if h.baseURL != "" {
+		return h.baseURL
+	}
+	return requestBaseURL(r)
+}
+
+// feedLink is an Atom feed advertised in a page's head, so feed readers can
+// discover it.
+type feedLink struct {
+	Title string
+	URL   string
+}
+
+// pageFeedLink returns the feed of the page's revision history.
+func (h *PageHandler) pageFeedLink(r *http.Request, title string) feedLink {
+	return feedLink{Title: "History of " + title, URL: h.siteURL(r) + "/view/" + url.PathEscape(title) + "/feed.xml"}
+}
+
+// categoryFeedLink returns the feed of the pages in the category.
+func (h *PageHandler) categoryFeedLink(r *http.Request, categoryName string) feedLink {
+	return feedLink{Title: "Pages in " + categoryName, URL: h.siteURL(r) + "/category/" + url.PathEscape(categoryName) + "/feed.xml"}
+}
+
+// categoryFeedLimit is the number of recently updated pages listed in a category feed.
+const categoryFeedLimit = 50
+
+// pageFeedHandler serves an Atom feed of a single page's revision history.
+func (h *PageHandler) pageFeedHandler(w http.ResponseWriter, r *http.Request) *middleware.AppError {
+	title := chi.URLParam(r, "title")
+	page, revisions, err := h.pageService.GetPageHistory(r.Context(), title)
+	if err != nil {
+		return &middleware.AppError{Error: err, Message: "Page not found", Code: http.StatusNotFound}
+	}
+	if !h.canSee(r, page) {
+		return &middleware.AppError{Error: fmt.Errorf("page %q is hidden from the user", title), Message: "Page not found", Code: http.StatusNotFound}
+	}
+
+	// Entries are listed newest first, regardless of how they were loaded.
+	sort.SliceStable(revisions, func(i, j int) bool {
+		return revisions[i].CreatedAt.After(revisions[j].CreatedAt)
+	})
+
+	base := h.siteURL(r)
+	pageURL := base + "/view/" + url.PathEscape(page.Title)
+	updated := page.UpdatedAt
+	if len(revisions) > 0 {
+		updated = revisions[0].CreatedAt
+	}
+	history := feed.New(h.pageFeedLink(r, page.Title).URL, "History of "+page.Title, pageURL, updated)
+
+	for i, rev := range revisions {
+		// The first revision has nothing to be compared with, so it links to the page.
+		link := pageURL
+		summary := fmt.Sprintf("%s created %s", rev.AuthorID, rev.Title)
+		if i+1 < len(revisions) {
+			link = fmt.Sprintf("%s/diff/%s?from=%d&to=%d", base, url.PathEscape(page.Title), revisions[i+1].ID, rev.ID)
+			summary = fmt.Sprintf("%s edited %s", rev.AuthorID, rev.Title)
+		}
+		history.Entries = append(history.Entries, feed.Entry{
+			ID:      fmt.Sprintf("%s#revision-%d", pageURL, rev.ID),
+			Title:   fmt.Sprintf("Revision %d of %s", rev.ID, rev.Title),
+			Updated: feed.Timestamp(rev.CreatedAt),
+			Author:  feed.Person{Name: rev.AuthorID},
+			Links:   []feed.Link{{Href: link, Rel: "alternate", Type: "text/html"}},
+			Summary: summary,
+		})
+	}
+
+	return writeAtomFeed(w, history)
+}
+
+// categoryFeedHandler serves an Atom feed of the pages in a category and its
+// subcategories, most recently updated first.
+func (h *PageHandler) categoryFeedHandler(w http.ResponseWriter, r *http.Request) *middleware.AppError {
+	categoryName := chi.URLParam(r, "categoryName")
+	pages, err := h.pageService.GetPagesForCategory(r.Context(), categoryName)
+	if err != nil {
+		if errors.Is(err, service.ErrCategoryNotFound) {
+			return &middleware.AppError{Error: err, Message: "Category not found", Code: http.StatusNotFound}
+		}
+		return &middleware.AppError{Error: err, Message: "Failed to get pages for category", Code: http.StatusInternalServerError}
+	}
+	pages = h.visiblePages(r, pages)
+	sort.SliceStable(pages, func(i, j int) bool {
+		return pages[i].UpdatedAt.After(pages[j].UpdatedAt)
+	})
+	if len(pages) > categoryFeedLimit {
+		pages = pages[:categoryFeedLimit]
+	}
+
+	base := h.siteURL(r)
+	// A feed must have an updated date, even when the category is empty.
+	updated := time.Unix(0, 0)
+	if len(pages) > 0 {
+		updated = pages[0].UpdatedAt
+	}
+	link := h.categoryFeedLink(r, categoryName)
+	recent := feed.New(link.URL, link.Title, base+"/category/"+url.PathEscape(categoryName), updated)
+
+	for _, page := range pages {
+		pageURL := base + "/view/" + url.PathEscape(page.Title)
+		recent.Entries = append(recent.Entries, feed.Entry{
+			ID:      pageURL,
+			Title:   page.Title,
+			Updated: feed.Timestamp(page.UpdatedAt),
+			Author:  feed.Person{Name: page.AuthorID},
+			Links:   []feed.Link{{Href: pageURL, Rel: "alternate", Type: "text/html"}},
+			Summary: fmt.Sprintf("%s updated %s", page.AuthorID, page.Title),
+		})
+	}
+
+	return writeAtomFeed(w, recent)
+}
+
+// writeAtomFeed serves the feed with the Atom content type.
+func writeAtomFeed(w http.ResponseWriter, f *feed.Feed) *middleware.AppError {
+	w.Header().Set("Content-Type", feed.ContentType)
+	if err := f.Write(w); err != nil {
+		return &middleware.AppError{Error: err, Message: "Failed to generate feed", Code: http.StatusInternalServerError}
+	}
+	return nil
+}
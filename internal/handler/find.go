@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"html/template"
+	"regexp"
+	"strings"
+
+	xhtml "golang.org/x/net/html"
+)
+
+// maxFindLength limits the length of the ?find= term.
+const maxFindLength = 100
+
+// highlightMatches wraps every case-insensitive occurrence of term in the
+// rendered page's text in <mark id="find-N">, leaving tags and attributes
+// untouched. It returns the highlighted HTML and the number of matches.
+func highlightMatches(content template.HTML, term string) (template.HTML, int) {
+	term = strings.TrimSpace(term)
+	if term == "" {
+		return content, 0
+	}
+	pattern := regexp.MustCompile(`(?i)` + regexp.QuoteMeta(term))
+
+	var b bytes.Buffer
+	count := 0
+	z := xhtml.NewTokenizer(strings.NewReader(string(content)))
+	for {
+		tt := z.Next()
+		if tt == xhtml.ErrorToken {
+			break
+		}
+		if tt != xhtml.TextToken {
+			b.Write(z.Raw())
+			continue
+		}
+		text := string(z.Text())
+		last := 0
+		for _, loc := range pattern.FindAllStringIndex(text, -1) {
+			count++
+			b.WriteString(html.EscapeString(text[last:loc[0]]))
+			fmt.Fprintf(&b, `<mark id="find-%d">%s</mark>`, count, html.EscapeString(text[loc[0]:loc[1]]))
+			last = loc[1]
+		}
+		b.WriteString(html.EscapeString(text[last:]))
+	}
+	return template.HTML(b.String()), count
+}
@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"go-wiki-app/internal/middleware"
+	"go-wiki-app/internal/service"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// historyHandler lists the revisions of a page, newest first.
+func (h *PageHandler) historyHandler(w http.ResponseWriter, r *http.Request) *middleware.AppError {
+	title := chi.URLParam(r, "title")
+	page, revisions, err := h.pageService.GetPageHistory(r.Context(), title)
+	if err != nil {
+		return &middleware.AppError{Error: err, Message: "Page not found", Code: http.StatusNotFound}
+	}
+	if !h.canSee(r, page) {
+		return &middleware.AppError{Error: fmt.Errorf("page %q is hidden from the user", title), Message: "Page not found", Code: http.StatusNotFound}
+	}
+
+	templateData := h.newTemplateData(r)
+	templateData["Page"] = page
+	templateData["Revisions"] = revisions
+	templateData["Feeds"] = []feedLink{h.pageFeedLink(r, page.Title)}
+	templateData["CanRollback"] = h.can(r.Context(), "/rollback/"+page.Title+"/", http.MethodPost)
+	if err := h.view.Render(w, r, "pages/history.html", templateData); err != nil {
+		return &middleware.AppError{Error: err, Message: "Failed to render page history", Code: http.StatusInternalServerError}
+	}
+	return nil
+}
+
+// diffHandler shows what changed in a page from the revision given by the
+// "from" query parameter to the one given by "to". Omitting "to" compares
+// against the current content.
+func (h *PageHandler) diffHandler(w http.ResponseWriter, r *http.Request) *middleware.AppError {
+	title := chi.URLParam(r, "title")
+	fromID, err := strconv.ParseInt(r.URL.Query().Get("from"), 10, 64)
+	if err != nil {
+		return &middleware.AppError{Error: err, Message: "Invalid revision", Code: http.StatusBadRequest}
+	}
+	var toID int64
+	if to := r.URL.Query().Get("to"); to != "" {
+		if toID, err = strconv.ParseInt(to, 10, 64); err != nil || toID <= 0 {
+			return &middleware.AppError{Error: fmt.Errorf("invalid revision %q", to), Message: "Invalid revision", Code: http.StatusBadRequest}
+		}
+	}
+
+	page, err := h.pageService.ViewPage(r.Context(), title)
+	if err != nil {
+		return &middleware.AppError{Error: err, Message: "Page not found", Code: http.StatusNotFound}
+	}
+	if !h.canSee(r, page) {
+		return &middleware.AppError{Error: fmt.Errorf("page %q is hidden from the user", title), Message: "Page not found", Code: http.StatusNotFound}
+	}
+	var revisionDiff *service.RevisionDiff
+	if toID == 0 {
+		revisionDiff, err = h.pageService.DiffAgainstCurrent(r.Context(), page.ID, fromID)
+	} else {
+		revisionDiff, err = h.pageService.DiffRevisions(r.Context(), page.ID, fromID, toID)
+	}
+	if err != nil {
+		if errors.Is(err, service.ErrRevisionNotFound) {
+			return &middleware.AppError{Error: err, Message: "Revision not found", Code: http.StatusNotFound}
+		}
+		return &middleware.AppError{Error: err, Message: "Failed to compare revisions", Code: http.StatusInternalServerError}
+	}
+
+	templateData := h.newTemplateData(r)
+	templateData["Diff"] = revisionDiff
+	if err := h.view.Render(w, r, "pages/diff.html", templateData); err != nil {
+		return &middleware.AppError{Error: err, Message: "Failed to render diff", Code: http.StatusInternalServerError}
+	}
+	return nil
+}
+
+// rollbackHandler restores an earlier revision of a page as its new current
+// version. The "base" form field holds the page's newest revision when the
+// history was shown; if the page has been edited since, nothing is restored.
+func (h *PageHandler) rollbackHandler(w http.ResponseWriter, r *http.Request) *middleware.AppError {
+	title := chi.URLParam(r, "title")
+	revisionID, err := strconv.ParseInt(chi.URLParam(r, "revisionID"), 10, 64)
+	if err != nil {
+		return &middleware.AppError{Error: err, Message: "Invalid revision", Code: http.StatusBadRequest}
+	}
+	page, revisions, err := h.pageService.GetPageHistory(r.Context(), title)
+	if err != nil {
+		return &middleware.AppError{Error: err, Message: "Page not found", Code: http.StatusNotFound}
+	}
+	if denied, appErr := h.denyRestricted(w, r, page); denied {
+		return appErr
+	}
+	if base := r.FormValue("base"); base != "" && (len(revisions) == 0 || base != strconv.FormatInt(revisions[0].ID, 10)) {
+		return &middleware.AppError{
+			Error:   fmt.Errorf("page %q was edited after revision %s", title, base),
+			Message: "The page has been edited since you opened its history. Review the changes and try again.",
+			Code:    http.StatusConflict,
+		}
+	}
+
+	subject := middleware.GetUserInfo(r.Context()).Subject
+	if _, err := h.pageService.RollbackPage(r.Context(), page.ID, revisionID, subject); err != nil {
+		if errors.Is(err, service.ErrPendingReview) {
+			http.Redirect(w, r, "/view/"+url.PathEscape(page.Title)+"?pending=1", http.StatusSeeOther)
+			return nil
+		}
+		if errors.Is(err, service.ErrRevisionNotFound) {
+			return &middleware.AppError{Error: err, Message: "Revision not found", Code: http.StatusNotFound}
+		}
+		return &middleware.AppError{Error: err, Message: "Failed to restore the revision", Code: http.StatusInternalServerError}
+	}
+	http.Redirect(w, r, "/view/"+url.PathEscape(page.Title), http.StatusSeeOther)
+	return nil
+}
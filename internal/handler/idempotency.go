@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"go-wiki-app/internal/middleware"
+	"io"
+	"net/http"
+	"time"
+)
+
+// IdempotencyHeader is the request header clients send a unique key in, so
+// that retrying a request does not repeat its effect.
+const IdempotencyHeader = "Idempotency-Key"
+
+// maxIdempotencyKeyLength bounds the keys clients may send, e.g. a UUID.
+const maxIdempotencyKeyLength = 255
+
+// IdempotencyStore keeps the responses to requests carrying an idempotency key.
+// It is satisfied by *cache.Cache.
+type IdempotencyStore interface {
+	Get(key string) ([]byte, error)
+	Set(key string, value []byte, ttl time.Duration) error
+}
+
+// storedResponse is a response kept to be replayed when a request is retried
+// with the same idempotency key.
+type storedResponse struct {
+	RequestHash string            `json:"request_hash"`
+	Status      int               `json:"status"`
+	Header      map[string]string `json:"header"`
+	Body        []byte            `json:"body"`
+}
+
+// replayedHeaders are the response headers stored with a response.
+var replayedHeaders = []string{"Content-Type", "Location", "ETag"}
+
+// responseRecorder passes a response through while keeping a copy of it.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+// idempotent runs next at most once per idempotency key and user. A request
+// retried with the same key gets the stored response instead, and one reusing
+// the key for a different body gets 422, and one arriving while the first is
+// still running gets 409. Only successful responses are stored, so a request
+// that failed may be retried with its key. Requests without the header, and
+// all requests when no store is configured, run as usual.
+func (h *PageHandler) idempotent(w http.ResponseWriter, r *http.Request, next func(http.ResponseWriter, *http.Request) *middleware.AppError) *middleware.AppError {
+	key := r.Header.Get(IdempotencyHeader)
+	if key == "" || h.idempotency == nil {
+		return next(w, r)
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		return &middleware.AppError{Error: fmt.Errorf("idempotency key of %d bytes", len(key)), Message: "The Idempotency-Key is too long", Code: http.StatusBadRequest}
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxAPIBodyBytes))
+	if err != nil {
+		return &middleware.AppError{Error: err, Message: "Invalid request body", Code: http.StatusBadRequest}
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	sum := sha256.Sum256(append([]byte(r.Method+" "+r.URL.Path+"\n"), body...))
+	requestHash := hex.EncodeToString(sum[:])
+	// Keys are scoped to the user, so one client cannot read another's responses.
+	storeKey := "idempotency:" + middleware.GetUserInfo(r.Context()).Subject + ":" + key
+
+	// The key is reserved before the store is read, so a retry racing the
+	// first attempt either finds it still running or replays its response.
+	if _, running := h.idempotencyInFlight.LoadOrStore(storeKey, struct{}{}); running {
+		return &middleware.AppError{
+			Error:   fmt.Errorf("idempotency key %q is already in use by a running request", key),
+			Message: "A request with this Idempotency-Key is still being processed. Retry it shortly.",
+			Code:    http.StatusConflict,
+		}
+	}
+	defer h.idempotencyInFlight.Delete(storeKey)
+
+	if cached, err := h.idempotency.Get(storeKey); err != nil {
+		h.logFor(r.Context()).Error(err, "Failed to look up idempotency key")
+	} else if cached != nil {
+		var stored storedResponse
+		if err := json.Unmarshal(cached, &stored); err == nil {
+			if stored.RequestHash != requestHash {
+				return &middleware.AppError{
+					Error:   fmt.Errorf("idempotency key %q reused for a different request", key),
+					Message: "This Idempotency-Key was already used for a different request",
+					Code:    http.StatusUnprocessableEntity,
+				}
+			}
+			for name, value := range stored.Header {
+				w.Header().Set(name, value)
+			}
+			w.WriteHeader(stored.Status)
+			w.Write(stored.Body)
+			return nil
+		}
+	}
+
+	rec := &responseRecorder{ResponseWriter: w}
+	if appErr := next(rec, r); appErr != nil {
+		return appErr
+	}
+	if rec.status < 200 || rec.status >= 300 {
+		return nil
+	}
+	stored := storedResponse{RequestHash: requestHash, Status: rec.status, Header: make(map[string]string), Body: rec.body.Bytes()}
+	for _, name := range replayedHeaders {
+		if value := w.Header().Get(name); value != "" {
+			stored.Header[name] = value
+		}
+	}
+	out, err := json.Marshal(stored)
+	if err == nil {
+		err = h.idempotency.Set(storeKey, out, h.idempotencyTTL)
+	}
+	if err != nil {
+		h.logFor(r.Context()).Error(err, "Failed to store idempotent response")
+	}
+	return nil
+}
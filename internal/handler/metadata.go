@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"go-wiki-app/internal/data"
+	"net/http"
+	"strings"
+)
+
+// metadataFormPrefix prefixes the edit form's metadata inputs, e.g. "meta_owner".
+const metadataFormPrefix = "meta_"
+
+// metadataField is a page metadata entry prepared for the view and edit templates.
+type metadataField struct {
+	Key   string
+	Label string
+	Value string
+}
+
+// metadataFields lists the configured metadata fields with the page's values,
+// in configuration order. When onlySet is true, empty fields are left out.
+func (h *PageHandler) metadataFields(page *data.Page, onlySet bool) []metadataField {
+	var fields []metadataField
+	for _, key := range h.pageService.MetadataFields() {
+		value := page.Metadata[key]
+		if onlySet && value == "" {
+			continue
+		}
+		fields = append(fields, metadataField{Key: key, Label: metadataLabel(key), Value: value})
+	}
+	return fields
+}
+
+// metadataFromForm collects the submitted metadata values. Fields absent from
+// the form are not included, so they are left unchanged when saved.
+func (h *PageHandler) metadataFromForm(r *http.Request) map[string]string {
+	metadata := map[string]string{}
+	for _, key := range h.pageService.MetadataFields() {
+		if values, ok := r.PostForm[metadataFormPrefix+key]; ok && len(values) > 0 {
+			metadata[key] = values[0]
+		}
+	}
+	return metadata
+}
+
+// metadataLabel turns a metadata key such as "review_date" into "Review date".
+func metadataLabel(key string) string {
+	label := strings.ReplaceAll(key, "_", " ")
+	if label == "" {
+		return label
+	}
+	return strings.ToUpper(label[:1]) + label[1:]
+}
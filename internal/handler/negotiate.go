@@ -0,0 +1,285 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-wiki-app/internal/data"
+	"go-wiki-app/internal/middleware"
+	"hash/fnv"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Representations a page can be served as from /view/{title}.
+const (
+	formatHTML     = "text/html"
+	formatMarkdown = "text/markdown"
+	formatJSON     = "application/json"
+)
+
+// negotiableFormats lists the supported representations in order of preference
+// when the client accepts several of them equally.
+var negotiableFormats = []string{formatHTML, formatMarkdown, formatJSON}
+
+// negotiateFormat picks the representation that best matches an Accept header.
+// A missing header, wildcards, and unsupported types fall back to HTML.
+func negotiateFormat(accept string) string {
+	if accept == "" {
+		return formatHTML
+	}
+	best, bestQ := formatHTML, 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+		for _, format := range negotiableFormats {
+			if mediaType != format || q <= bestQ {
+				continue
+			}
+			best, bestQ = format, q
+		}
+		// Wildcards only win when nothing more specific was requested.
+		if (mediaType == "*/*" || mediaType == "text/*") && q > bestQ {
+			best, bestQ = formatHTML, q
+		}
+	}
+	return best
+}
+
+// acceptsJSON reports whether an Accept header allows a JSON response. A missing
+// header accepts anything.
+func acceptsJSON(accept string) bool {
+	if accept == "" {
+		return true
+	}
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		if q, err := strconv.ParseFloat(params["q"], 64); err == nil && q <= 0 {
+			continue
+		}
+		if mediaType == formatJSON || mediaType == "application/*" || mediaType == "*/*" {
+			return true
+		}
+	}
+	return false
+}
+
+// writeJSON serves v as JSON with the given status code.
+func writeJSON(w http.ResponseWriter, code int, v interface{}) *middleware.AppError {
+	out, err := json.Marshal(v)
+	if err != nil {
+		return &middleware.AppError{Error: err, Message: "Failed to encode response", Code: http.StatusInternalServerError}
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	w.Write(out)
+	return nil
+}
+
+// pageJSON is the JSON representation of a page served by viewHandler.
+type pageJSON struct {
+	ID          int64     `json:"id"`
+	Title       string    `json:"title"`
+	Content     string    `json:"content"`
+	AuthorID    string    `json:"author_id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Category    string    `json:"category,omitempty"`
+	Subcategory string    `json:"subcategory,omitempty"`
+	IsStub      bool      `json:"is_stub"`
+}
+
+// pageETag identifies the version of a page served as JSON. It is derived from
+// when the page was last updated, with a hash of its title and content so that
+// edits made within the same second still change it.
+func pageETag(page *data.Page) string {
+	h := fnv.New32a()
+	h.Write([]byte(page.Title))
+	h.Write([]byte{0})
+	h.Write([]byte(page.Content))
+	return fmt.Sprintf(`"%d-%d-%x"`, page.ID, page.UpdatedAt.Unix(), h.Sum32())
+}
+
+// etagMatches reports whether an If-Match or If-None-Match header lists etag or
+// is "*". With weak set, as for If-None-Match, weak validators also match.
+func etagMatches(header, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if weak {
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// pagesETag identifies a version of a view built from every page, derived from
+// when a page was last updated and how many there are. The variant tells apart
+// representations of the same view, e.g. formats or what the current user may see.
+func pagesETag(lastModified time.Time, count int, variant string) string {
+	h := fnv.New32a()
+	h.Write([]byte(variant))
+	return fmt.Sprintf(`"%d-%d-%x"`, lastModified.UnixNano(), count, h.Sum32())
+}
+
+// notModified sets the ETag and Last-Modified validators of a view built from
+// every page and reports whether the client's copy is still current, in which
+// case it has already answered with 304. If-None-Match takes precedence over
+// If-Modified-Since, as in RFC 9110.
+func notModified(w http.ResponseWriter, r *http.Request, lastModified time.Time, etag string) bool {
+	w.Header().Set("ETag", etag)
+	if !lastModified.IsZero() {
+		w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	match := false
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		match = etagMatches(ifNoneMatch, etag, true)
+	} else if since, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil && !lastModified.IsZero() {
+		// Last-Modified has a resolution of one second.
+		match = !lastModified.Truncate(time.Second).After(since)
+	}
+	if match {
+		w.WriteHeader(http.StatusNotModified)
+	}
+	return match
+}
+
+// writePageMarkdown serves the page's raw markdown source.
+func writePageMarkdown(w http.ResponseWriter, page *data.Page) *middleware.AppError {
+	w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+	w.Write([]byte(page.Content))
+	return nil
+}
+
+// newPageJSON builds the JSON representation of a page.
+func newPageJSON(page *data.Page) pageJSON {
+	return pageJSON{
+		ID:          page.ID,
+		Title:       page.Title,
+		Content:     page.Content,
+		AuthorID:    page.AuthorID,
+		CreatedAt:   page.CreatedAt,
+		UpdatedAt:   page.UpdatedAt,
+		Category:    page.CategoryName,
+		Subcategory: page.SubcategoryName,
+		IsStub:      page.IsStub,
+	}
+}
+
+// writePageJSON serves the page's metadata and raw content as JSON.
+func writePageJSON(w http.ResponseWriter, page *data.Page) *middleware.AppError {
+	out, err := json.Marshal(newPageJSON(page))
+	if err != nil {
+		return &middleware.AppError{Error: err, Message: "Failed to encode page", Code: http.StatusInternalServerError}
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(out)
+	return nil
+}
+
+// pageSummaryJSON is a page in the JSON page list, without its content.
+type pageSummaryJSON struct {
+	ID        int64     `json:"id"`
+	Title     string    `json:"title"`
+	AuthorID  string    `json:"author_id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// pageListJSON is the JSON representation of a slice of the page list served by listHandler.
+type pageListJSON struct {
+	Pages []pageSummaryJSON `json:"pages"`
+	Total int               `json:"total"`
+	Next  string            `json:"next,omitempty"`
+	Prev  string            `json:"prev,omitempty"`
+}
+
+// listPagesJSON serves a slice of the page list as JSON. Clients walk the list
+// with the "after" and "before" cursors, following the next and prev URLs that
+// are also sent as Link headers, and may set the page size with "limit".
+func (h *PageHandler) listPagesJSON(w http.ResponseWriter, r *http.Request) *middleware.AppError {
+	query := r.URL.Query()
+	var filter data.PageListFilter
+	for name, dest := range map[string]*int64{"after": &filter.AfterID, "before": &filter.BeforeID} {
+		if value := query.Get(name); value != "" {
+			id, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || id <= 0 {
+				return &middleware.AppError{Error: fmt.Errorf("invalid %s cursor %q", name, value), Message: "Invalid page cursor", Code: http.StatusBadRequest}
+			}
+			*dest = id
+		}
+	}
+	if filter.AfterID > 0 && filter.BeforeID > 0 {
+		return &middleware.AppError{Error: errors.New("both after and before cursors given"), Message: "Use either the after or the before cursor, not both", Code: http.StatusBadRequest}
+	}
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			return &middleware.AppError{Error: fmt.Errorf("invalid limit %q", value), Message: "Invalid limit", Code: http.StatusBadRequest}
+		}
+		filter.Limit = limit
+	}
+
+	list, err := h.pageService.ListPages(r.Context(), filter)
+	if err != nil {
+		return &middleware.AppError{Error: err, Message: "Failed to retrieve pages", Code: http.StatusInternalServerError}
+	}
+
+	out := pageListJSON{Pages: []pageSummaryJSON{}, Total: list.Total}
+	// Cursors come from the unfiltered slice, so hidden pages do not shift the walk.
+	for _, page := range h.visiblePages(r, list.Pages) {
+		out.Pages = append(out.Pages, pageSummaryJSON{
+			ID:        page.ID,
+			Title:     page.Title,
+			AuthorID:  page.AuthorID,
+			CreatedAt: page.CreatedAt,
+			UpdatedAt: page.UpdatedAt,
+		})
+	}
+	cursorURL := func(name string, id int64) string {
+		q := url.Values{name: {strconv.FormatInt(id, 10)}}
+		if limit := query.Get("limit"); limit != "" {
+			q.Set("limit", limit)
+		}
+		return r.URL.Path + "?" + q.Encode()
+	}
+	if n := len(list.Pages); n > 0 {
+		if list.HasNext {
+			out.Next = cursorURL("after", list.Pages[n-1].ID)
+			w.Header().Add("Link", fmt.Sprintf(`<%s>; rel="next"`, out.Next))
+		}
+		if list.HasPrev {
+			out.Prev = cursorURL("before", list.Pages[0].ID)
+			w.Header().Add("Link", fmt.Sprintf(`<%s>; rel="prev"`, out.Prev))
+		}
+	}
+
+	body, err := json.Marshal(out)
+	if err != nil {
+		return &middleware.AppError{Error: err, Message: "Failed to encode pages", Code: http.StatusInternalServerError}
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("X-Total-Count", strconv.Itoa(list.Total))
+	w.Write(body)
+	return nil
+}
@@ -0,0 +1,171 @@
+package handler
+
+import (
+	"context"
+	"go-wiki-app/internal/config"
+	"go-wiki-app/internal/data"
+	"go-wiki-app/internal/export"
+	"go-wiki-app/internal/logger"
+	"go-wiki-app/internal/service"
+	"time"
+)
+
+// Option configures optional behaviour of a PageHandler.
+type Option func(*PageHandler)
+
+// WithEditorConfig sets the options passed to the Markdown editor on the edit page.
+func WithEditorConfig(cfg config.EditorConfig) Option {
+	return func(h *PageHandler) {
+		h.editor = cfg
+	}
+}
+
+// WithLanguage sets the content language declared on rendered pages.
+func WithLanguage(lang string) Option {
+	return func(h *PageHandler) {
+		if lang != "" {
+			h.language = lang
+		}
+	}
+}
+
+// WithSlugTransliteration makes export file names ASCII-only by transliterating titles.
+func WithSlugTransliteration(enabled bool) Option {
+	return func(h *PageHandler) {
+		h.slugTransliterate = enabled
+	}
+}
+
+// WithPDFExport enables /export/{title}.pdf using the given converter.
+func WithPDFExport(c export.PDFConverter) Option {
+	return func(h *PageHandler) {
+		h.pdf = c
+	}
+}
+
+// WithEPUBWriter replaces the writer used for category EPUB exports.
+func WithEPUBWriter(w export.EPUBWriter) Option {
+	return func(h *PageHandler) {
+		h.epub = w
+	}
+}
+
+// WithMaxBatchSize caps how many pages one batch request may fetch.
+func WithMaxBatchSize(n int) Option {
+	return func(h *PageHandler) {
+		if n > 0 {
+			h.maxBatchSize = n
+		}
+	}
+}
+
+// WithBaseURL sets the public address of the wiki used for absolute links in
+// feeds and exports. Without it they are derived from the request.
+func WithBaseURL(baseURL string) Option {
+	return func(h *PageHandler) {
+		h.baseURL = baseURL
+	}
+}
+
+// WithWelcomePageIndexing lets search engines index the welcome page shown to
+// anonymous visitors before the Home page is written. It is marked noindex by default.
+func WithWelcomePageIndexing(index bool) Option {
+	return func(h *PageHandler) {
+		h.indexWelcomePage = index
+	}
+}
+
+// WithHighlightCSS sets the stylesheet served for highlighted code blocks.
+func WithHighlightCSS(css []byte) Option {
+	return func(h *PageHandler) {
+		h.highlightCSS = css
+	}
+}
+
+// WithIdempotencyStore makes POST /api/v1/pages honour the Idempotency-Key
+// header, keeping each response in store for ttl.
+func WithIdempotencyStore(store IdempotencyStore, ttl time.Duration) Option {
+	return func(h *PageHandler) {
+		h.idempotency = store
+		h.idempotencyTTL = ttl
+	}
+}
+
+// RoleLister lists the roles known to the authorization policy.
+// It is satisfied by casbin.IEnforcer.
+type RoleLister interface {
+	GetAllRoles() ([]string, error)
+}
+
+// WithRoles lets editors restrict a page to one of the given roles from the edit form.
+func WithRoles(roles RoleLister) Option {
+	return func(h *PageHandler) {
+		h.roles = roles
+	}
+}
+
+// AuthOption configures optional behaviour of an AuthHandler.
+type AuthOption func(*AuthHandler)
+
+// WithDefaultRole grants role to users whose ID token carries no roles when
+// they first log in. Each grant is logged to log.
+func WithDefaultRole(role string, log logger.Logger) AuthOption {
+	return func(h *AuthHandler) {
+		h.defaultRole = role
+		h.log = log
+	}
+}
+
+// SessionEpochs tracks the session epoch that login sessions must have been
+// created in to stay valid.
+type SessionEpochs interface {
+	SessionEpoch(ctx context.Context) (int64, error)
+	Lockdown(ctx context.Context) (int64, error)
+}
+
+// WithSessionEpochs records the current session epoch in each new login
+// session, so that a lockdown ends it.
+func WithSessionEpochs(epochs SessionEpochs) AuthOption {
+	return func(h *AuthHandler) {
+		h.epochs = epochs
+	}
+}
+
+// AdminOption configures optional behaviour of an AdminHandler.
+type AdminOption func(*AdminHandler)
+
+// WithLockdown lets administrators end every login session at once.
+func WithLockdown(epochs SessionEpochs) AdminOption {
+	return func(h *AdminHandler) {
+		h.epochs = epochs
+	}
+}
+
+// WithBuildInfo sets the version and VCS commit of the running binary reported on /status.
+func WithBuildInfo(version, commit string) AdminOption {
+	return func(h *AdminHandler) {
+		h.version = version
+		h.commit = commit
+	}
+}
+
+// WithDatabaseDriver sets the database driver reported on /status, e.g. "mysql".
+func WithDatabaseDriver(driver string) AdminOption {
+	return func(h *AdminHandler) {
+		h.databaseDriver = driver
+	}
+}
+
+// ReviewQueue holds edits awaiting a moderator's approval.
+type ReviewQueue interface {
+	GetPendingEditReviews(ctx context.Context) ([]*service.PendingEditReview, error)
+	ApprovePendingEdit(ctx context.Context, id int64) (*data.Page, error)
+	RejectPendingEdit(ctx context.Context, id int64) (*data.PendingEdit, error)
+}
+
+// WithReviewQueue lets moderators approve or reject the edits held for review.
+func WithReviewQueue(queue ReviewQueue) AdminOption {
+	return func(h *AdminHandler) {
+		h.review = queue
+	}
+}
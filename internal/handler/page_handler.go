@@ -1,64 +1,333 @@
 package handler
 
 import (
+	"bytes"
+	"context"
 	"errors"
+	"fmt"
+	"go-wiki-app/internal/config"
 	"go-wiki-app/internal/data"
+	"go-wiki-app/internal/export"
 	"go-wiki-app/internal/logger"
 	"go-wiki-app/internal/middleware"
 	"go-wiki-app/internal/service"
 	"go-wiki-app/internal/view"
+	"hash/fnv"
+	"html/template"
+	"io"
+	"mime"
 	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 )
 
+// Permissions answers authorization questions for the current user.
+// It is satisfied by casbin.IEnforcer and can be mocked in tests.
+type Permissions interface {
+	Enforce(rvals ...interface{}) (bool, error)
+}
+
 // PageHandler holds the dependencies for the page handlers.
 type PageHandler struct {
 	pageService service.PageServicer
 	view        *view.View
 	log         logger.Logger
+	permissions Permissions
+	editor      config.EditorConfig
+	language    string
+	// slugTransliterate restricts slugs in export file names to ASCII.
+	slugTransliterate bool
+	pdf               export.PDFConverter
+	epub              export.EPUBWriter
+	maxBatchSize      int
+	highlightCSS      []byte
+	baseURL           string
+	// indexWelcomePage lets search engines index the welcome page shown in
+	// place of a missing Home page.
+	indexWelcomePage bool
+	// roles lists the roles editors may restrict pages to; nil hides the choice.
+	roles RoleLister
+	// idempotency keeps API responses for retries with the same Idempotency-Key,
+	// for idempotencyTTL; nil ignores the header.
+	idempotency    IdempotencyStore
+	idempotencyTTL time.Duration
+	// idempotencyInFlight holds the store keys of the requests being run, so
+	// a retry arriving before the first attempt finishes does not run again.
+	idempotencyInFlight sync.Map
 }
 
 // NewPageHandler creates a new PageHandler with the given dependencies.
-func NewPageHandler(ps service.PageServicer, v *view.View, log logger.Logger) *PageHandler {
-	return &PageHandler{
-		pageService: ps,
-		view:        v,
-		log:         log,
+func NewPageHandler(ps service.PageServicer, v *view.View, log logger.Logger, perms Permissions, opts ...Option) *PageHandler {
+	h := &PageHandler{
+		pageService:  ps,
+		view:         v,
+		log:          log,
+		permissions:  perms,
+		editor:       config.DefaultEditorConfig(),
+		language:     "en",
+		epub:         export.NewGoEPUB(),
+		maxBatchSize: defaultMaxBatchSize,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// logFor returns the logger for the request in ctx, tagged with its ID by
+// middleware.RequestLogger, or the handler's logger outside of a request.
+func (h *PageHandler) logFor(ctx context.Context) logger.Logger {
+	if log := logger.FromContext(ctx); log != nil {
+		return log
+	}
+	return h.log
+}
+
+// can reports whether the user in ctx may perform method on path.
+// Without a permissions checker everything is allowed, mirroring a wiki with no policies.
+func (h *PageHandler) can(ctx context.Context, path, method string) bool {
+	if h.permissions == nil {
+		return true
+	}
+	subject := middleware.GetUserInfo(ctx).Subject
+	allowed, err := h.permissions.Enforce(subject, path, method)
+	if err != nil {
+		h.logFor(ctx).Error(err, "Failed to check permissions")
+		return false
+	}
+	return allowed
+}
+
+// canView reports whether the current user is allowed to view the page with the given title.
+func (h *PageHandler) canView(r *http.Request, title string) bool {
+	return h.can(r.Context(), "/view/"+title, http.MethodGet)
+}
+
+// canSee reports whether the current user may see the page in views and listings.
+// Archived pages stay visible only to users who can edit them, and pages
+// restricted to a role only to users holding it.
+func (h *PageHandler) canSee(r *http.Request, page *data.Page) bool {
+	if !h.canView(r, page.Title) || !h.pageService.CanView(r.Context(), page, middleware.GetUserInfo(r.Context())) {
+		return false
+	}
+	return !page.IsArchived(time.Now()) || h.canEditPage(r, page)
+}
+
+// visiblePages returns the pages the current user may see, in their original order.
+func (h *PageHandler) visiblePages(r *http.Request, pages []*data.Page) []*data.Page {
+	visible := make([]*data.Page, 0, len(pages))
+	for _, page := range pages {
+		if h.canSee(r, page) {
+			visible = append(visible, page)
+		}
+	}
+	return visible
+}
+
+// canEdit reports whether the current user is allowed to edit the page with the given title.
+func (h *PageHandler) canEdit(r *http.Request, title string) bool {
+	return h.can(r.Context(), "/edit/"+title, http.MethodGet)
+}
+
+// canEditPage reports whether the current user may edit the page, either
+// through their roles or because they own it.
+func (h *PageHandler) canEditPage(r *http.Request, page *data.Page) bool {
+	if h.canEdit(r, page.Title) {
+		return true
+	}
+	subject := middleware.GetUserInfo(r.Context()).Subject
+	return page.OwnerSubject != "" && subject != "anonymous" && page.OwnerSubject == subject
+}
+
+// denyRestricted answers requests for a page restricted to a role the user
+// lacks: anonymous users are sent to log in and everyone else is refused. It
+// reports whether the request was answered.
+func (h *PageHandler) denyRestricted(w http.ResponseWriter, r *http.Request, page *data.Page) (bool, *middleware.AppError) {
+	userInfo := middleware.GetUserInfo(r.Context())
+	if h.pageService.CanView(r.Context(), page, userInfo) {
+		return false, nil
 	}
+	if userInfo.Subject == "anonymous" {
+		http.Redirect(w, r, "/auth/login", http.StatusFound)
+		return true, nil
+	}
+	return true, &middleware.AppError{Error: fmt.Errorf("page %q requires role %q", page.Title, page.RequiredRole), Message: "Forbidden", Code: http.StatusForbidden}
+}
+
+// knownRoles returns the roles pages may be restricted to, sorted by name.
+// Failures only hide the choice.
+func (h *PageHandler) knownRoles(ctx context.Context) []string {
+	if h.roles == nil {
+		return nil
+	}
+	roles, err := h.roles.GetAllRoles()
+	if err != nil {
+		h.logFor(ctx).Error(err, "Failed to list roles")
+		return nil
+	}
+	// Everyone holds the anonymous role, so it restricts nothing.
+	roles = slices.DeleteFunc(roles, func(role string) bool { return role == "anonymous" })
+	slices.Sort(roles)
+	return roles
 }
 
 // newTemplateData creates a map for template data and pre-populates it with common data.
-func newTemplateData(r *http.Request) map[string]interface{} {
+func (h *PageHandler) newTemplateData(r *http.Request) map[string]interface{} {
 	data := make(map[string]interface{})
+	data["Language"] = h.language
 	data["UserInfo"] = middleware.GetUserInfo(r.Context())
 	data["IsBasicMode"] = middleware.IsBasicMode(r.Context())
 	return data
 }
 
-// viewHandler handles requests to view a wiki page.
+// honeypotField is a form field hidden from people on the anonymous edit form.
+const honeypotField = "website"
+
+// titlePathTooDeepMessage explains why a path-style title was rejected.
+const titlePathTooDeepMessage = "Titles can have at most a category and a subcategory before the page name, e.g. API/Auth/Tokens."
+
+// invalidTitlePathMessage explains why a path-style title without a usable page name was rejected.
+const invalidTitlePathMessage = "Titles need a page name after the category, and the page name cannot be Home."
+
+// titlePathTakenMessage explains why a path-style title naming an existing page was rejected.
+const titlePathTakenMessage = "Another page already has this page name. Choose a different name."
+
+// viewHandler handles requests to view a wiki page. The Accept header selects
+// between the rendered page, its raw markdown source, and a JSON representation.
 func (h *PageHandler) viewHandler(w http.ResponseWriter, r *http.Request) *middleware.AppError {
 	title := chi.URLParam(r, "title")
-	templateData := newTemplateData(r)
+	format := negotiateFormat(r.Header.Get("Accept"))
+	w.Header().Add("Vary", "Accept")
 
 	page, err := h.pageService.ViewPage(r.Context(), title)
 	if err != nil {
-		if errors.Is(err, service.ErrAnonymousHome) {
+		if errors.Is(err, service.ErrAnonymousHome) && format == formatHTML {
+			// The welcome page is placeholder content until someone writes the Home page.
+			if !h.indexWelcomePage {
+				middleware.NoIndex(w)
+			}
+			templateData := h.newTemplateData(r)
 			if err := h.view.Render(w, r, "pages/welcome.html", templateData); err != nil {
 				return &middleware.AppError{Error: err, Message: "Failed to render welcome page", Code: http.StatusInternalServerError}
 			}
 			return nil
 		}
+		if appErr := renderAborted(w, err); appErr != nil {
+			return appErr
+		}
+		if !errors.Is(err, service.ErrPageNotFound) {
+			// The page may well exist, so it must not be offered for creation.
+			return &middleware.AppError{Error: err, Message: "Failed to load the page", Code: http.StatusInternalServerError}
+		}
+		if format == formatHTML && title != "Home" && h.canEdit(r, title) {
+			return h.renderCreatePrompt(w, r, title, err)
+		}
 		return &middleware.AppError{Error: err, Message: "Page not found", Code: http.StatusNotFound}
 	}
+	archived := page.IsArchived(time.Now())
+	if archived && !h.canEditPage(r, page) {
+		return &middleware.AppError{Error: fmt.Errorf("page %q is archived", title), Message: "Page not found", Code: http.StatusNotFound}
+	}
+	if denied, appErr := h.denyRestricted(w, r, page); denied {
+		return appErr
+	}
+
+	switch format {
+	case formatMarkdown:
+		return writePageMarkdown(w, page)
+	case formatJSON:
+		// Polling clients can revalidate with the ETag instead of downloading the page again.
+		etag := pageETag(page)
+		w.Header().Set("ETag", etag)
+		if etagMatches(r.Header.Get("If-None-Match"), etag, true) {
+			w.WriteHeader(http.StatusNotModified)
+			return nil
+		}
+		return writePageJSON(w, page)
+	}
 
+	templateData := h.newTemplateData(r)
+	if find := r.URL.Query().Get("find"); find != "" && len(find) <= maxFindLength {
+		highlighted, count := highlightMatches(page.HTMLContent, find)
+		page.HTMLContent = highlighted
+		templateData["Find"] = find
+		templateData["FindCount"] = count
+	}
 	templateData["Page"] = page
+	templateData["Metadata"] = h.metadataFields(page, true)
+	if page.ID != 0 {
+		templateData["Feeds"] = []feedLink{h.pageFeedLink(r, page.Title)}
+	}
+	templateData["CanEdit"] = h.canEditPage(r, page)
+	templateData["CanDelete"] = page.Title != "Home" && h.can(r.Context(), "/delete/"+page.Title, http.MethodPost)
+	if page.ID != 0 && page.Title != "Home" && h.can(r.Context(), "/move/"+page.Title, http.MethodPost) {
+		h.addMoveCategories(r, page, templateData)
+	}
+	templateData["Archived"] = archived
+	templateData["PendingReview"] = r.URL.Query().Get("pending") == "1"
+	// After a page is created, warn about near-duplicates without blocking the save.
+	if r.URL.Query().Get("similar") == "1" {
+		if similar, err := h.pageService.FindSimilarContent(r.Context(), page.Content); err == nil {
+			var others []*data.Page
+			for _, p := range h.visiblePages(r, similar) {
+				if p.ID != page.ID && p.Title != page.Title {
+					others = append(others, p)
+				}
+			}
+			templateData["SimilarPages"] = others
+		} else {
+			h.logFor(r.Context()).Error(err, "Failed to look up similar pages")
+		}
+	}
+	if page.ID != 0 {
+		templateData["CoEditedPages"] = h.coEditedPages(r, page.ID)
+	}
 	if err := h.view.Render(w, r, "pages/view.html", templateData); err != nil {
 		return &middleware.AppError{Error: err, Message: "Failed to render view", Code: http.StatusInternalServerError}
 	}
 	return nil
 }
 
+// renderCreatePrompt answers a request for a missing page with a 404 that
+// offers to create it. It is only used for users allowed to edit the page;
+// everyone else gets the plain error page.
+func (h *PageHandler) renderCreatePrompt(w http.ResponseWriter, r *http.Request, title string, err error) *middleware.AppError {
+	templateData := h.newTemplateData(r)
+	templateData["StatusCode"] = http.StatusNotFound
+	templateData["StatusText"] = "Page not found"
+	templateData["MissingTitle"] = title
+	templateData["CreateURL"] = "/edit/" + url.PathEscape(title)
+	var buf bytes.Buffer
+	if renderErr := h.view.Render(&buf, r, "pages/error.html", templateData); renderErr != nil {
+		return &middleware.AppError{Error: renderErr, Message: "Page not found", Code: http.StatusNotFound}
+	}
+	middleware.NoIndex(w)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusNotFound)
+	w.Write(buf.Bytes())
+	return nil
+}
+
+// coEditedLimit is the number of "often edited together" suggestions shown on a page.
+const coEditedLimit = 5
+
+// coEditedPages returns the visible pages often edited together with the given page.
+// Failures only hide the suggestions.
+func (h *PageHandler) coEditedPages(r *http.Request, pageID int64) []*data.Page {
+	pages, err := h.pageService.GetCoEditedPages(r.Context(), pageID, coEditedLimit)
+	if err != nil {
+		h.logFor(r.Context()).Error(err, "Failed to look up co-edited pages")
+		return nil
+	}
+	return h.visiblePages(r, pages)
+}
+
 // editHandler displays the form for editing a page.
 func (h *PageHandler) editHandler(w http.ResponseWriter, r *http.Request) *middleware.AppError {
 	title := chi.URLParam(r, "title")
@@ -73,20 +342,60 @@ func (h *PageHandler) editHandler(w http.ResponseWriter, r *http.Request) *middl
 		if errors.Is(err, service.ErrAnonymousHome) {
 			return &middleware.AppError{Error: err, Message: "Page not found", Code: http.StatusNotFound}
 		}
+		// A page that exists but could not be rendered must not be edited as a new one.
+		if appErr := renderAborted(w, err); appErr != nil {
+			return appErr
+		}
 		page = &data.Page{Title: title}
 	}
+	if denied, appErr := h.denyRestricted(w, r, page); denied {
+		return appErr
+	}
 
-	templateData := newTemplateData(r)
+	templateData := h.newTemplateData(r)
 	templateData["Page"] = page
+	templateData["EditorConfig"] = h.editorConfigFor(page.Title)
+	templateData["MetadataFields"] = h.metadataFields(page, false)
+	if middleware.GetUserInfo(r.Context()).Subject != "anonymous" {
+		templateData["Roles"] = h.knownRoles(r.Context())
+	}
+	if page.ID == 0 {
+		// A new page can be started from a template instead of a blank editor.
+		if templates, err := h.pageService.TemplateNames(r.Context()); err == nil {
+			templateData["Templates"] = templates
+		} else {
+			h.logFor(r.Context()).Error(err, "Failed to list page templates")
+		}
+	}
 	if err := h.view.Render(w, r, "pages/edit.html", templateData); err != nil {
 		return &middleware.AppError{Error: err, Message: "Failed to render edit page", Code: http.StatusInternalServerError}
 	}
 	return nil
 }
 
-// listHandler displays a list of all pages in the wiki.
+// listHandler displays a list of all pages in the wiki. Clients that prefer JSON
+// get the list a slice at a time instead (see listPagesJSON).
 func (h *PageHandler) listHandler(w http.ResponseWriter, r *http.Request) *middleware.AppError {
-	pages, err := h.pageService.GetAllPages(r.Context())
+	w.Header().Add("Vary", "Accept")
+	format := negotiateFormat(r.Header.Get("Accept"))
+	lastModified, count, err := h.pageService.PagesLastModified(r.Context())
+	if err != nil {
+		return &middleware.AppError{Error: err, Message: "Failed to retrieve pages", Code: http.StatusInternalServerError}
+	}
+	// The list shows only what the current user may see, so it differs between users.
+	userInfo := middleware.GetUserInfo(r.Context())
+	variant := format + "\x00" + userInfo.Subject + "\x00" + strings.Join(userInfo.Roles, ",")
+	if notModified(w, r, lastModified, pagesETag(lastModified, count, variant)) {
+		return nil
+	}
+	if format == formatJSON {
+		return h.listPagesJSON(w, r)
+	}
+	pageNum, appErr := pageNumber(r)
+	if appErr != nil {
+		return appErr
+	}
+	pages, total, err := h.pageService.GetAllPagesPaged(r.Context(), pageNum, listPerPage)
 	if err != nil {
 		return &middleware.AppError{Error: err, Message: "Failed to retrieve pages", Code: http.StatusInternalServerError}
 	}
@@ -94,15 +403,68 @@ func (h *PageHandler) listHandler(w http.ResponseWriter, r *http.Request) *middl
 	if err != nil {
 		return &middleware.AppError{Error: err, Message: "Failed to retrieve category tree", Code: http.StatusInternalServerError}
 	}
-	templateData := newTemplateData(r)
-	templateData["Pages"] = pages
+	templateData := h.newTemplateData(r)
+	templateData["Pages"] = h.visiblePages(r, pages)
 	templateData["CategoryTree"] = categoryTree
+	templateData["PageNum"] = pageNum
+	templateData["PageCount"] = (total + listPerPage - 1) / listPerPage
+	if pageNum > 1 {
+		templateData["PrevURL"] = listPageURL(pageNum - 1)
+	}
+	if pageNum*listPerPage < total {
+		templateData["NextURL"] = listPageURL(pageNum + 1)
+	}
 	if err := h.view.Render(w, r, "pages/list.html", templateData); err != nil {
 		return &middleware.AppError{Error: err, Message: "Failed to render list page", Code: http.StatusInternalServerError}
 	}
 	return nil
 }
 
+// renderBusyError answers a request for a page that was not rendered because
+// the server was already rendering as many pages as it may.
+func renderBusyError(w http.ResponseWriter, err error) *middleware.AppError {
+	w.Header().Set("Retry-After", "1")
+	return &middleware.AppError{Error: err, Message: "The wiki is busy. Please try again in a moment.", Code: http.StatusServiceUnavailable}
+}
+
+// statusClientClosedRequest is the nonstandard status, borrowed from nginx,
+// recorded for requests the client gave up on before they were answered.
+const statusClientClosedRequest = 499
+
+// renderAborted answers a request for a page that was not rendered because the
+// server was busy or the request ended while the render waited for a slot. It
+// returns nil for any other error, which the caller handles as before.
+func renderAborted(w http.ResponseWriter, err error) *middleware.AppError {
+	switch {
+	case errors.Is(err, service.ErrRenderBusy), errors.Is(err, context.DeadlineExceeded):
+		return renderBusyError(w, err)
+	case errors.Is(err, context.Canceled):
+		return &middleware.AppError{Error: err, Message: "Request cancelled", Code: statusClientClosedRequest}
+	}
+	return nil
+}
+
+// listPerPage is the number of pages shown per page on /list.
+const listPerPage = 50
+
+// listPageURL returns the /list URL of the given page of results.
+func listPageURL(page int) template.URL {
+	return template.URL("/list?page=" + strconv.Itoa(page))
+}
+
+// pageNumber reads the 1-based ?page= parameter of a paginated listing.
+func pageNumber(r *http.Request) (int, *middleware.AppError) {
+	p := r.URL.Query().Get("page")
+	if p == "" {
+		return 1, nil
+	}
+	n, err := strconv.Atoi(p)
+	if err != nil || n < 1 {
+		return 0, &middleware.AppError{Error: errors.New("invalid page number"), Message: "Invalid page number", Code: http.StatusBadRequest}
+	}
+	return n, nil
+}
+
 // searchCategoriesHandler handles API requests to search for categories.
 func (h *PageHandler) searchCategoriesHandler(w http.ResponseWriter, r *http.Request) *middleware.AppError {
 	query := r.URL.Query().Get("q")
@@ -110,7 +472,7 @@ func (h *PageHandler) searchCategoriesHandler(w http.ResponseWriter, r *http.Req
 	if err != nil {
 		return &middleware.AppError{Error: err, Message: "Failed to search for categories", Code: http.StatusInternalServerError}
 	}
-	templateData := newTemplateData(r)
+	templateData := h.newTemplateData(r)
 	templateData["Categories"] = categories
 	if err := h.view.Render(w, r, "pages/htmx/category_search_results.html", templateData); err != nil {
 		return &middleware.AppError{Error: err, Message: "Failed to render search results", Code: http.StatusInternalServerError}
@@ -118,6 +480,18 @@ func (h *PageHandler) searchCategoriesHandler(w http.ResponseWriter, r *http.Req
 	return nil
 }
 
+const (
+	// expiresField is the edit form field holding the date the page is archived on.
+	expiresField = "expires_at"
+	// expiresDateFormat is the format of expiresField; the page is archived at the start of that day, UTC.
+	expiresDateFormat = "2006-01-02"
+	// requiredRoleField is the edit form field holding the role the page is restricted to.
+	requiredRoleField = "required_role"
+	// extraCategoriesField is the edit form field holding the comma-separated
+	// paths of the other categories the page is tagged with.
+	extraCategoriesField = "extra_categories"
+)
+
 // saveHandler handles form submissions from the edit page.
 func (h *PageHandler) saveHandler(w http.ResponseWriter, r *http.Request) *middleware.AppError {
 	originalTitle := chi.URLParam(r, "title")
@@ -127,36 +501,193 @@ func (h *PageHandler) saveHandler(w http.ResponseWriter, r *http.Request) *middl
 	subcategory := r.FormValue("subcategory")
 	authorID := middleware.GetUserInfo(r.Context()).Subject
 
+	// Humans never see the honeypot field, so an anonymous save that fills it is a bot.
+	if authorID == "anonymous" && r.FormValue(honeypotField) != "" {
+		return &middleware.AppError{Error: errors.New("honeypot field filled"), Message: "Your edit could not be saved.", Code: http.StatusBadRequest}
+	}
+
 	// Server-side validation to prevent editing "Home" page
 	if originalTitle == "Home" || newTitle == "Home" {
 		return &middleware.AppError{Error: errors.New("home page is not editable"), Message: "The Home page cannot be edited.", Code: http.StatusForbidden}
 	}
 
-	page, err := h.pageService.ViewPage(r.Context(), originalTitle)
+	// Logged-in editors may schedule the page to be archived. The field is only
+	// applied when the form includes it, so other clients leave the expiry alone.
+	_, setExpiry := r.PostForm[expiresField]
+	setExpiry = setExpiry && authorID != "anonymous"
+	var expiresAt *time.Time
+	if setExpiry {
+		if value := r.PostForm.Get(expiresField); value != "" {
+			day, err := time.Parse(expiresDateFormat, value)
+			if err != nil {
+				return &middleware.AppError{Error: err, Message: "Invalid expiry date, expected YYYY-MM-DD", Code: http.StatusBadRequest}
+			}
+			expiresAt = &day
+		}
+	}
+
+	// Likewise, logged-in editors may restrict the page to one of the known roles.
+	_, setRole := r.PostForm[requiredRoleField]
+	setRole = setRole && authorID != "anonymous" && h.roles != nil
+	requiredRole := r.PostForm.Get(requiredRoleField)
+	if setRole && requiredRole != "" && !slices.Contains(h.knownRoles(r.Context()), requiredRole) {
+		return &middleware.AppError{Error: fmt.Errorf("unknown role %q", requiredRole), Message: "Unknown role", Code: http.StatusBadRequest}
+	}
+
+	// Path-style titles may be saved under a shorter title, so the redirect
+	// goes to the title the service actually saved.
+	var redirectURL string
+	// The page is looked up without rendering it, so a busy renderer cannot
+	// make an existing page look missing.
+	page, err := h.pageService.GetPage(r.Context(), originalTitle)
+	if err != nil && !errors.Is(err, service.ErrPageNotFound) {
+		return &middleware.AppError{Error: err, Message: "Failed to load the page", Code: http.StatusInternalServerError}
+	}
+	// Clients may send the ETag of the version they edited, so that a save fails
+	// instead of overwriting a change made since they read the page.
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" && (err != nil || !etagMatches(ifMatch, pageETag(page), false)) {
+		return &middleware.AppError{
+			Error:   fmt.Errorf("page %q does not match If-Match %s", originalTitle, ifMatch),
+			Message: "This page was changed since you last read it. Reload it and try again.",
+			Code:    http.StatusPreconditionFailed,
+		}
+	}
+	if err == nil {
+		// Editors without the page's role may neither overwrite it nor lift the restriction.
+		if denied, appErr := h.denyRestricted(w, r, page); denied {
+			return appErr
+		}
+	}
 	if err != nil {
-		// If the page does not exist (and it's not the special anonymous home case), create it.
-		if !errors.Is(err, service.ErrAnonymousHome) {
-			if _, createErr := h.pageService.CreatePage(r.Context(), newTitle, content, authorID, category, subcategory); createErr != nil {
-				return &middleware.AppError{Error: createErr, Message: "Failed to create page", Code: http.StatusInternalServerError}
+		// If the page does not exist, create it.
+		created, createErr := h.pageService.CreatePage(r.Context(), newTitle, content, authorID, category, subcategory)
+		if errors.Is(createErr, service.ErrPendingReview) {
+			// The page is only created once a moderator approves it.
+			return h.redirectAfterSave(w, r, "/view/"+created.Title+"?pending=1")
+		}
+		if createErr != nil {
+			if errors.Is(createErr, service.ErrWikiFull) {
+				return &middleware.AppError{Error: createErr, Message: "This wiki has reached its page or storage limit. Please contact an administrator.", Code: http.StatusInsufficientStorage}
 			}
-		} else {
-			// This case indicates trying to save a page from a state that shouldn't be possible (e.g., anonymous user on home).
-			return &middleware.AppError{Error: err, Message: "Cannot create page from this state", Code: http.StatusBadRequest}
+			if errors.Is(createErr, service.ErrPageInTrash) {
+				return &middleware.AppError{Error: createErr, Message: pageInTrashMessage, Code: http.StatusConflict}
+			}
+			if errors.Is(createErr, service.ErrQuotaExceeded) {
+				return &middleware.AppError{Error: createErr, Message: "You have created too many pages recently. Please try again later.", Code: http.StatusTooManyRequests}
+			}
+			if errors.Is(createErr, service.ErrTitlePathTooDeep) {
+				return &middleware.AppError{Error: createErr, Message: titlePathTooDeepMessage, Code: http.StatusBadRequest}
+			}
+			if errors.Is(createErr, service.ErrInvalidTitlePath) {
+				return &middleware.AppError{Error: createErr, Message: invalidTitlePathMessage, Code: http.StatusBadRequest}
+			}
+			if errors.Is(createErr, service.ErrTitlePathTaken) {
+				return &middleware.AppError{Error: createErr, Message: titlePathTakenMessage, Code: http.StatusConflict}
+			}
+			return &middleware.AppError{Error: createErr, Message: "Failed to create page", Code: http.StatusInternalServerError}
 		}
+		page = created
+		// Ask the view page to check for near-duplicates of the new content.
+		redirectURL = "/view/" + page.Title + "?similar=1"
 	} else {
 		// If the page exists, update it.
-		// The page object from ViewPage will have the ID we need.
-		if _, updateErr := h.pageService.UpdatePage(r.Context(), page.ID, newTitle, content, category, subcategory); updateErr != nil {
+		minor := r.FormValue("minor") != ""
+		updated, updateErr := h.pageService.UpdatePage(r.Context(), page.ID, newTitle, content, category, subcategory, minor)
+		if errors.Is(updateErr, service.ErrPendingReview) {
+			// The edit waits for a moderator. Metadata, expiry and extra category
+			// changes are not part of the review, so they are not applied either.
+			return h.redirectAfterSave(w, r, "/view/"+page.Title+"?pending=1")
+		}
+		if updateErr != nil {
+			if errors.Is(updateErr, service.ErrTitlePathTooDeep) {
+				return &middleware.AppError{Error: updateErr, Message: titlePathTooDeepMessage, Code: http.StatusBadRequest}
+			}
+			if errors.Is(updateErr, service.ErrInvalidTitlePath) {
+				return &middleware.AppError{Error: updateErr, Message: invalidTitlePathMessage, Code: http.StatusBadRequest}
+			}
+			if errors.Is(updateErr, service.ErrTitlePathTaken) {
+				return &middleware.AppError{Error: updateErr, Message: titlePathTakenMessage, Code: http.StatusConflict}
+			}
 			return &middleware.AppError{Error: updateErr, Message: "Failed to update page", Code: http.StatusInternalServerError}
 		}
+		redirectURL = "/view/" + updated.Title
+	}
+
+	if metadata := h.metadataFromForm(r); len(metadata) > 0 {
+		if err := h.pageService.SetPageMetadata(r.Context(), page.ID, metadata); err != nil {
+			if errors.Is(err, service.ErrUnknownMetadataKey) {
+				return &middleware.AppError{Error: err, Message: "Unknown metadata field", Code: http.StatusBadRequest}
+			}
+			return &middleware.AppError{Error: err, Message: "Failed to save page metadata", Code: http.StatusInternalServerError}
+		}
+	}
+
+	if setExpiry {
+		if err := h.pageService.SetPageExpiry(r.Context(), page.ID, expiresAt); err != nil {
+			return &middleware.AppError{Error: err, Message: "Failed to save page expiry", Code: http.StatusInternalServerError}
+		}
+	}
+
+	if setRole {
+		if err := h.pageService.SetPageRequiredRole(r.Context(), page.ID, requiredRole); err != nil {
+			return &middleware.AppError{Error: err, Message: "Failed to save page access", Code: http.StatusInternalServerError}
+		}
+	}
+
+	if _, ok := r.PostForm[extraCategoriesField]; ok {
+		paths := strings.Split(r.PostForm.Get(extraCategoriesField), ",")
+		if err := h.pageService.SetExtraCategories(r.Context(), page.ID, paths); err != nil {
+			return &middleware.AppError{Error: err, Message: "Failed to save page categories", Code: http.StatusInternalServerError}
+		}
 	}
 
+	return h.redirectAfterSave(w, r, redirectURL)
+}
+
+// redirectAfterSave sends the editor to redirectURL, through htmx when the
+// form was submitted with it.
+func (h *PageHandler) redirectAfterSave(w http.ResponseWriter, r *http.Request, redirectURL string) *middleware.AppError {
 	if r.Header.Get("HX-Request") == "true" && !middleware.IsBasicMode(r.Context()) {
-		w.Header().Set("HX-Redirect", "/view/"+newTitle)
+		w.Header().Set("HX-Redirect", redirectURL)
 		return nil
 	}
+	http.Redirect(w, r, redirectURL, http.StatusFound)
+	return nil
+}
+
+// createFromTemplateHandler creates a page from the template named in the form
+// and opens it in the editor.
+func (h *PageHandler) createFromTemplateHandler(w http.ResponseWriter, r *http.Request) *middleware.AppError {
+	title := chi.URLParam(r, "title")
+	if title == "Home" {
+		return &middleware.AppError{Error: errors.New("home page is not editable"), Message: "The Home page cannot be edited.", Code: http.StatusForbidden}
+	}
+	if _, err := h.pageService.GetPage(r.Context(), title); err == nil {
+		return &middleware.AppError{Error: fmt.Errorf("page %q already exists", title), Message: "A page with this title already exists", Code: http.StatusConflict}
+	} else if !errors.Is(err, service.ErrPageNotFound) {
+		return &middleware.AppError{Error: err, Message: "Failed to load the page", Code: http.StatusInternalServerError}
+	}
 
-	http.Redirect(w, r, "/view/"+newTitle, http.StatusFound)
+	authorID := middleware.GetUserInfo(r.Context()).Subject
+	if _, err := h.pageService.CreateFromTemplate(r.Context(), r.FormValue("template"), title, authorID); err != nil {
+		switch {
+		case errors.Is(err, service.ErrPendingReview):
+			http.Redirect(w, r, "/view/"+url.PathEscape(title)+"?pending=1", http.StatusSeeOther)
+			return nil
+		case errors.Is(err, service.ErrTemplateNotFound):
+			return &middleware.AppError{Error: err, Message: "Template not found", Code: http.StatusBadRequest}
+		case errors.Is(err, service.ErrUnknownTemplateVariable):
+			return &middleware.AppError{Error: err, Message: "The template uses an unknown variable", Code: http.StatusBadRequest}
+		case errors.Is(err, service.ErrWikiFull):
+			return &middleware.AppError{Error: err, Message: "This wiki has reached its page or storage limit. Please contact an administrator.", Code: http.StatusInsufficientStorage}
+		case errors.Is(err, service.ErrQuotaExceeded):
+			return &middleware.AppError{Error: err, Message: "You have created too many pages recently. Please try again later.", Code: http.StatusTooManyRequests}
+		case errors.Is(err, service.ErrPageInTrash):
+			return &middleware.AppError{Error: err, Message: pageInTrashMessage, Code: http.StatusConflict}
+		}
+		return &middleware.AppError{Error: err, Message: "Failed to create page", Code: http.StatusInternalServerError}
+	}
+	http.Redirect(w, r, "/edit/"+url.PathEscape(title), http.StatusSeeOther)
 	return nil
 }
 
@@ -166,9 +697,11 @@ func (h *PageHandler) viewByCategoryHandler(w http.ResponseWriter, r *http.Reque
 	if err != nil {
 		return &middleware.AppError{Error: err, Message: "Failed to get pages for category", Code: http.StatusNotFound}
 	}
-	templateData := newTemplateData(r)
+	templateData := h.newTemplateData(r)
 	templateData["Title"] = "Category: " + categoryName
-	templateData["Pages"] = pages
+	templateData["Pages"] = h.visiblePages(r, pages)
+	templateData["EPUBURL"] = "/category/" + url.PathEscape(categoryName) + "/export.epub"
+	templateData["Feeds"] = []feedLink{h.categoryFeedLink(r, categoryName)}
 	if err := h.view.Render(w, r, "pages/category_view.html", templateData); err != nil {
 		return &middleware.AppError{Error: err, Message: "Failed to render category view", Code: http.StatusInternalServerError}
 	}
@@ -180,26 +713,239 @@ func (h *PageHandler) categoriesHandler(w http.ResponseWriter, r *http.Request)
 	if err != nil {
 		return &middleware.AppError{Error: err, Message: "Failed to retrieve category tree", Code: http.StatusInternalServerError}
 	}
-	templateData := newTemplateData(r)
+	templateData := h.newTemplateData(r)
 	templateData["CategoryTree"] = categoryTree
+	canRename := h.can(r.Context(), "/categories/0/rename", http.MethodPost)
+	canDelete := h.can(r.Context(), "/categories/0", http.MethodDelete)
+	templateData["CanRenameCategories"] = canRename
+	templateData["CanDeleteCategories"] = canDelete
+	if canRename || canDelete {
+		templateData["ManagedCategories"] = flattenCategoryTree(categoryTree)
+	}
 	if err := h.view.Render(w, r, "pages/categories.html", templateData); err != nil {
 		return &middleware.AppError{Error: err, Message: "Failed to render categories page", Code: http.StatusInternalServerError}
 	}
 	return nil
 }
 
+// addMoveCategories offers the existing categories as destinations for the
+// page, with its current category selected.
+func (h *PageHandler) addMoveCategories(r *http.Request, page *data.Page, templateData map[string]interface{}) {
+	categoryTree, err := h.pageService.GetCategoryTree(r.Context())
+	if err != nil {
+		h.logFor(r.Context()).Error(err, "Failed to retrieve categories to move the page to")
+		return
+	}
+	templateData["MoveCategories"] = flattenCategoryTree(categoryTree)
+	if n := len(page.Breadcrumb); n > 0 {
+		templateData["CategoryPath"] = page.Breadcrumb[n-1].Path
+	}
+}
+
+// flattenCategoryTree lists the categories of the tree depth-first, each
+// before its subcategories.
+func flattenCategoryTree(nodes []*service.CategoryNode) []*service.CategoryNode {
+	var flat []*service.CategoryNode
+	for _, node := range nodes {
+		flat = append(flat, node)
+		flat = append(flat, flattenCategoryTree(node.Children)...)
+	}
+	return flat
+}
+
+// renameCategoryHandler renames a category to the value of the "name" form field.
+func (h *PageHandler) renameCategoryHandler(w http.ResponseWriter, r *http.Request) *middleware.AppError {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		return &middleware.AppError{Error: err, Message: "Invalid category ID", Code: http.StatusBadRequest}
+	}
+	name := r.FormValue("name")
+	if err := h.pageService.RenameCategory(r.Context(), id, name); err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidCategoryName):
+			return &middleware.AppError{Error: err, Message: "Category names cannot be empty or contain a slash", Code: http.StatusBadRequest}
+		case errors.Is(err, service.ErrCategoryNotFound):
+			return &middleware.AppError{Error: err, Message: "Category not found", Code: http.StatusNotFound}
+		case errors.Is(err, data.ErrCategoryExists):
+			return &middleware.AppError{Error: err, Message: "A category with this name already exists here", Code: http.StatusConflict}
+		}
+		return &middleware.AppError{Error: err, Message: "Failed to rename the category", Code: http.StatusInternalServerError}
+	}
+	h.logFor(r.Context()).Info(fmt.Sprintf("%s renamed category %d to %q", middleware.GetUserInfo(r.Context()).Subject, id, name))
+	http.Redirect(w, r, "/categories", http.StatusSeeOther)
+	return nil
+}
+
+// maxDeleteFormBytes bounds the form-encoded body read from DELETE requests.
+const maxDeleteFormBytes = 64 << 10
+
+// deleteFormValue returns the named parameter of a DELETE request, from its
+// query string or from the form-encoded body htmx sends it in. net/http only
+// parses the bodies of POST, PUT and PATCH requests, so r.FormValue misses it.
+func deleteFormValue(w http.ResponseWriter, r *http.Request, key string) (string, error) {
+	if value := r.URL.Query().Get(key); value != "" {
+		return value, nil
+	}
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/x-www-form-urlencoded" {
+		return "", nil
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxDeleteFormBytes))
+	if err != nil {
+		return "", err
+	}
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		return "", err
+	}
+	return values.Get(key), nil
+}
+
+// deleteCategoryHandler deletes a category without subcategories. Its pages
+// move to the category in the "reassign_to" parameter, or to the NoCategory
+// default without one. htmx requests are sent back to /categories.
+func (h *PageHandler) deleteCategoryHandler(w http.ResponseWriter, r *http.Request) *middleware.AppError {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		return &middleware.AppError{Error: err, Message: "Invalid category ID", Code: http.StatusBadRequest}
+	}
+	value, err := deleteFormValue(w, r, "reassign_to")
+	if err != nil {
+		return &middleware.AppError{Error: err, Message: "Invalid form", Code: http.StatusBadRequest}
+	}
+	var reassignToID *int64
+	if value != "" {
+		targetID, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return &middleware.AppError{Error: err, Message: "Invalid target category", Code: http.StatusBadRequest}
+		}
+		reassignToID = &targetID
+	}
+	if err := h.pageService.DeleteCategory(r.Context(), id, reassignToID); err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidCategoryDelete):
+			return &middleware.AppError{Error: err, Message: "A category's pages cannot be moved to the category itself", Code: http.StatusBadRequest}
+		case errors.Is(err, service.ErrCategoryNotFound):
+			return &middleware.AppError{Error: err, Message: "Category not found", Code: http.StatusNotFound}
+		case errors.Is(err, data.ErrCategoryHasSubcategories):
+			return &middleware.AppError{Error: err, Message: "Delete or merge the category's subcategories first", Code: http.StatusConflict}
+		}
+		return &middleware.AppError{Error: err, Message: "Failed to delete the category", Code: http.StatusInternalServerError}
+	}
+	h.logFor(r.Context()).Info(fmt.Sprintf("%s deleted category %d", middleware.GetUserInfo(r.Context()).Subject, id))
+	if r.Header.Get("HX-Request") == "true" {
+		w.Header().Set("HX-Redirect", "/categories")
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// movePageHandler files a page under the category in the "category" form
+// field, and the optional "subcategory" field below it, leaving its content
+// and history as they are.
+func (h *PageHandler) movePageHandler(w http.ResponseWriter, r *http.Request) *middleware.AppError {
+	title := chi.URLParam(r, "title")
+	if title == "Home" {
+		return &middleware.AppError{Error: errors.New("home page cannot be moved"), Message: "The Home page cannot be moved.", Code: http.StatusForbidden}
+	}
+	// The page is only checked, so it is not rendered.
+	page, err := h.pageService.GetPage(r.Context(), title)
+	if errors.Is(err, service.ErrPageNotFound) {
+		return &middleware.AppError{Error: err, Message: "Page not found", Code: http.StatusNotFound}
+	}
+	if err != nil {
+		return &middleware.AppError{Error: err, Message: "Failed to load the page", Code: http.StatusInternalServerError}
+	}
+	if denied, appErr := h.denyRestricted(w, r, page); denied {
+		return appErr
+	}
+	if !h.canSee(r, page) {
+		return &middleware.AppError{Error: fmt.Errorf("page %q is hidden from the user", title), Message: "Page not found", Code: http.StatusNotFound}
+	}
+	category := strings.TrimSpace(r.FormValue("category"))
+	subcategory := strings.TrimSpace(r.FormValue("subcategory"))
+	if err := h.pageService.MovePage(r.Context(), title, category, subcategory); err != nil {
+		if errors.Is(err, service.ErrPendingReview) {
+			return h.redirectAfterSave(w, r, "/view/"+url.PathEscape(title)+"?pending=1")
+		}
+		return &middleware.AppError{Error: err, Message: "Failed to move the page", Code: http.StatusInternalServerError}
+	}
+	h.logFor(r.Context()).Info(fmt.Sprintf("%s moved %s to category %q", middleware.GetUserInfo(r.Context()).Subject, title, strings.Trim(category+"/"+subcategory, "/")))
+	return h.redirectAfterSave(w, r, "/view/"+url.PathEscape(title))
+}
+
 func (h *PageHandler) viewBySubcategoryHandler(w http.ResponseWriter, r *http.Request) *middleware.AppError {
 	categoryName := chi.URLParam(r, "categoryName")
-	subcategoryName := chi.URLParam(r, "subcategoryName")
+	subcategoryName := chi.URLParam(r, "*")
 	pages, err := h.pageService.GetPagesForSubcategory(r.Context(), categoryName, subcategoryName)
 	if err != nil {
 		return &middleware.AppError{Error: err, Message: "Failed to get pages for subcategory", Code: http.StatusNotFound}
 	}
-	templateData := newTemplateData(r)
-	templateData["Title"] = "Category: " + categoryName + " / " + subcategoryName
-	templateData["Pages"] = pages
+	templateData := h.newTemplateData(r)
+	templateData["Title"] = "Category: " + categoryName + " / " + strings.ReplaceAll(strings.Trim(subcategoryName, "/"), "/", " / ")
+	templateData["Pages"] = h.visiblePages(r, pages)
 	if err := h.view.Render(w, r, "pages/category_view.html", templateData); err != nil {
 		return &middleware.AppError{Error: err, Message: "Failed to render category view", Code: http.StatusInternalServerError}
 	}
 	return nil
 }
+
+// stubsHandler lists the pages that are short enough to be considered stubs.
+func (h *PageHandler) stubsHandler(w http.ResponseWriter, r *http.Request) *middleware.AppError {
+	stubs, err := h.pageService.GetStubs(r.Context())
+	if err != nil {
+		return &middleware.AppError{Error: err, Message: "Failed to retrieve stubs", Code: http.StatusInternalServerError}
+	}
+	templateData := h.newTemplateData(r)
+	templateData["Pages"] = h.visiblePages(r, stubs)
+	if err := h.view.Render(w, r, "pages/stubs.html", templateData); err != nil {
+		return &middleware.AppError{Error: err, Message: "Failed to render stubs page", Code: http.StatusInternalServerError}
+	}
+	return nil
+}
+
+// myPagesLimit is the maximum number of pages listed on /my/pages.
+const myPagesLimit = 100
+
+// myPagesHandler lists the pages the current user created or edited, most recent first.
+// Anonymous users are asked to log in instead.
+func (h *PageHandler) myPagesHandler(w http.ResponseWriter, r *http.Request) *middleware.AppError {
+	templateData := h.newTemplateData(r)
+	if subject := middleware.GetUserInfo(r.Context()).Subject; subject != "anonymous" {
+		pages, err := h.pageService.GetPagesEditedBy(r.Context(), subject, myPagesLimit)
+		if err != nil {
+			return &middleware.AppError{Error: err, Message: "Failed to retrieve your pages", Code: http.StatusInternalServerError}
+		}
+		templateData["Pages"] = h.visiblePages(r, pages)
+	}
+	if err := h.view.Render(w, r, "pages/my_pages.html", templateData); err != nil {
+		return &middleware.AppError{Error: err, Message: "Failed to render your pages", Code: http.StatusInternalServerError}
+	}
+	return nil
+}
+
+// archivedHandler lists the pages that have passed their expiry date, for editors
+// to review, restore or delete.
+func (h *PageHandler) archivedHandler(w http.ResponseWriter, r *http.Request) *middleware.AppError {
+	pages, err := h.pageService.GetArchivedPages(r.Context())
+	if err != nil {
+		return &middleware.AppError{Error: err, Message: "Failed to retrieve archived pages", Code: http.StatusInternalServerError}
+	}
+	templateData := h.newTemplateData(r)
+	templateData["Pages"] = h.visiblePages(r, pages)
+	if err := h.view.Render(w, r, "pages/archived.html", templateData); err != nil {
+		return &middleware.AppError{Error: err, Message: "Failed to render archived pages", Code: http.StatusInternalServerError}
+	}
+	return nil
+}
+
+// highlightCSSHandler serves the stylesheet that colours highlighted code blocks.
+// It is built from the configured theme at startup, so it is revalidated by
+// ETag rather than cached for long like the other static assets.
+func (h *PageHandler) highlightCSSHandler(w http.ResponseWriter, r *http.Request) {
+	sum := fnv.New32a()
+	sum.Write(h.highlightCSS)
+	w.Header().Set("ETag", fmt.Sprintf(`"%x"`, sum.Sum32()))
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Content-Type", "text/css; charset=utf-8")
+	http.ServeContent(w, r, "highlight.css", time.Time{}, bytes.NewReader(h.highlightCSS))
+}
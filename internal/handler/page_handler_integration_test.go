@@ -3,7 +3,10 @@
 package handler
 
 import (
+	"compress/gzip"
 	"context"
+	"encoding/json"
+	"fmt"
 	"go-wiki-app/internal/auth"
 	"go-wiki-app/internal/cache"
 	"go-wiki-app/internal/config"
@@ -56,7 +59,12 @@ func TestMain(m *testing.M) {
 		author_id TEXT NOT NULL,
 		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
 		updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
-		category_id INTEGER
+		category_id INTEGER,
+		search_text TEXT,
+		expires_at DATETIME,
+		owner_subject TEXT NOT NULL DEFAULT '',
+		deleted_at DATETIME,
+		trusted_html BOOLEAN NOT NULL DEFAULT FALSE
 	);`
 	db.MustExec(pagesSchema)
 
@@ -65,11 +73,48 @@ func TestMain(m *testing.M) {
 		id INTEGER PRIMARY KEY,
 		name TEXT NOT NULL,
 		parent_id INTEGER,
+		search_name TEXT,
+		order_weight INTEGER NOT NULL DEFAULT 0,
 		FOREIGN KEY (parent_id) REFERENCES categories(id) ON DELETE CASCADE,
 		UNIQUE (name, parent_id)
 	);`
 	db.MustExec(categoriesSchema)
 
+	pageCategoriesSchema := `
+	CREATE TABLE page_categories (
+		page_id INTEGER NOT NULL,
+		category_id INTEGER NOT NULL,
+		PRIMARY KEY (page_id, category_id)
+	);`
+	db.MustExec(pageCategoriesSchema)
+
+	activitySchema := `
+	CREATE TABLE activity (
+		id INTEGER PRIMARY KEY,
+		page_id INTEGER,
+		page_title TEXT NOT NULL,
+		action TEXT NOT NULL,
+		author_id TEXT NOT NULL,
+		author_ip TEXT NOT NULL DEFAULT '',
+		minor BOOLEAN NOT NULL DEFAULT FALSE,
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);`
+	db.MustExec(activitySchema)
+
+	revisionsSchema := `
+	CREATE TABLE revisions (
+		id INTEGER PRIMARY KEY,
+		page_id INTEGER NOT NULL,
+		title TEXT NOT NULL,
+		content TEXT NOT NULL,
+		author_id TEXT NOT NULL,
+		author_ip TEXT NOT NULL DEFAULT '',
+		minor BOOLEAN NOT NULL DEFAULT FALSE,
+		trusted_html BOOLEAN NOT NULL DEFAULT FALSE,
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);`
+	db.MustExec(revisionsSchema)
+
 	casbinSchema, _ := os.ReadFile("../../migrations/002_create_casbin_rule_table.up.sql")
 	db.MustExec(string(casbinSchema))
 	sessionsSchema, _ := os.ReadFile("../../migrations/003_create_sessions_table.up.sql")
@@ -81,19 +126,25 @@ func TestMain(m *testing.M) {
 
 	pageRepository := data.NewSQLPageRepository(db)
 	categoryRepository := data.NewCategoryRepository(db)
-	pageService := service.NewPageService(pageRepository, categoryRepository, testCache)
+	pageService := service.NewPageService(pageRepository, categoryRepository, testCache,
+		service.WithRevisions(data.NewSQLRevisionRepository(db)),
+	)
 
 	sessionManager := scs.New()
 	sessionManager.Store = sqlite3store.New(db.DB)
 	sessionManager.Lifetime = 3 * time.Minute
 
-	pageHandler := NewPageHandler(pageService, viewService, log)
-	seoHandler := NewSeoHandler(pageService)
-
 	enforcer, _ := auth.NewEnforcer("sqlite3", dsn, "../../auth_model.conf")
-	authzMiddleware := middleware.Authorizer(enforcer, sessionManager)
+
+	pageHandler := NewPageHandler(pageService, viewService, log, enforcer)
+	seoHandler := NewSeoHandler(pageService, config.SiteConfig{})
+	adminHandler := NewAdminHandler(pageService, viewService, log)
+
+	authzMiddleware := middleware.Authorizer(enforcer, sessionManager, pageService.PageOwner, nil)
 	errorMiddleware := middleware.Error(log, viewService)
-	router := NewRouter(pageHandler, nil, seoHandler, authzMiddleware, errorMiddleware, sessionManager)
+	sessionExpiryMiddleware := middleware.SessionExpiry(sessionManager, 0)
+	securityHeadersMiddleware := middleware.SecurityHeaders(config.DefaultContentSecurityPolicy, false)
+	router := NewRouter(pageHandler, nil, seoHandler, adminHandler, authzMiddleware, errorMiddleware, sessionExpiryMiddleware, securityHeadersMiddleware, middleware.RateLimit(0, 0), sessionManager)
 
 	testAppInstance = &testApp{
 		Router:         router,
@@ -113,6 +164,17 @@ func TestMain(m *testing.M) {
 
 func getAuthenticatedCookie(t *testing.T) *http.Cookie {
 	t.Helper()
+	return getSessionCookie(t, "test-editor")
+}
+
+// testCSRFToken is the CSRF token in the sessions of getSessionCookie. POST
+// requests send it in the X-CSRF-Token header or the csrf_token form field.
+const testCSRFToken = "test-csrf-token"
+
+// getSessionCookie returns a session cookie for a user with the given subject,
+// or for an anonymous visitor if the subject is empty.
+func getSessionCookie(t *testing.T, subject string) *http.Cookie {
+	t.Helper()
 
 	var cookie *http.Cookie
 
@@ -120,7 +182,10 @@ func getAuthenticatedCookie(t *testing.T) *http.Cookie {
 	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
 		// The Authorizer middleware uses "user_subject" from the session
-		testAppInstance.SessionManager.Put(ctx, "user_subject", "test-editor")
+		if subject != "" {
+			testAppInstance.SessionManager.Put(ctx, "user_subject", subject)
+		}
+		testAppInstance.SessionManager.Put(ctx, middleware.CSRFTokenKey, testCSRFToken)
 		w.WriteHeader(http.StatusOK)
 	})
 
@@ -160,6 +225,7 @@ func TestSavePage_WithCategories_Integration(t *testing.T) {
 
 	req := httptest.NewRequest("POST", "/save/NewCategorizedPage", strings.NewReader(form.Encode()))
 	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set(middleware.CSRFHeader, testCSRFToken)
 	req.AddCookie(cookie)
 
 	rr := httptest.NewRecorder()
@@ -185,3 +251,691 @@ func TestSavePage_WithCategories_Integration(t *testing.T) {
 		t.Errorf("Expected subcategory name 'Passing', got '%s'", subCategory.Name)
 	}
 }
+
+func TestSavePage_GetReturnsMethodNotAllowed_Integration(t *testing.T) {
+	req := httptest.NewRequest("GET", "/save/SomePage", nil)
+	rr := httptest.NewRecorder()
+	testAppInstance.Router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("want status %d; got %d", http.StatusMethodNotAllowed, rr.Code)
+	}
+	if allow := rr.Header().Get("Allow"); allow != "POST, OPTIONS" {
+		t.Errorf("want Allow header %q; got %q", "POST, OPTIONS", allow)
+	}
+	if !strings.Contains(rr.Body.String(), "Method Not Allowed") || !strings.Contains(rr.Body.String(), "<html") {
+		t.Errorf("expected the styled error page, got %q", rr.Body.String())
+	}
+}
+
+func TestNotFound_NoIndex_Integration(t *testing.T) {
+	auth.SeedDefaultPolicies(testAppInstance.Enforcer, logger.New(config.LogConfig{Level: "error"}), false)
+	for _, path := range []string{"/view/NoSuchPage", "/no/such/route"} {
+		rr := httptest.NewRecorder()
+		testAppInstance.Router.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("%s: want status %d; got %d", path, http.StatusNotFound, rr.Code)
+		}
+		if got := rr.Header().Get("X-Robots-Tag"); got != "noindex" {
+			t.Errorf("%s: want X-Robots-Tag %q; got %q", path, "noindex", got)
+		}
+		if !strings.Contains(rr.Body.String(), "<html") {
+			t.Errorf("%s: expected the styled error page, got %q", path, rr.Body.String())
+		}
+	}
+}
+
+func TestOptions_AdvertisesAllowedMethods_Integration(t *testing.T) {
+	req := httptest.NewRequest("OPTIONS", "/view/Home", nil)
+	rr := httptest.NewRecorder()
+	testAppInstance.Router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("want status %d; got %d", http.StatusNoContent, rr.Code)
+	}
+	if allow := rr.Header().Get("Allow"); allow != "GET, OPTIONS" {
+		t.Errorf("want Allow header %q; got %q", "GET, OPTIONS", allow)
+	}
+}
+
+func TestAdminDashboard_RequiresAdmin_Integration(t *testing.T) {
+	auth.SeedDefaultPolicies(testAppInstance.Enforcer, logger.New(config.LogConfig{Level: "error"}), false)
+	testAppInstance.Enforcer.AddRoleForUser("test-editor", "editor")
+	testAppInstance.Enforcer.AddRoleForUser("test-admin", "admin")
+
+	tests := []struct {
+		name     string
+		subject  string
+		wantCode int
+	}{
+		{"editor is denied", "test-editor", http.StatusForbidden},
+		{"admin is allowed", "test-admin", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/admin", nil)
+			req.AddCookie(getSessionCookie(t, tt.subject))
+			rr := httptest.NewRecorder()
+			testAppInstance.Router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantCode {
+				t.Fatalf("want status %d; got %d", tt.wantCode, rr.Code)
+			}
+			if tt.wantCode == http.StatusOK && !strings.Contains(rr.Body.String(), "Dashboard") {
+				t.Errorf("expected the dashboard, got %q", rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestSavePage_AnonymousEdit_Integration(t *testing.T) {
+	log := logger.New(config.LogConfig{Level: "error"})
+	defer auth.SeedDefaultPolicies(testAppInstance.Enforcer, log, false)
+
+	// Anonymous visitors get a session for the CSRF token of the edit form,
+	// which is posted without HTMX like in basic mode.
+	anonymous := getSessionCookie(t, "")
+	save := func(title string, form url.Values) *httptest.ResponseRecorder {
+		form.Set("title", title)
+		form.Set("content", "Open wiki content")
+		form.Set(middleware.CSRFField, testCSRFToken)
+		req := httptest.NewRequest("POST", "/save/"+title, strings.NewReader(form.Encode()))
+		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(anonymous)
+		rr := httptest.NewRecorder()
+		testAppInstance.Router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("denied when disabled", func(t *testing.T) {
+		auth.SeedDefaultPolicies(testAppInstance.Enforcer, log, false)
+		if rr := save("AnonDenied", url.Values{}); rr.Code != http.StatusForbidden {
+			t.Errorf("want status %d; got %d", http.StatusForbidden, rr.Code)
+		}
+	})
+
+	t.Run("succeeds when enabled", func(t *testing.T) {
+		auth.SeedDefaultPolicies(testAppInstance.Enforcer, log, true)
+		if rr := save("AnonAllowed", url.Values{}); rr.Code != http.StatusFound {
+			t.Fatalf("want status %d; got %d", http.StatusFound, rr.Code)
+		}
+
+		page, err := testAppInstance.PageRepo.GetPageByTitle(context.Background(), "AnonAllowed")
+		if err != nil {
+			t.Fatalf("failed to retrieve saved page: %v", err)
+		}
+		if page.AuthorID != "anonymous" {
+			t.Errorf("want author %q; got %q", "anonymous", page.AuthorID)
+		}
+		var ip string
+		testAppInstance.DB.Get(&ip, "SELECT author_ip FROM activity WHERE page_title = ?", "AnonAllowed")
+		if ip != "192.0.2.1" {
+			t.Errorf("want the client IP recorded with the activity; got %q", ip)
+		}
+		var revisions int
+		testAppInstance.DB.Get(&revisions, "SELECT COUNT(*) FROM revisions WHERE page_id = ? AND author_id = 'anonymous'", page.ID)
+		if revisions != 1 {
+			t.Errorf("want 1 anonymous revision; got %d", revisions)
+		}
+	})
+
+	t.Run("honeypot rejects bots", func(t *testing.T) {
+		auth.SeedDefaultPolicies(testAppInstance.Enforcer, log, true)
+		if rr := save("AnonBot", url.Values{"website": {"http://spam.example"}}); rr.Code != http.StatusBadRequest {
+			t.Errorf("want status %d; got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+}
+
+func TestAdminPruneRevisions_Integration(t *testing.T) {
+	auth.SeedDefaultPolicies(testAppInstance.Enforcer, logger.New(config.LogConfig{Level: "error"}), false)
+	testAppInstance.Enforcer.AddRoleForUser("test-admin", "admin")
+
+	req := httptest.NewRequest("POST", "/admin/revisions/prune", nil)
+	req.Header.Set(middleware.CSRFHeader, testCSRFToken)
+	req.AddCookie(getSessionCookie(t, "test-admin"))
+	rr := httptest.NewRecorder()
+	testAppInstance.Router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusSeeOther {
+		t.Fatalf("want status %d; got %d", http.StatusSeeOther, rr.Code)
+	}
+	if loc := rr.Header().Get("Location"); loc != "/admin?pruned=0" {
+		t.Errorf("want redirect to the dashboard with the pruned count; got %q", loc)
+	}
+}
+
+func TestArchivedPage_Integration(t *testing.T) {
+	auth.SeedDefaultPolicies(testAppInstance.Enforcer, logger.New(config.LogConfig{Level: "error"}), false)
+	testAppInstance.Enforcer.AddRoleForUser("test-editor", "editor")
+	ctx := context.Background()
+
+	page := &data.Page{Title: "ExpiredNotice", Content: "Old announcement", AuthorID: "test-editor"}
+	if err := testAppInstance.PageRepo.CreatePage(ctx, page); err != nil {
+		t.Fatalf("failed to create page: %v", err)
+	}
+	expired := time.Now().Add(-24 * time.Hour)
+	if err := testAppInstance.PageRepo.SetPageExpiry(ctx, page.ID, &expired); err != nil {
+		t.Fatalf("failed to set expiry: %v", err)
+	}
+
+	get := func(path string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rr := httptest.NewRecorder()
+		testAppInstance.Router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("hidden from anonymous users", func(t *testing.T) {
+		if rr := get("/view/ExpiredNotice", nil); rr.Code != http.StatusNotFound {
+			t.Errorf("want status %d; got %d", http.StatusNotFound, rr.Code)
+		}
+		if rr := get("/search?q=announcement", nil); strings.Contains(rr.Body.String(), "ExpiredNotice") {
+			t.Error("want the archived page left out of search results")
+		}
+		if rr := get("/archived", nil); rr.Code != http.StatusForbidden {
+			t.Errorf("want /archived forbidden to anonymous users; got %d", rr.Code)
+		}
+	})
+
+	t.Run("visible with a banner to editors", func(t *testing.T) {
+		cookie := getAuthenticatedCookie(t)
+		rr := get("/view/ExpiredNotice", cookie)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("want status %d; got %d", http.StatusOK, rr.Code)
+		}
+		if !strings.Contains(rr.Body.String(), "archived-banner") {
+			t.Error("want an archived banner for editors")
+		}
+		rr = get("/archived", cookie)
+		if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "/view/ExpiredNotice") {
+			t.Errorf("want the page listed on /archived; got status %d", rr.Code)
+		}
+	})
+}
+
+func TestPageOwner_CanEditOwnPage_Integration(t *testing.T) {
+	auth.SeedDefaultPolicies(testAppInstance.Enforcer, logger.New(config.LogConfig{Level: "error"}), false)
+	ctx := context.Background()
+
+	// "page-owner" has no roles; only ownership lets them edit.
+	owned := &data.Page{Title: "OwnedNotes", Content: "Mine", AuthorID: "someone-else", OwnerSubject: "page-owner"}
+	other := &data.Page{Title: "OtherNotes", Content: "Not mine", AuthorID: "someone-else", OwnerSubject: "someone-else"}
+	for _, page := range []*data.Page{owned, other} {
+		if err := testAppInstance.PageRepo.CreatePage(ctx, page); err != nil {
+			t.Fatalf("failed to create page: %v", err)
+		}
+	}
+	cookie := getSessionCookie(t, "page-owner")
+
+	edit := func(title string) int {
+		req := httptest.NewRequest("GET", "/edit/"+title, nil)
+		req.AddCookie(cookie)
+		rr := httptest.NewRecorder()
+		testAppInstance.Router.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	if code := edit("OwnedNotes"); code != http.StatusOK {
+		t.Errorf("want owner to edit their page with status %d; got %d", http.StatusOK, code)
+	}
+	if code := edit("OtherNotes"); code != http.StatusForbidden {
+		t.Errorf("want status %d editing someone else's page; got %d", http.StatusForbidden, code)
+	}
+}
+
+func TestRollback_Integration(t *testing.T) {
+	auth.SeedDefaultPolicies(testAppInstance.Enforcer, logger.New(config.LogConfig{Level: "error"}), false)
+	testAppInstance.Enforcer.AddRoleForUser("test-editor", "editor")
+	ctx := context.Background()
+	revisionRepo := data.NewSQLRevisionRepository(testAppInstance.DB)
+
+	page := &data.Page{Title: "RollbackGuide", Content: "second draft", AuthorID: "test-editor"}
+	if err := testAppInstance.PageRepo.CreatePage(ctx, page); err != nil {
+		t.Fatalf("failed to create page: %v", err)
+	}
+	first := &data.Revision{PageID: page.ID, Title: page.Title, Content: "first draft", AuthorID: "test-editor", CreatedAt: time.Now().Add(-time.Hour)}
+	second := &data.Revision{PageID: page.ID, Title: page.Title, Content: "second draft", AuthorID: "test-editor", CreatedAt: time.Now().Add(-time.Minute)}
+	foreign := &data.Revision{PageID: page.ID + 1000, Title: "Elsewhere", Content: "other page", AuthorID: "test-editor"}
+	for _, revision := range []*data.Revision{first, second, foreign} {
+		if err := revisionRepo.CreateRevision(ctx, revision); err != nil {
+			t.Fatalf("failed to create revision: %v", err)
+		}
+	}
+	cookie := getAuthenticatedCookie(t)
+
+	rollback := func(revisionID, base int64) *httptest.ResponseRecorder {
+		form := url.Values{"base": {fmt.Sprint(base)}}
+		req := httptest.NewRequest("POST", fmt.Sprintf("/rollback/RollbackGuide/%d", revisionID), strings.NewReader(form.Encode()))
+		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set(middleware.CSRFHeader, testCSRFToken)
+		req.AddCookie(cookie)
+		rr := httptest.NewRecorder()
+		testAppInstance.Router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := rollback(foreign.ID, second.ID); rr.Code != http.StatusNotFound {
+		t.Errorf("want status %d for a revision of another page; got %d", http.StatusNotFound, rr.Code)
+	}
+	if rr := rollback(first.ID, first.ID); rr.Code != http.StatusConflict {
+		t.Errorf("want status %d when the page changed since the history was shown; got %d", http.StatusConflict, rr.Code)
+	}
+	if rr := rollback(first.ID, second.ID); rr.Code != http.StatusSeeOther {
+		t.Fatalf("want status %d; got %d", http.StatusSeeOther, rr.Code)
+	}
+
+	restored, err := testAppInstance.PageRepo.GetPageByID(ctx, page.ID)
+	if err != nil || restored.Content != "first draft" {
+		t.Fatalf("want the first draft restored; got %+v (err %v)", restored, err)
+	}
+	history, _ := revisionRepo.GetRevisions(ctx, page.ID)
+	if len(history) != 3 || history[0].Content != "first draft" {
+		t.Errorf("want the restore saved as a third revision, keeping the others; got %d revisions", len(history))
+	}
+}
+
+func TestViewPage_CompressesJSON_Integration(t *testing.T) {
+	auth.SeedDefaultPolicies(testAppInstance.Enforcer, logger.New(config.LogConfig{Level: "error"}), false)
+	content := strings.Repeat("A long paragraph that compresses well. ", 500)
+	page := &data.Page{Title: "LargeJSONPage", Content: content, AuthorID: "someone"}
+	if err := testAppInstance.PageRepo.CreatePage(context.Background(), page); err != nil {
+		t.Fatalf("failed to create page: %v", err)
+	}
+
+	req := httptest.NewRequest("GET", "/view/LargeJSONPage", nil)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Accept-Encoding", "gzip")
+	rr := httptest.NewRecorder()
+	testAppInstance.Router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("want status %d; got %d", http.StatusOK, rr.Code)
+	}
+	if enc := rr.Header().Get("Content-Encoding"); enc != "gzip" {
+		t.Fatalf("want a gzip-encoded response; got Content-Encoding %q", enc)
+	}
+	if rr.Body.Len() >= len(content) {
+		t.Errorf("want the body compressed below %d bytes; got %d", len(content), rr.Body.Len())
+	}
+	zr, err := gzip.NewReader(rr.Body)
+	if err != nil {
+		t.Fatalf("failed to read gzip body: %v", err)
+	}
+	var got struct {
+		Title   string `json:"title"`
+		Content string `json:"content"`
+	}
+	if err := json.NewDecoder(zr).Decode(&got); err != nil {
+		t.Fatalf("failed to decode JSON: %v", err)
+	}
+	if got.Title != "LargeJSONPage" || got.Content != content {
+		t.Errorf("unexpected page in response: %q", got.Title)
+	}
+}
+
+func TestListPages_CursorPagination_Integration(t *testing.T) {
+	auth.SeedDefaultPolicies(testAppInstance.Enforcer, logger.New(config.LogConfig{Level: "error"}), false)
+	testAppInstance.DB.MustExec("DELETE FROM pages")
+	testAppInstance.Enforcer.AddRoleForUser("test-editor", "editor")
+	cookie := getAuthenticatedCookie(t)
+	ctx := context.Background()
+	create := func(title string) {
+		if err := testAppInstance.PageRepo.CreatePage(ctx, &data.Page{Title: title, Content: "Body of " + title, AuthorID: "someone"}); err != nil {
+			t.Fatalf("failed to create page: %v", err)
+		}
+	}
+	for _, title := range []string{"ListA", "ListB", "ListC", "ListD"} {
+		create(title)
+	}
+
+	type listResponse struct {
+		Pages []struct {
+			Title   string `json:"title"`
+			Content string `json:"content"`
+		} `json:"pages"`
+		Total int    `json:"total"`
+		Next  string `json:"next"`
+	}
+	get := func(target string) (listResponse, *httptest.ResponseRecorder) {
+		req := httptest.NewRequest("GET", target, nil)
+		req.Header.Set("Accept", "application/json")
+		req.AddCookie(cookie)
+		rr := httptest.NewRecorder()
+		testAppInstance.Router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("GET %s: want status %d; got %d", target, http.StatusOK, rr.Code)
+		}
+		var resp listResponse
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("GET %s: invalid JSON: %v", target, err)
+		}
+		return resp, rr
+	}
+
+	first, rr := get("/list?limit=2")
+	if first.Total != 4 || rr.Header().Get("X-Total-Count") != "4" {
+		t.Errorf("want a total of 4; got %d and header %q", first.Total, rr.Header().Get("X-Total-Count"))
+	}
+	if want := fmt.Sprintf(`<%s>; rel="next"`, first.Next); first.Next == "" || rr.Header().Get("Link") != want {
+		t.Fatalf("want Link header %q; got %q", want, rr.Header().Get("Link"))
+	}
+
+	// A page added mid-walk goes to the end, so it neither shifts nor repeats the pages already seen.
+	create("ListE")
+
+	var titles []string
+	for _, page := range first.Pages {
+		if page.Content != "" {
+			t.Errorf("want the list without content; got %q", page.Content)
+		}
+		titles = append(titles, page.Title)
+	}
+	next, rr := get(first.Next)
+	for _, page := range next.Pages {
+		titles = append(titles, page.Title)
+	}
+	if links := strings.Join(rr.Header().Values("Link"), ", "); !strings.Contains(links, `rel="prev"`) || !strings.Contains(links, "before=") {
+		t.Errorf("want a prev link on the second page; got %q", links)
+	}
+	if next.Next == "" {
+		t.Fatal("want a next link to the page added mid-walk")
+	}
+	last, _ := get(next.Next)
+	for _, page := range last.Pages {
+		titles = append(titles, page.Title)
+	}
+	if last.Next != "" {
+		t.Errorf("want no next link on the last page; got %q", last.Next)
+	}
+	if got := strings.Join(titles, ","); got != "ListA,ListB,ListC,ListD,ListE" {
+		t.Errorf("want every page exactly once in order; got %s", got)
+	}
+}
+
+func TestTrash_Integration(t *testing.T) {
+	auth.SeedDefaultPolicies(testAppInstance.Enforcer, logger.New(config.LogConfig{Level: "error"}), false)
+	testAppInstance.Enforcer.AddRoleForUser("test-editor", "editor")
+	testAppInstance.Enforcer.AddRoleForUser("test-admin", "admin")
+	page := &data.Page{Title: "TrashMe", Content: "Temporary", AuthorID: "test-editor"}
+	if err := testAppInstance.PageRepo.CreatePage(context.Background(), page); err != nil {
+		t.Fatalf("failed to create page: %v", err)
+	}
+	editor := getAuthenticatedCookie(t)
+
+	do := func(method, target string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		req.Header.Set(middleware.CSRFHeader, testCSRFToken)
+		req.AddCookie(cookie)
+		rr := httptest.NewRecorder()
+		testAppInstance.Router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := do("POST", "/delete/TrashMe", editor); rr.Code != http.StatusSeeOther {
+		t.Fatalf("want status %d deleting; got %d", http.StatusSeeOther, rr.Code)
+	}
+	if rr := do("GET", "/view/TrashMe", editor); rr.Code != http.StatusNotFound {
+		t.Errorf("want a deleted page to be gone; got status %d", rr.Code)
+	}
+	rr := do("GET", "/trash", editor)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "TrashMe") {
+		t.Fatalf("want the page listed in the trash; got status %d", rr.Code)
+	}
+	if strings.Contains(rr.Body.String(), "/trash/purge/") {
+		t.Error("want no purge button for editors")
+	}
+	if rr := do("POST", fmt.Sprintf("/trash/purge/%d", page.ID), editor); rr.Code != http.StatusForbidden {
+		t.Errorf("want editors forbidden to purge; got status %d", rr.Code)
+	}
+
+	if rr := do("POST", fmt.Sprintf("/trash/restore/%d", page.ID), editor); rr.Code != http.StatusSeeOther {
+		t.Fatalf("want status %d restoring; got %d", http.StatusSeeOther, rr.Code)
+	}
+	if rr := do("GET", "/view/TrashMe", editor); rr.Code != http.StatusOK {
+		t.Errorf("want the restored page to be back; got status %d", rr.Code)
+	}
+
+	admin := getSessionCookie(t, "test-admin")
+	do("POST", "/delete/TrashMe", admin)
+	if rr := do("POST", fmt.Sprintf("/trash/purge/%d", page.ID), admin); rr.Code != http.StatusSeeOther {
+		t.Fatalf("want status %d purging; got %d", http.StatusSeeOther, rr.Code)
+	}
+	if rr := do("GET", "/trash", admin); strings.Contains(rr.Body.String(), "TrashMe") {
+		t.Error("want the purged page gone from the trash")
+	}
+}
+
+func TestAPIv1_Pages_Integration(t *testing.T) {
+	auth.SeedDefaultPolicies(testAppInstance.Enforcer, logger.New(config.LogConfig{Level: "error"}), false)
+	testAppInstance.Enforcer.AddRoleForUser("test-editor", "editor")
+	editor := getAuthenticatedCookie(t)
+
+	do := func(method, target, body string, cookie *http.Cookie, header map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rr := httptest.NewRecorder()
+		testAppInstance.Router.ServeHTTP(rr, req)
+		return rr
+	}
+	var page struct {
+		Title   string `json:"title"`
+		Content string `json:"content"`
+		HTML    string `json:"html"`
+	}
+
+	rr := do("POST", "/api/v1/pages", `{"title": "API Page", "content": "Made by **API**"}`, editor, nil)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("want status %d creating; got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+	if got := rr.Header().Get("Location"); got != "/api/v1/pages/API%20Page" {
+		t.Errorf("want the new page's location; got %q", got)
+	}
+	if rr := do("POST", "/api/v1/pages", `{"title": "API Page"}`, editor, nil); rr.Code != http.StatusConflict {
+		t.Errorf("want status %d creating an existing page; got %d", http.StatusConflict, rr.Code)
+	}
+
+	rr = do("GET", "/api/v1/pages/API%20Page", "", nil, map[string]string{"Accept": "application/json"})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("want status %d reading; got %d", http.StatusOK, rr.Code)
+	}
+	if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Errorf("want a JSON response; got %q", ct)
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &page); err != nil {
+		t.Fatalf("failed to decode page: %v", err)
+	}
+	if page.Content != "Made by **API**" || !strings.Contains(page.HTML, "<strong>API</strong>") {
+		t.Errorf("want the markdown and rendered HTML; got %+v", page)
+	}
+	if rr := do("GET", "/api/v1/pages/API%20Page", "", nil, map[string]string{"Accept": "text/html"}); rr.Code != http.StatusNotAcceptable {
+		t.Errorf("want status %d when JSON is not accepted; got %d", http.StatusNotAcceptable, rr.Code)
+	}
+	if rr := do("PUT", "/api/v1/pages/API%20Page", "content=x", editor, map[string]string{"Content-Type": "application/x-www-form-urlencoded"}); rr.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("want status %d for a form body; got %d", http.StatusUnsupportedMediaType, rr.Code)
+	}
+
+	t.Run("anonymous users may only read", func(t *testing.T) {
+		for _, tt := range []struct{ method, target, body string }{
+			{"POST", "/api/v1/pages", `{"title": "Anonymous"}`},
+			{"PUT", "/api/v1/pages/API%20Page", `{"content": "Vandalised"}`},
+			{"DELETE", "/api/v1/pages/API%20Page", ""},
+		} {
+			rr := do(tt.method, tt.target, tt.body, nil, nil)
+			if rr.Code != http.StatusForbidden {
+				t.Errorf("%s %s: want status %d; got %d", tt.method, tt.target, http.StatusForbidden, rr.Code)
+			}
+			var body struct {
+				Status int    `json:"status"`
+				Error  string `json:"error"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil || body.Status != http.StatusForbidden {
+				t.Errorf("%s %s: want a JSON error; got %q", tt.method, tt.target, rr.Body.String())
+			}
+		}
+	})
+
+	t.Run("batch reads at the versioned and old paths", func(t *testing.T) {
+		for _, target := range []string{"/api/v1/pages/batch", "/api/pages/batch"} {
+			rr := do("POST", target, `{"titles": ["API Page"]}`, nil, nil)
+			if rr.Code != http.StatusOK {
+				t.Fatalf("%s: want status %d; got %d: %s", target, http.StatusOK, rr.Code, rr.Body.String())
+			}
+			var pages map[string]struct {
+				Status int `json:"status"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &pages); err != nil || pages["API Page"].Status != http.StatusOK {
+				t.Errorf("%s: want the page in the batch; got %q", target, rr.Body.String())
+			}
+		}
+	})
+
+	rr = do("PUT", "/api/v1/pages/API%20Page", `{"content": "Updated"}`, editor, map[string]string{"If-Match": `"stale"`})
+	if rr.Code != http.StatusPreconditionFailed {
+		t.Errorf("want status %d for a stale If-Match; got %d", http.StatusPreconditionFailed, rr.Code)
+	}
+	rr = do("PUT", "/api/v1/pages/API%20Page", `{"content": "Updated"}`, editor, nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("want status %d updating; got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &page); err != nil || page.Content != "Updated" || page.Title != "API Page" {
+		t.Errorf("want the updated page back; got %+v (%v)", page, err)
+	}
+
+	if rr := do("DELETE", "/api/v1/pages/API%20Page", "", editor, nil); rr.Code != http.StatusNoContent {
+		t.Fatalf("want status %d deleting; got %d", http.StatusNoContent, rr.Code)
+	}
+	rr = do("GET", "/api/v1/pages/API%20Page", "", nil, nil)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("want status %d for a deleted page; got %d", http.StatusNotFound, rr.Code)
+	}
+	if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Errorf("want API errors as JSON; got %q", ct)
+	}
+}
+
+func TestMovePage_Integration(t *testing.T) {
+	auth.SeedDefaultPolicies(testAppInstance.Enforcer, logger.New(config.LogConfig{Level: "error"}), false)
+	testAppInstance.Enforcer.AddRoleForUser("test-editor", "editor")
+	ctx := context.Background()
+
+	page := &data.Page{Title: "MovablePage", Content: "Content that stays put", AuthorID: "test-editor"}
+	if err := testAppInstance.PageRepo.CreatePage(ctx, page); err != nil {
+		t.Fatalf("failed to create page: %v", err)
+	}
+	before, err := testAppInstance.PageRepo.GetPageByTitle(ctx, "MovablePage")
+	if err != nil {
+		t.Fatalf("failed to retrieve page: %v", err)
+	}
+	move := func(cookie *http.Cookie) *httptest.ResponseRecorder {
+		form := url.Values{"category": {"Sports"}, "subcategory": {"Passing"}, middleware.CSRFField: {testCSRFToken}}
+		req := httptest.NewRequest("POST", "/move/MovablePage", strings.NewReader(form.Encode()))
+		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(cookie)
+		rr := httptest.NewRecorder()
+		testAppInstance.Router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	for _, subject := range []string{"", "test-reader"} {
+		if rr := move(getSessionCookie(t, subject)); rr.Code != http.StatusForbidden {
+			t.Errorf("subject %q: want status %d; got %d", subject, http.StatusForbidden, rr.Code)
+		}
+	}
+	if rr := move(getAuthenticatedCookie(t)); rr.Code != http.StatusFound {
+		t.Fatalf("want status %d; got %d: %s", http.StatusFound, rr.Code, rr.Body.String())
+	}
+
+	moved, err := testAppInstance.PageRepo.GetPageByTitle(ctx, "MovablePage")
+	if err != nil {
+		t.Fatalf("failed to retrieve moved page: %v", err)
+	}
+	if moved.Content != before.Content || !moved.UpdatedAt.Equal(before.UpdatedAt) {
+		t.Errorf("expected the content to be left alone, got %q updated at %v", moved.Content, moved.UpdatedAt)
+	}
+	if moved.CategoryID == nil {
+		t.Fatal("expected the page to have a category")
+	}
+	subCategory, err := testAppInstance.CategoryRepo.GetByID(*moved.CategoryID)
+	if err != nil {
+		t.Fatalf("failed to retrieve subcategory: %v", err)
+	}
+	if subCategory.Name != "Passing" {
+		t.Errorf("expected subcategory 'Passing', got '%s'", subCategory.Name)
+	}
+}
+
+func TestAPIv1_Entries_Integration(t *testing.T) {
+	auth.SeedDefaultPolicies(testAppInstance.Enforcer, logger.New(config.LogConfig{Level: "error"}), false)
+	testAppInstance.Enforcer.AddRoleForUser("test-editor", "editor")
+	editor := getAuthenticatedCookie(t)
+	ctx := context.Background()
+
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/v1/entries", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.AddCookie(editor)
+		rr := httptest.NewRecorder()
+		testAppInstance.Router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := post(`{"collection": "Incidents", "date": "2024-06-01", "body": "Database failover at 02:00."}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("want status %d for the first entry; got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+	if got := rr.Header().Get("Location"); got != "/api/v1/pages/Incidents%202024-06-01" {
+		t.Errorf("want the page's location; got %q", got)
+	}
+	rr = post(`{"collection": "Incidents", "date": "2024-06-01", "body": "Cache cluster restarted at 09:30."}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("want status %d for the second entry; got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	pages, err := testAppInstance.PageRepo.GetAllPages(ctx)
+	if err != nil {
+		t.Fatalf("failed to list pages: %v", err)
+	}
+	var count int
+	for _, p := range pages {
+		if strings.HasPrefix(p.Title, "Incidents") {
+			count++
+		}
+	}
+	if count != 1 {
+		t.Errorf("want both entries on one page; got %d pages", count)
+	}
+	page, err := testAppInstance.PageRepo.GetPageByTitle(ctx, "Incidents 2024-06-01")
+	if err != nil {
+		t.Fatalf("failed to retrieve the entries page: %v", err)
+	}
+	if want := "Database failover at 02:00.\n\n---\n\nCache cluster restarted at 09:30."; page.Content != want {
+		t.Errorf("want the second entry appended; got %q", page.Content)
+	}
+	year, err := testAppInstance.CategoryRepo.GetByID(*page.CategoryID)
+	if err != nil || year.Name != "2024" {
+		t.Errorf("want the page filed under Incidents/2024; got %+v (%v)", year, err)
+	}
+
+	for _, body := range []string{
+		`{"collection": "Incidents", "date": "June 1st", "body": "text"}`,
+		`{"collection": "Ops/Incidents", "date": "2024-06-01", "body": "text"}`,
+		`{"collection": "Incidents", "date": "2024-06-01", "body": "  "}`,
+	} {
+		if rr := post(body); rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: want status %d; got %d", body, http.StatusBadRequest, rr.Code)
+		}
+	}
+}
@@ -1,56 +1,151 @@
 package handler
 
 import (
+	"archive/zip"
+	"bytes"
 	"context"
+	"database/sql"
+	"encoding/json"
+	"encoding/xml"
 	"errors"
+	"fmt"
+	"go-wiki-app/internal/cache"
 	"go-wiki-app/internal/config"
 	"go-wiki-app/internal/data"
+	"go-wiki-app/internal/diff"
+	"go-wiki-app/internal/events"
 	"go-wiki-app/internal/logger"
+	"go-wiki-app/internal/middleware"
 	"go-wiki-app/internal/service"
 	"go-wiki-app/internal/view"
 	"go-wiki-app/web"
+	"html/template"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/casbin/casbin/v2"
 	"github.com/go-chi/chi/v5"
 )
 
 type mockPageService struct {
 	ViewPageFunc           func(ctx context.Context, title string) (*data.Page, error)
+	GetPageFunc            func(ctx context.Context, title string) (*data.Page, error)
 	CreatePageFunc         func(ctx context.Context, title, content, authorID, categoryName, subcategoryName string) (*data.Page, error)
-	UpdatePageFunc         func(ctx context.Context, id int64, title, content, categoryName, subcategoryName string) (*data.Page, error)
+	UpdatePageFunc         func(ctx context.Context, id int64, title, content, categoryName, subcategoryName string, minor bool) (*data.Page, error)
 	GetAllPagesFunc        func(ctx context.Context) ([]*data.Page, error)
+	GetAllPagesPagedFunc   func(ctx context.Context, page, perPage int) ([]*data.Page, int, error)
+	PagesLastModifiedFunc  func(ctx context.Context) (time.Time, int, error)
+	ListPagesFunc          func(ctx context.Context, filter data.PageListFilter) (*data.PageList, error)
+	GetPagesByTitlesFunc   func(ctx context.Context, titles []string) (map[string]*data.Page, error)
 	DeletePageFunc         func(ctx context.Context, id int64) error
 	GetCategoryTreeFunc    func(ctx context.Context) ([]*service.CategoryNode, error)
 	SearchCategoriesFunc   func(ctx context.Context, query string) ([]*data.Category, error)
 	GetPagesForCategoryFunc func(ctx context.Context, categoryName string) ([]*data.Page, error)
 	GetPagesForSubcategoryFunc func(ctx context.Context, categoryName string, subcategoryName string) ([]*data.Page, error)
+	SubscribeToPageFunc     func(ctx context.Context, title string) (<-chan events.Event, func(), error)
+	GetRecentActivityFunc   func(ctx context.Context, filter data.ActivityFilter, limit, offset int) ([]*data.Activity, error)
+	GetPageHistoryFunc      func(ctx context.Context, title string) (*data.Page, []*data.Revision, error)
+	DiffAgainstCurrentFunc  func(ctx context.Context, pageID, revisionID int64) (*service.RevisionDiff, error)
+	FindSimilarContentFunc  func(ctx context.Context, content string) ([]*data.Page, error)
+	GetStubsFunc            func(ctx context.Context) ([]*data.Page, error)
+	GetPagesEditedByFunc    func(ctx context.Context, subject string, limit int) ([]*data.Page, error)
+	GetCoEditedPagesFunc    func(ctx context.Context, pageID int64, limit int) ([]*data.Page, error)
+	SetPageExpiryFunc       func(ctx context.Context, pageID int64, expiresAt *time.Time) error
+	GetArchivedPagesFunc    func(ctx context.Context) ([]*data.Page, error)
+	TemplateNamesFunc       func(ctx context.Context) ([]string, error)
+	CreateFromTemplateFunc  func(ctx context.Context, templateName, title, author string) (*data.Page, error)
+	RollbackPageFunc        func(ctx context.Context, pageID, revisionID int64, authorID string) (*data.Page, error)
+	MetadataFieldsFunc      func() []string
+	SetPageMetadataFunc     func(ctx context.Context, pageID int64, metadata map[string]string) error
+	SearchPagesFunc         func(ctx context.Context, filter data.SearchFilter) ([]*service.SearchResult, error)
+	CanViewFunc             func(ctx context.Context, page *data.Page, userInfo *middleware.UserInfo) bool
+	SetPageRequiredRoleFunc func(ctx context.Context, pageID int64, role string) error
+	SetExtraCategoriesFunc  func(ctx context.Context, pageID int64, paths []string) error
+	RenameCategoryFunc      func(ctx context.Context, id int64, newName string) error
+	DeleteCategoryFunc      func(ctx context.Context, id int64, reassignToID *int64) error
+	MovePageFunc            func(ctx context.Context, title, categoryName, subcategoryName string) error
+	AppendEntryFunc         func(ctx context.Context, collection string, date time.Time, body, authorID string) (*data.Page, bool, error)
+	DiffRevisionsFunc       func(ctx context.Context, pageID, fromID, toID int64) (*service.RevisionDiff, error)
 }
 
 func (m *mockPageService) GetAllPages(ctx context.Context) ([]*data.Page, error) {
 	return m.GetAllPagesFunc(ctx)
 }
 
+func (m *mockPageService) GetAllPagesPaged(ctx context.Context, page, perPage int) ([]*data.Page, int, error) {
+	return m.GetAllPagesPagedFunc(ctx, page, perPage)
+}
+
+func (m *mockPageService) PagesLastModified(ctx context.Context) (time.Time, int, error) {
+	return m.PagesLastModifiedFunc(ctx)
+}
+
+func (m *mockPageService) ListPages(ctx context.Context, filter data.PageListFilter) (*data.PageList, error) {
+	return m.ListPagesFunc(ctx, filter)
+}
+
+func (m *mockPageService) GetPagesByTitles(ctx context.Context, titles []string) (map[string]*data.Page, error) {
+	return m.GetPagesByTitlesFunc(ctx, titles)
+}
+
 func (m *mockPageService) ViewPage(ctx context.Context, title string) (*data.Page, error) {
 	return m.ViewPageFunc(ctx, title)
 }
 
+func (m *mockPageService) GetPage(ctx context.Context, title string) (*data.Page, error) {
+	if m.GetPageFunc != nil {
+		return m.GetPageFunc(ctx, title)
+	}
+	// Tests that only stub ViewPage look pages up through it as well; its
+	// errors stand for missing pages.
+	if m.ViewPageFunc != nil {
+		page, err := m.ViewPageFunc(ctx, title)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", service.ErrPageNotFound, err)
+		}
+		return page, nil
+	}
+	return nil, errors.New("not implemented")
+}
+
 func (m *mockPageService) CreatePage(ctx context.Context, title, content, authorID, categoryName, subcategoryName string) (*data.Page, error) {
 	return m.CreatePageFunc(ctx, title, content, authorID, categoryName, subcategoryName)
 }
 
-func (m *mockPageService) UpdatePage(ctx context.Context, id int64, title, content, categoryName, subcategoryName string) (*data.Page, error) {
-	return m.UpdatePageFunc(ctx, id, title, content, categoryName, subcategoryName)
+func (m *mockPageService) UpdatePage(ctx context.Context, id int64, title, content, categoryName, subcategoryName string, minor bool) (*data.Page, error) {
+	return m.UpdatePageFunc(ctx, id, title, content, categoryName, subcategoryName, minor)
 }
 
 func (m *mockPageService) DeletePage(ctx context.Context, id int64) error {
 	return m.DeletePageFunc(ctx, id)
 }
 
+func (m *mockPageService) GetTrash(ctx context.Context) ([]*data.Page, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (m *mockPageService) RestorePage(ctx context.Context, id int64) error {
+	return errors.New("not implemented")
+}
+
+func (m *mockPageService) PurgePage(ctx context.Context, id int64) error {
+	return errors.New("not implemented")
+}
+
 func (m *mockPageService) GetCategoryTree(ctx context.Context) ([]*service.CategoryNode, error) {
-	return m.GetCategoryTreeFunc(ctx)
+	if m.GetCategoryTreeFunc != nil {
+		return m.GetCategoryTreeFunc(ctx)
+	}
+	return nil, nil
 }
 
 func (m *mockPageService) SearchCategories(ctx context.Context, query string) ([]*data.Category, error) {
@@ -71,6 +166,174 @@ func (m *mockPageService) GetPagesForSubcategory(ctx context.Context, categoryNa
 	return nil, nil
 }
 
+func (m *mockPageService) SubscribeToPage(ctx context.Context, title string) (<-chan events.Event, func(), error) {
+	if m.SubscribeToPageFunc != nil {
+		return m.SubscribeToPageFunc(ctx, title)
+	}
+	return nil, nil, errors.New("not implemented")
+}
+
+func (m *mockPageService) GetRecentActivity(ctx context.Context, filter data.ActivityFilter, limit, offset int) ([]*data.Activity, error) {
+	if m.GetRecentActivityFunc != nil {
+		return m.GetRecentActivityFunc(ctx, filter, limit, offset)
+	}
+	return nil, nil
+}
+
+func (m *mockPageService) GetPageHistory(ctx context.Context, title string) (*data.Page, []*data.Revision, error) {
+	if m.GetPageHistoryFunc != nil {
+		return m.GetPageHistoryFunc(ctx, title)
+	}
+	return nil, nil, errors.New("not implemented")
+}
+
+func (m *mockPageService) DiffAgainstCurrent(ctx context.Context, pageID, revisionID int64) (*service.RevisionDiff, error) {
+	if m.DiffAgainstCurrentFunc != nil {
+		return m.DiffAgainstCurrentFunc(ctx, pageID, revisionID)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *mockPageService) DiffRevisions(ctx context.Context, pageID, fromID, toID int64) (*service.RevisionDiff, error) {
+	if m.DiffRevisionsFunc != nil {
+		return m.DiffRevisionsFunc(ctx, pageID, fromID, toID)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *mockPageService) FindSimilarContent(ctx context.Context, content string) ([]*data.Page, error) {
+	if m.FindSimilarContentFunc != nil {
+		return m.FindSimilarContentFunc(ctx, content)
+	}
+	return nil, nil
+}
+
+func (m *mockPageService) GetStubs(ctx context.Context) ([]*data.Page, error) {
+	if m.GetStubsFunc != nil {
+		return m.GetStubsFunc(ctx)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *mockPageService) GetPagesEditedBy(ctx context.Context, subject string, limit int) ([]*data.Page, error) {
+	if m.GetPagesEditedByFunc != nil {
+		return m.GetPagesEditedByFunc(ctx, subject, limit)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *mockPageService) GetCoEditedPages(ctx context.Context, pageID int64, limit int) ([]*data.Page, error) {
+	if m.GetCoEditedPagesFunc != nil {
+		return m.GetCoEditedPagesFunc(ctx, pageID, limit)
+	}
+	return nil, nil
+}
+
+func (m *mockPageService) SetPageExpiry(ctx context.Context, pageID int64, expiresAt *time.Time) error {
+	if m.SetPageExpiryFunc != nil {
+		return m.SetPageExpiryFunc(ctx, pageID, expiresAt)
+	}
+	return nil
+}
+
+func (m *mockPageService) GetArchivedPages(ctx context.Context) ([]*data.Page, error) {
+	if m.GetArchivedPagesFunc != nil {
+		return m.GetArchivedPagesFunc(ctx)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *mockPageService) TemplateNames(ctx context.Context) ([]string, error) {
+	if m.TemplateNamesFunc != nil {
+		return m.TemplateNamesFunc(ctx)
+	}
+	return nil, nil
+}
+
+func (m *mockPageService) CreateFromTemplate(ctx context.Context, templateName, title, author string) (*data.Page, error) {
+	if m.CreateFromTemplateFunc != nil {
+		return m.CreateFromTemplateFunc(ctx, templateName, title, author)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *mockPageService) RollbackPage(ctx context.Context, pageID, revisionID int64, authorID string) (*data.Page, error) {
+	if m.RollbackPageFunc != nil {
+		return m.RollbackPageFunc(ctx, pageID, revisionID, authorID)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *mockPageService) CanView(ctx context.Context, page *data.Page, userInfo *middleware.UserInfo) bool {
+	if m.CanViewFunc != nil {
+		return m.CanViewFunc(ctx, page, userInfo)
+	}
+	return true
+}
+
+func (m *mockPageService) SetPageRequiredRole(ctx context.Context, pageID int64, role string) error {
+	if m.SetPageRequiredRoleFunc != nil {
+		return m.SetPageRequiredRoleFunc(ctx, pageID, role)
+	}
+	return nil
+}
+
+func (m *mockPageService) SetExtraCategories(ctx context.Context, pageID int64, paths []string) error {
+	if m.SetExtraCategoriesFunc != nil {
+		return m.SetExtraCategoriesFunc(ctx, pageID, paths)
+	}
+	return nil
+}
+
+func (m *mockPageService) RenameCategory(ctx context.Context, id int64, newName string) error {
+	if m.RenameCategoryFunc != nil {
+		return m.RenameCategoryFunc(ctx, id, newName)
+	}
+	return errors.New("not implemented")
+}
+
+func (m *mockPageService) DeleteCategory(ctx context.Context, id int64, reassignToID *int64) error {
+	if m.DeleteCategoryFunc != nil {
+		return m.DeleteCategoryFunc(ctx, id, reassignToID)
+	}
+	return errors.New("not implemented")
+}
+
+func (m *mockPageService) MovePage(ctx context.Context, title, categoryName, subcategoryName string) error {
+	if m.MovePageFunc != nil {
+		return m.MovePageFunc(ctx, title, categoryName, subcategoryName)
+	}
+	return errors.New("not implemented")
+}
+
+func (m *mockPageService) AppendEntry(ctx context.Context, collection string, date time.Time, body, authorID string) (*data.Page, bool, error) {
+	if m.AppendEntryFunc != nil {
+		return m.AppendEntryFunc(ctx, collection, date, body, authorID)
+	}
+	return nil, false, errors.New("not implemented")
+}
+
+func (m *mockPageService) MetadataFields() []string {
+	if m.MetadataFieldsFunc != nil {
+		return m.MetadataFieldsFunc()
+	}
+	return nil
+}
+
+func (m *mockPageService) SetPageMetadata(ctx context.Context, pageID int64, metadata map[string]string) error {
+	if m.SetPageMetadataFunc != nil {
+		return m.SetPageMetadataFunc(ctx, pageID, metadata)
+	}
+	return errors.New("not implemented")
+}
+
+func (m *mockPageService) SearchPages(ctx context.Context, filter data.SearchFilter) ([]*service.SearchResult, error) {
+	if m.SearchPagesFunc != nil {
+		return m.SearchPagesFunc(ctx, filter)
+	}
+	return nil, errors.New("not implemented")
+}
+
 func TestViewHandler_Welcome(t *testing.T) {
 	pageService := &mockPageService{
 		ViewPageFunc: func(ctx context.Context, title string) (*data.Page, error) {
@@ -82,7 +345,7 @@ func TestViewHandler_Welcome(t *testing.T) {
 	}
 	viewService, _ := view.New(web.TemplateFS)
 	log := logger.New(config.LogConfig{Level: "info"})
-	pageHandler := NewPageHandler(pageService, viewService, log)
+	pageHandler := NewPageHandler(pageService, viewService, log, nil)
 	req := httptest.NewRequest("GET", "/view/Home", nil)
 	rr := httptest.NewRecorder()
 	r := chi.NewRouter()
@@ -97,12 +360,87 @@ func TestViewHandler_Welcome(t *testing.T) {
 	if !strings.Contains(rr.Body.String(), "Welcome to Go Wiki!") {
 		t.Errorf("handler returned unexpected body: got %v", rr.Body.String())
 	}
+	if got := rr.Header().Get("X-Robots-Tag"); got != "noindex" {
+		t.Errorf("expected the welcome page to be marked noindex, got %q", got)
+	}
+
+	// Operators may opt in to having the welcome page indexed.
+	pageHandler = NewPageHandler(pageService, viewService, log, nil, WithWelcomePageIndexing(true))
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("GET", "/view/Home", nil))
+	if got := rr.Header().Get("X-Robots-Tag"); got != "" {
+		t.Errorf("expected no X-Robots-Tag when indexing is enabled, got %q", got)
+	}
+}
+
+func TestViewHandler_RenderBusy(t *testing.T) {
+	pageService := &mockPageService{
+		ViewPageFunc: func(ctx context.Context, title string) (*data.Page, error) {
+			return nil, service.ErrRenderBusy
+		},
+	}
+	viewService, _ := view.New(web.TemplateFS)
+	log := logger.New(config.LogConfig{Level: "error"})
+	pageHandler := NewPageHandler(pageService, viewService, log, nil)
+
+	for _, path := range []string{"/view/Busy", "/edit/Busy"} {
+		rr := httptest.NewRecorder()
+		r := chi.NewRouter()
+		r.Get("/view/{title}", func(w http.ResponseWriter, r *http.Request) {
+			if appErr := pageHandler.viewHandler(w, r); appErr != nil {
+				w.WriteHeader(appErr.Code)
+			}
+		})
+		r.Get("/edit/{title}", func(w http.ResponseWriter, r *http.Request) {
+			if appErr := pageHandler.editHandler(w, r); appErr != nil {
+				w.WriteHeader(appErr.Code)
+			}
+		})
+		r.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+
+		if rr.Code != http.StatusServiceUnavailable {
+			t.Errorf("%s: want status %d; got %d", path, http.StatusServiceUnavailable, rr.Code)
+		}
+		if rr.Header().Get("Retry-After") == "" {
+			t.Errorf("%s: expected a Retry-After header", path)
+		}
+	}
+}
+
+func TestViewHandler_RenderCancelled(t *testing.T) {
+	pageService := &mockPageService{
+		ViewPageFunc: func(ctx context.Context, title string) (*data.Page, error) {
+			return nil, context.Canceled
+		},
+	}
+	viewService, _ := view.New(web.TemplateFS)
+	log := logger.New(config.LogConfig{Level: "error"})
+	pageHandler := NewPageHandler(pageService, viewService, log, nil)
+
+	rr := httptest.NewRecorder()
+	r := chi.NewRouter()
+	r.Get("/view/{title}", func(w http.ResponseWriter, r *http.Request) {
+		if appErr := pageHandler.viewHandler(w, r); appErr != nil {
+			w.WriteHeader(appErr.Code)
+		}
+	})
+	r.ServeHTTP(rr, httptest.NewRequest("GET", "/view/Slow", nil))
+
+	// A request that ended while waiting to render is not a missing page.
+	if rr.Code != statusClientClosedRequest {
+		t.Errorf("want status %d; got %d", statusClientClosedRequest, rr.Code)
+	}
 }
 
 func TestListHandler(t *testing.T) {
+	var requestedPage int
 	pageService := &mockPageService{
-		GetAllPagesFunc: func(ctx context.Context) ([]*data.Page, error) {
-			return []*data.Page{{Title: "Page 1"}, {Title: "Page 2"}}, nil
+		GetAllPagesPagedFunc: func(ctx context.Context, page, perPage int) ([]*data.Page, int, error) {
+			requestedPage = page
+			return []*data.Page{{Title: "Page 1"}, {Title: "Page 2"}}, 2 * listPerPage, nil
+		},
+		PagesLastModifiedFunc: func(ctx context.Context) (time.Time, int, error) {
+			return time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC), 2 * listPerPage, nil
 		},
 		GetCategoryTreeFunc: func(ctx context.Context) ([]*service.CategoryNode, error) {
 			return []*service.CategoryNode{}, nil // Return empty tree for this test
@@ -110,7 +448,7 @@ func TestListHandler(t *testing.T) {
 	}
 	viewService, _ := view.New(web.TemplateFS)
 	log := logger.New(config.LogConfig{Level: "info"})
-	pageHandler := NewPageHandler(pageService, viewService, log)
+	pageHandler := NewPageHandler(pageService, viewService, log, nil)
 	req := httptest.NewRequest("GET", "/list", nil)
 	rr := httptest.NewRecorder()
 	r := chi.NewRouter()
@@ -127,6 +465,21 @@ func TestListHandler(t *testing.T) {
 	if !strings.Contains(body, "Page 1") {
 		t.Errorf("handler returned unexpected body: got %v", body)
 	}
+	if requestedPage != 1 || !strings.Contains(body, `href="/list?page=2"`) || strings.Contains(body, "Previous") {
+		t.Errorf("expected the first page with a next link only, got page %d and body %v", requestedPage, body)
+	}
+
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("GET", "/list?page=2", nil))
+	body = rr.Body.String()
+	if requestedPage != 2 || !strings.Contains(body, `href="/list?page=1"`) || strings.Contains(body, "Next") {
+		t.Errorf("expected the last page with a previous link only, got page %d and body %v", requestedPage, body)
+	}
+
+	appErr := pageHandler.listHandler(httptest.NewRecorder(), httptest.NewRequest("GET", "/list?page=0", nil))
+	if appErr == nil || appErr.Code != http.StatusBadRequest {
+		t.Errorf("expected an invalid page number to be rejected, got %+v", appErr)
+	}
 }
 
 func TestViewHandler_ViewPage(t *testing.T) {
@@ -137,7 +490,7 @@ func TestViewHandler_ViewPage(t *testing.T) {
 	}
 	viewService, _ := view.New(web.TemplateFS)
 	log := logger.New(config.LogConfig{Level: "info"})
-	pageHandler := NewPageHandler(pageService, viewService, log)
+	pageHandler := NewPageHandler(pageService, viewService, log, nil)
 	req := httptest.NewRequest("GET", "/view/Test%20Page", nil)
 	rr := httptest.NewRecorder()
 	r := chi.NewRouter()
@@ -153,3 +506,2681 @@ func TestViewHandler_ViewPage(t *testing.T) {
 		t.Errorf("handler returned unexpected body: got %v", rr.Body.String())
 	}
 }
+
+func TestPageFeedHandler_NewestFirst(t *testing.T) {
+	base := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	pageService := &mockPageService{
+		GetPageHistoryFunc: func(ctx context.Context, title string) (*data.Page, []*data.Revision, error) {
+			page := &data.Page{ID: 1, Title: title, UpdatedAt: base.Add(2 * time.Hour)}
+			// Deliberately out of order to ensure the feed sorts them.
+			return page, []*data.Revision{
+				{ID: 1, PageID: 1, Title: title, AuthorID: "alice", CreatedAt: base},
+				{ID: 3, PageID: 1, Title: title, AuthorID: "carol", CreatedAt: base.Add(2 * time.Hour)},
+				{ID: 2, PageID: 1, Title: title, AuthorID: "bob", CreatedAt: base.Add(time.Hour)},
+			}, nil
+		},
+	}
+	viewService, _ := view.New(web.TemplateFS)
+	log := logger.New(config.LogConfig{Level: "info"})
+	pageHandler := NewPageHandler(pageService, viewService, log, nil)
+	req := httptest.NewRequest("GET", "/view/Feed%20Page/feed.xml", nil)
+	rr := httptest.NewRecorder()
+	r := chi.NewRouter()
+	r.Get("/view/{title}/feed.xml", func(w http.ResponseWriter, r *http.Request) {
+		pageHandler.pageFeedHandler(w, r)
+	})
+	r.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/atom+xml") {
+		t.Errorf("expected Atom content type, got %q", ct)
+	}
+
+	var feed struct {
+		Entries []struct {
+			Author struct {
+				Name string `xml:"name"`
+			} `xml:"author"`
+			Link struct {
+				Href string `xml:"href,attr"`
+			} `xml:"link"`
+		} `xml:"entry"`
+	}
+	if err := xml.Unmarshal(rr.Body.Bytes(), &feed); err != nil {
+		t.Fatalf("failed to parse feed: %v", err)
+	}
+	want := []string{"carol", "bob", "alice"}
+	if len(feed.Entries) != len(want) {
+		t.Fatalf("expected %d entries, got %d", len(want), len(feed.Entries))
+	}
+	for i, name := range want {
+		if feed.Entries[i].Author.Name != name {
+			t.Errorf("entry %d: expected author %s, got %s", i, name, feed.Entries[i].Author.Name)
+		}
+	}
+	// Edits link to their diff, and the creation, which has no diff, to the page.
+	if href := feed.Entries[0].Link.Href; !strings.HasSuffix(href, "/diff/Feed%20Page?from=2&to=3") {
+		t.Errorf("expected the newest entry to link to its diff, got %q", href)
+	}
+	if href := feed.Entries[2].Link.Href; !strings.HasSuffix(href, "/view/Feed%20Page") {
+		t.Errorf("expected the creation entry to link to the page, got %q", href)
+	}
+}
+
+func TestPageFeedHandler_NoHistory(t *testing.T) {
+	pageService := &mockPageService{
+		GetPageHistoryFunc: func(ctx context.Context, title string) (*data.Page, []*data.Revision, error) {
+			return &data.Page{ID: 1, Title: title}, []*data.Revision{}, nil
+		},
+	}
+	viewService, _ := view.New(web.TemplateFS)
+	log := logger.New(config.LogConfig{Level: "info"})
+	pageHandler := NewPageHandler(pageService, viewService, log, nil)
+	req := httptest.NewRequest("GET", "/view/Quiet/feed.xml", nil)
+	rr := httptest.NewRecorder()
+	r := chi.NewRouter()
+	r.Get("/view/{title}/feed.xml", func(w http.ResponseWriter, r *http.Request) {
+		pageHandler.pageFeedHandler(w, r)
+	})
+	r.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	if strings.Contains(rr.Body.String(), "<entry>") {
+		t.Errorf("expected no entries for a page without history, got %s", rr.Body.String())
+	}
+}
+
+func TestEditHandler_EmbedsEditorConfig(t *testing.T) {
+	pageService := &mockPageService{
+		ViewPageFunc: func(ctx context.Context, title string) (*data.Page, error) {
+			return &data.Page{ID: 1, Title: title, Content: "Some content"}, nil
+		},
+	}
+	viewService, _ := view.New(web.TemplateFS)
+	log := logger.New(config.LogConfig{Level: "info"})
+	editorCfg := config.EditorConfig{Toolbar: []string{"bold", "italic"}, SpellCheck: false, AutosaveSeconds: 30}
+	pageHandler := NewPageHandler(pageService, viewService, log, nil, WithEditorConfig(editorCfg))
+	req := httptest.NewRequest("GET", "/edit/Notes", nil)
+	rr := httptest.NewRecorder()
+	r := chi.NewRouter()
+	r.Get("/edit/{title}", func(w http.ResponseWriter, r *http.Request) {
+		pageHandler.editHandler(w, r)
+	})
+	r.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	body := rr.Body.String()
+	if !strings.Contains(body, `id="editor-config"`) {
+		t.Fatalf("expected editor config to be embedded, got %v", body)
+	}
+	for _, want := range []string{`"toolbar":["bold","italic"]`, `"spellChecker":false`, `"delay":30000`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected editor config to contain %s", want)
+		}
+	}
+}
+
+func TestEditHandler_IncludesCSRFToken(t *testing.T) {
+	pageService := &mockPageService{
+		ViewPageFunc: func(ctx context.Context, title string) (*data.Page, error) {
+			return &data.Page{ID: 1, Title: title, Content: "Some content"}, nil
+		},
+	}
+	viewService, _ := view.New(web.TemplateFS, view.WithCSRFToken(func(ctx context.Context) string { return "form-token" }))
+	log := logger.New(config.LogConfig{Level: "error"})
+	pageHandler := NewPageHandler(pageService, viewService, log, nil)
+	r := chi.NewRouter()
+	r.Use(middleware.SettingsMiddleware)
+	r.Get("/edit/{title}", func(w http.ResponseWriter, r *http.Request) {
+		pageHandler.editHandler(w, r)
+	})
+
+	field := `<input type="hidden" name="csrf_token" value="form-token">`
+	header := `hx-headers='{"X-CSRF-Token": "form-token"}'`
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("GET", "/edit/Notes", nil))
+	if body := rr.Body.String(); !strings.Contains(body, field) || !strings.Contains(body, header) {
+		t.Errorf("expected the HTMX edit form to send the token as a field and a header, got %s", body)
+	}
+
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("GET", "/edit/Notes?basic=true", nil))
+	body := rr.Body.String()
+	if !strings.Contains(body, field) {
+		t.Errorf("expected the basic mode edit form to carry the token, got %s", body)
+	}
+	if strings.Contains(body, "hx-headers") {
+		t.Error("expected no HTMX attributes in basic mode")
+	}
+}
+
+func TestViewHandler_DeclaresContentLanguage(t *testing.T) {
+	pageService := &mockPageService{
+		ViewPageFunc: func(ctx context.Context, title string) (*data.Page, error) {
+			return &data.Page{Title: "Startseite", Content: "Hallo Welt"}, nil
+		},
+	}
+	viewService, _ := view.New(web.TemplateFS)
+	log := logger.New(config.LogConfig{Level: "info"})
+	pageHandler := NewPageHandler(pageService, viewService, log, nil, WithLanguage("de"))
+	req := httptest.NewRequest("GET", "/view/Startseite", nil)
+	rr := httptest.NewRecorder()
+	r := chi.NewRouter()
+	r.Get("/view/{title}", func(w http.ResponseWriter, r *http.Request) {
+		pageHandler.viewHandler(w, r)
+	})
+	r.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	if !strings.Contains(rr.Body.String(), `<html lang="de">`) {
+		t.Errorf("expected layout to declare the configured language, got %v", rr.Body.String())
+	}
+}
+
+func TestViewHandler_AccessibleLandmarks(t *testing.T) {
+	pageService := &mockPageService{
+		ViewPageFunc: func(ctx context.Context, title string) (*data.Page, error) {
+			return &data.Page{
+				Title:       "Guide",
+				HTMLContent: `<h2 id="install">Install</h2>`,
+				TableOfContents: []*data.TOCEntry{
+					{ID: "install", Text: "Install", Level: 2},
+				},
+			}, nil
+		},
+	}
+	viewService, _ := view.New(web.TemplateFS)
+	log := logger.New(config.LogConfig{Level: "info"})
+	pageHandler := NewPageHandler(pageService, viewService, log, nil)
+	req := httptest.NewRequest("GET", "/view/Guide", nil)
+	rr := httptest.NewRecorder()
+	r := chi.NewRouter()
+	r.Get("/view/{title}", func(w http.ResponseWriter, r *http.Request) {
+		pageHandler.viewHandler(w, r)
+	})
+	r.ServeHTTP(rr, req)
+
+	body := rr.Body.String()
+	for _, want := range []string{
+		`<a href="#main-content" class="skip-link">`,
+		`<main class="container" id="main-content"`,
+		`<nav aria-label="Table of contents"`,
+		`<a href="#install">Install</a>`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected body to contain %s", want)
+		}
+	}
+}
+
+func TestViewHandler_CanEdit(t *testing.T) {
+	enforcer, err := casbin.NewEnforcer("../../auth_model.conf")
+	if err != nil {
+		t.Fatalf("Failed to create enforcer: %v", err)
+	}
+	enforcer.AddPolicy("anonymous", "/view/*", "GET")
+	enforcer.AddPolicy("editor", "/edit/*", "GET")
+	enforcer.AddRoleForUser("editor-user", "editor")
+
+	pageService := &mockPageService{
+		ViewPageFunc: func(ctx context.Context, title string) (*data.Page, error) {
+			return &data.Page{Title: title, Content: "Content"}, nil
+		},
+	}
+	viewService, _ := view.New(web.TemplateFS)
+	log := logger.New(config.LogConfig{Level: "info"})
+	pageHandler := NewPageHandler(pageService, viewService, log, enforcer)
+	r := chi.NewRouter()
+	r.Get("/view/{title}", func(w http.ResponseWriter, r *http.Request) {
+		pageHandler.viewHandler(w, r)
+	})
+
+	testCases := []struct {
+		name    string
+		subject string
+		canEdit bool
+	}{
+		{"anonymous cannot edit", "anonymous", false},
+		{"editor can edit", "editor-user", true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/view/Notes", nil)
+			req = req.WithContext(middleware.SetUserInfo(req.Context(), &middleware.UserInfo{Subject: tc.subject}))
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			hasEditLink := strings.Contains(rr.Body.String(), `href="/edit/Notes"`)
+			if hasEditLink != tc.canEdit {
+				t.Errorf("expected edit link present=%v, got %v", tc.canEdit, hasEditLink)
+			}
+		})
+	}
+}
+
+// mockPermissions is a Permissions implementation that allows a fixed set of requests.
+type mockPermissions struct {
+	allowed map[string]bool
+	err     error
+}
+
+func (m *mockPermissions) Enforce(rvals ...interface{}) (bool, error) {
+	if m.err != nil {
+		return false, m.err
+	}
+	return m.allowed[fmt.Sprint(rvals...)], nil
+}
+
+func TestPageHandler_Can(t *testing.T) {
+	log := logger.New(config.LogConfig{Level: "info"})
+	perms := &mockPermissions{allowed: map[string]bool{
+		fmt.Sprint("alice", "/edit/Notes", "GET"): true,
+	}}
+	pageHandler := NewPageHandler(&mockPageService{}, nil, log, perms)
+	ctx := middleware.SetUserInfo(context.Background(), &middleware.UserInfo{Subject: "alice"})
+
+	if !pageHandler.can(ctx, "/edit/Notes", "GET") {
+		t.Error("expected alice to be allowed to edit Notes")
+	}
+	if pageHandler.can(ctx, "/edit/Other", "GET") {
+		t.Error("expected alice not to be allowed to edit Other")
+	}
+
+	perms.err = errors.New("enforcer failure")
+	if pageHandler.can(ctx, "/edit/Notes", "GET") {
+		t.Error("expected enforcer errors to deny access")
+	}
+
+	noPerms := NewPageHandler(&mockPageService{}, nil, log, nil)
+	if !noPerms.can(ctx, "/edit/Anything", "GET") {
+		t.Error("expected everything to be allowed without a permissions checker")
+	}
+}
+
+func TestCategoriesExportHandler_OPML(t *testing.T) {
+	parentID := int64(1)
+	pageService := &mockPageService{
+		GetCategoryTreeFunc: func(ctx context.Context) ([]*service.CategoryNode, error) {
+			return []*service.CategoryNode{
+				{
+					Category: &data.Category{ID: 1, Name: "Engineering"},
+					Path:     "Engineering",
+					Children: []*service.CategoryNode{{Category: &data.Category{ID: 2, Name: "Backend", ParentID: &parentID}, Path: "Engineering/Backend"}},
+				},
+			}, nil
+		},
+		GetPagesForSubcategoryFunc: func(ctx context.Context, categoryName, subcategoryName string) ([]*data.Page, error) {
+			return []*data.Page{{Title: "Deploying"}, {Title: "Secret Plans"}}, nil
+		},
+	}
+	perms := &mockPermissions{allowed: map[string]bool{
+		fmt.Sprint("anonymous", "/view/Deploying", "GET"): true,
+	}}
+	log := logger.New(config.LogConfig{Level: "info"})
+	pageHandler := NewPageHandler(pageService, nil, log, perms)
+
+	req := httptest.NewRequest("GET", "/categories/export?format=opml", nil)
+	req = req.WithContext(middleware.SetUserInfo(req.Context(), &middleware.UserInfo{Subject: "anonymous"}))
+	rr := httptest.NewRecorder()
+	if appErr := pageHandler.categoriesExportHandler(rr, req); appErr != nil {
+		t.Fatalf("handler returned error: %v", appErr.Error)
+	}
+
+	var doc opmlDocument
+	if err := xml.Unmarshal(rr.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("failed to parse OPML: %v", err)
+	}
+	if len(doc.Body.Outlines) != 1 || doc.Body.Outlines[0].Text != "Engineering" {
+		t.Fatalf("expected a single Engineering category, got %+v", doc.Body.Outlines)
+	}
+	subcategories := doc.Body.Outlines[0].Outlines
+	if len(subcategories) != 1 || subcategories[0].Text != "Backend" {
+		t.Fatalf("expected Backend nested under Engineering, got %+v", subcategories)
+	}
+	pages := subcategories[0].Outlines
+	if len(pages) != 1 || pages[0].Text != "Deploying" {
+		t.Fatalf("expected only the viewable page under Backend, got %+v", pages)
+	}
+	if !strings.HasSuffix(pages[0].URL, "/view/Deploying") {
+		t.Errorf("unexpected page URL %q", pages[0].URL)
+	}
+}
+
+func TestViewHandler_ContentNegotiation(t *testing.T) {
+	pageService := &mockPageService{
+		ViewPageFunc: func(ctx context.Context, title string) (*data.Page, error) {
+			return &data.Page{
+				ID:          7,
+				Title:       "Guide",
+				Content:     "# Guide\n\nRead *this*.",
+				HTMLContent: "<h1>Guide</h1><p>Read <em>this</em>.</p>",
+				AuthorID:    "alice",
+			}, nil
+		},
+	}
+	viewService, _ := view.New(web.TemplateFS)
+	log := logger.New(config.LogConfig{Level: "info"})
+	pageHandler := NewPageHandler(pageService, viewService, log, nil)
+	r := chi.NewRouter()
+	r.Get("/view/{title}", func(w http.ResponseWriter, r *http.Request) {
+		pageHandler.viewHandler(w, r)
+	})
+
+	tests := []struct {
+		accept      string
+		contentType string
+		check       func(t *testing.T, body string)
+	}{
+		{"text/html", "text/html", func(t *testing.T, body string) {
+			if !strings.Contains(body, "<em>this</em>") || !strings.Contains(body, "<html") {
+				t.Errorf("expected the rendered page, got %q", body)
+			}
+		}},
+		{"text/markdown", "text/markdown", func(t *testing.T, body string) {
+			if body != "# Guide\n\nRead *this*." {
+				t.Errorf("expected the raw markdown source, got %q", body)
+			}
+		}},
+		{"application/json", "application/json", func(t *testing.T, body string) {
+			var got map[string]interface{}
+			if err := json.Unmarshal([]byte(body), &got); err != nil {
+				t.Fatalf("expected valid JSON, got %q: %v", body, err)
+			}
+			if got["title"] != "Guide" || got["content"] != "# Guide\n\nRead *this*." || got["author_id"] != "alice" {
+				t.Errorf("unexpected JSON body: %v", got)
+			}
+			if _, ok := got["updated_at"]; !ok {
+				t.Errorf("expected page metadata in JSON body, got %v", got)
+			}
+		}},
+		{"text/markdown;q=0.5, application/json", "application/json", func(t *testing.T, body string) {
+			if !strings.HasPrefix(body, "{") {
+				t.Errorf("expected the higher-quality JSON representation, got %q", body)
+			}
+		}},
+		{"*/*", "text/html", func(t *testing.T, body string) {}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/view/Guide", nil)
+			req.Header.Set("Accept", tt.accept)
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+			}
+			if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, tt.contentType) {
+				t.Errorf("want Content-Type %q; got %q", tt.contentType, ct)
+			}
+			if vary := rr.Header().Get("Vary"); vary != "Accept" {
+				t.Errorf("want Vary: Accept; got %q", vary)
+			}
+			tt.check(t, rr.Body.String())
+		})
+	}
+}
+
+func TestPageETag_ConditionalRequests(t *testing.T) {
+	current := &data.Page{ID: 7, Title: "Guide", Content: "Version 2", UpdatedAt: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)}
+	updated := false
+	pageService := &mockPageService{
+		ViewPageFunc: func(ctx context.Context, title string) (*data.Page, error) {
+			return current, nil
+		},
+		UpdatePageFunc: func(ctx context.Context, id int64, title, content, categoryName, subcategoryName string, minor bool) (*data.Page, error) {
+			updated = true
+			return &data.Page{ID: id, Title: title}, nil
+		},
+	}
+	viewService, _ := view.New(web.TemplateFS)
+	log := logger.New(config.LogConfig{Level: "info"})
+	pageHandler := NewPageHandler(pageService, viewService, log, nil)
+	r := chi.NewRouter()
+	errorMiddleware := middleware.Error(log, viewService)
+	r.Method("GET", "/view/{title}", errorMiddleware(pageHandler.viewHandler))
+	r.Method("POST", "/save/{title}", errorMiddleware(pageHandler.saveHandler))
+
+	get := func(ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/view/Guide", nil)
+		req.Header.Set("Accept", "application/json")
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+	save := func(ifMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/save/Guide", strings.NewReader("title=Guide&content=Version+3"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("If-Match", ifMatch)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+
+	etag := get("").Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("expected the JSON representation to carry an ETag")
+	}
+
+	t.Run("matching If-None-Match is not modified", func(t *testing.T) {
+		rr := get("W/" + etag)
+		if rr.Code != http.StatusNotModified {
+			t.Fatalf("want status %d; got %d", http.StatusNotModified, rr.Code)
+		}
+		if rr.Body.Len() != 0 {
+			t.Errorf("expected an empty body, got %q", rr.Body.String())
+		}
+	})
+
+	t.Run("stale If-None-Match gets the page", func(t *testing.T) {
+		if rr := get(`"stale"`); rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "Version 2") {
+			t.Errorf("want status %d with the page; got %d", http.StatusOK, rr.Code)
+		}
+	})
+
+	t.Run("stale If-Match is rejected", func(t *testing.T) {
+		stale := pageETag(&data.Page{ID: 7, Title: "Guide", Content: "Version 1", UpdatedAt: current.UpdatedAt})
+		if rr := save(stale); rr.Code != http.StatusPreconditionFailed {
+			t.Fatalf("want status %d; got %d", http.StatusPreconditionFailed, rr.Code)
+		}
+		if updated {
+			t.Error("expected the page not to be updated")
+		}
+	})
+
+	t.Run("matching If-Match saves", func(t *testing.T) {
+		if rr := save(etag); rr.Code != http.StatusFound {
+			t.Fatalf("want status %d; got %d", http.StatusFound, rr.Code)
+		}
+		if !updated {
+			t.Error("expected the page to be updated")
+		}
+	})
+}
+
+func TestFaviconHandler(t *testing.T) {
+	custom := filepath.Join(t.TempDir(), "custom.ico")
+	if err := os.WriteFile(custom, []byte("\x00\x00\x01\x00custom-icon"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		site config.SiteConfig
+		want func(body []byte) bool
+	}{
+		{"bundled", config.SiteConfig{}, func(body []byte) bool { return bytes.HasPrefix(body, []byte("\x00\x00\x01\x00")) }},
+		{"configured", config.SiteConfig{FaviconPath: custom}, func(body []byte) bool { return string(body) == "\x00\x00\x01\x00custom-icon" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seoHandler := NewSeoHandler(&mockPageService{}, tt.site)
+			req := httptest.NewRequest("GET", "/favicon.ico", nil)
+			rr := httptest.NewRecorder()
+			seoHandler.faviconHandler(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+			}
+			if ct := rr.Header().Get("Content-Type"); ct != "image/x-icon" {
+				t.Errorf("want Content-Type image/x-icon; got %q", ct)
+			}
+			if cc := rr.Header().Get("Cache-Control"); !strings.Contains(cc, "max-age=2592000") {
+				t.Errorf("want a long-lived Cache-Control header; got %q", cc)
+			}
+			if !tt.want(rr.Body.Bytes()) {
+				t.Errorf("unexpected icon body %q", rr.Body.Bytes())
+			}
+		})
+	}
+}
+
+func TestPageMetadata_SaveAndRender(t *testing.T) {
+	var saved map[string]string
+	pageService := &mockPageService{
+		ViewPageFunc: func(ctx context.Context, title string) (*data.Page, error) {
+			return &data.Page{
+				ID:       3,
+				Title:    "Runbook",
+				Metadata: map[string]string{"review_date": "2025-01-31", "owner": "alice"},
+			}, nil
+		},
+		UpdatePageFunc: func(ctx context.Context, id int64, title, content, categoryName, subcategoryName string, minor bool) (*data.Page, error) {
+			return &data.Page{ID: id, Title: title}, nil
+		},
+		MetadataFieldsFunc: func() []string { return []string{"owner", "status", "review_date"} },
+		SetPageMetadataFunc: func(ctx context.Context, pageID int64, metadata map[string]string) error {
+			if pageID != 3 {
+				t.Errorf("expected metadata for page 3, got %d", pageID)
+			}
+			saved = metadata
+			return nil
+		},
+	}
+	viewService, _ := view.New(web.TemplateFS)
+	log := logger.New(config.LogConfig{Level: "info"})
+	pageHandler := NewPageHandler(pageService, viewService, log, nil)
+	r := chi.NewRouter()
+	r.Get("/view/{title}", func(w http.ResponseWriter, r *http.Request) {
+		pageHandler.viewHandler(w, r)
+	})
+	r.Post("/save/{title}", func(w http.ResponseWriter, r *http.Request) {
+		pageHandler.saveHandler(w, r)
+	})
+
+	t.Run("save", func(t *testing.T) {
+		form := "title=Runbook&content=Steps&meta_owner=bob&meta_status="
+		req := httptest.NewRequest("POST", "/save/Runbook", strings.NewReader(form))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusFound {
+			t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusFound)
+		}
+		want := map[string]string{"owner": "bob", "status": ""}
+		if fmt.Sprint(saved) != fmt.Sprint(want) {
+			t.Errorf("want metadata %v; got %v", want, saved)
+		}
+	})
+
+	t.Run("render", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/view/Runbook", nil)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		body := rr.Body.String()
+		if !strings.Contains(body, `<aside class="page-meta"`) {
+			t.Fatalf("expected a metadata sidebar, got %v", body)
+		}
+		owner := strings.Index(body, `<th scope="row">Owner</th><td>alice</td>`)
+		review := strings.Index(body, `<th scope="row">Review date</th><td>2025-01-31</td>`)
+		if owner == -1 || review == -1 || owner > review {
+			t.Errorf("expected metadata rows in configured order, got %v", body)
+		}
+		if strings.Contains(body, ">Status<") {
+			t.Error("expected unset fields to be left out of the sidebar")
+		}
+	})
+}
+
+// stubPDFConverter records the HTML it is given and returns a fixed PDF.
+type stubPDFConverter struct {
+	html []byte
+}
+
+func (c *stubPDFConverter) ConvertHTML(ctx context.Context, html []byte) ([]byte, error) {
+	c.html = html
+	return []byte("%PDF-1.4 stub"), nil
+}
+
+func TestPagePDFHandler(t *testing.T) {
+	pageService := &mockPageService{
+		ViewPageFunc: func(ctx context.Context, title string) (*data.Page, error) {
+			return &data.Page{Title: "Install Guide", HTMLContent: "<p>Run the <em>installer</em>.</p>"}, nil
+		},
+	}
+	viewService, _ := view.New(web.TemplateFS)
+	log := logger.New(config.LogConfig{Level: "info"})
+	newRouter := func(h *PageHandler) http.Handler {
+		r := chi.NewRouter()
+		r.Method("GET", "/export/{title}", middleware.Error(log, viewService)(h.pagePDFHandler))
+		return r
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/export/Install%20Guide.pdf", nil)
+		rr := httptest.NewRecorder()
+		newRouter(NewPageHandler(pageService, viewService, log, nil)).ServeHTTP(rr, req)
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("want status %d when PDF export is disabled; got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		converter := &stubPDFConverter{}
+		req := httptest.NewRequest("GET", "/export/Install%20Guide.pdf", nil)
+		rr := httptest.NewRecorder()
+		newRouter(NewPageHandler(pageService, viewService, log, nil, WithPDFExport(converter))).ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+		}
+		if ct := rr.Header().Get("Content-Type"); ct != "application/pdf" {
+			t.Errorf("want Content-Type application/pdf; got %q", ct)
+		}
+		if rr.Body.String() != "%PDF-1.4 stub" {
+			t.Errorf("expected the converter output as the body, got %q", rr.Body.String())
+		}
+		html := string(converter.html)
+		for _, want := range []string{
+			"<h1>Install Guide</h1>",
+			"<p>Run the <em>installer</em>.</p>",
+			"http://example.com/view/Install%20Guide",
+			time.Now().Format("2006-01-02"),
+		} {
+			if !strings.Contains(html, want) {
+				t.Errorf("expected converted HTML to contain %q, got %v", want, html)
+			}
+		}
+	})
+
+	t.Run("dotted title", func(t *testing.T) {
+		var viewed []string
+		dotted := &mockPageService{
+			ViewPageFunc: func(ctx context.Context, title string) (*data.Page, error) {
+				viewed = append(viewed, title)
+				return &data.Page{Title: title, HTMLContent: "<p>Runtime notes.</p>"}, nil
+			},
+		}
+		router := newRouter(NewPageHandler(dotted, viewService, log, nil, WithPDFExport(&stubPDFConverter{})))
+		for _, path := range []string{"/export/Node.js.pdf", "/export/v1.2.pdf"} {
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+			if rr.Code != http.StatusOK {
+				t.Errorf("%s: want status %d; got %d", path, http.StatusOK, rr.Code)
+			}
+		}
+		if !slices.Equal(viewed, []string{"Node.js", "v1.2"}) {
+			t.Errorf("expected the full titles to be exported, got %q", viewed)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", "/export/Node.js", nil))
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("want status %d without the .pdf suffix; got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+
+	t.Run("forbidden", func(t *testing.T) {
+		converter := &stubPDFConverter{}
+		req := httptest.NewRequest("GET", "/export/Install%20Guide.pdf", nil)
+		rr := httptest.NewRecorder()
+		newRouter(NewPageHandler(pageService, viewService, log, &mockPermissions{}, WithPDFExport(converter))).ServeHTTP(rr, req)
+		if rr.Code != http.StatusForbidden || converter.html != nil {
+			t.Errorf("want status %d without conversion; got %d", http.StatusForbidden, rr.Code)
+		}
+	})
+}
+
+func TestCategoryEPUBHandler_SkipsPageThatPanics(t *testing.T) {
+	pageService := &mockPageService{
+		GetPagesForCategoryFunc: func(ctx context.Context, categoryName string) ([]*data.Page, error) {
+			return []*data.Page{{Title: "Alpha"}, {Title: "Broken"}, {Title: "Gamma"}}, nil
+		},
+		ViewPageFunc: func(ctx context.Context, title string) (*data.Page, error) {
+			if title == "Broken" {
+				panic("pathological markdown")
+			}
+			return &data.Page{Title: title, HTMLContent: template.HTML("<p>Body of " + title + "</p>")}, nil
+		},
+	}
+	perms := &mockPermissions{allowed: map[string]bool{
+		fmt.Sprint("anonymous", "/view/Alpha", "GET"):  true,
+		fmt.Sprint("anonymous", "/view/Broken", "GET"): true,
+		fmt.Sprint("anonymous", "/view/Gamma", "GET"):  true,
+	}}
+	viewService, _ := view.New(web.TemplateFS)
+	log := logger.New(config.LogConfig{Level: "error"})
+	pageHandler := NewPageHandler(pageService, viewService, log, perms)
+	r := chi.NewRouter()
+	r.Method("GET", "/category/{categoryName}/export.epub", middleware.Error(log, viewService)(pageHandler.categoryEPUBHandler))
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("GET", "/category/Guides/export.epub", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	book, err := zip.NewReader(bytes.NewReader(rr.Body.Bytes()), int64(rr.Body.Len()))
+	if err != nil {
+		t.Fatalf("response is not a valid EPUB archive: %v", err)
+	}
+	var chapters []string
+	for _, f := range book.File {
+		if !strings.Contains(f.Name, "chapter") {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(rc)
+		rc.Close()
+		chapters = append(chapters, string(body))
+	}
+	if len(chapters) != 3 {
+		t.Fatalf("expected a chapter for every page, got %d", len(chapters))
+	}
+	if !strings.Contains(chapters[0], "Body of Alpha") || !strings.Contains(chapters[2], "Body of Gamma") {
+		t.Errorf("expected the other pages to be rendered, got %v", chapters)
+	}
+	if !strings.Contains(chapters[1], "could not be rendered") {
+		t.Errorf("expected a placeholder for the page that panicked, got %s", chapters[1])
+	}
+}
+
+func TestCategoryEPUBHandler(t *testing.T) {
+	pages := map[string]*data.Page{
+		"Beta":    {Title: "Beta", HTMLContent: "<p>Second<br>chapter</p>"},
+		"Alpha":   {Title: "Alpha", HTMLContent: "<p>First &amp; foremost</p>"},
+		"Secrets": {Title: "Secrets", HTMLContent: "<p>Private</p>"},
+	}
+	pageService := &mockPageService{
+		GetPagesForCategoryFunc: func(ctx context.Context, categoryName string) ([]*data.Page, error) {
+			return []*data.Page{{Title: "Beta"}, {Title: "Secrets"}, {Title: "Alpha"}}, nil
+		},
+		ViewPageFunc: func(ctx context.Context, title string) (*data.Page, error) {
+			return pages[title], nil
+		},
+	}
+	perms := &mockPermissions{allowed: map[string]bool{
+		fmt.Sprint("anonymous", "/view/Alpha", "GET"): true,
+		fmt.Sprint("anonymous", "/view/Beta", "GET"):  true,
+	}}
+	viewService, _ := view.New(web.TemplateFS)
+	log := logger.New(config.LogConfig{Level: "info"})
+	pageHandler := NewPageHandler(pageService, viewService, log, perms)
+	r := chi.NewRouter()
+	r.Method("GET", "/category/{categoryName}/export.epub", middleware.Error(log, viewService)(pageHandler.categoryEPUBHandler))
+
+	req := httptest.NewRequest("GET", "/category/Guides/export.epub", nil)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "application/epub+zip" {
+		t.Errorf("want Content-Type application/epub+zip; got %q", ct)
+	}
+
+	book, err := zip.NewReader(bytes.NewReader(rr.Body.Bytes()), int64(rr.Body.Len()))
+	if err != nil {
+		t.Fatalf("response is not a valid EPUB archive: %v", err)
+	}
+	var chapters []string
+	for _, f := range book.File {
+		if !strings.Contains(f.Name, "chapter") {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(rc)
+		rc.Close()
+		chapters = append(chapters, string(body))
+	}
+	if len(chapters) != 2 {
+		t.Fatalf("expected one chapter per viewable page (2), got %d", len(chapters))
+	}
+	if !strings.Contains(chapters[0], "<h1>Alpha</h1>") || !strings.Contains(chapters[1], "<br/>") {
+		t.Errorf("expected alphabetical XHTML chapters, got %v", chapters)
+	}
+	for _, chapter := range chapters {
+		if strings.Contains(chapter, "Private") {
+			t.Error("expected pages the user cannot view to be excluded")
+		}
+	}
+}
+
+func TestSearchHandler_Filters(t *testing.T) {
+	var got data.SearchFilter
+	pageService := &mockPageService{
+		SearchPagesFunc: func(ctx context.Context, filter data.SearchFilter) ([]*service.SearchResult, error) {
+			got = filter
+			return []*service.SearchResult{{
+				Page:    &data.Page{Title: "Deploy Guide", AuthorID: "alice"},
+				Snippet: "How to <mark>deploy</mark>",
+			}}, nil
+		},
+	}
+	viewService, _ := view.New(web.TemplateFS)
+	log := logger.New(config.LogConfig{Level: "info"})
+	pageHandler := NewPageHandler(pageService, viewService, log, nil)
+	r := chi.NewRouter()
+	r.Method("GET", "/search", middleware.Error(log, viewService)(pageHandler.searchHandler))
+
+	req := httptest.NewRequest("GET", "/search?q=deploy&category=Ops&author=alice&updated_after=2024-01-01&updated_before=2024-01-31&sort=title", nil)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	want := data.SearchFilter{
+		Query:         "deploy",
+		Category:      "Ops",
+		AuthorID:      "alice",
+		UpdatedAfter:  time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		UpdatedBefore: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+		Sort:          data.SearchSortTitle,
+		Limit:         searchResultLimit,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want filter %+v; got %+v", want, got)
+	}
+	if !strings.Contains(rr.Body.String(), `<a href="/view/Deploy%20Guide">Deploy Guide</a>`) || !strings.Contains(rr.Body.String(), "How to <mark>deploy</mark>") {
+		t.Errorf("expected the result and its snippet to be listed, got %v", rr.Body.String())
+	}
+
+	for _, bad := range []string{"sort=id DESC", "updated_after=yesterday", "updated_before=2024-13-01"} {
+		req := httptest.NewRequest("GET", "/search?q=x&"+strings.ReplaceAll(bad, " ", "%20"), nil)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: want status %d; got %d", bad, http.StatusBadRequest, rr.Code)
+		}
+	}
+}
+
+func TestLiveSearchHandler(t *testing.T) {
+	var queries []string
+	pageService := &mockPageService{
+		SearchPagesFunc: func(ctx context.Context, filter data.SearchFilter) ([]*service.SearchResult, error) {
+			queries = append(queries, filter.Query)
+			return []*service.SearchResult{{Page: &data.Page{Title: "Coding style"}, Snippet: "<mark>100%</mark> enforced"}}, nil
+		},
+	}
+	viewService, _ := view.New(web.TemplateFS)
+	log := logger.New(config.LogConfig{Level: "info"})
+	pageHandler := NewPageHandler(pageService, viewService, log, nil)
+	r := chi.NewRouter()
+	r.Method("GET", "/api/search/pages", middleware.Error(log, viewService)(pageHandler.liveSearchHandler))
+
+	search := func(q string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest("GET", "/api/search/pages?q="+url.QueryEscape(q), nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+		}
+		return rr
+	}
+
+	t.Run("empty query renders nothing", func(t *testing.T) {
+		rr := search("   ")
+		if len(queries) != 0 || strings.TrimSpace(rr.Body.String()) != "" {
+			t.Errorf("expected no search and an empty fragment, got %d searches and %q", len(queries), rr.Body.String())
+		}
+	})
+
+	t.Run("wildcards are passed on literally", func(t *testing.T) {
+		rr := search("100% de_loy")
+		if len(queries) != 1 || queries[0] != "100% de_loy" {
+			t.Fatalf("expected the query to reach the service unchanged, got %q", queries)
+		}
+		if !strings.Contains(rr.Body.String(), `<a href="/view/Coding%20style">Coding style</a>`) {
+			t.Errorf("expected the result to be listed, got %v", rr.Body.String())
+		}
+	})
+}
+
+func TestViewHandler_FindInPage(t *testing.T) {
+	pageService := &mockPageService{
+		ViewPageFunc: func(ctx context.Context, title string) (*data.Page, error) {
+			return &data.Page{
+				Title:       "Go",
+				HTMLContent: `<p>Go is fun. <a href="/view/Go" title="go">go</a> &amp; GO!</p><p>Ago</p>`,
+			}, nil
+		},
+	}
+	viewService, _ := view.New(web.TemplateFS)
+	log := logger.New(config.LogConfig{Level: "info"})
+	pageHandler := NewPageHandler(pageService, viewService, log, nil)
+	r := chi.NewRouter()
+	r.Get("/view/{title}", func(w http.ResponseWriter, r *http.Request) {
+		pageHandler.viewHandler(w, r)
+	})
+
+	req := httptest.NewRequest("GET", "/view/Go?find=go", nil)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	body := rr.Body.String()
+	want := `<p><mark id="find-1">Go</mark> is fun. <a href="/view/Go" title="go"><mark id="find-2">go</mark></a> &amp; <mark id="find-3">GO</mark>!</p><p>A<mark id="find-4">go</mark></p>`
+	if !strings.Contains(body, want) {
+		t.Errorf("expected every occurrence in the text to be marked without touching tags, got %v", body)
+	}
+	if !strings.Contains(body, "4 matches for “go”") {
+		t.Errorf("expected the match count to be shown, got %v", body)
+	}
+}
+
+// mockDashboardService is a mock implementation of service.DashboardServicer.
+type mockDashboardService struct {
+	stats       *data.ContentStats
+	categories  int
+	authors     int
+	activity    []*data.Activity
+	cacheStats  cache.Stats
+	healthErr   error
+	categoryErr error
+	tree        []*service.CategoryNode
+	mergeErr    error
+	merged      [2]int64
+	weights     map[int64]int
+	resanitized int
+}
+
+func (m *mockDashboardService) PageStats(ctx context.Context) (*data.ContentStats, error) {
+	return m.stats, nil
+}
+
+func (m *mockDashboardService) CategoryCount(ctx context.Context) (int, error) {
+	return m.categories, m.categoryErr
+}
+
+func (m *mockDashboardService) AuthorCount(ctx context.Context) (int, error) {
+	return m.authors, nil
+}
+
+func (m *mockDashboardService) CacheStats() cache.Stats {
+	return m.cacheStats
+}
+
+func (m *mockDashboardService) GetRecentActivity(ctx context.Context, filter data.ActivityFilter, limit, offset int) ([]*data.Activity, error) {
+	return m.activity, nil
+}
+
+func (m *mockDashboardService) DatabaseHealth(ctx context.Context) error {
+	return m.healthErr
+}
+
+func (m *mockDashboardService) PruneRevisions(ctx context.Context) (int, error) {
+	return 0, nil
+}
+
+func (m *mockDashboardService) ResanitizeAll(ctx context.Context) (int, error) {
+	return m.resanitized, nil
+}
+
+func (m *mockDashboardService) GetContributions(ctx context.Context, subject string) ([]*data.Revision, error) {
+	return nil, nil
+}
+
+func (m *mockDashboardService) RevertContributionsBy(ctx context.Context, subject string) ([]*service.RevertResult, error) {
+	return nil, nil
+}
+
+func (m *mockDashboardService) StartExternalLinkCheck() bool {
+	return false
+}
+
+func (m *mockDashboardService) LinkCheckStatus() service.LinkCheckStatus {
+	return service.LinkCheckStatus{}
+}
+
+func (m *mockDashboardService) GetDeadLinks(ctx context.Context) ([]*data.DeadLink, error) {
+	return nil, nil
+}
+
+func (m *mockDashboardService) SetPageOwner(ctx context.Context, title, owner string) error {
+	return nil
+}
+
+func (m *mockDashboardService) GetCategoryTree(ctx context.Context) ([]*service.CategoryNode, error) {
+	return m.tree, nil
+}
+
+func (m *mockDashboardService) MergeCategories(ctx context.Context, sourceID, targetID int64) error {
+	m.merged = [2]int64{sourceID, targetID}
+	return m.mergeErr
+}
+
+func (m *mockDashboardService) UpdateCategoryOrder(ctx context.Context, weights map[int64]int) error {
+	m.weights = weights
+	return nil
+}
+
+func TestAdminDashboardHandler(t *testing.T) {
+	viewService, _ := view.New(web.TemplateFS)
+	log := logger.New(config.LogConfig{Level: "error"})
+
+	t.Run("shows the key metrics", func(t *testing.T) {
+		ds := &mockDashboardService{
+			stats:      &data.ContentStats{PageCount: 42, ContentBytes: 1234},
+			categories: 7,
+			authors:    5,
+			cacheStats: cache.Stats{Hits: 3, Misses: 1},
+			activity:   []*data.Activity{{PageTitle: "EditedPage", Action: "update", AuthorID: "alice", CreatedAt: time.Now()}},
+		}
+		h := NewAdminHandler(ds, viewService, log)
+		rr := httptest.NewRecorder()
+		if appErr := h.dashboardHandler(rr, httptest.NewRequest("GET", "/admin", nil)); appErr != nil {
+			t.Fatalf("unexpected error: %v", appErr.Error)
+		}
+
+		body := rr.Body.String()
+		for _, want := range []string{
+			`id="stat-pages"><strong>42</strong>`,
+			`id="stat-categories"><strong>7</strong>`,
+			`id="stat-users"><strong>5</strong>`,
+			`<strong>75.0%</strong> hit rate`,
+			`id="stat-db-health"><strong>OK</strong>`,
+			"EditedPage",
+		} {
+			if !strings.Contains(body, want) {
+				t.Errorf("expected body to contain %q", want)
+			}
+		}
+	})
+
+	t.Run("a failing widget does not break the page", func(t *testing.T) {
+		ds := &mockDashboardService{
+			stats:       &data.ContentStats{},
+			categoryErr: errors.New("boom"),
+			healthErr:   errors.New("connection refused"),
+		}
+		h := NewAdminHandler(ds, viewService, log)
+		rr := httptest.NewRecorder()
+		if appErr := h.dashboardHandler(rr, httptest.NewRequest("GET", "/admin", nil)); appErr != nil {
+			t.Fatalf("unexpected error: %v", appErr.Error)
+		}
+
+		body := rr.Body.String()
+		if strings.Contains(body, `id="stat-categories"`) || !strings.Contains(body, "Unavailable") {
+			t.Error("expected the categories widget to be shown as unavailable")
+		}
+		if !strings.Contains(body, `id="stat-db-health"><strong>Unreachable</strong>`) {
+			t.Error("expected the database to be reported unreachable")
+		}
+	})
+}
+
+// mockReviewQueue is a ReviewQueue returning fixed reviews.
+type mockReviewQueue struct {
+	reviews []*service.PendingEditReview
+}
+
+func (m *mockReviewQueue) GetPendingEditReviews(ctx context.Context) ([]*service.PendingEditReview, error) {
+	return m.reviews, nil
+}
+
+func (m *mockReviewQueue) ApprovePendingEdit(ctx context.Context, id int64) (*data.Page, error) {
+	return nil, service.ErrPendingEditNotFound
+}
+
+func (m *mockReviewQueue) RejectPendingEdit(ctx context.Context, id int64) (*data.PendingEdit, error) {
+	return nil, service.ErrPendingEditNotFound
+}
+
+func TestReviewQueueHandler_ShowsDiffs(t *testing.T) {
+	viewService, _ := view.New(web.TemplateFS)
+	log := logger.New(config.LogConfig{Level: "error"})
+	queued := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	queue := &mockReviewQueue{reviews: []*service.PendingEditReview{
+		{
+			Edit:    &data.PendingEdit{ID: 4, PageID: 7, Title: "Runbook", Content: "intro\nnew step", AuthorID: "anonymous", AuthorIP: "192.0.2.7", CreatedAt: queued},
+			Page:    &data.Page{ID: 7, Title: "Runbook", UpdatedAt: queued.Add(-time.Hour)},
+			Lines:   diff.Lines("intro\nold step", "intro\nnew step"),
+			Added:   1,
+			Removed: 1,
+		},
+		{
+			Edit:     &data.PendingEdit{ID: 5, PageID: 8, Title: "Guide", Content: "stale", AuthorID: "bob", CreatedAt: queued},
+			Page:     &data.Page{ID: 8, Title: "Guide", UpdatedAt: queued.Add(time.Hour)},
+			Lines:    diff.Lines("fresh", "stale"),
+			Added:    1,
+			Removed:  1,
+			Conflict: true,
+		},
+	}}
+	h := NewAdminHandler(&mockDashboardService{}, viewService, log, WithReviewQueue(queue))
+	rr := httptest.NewRecorder()
+	if appErr := h.reviewQueueHandler(rr, httptest.NewRequest("GET", "/admin/review", nil)); appErr != nil {
+		t.Fatalf("unexpected error: %v", appErr.Error)
+	}
+
+	body := rr.Body.String()
+	for _, want := range []string{
+		`class="diff-line diff-delete">- old step`,
+		`class="diff-line diff-insert">+ new step`,
+		"By anonymous (192.0.2.7)",
+		"on 2025-03-01 12:00",
+		"+1 / -1 lines",
+		`action="/admin/review/4"`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected body to contain %q", want)
+		}
+	}
+	if strings.Count(body, `class="review-conflict"`) != 1 {
+		t.Fatalf("expected exactly one edit to be flagged as conflicting")
+	}
+	conflict := strings.Index(body, `class="review-conflict"`)
+	if conflict < strings.Index(body, `id="review-5"`) {
+		t.Error("expected the conflict warning on the edit to the page changed since it was queued")
+	}
+}
+
+func TestDiffHandler_AgainstCurrent(t *testing.T) {
+	var gotPageID, gotRevisionID int64
+	pageService := &mockPageService{
+		ViewPageFunc: func(ctx context.Context, title string) (*data.Page, error) {
+			return &data.Page{ID: 7, Title: title, Content: "intro\nnew line"}, nil
+		},
+		DiffAgainstCurrentFunc: func(ctx context.Context, pageID, revisionID int64) (*service.RevisionDiff, error) {
+			gotPageID, gotRevisionID = pageID, revisionID
+			if revisionID != 3 {
+				return nil, service.ErrRevisionNotFound
+			}
+			return &service.RevisionDiff{
+				Page:  &data.Page{ID: 7, Title: "Diffed"},
+				From:  &data.Revision{ID: 3, PageID: 7, AuthorID: "alice"},
+				Lines: diff.Lines("intro\nold line", "intro\nnew line"),
+			}, nil
+		},
+	}
+	viewService, _ := view.New(web.TemplateFS)
+	log := logger.New(config.LogConfig{Level: "error"})
+	pageHandler := NewPageHandler(pageService, viewService, log, nil)
+	r := chi.NewRouter()
+	r.Get("/diff/{title}", func(w http.ResponseWriter, r *http.Request) {
+		if appErr := pageHandler.diffHandler(w, r); appErr != nil {
+			w.WriteHeader(appErr.Code)
+		}
+	})
+
+	t.Run("omitting to compares with the current version", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest("GET", "/diff/Diffed?from=3", nil))
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("want status %d; got %d", http.StatusOK, rr.Code)
+		}
+		if gotPageID != 7 || gotRevisionID != 3 {
+			t.Errorf("expected revision 3 of page 7 to be diffed, got revision %d of page %d", gotRevisionID, gotPageID)
+		}
+		body := rr.Body.String()
+		for _, want := range []string{"to the current version", `class="diff-line diff-delete">- old line`, `class="diff-line diff-insert">+ new line`} {
+			if !strings.Contains(body, want) {
+				t.Errorf("expected body to contain %q", want)
+			}
+		}
+	})
+
+	t.Run("unknown revision is not found", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest("GET", "/diff/Diffed?from=99", nil))
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("want status %d; got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+
+	t.Run("missing from is a bad request", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest("GET", "/diff/Diffed", nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("want status %d; got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+}
+
+func TestPageEventsHandler_SkipsMinorEdits(t *testing.T) {
+	newService := func() *mockPageService {
+		return &mockPageService{
+			GetPageFunc: func(ctx context.Context, title string) (*data.Page, error) {
+				return &data.Page{ID: 1, Title: title}, nil
+			},
+			SubscribeToPageFunc: func(ctx context.Context, title string) (<-chan events.Event, func(), error) {
+				updates := make(chan events.Event, 2)
+				updates <- events.Event{Type: "updated", Title: "Typo fix", Minor: true}
+				updates <- events.Event{Type: "updated", Title: "Rewrite"}
+				close(updates)
+				return updates, func() {}, nil
+			},
+		}
+	}
+	log := logger.New(config.LogConfig{Level: "error"})
+
+	tests := []struct {
+		name      string
+		url       string
+		wantMinor bool
+	}{
+		{"minor edits are skipped by default", "/sse/page/Watched", false},
+		{"watchers can opt in to minor edits", "/sse/page/Watched?minor=1", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pageHandler := NewPageHandler(newService(), nil, log, nil)
+			r := chi.NewRouter()
+			r.Get("/sse/page/{title}", func(w http.ResponseWriter, r *http.Request) {
+				pageHandler.pageEventsHandler(w, r)
+			})
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, httptest.NewRequest("GET", tt.url, nil))
+
+			body := rr.Body.String()
+			if !strings.Contains(body, `"title":"Rewrite"`) {
+				t.Errorf("expected the regular edit to be delivered, got %q", body)
+			}
+			if got := strings.Contains(body, `"title":"Typo fix"`); got != tt.wantMinor {
+				t.Errorf("minor edit delivered = %v, want %v (body %q)", got, tt.wantMinor, body)
+			}
+		})
+	}
+}
+
+func TestMyPagesHandler(t *testing.T) {
+	var gotSubject string
+	pageService := &mockPageService{
+		GetPagesEditedByFunc: func(ctx context.Context, subject string, limit int) ([]*data.Page, error) {
+			gotSubject = subject
+			return []*data.Page{{Title: "Recent"}, {Title: "Older"}}, nil
+		},
+	}
+	viewService, _ := view.New(web.TemplateFS)
+	log := logger.New(config.LogConfig{Level: "info"})
+	pageHandler := NewPageHandler(pageService, viewService, log, nil)
+	r := chi.NewRouter()
+	r.Method("GET", "/my/pages", middleware.Error(log, viewService)(pageHandler.myPagesHandler))
+
+	t.Run("logged in user sees their pages", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/my/pages", nil)
+		req = req.WithContext(middleware.SetUserInfo(req.Context(), &middleware.UserInfo{Subject: "alice"}))
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", rr.Code)
+		}
+		if gotSubject != "alice" {
+			t.Errorf("expected pages to be looked up for alice, got %q", gotSubject)
+		}
+		body := rr.Body.String()
+		if strings.Index(body, "/view/Recent") > strings.Index(body, "/view/Older") {
+			t.Errorf("expected pages in the order returned by the service, got %s", body)
+		}
+	})
+
+	t.Run("anonymous user is asked to log in", func(t *testing.T) {
+		gotSubject = ""
+		req := httptest.NewRequest("GET", "/my/pages", nil)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", rr.Code)
+		}
+		if gotSubject != "" {
+			t.Errorf("expected no lookup for anonymous users, got %q", gotSubject)
+		}
+		if !strings.Contains(rr.Body.String(), "to see the pages you have created or edited") {
+			t.Errorf("expected a login prompt, got %s", rr.Body.String())
+		}
+	})
+}
+
+func TestBatchPagesHandler(t *testing.T) {
+	var requested []string
+	pageService := &mockPageService{
+		GetPagesByTitlesFunc: func(ctx context.Context, titles []string) (map[string]*data.Page, error) {
+			requested = titles
+			return map[string]*data.Page{
+				"Home": {ID: 1, Title: "Home", Content: "Welcome"},
+			}, nil
+		},
+	}
+	viewService, _ := view.New(web.TemplateFS)
+	log := logger.New(config.LogConfig{Level: "info"})
+	pageHandler := NewPageHandler(pageService, viewService, log, nil, WithMaxBatchSize(3))
+	r := chi.NewRouter()
+	r.Method("POST", "/api/pages/batch", middleware.Error(log, viewService)(pageHandler.batchPagesHandler))
+
+	batch := func(body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest("POST", "/api/pages/batch", strings.NewReader(body)))
+		return rr
+	}
+
+	t.Run("existing and missing titles", func(t *testing.T) {
+		rr := batch(`{"titles": ["Home", "Nowhere", "Home"]}`)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+		}
+		if len(requested) != 2 {
+			t.Errorf("expected duplicate titles to be fetched once, got %q", requested)
+		}
+		var got map[string]struct {
+			Status int       `json:"status"`
+			Page   *pageJSON `json:"page"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if home := got["Home"]; home.Status != http.StatusOK || home.Page == nil || home.Page.Content != "Welcome" {
+			t.Errorf("expected Home to be returned, got %+v", home)
+		}
+		if missing := got["Nowhere"]; missing.Status != http.StatusNotFound || missing.Page != nil {
+			t.Errorf("expected Nowhere to be marked as not found, got %+v", missing)
+		}
+	})
+
+	t.Run("too many titles", func(t *testing.T) {
+		rr := batch(`{"titles": ["A", "B", "C", "D"]}`)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+		}
+	})
+
+	t.Run("invalid body", func(t *testing.T) {
+		rr := batch(`titles=Home`)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+		}
+	})
+}
+
+func TestSitemapHandler_ConditionalRequests(t *testing.T) {
+	lastUpdate := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	pageService := &mockPageService{
+		PagesLastModifiedFunc: func(ctx context.Context) (time.Time, int, error) {
+			return lastUpdate, 1, nil
+		},
+		GetAllPagesFunc: func(ctx context.Context) ([]*data.Page, error) {
+			return []*data.Page{{Title: "Home", UpdatedAt: lastUpdate}}, nil
+		},
+	}
+	seoHandler := NewSeoHandler(pageService, config.SiteConfig{BaseURL: "https://wiki.example.com"})
+
+	get := func(header, value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/sitemap.xml", nil)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		rr := httptest.NewRecorder()
+		seoHandler.sitemapHandler(rr, req)
+		return rr
+	}
+
+	rr := get("", "")
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "<loc>https://wiki.example.com/view/Home</loc>") {
+		t.Fatalf("expected the sitemap with the configured host, got %v: %v", rr.Code, rr.Body.String())
+	}
+	if got := rr.Header().Get("Last-Modified"); got != lastUpdate.Format(http.TimeFormat) {
+		t.Errorf("expected Last-Modified to be the newest page update, got %q", got)
+	}
+	etag := rr.Header().Get("ETag")
+	if etag == "" {
+		t.Error("expected an ETag")
+	}
+
+	if rr := get("If-Modified-Since", lastUpdate.Add(time.Hour).Format(http.TimeFormat)); rr.Code != http.StatusNotModified || rr.Body.Len() != 0 {
+		t.Errorf("expected 304 when the client's copy is newer than the last update, got %v", rr.Code)
+	}
+	if rr := get("If-Modified-Since", lastUpdate.Format(http.TimeFormat)); rr.Code != http.StatusNotModified {
+		t.Errorf("expected 304 when the client's copy is as new as the last update, got %v", rr.Code)
+	}
+	if rr := get("If-Modified-Since", lastUpdate.Add(-time.Hour).Format(http.TimeFormat)); rr.Code != http.StatusOK {
+		t.Errorf("expected the sitemap when the client's copy is older than the last update, got %v", rr.Code)
+	}
+	if rr := get("If-None-Match", etag); rr.Code != http.StatusNotModified {
+		t.Errorf("expected 304 for a matching ETag, got %v", rr.Code)
+	}
+	if rr := get("If-None-Match", `"stale"`); rr.Code != http.StatusOK {
+		t.Errorf("expected the sitemap for a stale ETag, got %v", rr.Code)
+	}
+
+	robots := httptest.NewRecorder()
+	seoHandler.robotsHandler(robots, httptest.NewRequest("GET", "/robots.txt", nil))
+	if !strings.Contains(robots.Body.String(), "Sitemap: https://wiki.example.com/sitemap.xml") {
+		t.Errorf("expected robots.txt to point at the configured host, got %s", robots.Body.String())
+	}
+}
+
+type mockRoleLister []string
+
+func (m mockRoleLister) GetAllRoles() ([]string, error) {
+	return m, nil
+}
+
+func TestPageACL_RestrictsViewAndEdit(t *testing.T) {
+	var savedRole *string
+	var changed []string
+	pageService := &mockPageService{
+		ViewPageFunc: func(ctx context.Context, title string) (*data.Page, error) {
+			return &data.Page{ID: 4, Title: title, Content: "secret", HTMLContent: "<p>secret</p>", RequiredRole: "hr"}, nil
+		},
+		GetPageFunc: func(ctx context.Context, title string) (*data.Page, error) {
+			return &data.Page{ID: 4, Title: title, Content: "secret", RequiredRole: "hr"}, nil
+		},
+		UpdatePageFunc: func(ctx context.Context, id int64, title, content, categoryName, subcategoryName string, minor bool) (*data.Page, error) {
+			changed = append(changed, "update")
+			return &data.Page{ID: id, Title: title}, nil
+		},
+		DeletePageFunc: func(ctx context.Context, id int64) error {
+			changed = append(changed, "delete")
+			return nil
+		},
+		MovePageFunc: func(ctx context.Context, title, categoryName, subcategoryName string) error {
+			changed = append(changed, "move")
+			return nil
+		},
+		CanViewFunc: func(ctx context.Context, page *data.Page, userInfo *middleware.UserInfo) bool {
+			return page.RequiredRole == "" || slices.Contains(userInfo.Roles, page.RequiredRole)
+		},
+		SetPageRequiredRoleFunc: func(ctx context.Context, pageID int64, role string) error {
+			savedRole = &role
+			return nil
+		},
+	}
+	viewService, _ := view.New(web.TemplateFS)
+	log := logger.New(config.LogConfig{Level: "error"})
+	pageHandler := NewPageHandler(pageService, viewService, log, nil, WithRoles(mockRoleLister{"editor", "hr", "anonymous"}))
+
+	serve := func(req *http.Request, userInfo *middleware.UserInfo) *httptest.ResponseRecorder {
+		r := chi.NewRouter()
+		handle := func(h func(http.ResponseWriter, *http.Request) *middleware.AppError) http.HandlerFunc {
+			return func(w http.ResponseWriter, r *http.Request) {
+				if appErr := h(w, r); appErr != nil {
+					w.WriteHeader(appErr.Code)
+				}
+			}
+		}
+		r.Get("/view/{title}", handle(pageHandler.viewHandler))
+		r.Get("/edit/{title}", handle(pageHandler.editHandler))
+		r.Post("/save/{title}", handle(pageHandler.saveHandler))
+		r.Post("/delete/{title}", handle(pageHandler.deleteHandler))
+		r.Post("/move/{title}", handle(pageHandler.movePageHandler))
+		r.Get("/api/v1/pages/{title}", handle(pageHandler.apiGetPageHandler))
+		r.Put("/api/v1/pages/{title}", handle(pageHandler.apiUpdatePageHandler))
+		if userInfo != nil {
+			req = req.WithContext(middleware.SetUserInfo(req.Context(), userInfo))
+		}
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+	hr := &middleware.UserInfo{Subject: "carol", Roles: []string{"hr"}}
+	editor := &middleware.UserInfo{Subject: "bob", Roles: []string{"editor"}}
+
+	t.Run("allowed", func(t *testing.T) {
+		rr := serve(httptest.NewRequest("GET", "/view/Salaries", nil), hr)
+		if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "secret") {
+			t.Errorf("expected the page to be shown to a holder of the role, got %d", rr.Code)
+		}
+	})
+
+	t.Run("denied", func(t *testing.T) {
+		for _, path := range []string{"/view/Salaries", "/edit/Salaries"} {
+			rr := serve(httptest.NewRequest("GET", path, nil), editor)
+			if rr.Code != http.StatusForbidden {
+				t.Errorf("%s: want status %d; got %d", path, http.StatusForbidden, rr.Code)
+			}
+			if strings.Contains(rr.Body.String(), "secret") {
+				t.Errorf("%s: expected the content to be withheld", path)
+			}
+		}
+	})
+
+	t.Run("anonymous", func(t *testing.T) {
+		rr := serve(httptest.NewRequest("GET", "/view/Salaries", nil), nil)
+		if rr.Code != http.StatusFound || rr.Header().Get("Location") != "/auth/login" {
+			t.Errorf("expected a redirect to the login page, got %d to %q", rr.Code, rr.Header().Get("Location"))
+		}
+	})
+
+	t.Run("edit form lists roles", func(t *testing.T) {
+		body := serve(httptest.NewRequest("GET", "/edit/Salaries", nil), hr).Body.String()
+		if !strings.Contains(body, `<option value="hr" selected>`) || !strings.Contains(body, `<option value="editor">`) {
+			t.Errorf("expected a role dropdown with the page's role selected, got %s", body)
+		}
+		if strings.Contains(body, `<option value="anonymous"`) {
+			t.Error("expected the anonymous role to be left out")
+		}
+	})
+
+	t.Run("save", func(t *testing.T) {
+		post := func(form string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest("POST", "/save/Salaries", strings.NewReader(form))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			return serve(req, hr)
+		}
+		if rr := post("title=Salaries&content=secret&required_role=editor"); rr.Code != http.StatusFound {
+			t.Fatalf("want status %d; got %d", http.StatusFound, rr.Code)
+		}
+		if savedRole == nil || *savedRole != "editor" {
+			t.Errorf("expected the editor role to be saved, got %v", savedRole)
+		}
+		savedRole = nil
+		if rr := post("title=Salaries&content=secret&required_role=root"); rr.Code != http.StatusBadRequest {
+			t.Errorf("expected an unknown role to be rejected, got %d", rr.Code)
+		}
+		if savedRole != nil {
+			t.Error("expected nothing to be saved for an unknown role")
+		}
+	})
+
+	t.Run("editor without the role cannot change the page", func(t *testing.T) {
+		changed, savedRole = nil, nil
+		form := func(path, values string) *http.Request {
+			req := httptest.NewRequest("POST", path, strings.NewReader(values))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			return req
+		}
+		apiUpdate := httptest.NewRequest("PUT", "/api/v1/pages/Salaries", strings.NewReader(`{"content":"overwritten"}`))
+		apiUpdate.Header.Set("Content-Type", "application/json")
+		requests := map[string]*http.Request{
+			"save":       form("/save/Salaries", "title=Salaries&content=overwritten&required_role="),
+			"delete":     form("/delete/Salaries", ""),
+			"move":       form("/move/Salaries", "category=Public"),
+			"api update": apiUpdate,
+		}
+		for name, req := range requests {
+			if rr := serve(req, editor); rr.Code != http.StatusForbidden && rr.Code != http.StatusNotFound {
+				t.Errorf("%s: expected the change to be refused, got %d", name, rr.Code)
+			}
+		}
+		if len(changed) != 0 || savedRole != nil {
+			t.Errorf("expected the restricted page to be left alone, got %v (role %v)", changed, savedRole)
+		}
+	})
+
+	t.Run("api hides the page", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/v1/pages/Salaries", nil)
+		req.Header.Set("Accept", "application/json")
+		rr := serve(req, editor)
+		if rr.Code != http.StatusNotFound || strings.Contains(rr.Body.String(), "secret") {
+			t.Errorf("expected the restricted page to be hidden from the API, got %d", rr.Code)
+		}
+	})
+}
+
+func TestCategoryFeed_DiscoveryAndEntries(t *testing.T) {
+	base := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	pageService := &mockPageService{
+		GetPagesForCategoryFunc: func(ctx context.Context, categoryName string) ([]*data.Page, error) {
+			return []*data.Page{
+				{ID: 1, Title: "Older", AuthorID: "alice", UpdatedAt: base},
+				{ID: 2, Title: "Newer", AuthorID: "bob", UpdatedAt: base.Add(time.Hour)},
+			}, nil
+		},
+	}
+	viewService, _ := view.New(web.TemplateFS)
+	log := logger.New(config.LogConfig{Level: "error"})
+	pageHandler := NewPageHandler(pageService, viewService, log, nil, WithBaseURL("https://wiki.example.com"))
+	r := chi.NewRouter()
+	r.Get("/category/{categoryName}", func(w http.ResponseWriter, r *http.Request) {
+		pageHandler.viewByCategoryHandler(w, r)
+	})
+	r.Get("/category/{categoryName}/feed.xml", func(w http.ResponseWriter, r *http.Request) {
+		pageHandler.categoryFeedHandler(w, r)
+	})
+
+	t.Run("discovery link", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest("GET", "/category/Release%20Notes", nil))
+		want := `<link rel="alternate" type="application/atom+xml" title="Pages in Release Notes" href="https://wiki.example.com/category/Release%20Notes/feed.xml">`
+		if !strings.Contains(rr.Body.String(), want) {
+			t.Errorf("expected the category view to advertise its feed, got %s", rr.Body.String())
+		}
+	})
+
+	t.Run("feed", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest("GET", "/category/Release%20Notes/feed.xml", nil))
+		if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/atom+xml") {
+			t.Fatalf("expected Atom content type, got %q", ct)
+		}
+		var feed struct {
+			Entries []struct {
+				Title string `xml:"title"`
+			} `xml:"entry"`
+		}
+		if err := xml.Unmarshal(rr.Body.Bytes(), &feed); err != nil {
+			t.Fatalf("failed to parse feed: %v", err)
+		}
+		if len(feed.Entries) != 2 || feed.Entries[0].Title != "Newer" || feed.Entries[1].Title != "Older" {
+			t.Errorf("expected the pages most recently updated first, got %+v", feed.Entries)
+		}
+	})
+}
+
+func TestCategoryFeedHandler_OnlyRequestedCategory(t *testing.T) {
+	now := time.Now()
+	byCategory := map[string][]*data.Page{
+		"Ops":   {{ID: 1, Title: "Runbook", UpdatedAt: now}, {ID: 2, Title: "Pager", UpdatedAt: now}, {ID: 3, Title: "Secrets", UpdatedAt: now}},
+		"Sales": {{ID: 4, Title: "Pricing", UpdatedAt: now}},
+	}
+	pageService := &mockPageService{
+		GetPagesForCategoryFunc: func(ctx context.Context, categoryName string) ([]*data.Page, error) {
+			pages, ok := byCategory[categoryName]
+			if !ok {
+				return nil, fmt.Errorf("%w: %q", service.ErrCategoryNotFound, categoryName)
+			}
+			return pages, nil
+		},
+	}
+	perms := &mockPermissions{allowed: map[string]bool{
+		fmt.Sprint("anonymous", "/view/Runbook", "GET"): true,
+		fmt.Sprint("anonymous", "/view/Pager", "GET"):   true,
+		fmt.Sprint("anonymous", "/view/Pricing", "GET"): true,
+	}}
+	log := logger.New(config.LogConfig{Level: "error"})
+	pageHandler := NewPageHandler(pageService, nil, log, perms)
+	r := chi.NewRouter()
+	r.Get("/category/{categoryName}/feed.xml", func(w http.ResponseWriter, r *http.Request) {
+		if appErr := pageHandler.categoryFeedHandler(w, r); appErr != nil {
+			w.WriteHeader(appErr.Code)
+		}
+	})
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("GET", "/category/Ops/feed.xml", nil))
+	var feed struct {
+		Entries []struct {
+			Title string `xml:"title"`
+		} `xml:"entry"`
+	}
+	if err := xml.Unmarshal(rr.Body.Bytes(), &feed); err != nil {
+		t.Fatalf("failed to parse feed: %v", err)
+	}
+	var titles []string
+	for _, entry := range feed.Entries {
+		titles = append(titles, entry.Title)
+	}
+	if fmt.Sprint(titles) != "[Runbook Pager]" {
+		t.Errorf("expected only the visible pages of Ops, got %v", titles)
+	}
+
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("GET", "/category/Unknown/feed.xml", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown category, got %d", rr.Code)
+	}
+}
+
+func TestAdminStatusHandler(t *testing.T) {
+	log := logger.New(config.LogConfig{Level: "error"})
+	ds := &mockDashboardService{
+		stats:       &data.ContentStats{PageCount: 42, ContentBytes: 1234},
+		cacheStats:  cache.Stats{Hits: 3, Misses: 1},
+		categoryErr: errors.New("boom"),
+	}
+	h := NewAdminHandler(ds, nil, log, WithBuildInfo("1.2.3", "abc123"), WithDatabaseDriver("postgres"))
+	rr := httptest.NewRecorder()
+	if appErr := h.statusHandler(rr, httptest.NewRequest("GET", "/status", nil)); appErr != nil {
+		t.Fatalf("unexpected error: %v", appErr.Error)
+	}
+	if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Errorf("expected a JSON response, got %q", ct)
+	}
+
+	var report map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &report); err != nil {
+		t.Fatalf("failed to decode status: %v", err)
+	}
+	if _, ok := report["uptime_seconds"]; !ok {
+		t.Error("expected the uptime to be reported")
+	}
+	status := make(map[string]map[string]interface{})
+	for _, name := range []string{"build", "database", "cache", "pages", "categories", "runtime"} {
+		section, ok := report[name].(map[string]interface{})
+		if !ok {
+			t.Fatalf("expected a %q section, got %v", name, report)
+		}
+		status[name] = section
+	}
+	if status["build"]["version"] != "1.2.3" || status["build"]["commit"] != "abc123" {
+		t.Errorf("expected the build info, got %v", status["build"])
+	}
+	if status["database"]["driver"] != "postgres" || status["database"]["error"] != nil {
+		t.Errorf("expected a healthy postgres database, got %v", status["database"])
+	}
+	if status["cache"]["hit_rate"] != 0.75 {
+		t.Errorf("expected a 0.75 hit rate, got %v", status["cache"])
+	}
+	if status["pages"]["count"] != float64(42) {
+		t.Errorf("expected 42 pages, got %v", status["pages"])
+	}
+	if status["categories"]["error"] != "boom" {
+		t.Errorf("expected the failing section to report its error, got %v", status["categories"])
+	}
+	if goroutines, _ := status["runtime"]["goroutines"].(float64); goroutines < 1 {
+		t.Errorf("expected runtime stats, got %v", status["runtime"])
+	}
+}
+
+func TestViewHandler_MissingPageOffersCreation(t *testing.T) {
+	viewErr := fmt.Errorf("%w: %w", service.ErrPageNotFound, sql.ErrNoRows)
+	pageService := &mockPageService{
+		ViewPageFunc: func(ctx context.Context, title string) (*data.Page, error) {
+			return nil, viewErr
+		},
+	}
+	perms := &mockPermissions{allowed: map[string]bool{
+		fmt.Sprint("alice", "/edit/New Ideas", "GET"): true,
+	}}
+	viewService, _ := view.New(web.TemplateFS)
+	log := logger.New(config.LogConfig{Level: "error"})
+	pageHandler := NewPageHandler(pageService, viewService, log, perms)
+	r := chi.NewRouter()
+	r.Get("/view/{title}", func(w http.ResponseWriter, r *http.Request) {
+		if appErr := pageHandler.viewHandler(w, r); appErr != nil {
+			w.WriteHeader(appErr.Code)
+		}
+	})
+	request := func(subject string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/view/New%20Ideas", nil)
+		req = req.WithContext(middleware.SetUserInfo(req.Context(), &middleware.UserInfo{Subject: subject}))
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("editor", func(t *testing.T) {
+		rr := request("alice")
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("want status %d; got %d", http.StatusNotFound, rr.Code)
+		}
+		if !strings.Contains(rr.Body.String(), `<a href="/edit/New%20Ideas">Create it?</a>`) {
+			t.Errorf("expected a prompt to create the page, got %s", rr.Body.String())
+		}
+	})
+
+	t.Run("anonymous", func(t *testing.T) {
+		rr := request("anonymous")
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("want status %d; got %d", http.StatusNotFound, rr.Code)
+		}
+		if strings.Contains(rr.Body.String(), "Create it?") {
+			t.Error("expected no prompt for a user who cannot edit")
+		}
+	})
+
+	t.Run("load failure", func(t *testing.T) {
+		viewErr = errors.New("database is locked")
+		rr := request("alice")
+		if rr.Code != http.StatusInternalServerError {
+			t.Errorf("want status %d; got %d", http.StatusInternalServerError, rr.Code)
+		}
+		if strings.Contains(rr.Body.String(), "Create it?") {
+			t.Error("expected no prompt when the page could not be loaded")
+		}
+	})
+}
+
+func TestAdminMergeCategoriesHandler(t *testing.T) {
+	viewService, _ := view.New(web.TemplateFS)
+	log := logger.New(config.LogConfig{Level: "error"})
+
+	t.Run("dashboard offers the categories", func(t *testing.T) {
+		parentID := int64(1)
+		ds := &mockDashboardService{
+			stats: &data.ContentStats{},
+			tree: []*service.CategoryNode{{
+				Category: &data.Category{ID: 1, Name: "JS"},
+				Path:     "JS",
+				Children: []*service.CategoryNode{{Category: &data.Category{ID: 3, Name: "Frameworks", ParentID: &parentID}, Path: "JS/Frameworks"}},
+			}},
+		}
+		h := NewAdminHandler(ds, viewService, log)
+		rr := httptest.NewRecorder()
+		if appErr := h.dashboardHandler(rr, httptest.NewRequest("GET", "/admin", nil)); appErr != nil {
+			t.Fatalf("unexpected error: %v", appErr.Error)
+		}
+		body := rr.Body.String()
+		if !strings.Contains(body, `action="/admin/categories/merge"`) || !strings.Contains(body, `<option value="3">JS/Frameworks</option>`) {
+			t.Errorf("expected a merge form listing the categories, got %v", body)
+		}
+	})
+
+	t.Run("merges and redirects", func(t *testing.T) {
+		ds := &mockDashboardService{}
+		h := NewAdminHandler(ds, viewService, log)
+		req := httptest.NewRequest("POST", "/admin/categories/merge", strings.NewReader("source=1&target=2"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr := httptest.NewRecorder()
+		if appErr := h.mergeCategoriesHandler(rr, req); appErr != nil {
+			t.Fatalf("unexpected error: %v", appErr.Error)
+		}
+		if rr.Code != http.StatusSeeOther || ds.merged != [2]int64{1, 2} {
+			t.Errorf("expected category 1 to be merged into 2 and a redirect, got %d and %v", rr.Code, ds.merged)
+		}
+	})
+
+	t.Run("rejects an invalid merge", func(t *testing.T) {
+		ds := &mockDashboardService{mergeErr: service.ErrInvalidCategoryMerge}
+		h := NewAdminHandler(ds, viewService, log)
+		req := httptest.NewRequest("POST", "/admin/categories/merge", strings.NewReader("source=1&target=1"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		appErr := h.mergeCategoriesHandler(httptest.NewRecorder(), req)
+		if appErr == nil || appErr.Code != http.StatusBadRequest {
+			t.Errorf("expected a 400 error, got %v", appErr)
+		}
+	})
+}
+
+// memoryStore is an in-memory IdempotencyStore.
+type memoryStore map[string][]byte
+
+func (m memoryStore) Get(key string) ([]byte, error) { return m[key], nil }
+
+func (m memoryStore) Set(key string, value []byte, ttl time.Duration) error {
+	m[key] = value
+	return nil
+}
+
+func TestAPICreatePageHandler_IdempotencyKey(t *testing.T) {
+	var created []*data.Page
+	pageService := &mockPageService{
+		ViewPageFunc: func(ctx context.Context, title string) (*data.Page, error) {
+			for _, p := range created {
+				if p.Title == title {
+					return p, nil
+				}
+			}
+			return nil, errors.New("page not found")
+		},
+		CreatePageFunc: func(ctx context.Context, title, content, authorID, categoryName, subcategoryName string) (*data.Page, error) {
+			page := &data.Page{ID: int64(len(created) + 1), Title: title, Content: content, UpdatedAt: time.Now()}
+			created = append(created, page)
+			return page, nil
+		},
+	}
+	log := logger.New(config.LogConfig{Level: "error"})
+	pageHandler := NewPageHandler(pageService, nil, log, nil, WithIdempotencyStore(memoryStore{}, time.Hour))
+	create := func(key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/v1/pages", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(IdempotencyHeader, key)
+		req = req.WithContext(middleware.SetUserInfo(req.Context(), &middleware.UserInfo{Subject: "bot"}))
+		rr := httptest.NewRecorder()
+		if appErr := pageHandler.apiCreatePageHandler(rr, req); appErr != nil {
+			rr.Code = appErr.Code
+		}
+		return rr
+	}
+
+	first := create("key-1", `{"title": "Incident 42", "content": "Disk full"}`)
+	second := create("key-1", `{"title": "Incident 42", "content": "Disk full"}`)
+	if len(created) != 1 {
+		t.Fatalf("expected a single page to be created, got %d", len(created))
+	}
+	if first.Code != http.StatusCreated || second.Code != first.Code {
+		t.Errorf("expected both responses to be 201, got %d and %d", first.Code, second.Code)
+	}
+	if second.Body.String() != first.Body.String() || second.Header().Get("Location") != first.Header().Get("Location") {
+		t.Errorf("expected the retry to get the original response, got %q and %q", first.Body.String(), second.Body.String())
+	}
+	if rr := create("key-1", `{"title": "Incident 43"}`); rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 reusing the key for another page, got %d", rr.Code)
+	}
+}
+
+func TestPageCategories_SaveAndRender(t *testing.T) {
+	var saved []string
+	pageService := &mockPageService{
+		ViewPageFunc: func(ctx context.Context, title string) (*data.Page, error) {
+			return &data.Page{
+				ID:              3,
+				Title:           "Galileo",
+				CategoryName:    "Science",
+				SubcategoryName: "Astronomy",
+				ExtraCategories: []data.CategoryCrumb{{Name: "Renaissance", Path: "History/Renaissance"}},
+			}, nil
+		},
+		UpdatePageFunc: func(ctx context.Context, id int64, title, content, categoryName, subcategoryName string, minor bool) (*data.Page, error) {
+			return &data.Page{ID: id, Title: title}, nil
+		},
+		SetExtraCategoriesFunc: func(ctx context.Context, pageID int64, paths []string) error {
+			if pageID != 3 {
+				t.Errorf("expected categories for page 3, got %d", pageID)
+			}
+			saved = paths
+			return nil
+		},
+	}
+	viewService, _ := view.New(web.TemplateFS)
+	log := logger.New(config.LogConfig{Level: "info"})
+	pageHandler := NewPageHandler(pageService, viewService, log, nil)
+	r := chi.NewRouter()
+	r.Get("/view/{title}", func(w http.ResponseWriter, r *http.Request) {
+		pageHandler.viewHandler(w, r)
+	})
+	r.Post("/save/{title}", func(w http.ResponseWriter, r *http.Request) {
+		pageHandler.saveHandler(w, r)
+	})
+
+	t.Run("save", func(t *testing.T) {
+		form := "title=Galileo&content=Astronomer&extra_categories=History/Renaissance,+Biography"
+		req := httptest.NewRequest("POST", "/save/Galileo", strings.NewReader(form))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusFound {
+			t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusFound)
+		}
+		want := []string{"History/Renaissance", " Biography"}
+		if fmt.Sprint(saved) != fmt.Sprint(want) {
+			t.Errorf("want categories %q; got %q", want, saved)
+		}
+	})
+
+	t.Run("save without the field", func(t *testing.T) {
+		saved = nil
+		req := httptest.NewRequest("POST", "/save/Galileo", strings.NewReader("title=Galileo&content=Astronomer"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		if saved != nil {
+			t.Errorf("expected the page's categories to be left alone, got %q", saved)
+		}
+	})
+
+	t.Run("render", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/view/Galileo", nil)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		if body := rr.Body.String(); !strings.Contains(body, `<a href="/category/History/Renaissance">History/Renaissance</a>`) {
+			t.Errorf("expected a link to the extra category, got %v", body)
+		}
+	})
+}
+
+func TestRenameCategoryHandler(t *testing.T) {
+	viewService, _ := view.New(web.TemplateFS)
+	log := logger.New(config.LogConfig{Level: "error"})
+	var renamed string
+	pageService := &mockPageService{
+		GetCategoryTreeFunc: func(ctx context.Context) ([]*service.CategoryNode, error) {
+			parentID := int64(1)
+			return []*service.CategoryNode{{
+				Category: &data.Category{ID: 1, Name: "JS"},
+				Path:     "JS",
+				Children: []*service.CategoryNode{{Category: &data.Category{ID: 3, Name: "Frameworks", ParentID: &parentID}, Path: "JS/Frameworks"}},
+			}}, nil
+		},
+		RenameCategoryFunc: func(ctx context.Context, id int64, newName string) error {
+			switch newName {
+			case "Tools":
+				return fmt.Errorf("%w: %q", data.ErrCategoryExists, newName)
+			case "":
+				return service.ErrInvalidCategoryName
+			}
+			if id != 3 {
+				return service.ErrCategoryNotFound
+			}
+			renamed = newName
+			return nil
+		},
+	}
+	pageHandler := NewPageHandler(pageService, viewService, log, nil)
+	r := chi.NewRouter()
+	r.Method("POST", "/categories/{id}/rename", middleware.Error(log, viewService)(pageHandler.renameCategoryHandler))
+
+	rr := httptest.NewRecorder()
+	if appErr := pageHandler.categoriesHandler(rr, httptest.NewRequest("GET", "/categories", nil)); appErr != nil {
+		t.Fatalf("unexpected error: %v", appErr.Error)
+	}
+	body := rr.Body.String()
+	if !strings.Contains(body, `action="/categories/3/rename"`) {
+		t.Errorf("expected a rename form for each category, got %v", body)
+	}
+	// Only categories without subcategories can be deleted.
+	if !strings.Contains(body, `hx-delete="/categories/3"`) || strings.Contains(body, `hx-delete="/categories/1"`) {
+		t.Errorf("expected a delete button for Frameworks only, got %v", body)
+	}
+	// Readers who may not rename categories are not offered the forms.
+	reader := NewPageHandler(pageService, viewService, log, &mockPermissions{})
+	rr = httptest.NewRecorder()
+	reader.categoriesHandler(rr, httptest.NewRequest("GET", "/categories", nil))
+	if strings.Contains(rr.Body.String(), "/rename") {
+		t.Error("expected no rename forms for readers")
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		form     string
+		wantCode int
+	}{
+		{"renames and redirects", "/categories/3/rename", "name=Libraries", http.StatusSeeOther},
+		{"sibling has the name", "/categories/3/rename", "name=Tools", http.StatusConflict},
+		{"invalid name", "/categories/3/rename", "name=", http.StatusBadRequest},
+		{"unknown category", "/categories/9/rename", "name=Libraries", http.StatusNotFound},
+		{"invalid ID", "/categories/abc/rename", "name=Libraries", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", tt.path, strings.NewReader(tt.form))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+			if rr.Code != tt.wantCode {
+				t.Errorf("want status %d; got %d", tt.wantCode, rr.Code)
+			}
+		})
+	}
+	if renamed != "Libraries" {
+		t.Errorf("expected Frameworks to be renamed to Libraries, got %q", renamed)
+	}
+}
+
+func TestDeleteCategoryHandler(t *testing.T) {
+	viewService, _ := view.New(web.TemplateFS)
+	log := logger.New(config.LogConfig{Level: "error"})
+	var deleted []string
+	pageService := &mockPageService{
+		DeleteCategoryFunc: func(ctx context.Context, id int64, reassignToID *int64) error {
+			switch {
+			case id == 1:
+				return fmt.Errorf("%w: category 1 has 2", data.ErrCategoryHasSubcategories)
+			case id == 9:
+				return service.ErrCategoryNotFound
+			case reassignToID != nil && *reassignToID == id:
+				return service.ErrInvalidCategoryDelete
+			}
+			target := "default"
+			if reassignToID != nil {
+				target = fmt.Sprint(*reassignToID)
+			}
+			deleted = append(deleted, fmt.Sprintf("%d->%s", id, target))
+			return nil
+		},
+	}
+	pageHandler := NewPageHandler(pageService, viewService, log, nil)
+	r := chi.NewRouter()
+	r.Method("DELETE", "/categories/{id}", middleware.Error(log, viewService)(pageHandler.deleteCategoryHandler))
+
+	tests := []struct {
+		name     string
+		path     string
+		wantCode int
+	}{
+		{"reassign", "/categories/3?reassign_to=4", http.StatusNoContent},
+		{"orphan", "/categories/3", http.StatusNoContent},
+		{"has subcategories", "/categories/1", http.StatusConflict},
+		{"unknown category", "/categories/9", http.StatusNotFound},
+		{"reassign to itself", "/categories/3?reassign_to=3", http.StatusBadRequest},
+		{"invalid target", "/categories/3?reassign_to=abc", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, httptest.NewRequest("DELETE", tt.path, nil))
+			if rr.Code != tt.wantCode {
+				t.Errorf("want status %d; got %d", tt.wantCode, rr.Code)
+			}
+		})
+	}
+	if fmt.Sprint(deleted) != "[3->4 3->default]" {
+		t.Errorf("expected category 3 to be reassigned, then orphaned, got %v", deleted)
+	}
+
+	t.Run("htmx", func(t *testing.T) {
+		deleted = nil
+		// htmx sends the included select in the body of the DELETE request.
+		req := httptest.NewRequest("DELETE", "/categories/3", strings.NewReader("reassign_to=5"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("HX-Request", "true")
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		if got := rr.Header().Get("HX-Redirect"); got != "/categories" {
+			t.Errorf("expected htmx to be sent back to /categories, got %q", got)
+		}
+		if fmt.Sprint(deleted) != "[3->5]" {
+			t.Errorf("expected the pages to move to the category chosen in the form, got %v", deleted)
+		}
+	})
+}
+
+func TestAdminCategoryOrderHandler(t *testing.T) {
+	viewService, _ := view.New(web.TemplateFS)
+	log := logger.New(config.LogConfig{Level: "error"})
+
+	t.Run("dashboard offers the weights", func(t *testing.T) {
+		ds := &mockDashboardService{
+			stats: &data.ContentStats{},
+			tree: []*service.CategoryNode{{
+				Category: &data.Category{ID: 1, Name: "Getting Started", OrderWeight: -5},
+				Path:     "Getting Started",
+			}},
+		}
+		h := NewAdminHandler(ds, viewService, log)
+		rr := httptest.NewRecorder()
+		if appErr := h.dashboardHandler(rr, httptest.NewRequest("GET", "/admin", nil)); appErr != nil {
+			t.Fatalf("unexpected error: %v", appErr.Error)
+		}
+		if body := rr.Body.String(); !strings.Contains(body, `name="weight_1" value="-5"`) {
+			t.Errorf("expected a weight field for each category, got %v", body)
+		}
+	})
+
+	t.Run("saves and redirects", func(t *testing.T) {
+		ds := &mockDashboardService{}
+		h := NewAdminHandler(ds, viewService, log)
+		req := httptest.NewRequest("POST", "/admin/categories/order", strings.NewReader("weight_1=-5&weight_2=+10&csrf_token=x"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr := httptest.NewRecorder()
+		if appErr := h.categoryOrderHandler(rr, req); appErr != nil {
+			t.Fatalf("unexpected error: %v", appErr.Error)
+		}
+		if rr.Code != http.StatusSeeOther || fmt.Sprint(ds.weights) != "map[1:-5 2:10]" {
+			t.Errorf("expected the weights to be saved and a redirect, got %d and %v", rr.Code, ds.weights)
+		}
+	})
+
+	t.Run("rejects a weight that is not a number", func(t *testing.T) {
+		ds := &mockDashboardService{}
+		h := NewAdminHandler(ds, viewService, log)
+		req := httptest.NewRequest("POST", "/admin/categories/order", strings.NewReader("weight_1=first"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		appErr := h.categoryOrderHandler(httptest.NewRecorder(), req)
+		if appErr == nil || appErr.Code != http.StatusBadRequest || ds.weights != nil {
+			t.Errorf("expected a 400 error and nothing saved, got %v", appErr)
+		}
+	})
+}
+
+func TestMovePageHandler(t *testing.T) {
+	viewService, _ := view.New(web.TemplateFS)
+	log := logger.New(config.LogConfig{Level: "error"})
+	var movedTo string
+	pageService := &mockPageService{
+		ViewPageFunc: func(ctx context.Context, title string) (*data.Page, error) {
+			if title != "Quicksort" {
+				return nil, errors.New("page not found")
+			}
+			return &data.Page{ID: 5, Title: "Quicksort", Content: "Divide and conquer.",
+				Breadcrumb: []data.CategoryCrumb{{Name: "Algorithms", Path: "Algorithms"}, {Name: "Sorting", Path: "Algorithms/Sorting"}}}, nil
+		},
+		GetCategoryTreeFunc: func(ctx context.Context) ([]*service.CategoryNode, error) {
+			parentID := int64(1)
+			return []*service.CategoryNode{{
+				Category: &data.Category{ID: 1, Name: "Algorithms"},
+				Path:     "Algorithms",
+				Children: []*service.CategoryNode{{Category: &data.Category{ID: 2, Name: "Sorting", ParentID: &parentID}, Path: "Algorithms/Sorting"}},
+			}}, nil
+		},
+		GetPageFunc: func(ctx context.Context, title string) (*data.Page, error) {
+			switch title {
+			case "Quicksort":
+				return &data.Page{ID: 5, Title: title}, nil
+			case "Secret":
+				return &data.Page{ID: 6, Title: title, RequiredRole: "hr"}, nil
+			}
+			return nil, fmt.Errorf("%w: %w", service.ErrPageNotFound, sql.ErrNoRows)
+		},
+		CanViewFunc: func(ctx context.Context, page *data.Page, userInfo *middleware.UserInfo) bool {
+			return page.RequiredRole == "" || slices.Contains(userInfo.Roles, page.RequiredRole)
+		},
+		MovePageFunc: func(ctx context.Context, title, categoryName, subcategoryName string) error {
+			movedTo = title + ":" + categoryName + "|" + subcategoryName
+			return nil
+		},
+	}
+	pageHandler := NewPageHandler(pageService, viewService, log, nil)
+	r := chi.NewRouter()
+	r.Method("GET", "/view/{title}", middleware.Error(log, viewService)(pageHandler.viewHandler))
+	r.Method("POST", "/move/{title}", middleware.Error(log, viewService)(pageHandler.movePageHandler))
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("GET", "/view/Quicksort", nil))
+	body := rr.Body.String()
+	if !strings.Contains(body, `hx-post="/move/Quicksort"`) || !strings.Contains(body, `<option value="Algorithms/Sorting" selected>`) {
+		t.Errorf("expected a move form with the current category selected, got %v", body)
+	}
+	// Readers who may not move pages are not offered the form.
+	reader := NewPageHandler(pageService, viewService, log, &mockPermissions{})
+	rr = httptest.NewRecorder()
+	reader.viewHandler(rr, httptest.NewRequest("GET", "/view/Quicksort", nil))
+	if strings.Contains(rr.Body.String(), "/move/") {
+		t.Error("expected no move form for readers")
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		htmx     bool
+		wantCode int
+	}{
+		{"moves and redirects", "/move/Quicksort", false, http.StatusFound},
+		{"redirects through htmx", "/move/Quicksort", true, http.StatusOK},
+		{"unknown page", "/move/Nowhere", false, http.StatusNotFound},
+		{"home page", "/move/Home", false, http.StatusForbidden},
+		{"restricted page", "/move/Secret", false, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			movedTo = ""
+			req := httptest.NewRequest("POST", tt.path, strings.NewReader("category=Algorithms/Searching"))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req = req.WithContext(middleware.SetUserInfo(req.Context(), &middleware.UserInfo{Subject: "bob", Roles: []string{"editor"}}))
+			if tt.htmx {
+				req.Header.Set("HX-Request", "true")
+			}
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+			if rr.Code != tt.wantCode {
+				t.Fatalf("want status %d; got %d", tt.wantCode, rr.Code)
+			}
+			if tt.wantCode >= http.StatusBadRequest {
+				if movedTo != "" {
+					t.Errorf("expected the page not to be moved, got %q", movedTo)
+				}
+				return
+			}
+			if movedTo != "Quicksort:Algorithms/Searching|" {
+				t.Errorf("expected Quicksort to be moved to Algorithms/Searching, got %q", movedTo)
+			}
+			if tt.htmx && rr.Header().Get("HX-Redirect") != "/view/Quicksort" {
+				t.Errorf("expected an HX-Redirect to the page, got %q", rr.Header().Get("HX-Redirect"))
+			}
+		})
+	}
+}
+
+func TestDiffHandler_BetweenRevisions(t *testing.T) {
+	var gotFromID, gotToID int64
+	pageService := &mockPageService{
+		ViewPageFunc: func(ctx context.Context, title string) (*data.Page, error) {
+			return &data.Page{ID: 7, Title: title}, nil
+		},
+		DiffRevisionsFunc: func(ctx context.Context, pageID, fromID, toID int64) (*service.RevisionDiff, error) {
+			gotFromID, gotToID = fromID, toID
+			if toID == 99 {
+				return nil, service.ErrRevisionNotFound
+			}
+			from := &data.Revision{ID: fromID, PageID: 7, AuthorID: "alice", Content: "intro\nold line"}
+			to := &data.Revision{ID: toID, PageID: 7, AuthorID: "bob", Content: "intro\nnew line"}
+			if toID == fromID {
+				to.Content = from.Content
+			}
+			return &service.RevisionDiff{Page: &data.Page{ID: 7, Title: "Diffed"}, From: from, To: to, Lines: diff.Lines(from.Content, to.Content)}, nil
+		},
+	}
+	viewService, _ := view.New(web.TemplateFS)
+	log := logger.New(config.LogConfig{Level: "error"})
+	pageHandler := NewPageHandler(pageService, viewService, log, nil)
+	r := chi.NewRouter()
+	r.Get("/diff/{title}", func(w http.ResponseWriter, r *http.Request) {
+		if appErr := pageHandler.diffHandler(w, r); appErr != nil {
+			w.WriteHeader(appErr.Code)
+		}
+	})
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("GET", "/diff/Diffed?from=2&to=5", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("want status %d; got %d", http.StatusOK, rr.Code)
+	}
+	if gotFromID != 2 || gotToID != 5 {
+		t.Errorf("expected revisions 2 and 5 to be diffed, got %d and %d", gotFromID, gotToID)
+	}
+	body := rr.Body.String()
+	for _, want := range []string{"to the revision by bob", `class="diff-line diff-delete">- old line`, `class="diff-line diff-insert">+ new line`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected body to contain %q", want)
+		}
+	}
+
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("GET", "/diff/Diffed?from=2&to=2", nil))
+	if body := rr.Body.String(); !strings.Contains(body, "no differences") || strings.Contains(body, `class="diff-line`) {
+		t.Errorf("expected identical revisions to show no differences, got %v", body)
+	}
+
+	for target, want := range map[string]int{
+		"/diff/Diffed?from=2&to=99":     http.StatusNotFound,
+		"/diff/Diffed?from=2&to=latest": http.StatusBadRequest,
+		"/diff/Diffed?from=2&to=-1":     http.StatusBadRequest,
+	} {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest("GET", target, nil))
+		if rr.Code != want {
+			t.Errorf("%s: want status %d; got %d", target, want, rr.Code)
+		}
+	}
+}
+
+func TestViewHandler_SimilarPagesAreVisible(t *testing.T) {
+	expired := time.Now().Add(-time.Hour)
+	pageService := &mockPageService{
+		ViewPageFunc: func(ctx context.Context, title string) (*data.Page, error) {
+			return &data.Page{ID: 1, Title: title, Content: "Release checklist"}, nil
+		},
+		FindSimilarContentFunc: func(ctx context.Context, content string) ([]*data.Page, error) {
+			return []*data.Page{
+				{ID: 2, Title: "Shipping"},
+				{ID: 3, Title: "OldChecklist", ExpiresAt: &expired},
+			}, nil
+		},
+	}
+	viewService, _ := view.New(web.TemplateFS)
+	log := logger.New(config.LogConfig{Level: "error"})
+	perms := &mockPermissions{allowed: map[string]bool{
+		fmt.Sprint("anonymous", "/view/Release", "GET"):      true,
+		fmt.Sprint("anonymous", "/view/Shipping", "GET"):     true,
+		fmt.Sprint("anonymous", "/view/OldChecklist", "GET"): true,
+	}}
+	pageHandler := NewPageHandler(pageService, viewService, log, perms)
+	r := chi.NewRouter()
+	r.Method("GET", "/view/{title}", middleware.Error(log, viewService)(pageHandler.viewHandler))
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("GET", "/view/Release?similar=1", nil))
+	body := rr.Body.String()
+	if !strings.Contains(body, `href="/view/Shipping"`) {
+		t.Errorf("expected the visible near-duplicate to be listed, got %v", body)
+	}
+	if strings.Contains(body, "OldChecklist") {
+		t.Error("expected the archived near-duplicate to be hidden from readers")
+	}
+}
+
+func TestAdminResanitizeHandler(t *testing.T) {
+	viewService, _ := view.New(web.TemplateFS)
+	log := logger.New(config.LogConfig{Level: "error"})
+	adminHandler := NewAdminHandler(&mockDashboardService{resanitized: 2}, viewService, log)
+
+	rr := httptest.NewRecorder()
+	if appErr := adminHandler.resanitizeHandler(rr, httptest.NewRequest("POST", "/admin/resanitize", nil)); appErr != nil {
+		t.Fatalf("unexpected error: %v", appErr.Error)
+	}
+	if got := strings.TrimSpace(rr.Body.String()); got != `{"changed":2}` {
+		t.Errorf("expected the number of changed pages, got %s", got)
+	}
+}
+
+func TestHistoryHandlers_HideArchivedPages(t *testing.T) {
+	expired := time.Now().Add(-time.Hour)
+	archived := &data.Page{ID: 1, Title: "OldChecklist", Content: "secret", ExpiresAt: &expired}
+	pageService := &mockPageService{
+		ViewPageFunc: func(ctx context.Context, title string) (*data.Page, error) {
+			return archived, nil
+		},
+		GetPageHistoryFunc: func(ctx context.Context, title string) (*data.Page, []*data.Revision, error) {
+			return archived, []*data.Revision{{ID: 7, PageID: 1, Title: "OldChecklist", Content: "secret", AuthorID: "alice"}}, nil
+		},
+		DiffAgainstCurrentFunc: func(ctx context.Context, pageID, revisionID int64) (*service.RevisionDiff, error) {
+			return &service.RevisionDiff{}, nil
+		},
+	}
+	viewService, _ := view.New(web.TemplateFS)
+	log := logger.New(config.LogConfig{Level: "error"})
+	perms := &mockPermissions{allowed: map[string]bool{
+		fmt.Sprint("anonymous", "/view/OldChecklist", "GET"): true,
+	}}
+	pageHandler := NewPageHandler(pageService, viewService, log, perms)
+	r := chi.NewRouter()
+	r.Method("GET", "/history/{title}", middleware.Error(log, viewService)(pageHandler.historyHandler))
+	r.Method("GET", "/diff/{title}", middleware.Error(log, viewService)(pageHandler.diffHandler))
+	r.Method("GET", "/view/{title}/feed.xml", middleware.Error(log, viewService)(pageHandler.pageFeedHandler))
+
+	for _, path := range []string{"/history/OldChecklist", "/diff/OldChecklist?from=7", "/view/OldChecklist/feed.xml"} {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("%s: want status %d; got %d", path, http.StatusNotFound, rr.Code)
+		}
+		if strings.Contains(rr.Body.String(), "alice") {
+			t.Errorf("%s: expected the revisions to be withheld", path)
+		}
+	}
+}
+
+func TestAPIAppendEntryHandler(t *testing.T) {
+	var appended []string
+	pageService := &mockPageService{
+		GetPageFunc: func(ctx context.Context, title string) (*data.Page, error) {
+			if title == "Payroll 2024-06-01" {
+				return &data.Page{ID: 7, Title: title, Content: "salaries", RequiredRole: "hr"}, nil
+			}
+			return nil, fmt.Errorf("%w: %q", service.ErrPageNotFound, title)
+		},
+		ViewPageFunc: func(ctx context.Context, title string) (*data.Page, error) {
+			return nil, service.ErrRenderBusy
+		},
+		AppendEntryFunc: func(ctx context.Context, collection string, date time.Time, body, authorID string) (*data.Page, bool, error) {
+			appended = append(appended, body)
+			return &data.Page{ID: 3, Title: service.EntryTitle(collection, date), Content: body, UpdatedAt: time.Now()}, true, nil
+		},
+		CanViewFunc: func(ctx context.Context, page *data.Page, userInfo *middleware.UserInfo) bool {
+			return page.RequiredRole == "" || slices.Contains(userInfo.Roles, page.RequiredRole)
+		},
+	}
+	log := logger.New(config.LogConfig{Level: "error"})
+	pageHandler := NewPageHandler(pageService, nil, log, nil)
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/v1/entries", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req = req.WithContext(middleware.SetUserInfo(req.Context(), &middleware.UserInfo{Subject: "bot", Roles: []string{"editor"}}))
+		rr := httptest.NewRecorder()
+		if appErr := pageHandler.appendEntryFromAPI(rr, req); appErr != nil {
+			rr.Code = appErr.Code
+		}
+		return rr
+	}
+
+	// A busy renderer does not fail an entry that was already saved.
+	rr := post(`{"collection": "Incidents", "date": "2024-06-01", "body": "Database failover."}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var got map[string]any
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil || got["content"] != "Database failover." {
+		t.Errorf("expected the saved page in the response, got %s (%v)", rr.Body.String(), err)
+	}
+	if rr.Header().Get("ETag") == "" {
+		t.Error("expected an ETag for the saved page")
+	}
+
+	if rr := post(`{"collection": "Payroll", "date": "2024-06-01", "body": "Bonus paid."}`); rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a page the user cannot see, got %d", rr.Code)
+	}
+	if len(appended) != 1 {
+		t.Errorf("expected only the visible entry to be appended, got %q", appended)
+	}
+}
+
+func TestHistoryHandler_CompareColumns(t *testing.T) {
+	page := &data.Page{ID: 1, Title: "Guide", Content: "v2"}
+	revisions := []*data.Revision{{ID: 8, PageID: 1, Title: "Guide", Content: "v2", AuthorID: "alice"}}
+	pageService := &mockPageService{
+		GetPageHistoryFunc: func(ctx context.Context, title string) (*data.Page, []*data.Revision, error) {
+			return page, revisions, nil
+		},
+	}
+	viewService, _ := view.New(web.TemplateFS)
+	log := logger.New(config.LogConfig{Level: "error"})
+	pageHandler := NewPageHandler(pageService, viewService, log, nil)
+	r := chi.NewRouter()
+	r.Method("GET", "/history/{title}", middleware.Error(log, viewService)(pageHandler.historyHandler))
+	history := func() string {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest("GET", "/history/Guide", nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("want status %d; got %d", http.StatusOK, rr.Code)
+		}
+		return rr.Body.String()
+	}
+
+	if body := history(); strings.Contains(body, "<th>From</th>") || strings.Contains(body, `name="from"`) {
+		t.Error("expected no compare columns for a single revision")
+	}
+	revisions = append([]*data.Revision{{ID: 9, PageID: 1, Title: "Guide", Content: "v3", AuthorID: "bob"}}, revisions...)
+	if body := history(); !strings.Contains(body, "<th>From</th>") || strings.Count(body, `name="from"`) != 2 {
+		t.Error("expected compare columns once there are two revisions")
+	}
+}
+
+func TestExistenceChecks_RenderBusy(t *testing.T) {
+	var updated, created []string
+	pageService := &mockPageService{
+		ViewPageFunc: func(ctx context.Context, title string) (*data.Page, error) {
+			return nil, service.ErrRenderBusy
+		},
+		GetPageFunc: func(ctx context.Context, title string) (*data.Page, error) {
+			return &data.Page{ID: 3, Title: title, Content: "Steps"}, nil
+		},
+		UpdatePageFunc: func(ctx context.Context, id int64, title, content, categoryName, subcategoryName string, minor bool) (*data.Page, error) {
+			updated = append(updated, title)
+			return &data.Page{ID: id, Title: title, Content: content}, nil
+		},
+		CreatePageFunc: func(ctx context.Context, title, content, authorID, categoryName, subcategoryName string) (*data.Page, error) {
+			created = append(created, title)
+			return nil, errors.New("UNIQUE constraint failed: pages.title")
+		},
+	}
+	log := logger.New(config.LogConfig{Level: "error"})
+	pageHandler := NewPageHandler(pageService, nil, log, nil)
+	serve := func(h func(http.ResponseWriter, *http.Request) *middleware.AppError, req *http.Request) int {
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("title", "Runbook")
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		rr := httptest.NewRecorder()
+		if appErr := h(rr, req); appErr != nil {
+			return appErr.Code
+		}
+		return rr.Code
+	}
+
+	req := httptest.NewRequest("POST", "/save/Runbook", strings.NewReader("title=Runbook&content=More+steps"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if code := serve(pageHandler.saveHandler, req); code != http.StatusFound {
+		t.Errorf("save: want status %d; got %d", http.StatusFound, code)
+	}
+	if !slices.Equal(updated, []string{"Runbook"}) {
+		t.Errorf("expected the existing page to be updated, got %q", updated)
+	}
+
+	req = httptest.NewRequest("POST", "/api/v1/pages", strings.NewReader(`{"title": "Runbook", "content": "Steps"}`))
+	req.Header.Set("Content-Type", "application/json")
+	if code := serve(pageHandler.createPageFromAPI, req); code != http.StatusConflict {
+		t.Errorf("api create: want status %d; got %d", http.StatusConflict, code)
+	}
+	req = httptest.NewRequest("POST", "/new/Runbook", strings.NewReader("template=Checklist"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if code := serve(pageHandler.createFromTemplateHandler, req); code != http.StatusConflict {
+		t.Errorf("template create: want status %d; got %d", http.StatusConflict, code)
+	}
+	if len(created) != 0 {
+		t.Errorf("expected no existing page to be created again, got %q", created)
+	}
+}
+
+func TestPageACL_HidesEventsAndChanges(t *testing.T) {
+	restricted, public := int64(4), int64(5)
+	var subscribed []string
+	pageService := &mockPageService{
+		GetPageFunc: func(ctx context.Context, title string) (*data.Page, error) {
+			if title == "Salaries" {
+				return &data.Page{ID: restricted, Title: title, RequiredRole: "hr"}, nil
+			}
+			return &data.Page{ID: public, Title: title}, nil
+		},
+		SubscribeToPageFunc: func(ctx context.Context, title string) (<-chan events.Event, func(), error) {
+			subscribed = append(subscribed, title)
+			updates := make(chan events.Event)
+			close(updates)
+			return updates, func() {}, nil
+		},
+		GetRecentActivityFunc: func(ctx context.Context, filter data.ActivityFilter, limit, offset int) ([]*data.Activity, error) {
+			return []*data.Activity{
+				{ID: 1, PageID: &restricted, PageTitle: "Salaries", Action: data.ActivityUpdate, AuthorID: "carol", CreatedAt: time.Now()},
+				{ID: 2, PageID: &public, PageTitle: "Handbook", Action: data.ActivityUpdate, AuthorID: "dave", CreatedAt: time.Now()},
+			}, nil
+		},
+		CanViewFunc: func(ctx context.Context, page *data.Page, userInfo *middleware.UserInfo) bool {
+			return page.ID != restricted || slices.Contains(userInfo.Roles, "hr")
+		},
+	}
+	viewService, _ := view.New(web.TemplateFS)
+	log := logger.New(config.LogConfig{Level: "error"})
+	pageHandler := NewPageHandler(pageService, viewService, log, nil)
+	r := chi.NewRouter()
+	r.Method("GET", "/sse/page/{title}", middleware.Error(log, viewService)(pageHandler.pageEventsHandler))
+	r.Method("GET", "/changes", middleware.Error(log, viewService)(pageHandler.changesHandler))
+	serve := func(path string, roles ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req = req.WithContext(middleware.SetUserInfo(req.Context(), &middleware.UserInfo{Subject: "bob", Roles: roles}))
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := serve("/sse/page/Salaries"); rr.Code != http.StatusNotFound {
+		t.Errorf("want status %d watching a restricted page; got %d", http.StatusNotFound, rr.Code)
+	}
+	serve("/sse/page/Salaries", "hr")
+	serve("/sse/page/Handbook")
+	if !slices.Equal(subscribed, []string{"Salaries", "Handbook"}) {
+		t.Errorf("expected only the permitted subscriptions, got %q", subscribed)
+	}
+
+	body := serve("/changes").Body.String()
+	if strings.Contains(body, "Salaries") || strings.Contains(body, "carol") {
+		t.Error("expected the restricted page's activity to be hidden")
+	}
+	if !strings.Contains(body, "Handbook") {
+		t.Error("expected the public page's activity to be listed")
+	}
+	if body := serve("/changes", "hr").Body.String(); !strings.Contains(body, "Salaries") {
+		t.Error("expected the restricted page's activity to be listed for its role")
+	}
+}
+
+func TestIdempotent_ConcurrentDuplicate(t *testing.T) {
+	entered, release := make(chan struct{}), make(chan struct{})
+	var appended int
+	pageService := &mockPageService{
+		GetPageFunc: func(ctx context.Context, title string) (*data.Page, error) {
+			return nil, fmt.Errorf("%w: %q", service.ErrPageNotFound, title)
+		},
+		AppendEntryFunc: func(ctx context.Context, collection string, date time.Time, body, authorID string) (*data.Page, bool, error) {
+			appended++
+			entered <- struct{}{}
+			<-release
+			return &data.Page{ID: 3, Title: service.EntryTitle(collection, date), Content: body, UpdatedAt: time.Now()}, true, nil
+		},
+	}
+	log := logger.New(config.LogConfig{Level: "error"})
+	pageHandler := NewPageHandler(pageService, nil, log, nil, WithIdempotencyStore(memoryStore{}, time.Hour))
+	post := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/v1/entries", strings.NewReader(`{"collection": "Incidents", "date": "2024-06-01", "body": "Disk full."}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(IdempotencyHeader, "key-1")
+		req = req.WithContext(middleware.SetUserInfo(req.Context(), &middleware.UserInfo{Subject: "bot"}))
+		rr := httptest.NewRecorder()
+		if appErr := pageHandler.apiAppendEntryHandler(rr, req); appErr != nil {
+			rr.Code = appErr.Code
+		}
+		return rr
+	}
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- post() }()
+	<-entered
+	if rr := post(); rr.Code != http.StatusConflict {
+		t.Errorf("want status %d for a retry while the first request runs; got %d", http.StatusConflict, rr.Code)
+	}
+	close(release)
+	first := <-done
+	if first.Code != http.StatusCreated {
+		t.Fatalf("want status %d for the first request; got %d", http.StatusCreated, first.Code)
+	}
+	if rr := post(); rr.Code != http.StatusCreated || rr.Body.String() != first.Body.String() {
+		t.Errorf("expected a later retry to replay the first response, got %d %q", rr.Code, rr.Body.String())
+	}
+	if appended != 1 {
+		t.Errorf("expected the entry to be appended once, got %d", appended)
+	}
+}
@@ -12,30 +12,64 @@ import (
 	chiMiddleware "github.com/go-chi/chi/v5/middleware"
 )
 
+// compressibleContentTypes are the response types gzip-compressed for clients
+// that accept it: HTML pages, the Markdown and JSON representations of pages,
+// feeds, and static assets.
+var compressibleContentTypes = []string{
+	"text/html",
+	"text/css",
+	"text/plain",
+	"text/markdown",
+	"text/javascript",
+	"application/javascript",
+	"application/json",
+	"application/atom+xml",
+	"application/rss+xml",
+	"application/xml",
+	"image/svg+xml",
+}
+
 // NewRouter creates and configures a new chi router.
 func NewRouter(
 	pageHandler *PageHandler,
 	authHandler *AuthHandler,
 	seoHandler *SeoHandler,
+	adminHandler *AdminHandler,
 	authzMiddleware func(http.Handler) http.Handler,
 	errorMiddleware func(middleware.AppHandler) http.Handler,
+	sessionExpiryMiddleware func(http.Handler) http.Handler,
+	securityHeadersMiddleware func(http.Handler) http.Handler,
+	authRateLimitMiddleware func(http.Handler) http.Handler,
 	sessionManager session.Manager,
 ) *chi.Mux {
 	r := chi.NewRouter()
 
 	r.Use(chiMiddleware.RequestID)
+	r.Use(middleware.RequestLogger(pageHandler.log))
 	r.Use(chiMiddleware.RealIP)
 	r.Use(chiMiddleware.Logger)
-	r.Use(chiMiddleware.Compress(5))
+	r.Use(securityHeadersMiddleware)
+	r.Use(chiMiddleware.Compress(5, compressibleContentTypes...))
 	r.Use(sessionManager.LoadAndSave)
+	r.Use(sessionExpiryMiddleware)
+	// Forms must send back the session's CSRF token. The OIDC callback is
+	// reached by the identity provider's redirect, which cannot carry one.
+	r.Use(middleware.CSRF(sessionManager, "/auth/callback"))
 	r.Use(middleware.SettingsMiddleware)
 
+	// Known paths requested with an unsupported method get a styled 405 listing
+	// the allowed methods, and OPTIONS requests are answered from the same list.
+	r.MethodNotAllowed(middleware.MethodNotAllowed(pageHandler.view))
+	r.NotFound(middleware.NotFound(pageHandler.view))
+
 	staticFS, _ := fs.Sub(web.StaticFS, "static")
 	fileServer := http.FileServer(http.FS(staticFS))
 	r.Handle("/static/*", http.StripPrefix("/static/", fileServer))
+	r.Get("/static/css/highlight.css", pageHandler.highlightCSSHandler)
 
 	// SEO routes
 	r.Get("/robots.txt", seoHandler.robotsHandler)
+	r.Get("/favicon.ico", seoHandler.faviconHandler)
 	r.Get("/sitemap.xml", seoHandler.sitemapHandler)
 
 	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
@@ -44,6 +78,9 @@ func NewRouter(
 
 	r.Group(func(r chi.Router) {
 		if authHandler != nil {
+			// Each login starts a new OIDC flow whose state is kept in the session
+			// store, so clients may only start so many.
+			r.Use(authRateLimitMiddleware)
 			r.Get("/auth/login", authHandler.handleLogin)
 			r.Get("/auth/callback", authHandler.handleCallback)
 			r.Get("/auth/logout", authHandler.handleLogout)
@@ -53,13 +90,62 @@ func NewRouter(
 	r.Group(func(r chi.Router) {
 		r.Use(authzMiddleware)
 		r.Method("GET", "/view/{title}", errorMiddleware(pageHandler.viewHandler))
+		r.Method("GET", "/view/{title}/feed.xml", errorMiddleware(pageHandler.pageFeedHandler))
+		r.Method("GET", "/sse/page/{title}", errorMiddleware(pageHandler.pageEventsHandler))
+		r.Method("GET", "/export/{title}", errorMiddleware(pageHandler.pagePDFHandler))
+		r.Method("GET", "/history/{title}", errorMiddleware(pageHandler.historyHandler))
+		r.Method("GET", "/diff/{title}", errorMiddleware(pageHandler.diffHandler))
+		r.Method("POST", "/rollback/{title}/{revisionID}", errorMiddleware(pageHandler.rollbackHandler))
 		r.Method("GET", "/edit/{title}", errorMiddleware(pageHandler.editHandler))
 		r.Method("POST", "/save/{title}", errorMiddleware(pageHandler.saveHandler))
 		r.Method("GET", "/list", errorMiddleware(pageHandler.listHandler))
+		r.Method("GET", "/changes", errorMiddleware(pageHandler.changesHandler))
+		r.Method("GET", "/search", errorMiddleware(pageHandler.searchHandler))
+		r.Method("GET", "/stubs", errorMiddleware(pageHandler.stubsHandler))
+		r.Method("GET", "/my/pages", errorMiddleware(pageHandler.myPagesHandler))
+		r.Method("POST", "/create/{title}", errorMiddleware(pageHandler.createFromTemplateHandler))
+		r.Method("GET", "/archived", errorMiddleware(pageHandler.archivedHandler))
+		r.Method("POST", "/delete/{title}", errorMiddleware(pageHandler.deleteHandler))
+		r.Method("POST", "/move/{title}", errorMiddleware(pageHandler.movePageHandler))
+		r.Method("GET", "/trash", errorMiddleware(pageHandler.trashHandler))
+		r.Method("POST", "/trash/restore/{id}", errorMiddleware(pageHandler.restoreHandler))
+		r.Method("POST", "/trash/purge/{id}", errorMiddleware(pageHandler.purgeHandler))
 		r.Method("GET", "/categories", errorMiddleware(pageHandler.categoriesHandler))
+		r.Method("GET", "/categories/export", errorMiddleware(pageHandler.categoriesExportHandler))
+		r.Method("POST", "/categories/{id}/rename", errorMiddleware(pageHandler.renameCategoryHandler))
+		r.Method("DELETE", "/categories/{id}", errorMiddleware(pageHandler.deleteCategoryHandler))
 		r.Method("GET", "/api/search/categories", errorMiddleware(pageHandler.searchCategoriesHandler))
+		r.Method("GET", "/api/search/pages", errorMiddleware(pageHandler.liveSearchHandler))
+		r.Method("GET", "/api/v1/pages", errorMiddleware(pageHandler.apiListPagesHandler))
+		r.Method("POST", "/api/v1/pages/batch", errorMiddleware(pageHandler.batchPagesHandler))
+		// The batch endpoint predates the versioned API; its old path is kept for existing clients.
+		r.Method("POST", "/api/pages/batch", errorMiddleware(pageHandler.batchPagesHandler))
+		r.Method("POST", "/api/v1/pages", errorMiddleware(pageHandler.apiCreatePageHandler))
+		r.Method("POST", "/api/v1/entries", errorMiddleware(pageHandler.apiAppendEntryHandler))
+		r.Method("GET", "/api/v1/pages/{title}", errorMiddleware(pageHandler.apiGetPageHandler))
+		r.Method("PUT", "/api/v1/pages/{title}", errorMiddleware(pageHandler.apiUpdatePageHandler))
+		r.Method("DELETE", "/api/v1/pages/{title}", errorMiddleware(pageHandler.apiDeletePageHandler))
 		r.Method("GET", "/category/{categoryName}", errorMiddleware(pageHandler.viewByCategoryHandler))
-		r.Method("GET", "/category/{categoryName}/{subcategoryName}", errorMiddleware(pageHandler.viewBySubcategoryHandler))
+		r.Method("GET", "/category/{categoryName}/export.epub", errorMiddleware(pageHandler.categoryEPUBHandler))
+		r.Method("GET", "/category/{categoryName}/feed.xml", errorMiddleware(pageHandler.categoryFeedHandler))
+		// Subcategories may be nested, e.g. /category/Science/Physics/Quantum.
+		r.Method("GET", "/category/{categoryName}/*", errorMiddleware(pageHandler.viewBySubcategoryHandler))
+		if adminHandler != nil {
+			r.Method("GET", "/admin", errorMiddleware(adminHandler.dashboardHandler))
+			r.Method("GET", "/status", errorMiddleware(adminHandler.statusHandler))
+			r.Method("POST", "/admin/revisions/prune", errorMiddleware(adminHandler.pruneRevisionsHandler))
+			r.Method("POST", "/admin/resanitize", errorMiddleware(adminHandler.resanitizeHandler))
+			r.Method("POST", "/admin/lockdown", errorMiddleware(adminHandler.lockdownHandler))
+			r.Method("GET", "/admin/contributions/{subject}", errorMiddleware(adminHandler.contributionsHandler))
+			r.Method("POST", "/admin/contributions/{subject}/revert", errorMiddleware(adminHandler.revertContributionsHandler))
+			r.Method("POST", "/admin/check-links", errorMiddleware(adminHandler.checkLinksHandler))
+			r.Method("GET", "/admin/dead-external-links", errorMiddleware(adminHandler.deadLinksHandler))
+			r.Method("POST", "/admin/pages/{title}/owner", errorMiddleware(adminHandler.setPageOwnerHandler))
+			r.Method("POST", "/admin/categories/merge", errorMiddleware(adminHandler.mergeCategoriesHandler))
+			r.Method("POST", "/admin/categories/order", errorMiddleware(adminHandler.categoryOrderHandler))
+			r.Method("GET", "/admin/review", errorMiddleware(adminHandler.reviewQueueHandler))
+			r.Method("POST", "/admin/review/{id}", errorMiddleware(adminHandler.reviewHandler))
+		}
 	})
 
 	return r
@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"fmt"
+	"go-wiki-app/internal/data"
+	"go-wiki-app/internal/middleware"
+	"go-wiki-app/internal/service"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// searchResultLimit caps the number of results shown on /search.
+	searchResultLimit = 50
+	// searchDateFormat is the format accepted by the date filters on /search.
+	searchDateFormat = "2006-01-02"
+)
+
+// searchSorts lists the accepted values of the ?sort= parameter. Without one,
+// results are sorted by relevance when there are search words.
+var searchSorts = map[string]data.SearchSort{
+	"":          data.SearchSortRelevance,
+	"relevance": data.SearchSortRelevance,
+	"updated":   data.SearchSortUpdated,
+	"title":     data.SearchSortTitle,
+}
+
+// parseSearchFilter validates the /search query parameters.
+func parseSearchFilter(r *http.Request) (data.SearchFilter, *middleware.AppError) {
+	query := r.URL.Query()
+	filter := data.SearchFilter{
+		Query:       query.Get("q"),
+		Category:    query.Get("category"),
+		Subcategory: query.Get("subcategory"),
+		AuthorID:    query.Get("author"),
+		Limit:       searchResultLimit,
+	}
+
+	sort, ok := searchSorts[query.Get("sort")]
+	if !ok {
+		return filter, &middleware.AppError{Error: fmt.Errorf("unsupported sort %q", query.Get("sort")), Message: "Invalid 'sort', expected 'relevance', 'updated' or 'title'", Code: http.StatusBadRequest}
+	}
+	if sort == data.SearchSortRelevance && filter.Query == "" {
+		sort = data.SearchSortUpdated
+	}
+	filter.Sort = sort
+
+	if after := query.Get("updated_after"); after != "" {
+		since, err := time.Parse(searchDateFormat, after)
+		if err != nil {
+			return filter, &middleware.AppError{Error: err, Message: "Invalid 'updated_after' date, expected YYYY-MM-DD", Code: http.StatusBadRequest}
+		}
+		filter.UpdatedAfter = since
+	}
+	if before := query.Get("updated_before"); before != "" {
+		until, err := time.Parse(searchDateFormat, before)
+		if err != nil {
+			return filter, &middleware.AppError{Error: err, Message: "Invalid 'updated_before' date, expected YYYY-MM-DD", Code: http.StatusBadRequest}
+		}
+		// The "before" date is inclusive, so include the whole day.
+		filter.UpdatedBefore = until.AddDate(0, 0, 1)
+	}
+	return filter, nil
+}
+
+// searchHandler searches page titles and content (?q=), optionally narrowed down
+// by category, subcategory, author and an updated date range, sorted by ?sort=.
+func (h *PageHandler) searchHandler(w http.ResponseWriter, r *http.Request) *middleware.AppError {
+	filter, appErr := parseSearchFilter(r)
+	if appErr != nil {
+		return appErr
+	}
+
+	templateData := h.newTemplateData(r)
+	query := r.URL.Query()
+	for _, key := range []string{"q", "category", "subcategory", "author", "updated_after", "updated_before", "sort"} {
+		templateData[key] = query.Get(key)
+	}
+
+	searched := filter.Query != "" || filter.Category != "" || filter.Subcategory != "" || filter.AuthorID != "" ||
+		!filter.UpdatedAfter.IsZero() || !filter.UpdatedBefore.IsZero()
+	if searched {
+		found, err := h.pageService.SearchPages(r.Context(), filter)
+		if err != nil {
+			return &middleware.AppError{Error: err, Message: "Failed to search pages", Code: http.StatusInternalServerError}
+		}
+		results := make([]*service.SearchResult, 0, len(found))
+		for _, result := range found {
+			if h.canSee(r, result.Page) {
+				results = append(results, result)
+			}
+		}
+		templateData["Results"] = results
+		templateData["Searched"] = true
+	}
+
+	if err := h.view.Render(w, r, "pages/search.html", templateData); err != nil {
+		return &middleware.AppError{Error: err, Message: "Failed to render search page", Code: http.StatusInternalServerError}
+	}
+	return nil
+}
+
+// liveSearchLimit caps the number of results shown while typing a search.
+const liveSearchLimit = 10
+
+// liveSearchHandler renders the best matches for ?q= as an HTMX fragment,
+// shown under the search box while the user types. An empty query renders nothing.
+func (h *PageHandler) liveSearchHandler(w http.ResponseWriter, r *http.Request) *middleware.AppError {
+	templateData := h.newTemplateData(r)
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query != "" {
+		found, err := h.pageService.SearchPages(r.Context(), data.SearchFilter{Query: query, Sort: data.SearchSortRelevance, Limit: liveSearchLimit})
+		if err != nil {
+			return &middleware.AppError{Error: err, Message: "Failed to search pages", Code: http.StatusInternalServerError}
+		}
+		results := make([]*service.SearchResult, 0, len(found))
+		for _, result := range found {
+			if h.canSee(r, result.Page) {
+				results = append(results, result)
+			}
+		}
+		templateData["Results"] = results
+		templateData["Searched"] = true
+	}
+	if err := h.view.Render(w, r, "pages/htmx/page_search_results.html", templateData); err != nil {
+		return &middleware.AppError{Error: err, Message: "Failed to render search results", Code: http.StatusInternalServerError}
+	}
+	return nil
+}
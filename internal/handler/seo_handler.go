@@ -1,36 +1,87 @@
 package handler
 
 import (
+	"bytes"
 	"encoding/xml"
 	"fmt"
+	"go-wiki-app/internal/config"
 	"go-wiki-app/internal/service"
+	"go-wiki-app/web"
+	"io/fs"
 	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
 )
 
+// faviconCacheControl lets browsers keep the site icon for a month.
+const faviconCacheControl = "public, max-age=2592000"
+
 // SeoHandler holds dependencies for SEO-related handlers.
 type SeoHandler struct {
 	pageService service.PageServicer
+	site        config.SiteConfig
 }
 
 // NewSeoHandler creates a new SeoHandler.
-func NewSeoHandler(ps service.PageServicer) *SeoHandler {
-	return &SeoHandler{pageService: ps}
+func NewSeoHandler(ps service.PageServicer, site config.SiteConfig) *SeoHandler {
+	return &SeoHandler{pageService: ps, site: site}
+}
+
+// faviconHandler serves the site icon. An operator-provided icon is read from
+// disk on each request so it can be replaced without a restart; otherwise the
+// icon bundled with the static assets is served.
+func (h *SeoHandler) faviconHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", faviconCacheControl)
+
+	if h.site.FaviconPath == "" {
+		icon, err := fs.ReadFile(web.StaticFS, "static/img/favicon.ico")
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "image/x-icon")
+		http.ServeContent(w, r, "favicon.ico", time.Time{}, bytes.NewReader(icon))
+		return
+	}
+
+	f, err := os.Open(h.site.FaviconPath)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	if filepath.Ext(info.Name()) == ".ico" {
+		w.Header().Set("Content-Type", "image/x-icon")
+	}
+	http.ServeContent(w, r, info.Name(), info.ModTime(), f)
 }
 
-// robotsHandler serves a static robots.txt file.
+// baseURL returns the configured public address of the wiki, or the one the
+// current request was made to if none is configured.
+func (h *SeoHandler) baseURL(r *http.Request) string {
+	if h.site.BaseURL != "" {
+		return h.site.BaseURL
+	}
+	return requestBaseURL(r)
+}
+
+// robotsHandler serves a static robots.txt file pointing crawlers at the sitemap.
 func (h *SeoHandler) robotsHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/plain")
 	fmt.Fprintln(w, "User-agent: *")
 	fmt.Fprintln(w, "Allow: /")
 	fmt.Fprintln(w, "")
-	// In a real app, you would get the domain from config.
-	fmt.Fprintln(w, "Sitemap: http://localhost:8080/sitemap.xml")
+	fmt.Fprintf(w, "Sitemap: %s/sitemap.xml\n", h.baseURL(r))
 }
 
-const (
-	sitemapDateFormat = "2006-01-02"
-	baseURL           = "http://localhost:8080/view/" // In a real app, get this from config
-)
+const sitemapDateFormat = "2006-01-02"
 
 type sitemapURL struct {
 	XMLName xml.Name `xml:"url"`
@@ -45,7 +96,17 @@ type urlSet struct {
 }
 
 // sitemapHandler generates and serves a dynamic sitemap.xml.
+// Crawlers fetch it often, so it can be revalidated cheaply against the newest page update.
 func (h *SeoHandler) sitemapHandler(w http.ResponseWriter, r *http.Request) {
+	lastModified, count, err := h.pageService.PagesLastModified(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to retrieve pages for sitemap", http.StatusInternalServerError)
+		return
+	}
+	if notModified(w, r, lastModified, pagesETag(lastModified, count, "sitemap")) {
+		return
+	}
+
 	pages, err := h.pageService.GetAllPages(r.Context())
 	if err != nil {
 		http.Error(w, "Failed to retrieve pages for sitemap", http.StatusInternalServerError)
@@ -57,9 +118,10 @@ func (h *SeoHandler) sitemapHandler(w http.ResponseWriter, r *http.Request) {
 		URLs:  make([]sitemapURL, len(pages)),
 	}
 
+	viewURL := h.baseURL(r) + "/view/"
 	for i, page := range pages {
 		sitemap.URLs[i] = sitemapURL{
-			Loc:     baseURL + page.Title,
+			Loc:     viewURL + url.PathEscape(page.Title),
 			LastMod: page.UpdatedAt.Format(sitemapDateFormat),
 		}
 	}
@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-wiki-app/internal/events"
+	"go-wiki-app/internal/middleware"
+	"go-wiki-app/internal/service"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// sseHeartbeatInterval controls how often a comment line is sent to keep idle
+// connections from being closed by proxies.
+const sseHeartbeatInterval = 25 * time.Second
+
+// pageEventsHandler streams live update notifications for a single page using
+// server-sent events. The stream stays open until the client disconnects.
+// Minor edits are skipped unless the watcher opts in with ?minor=1. Only pages
+// the user may see can be watched, since the events name their authors.
+func (h *PageHandler) pageEventsHandler(w http.ResponseWriter, r *http.Request) *middleware.AppError {
+	title := chi.URLParam(r, "title")
+	includeMinor := r.URL.Query().Get("minor") == "1"
+
+	page, err := h.pageService.GetPage(r.Context(), title)
+	if errors.Is(err, service.ErrPageNotFound) {
+		return &middleware.AppError{Error: err, Message: "Page not found", Code: http.StatusNotFound}
+	}
+	if err != nil {
+		return &middleware.AppError{Error: err, Message: "Failed to load the page", Code: http.StatusInternalServerError}
+	}
+	if !h.canSee(r, page) {
+		return &middleware.AppError{Error: fmt.Errorf("page %q is hidden from the user", title), Message: "Page not found", Code: http.StatusNotFound}
+	}
+
+	updates, unsubscribe, err := h.pageService.SubscribeToPage(r.Context(), title)
+	if err != nil {
+		if errors.Is(err, events.ErrTooManySubscribers) {
+			return &middleware.AppError{Error: err, Message: "Too many listeners for this page", Code: http.StatusServiceUnavailable}
+		}
+		return &middleware.AppError{Error: err, Message: "Failed to subscribe to page updates", Code: http.StatusInternalServerError}
+	}
+	defer unsubscribe()
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, ": connected\n\n")
+	if err := rc.Flush(); err != nil {
+		h.logFor(r.Context()).Error(err, "Streaming is not supported by the response writer")
+		return nil
+	}
+
+	heartbeat := time.NewTicker(sseHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return nil
+		case ev, ok := <-updates:
+			if !ok {
+				return nil
+			}
+			if ev.Minor && !includeMinor {
+				continue
+			}
+			payload, err := json.Marshal(ev)
+			if err != nil {
+				h.logFor(r.Context()).Error(err, "Failed to encode page event")
+				continue
+			}
+			fmt.Fprintf(w, "event: page-%s\ndata: %s\n\n", ev.Type, payload)
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+		}
+		if err := rc.Flush(); err != nil {
+			// The client has gone away.
+			return nil
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"go-wiki-app/internal/middleware"
+	"net/http"
+	"runtime"
+	"time"
+)
+
+// statusCacheBackend is the store behind the page cache reported on /status.
+const statusCacheBackend = "sqlite"
+
+// statusReport is the JSON document served by statusHandler. Each section is
+// computed on its own and carries its own error, so one failing check does not
+// hide the others.
+type statusReport struct {
+	Build         statusBuild      `json:"build"`
+	StartedAt     time.Time        `json:"started_at"`
+	UptimeSeconds int64            `json:"uptime_seconds"`
+	Database      statusDatabase   `json:"database"`
+	Cache         statusCache      `json:"cache"`
+	Pages         statusPages      `json:"pages"`
+	Categories    statusCategories `json:"categories"`
+	Runtime       statusRuntime    `json:"runtime"`
+}
+
+type statusBuild struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	GoVersion string `json:"go_version"`
+}
+
+type statusDatabase struct {
+	Driver        string  `json:"driver,omitempty"`
+	PingLatencyMS float64 `json:"ping_latency_ms"`
+	Error         string  `json:"error,omitempty"`
+}
+
+type statusCache struct {
+	Backend string  `json:"backend"`
+	Hits    int64   `json:"hits"`
+	Misses  int64   `json:"misses"`
+	HitRate float64 `json:"hit_rate"`
+}
+
+type statusPages struct {
+	Count        int64  `json:"count"`
+	ContentBytes int64  `json:"content_bytes"`
+	Error        string `json:"error,omitempty"`
+}
+
+type statusCategories struct {
+	Count int    `json:"count"`
+	Error string `json:"error,omitempty"`
+}
+
+type statusRuntime struct {
+	Goroutines     int    `json:"goroutines"`
+	HeapAllocBytes uint64 `json:"heap_alloc_bytes"`
+	SysBytes       uint64 `json:"sys_bytes"`
+	NumGC          uint32 `json:"num_gc"`
+}
+
+// statusHandler serves a JSON summary of the wiki's health for monitoring:
+// the running build, uptime, database and cache state, content counts and
+// runtime statistics.
+func (h *AdminHandler) statusHandler(w http.ResponseWriter, r *http.Request) *middleware.AppError {
+	ctx := r.Context()
+	report := statusReport{
+		Build:         statusBuild{Version: h.version, Commit: h.commit, GoVersion: runtime.Version()},
+		StartedAt:     h.started.UTC(),
+		UptimeSeconds: int64(time.Since(h.started).Seconds()),
+		Database:      statusDatabase{Driver: h.databaseDriver},
+	}
+	if report.Build.Version == "" {
+		report.Build.Version = "dev"
+	}
+
+	healthCtx, cancel := context.WithTimeout(ctx, dashboardHealthTimeout)
+	defer cancel()
+	start := time.Now()
+	err := h.dashboard.DatabaseHealth(healthCtx)
+	report.Database.PingLatencyMS = float64(time.Since(start).Microseconds()) / 1000
+	if err != nil {
+		h.logFor(ctx).Error(err, "Status: database health check failed")
+		report.Database.Error = err.Error()
+	}
+
+	cacheStats := h.dashboard.CacheStats()
+	report.Cache = statusCache{Backend: statusCacheBackend, Hits: cacheStats.Hits, Misses: cacheStats.Misses, HitRate: cacheStats.HitRate()}
+
+	if stats, err := h.dashboard.PageStats(ctx); err != nil {
+		h.logFor(ctx).Error(err, "Status: failed to load page stats")
+		report.Pages.Error = err.Error()
+	} else {
+		report.Pages.Count = stats.PageCount
+		report.Pages.ContentBytes = stats.ContentBytes
+	}
+	if n, err := h.dashboard.CategoryCount(ctx); err != nil {
+		h.logFor(ctx).Error(err, "Status: failed to count categories")
+		report.Categories.Error = err.Error()
+	} else {
+		report.Categories.Count = n
+	}
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	report.Runtime = statusRuntime{
+		Goroutines:     runtime.NumGoroutine(),
+		HeapAllocBytes: mem.HeapAlloc,
+		SysBytes:       mem.Sys,
+		NumGC:          mem.NumGC,
+	}
+
+	out, err := json.Marshal(report)
+	if err != nil {
+		return &middleware.AppError{Error: err, Message: "Failed to encode status", Code: http.StatusInternalServerError}
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(out)
+	return nil
+}
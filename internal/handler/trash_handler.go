@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"go-wiki-app/internal/middleware"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// pageInTrashMessage explains why a page cannot be created with the title of a deleted page.
+const pageInTrashMessage = "A deleted page with this title is in the trash. Restore it from the trash instead."
+
+// deleteHandler moves a page to the trash, from which editors can restore it.
+func (h *PageHandler) deleteHandler(w http.ResponseWriter, r *http.Request) *middleware.AppError {
+	title := chi.URLParam(r, "title")
+	if title == "Home" {
+		return &middleware.AppError{Error: errors.New("home page cannot be deleted"), Message: "The Home page cannot be deleted.", Code: http.StatusForbidden}
+	}
+	page, err := h.pageService.ViewPage(r.Context(), title)
+	if err != nil {
+		return &middleware.AppError{Error: err, Message: "Page not found", Code: http.StatusNotFound}
+	}
+	if denied, appErr := h.denyRestricted(w, r, page); denied {
+		return appErr
+	}
+	if err := h.pageService.DeletePage(r.Context(), page.ID); err != nil {
+		return &middleware.AppError{Error: err, Message: "Failed to delete page", Code: http.StatusInternalServerError}
+	}
+	http.Redirect(w, r, "/trash", http.StatusSeeOther)
+	return nil
+}
+
+// trashHandler lists the pages in the trash.
+func (h *PageHandler) trashHandler(w http.ResponseWriter, r *http.Request) *middleware.AppError {
+	pages, err := h.pageService.GetTrash(r.Context())
+	if err != nil {
+		return &middleware.AppError{Error: err, Message: "Failed to retrieve the trash", Code: http.StatusInternalServerError}
+	}
+	templateData := h.newTemplateData(r)
+	templateData["Pages"] = pages
+	templateData["CanPurge"] = h.can(r.Context(), "/trash/purge/", http.MethodPost)
+	if err := h.view.Render(w, r, "pages/trash.html", templateData); err != nil {
+		return &middleware.AppError{Error: err, Message: "Failed to render the trash", Code: http.StatusInternalServerError}
+	}
+	return nil
+}
+
+// restoreHandler takes a page out of the trash.
+func (h *PageHandler) restoreHandler(w http.ResponseWriter, r *http.Request) *middleware.AppError {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		return &middleware.AppError{Error: err, Message: "Invalid page", Code: http.StatusBadRequest}
+	}
+	if err := h.pageService.RestorePage(r.Context(), id); err != nil {
+		return &middleware.AppError{Error: fmt.Errorf("restoring page %d: %w", id, err), Message: "Page not found in the trash", Code: http.StatusNotFound}
+	}
+	http.Redirect(w, r, "/trash", http.StatusSeeOther)
+	return nil
+}
+
+// purgeHandler permanently deletes a page in the trash.
+func (h *PageHandler) purgeHandler(w http.ResponseWriter, r *http.Request) *middleware.AppError {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		return &middleware.AppError{Error: err, Message: "Invalid page", Code: http.StatusBadRequest}
+	}
+	if err := h.pageService.PurgePage(r.Context(), id); err != nil {
+		return &middleware.AppError{Error: fmt.Errorf("purging page %d: %w", id, err), Message: "Page not found in the trash", Code: http.StatusNotFound}
+	}
+	http.Redirect(w, r, "/trash", http.StatusSeeOther)
+	return nil
+}
@@ -1,6 +1,7 @@
 package logger
 
 import (
+	"context"
 	"go-wiki-app/internal/config"
 	"io"
 	"os"
@@ -11,6 +12,7 @@ import (
 
 // Logger defines a standard interface for logging.
 type Logger interface {
+	Debug(msg string)
 	Info(msg string)
 	Warn(msg string)
 	Error(err error, msg string)
@@ -47,6 +49,10 @@ func New(cfg config.LogConfig, testWriter ...io.Writer) Logger {
 	return &zerologLogger{logger: logger}
 }
 
+func (l *zerologLogger) Debug(msg string) {
+	l.logger.Debug().Msg(msg)
+}
+
 func (l *zerologLogger) Info(msg string) {
 	l.logger.Info().Msg(msg)
 }
@@ -68,3 +74,19 @@ func (l *zerologLogger) With(fields map[string]interface{}) Logger {
 	subLogger := l.logger.With().Fields(fields).Logger()
 	return &zerologLogger{logger: subLogger}
 }
+
+// contextKey is the type of the context key the request-scoped logger is stored under.
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying log, typically a sub-logger with
+// fields identifying the current request.
+func NewContext(ctx context.Context, log Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, log)
+}
+
+// FromContext returns the logger stored in ctx by NewContext, or nil if there
+// is none.
+func FromContext(ctx context.Context) Logger {
+	log, _ := ctx.Value(contextKey{}).(Logger)
+	return log
+}
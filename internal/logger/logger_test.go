@@ -69,4 +69,23 @@ func TestLogger(t *testing.T) {
 			t.Error("warn level log should have appeared")
 		}
 	})
+
+	t.Run("debug level filtering", func(t *testing.T) {
+		for _, tc := range []struct {
+			level string
+			want  bool
+		}{
+			{"info", false},
+			{"debug", true},
+		} {
+			var buf bytes.Buffer
+			log := New(config.LogConfig{Level: tc.level, Format: "console"}, &buf)
+
+			log.Debug("debug details")
+
+			if got := strings.Contains(buf.String(), "debug details"); got != tc.want {
+				t.Errorf("at level %s: debug message logged = %v, want %v", tc.level, got, tc.want)
+			}
+		}
+	})
 }
@@ -1,8 +1,12 @@
 package middleware
 
 import (
+	"context"
+	"go-wiki-app/internal/data"
 	"go-wiki-app/internal/session"
+	"net"
 	"net/http"
+	"strings"
 
 	"github.com/casbin/casbin/v2"
 )
@@ -10,12 +14,14 @@ import (
 // Authorizer is a middleware that enforces access control using Casbin.
 // It performs the following steps:
 // 1. Determines the user's subject from the session, defaulting to "anonymous".
-// 2. Fetches the user's roles and display name and adds them to the request context.
+// 2. Fetches the user's roles, display name and, when users is non-nil, their
+//    stored profile, and adds them to the request context.
 // 3. Uses the Casbin enforcer to check if the subject is allowed to perform the
 //    requested action (e.g., GET) on the requested resource (e.g., /view/SomePage).
 // 4. If allowed, it passes the request to the next handler.
-// 5. If not allowed, it returns a 403 Forbidden error.
-func Authorizer(e casbin.IEnforcer, sm session.Manager) func(http.Handler) http.Handler {
+// 5. If not allowed, it lets a page's owner edit and save it (when owners is
+//    non-nil) and otherwise returns a 403 Forbidden error.
+func Authorizer(e casbin.IEnforcer, sm session.Manager, owners PageOwnerLookup, users UserLookup) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			// 1. Identify the user (subject) from the session.
@@ -32,7 +38,13 @@ func Authorizer(e casbin.IEnforcer, sm session.Manager) func(http.Handler) http.
 			}
 			displayName := sm.GetString(r.Context(), "user_display_name")
 
-			userInfo := &UserInfo{Subject: subject, Roles: roles, DisplayName: displayName}
+			userInfo := &UserInfo{Subject: subject, Roles: roles, DisplayName: displayName, IP: clientIP(r)}
+			if users != nil && subject != "anonymous" {
+				// A missing profile is not an error: the user may have logged in before profiles were stored.
+				if user, err := users(r.Context(), subject); err == nil {
+					userInfo.User = user
+				}
+			}
 			ctx := SetUserInfo(r.Context(), userInfo)
 			r = r.WithContext(ctx)
 
@@ -43,7 +55,15 @@ func Authorizer(e casbin.IEnforcer, sm session.Manager) func(http.Handler) http.
 				return
 			}
 
+			if !allowed && owners != nil && subject != "anonymous" {
+				allowed = ownsEditedPage(r, owners, subject)
+			}
+
 			if !allowed {
+				if isAPIRequest(r) {
+					writeJSONError(w, http.StatusForbidden, "Forbidden")
+					return
+				}
 				http.Error(w, "Forbidden", http.StatusForbidden)
 				return
 			}
@@ -52,3 +72,38 @@ func Authorizer(e casbin.IEnforcer, sm session.Manager) func(http.Handler) http.
 		})
 	}
 }
+
+// PageOwnerLookup returns the subject owning the page with the given title, or
+// an empty string if the page has no owner or does not exist.
+type PageOwnerLookup func(ctx context.Context, title string) (string, error)
+
+// UserLookup returns the stored profile of the user with the given subject.
+type UserLookup func(ctx context.Context, subject string) (*data.User, error)
+
+// ownerEditRoutes are the routes a page's owner may always use on their page.
+var ownerEditRoutes = []struct{ method, prefix string }{
+	{http.MethodGet, "/edit/"},
+	{http.MethodPost, "/save/"},
+	{http.MethodPut, APIPrefix + "pages/"},
+}
+
+// ownsEditedPage reports whether the request edits a page owned by subject.
+func ownsEditedPage(r *http.Request, owners PageOwnerLookup, subject string) bool {
+	for _, route := range ownerEditRoutes {
+		title, ok := strings.CutPrefix(r.URL.Path, route.prefix)
+		if r.Method != route.method || !ok || title == "" {
+			continue
+		}
+		owner, err := owners(r.Context(), title)
+		return err == nil && owner == subject
+	}
+	return false
+}
+
+// clientIP returns the host part of the request's remote address.
+func clientIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
@@ -0,0 +1,59 @@
+//go:build unit
+
+package middleware
+
+import (
+	"context"
+	"errors"
+	"go-wiki-app/internal/data"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/casbin/casbin/v2"
+)
+
+func TestAuthorizer_AttachesUserRecord(t *testing.T) {
+	enforcer, err := casbin.NewEnforcer("../../auth_model.conf")
+	if err != nil {
+		t.Fatalf("failed to create enforcer: %v", err)
+	}
+	enforcer.AddPolicy("anonymous", "/view/*", "GET")
+	enforcer.AddRoleForUser("alice", "anonymous")
+
+	lookups := 0
+	users := func(ctx context.Context, subject string) (*data.User, error) {
+		lookups++
+		if subject == "alice" {
+			return &data.User{Subject: "alice", Email: "alice@example.com", Picture: "https://example.com/alice.png"}, nil
+		}
+		return nil, errors.New("user not found")
+	}
+
+	var got *UserInfo
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = GetUserInfo(r.Context())
+	})
+	serve := func(subject string) {
+		got = nil
+		sm := &mockSession{values: map[string]interface{}{"user_subject": subject}}
+		rr := httptest.NewRecorder()
+		Authorizer(enforcer, sm, nil, users)(next).ServeHTTP(rr, httptest.NewRequest("GET", "/view/Home", nil))
+		if rr.Code != http.StatusOK || got == nil {
+			t.Fatalf("expected the request to reach the handler, got status %d", rr.Code)
+		}
+	}
+
+	serve("alice")
+	if got.User == nil || got.User.Email != "alice@example.com" || got.User.Picture == "" {
+		t.Errorf("expected alice's record downstream, got %+v", got.User)
+	}
+
+	serve("")
+	if got.Subject != "anonymous" || got.User != nil {
+		t.Errorf("expected an anonymous user without a record, got %+v", got)
+	}
+	if lookups != 1 {
+		t.Errorf("expected no lookup for anonymous users, got %d lookups", lookups)
+	}
+}
@@ -3,6 +3,7 @@ package middleware
 import (
 	"context"
 	"encoding/gob"
+	"go-wiki-app/internal/data"
 )
 
 func init() {
@@ -19,6 +20,11 @@ type UserInfo struct {
 	Subject     string
 	Roles       []string
 	DisplayName string
+	// IP is the client address of the request.
+	IP string
+	// User is the stored profile of a logged-in user, with their email and
+	// avatar. It is nil for anonymous users and users without a stored profile.
+	User *data.User
 }
 
 // GetUserInfo retrieves the user information from the request context.
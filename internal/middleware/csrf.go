@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"go-wiki-app/internal/session"
+	"mime"
+	"net/http"
+	"slices"
+)
+
+const (
+	// CSRFTokenKey is the session key holding the session's CSRF token.
+	CSRFTokenKey = "csrf_token"
+	// CSRFField is the form field forms send the CSRF token in.
+	CSRFField = "csrf_token"
+	// CSRFHeader is the request header HTMX and scripts send the CSRF token in.
+	CSRFHeader = "X-CSRF-Token"
+)
+
+const csrfContextKey = contextKey("csrf")
+
+// csrfSource hands out the session's CSRF token to the templates of a request.
+type csrfSource struct {
+	sm session.Manager
+}
+
+// crossSiteFormTypes are the content types another site can POST with a plain
+// HTML form or a script without a CORS preflight. An empty type covers
+// bodiless requests.
+var crossSiteFormTypes = []string{"", "application/x-www-form-urlencoded", "multipart/form-data", "text/plain"}
+
+// CSRF rejects cross-site request forgeries: POST requests another site could
+// have sent on the user's behalf must carry the session's CSRF token in the
+// CSRFField form field or the CSRFHeader header, or they get a 403. Other
+// methods and POSTs with other bodies, such as the API's JSON, cannot be sent
+// cross-site without a CORS preflight, which the wiki does not answer, so they
+// are not checked. Requests to the exempt paths, such as the OIDC callback,
+// are never checked. It must run after the session is loaded.
+func CSRF(sm session.Manager, exempt ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if needsCSRFToken(r) && !slices.Contains(exempt, r.URL.Path) && !validCSRFToken(r, sm.GetString(r.Context(), CSRFTokenKey)) {
+				if isAPIRequest(r) {
+					writeJSONError(w, http.StatusForbidden, "Forbidden")
+					return
+				}
+				http.Error(w, "Forbidden: the form has expired, reload the page and try again", http.StatusForbidden)
+				return
+			}
+			ctx := context.WithValue(r.Context(), csrfContextKey, &csrfSource{sm: sm})
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// needsCSRFToken reports whether another site could have sent the request.
+func needsCSRFToken(r *http.Request) bool {
+	if r.Method != http.MethodPost {
+		return false
+	}
+	mediaType := ""
+	if contentType := r.Header.Get("Content-Type"); contentType != "" {
+		var err error
+		if mediaType, _, err = mime.ParseMediaType(contentType); err != nil {
+			return true
+		}
+	}
+	return slices.Contains(crossSiteFormTypes, mediaType)
+}
+
+// validCSRFToken reports whether the request carries the session's token.
+func validCSRFToken(r *http.Request, want string) bool {
+	if want == "" {
+		return false
+	}
+	got := r.Header.Get(CSRFHeader)
+	if got == "" {
+		got = r.PostFormValue(CSRFField)
+	}
+	return subtle.ConstantTimeCompare([]byte(got), []byte(want)) == 1
+}
+
+// CSRFToken returns the CSRF token of the request's session for forms to
+// send back, or "" outside the CSRF middleware. The token is created on first
+// use, so only visitors shown a form get a session.
+func CSRFToken(ctx context.Context) string {
+	source, ok := ctx.Value(csrfContextKey).(*csrfSource)
+	if !ok {
+		return ""
+	}
+	if token := source.sm.GetString(ctx, CSRFTokenKey); token != "" {
+		return token
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	source.sm.Put(ctx, CSRFTokenKey, token)
+	return token
+}
@@ -0,0 +1,94 @@
+//go:build unit
+
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestCSRF(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	newSession := func() *mockSession {
+		return &mockSession{values: map[string]interface{}{CSRFTokenKey: "secret-token"}}
+	}
+	formPost := func(target string, form url.Values) *http.Request {
+		req := httptest.NewRequest("POST", target, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return req
+	}
+
+	tests := []struct {
+		name     string
+		session  *mockSession
+		req      func() *http.Request
+		wantCode int
+	}{
+		{"missing token", newSession(), func() *http.Request {
+			return formPost("/save/Home", url.Values{"content": {"x"}})
+		}, http.StatusForbidden},
+		{"wrong token", newSession(), func() *http.Request {
+			return formPost("/save/Home", url.Values{CSRFField: {"guessed"}})
+		}, http.StatusForbidden},
+		{"no token in the session", &mockSession{values: map[string]interface{}{}}, func() *http.Request {
+			return formPost("/save/Home", url.Values{CSRFField: {""}})
+		}, http.StatusForbidden},
+		{"bodiless POST without a token", newSession(), func() *http.Request {
+			return httptest.NewRequest("POST", "/admin/lockdown", nil)
+		}, http.StatusForbidden},
+		{"valid form field", newSession(), func() *http.Request {
+			return formPost("/save/Home", url.Values{CSRFField: {"secret-token"}})
+		}, http.StatusNoContent},
+		{"valid header", newSession(), func() *http.Request {
+			req := formPost("/save/Home", url.Values{"content": {"x"}})
+			req.Header.Set(CSRFHeader, "secret-token")
+			return req
+		}, http.StatusNoContent},
+		{"safe methods are not checked", newSession(), func() *http.Request {
+			return httptest.NewRequest("GET", "/edit/Home", nil)
+		}, http.StatusNoContent},
+		{"JSON needs a preflight and is not checked", newSession(), func() *http.Request {
+			req := httptest.NewRequest("POST", "/api/v1/pages", strings.NewReader(`{"title": "x"}`))
+			req.Header.Set("Content-Type", "application/json")
+			return req
+		}, http.StatusNoContent},
+		{"exempt path", newSession(), func() *http.Request {
+			return formPost("/auth/callback", url.Values{})
+		}, http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			CSRF(tt.session, "/auth/callback")(ok).ServeHTTP(rr, tt.req())
+			if rr.Code != tt.wantCode {
+				t.Errorf("want status %d; got %d", tt.wantCode, rr.Code)
+			}
+		})
+	}
+}
+
+func TestCSRFToken(t *testing.T) {
+	sm := &mockSession{values: map[string]interface{}{}}
+	var first, second string
+	handler := CSRF(sm)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		first = CSRFToken(r.Context())
+		second = CSRFToken(r.Context())
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/edit/Home", nil))
+
+	if first == "" || first != second {
+		t.Fatalf("expected one token per session, got %q and %q", first, second)
+	}
+	if sm.values[CSRFTokenKey] != first {
+		t.Errorf("expected the token to be stored in the session, got %v", sm.values[CSRFTokenKey])
+	}
+	if token := CSRFToken(context.Background()); token != "" {
+		t.Errorf("expected no token outside the middleware, got %q", token)
+	}
+}
@@ -1,10 +1,14 @@
 package middleware
 
 import (
+	"encoding/json"
 	"fmt"
 	"go-wiki-app/internal/logger"
 	"go-wiki-app/internal/view"
 	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
 )
 
 // AppError represents a custom error type for the application.
@@ -27,26 +31,112 @@ func Error(log logger.Logger, view *view.View) func(AppHandler) http.Handler {
 					if !ok {
 						err = fmt.Errorf("%v", rec)
 					}
-					log.Error(err, "Panic recovered")
-					data := map[string]interface{}{
-						"StatusCode": http.StatusInternalServerError,
-						"StatusText": "Internal Server Error",
-					}
-					w.WriteHeader(http.StatusInternalServerError)
-					view.Render(w, r, "error.html", data)
+					requestLog(r, log).Error(err, "Panic recovered")
+					renderError(w, r, view, http.StatusInternalServerError, "Internal Server Error")
 				}
 			}()
 
 			err := next(w, r)
 			if err != nil {
-				log.Error(err.Error, err.Message)
-				data := map[string]interface{}{
-					"StatusCode": err.Code,
-					"StatusText": err.Message,
-				}
-				w.WriteHeader(err.Code)
-				view.Render(w, r, "error.html", data)
+				requestLog(r, log).Error(err.Error, err.Message)
+				renderError(w, r, view, err.Code, err.Message)
 			}
 		})
 	}
 }
+
+// MethodNotAllowed responds to requests whose path exists but does not accept
+// the request method. It advertises the accepted methods in the Allow header,
+// answers OPTIONS requests with an empty 204 response, and renders the styled
+// error page for everything else.
+func MethodNotAllowed(view *view.View) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		allowed := allowedMethods(r)
+		if len(allowed) > 0 {
+			w.Header().Set("Allow", strings.Join(append(allowed, http.MethodOptions), ", "))
+		}
+		if r.Method == http.MethodOptions && len(allowed) > 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		renderError(w, r, view, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+// NotFound renders the styled error page for paths that match no route.
+func NotFound(view *view.View) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		renderError(w, r, view, http.StatusNotFound, "Page not found")
+	}
+}
+
+// NoIndex asks search engines not to index the response, so error pages and
+// placeholder content do not show up in search results.
+func NoIndex(w http.ResponseWriter) {
+	w.Header().Set("X-Robots-Tag", "noindex")
+}
+
+// routeMethods are the methods probed when building an Allow header.
+var routeMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+	http.MethodPatch, http.MethodDelete,
+}
+
+// allowedMethods returns the methods the router accepts for the request's path.
+func allowedMethods(r *http.Request) []string {
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil || rctx.Routes == nil {
+		return nil
+	}
+	path := r.URL.RawPath
+	if path == "" {
+		path = r.URL.Path
+	}
+	var allowed []string
+	for _, method := range routeMethods {
+		if rctx.Routes.Match(chi.NewRouteContext(), method, path) {
+			allowed = append(allowed, method)
+		}
+	}
+	return allowed
+}
+
+// APIPrefix is the path prefix of the JSON API. Its errors are sent as JSON
+// instead of as the styled error page.
+const APIPrefix = "/api/v1/"
+
+// isAPIRequest reports whether the request is made to the JSON API.
+func isAPIRequest(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.Path, APIPrefix) || r.URL.Path == strings.TrimSuffix(APIPrefix, "/")
+}
+
+// writeJSONError writes an API error as {"status": code, "error": text}.
+func writeJSONError(w http.ResponseWriter, code int, text string) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(struct {
+		Status int    `json:"status"`
+		Error  string `json:"error"`
+	}{code, text})
+}
+
+// renderError writes the styled error page with the given status code, or a
+// JSON error for requests to the API.
+func renderError(w http.ResponseWriter, r *http.Request, view *view.View, code int, text string) {
+	if code == http.StatusNotFound {
+		NoIndex(w)
+	}
+	if isAPIRequest(r) {
+		writeJSONError(w, code, text)
+		return
+	}
+	data := map[string]interface{}{
+		"StatusCode":  code,
+		"StatusText":  text,
+		"UserInfo":    GetUserInfo(r.Context()),
+		"IsBasicMode": IsBasicMode(r.Context()),
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(code)
+	view.Render(w, r, "pages/error.html", data)
+}
@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// rateLimitSweepInterval is how often buckets of clients that have gone quiet
+// are dropped.
+const rateLimitSweepInterval = time.Minute
+
+// tokenBucket holds the requests a client may still make.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter keeps a token bucket per client IP address.
+type rateLimiter struct {
+	rate      float64 // tokens added per second
+	burst     float64
+	now       func() time.Time
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+// allow takes a token from the client's bucket. If the bucket is empty it
+// reports how long until the next token is added instead.
+func (l *rateLimiter) allow(client string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	if now.Sub(l.lastSweep) >= rateLimitSweepInterval {
+		l.sweep(now)
+	}
+	b, ok := l.buckets[client]
+	if !ok {
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[client] = b
+	}
+	b.tokens = l.refill(b, now)
+	b.last = now
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// refill returns the tokens in the bucket at the given time.
+func (l *rateLimiter) refill(b *tokenBucket, now time.Time) float64 {
+	return math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+}
+
+// sweep drops the buckets that have filled up again, as a new bucket is full too.
+func (l *rateLimiter) sweep(now time.Time) {
+	for client, b := range l.buckets {
+		if l.refill(b, now) >= l.burst {
+			delete(l.buckets, client)
+		}
+	}
+	l.lastSweep = now
+}
+
+// RateLimit throttles each client IP address with a token bucket holding up to
+// burst requests and refilled with rate requests per second. Requests beyond
+// that get 429 Too Many Requests with a Retry-After header. It must run after
+// chi's RealIP so that clients behind a proxy are told apart. A rate of zero or
+// less disables the limit.
+func RateLimit(rate float64, burst int) func(http.Handler) http.Handler {
+	if rate <= 0 {
+		return func(next http.Handler) http.Handler { return next }
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	l := &rateLimiter{rate: rate, burst: float64(burst), now: time.Now, buckets: make(map[string]*tokenBucket)}
+	return l.middleware
+}
+
+func (l *rateLimiter) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ok, wait := l.allow(clientIP(r)); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			http.Error(w, "Too many requests", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
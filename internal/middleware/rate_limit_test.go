@@ -0,0 +1,52 @@
+//go:build unit
+
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRateLimit(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusFound)
+	})
+	const burst = 3
+	// One request a minute, so the bucket does not refill during the test.
+	limited := RateLimit(1.0/60, burst)(ok)
+	request := func(ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/auth/login", nil)
+		req.RemoteAddr = ip + ":1234"
+		rr := httptest.NewRecorder()
+		limited.ServeHTTP(rr, req)
+		return rr
+	}
+
+	for i := 0; i < burst; i++ {
+		if rr := request("192.0.2.1"); rr.Code != http.StatusFound {
+			t.Fatalf("request %d: expected to pass, got %d", i+1, rr.Code)
+		}
+	}
+	rr := request("192.0.2.1")
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected request %d to be limited, got %d", burst+1, rr.Code)
+	}
+	if got := rr.Header().Get("Retry-After"); got != "60" {
+		t.Errorf("expected Retry-After: 60, got %q", got)
+	}
+	if rr := request("192.0.2.2"); rr.Code != http.StatusFound {
+		t.Errorf("expected another client to be unaffected, got %d", rr.Code)
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		unlimited := RateLimit(0, burst)(ok)
+		for i := 0; i < burst+1; i++ {
+			rr := httptest.NewRecorder()
+			unlimited.ServeHTTP(rr, httptest.NewRequest("GET", "/auth/login", nil))
+			if rr.Code != http.StatusFound {
+				t.Fatalf("expected no limit, got %d", rr.Code)
+			}
+		}
+	})
+}
@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"go-wiki-app/internal/logger"
+	"net/http"
+
+	chiMiddleware "github.com/go-chi/chi/v5/middleware"
+)
+
+// RequestLogger stores a sub-logger tagged with the request's ID in the
+// context, so every line logged while serving the request can be matched to
+// it. It must run after chi's RequestID middleware.
+func RequestLogger(log logger.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if id := chiMiddleware.GetReqID(r.Context()); id != "" {
+				ctx := logger.NewContext(r.Context(), log.With(map[string]interface{}{"request_id": id}))
+				r = r.WithContext(ctx)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// requestLog returns the request-scoped logger stored by RequestLogger, or
+// log if there is none.
+func requestLog(r *http.Request, log logger.Logger) logger.Logger {
+	if reqLog := logger.FromContext(r.Context()); reqLog != nil {
+		return reqLog
+	}
+	return log
+}
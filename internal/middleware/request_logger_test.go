@@ -0,0 +1,45 @@
+//go:build unit
+
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"go-wiki-app/internal/config"
+	"go-wiki-app/internal/logger"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	chiMiddleware "github.com/go-chi/chi/v5/middleware"
+)
+
+func TestRequestLogger_TagsLogLinesWithRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	log := logger.New(config.LogConfig{Level: "info", Format: "json"}, &buf)
+	failing := Error(log, nil)(func(w http.ResponseWriter, r *http.Request) *AppError {
+		logger.FromContext(r.Context()).Info("loading page")
+		return &AppError{Error: errors.New("boom"), Message: "Page not found", Code: http.StatusNotFound}
+	})
+	handler := chiMiddleware.RequestID(RequestLogger(log)(failing))
+
+	req := httptest.NewRequest("GET", "/api/v1/pages/Missing", nil)
+	req.Header.Set(chiMiddleware.RequestIDHeader, "req-42")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected a line from the handler and one from the error middleware, got %q", buf.String())
+	}
+	for _, line := range lines {
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("failed to unmarshal log line %q: %v", line, err)
+		}
+		if entry["request_id"] != "req-42" {
+			t.Errorf("expected request_id req-42 in %q", line)
+		}
+	}
+}
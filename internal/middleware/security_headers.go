@@ -0,0 +1,28 @@
+package middleware
+
+import "net/http"
+
+// hstsMaxAge is the Strict-Transport-Security max-age, one year in seconds.
+const hstsMaxAge = "31536000"
+
+// SecurityHeaders sets headers that harden every response against content
+// sniffing, framing and injected scripts. csp is sent as the
+// Content-Security-Policy and left out when empty. Strict-Transport-Security
+// is only sent when hsts is set, i.e. when the server terminates TLS itself,
+// as browsers ignore it over plain HTTP.
+func SecurityHeaders(csp string, hsts bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h := w.Header()
+			h.Set("X-Content-Type-Options", "nosniff")
+			h.Set("X-Frame-Options", "DENY")
+			if csp != "" {
+				h.Set("Content-Security-Policy", csp)
+			}
+			if hsts {
+				h.Set("Strict-Transport-Security", "max-age="+hstsMaxAge)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
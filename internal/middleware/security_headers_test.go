@@ -0,0 +1,53 @@
+//go:build unit
+
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	})
+	const csp = "default-src 'self'; script-src 'self'"
+
+	t.Run("sets the headers on responses", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		SecurityHeaders(csp, false)(ok).ServeHTTP(rr, httptest.NewRequest("GET", "/view/Home", nil))
+
+		for header, want := range map[string]string{
+			"X-Content-Type-Options":  "nosniff",
+			"X-Frame-Options":         "DENY",
+			"Content-Security-Policy": csp,
+		} {
+			if got := rr.Header().Get(header); got != want {
+				t.Errorf("%s = %q, want %q", header, got, want)
+			}
+		}
+		if got := rr.Header().Get("Strict-Transport-Security"); got != "" {
+			t.Errorf("expected no Strict-Transport-Security without TLS, got %q", got)
+		}
+		if rr.Body.String() != "hello" {
+			t.Errorf("expected the handler's response, got %q", rr.Body.String())
+		}
+	})
+
+	t.Run("HSTS with TLS", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		SecurityHeaders(csp, true)(ok).ServeHTTP(rr, httptest.NewRequest("GET", "/view/Home", nil))
+		if got := rr.Header().Get("Strict-Transport-Security"); got != "max-age=31536000" {
+			t.Errorf("Strict-Transport-Security = %q, want max-age=31536000", got)
+		}
+	})
+
+	t.Run("empty policy sends no CSP", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		SecurityHeaders("", false)(ok).ServeHTTP(rr, httptest.NewRequest("GET", "/view/Home", nil))
+		if _, set := rr.Header()["Content-Security-Policy"]; set {
+			t.Error("expected no Content-Security-Policy header for an empty policy")
+		}
+	})
+}
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"context"
+	"go-wiki-app/internal/session"
+	"net/http"
+)
+
+// SessionEpochKey is the session key holding the session epoch a login session
+// was created in.
+const SessionEpochKey = "session_epoch"
+
+// SessionEpochLookup returns the current session epoch.
+type SessionEpochLookup func(ctx context.Context) (int64, error)
+
+// SessionEpoch ends logged-in sessions created before the current session epoch,
+// so that an administrator's lockdown forces everyone to log in again. Such
+// sessions are redirected to log in. It must run after the session is loaded.
+func SessionEpoch(sm session.Manager, current SessionEpochLookup) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			if sm.GetString(ctx, "user_subject") != "" {
+				epoch, err := current(ctx)
+				if err != nil {
+					http.Error(w, "Session error", http.StatusInternalServerError)
+					return
+				}
+				if sm.GetInt64(ctx, SessionEpochKey) != epoch {
+					if err := sm.Destroy(ctx); err != nil {
+						http.Error(w, "Session error", http.StatusInternalServerError)
+						return
+					}
+					http.Redirect(w, r, "/auth/login", http.StatusFound)
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,49 @@
+//go:build unit
+
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSessionEpoch_Lockdown(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	var epoch int64
+	current := func(ctx context.Context) (int64, error) { return epoch, nil }
+	serve := func(sm *mockSession) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		SessionEpoch(sm, current)(next).ServeHTTP(rr, httptest.NewRequest("GET", "/view/Home", nil))
+		return rr
+	}
+
+	before := &mockSession{values: map[string]interface{}{"user_subject": "alice", SessionEpochKey: int64(0)}}
+	if rr := serve(before); before.destroyed || rr.Code != http.StatusOK {
+		t.Fatalf("expected a session of the current epoch to continue, got status %d destroyed=%v", rr.Code, before.destroyed)
+	}
+
+	// An administrator starts a lockdown.
+	epoch++
+
+	rr := serve(before)
+	if !before.destroyed {
+		t.Error("expected a session created before the lockdown to be destroyed")
+	}
+	if rr.Code != http.StatusFound || rr.Header().Get("Location") != "/auth/login" {
+		t.Errorf("expected redirect to login, got %d %q", rr.Code, rr.Header().Get("Location"))
+	}
+
+	after := &mockSession{values: map[string]interface{}{"user_subject": "alice", SessionEpochKey: int64(1)}}
+	if rr := serve(after); after.destroyed || rr.Code != http.StatusOK {
+		t.Errorf("expected a session created after the lockdown to continue, got status %d destroyed=%v", rr.Code, after.destroyed)
+	}
+
+	anonymous := &mockSession{values: map[string]interface{}{}}
+	if rr := serve(anonymous); anonymous.destroyed || rr.Code != http.StatusOK {
+		t.Errorf("expected anonymous sessions to be left alone, got status %d destroyed=%v", rr.Code, anonymous.destroyed)
+	}
+}
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"go-wiki-app/internal/session"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// SessionDeadlineKey is the session key holding the time at which a logged-in
+	// session expires. It lets sessions have different lifetimes (e.g. "remember me")
+	// even though the session manager's lifetime is global.
+	SessionDeadlineKey = "session_deadline"
+	// LastActivityKey is the session key holding the time of the user's last request.
+	LastActivityKey = "last_activity"
+	// IdleRemainingHeader tells clients how many seconds remain before an idle
+	// session is logged out, so they can warn the user.
+	IdleRemainingHeader = "X-Session-Idle-Remaining"
+)
+
+// SessionExpiry ends logged-in sessions that have passed their deadline or,
+// when idleTimeout is positive, that have been inactive for longer than
+// idleTimeout. Expired deadlines continue the request anonymously; idle sessions
+// are redirected to log in again. It must run after the session is loaded.
+func SessionExpiry(sm session.Manager, idleTimeout time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			now := time.Now()
+
+			deadline := sm.GetTime(ctx, SessionDeadlineKey)
+			if !deadline.IsZero() && now.After(deadline) {
+				if err := sm.Destroy(ctx); err != nil {
+					http.Error(w, "Session error", http.StatusInternalServerError)
+					return
+				}
+			}
+
+			if idleTimeout > 0 && sm.GetString(ctx, "user_subject") != "" {
+				lastActivity := sm.GetTime(ctx, LastActivityKey)
+				if !lastActivity.IsZero() && now.Sub(lastActivity) > idleTimeout {
+					if err := sm.Destroy(ctx); err != nil {
+						http.Error(w, "Session error", http.StatusInternalServerError)
+						return
+					}
+					http.Redirect(w, r, "/auth/login", http.StatusFound)
+					return
+				}
+				sm.Put(ctx, LastActivityKey, now)
+				w.Header().Set(IdleRemainingHeader, strconv.Itoa(int(idleTimeout.Seconds())))
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,100 @@
+//go:build unit
+
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// mockSession is an in-memory session.Manager for middleware tests.
+type mockSession struct {
+	values    map[string]interface{}
+	destroyed bool
+}
+
+func (m *mockSession) LoadAndSave(next http.Handler) http.Handler { return next }
+func (m *mockSession) Put(ctx context.Context, key string, val interface{}) {
+	m.values[key] = val
+}
+func (m *mockSession) GetString(ctx context.Context, key string) string {
+	s, _ := m.values[key].(string)
+	return s
+}
+func (m *mockSession) GetTime(ctx context.Context, key string) time.Time {
+	t, _ := m.values[key].(time.Time)
+	return t
+}
+func (m *mockSession) GetInt64(ctx context.Context, key string) int64 {
+	n, _ := m.values[key].(int64)
+	return n
+}
+func (m *mockSession) PopString(ctx context.Context, key string) string {
+	s := m.GetString(ctx, key)
+	delete(m.values, key)
+	return s
+}
+func (m *mockSession) Remove(ctx context.Context, key string)   { delete(m.values, key) }
+func (m *mockSession) RememberMe(ctx context.Context, val bool) {}
+func (m *mockSession) Destroy(ctx context.Context) error {
+	m.values = map[string]interface{}{}
+	m.destroyed = true
+	return nil
+}
+
+func TestSessionExpiry_IdleTimeout(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	t.Run("active session is kept and refreshed", func(t *testing.T) {
+		sm := &mockSession{values: map[string]interface{}{
+			"user_subject":  "alice",
+			LastActivityKey: time.Now().Add(-5 * time.Minute),
+		}}
+		rr := httptest.NewRecorder()
+		SessionExpiry(sm, 30*time.Minute)(next).ServeHTTP(rr, httptest.NewRequest("GET", "/view/Home", nil))
+
+		if sm.destroyed || rr.Code != http.StatusOK {
+			t.Fatalf("expected active session to continue, got status %d destroyed=%v", rr.Code, sm.destroyed)
+		}
+		if time.Since(sm.GetTime(context.Background(), LastActivityKey)) > time.Second {
+			t.Error("expected last activity to be refreshed")
+		}
+		if rr.Header().Get(IdleRemainingHeader) != "1800" {
+			t.Errorf("expected idle remaining header of 1800, got %q", rr.Header().Get(IdleRemainingHeader))
+		}
+	})
+
+	t.Run("idle session is logged out", func(t *testing.T) {
+		sm := &mockSession{values: map[string]interface{}{
+			"user_subject":  "alice",
+			LastActivityKey: time.Now().Add(-31 * time.Minute),
+		}}
+		rr := httptest.NewRecorder()
+		SessionExpiry(sm, 30*time.Minute)(next).ServeHTTP(rr, httptest.NewRequest("GET", "/view/Home", nil))
+
+		if !sm.destroyed {
+			t.Error("expected idle session to be destroyed")
+		}
+		if rr.Code != http.StatusFound || rr.Header().Get("Location") != "/auth/login" {
+			t.Errorf("expected redirect to login, got %d %q", rr.Code, rr.Header().Get("Location"))
+		}
+	})
+
+	t.Run("expired deadline continues anonymously", func(t *testing.T) {
+		sm := &mockSession{values: map[string]interface{}{
+			"user_subject":     "alice",
+			SessionDeadlineKey: time.Now().Add(-time.Minute),
+		}}
+		rr := httptest.NewRecorder()
+		SessionExpiry(sm, 0)(next).ServeHTTP(rr, httptest.NewRequest("GET", "/view/Home", nil))
+
+		if !sm.destroyed || rr.Code != http.StatusOK {
+			t.Errorf("expected session to be destroyed and request to continue, got %d destroyed=%v", rr.Code, sm.destroyed)
+		}
+	})
+}
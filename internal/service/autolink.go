@@ -0,0 +1,182 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+)
+
+// Auto-link modes for config.MarkdownConfig.AutoLinkMode.
+const (
+	AutoLinkExact     = "exact"
+	AutoLinkCamelCase = "camelcase"
+)
+
+// autoLinkTitlesKey carries the titles that may be auto-linked into a single parse.
+var autoLinkTitlesKey = parser.NewContextKey()
+
+// autoLinkCurrentKey carries the title of the page being rendered, which is never linked to itself.
+var autoLinkCurrentKey = parser.NewContextKey()
+
+// wikiWordPattern matches CamelCase words such as "WikiWord" or "ReleaseNotes2".
+var wikiWordPattern = regexp.MustCompile(`\b[A-Z][a-z0-9]+(?:[A-Z][a-z0-9]+)+\b`)
+
+// titleLinker is a goldmark AST transformer that turns mentions of existing
+// page titles in plain text into links to those pages. Text inside code spans
+// and existing links is left alone; code blocks never contain text nodes.
+type titleLinker struct {
+	mode string
+
+	// The exact-title pattern is rebuilt only when the set of titles changes.
+	mu           sync.Mutex
+	patternKey   string
+	titlePattern *regexp.Regexp
+}
+
+// Transform implements parser.ASTTransformer.
+func (l *titleLinker) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	titles, _ := pc.Get(autoLinkTitlesKey).(map[string]bool)
+	if len(titles) == 0 {
+		return
+	}
+	current, _ := pc.Get(autoLinkCurrentKey).(string)
+	pattern := wikiWordPattern
+	if l.mode != AutoLinkCamelCase {
+		pattern = l.exactPattern(titles)
+	}
+	source := reader.Source()
+
+	var textNodes []*ast.Text
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n.Kind() {
+		case ast.KindCodeSpan, ast.KindLink, ast.KindAutoLink, ast.KindImage, KindWikiLink:
+			return ast.WalkSkipChildren, nil
+		case ast.KindText:
+			textNodes = append(textNodes, n.(*ast.Text))
+		}
+		return ast.WalkContinue, nil
+	})
+
+	for _, node := range textNodes {
+		l.linkText(node, source, pattern, titles, current)
+	}
+}
+
+// linkText splits a text node around title mentions, replacing each mention with a link.
+func (l *titleLinker) linkText(node *ast.Text, source []byte, pattern *regexp.Regexp, titles map[string]bool, current string) {
+	segment := node.Segment
+	value := segment.Value(source)
+	matches := pattern.FindAllSubmatchIndex(value, -1)
+	if len(matches) == 0 {
+		return
+	}
+	parent := node.Parent()
+	pos := 0
+	linked := false
+	for _, m := range matches {
+		// The exact-title pattern captures the title after its leading boundary.
+		start, end := m[0], m[1]
+		if len(m) >= 4 {
+			start, end = m[2], m[3]
+		}
+		if r, _ := utf8.DecodeRune(value[end:]); end < len(value) && isWordRune(r) {
+			continue
+		}
+		title := string(value[start:end])
+		if !titles[title] || title == current {
+			continue
+		}
+		if start > pos {
+			parent.InsertBefore(parent, node, ast.NewTextSegment(text.NewSegment(segment.Start+pos, segment.Start+start)))
+		}
+		link := ast.NewLink()
+		link.Destination = []byte("/view/" + url.PathEscape(title))
+		link.AppendChild(link, ast.NewTextSegment(text.NewSegment(segment.Start+start, segment.Start+end)))
+		parent.InsertBefore(parent, node, link)
+		pos = end
+		linked = true
+	}
+	if !linked {
+		return
+	}
+	// Keep the remainder in the original node so line break flags are preserved.
+	node.Segment = segment.WithStart(segment.Start + pos)
+	if node.Segment.Len() == 0 && !node.SoftLineBreak() && !node.HardLineBreak() {
+		parent.RemoveChild(parent, node)
+	}
+}
+
+// exactPattern returns the pattern for the titles, reusing the compiled one
+// from the previous parse when the titles have not changed.
+func (l *titleLinker) exactPattern(titles map[string]bool) *regexp.Regexp {
+	sorted := make([]string, 0, len(titles))
+	for title := range titles {
+		sorted = append(sorted, title)
+	}
+	sort.Strings(sorted)
+	key := strings.Join(sorted, "\n")
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.titlePattern == nil || l.patternKey != key {
+		l.titlePattern = exactTitlePattern(sorted)
+		l.patternKey = key
+	}
+	return l.titlePattern
+}
+
+// exactTitlePattern builds a pattern matching any of the titles after a word
+// boundary, preferring the longest title when several overlap. The title is
+// the first submatch; Go regexps have no lookahead, so the boundary after it
+// is checked by the caller with isWordRune. Unlike \b, the boundaries treat
+// letters and digits of every script as word characters.
+func exactTitlePattern(titles []string) *regexp.Regexp {
+	quoted := make([]string, 0, len(titles))
+	for _, title := range titles {
+		quoted = append(quoted, regexp.QuoteMeta(title))
+	}
+	sort.SliceStable(quoted, func(i, j int) bool { return len(quoted[i]) > len(quoted[j]) })
+	return regexp.MustCompile(`(?:^|[^\p{L}\p{M}\p{N}_])(` + strings.Join(quoted, "|") + `)`)
+}
+
+// isWordRune reports whether r is part of a word in any script.
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsMark(r) || unicode.IsNumber(r)
+}
+
+// pageTitles returns the set of existing page titles, cached until the page list changes.
+func (s *PageService) pageTitles(ctx context.Context) map[string]bool {
+	var list []string
+	if cached, _ := s.cache.Get(pageTitlesCacheKey); cached == nil || json.Unmarshal(cached, &list) != nil {
+		pages, err := s.repo.GetAllPages(ctx)
+		if err != nil {
+			return nil
+		}
+		list = make([]string, 0, len(pages))
+		for _, page := range pages {
+			list = append(list, page.Title)
+		}
+		if bytesToCache, err := json.Marshal(list); err == nil {
+			s.cache.Set(pageTitlesCacheKey, bytesToCache, 5*time.Minute)
+		}
+	}
+	titles := make(map[string]bool, len(list))
+	for _, title := range list {
+		titles[title] = true
+	}
+	return titles
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"go-wiki-app/internal/data"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// backupTimestampFormat names backup files so they sort chronologically.
+const backupTimestampFormat = "20060102T150405.000000000Z"
+
+// backupPage writes a copy of the page's markdown to the configured backup
+// directory as {slug}/{timestamp}.md. The write happens in the background and
+// failures are only logged, so backups never block or fail a save.
+func (s *PageService) backupPage(page *data.Page) {
+	dir := s.content.FileBackupDir
+	if dir == "" {
+		return
+	}
+	slug := Slugify(page.Title, s.content.SlugTransliterate)
+	content := []byte(page.Content)
+	name := time.Now().UTC().Format(backupTimestampFormat) + ".md"
+
+	s.backups.Add(1)
+	go func() {
+		defer s.backups.Done()
+		if err := writeBackup(filepath.Join(dir, slug), name, content); err != nil && s.log != nil {
+			s.log.Error(err, fmt.Sprintf("Failed to back up page %q", page.Title))
+		}
+	}()
+}
+
+// WaitForBackups blocks until the background backup writes have finished or
+// the context is done, so a shutdown does not cut a backup file short.
+func (s *PageService) WaitForBackups(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.backups.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func writeBackup(dir, name string, content []byte) error {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, name), content, 0o640)
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidCategoryDelete is returned when a category's pages would be
+// reassigned to the category itself.
+var ErrInvalidCategoryDelete = errors.New("invalid category deletion")
+
+// DeleteCategory deletes a category without subcategories. Its pages move to
+// the category reassignToID, or the NoSubCategory below it when it is a
+// top-level category, along with the pages only tagged with it, or,
+// when reassignToID is nil, to the NoCategory/NoSubCategory default that pages
+// saved without a category are filed under, dropping its tags from the others.
+// It fails with data.ErrCategoryHasSubcategories if the category still has
+// subcategories, which must be deleted or merged elsewhere first.
+func (s *PageService) DeleteCategory(ctx context.Context, id int64, reassignToID *int64) error {
+	category, err := s.categoryRepo.GetByID(id)
+	if err != nil {
+		return err
+	}
+	if category == nil {
+		return fmt.Errorf("%w: id %d", ErrCategoryNotFound, id)
+	}
+	var targetID int64
+	if reassignToID != nil {
+		target, err := s.categoryRepo.GetByID(*reassignToID)
+		if err != nil {
+			return err
+		}
+		if target == nil {
+			return fmt.Errorf("%w: id %d", ErrCategoryNotFound, *reassignToID)
+		}
+		targetID = target.ID
+		if target.ParentID == nil {
+			// Pages are filed below a top-level category, under its
+			// NoSubCategory, as when they are saved with it.
+			subcategoryID, err := s.getOrCreateCategoryPath(ctx, []string{target.Name, "NoSubCategory"})
+			if err != nil {
+				return err
+			}
+			targetID = *subcategoryID
+		}
+	} else {
+		defaultID, err := s.getOrCreateCategories(ctx, "", "")
+		if err != nil {
+			return err
+		}
+		targetID = *defaultID
+	}
+	if targetID == category.ID {
+		return fmt.Errorf("%w: the pages of '%s' cannot be moved to itself", ErrInvalidCategoryDelete, category.Name)
+	}
+
+	// Collect the affected pages first, as the category is gone afterwards.
+	titles, err := s.categoryPageTitles(ctx, category.ID)
+	if err != nil {
+		return err
+	}
+	if err := s.categoryRepo.Delete(ctx, category.ID, targetID, reassignToID != nil); err != nil {
+		return err
+	}
+	for _, title := range titles {
+		s.cache.Delete("page:" + title)
+	}
+	s.invalidatePageList()
+	return nil
+}
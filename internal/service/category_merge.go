@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// ErrInvalidCategoryMerge is returned when a category is merged into itself or
+// one of its subcategories, or a top-level category is merged with a subcategory.
+var ErrInvalidCategoryMerge = errors.New("invalid category merge")
+
+// MergeCategories moves every page and subcategory of the source category into
+// the target and deletes the source. Subcategories that exist under both are
+// merged into the target's one. Both categories must be top-level, or both be
+// subcategories.
+func (s *PageService) MergeCategories(ctx context.Context, sourceID, targetID int64) error {
+	if sourceID == targetID {
+		return fmt.Errorf("%w: a category cannot be merged into itself", ErrInvalidCategoryMerge)
+	}
+	source, err := s.categoryRepo.GetByID(sourceID)
+	if err != nil {
+		return err
+	}
+	if source == nil {
+		return fmt.Errorf("%w: id %d", ErrCategoryNotFound, sourceID)
+	}
+	target, err := s.categoryRepo.GetByID(targetID)
+	if err != nil {
+		return err
+	}
+	if target == nil {
+		return fmt.Errorf("%w: id %d", ErrCategoryNotFound, targetID)
+	}
+	if (source.ParentID == nil) != (target.ParentID == nil) {
+		return fmt.Errorf("%w: '%s' and '%s' are not at the same level", ErrInvalidCategoryMerge, source.Name, target.Name)
+	}
+	// Merging into a category below the source would make it its own parent.
+	all, err := s.categoryRepo.GetAll()
+	if err != nil {
+		return err
+	}
+	if slices.Contains(categoryDescendants(all, source.ID), target.ID) {
+		return fmt.Errorf("%w: '%s' is below '%s'", ErrInvalidCategoryMerge, target.Name, source.Name)
+	}
+
+	// Collect the affected pages first, as the source's categories are gone afterwards.
+	titles, err := s.categoryPageTitles(ctx, source.ID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.categoryRepo.Merge(ctx, source.ID, target.ID); err != nil {
+		return err
+	}
+	for _, title := range titles {
+		s.cache.Delete("page:" + title)
+	}
+	s.invalidatePageList()
+	return nil
+}
+
+// categoryPageTitles returns the titles of the pages in a category and its
+// subcategories, whose cached copies show the category's path.
+func (s *PageService) categoryPageTitles(ctx context.Context, id int64) ([]string, error) {
+	all, err := s.categoryRepo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	var titles []string
+	for _, id := range categoryDescendants(all, id) {
+		pages, err := s.repo.GetPagesByCategoryID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range pages {
+			titles = append(titles, p.Title)
+		}
+	}
+	return titles, nil
+}
@@ -0,0 +1,17 @@
+package service
+
+import "context"
+
+// UpdateCategoryOrder sets the order weights of categories, keyed by ID.
+// Categories are listed lightest first among their siblings, then by name, so
+// equal weights keep the alphabetical order.
+func (s *PageService) UpdateCategoryOrder(ctx context.Context, weights map[int64]int) error {
+	if len(weights) == 0 {
+		return nil
+	}
+	if err := s.categoryRepo.UpdateOrder(ctx, weights); err != nil {
+		return err
+	}
+	s.invalidatePageList()
+	return nil
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"go-wiki-app/internal/data"
+	"strings"
+)
+
+// maxCategoryDepth bounds the walk up a category's parents, so that a cycle in
+// the stored tree cannot hang a request.
+const maxCategoryDepth = 32
+
+// splitCategoryPath splits a slash-delimited category path such as
+// "Science/Physics" into its names, ignoring empty segments and the spaces
+// around each name.
+func splitCategoryPath(path string) []string {
+	var names []string
+	for _, name := range strings.Split(path, "/") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// categoryPath returns the names on the path to a page's category, top-level
+// category first. A page without a category or subcategory is filed under
+// NoCategory and NoSubCategory, so every page sits at least two levels deep.
+func categoryPath(categoryName, subcategoryName string) []string {
+	names := splitCategoryPath(categoryName)
+	if len(names) == 0 {
+		names = []string{"NoCategory"}
+	}
+	names = append(names, splitCategoryPath(subcategoryName)...)
+	if len(names) == 1 {
+		names = append(names, "NoSubCategory")
+	}
+	return names
+}
+
+// findCategoryPath walks down the category tree along the given names. It
+// returns nil if a category on the path does not exist.
+func (s *PageService) findCategoryPath(names []string) (*data.Category, error) {
+	var category *data.Category
+	for _, name := range names {
+		var parentID *int64
+		if category != nil {
+			parentID = &category.ID
+		}
+		next, err := s.categoryRepo.FindByName(name, parentID)
+		if err != nil || next == nil {
+			return nil, err
+		}
+		category = next
+	}
+	return category, nil
+}
+
+// categoryDescendants returns the IDs of the category and of every category
+// below it, at any depth. Each category is listed once, so a cycle in the
+// parent links cannot make it loop forever.
+func categoryDescendants(all []*data.Category, id int64) []int64 {
+	children := make(map[int64][]int64)
+	for _, c := range all {
+		if c.ParentID != nil {
+			children[*c.ParentID] = append(children[*c.ParentID], c.ID)
+		}
+	}
+	ids := []int64{id}
+	seen := map[int64]bool{id: true}
+	for i := 0; i < len(ids); i++ {
+		for _, child := range children[ids[i]] {
+			if !seen[child] {
+				seen[child] = true
+				ids = append(ids, child)
+			}
+		}
+	}
+	return ids
+}
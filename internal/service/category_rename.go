@@ -0,0 +1,45 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidCategoryName is returned when a category is renamed to an empty
+// name or one containing a slash, which separates the levels of a category path.
+var ErrInvalidCategoryName = errors.New("invalid category name")
+
+// RenameCategory gives a category a new name. Its pages and subcategories stay
+// in it, so their paths change with it. It fails with data.ErrCategoryExists if
+// another category under the same parent already has the name.
+func (s *PageService) RenameCategory(ctx context.Context, id int64, newName string) error {
+	newName = strings.TrimSpace(newName)
+	if newName == "" || strings.Contains(newName, "/") {
+		return fmt.Errorf("%w: '%s'", ErrInvalidCategoryName, newName)
+	}
+	category, err := s.categoryRepo.GetByID(id)
+	if err != nil {
+		return err
+	}
+	if category == nil {
+		return fmt.Errorf("%w: id %d", ErrCategoryNotFound, id)
+	}
+	if category.Name == newName {
+		return nil
+	}
+
+	titles, err := s.categoryPageTitles(ctx, category.ID)
+	if err != nil {
+		return err
+	}
+	if err := s.categoryRepo.Rename(ctx, category.ID, newName); err != nil {
+		return err
+	}
+	for _, title := range titles {
+		s.cache.Delete("page:" + title)
+	}
+	s.invalidatePageList()
+	return nil
+}
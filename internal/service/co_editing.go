@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go-wiki-app/internal/data"
+	"sort"
+	"time"
+)
+
+const (
+	// coEditScanLimit bounds how many recent revisions are scanned for co-edits.
+	coEditScanLimit = 5000
+	// coEditedCacheTTL is how long a page's co-edited pages are cached.
+	coEditedCacheTTL = 10 * time.Minute
+)
+
+// GetCoEditedPages returns up to limit pages that were often edited together with
+// the given page: pages the same author edited within the configured time window
+// of an edit to this page. Pages with the most such edits come first. Only recent
+// revisions are considered, and the result is cached per page.
+func (s *PageService) GetCoEditedPages(ctx context.Context, pageID int64, limit int) ([]*data.Page, error) {
+	if s.revisions == nil || s.retention.CoEditWindowMinutes <= 0 {
+		return []*data.Page{}, nil
+	}
+
+	key := fmt.Sprintf("coedited:%d", pageID)
+	var ids []int64
+	if cached, _ := s.cache.Get(key); cached == nil || json.Unmarshal(cached, &ids) != nil {
+		var err error
+		if ids, err = s.coEditedPageIDs(ctx, pageID); err != nil {
+			return nil, err
+		}
+		if b, err := json.Marshal(ids); err == nil {
+			s.cache.Set(key, b, coEditedCacheTTL)
+		}
+	}
+
+	pages := make([]*data.Page, 0, limit)
+	for _, id := range ids {
+		if len(pages) == limit {
+			break
+		}
+		page, err := s.repo.GetPageByID(ctx, id)
+		if err != nil || page.DeletedAt != nil {
+			// The page may have been deleted since the result was cached.
+			continue
+		}
+		pages = append(pages, page)
+	}
+	return pages, nil
+}
+
+// coEditedPageIDs ranks the pages co-edited with pageID by the number of edits
+// made within the window of an edit to pageID by the same author.
+func (s *PageService) coEditedPageIDs(ctx context.Context, pageID int64) ([]int64, error) {
+	since := time.Time{}
+	if days := s.retention.CoEditLookbackDays; days > 0 {
+		since = time.Now().AddDate(0, 0, -days)
+	}
+	edits, err := s.revisions.GetRecentEdits(ctx, since, coEditScanLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	// Anonymous edits are not attributed to a single person, so they are ignored.
+	byAuthor := make(map[string][]*data.Revision)
+	for _, edit := range edits {
+		if edit.AuthorID != "" && edit.AuthorID != "anonymous" {
+			byAuthor[edit.AuthorID] = append(byAuthor[edit.AuthorID], edit)
+		}
+	}
+
+	window := time.Duration(s.retention.CoEditWindowMinutes) * time.Minute
+	scores := make(map[int64]int)
+	for _, authorEdits := range byAuthor {
+		for _, own := range authorEdits {
+			if own.PageID != pageID {
+				continue
+			}
+			for _, other := range authorEdits {
+				if other.PageID == pageID {
+					continue
+				}
+				if d := other.CreatedAt.Sub(own.CreatedAt); d <= window && d >= -window {
+					scores[other.PageID]++
+				}
+			}
+		}
+	}
+
+	ids := make([]int64, 0, len(scores))
+	for id := range scores {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if scores[ids[i]] != scores[ids[j]] {
+			return scores[ids[i]] > scores[ids[j]]
+		}
+		return ids[i] < ids[j]
+	})
+	return ids, nil
+}
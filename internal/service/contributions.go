@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-wiki-app/internal/data"
+	"go-wiki-app/internal/middleware"
+)
+
+// RevertResult describes what reverting a user's contributions did to one page.
+type RevertResult struct {
+	PageID int64
+	Title  string
+	// RevertedTo is the revision the page was restored to, or nil if the page was left unchanged.
+	RevertedTo *data.Revision
+	// Reason explains why a page was left unchanged.
+	Reason string
+}
+
+// GetContributions returns all revisions made by the subject, newest first. The
+// subject is a user's ID or the IP address of an anonymous editor.
+func (s *PageService) GetContributions(ctx context.Context, subject string) ([]*data.Revision, error) {
+	if s.revisions == nil {
+		return []*data.Revision{}, nil
+	}
+	return s.revisions.GetBySubject(ctx, subject)
+}
+
+// RevertContributionsBy undoes the subject's edits: every page whose current
+// version was made by the subject is restored to its newest revision by
+// someone else. Pages edited by someone else since, and pages only the subject
+// ever edited, are left unchanged. Each revert is saved as a new revision by
+// the current user and recorded in the activity log.
+func (s *PageService) RevertContributionsBy(ctx context.Context, subject string) ([]*RevertResult, error) {
+	if subject == "" {
+		return nil, errors.New("no subject to revert")
+	}
+	contributions, err := s.GetContributions(ctx, subject)
+	if err != nil {
+		return nil, err
+	}
+	var results []*RevertResult
+	reverted := make(map[int64]bool)
+	for _, contribution := range contributions {
+		if reverted[contribution.PageID] {
+			continue
+		}
+		reverted[contribution.PageID] = true
+		result, err := s.revertPage(ctx, contribution.PageID, subject)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// revertPage restores a page to its newest revision not made by the subject.
+func (s *PageService) revertPage(ctx context.Context, pageID int64, subject string) (*RevertResult, error) {
+	revisions, err := s.revisions.GetRevisions(ctx, pageID)
+	if err != nil {
+		return nil, err
+	}
+	result := &RevertResult{PageID: pageID}
+	if len(revisions) == 0 {
+		result.Reason = "the page has no revisions"
+		return result, nil
+	}
+	result.Title = revisions[0].Title
+	if !madeBy(revisions[0], subject) {
+		result.Reason = "someone else has edited the page since"
+		return result, nil
+	}
+	var target *data.Revision
+	for _, revision := range revisions[1:] {
+		if !madeBy(revision, subject) {
+			target = revision
+			break
+		}
+	}
+	if target == nil {
+		result.Reason = "every revision of the page is by this user"
+		return result, nil
+	}
+
+	page, err := s.repo.GetPageByID(ctx, pageID)
+	if err != nil {
+		return nil, err
+	}
+	_ = s.populateCategoryNames(page)
+	page, err = s.UpdatePage(ctx, pageID, target.Title, target.Content, page.CategoryName, page.SubcategoryName, false)
+	if err != nil {
+		return nil, err
+	}
+	s.recordActivity(ctx, page, data.ActivityRevert, false)
+	if s.log != nil {
+		s.log.Info(fmt.Sprintf("%s reverted %q to revision %d, undoing edits by %s",
+			middleware.GetUserInfo(ctx).Subject, page.Title, target.ID, subject))
+	}
+	result.Title = page.Title
+	result.RevertedTo = target
+	return result, nil
+}
+
+// madeBy reports whether the revision was made by the subject, matching either
+// the author or the address of an anonymous editor.
+func madeBy(revision *data.Revision, subject string) bool {
+	return revision.AuthorID == subject || (revision.AuthorIP != "" && revision.AuthorIP == subject)
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"go-wiki-app/internal/cache"
+	"go-wiki-app/internal/data"
+	"time"
+)
+
+// dashboardCacheTTL is how long the dashboard's counts are cached.
+const dashboardCacheTTL = time.Minute
+
+// DashboardServicer provides the widgets of the admin dashboard. Each widget is
+// computed on its own so that one failing does not hide the others.
+type DashboardServicer interface {
+	PageStats(ctx context.Context) (*data.ContentStats, error)
+	CategoryCount(ctx context.Context) (int, error)
+	AuthorCount(ctx context.Context) (int, error)
+	CacheStats() cache.Stats
+	GetRecentActivity(ctx context.Context, filter data.ActivityFilter, limit, offset int) ([]*data.Activity, error)
+	DatabaseHealth(ctx context.Context) error
+}
+
+// AdminServicer provides the dashboard widgets and the maintenance actions
+// available to administrators.
+type AdminServicer interface {
+	DashboardServicer
+	PruneRevisions(ctx context.Context) (int, error)
+	ResanitizeAll(ctx context.Context) (int, error)
+	GetContributions(ctx context.Context, subject string) ([]*data.Revision, error)
+	RevertContributionsBy(ctx context.Context, subject string) ([]*RevertResult, error)
+	StartExternalLinkCheck() bool
+	LinkCheckStatus() LinkCheckStatus
+	GetDeadLinks(ctx context.Context) ([]*data.DeadLink, error)
+	SetPageOwner(ctx context.Context, title, owner string) error
+	GetCategoryTree(ctx context.Context) ([]*CategoryNode, error)
+	MergeCategories(ctx context.Context, sourceID, targetID int64) error
+	UpdateCategoryOrder(ctx context.Context, weights map[int64]int) error
+}
+
+var _ AdminServicer = (*PageService)(nil)
+
+// PageStats returns the number of pages and the total size of their content.
+func (s *PageService) PageStats(ctx context.Context) (*data.ContentStats, error) {
+	return s.contentUsage(ctx)
+}
+
+// CategoryCount returns the number of categories and subcategories.
+func (s *PageService) CategoryCount(ctx context.Context) (int, error) {
+	return s.cachedCount("dashboard:categories", func() (int, error) {
+		categories, err := s.categoryRepo.GetAll()
+		return len(categories), err
+	})
+}
+
+// AuthorCount returns the number of distinct users who have created pages.
+func (s *PageService) AuthorCount(ctx context.Context) (int, error) {
+	return s.cachedCount("dashboard:authors", func() (int, error) {
+		return s.repo.CountAuthors(ctx)
+	})
+}
+
+// CacheStats returns the page cache's hit and miss counts.
+func (s *PageService) CacheStats() cache.Stats {
+	return s.cache.Stats()
+}
+
+// DatabaseHealth returns an error if the database cannot be reached.
+func (s *PageService) DatabaseHealth(ctx context.Context) error {
+	return s.repo.Ping(ctx)
+}
+
+// cachedCount returns the count cached under key, computing and caching it if needed.
+func (s *PageService) cachedCount(key string, count func() (int, error)) (int, error) {
+	if cached, _ := s.cache.Get(key); cached != nil {
+		var n int
+		if json.Unmarshal(cached, &n) == nil {
+			return n, nil
+		}
+	}
+	n, err := count()
+	if err != nil {
+		return 0, err
+	}
+	if b, err := json.Marshal(n); err == nil {
+		s.cache.Set(key, b, dashboardCacheTTL)
+	}
+	return n, nil
+}
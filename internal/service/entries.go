@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"go-wiki-app/internal/data"
+	"strings"
+	"time"
+)
+
+// ErrInvalidEntry is returned when a log entry has no collection or body, or
+// its collection contains a slash.
+var ErrInvalidEntry = errors.New("invalid entry")
+
+// entrySeparator divides the entries appended to the same page.
+const entrySeparator = "\n\n---\n\n"
+
+// EntryTitle returns the title of the page that keeps the entries of the
+// collection for the day of date.
+func EntryTitle(collection string, date time.Time) string {
+	return strings.TrimSpace(collection) + " " + date.Format("2006-01-02")
+}
+
+// AppendEntry files a log entry, such as an incident report, on the page for
+// its collection and day, e.g. "Incidents 2024-06-01" under the category
+// Incidents/2024. The day's first entry creates the page and later ones are
+// appended to it below a horizontal rule. It reports whether the page was
+// created. Entries are filed one at a time, so entries posted together are all
+// kept. When edits by the current user need review, the entry itself is
+// queued and appended to the page as it is when a moderator approves it.
+func (s *PageService) AppendEntry(ctx context.Context, collection string, date time.Time, body, authorID string) (*data.Page, bool, error) {
+	collection, body = strings.TrimSpace(collection), strings.TrimSpace(body)
+	if collection == "" || body == "" || strings.Contains(collection, "/") {
+		return nil, false, fmt.Errorf("%w: collection %q", ErrInvalidEntry, collection)
+	}
+	title := EntryTitle(collection, date)
+	year := date.Format("2006")
+	if s.needsReview(ctx) {
+		return nil, false, s.queueEntry(ctx, title, body, collection, year)
+	}
+	return s.appendToPage(ctx, title, body, authorID, collection, year)
+}
+
+// appendToPage appends the entry to the page with the title, creating the page
+// under the category and subcategory if it does not exist yet.
+func (s *PageService) appendToPage(ctx context.Context, title, body, authorID, categoryName, subcategoryName string) (*data.Page, bool, error) {
+	s.entryMu.Lock()
+	defer s.entryMu.Unlock()
+	page, err := s.repo.GetPageByTitle(ctx, title)
+	if errors.Is(err, sql.ErrNoRows) {
+		created, createErr := s.CreatePage(ctx, title, body, authorID, categoryName, subcategoryName)
+		if createErr == nil {
+			return created, true, nil
+		}
+		// Another writer, such as a second instance, may have created the
+		// page in the meantime; the entry is then appended to it.
+		if page, err = s.repo.GetPageByTitle(ctx, title); err != nil {
+			return nil, false, createErr
+		}
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	updated, err := s.UpdatePage(ctx, page.ID, page.Title, withEntry(page.Content, body), categoryName, subcategoryName, false)
+	return updated, false, err
+}
+
+// queueEntry holds an entry for review and returns ErrPendingReview. Only the
+// entry is queued, so entries approved one after another are all kept.
+func (s *PageService) queueEntry(ctx context.Context, title, body, categoryName, subcategoryName string) error {
+	edit := &data.PendingEdit{
+		Title:           title,
+		Content:         body,
+		CategoryName:    categoryName,
+		SubcategoryName: subcategoryName,
+		Entry:           true,
+	}
+	page, err := s.repo.GetPageByTitle(ctx, title)
+	if err == nil {
+		edit.PageID = page.ID
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	return s.queue(ctx, edit)
+}
+
+// withEntry returns the content of a page with the entry appended to it.
+func withEntry(content, body string) string {
+	existing := strings.TrimRight(content, "\n")
+	if existing == "" {
+		return body
+	}
+	return existing + entrySeparator + body
+}
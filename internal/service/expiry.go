@@ -0,0 +1,28 @@
+package service
+
+import (
+	"context"
+	"go-wiki-app/internal/data"
+	"time"
+)
+
+// SetPageExpiry schedules the page to be archived at expiresAt, or removes the
+// expiry if it is nil. Archival is evaluated when pages are read, so no
+// background job is needed and changing the date takes effect immediately.
+func (s *PageService) SetPageExpiry(ctx context.Context, pageID int64, expiresAt *time.Time) error {
+	page, err := s.repo.GetPageByID(ctx, pageID)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.SetPageExpiry(ctx, pageID, expiresAt); err != nil {
+		return err
+	}
+	s.cache.Delete("page:" + page.Title)
+	s.invalidatePageList()
+	return nil
+}
+
+// GetArchivedPages returns the pages that have passed their expiry date, most recently archived first.
+func (s *PageService) GetArchivedPages(ctx context.Context) ([]*data.Page, error) {
+	return s.repo.GetArchivedPages(ctx, time.Now())
+}
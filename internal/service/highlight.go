@@ -0,0 +1,30 @@
+package service
+
+import (
+	"bytes"
+
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/yuin/goldmark"
+	highlighting "github.com/yuin/goldmark-highlighting/v2"
+)
+
+// newHighlighting highlights fenced code blocks that name their language. Tokens
+// are marked with classes rather than inline styles, which the sanitizer would
+// strip; the colours come from the stylesheet built by HighlightCSS.
+func newHighlighting() goldmark.Extender {
+	return highlighting.NewHighlighting(
+		highlighting.WithFormatOptions(chromahtml.WithClasses(true)),
+	)
+}
+
+// HighlightCSS returns the stylesheet that colours highlighted code blocks in
+// the named chroma theme. Unknown themes fall back to chroma's default.
+func HighlightCSS(theme string) ([]byte, error) {
+	var buf bytes.Buffer
+	formatter := chromahtml.New(chromahtml.WithClasses(true))
+	if err := formatter.WriteCSS(&buf, styles.Get(theme)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,383 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"go-wiki-app/internal/data"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultLinkCheckUserAgent is sent when no user agent is configured.
+	defaultLinkCheckUserAgent = "PumiceWiki-LinkChecker/1.0"
+	// defaultLinkCheckTimeout bounds each request when no timeout is configured.
+	defaultLinkCheckTimeout = 10 * time.Second
+	// maxRobotsBytes caps how much of a site's robots.txt is read.
+	maxRobotsBytes = 512 * 1024
+)
+
+// HTTPDoer sends HTTP requests. It is satisfied by *http.Client and can be stubbed in tests.
+type HTTPDoer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// externalURLPattern matches absolute http(s) URLs in page markdown, stopping at
+// whitespace and at the characters that close markdown links and HTML attributes.
+var externalURLPattern = regexp.MustCompile(`https?://[^\s<>()"'\[\]]+`)
+
+// LinkCheckStatus reports the state of the background external link check.
+type LinkCheckStatus struct {
+	Running bool
+	// LastRun is when the last successful check finished, zero if none has.
+	LastRun time.Time
+	// Checked is the number of distinct URLs the last check looked at.
+	Checked int
+}
+
+// linkResult is the outcome of checking a single URL.
+type linkResult struct {
+	status int
+	err    string
+}
+
+// dead reports whether the result shows the link is broken. Being rate limited
+// says nothing about the link, so 429 responses are not counted as dead.
+func (r linkResult) dead() bool {
+	return r.err != "" || (r.status >= 400 && r.status != http.StatusTooManyRequests)
+}
+
+// StartExternalLinkCheck runs CheckExternalLinks in the background, off the
+// request path. It returns false without starting anything if a check is
+// already running.
+func (s *PageService) StartExternalLinkCheck() bool {
+	s.linkCheckMu.Lock()
+	defer s.linkCheckMu.Unlock()
+	if s.linkCheckStatus.Running {
+		return false
+	}
+	s.linkCheckStatus.Running = true
+
+	go func() {
+		checked, err := s.CheckExternalLinks(context.Background())
+		s.linkCheckMu.Lock()
+		s.linkCheckStatus.Running = false
+		if err == nil {
+			s.linkCheckStatus.LastRun = time.Now()
+			s.linkCheckStatus.Checked = checked
+		}
+		s.linkCheckMu.Unlock()
+		if err != nil && s.log != nil {
+			s.log.Error(err, "External link check failed")
+		}
+	}()
+	return true
+}
+
+// LinkCheckStatus returns the state of the background external link check.
+func (s *PageService) LinkCheckStatus() LinkCheckStatus {
+	s.linkCheckMu.Lock()
+	defer s.linkCheckMu.Unlock()
+	return s.linkCheckStatus
+}
+
+// GetDeadLinks returns the dead links found by the last external link check.
+func (s *PageService) GetDeadLinks(ctx context.Context) ([]*data.DeadLink, error) {
+	return s.repo.GetDeadLinks(ctx)
+}
+
+// CheckExternalLinks requests every external URL found in the pages' content and
+// stores the dead ones as the new link report. Hosts are checked concurrently, up
+// to the configured limit, while the requests to a single host are made one at a
+// time with a pause between them. URLs the site's robots.txt disallows are skipped.
+// It returns the number of distinct URLs found.
+func (s *PageService) CheckExternalLinks(ctx context.Context) (int, error) {
+	pages, err := s.repo.GetAllPages(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	linkedFrom := make(map[string][]*data.Page)
+	byOrigin := make(map[string][]string)
+	for _, page := range pages {
+		for _, link := range extractExternalURLs(page.Content) {
+			if len(linkedFrom[link]) == 0 {
+				u, _ := url.Parse(link)
+				origin := u.Scheme + "://" + u.Host
+				byOrigin[origin] = append(byOrigin[origin], link)
+			}
+			linkedFrom[link] = append(linkedFrom[link], page)
+		}
+	}
+
+	var mu sync.Mutex
+	results := make(map[string]linkResult)
+	origins := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < s.linkCheckConcurrency(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for origin := range origins {
+				s.checkOrigin(ctx, origin, byOrigin[origin], func(link string, result linkResult) {
+					mu.Lock()
+					results[link] = result
+					mu.Unlock()
+				})
+			}
+		}()
+	}
+	for origin := range byOrigin {
+		origins <- origin
+	}
+	close(origins)
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	now := time.Now().UTC()
+	var dead []*data.DeadLink
+	for link, result := range results {
+		if !result.dead() {
+			continue
+		}
+		for _, page := range linkedFrom[link] {
+			dead = append(dead, &data.DeadLink{
+				URL:        link,
+				PageID:     page.ID,
+				PageTitle:  page.Title,
+				StatusCode: result.status,
+				Error:      deadLinkReason(result),
+				CheckedAt:  now,
+			})
+		}
+	}
+	sort.Slice(dead, func(i, j int) bool {
+		if dead[i].PageTitle != dead[j].PageTitle {
+			return dead[i].PageTitle < dead[j].PageTitle
+		}
+		return dead[i].URL < dead[j].URL
+	})
+	if err := s.repo.ReplaceDeadLinks(ctx, dead); err != nil {
+		return 0, err
+	}
+	return len(linkedFrom), nil
+}
+
+// checkOrigin checks the links of a single site in order, honouring its
+// robots.txt and pausing between requests so the site is not flooded.
+func (s *PageService) checkOrigin(ctx context.Context, origin string, links []string, record func(string, linkResult)) {
+	delay := time.Duration(s.linkCheck.HostDelayMillis) * time.Millisecond
+	robots := s.fetchRobots(ctx, origin)
+	for _, link := range links {
+		u, _ := url.Parse(link)
+		if !robots.allowed(u.RequestURI()) {
+			continue
+		}
+		if delay > 0 {
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return
+			}
+		}
+		record(link, s.checkLink(ctx, link))
+	}
+}
+
+// checkLink requests the URL with HEAD, falling back to GET for servers that do not support HEAD.
+func (s *PageService) checkLink(ctx context.Context, link string) linkResult {
+	result := s.requestLink(ctx, http.MethodHead, link)
+	if result.status == http.StatusMethodNotAllowed || result.status == http.StatusNotImplemented {
+		result = s.requestLink(ctx, http.MethodGet, link)
+	}
+	return result
+}
+
+func (s *PageService) requestLink(ctx context.Context, method, link string) linkResult {
+	resp, err := s.linkCheckGet(ctx, method, link)
+	if err != nil {
+		return linkResult{err: err.Error()}
+	}
+	resp.Body.Close()
+	return linkResult{status: resp.StatusCode}
+}
+
+// linkCheckGet sends a request as the link checker, bounded by the configured timeout.
+// The response body must be closed by the caller.
+func (s *PageService) linkCheckGet(ctx context.Context, method, link string) (*http.Response, error) {
+	timeout := time.Duration(s.linkCheck.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = defaultLinkCheckTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	req, err := http.NewRequestWithContext(ctx, method, link, nil)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	req.Header.Set("User-Agent", s.linkCheckUserAgent())
+	client := s.httpClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = cancelOnClose{resp.Body, cancel}
+	return resp, nil
+}
+
+// fetchRobots loads the robots.txt rules of a site. A missing or unreadable
+// robots.txt allows everything.
+func (s *PageService) fetchRobots(ctx context.Context, origin string) robotsRules {
+	resp, err := s.linkCheckGet(ctx, http.MethodGet, origin+"/robots.txt")
+	if err != nil {
+		return robotsRules{}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return robotsRules{}
+	}
+	return parseRobots(io.LimitReader(resp.Body, maxRobotsBytes), s.linkCheckUserAgent())
+}
+
+func (s *PageService) linkCheckConcurrency() int {
+	if s.linkCheck.Concurrency > 0 {
+		return s.linkCheck.Concurrency
+	}
+	return 1
+}
+
+func (s *PageService) linkCheckUserAgent() string {
+	if s.linkCheck.UserAgent != "" {
+		return s.linkCheck.UserAgent
+	}
+	return defaultLinkCheckUserAgent
+}
+
+// cancelOnClose releases a request's context once its response body is closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
+
+// deadLinkReason describes why a link is dead for the report.
+func deadLinkReason(result linkResult) string {
+	if result.err != "" {
+		return result.err
+	}
+	return fmt.Sprintf("%d %s", result.status, http.StatusText(result.status))
+}
+
+// extractExternalURLs returns the distinct http(s) URLs in markdown content, in
+// order of appearance. Punctuation that ends a sentence is not part of the URL.
+func extractExternalURLs(content string) []string {
+	var links []string
+	seen := make(map[string]bool)
+	for _, match := range externalURLPattern.FindAllString(content, -1) {
+		link := strings.TrimRight(match, ".,;:!?*_")
+		u, err := url.Parse(link)
+		if err != nil || u.Host == "" || seen[link] {
+			continue
+		}
+		seen[link] = true
+		links = append(links, link)
+	}
+	return links
+}
+
+// robotsRules are the Allow and Disallow path prefixes of a robots.txt that
+// apply to the link checker.
+type robotsRules struct {
+	allow    []string
+	disallow []string
+}
+
+// allowed reports whether the path may be requested. The longest matching rule
+// wins, and Allow wins a tie, as in RFC 9309.
+func (r robotsRules) allowed(path string) bool {
+	longest := func(prefixes []string) int {
+		n := -1
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(path, prefix) && len(prefix) > n {
+				n = len(prefix)
+			}
+		}
+		return n
+	}
+	return longest(r.allow) >= longest(r.disallow)
+}
+
+// parseRobots reads the rules of a robots.txt that apply to userAgent: those of
+// the group naming its product token if there is one, otherwise those for "*".
+func parseRobots(body io.Reader, userAgent string) robotsRules {
+	token := strings.ToLower(strings.SplitN(userAgent, "/", 2)[0])
+	var specific, wildcard robotsRules
+	hasSpecific := false
+	var groups []*robotsRules
+	inRules := false
+
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			// A user-agent line after rules starts a new group.
+			if inRules {
+				groups, inRules = nil, false
+			}
+			agent := strings.ToLower(value)
+			switch {
+			case agent == "*":
+				groups = append(groups, &wildcard)
+			case agent != "" && strings.Contains(token, agent):
+				groups = append(groups, &specific)
+				hasSpecific = true
+			}
+		case "allow", "disallow":
+			inRules = true
+			// An empty Disallow allows everything, so it adds no rule.
+			if value == "" {
+				continue
+			}
+			for _, group := range groups {
+				if key == "allow" {
+					group.allow = append(group.allow, value)
+				} else {
+					group.disallow = append(group.disallow, value)
+				}
+			}
+		}
+	}
+	if hasSpecific {
+		return specific
+	}
+	return wildcard
+}
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"go-wiki-app/internal/data"
+	"go-wiki-app/internal/middleware"
+	"sort"
+	"time"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+// resanitizeBatchSize is the number of pages processed between cancellation checks.
+const resanitizeBatchSize = 50
+
+// ResanitizeAll runs the raw HTML stored in every page's markdown through the
+// current sanitizer policy and saves the pages whose content changed,
+// recording a revision for each. The markdown around the HTML is left as
+// written. It returns the number of pages that were changed. The task stops
+// between batches if ctx is cancelled.
+func (s *PageService) ResanitizeAll(ctx context.Context) (int, error) {
+	pages, err := s.repo.GetAllPages(ctx)
+	if err != nil {
+		return 0, err
+	}
+	subject := middleware.GetUserInfo(ctx).Subject
+	changed := 0
+	for start := 0; start < len(pages); start += resanitizeBatchSize {
+		if err := ctx.Err(); err != nil {
+			return changed, err
+		}
+		end := start + resanitizeBatchSize
+		if end > len(pages) {
+			end = len(pages)
+		}
+		for _, page := range pages[start:end] {
+			_, sanitizer := s.renderingFor(page)
+			sanitized := sanitizeRawHTML(s.markdown, sanitizer, page.Content)
+			if sanitized == page.Content {
+				continue
+			}
+			page.Content = sanitized
+			page.UpdatedAt = time.Now()
+			if err := s.savePage(ctx, page, subject, false); err != nil {
+				return changed, err
+			}
+			s.cache.Delete("page:" + page.Title)
+			s.recordActivity(ctx, page, data.ActivityUpdate, false)
+			changed++
+		}
+	}
+	if changed > 0 {
+		s.invalidatePageList()
+	}
+	return changed, nil
+}
+
+// sanitizeRawHTML sanitizes the HTML blocks and inline HTML tags of the
+// markdown source, leaving everything else, including code and autolinks,
+// untouched.
+func sanitizeRawHTML(markdown goldmark.Markdown, policy *bluemonday.Policy, content string) string {
+	source := []byte(content)
+	doc := markdown.Parser().Parse(text.NewReader(source))
+	var spans [][2]int
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch node := n.(type) {
+		case *ast.HTMLBlock:
+			lines := node.Lines()
+			if lines.Len() == 0 {
+				return ast.WalkContinue, nil
+			}
+			span := [2]int{lines.At(0).Start, lines.At(lines.Len() - 1).Stop}
+			if node.HasClosure() {
+				span[1] = node.ClosureLine.Stop
+			}
+			spans = append(spans, span)
+		case *ast.RawHTML:
+			if node.Segments.Len() > 0 {
+				spans = append(spans, [2]int{node.Segments.At(0).Start, node.Segments.At(node.Segments.Len() - 1).Stop})
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+	if len(spans) == 0 {
+		return content
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i][0] < spans[j][0] })
+
+	var out []byte
+	last := 0
+	for _, span := range spans {
+		if span[0] < last {
+			continue
+		}
+		out = append(out, source[last:span[0]]...)
+		raw := source[span[0]:span[1]]
+		sanitized := policy.SanitizeBytes(raw)
+		// Keep the trailing newline of a block that is not stripped entirely.
+		if len(bytes.TrimSpace(sanitized)) > 0 && bytes.HasSuffix(raw, []byte("\n")) && !bytes.HasSuffix(sanitized, []byte("\n")) {
+			sanitized = append(sanitized, '\n')
+		}
+		out = append(out, sanitized...)
+		last = span[1]
+	}
+	return string(append(out, source[last:]...))
+}
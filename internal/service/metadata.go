@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-wiki-app/internal/data"
+	"strings"
+)
+
+// ErrUnknownMetadataKey is returned when a metadata key is not in the configured allow-list.
+var ErrUnknownMetadataKey = errors.New("unknown metadata key")
+
+// MetadataFields returns the metadata keys pages may carry, in display order.
+func (s *PageService) MetadataFields() []string {
+	return s.content.MetadataFields
+}
+
+// SetPageMetadata updates a page's metadata. Keys missing from the map are
+// left unchanged and keys with an empty value are removed. Every key must be
+// in the configured allow-list; otherwise nothing is saved.
+func (s *PageService) SetPageMetadata(ctx context.Context, pageID int64, metadata map[string]string) error {
+	for key := range metadata {
+		if !s.isMetadataField(key) {
+			return fmt.Errorf("%w: %q", ErrUnknownMetadataKey, key)
+		}
+	}
+	page, err := s.repo.GetPageByID(ctx, pageID)
+	if err != nil {
+		return err
+	}
+	for key, value := range metadata {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			err = s.repo.DeletePageMetadata(ctx, pageID, key)
+		} else {
+			err = s.repo.SetPageMetadata(ctx, pageID, key, value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	s.cache.Delete("page:" + page.Title)
+	return nil
+}
+
+// populateMetadata loads the page's metadata, keeping only the allowed keys.
+func (s *PageService) populateMetadata(ctx context.Context, page *data.Page) error {
+	if len(s.content.MetadataFields) == 0 {
+		return nil
+	}
+	metadata, err := s.repo.GetPageMetadata(ctx, page.ID)
+	if err != nil {
+		return err
+	}
+	for key := range metadata {
+		if !s.isMetadataField(key) {
+			delete(metadata, key)
+		}
+	}
+	page.Metadata = metadata
+	return nil
+}
+
+func (s *PageService) isMetadataField(key string) bool {
+	for _, field := range s.content.MetadataFields {
+		if field == key {
+			return true
+		}
+	}
+	return false
+}
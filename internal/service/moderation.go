@@ -0,0 +1,235 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"go-wiki-app/internal/data"
+	"go-wiki-app/internal/diff"
+	"go-wiki-app/internal/middleware"
+	"slices"
+)
+
+// ErrPendingReview is returned by CreatePage, UpdatePage, RollbackPage, MovePage
+// and AppendEntry when the change was queued for a moderator's review instead
+// of being saved.
+var ErrPendingReview = errors.New("edit is pending review")
+
+// ErrPendingEditNotFound is returned when a queued edit does not exist, for
+// instance because another moderator already reviewed it.
+var ErrPendingEditNotFound = errors.New("pending edit not found")
+
+// PendingEditReview is a queued edit compared with the current version of its page.
+type PendingEditReview struct {
+	Edit *data.PendingEdit
+	// Page is the page as it is now.
+	Page  *data.Page
+	Lines []diff.Line
+	// Added and Removed count the lines the edit inserts and deletes.
+	Added, Removed int
+	// Conflict is set when the page was saved after the edit was queued. The
+	// edit was written against an older version, so approving it would undo
+	// the changes made since.
+	Conflict bool
+}
+
+// PendingEditRepository defines the interface for database operations on the
+// queue of edits awaiting review.
+type PendingEditRepository interface {
+	CreatePendingEdit(ctx context.Context, edit *data.PendingEdit) error
+	GetPendingEdit(ctx context.Context, id int64) (*data.PendingEdit, error)
+	GetPendingEdits(ctx context.Context) ([]*data.PendingEdit, error)
+	DeletePendingEdit(ctx context.Context, id int64) error
+}
+
+// approvedEditKey marks the context of an edit a moderator has approved, which
+// is saved on behalf of its author without being queued again.
+type approvedEditKey struct{}
+
+// needsReview reports whether edits by the current user must be approved by a
+// moderator before they are published.
+func (s *PageService) needsReview(ctx context.Context) bool {
+	if s.pendingEdits == nil || ctx.Value(approvedEditKey{}) != nil {
+		return false
+	}
+	for _, role := range middleware.GetUserInfo(ctx).Roles {
+		if slices.Contains(s.trustedRoles, role) {
+			return false
+		}
+	}
+	return true
+}
+
+// queueEdit holds an edit to the page for review and returns ErrPendingReview.
+func (s *PageService) queueEdit(ctx context.Context, id int64, title, content, categoryName, subcategoryName string, minor bool) error {
+	title, categoryName, subcategoryName, err := s.splitTitlePath(ctx, id, title, categoryName, subcategoryName)
+	if err != nil {
+		return err
+	}
+	if _, err := s.repo.GetPageByID(ctx, id); err != nil {
+		return err
+	}
+	return s.queue(ctx, &data.PendingEdit{
+		PageID:          id,
+		Title:           title,
+		Content:         content,
+		CategoryName:    categoryName,
+		SubcategoryName: subcategoryName,
+		Minor:           minor,
+	})
+}
+
+// queueNewPage holds a new page for review. No page is stored until a
+// moderator approves it, so readers never see it and a rejection leaves
+// nothing behind. The page as it would be created is returned with
+// ErrPendingReview.
+func (s *PageService) queueNewPage(ctx context.Context, title, content, authorID, categoryName, subcategoryName string) (*data.Page, error) {
+	if _, err := s.repo.GetPageByTitle(ctx, title); err == nil {
+		return nil, fmt.Errorf("page %q already exists", title)
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	if s.inTrash(ctx, title) {
+		return nil, ErrPageInTrash
+	}
+	page := &data.Page{Title: title, Content: content, AuthorID: authorID}
+	return page, s.queue(ctx, &data.PendingEdit{
+		Title:           title,
+		Content:         content,
+		CategoryName:    categoryName,
+		SubcategoryName: subcategoryName,
+	})
+}
+
+// queue stores the edit by the current user in the review queue and returns
+// ErrPendingReview.
+func (s *PageService) queue(ctx context.Context, edit *data.PendingEdit) error {
+	userInfo := middleware.GetUserInfo(ctx)
+	edit.AuthorID = userInfo.Subject
+	if userInfo.Subject == "anonymous" {
+		edit.AuthorIP = userInfo.IP
+	}
+	if err := s.pendingEdits.CreatePendingEdit(ctx, edit); err != nil {
+		return err
+	}
+	return ErrPendingReview
+}
+
+// GetPendingEdits returns the edits awaiting review, oldest first. Without a
+// review queue there are none.
+func (s *PageService) GetPendingEdits(ctx context.Context) ([]*data.PendingEdit, error) {
+	if s.pendingEdits == nil {
+		return []*data.PendingEdit{}, nil
+	}
+	return s.pendingEdits.GetPendingEdits(ctx)
+}
+
+// GetPendingEditReviews returns the edits awaiting review, oldest first, each
+// compared with the current content of its page.
+func (s *PageService) GetPendingEditReviews(ctx context.Context) ([]*PendingEditReview, error) {
+	edits, err := s.GetPendingEdits(ctx)
+	if err != nil {
+		return nil, err
+	}
+	reviews := make([]*PendingEditReview, 0, len(edits))
+	for _, edit := range edits {
+		page, err := s.pendingEditPage(ctx, edit)
+		if err != nil {
+			return nil, err
+		}
+		review := &PendingEditReview{
+			Edit:  edit,
+			Page:  page,
+			Lines: diff.Lines(page.Content, edit.Content),
+			// A new page conflicts with a page given its title since.
+			Conflict: page.UpdatedAt.After(edit.CreatedAt) || (edit.PageID == 0 && page.ID != 0),
+		}
+		if edit.Entry {
+			// An entry is appended to the page as it is then, so it
+			// never undoes later changes.
+			review.Lines = diff.Lines(page.Content, withEntry(page.Content, edit.Content))
+			review.Conflict = false
+		}
+		for _, line := range review.Lines {
+			switch line.Op {
+			case diff.Insert:
+				review.Added++
+			case diff.Delete:
+				review.Removed++
+			}
+		}
+		reviews = append(reviews, review)
+	}
+	return reviews, nil
+}
+
+// pendingEditPage returns the page the edit changes. A queued new page is
+// compared with an empty page, unless a page has been given its title since.
+func (s *PageService) pendingEditPage(ctx context.Context, edit *data.PendingEdit) (*data.Page, error) {
+	if edit.PageID != 0 && !edit.Entry {
+		return s.repo.GetPageByID(ctx, edit.PageID)
+	}
+	page, err := s.repo.GetPageByTitle(ctx, edit.Title)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &data.Page{Title: edit.Title}, nil
+	}
+	return page, err
+}
+
+// ApprovePendingEdit publishes a queued edit as a new revision of its page and
+// removes it from the queue. The edit is saved on behalf of its author, so the
+// history, activity log and watchers see who wrote it, and it never gets the
+// trusted HTML of an admin approving it. A queued new page is created then,
+// and a queued log entry is appended to the page as it is now.
+func (s *PageService) ApprovePendingEdit(ctx context.Context, id int64) (*data.Page, error) {
+	edit, err := s.getPendingEdit(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	authorCtx := middleware.SetUserInfo(ctx, &middleware.UserInfo{Subject: edit.AuthorID, IP: edit.AuthorIP})
+	authorCtx = context.WithValue(authorCtx, approvedEditKey{}, true)
+	var page *data.Page
+	if edit.Entry {
+		page, _, err = s.appendToPage(authorCtx, edit.Title, edit.Content, edit.AuthorID, edit.CategoryName, edit.SubcategoryName)
+	} else if edit.PageID == 0 {
+		page, err = s.CreatePage(authorCtx, edit.Title, edit.Content, edit.AuthorID, edit.CategoryName, edit.SubcategoryName)
+	} else {
+		page, err = s.updatePage(authorCtx, edit.PageID, edit.Title, edit.Content, edit.CategoryName, edit.SubcategoryName, edit.AuthorID, edit.Minor, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := s.pendingEdits.DeletePendingEdit(ctx, id); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// RejectPendingEdit discards a queued edit and returns it, so the caller can
+// report the rejection.
+func (s *PageService) RejectPendingEdit(ctx context.Context, id int64) (*data.PendingEdit, error) {
+	edit, err := s.getPendingEdit(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.pendingEdits.DeletePendingEdit(ctx, id); err != nil {
+		return nil, err
+	}
+	return edit, nil
+}
+
+// getPendingEdit loads a queued edit, mapping a missing one to ErrPendingEditNotFound.
+func (s *PageService) getPendingEdit(ctx context.Context, id int64) (*data.PendingEdit, error) {
+	if s.pendingEdits == nil {
+		return nil, ErrPendingEditNotFound
+	}
+	edit, err := s.pendingEdits.GetPendingEdit(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrPendingEditNotFound
+		}
+		return nil, err
+	}
+	return edit, nil
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"go-wiki-app/internal/config"
+	"go-wiki-app/internal/logger"
+)
+
+// Option configures optional dependencies and behaviour of a PageService.
+type Option func(*PageService)
+
+// WithRevisions enables revision history, storing a snapshot of every saved
+// page in the given repository.
+func WithRevisions(repo RevisionRepository) Option {
+	return func(s *PageService) {
+		s.revisions = repo
+	}
+}
+
+// WithRevisionRetention sets the policy PruneRevisions uses to drop old revisions.
+func WithRevisionRetention(cfg config.RevisionsConfig) Option {
+	return func(s *PageService) {
+		s.retention = cfg
+	}
+}
+
+// WithContentConfig applies content management settings such as creation quotas.
+func WithContentConfig(cfg config.ContentConfig) Option {
+	return func(s *PageService) {
+		s.content = cfg
+	}
+}
+
+// WithMarkdownConfig applies markdown rendering settings such as title auto-linking.
+func WithMarkdownConfig(cfg config.MarkdownConfig) Option {
+	return func(s *PageService) {
+		s.markdownConfig = cfg
+	}
+}
+
+// WithModeration holds edits by users without one of the configured trusted
+// roles in the given queue until a moderator approves them. It has no effect
+// unless cfg.RequireReview is set.
+func WithModeration(repo PendingEditRepository, cfg config.ModerationConfig) Option {
+	return func(s *PageService) {
+		if cfg.RequireReview {
+			s.pendingEdits = repo
+			s.trustedRoles = cfg.TrustedRoles
+		}
+	}
+}
+
+// WithPageACLs lets editors restrict single pages to the users holding a role.
+// Roles inherited through other roles are resolved with roles; when it is nil,
+// only the roles assigned to the user directly count.
+func WithPageACLs(repo PageACLRepository, roles RoleResolver) Option {
+	return func(s *PageService) {
+		s.pageACLs = repo
+		s.roleResolver = roles
+	}
+}
+
+// WithLinkCheck applies the external link checker's concurrency, timeout and politeness settings.
+func WithLinkCheck(cfg config.LinkCheckConfig) Option {
+	return func(s *PageService) {
+		s.linkCheck = cfg
+	}
+}
+
+// WithHTTPClient sets the client used for outgoing requests, such as link checks.
+// By default http.DefaultClient is used.
+func WithHTTPClient(client HTTPDoer) Option {
+	return func(s *PageService) {
+		s.httpClient = client
+	}
+}
+
+// WithLogger sets the logger used to report failures of background work,
+// such as file backups, that cannot be returned to the caller.
+func WithLogger(log logger.Logger) Option {
+	return func(s *PageService) {
+		s.log = log
+	}
+}
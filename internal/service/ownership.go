@@ -0,0 +1,27 @@
+package service
+
+import "context"
+
+// PageOwner returns the subject owning the page, or an empty string if it has no owner.
+// It matches middleware.PageOwnerLookup so owners can edit their pages without the editor role.
+func (s *PageService) PageOwner(ctx context.Context, title string) (string, error) {
+	page, err := s.repo.GetPageByTitle(ctx, title)
+	if err != nil {
+		return "", err
+	}
+	return page.OwnerSubject, nil
+}
+
+// SetPageOwner hands a page over to another subject. An empty owner removes the
+// page's owner, leaving it editable by editors only.
+func (s *PageService) SetPageOwner(ctx context.Context, title, owner string) error {
+	page, err := s.repo.GetPageByTitle(ctx, title)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.SetPageOwner(ctx, page.ID, owner); err != nil {
+		return err
+	}
+	s.cache.Delete("page:" + title)
+	return nil
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"go-wiki-app/internal/data"
+	"go-wiki-app/internal/middleware"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ErrPageACLsDisabled is returned by SetPageRequiredRole when the service was
+// created without a PageACLRepository.
+var ErrPageACLsDisabled = errors.New("page access control is not enabled")
+
+// PageACLRepository defines the interface for database operations on the
+// roles pages are restricted to.
+type PageACLRepository interface {
+	GetRequiredRole(ctx context.Context, pageID int64) (string, error)
+	GetRequiredRoles(ctx context.Context) (map[int64]string, error)
+	SetRequiredRole(ctx context.Context, pageID int64, role string) error
+}
+
+// requiredRolesCacheKey caches the roles of every restricted page, so pages
+// loaded by listings, which do not carry their role, can be checked too.
+const requiredRolesCacheKey = "acl:roles"
+
+// RoleResolver returns every role a user holds, including the roles inherited
+// through other roles. It is satisfied by casbin.IEnforcer.
+type RoleResolver interface {
+	GetImplicitRolesForUser(name string, domain ...string) ([]string, error)
+}
+
+// CanView reports whether the user may read the page. Restricted pages are
+// only shown to users holding the page's required role, directly or through
+// another role, and to admins. Pages that were not loaded with their role,
+// such as those in listings, are looked up among the restricted pages.
+func (s *PageService) CanView(ctx context.Context, page *data.Page, userInfo *middleware.UserInfo) bool {
+	role := page.RequiredRole
+	if role == "" && page.ID != 0 && s.pageACLs != nil {
+		roles, err := s.requiredRoles(ctx)
+		if err != nil {
+			// Without the restrictions, no page can be shown safely.
+			return false
+		}
+		role = roles[page.ID]
+	}
+	if role == "" {
+		return true
+	}
+	if userInfo == nil || userInfo.Subject == "anonymous" {
+		return false
+	}
+	roles := userInfo.Roles
+	if s.roleResolver != nil {
+		implicit, err := s.roleResolver.GetImplicitRolesForUser(userInfo.Subject)
+		if err != nil {
+			return false
+		}
+		roles = implicit
+	}
+	return slices.Contains(roles, role) || slices.Contains(roles, "admin")
+}
+
+// requiredRoles returns the roles of the restricted pages, keyed by page ID.
+func (s *PageService) requiredRoles(ctx context.Context) (map[int64]string, error) {
+	var roles map[int64]string
+	if cached, _ := s.cache.Get(requiredRolesCacheKey); cached != nil && json.Unmarshal(cached, &roles) == nil {
+		return roles, nil
+	}
+	roles, err := s.pageACLs.GetRequiredRoles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if bytesToCache, err := json.Marshal(roles); err == nil {
+		s.cache.Set(requiredRolesCacheKey, bytesToCache, 5*time.Minute)
+	}
+	return roles, nil
+}
+
+// SetPageRequiredRole restricts the page to users holding role. An empty role
+// makes the page visible to everyone again.
+func (s *PageService) SetPageRequiredRole(ctx context.Context, pageID int64, role string) error {
+	if s.pageACLs == nil {
+		return ErrPageACLsDisabled
+	}
+	page, err := s.repo.GetPageByID(ctx, pageID)
+	if err != nil {
+		return err
+	}
+	if err := s.pageACLs.SetRequiredRole(ctx, pageID, strings.TrimSpace(role)); err != nil {
+		return err
+	}
+	s.cache.Delete("page:" + page.Title)
+	s.cache.Delete(requiredRolesCacheKey)
+	return nil
+}
+
+// populateRequiredRole loads the role the page is restricted to.
+func (s *PageService) populateRequiredRole(ctx context.Context, page *data.Page) error {
+	if s.pageACLs == nil {
+		return nil
+	}
+	role, err := s.pageACLs.GetRequiredRole(ctx, page.ID)
+	if err != nil {
+		return err
+	}
+	page.RequiredRole = role
+	return nil
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"go-wiki-app/internal/data"
+	"strings"
+)
+
+// SetExtraCategories tags a page with further categories besides its own,
+// given as slash-delimited paths such as "Science/Physics", replacing its
+// previous ones. Missing categories are created. The page is listed under each
+// of them. Unlike a page's own category, a top-level path such as "Ops" tags
+// the page with that category itself.
+func (s *PageService) SetExtraCategories(ctx context.Context, pageID int64, paths []string) error {
+	page, err := s.repo.GetPageByID(ctx, pageID)
+	if err != nil {
+		return err
+	}
+	var ids []int64
+	if page.CategoryID != nil {
+		ids = append(ids, *page.CategoryID)
+	}
+	for _, path := range paths {
+		names := splitCategoryPath(path)
+		if len(names) == 0 {
+			continue
+		}
+		id, err := s.getOrCreateCategoryPath(ctx, names)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, *id)
+	}
+	if err := s.categoryRepo.SetPageCategories(ctx, page.ID, ids); err != nil {
+		return err
+	}
+	s.cache.Delete("page:" + page.Title)
+	return nil
+}
+
+// retagOwnCategory keeps a page tagged with its own category when the page
+// moves from the category previous to current, leaving its other tags alone.
+func (s *PageService) retagOwnCategory(ctx context.Context, pageID int64, previous, current *int64) error {
+	tagged, err := s.categoryRepo.GetCategoriesForPage(ctx, pageID)
+	if err != nil {
+		return err
+	}
+	var ids []int64
+	if current != nil {
+		ids = append(ids, *current)
+	}
+	for _, c := range tagged {
+		if previous == nil || c.ID != *previous {
+			ids = append(ids, c.ID)
+		}
+	}
+	return s.categoryRepo.SetPageCategories(ctx, pageID, ids)
+}
+
+// populateExtraCategories fills in the categories the page is tagged with
+// besides its own.
+func (s *PageService) populateExtraCategories(ctx context.Context, page *data.Page) error {
+	page.ExtraCategories = nil
+	tagged, err := s.categoryRepo.GetCategoriesForPage(ctx, page.ID)
+	if err != nil {
+		return err
+	}
+	for _, c := range tagged {
+		if page.CategoryID != nil && c.ID == *page.CategoryID {
+			continue
+		}
+		names, err := s.categoryAncestry(c.ID)
+		if err != nil {
+			return err
+		}
+		page.ExtraCategories = append(page.ExtraCategories, data.CategoryCrumb{Name: c.Name, Path: strings.Join(names, "/")})
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package service
+
+import "context"
+
+// MovePage files a page under another category, creating it and any missing
+// ancestors, without saving a new revision of the page. The category and
+// subcategory may be slash-delimited paths, as on the edit form. As there, a
+// top-level category files the page under its NoSubCategory, so the page stays
+// put when it is next edited. Like an edit, the move is queued when edits by
+// the current user need review.
+func (s *PageService) MovePage(ctx context.Context, title, categoryName, subcategoryName string) error {
+	page, err := s.repo.GetPageByTitle(ctx, title)
+	if err != nil {
+		return err
+	}
+	if s.needsReview(ctx) {
+		// Approving the edit files the unchanged content under the new category.
+		return s.queueEdit(ctx, page.ID, page.Title, page.Content, categoryName, subcategoryName, false)
+	}
+	categoryID, err := s.getOrCreateCategories(ctx, categoryName, subcategoryName)
+	if err != nil {
+		return err
+	}
+	previousCategoryID := page.CategoryID
+	if err := s.repo.SetPageCategory(ctx, page.ID, categoryID); err != nil {
+		return err
+	}
+	if err := s.retagOwnCategory(ctx, page.ID, previousCategoryID, categoryID); err != nil {
+		return err
+	}
+	s.cache.Delete("page:" + page.Title)
+	s.invalidatePageList()
+	return nil
+}
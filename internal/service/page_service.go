@@ -3,20 +3,29 @@ package service
 import (
 	"bytes"
 	"context"
+	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"go-wiki-app/internal/cache"
+	"go-wiki-app/internal/config"
 	"go-wiki-app/internal/data"
+	"go-wiki-app/internal/events"
+	"go-wiki-app/internal/logger"
 	"go-wiki-app/internal/middleware"
 	"html/template"
+	"regexp"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/microcosm-cc/bluemonday"
 	"github.com/yuin/goldmark"
 	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
 	"github.com/yuin/goldmark/renderer"
 	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
 	"github.com/yuin/goldmark/util"
 )
 
@@ -63,11 +72,36 @@ func (r *lazyLoadRenderer) renderImage(w util.BufWriter, source []byte, node ast
 type PageRepository interface {
 	CreatePage(ctx context.Context, page *data.Page) error
 	GetPageByTitle(ctx context.Context, title string) (*data.Page, error)
+	GetPagesByTitles(ctx context.Context, titles []string) ([]*data.Page, error)
 	GetPageByID(ctx context.Context, id int64) (*data.Page, error)
 	GetAllPages(ctx context.Context) ([]*data.Page, error)
+	GetPagesPaged(ctx context.Context, limit, offset int) ([]*data.Page, error)
+	CountPages(ctx context.Context) (int, error)
+	GetMaxUpdatedAt(ctx context.Context) (time.Time, error)
+	GetLatestArchivedAt(ctx context.Context, now time.Time) (time.Time, error)
+	ListPages(ctx context.Context, filter data.PageListFilter) (*data.PageList, error)
 	UpdatePage(ctx context.Context, page *data.Page) error
 	DeletePage(ctx context.Context, id int64) error
+	GetDeletedPages(ctx context.Context) ([]*data.Page, error)
+	RestorePage(ctx context.Context, id int64) error
+	PurgePage(ctx context.Context, id int64) error
 	GetPagesByCategoryID(ctx context.Context, categoryID int64) ([]*data.Page, error)
+	RecordActivity(ctx context.Context, activity *data.Activity) error
+	GetRecentActivity(ctx context.Context, filter data.ActivityFilter, limit, offset int) ([]*data.Activity, error)
+	GetPageMetadata(ctx context.Context, pageID int64) (map[string]string, error)
+	SetPageMetadata(ctx context.Context, pageID int64, key, value string) error
+	DeletePageMetadata(ctx context.Context, pageID int64, key string) error
+	GetContentStats(ctx context.Context) (*data.ContentStats, error)
+	SearchPages(ctx context.Context, filter data.SearchFilter) ([]*data.Page, error)
+	CountAuthors(ctx context.Context) (int, error)
+	GetPagesEditedBy(ctx context.Context, subject string, limit int) ([]*data.Page, error)
+	SetPageExpiry(ctx context.Context, pageID int64, expiresAt *time.Time) error
+	GetArchivedPages(ctx context.Context, now time.Time) ([]*data.Page, error)
+	ReplaceDeadLinks(ctx context.Context, links []*data.DeadLink) error
+	GetDeadLinks(ctx context.Context) ([]*data.DeadLink, error)
+	SetPageOwner(ctx context.Context, pageID int64, subject string) error
+	SetPageCategory(ctx context.Context, pageID int64, categoryID *int64) error
+	Ping(ctx context.Context) error
 }
 
 // CategoryRepository defines the interface for database operations on categories.
@@ -77,75 +111,268 @@ type CategoryRepository interface {
 	GetByID(id int64) (*data.Category, error)
 	GetAll() ([]*data.Category, error)
 	SearchByName(query string) ([]*data.Category, error)
+	Merge(ctx context.Context, sourceID, targetID int64) error
+	Rename(ctx context.Context, id int64, newName string) error
+	Delete(ctx context.Context, id, targetID int64, retag bool) error
+	UpdateOrder(ctx context.Context, weights map[int64]int) error
+	SetPageCategories(ctx context.Context, pageID int64, categoryIDs []int64) error
+	GetCategoriesForPage(ctx context.Context, pageID int64) ([]*data.Category, error)
 }
 
-// CategoryNode represents a parent category and its children.
+// CategoryNode is a category with its subcategories, which may have
+// subcategories of their own.
 type CategoryNode struct {
-	Parent   *data.Category
-	Children []*data.Category
+	Category *data.Category
+	// Path is the slash-delimited path from the top-level category, e.g. "Science/Physics".
+	Path     string
+	Children []*CategoryNode
 }
 
 // PageServicer defines the interface for interacting with pages.
 type PageServicer interface {
 	ViewPage(ctx context.Context, title string) (*data.Page, error)
+	GetPage(ctx context.Context, title string) (*data.Page, error)
 	CreatePage(ctx context.Context, title, content, authorID, categoryName, subcategoryName string) (*data.Page, error)
-	UpdatePage(ctx context.Context, id int64, title, content, categoryName, subcategoryName string) (*data.Page, error)
+	UpdatePage(ctx context.Context, id int64, title, content, categoryName, subcategoryName string, minor bool) (*data.Page, error)
 	GetAllPages(ctx context.Context) ([]*data.Page, error)
+	GetAllPagesPaged(ctx context.Context, page, perPage int) ([]*data.Page, int, error)
+	PagesLastModified(ctx context.Context) (time.Time, int, error)
+	ListPages(ctx context.Context, filter data.PageListFilter) (*data.PageList, error)
+	GetPagesByTitles(ctx context.Context, titles []string) (map[string]*data.Page, error)
 	DeletePage(ctx context.Context, id int64) error
+	GetTrash(ctx context.Context) ([]*data.Page, error)
+	RestorePage(ctx context.Context, id int64) error
+	PurgePage(ctx context.Context, id int64) error
 	GetCategoryTree(ctx context.Context) ([]*CategoryNode, error)
 	SearchCategories(ctx context.Context, query string) ([]*data.Category, error)
 	GetPagesForCategory(ctx context.Context, categoryName string) ([]*data.Page, error)
 	GetPagesForSubcategory(ctx context.Context, categoryName string, subcategoryName string) ([]*data.Page, error)
+	SubscribeToPage(ctx context.Context, title string) (<-chan events.Event, func(), error)
+	GetRecentActivity(ctx context.Context, filter data.ActivityFilter, limit, offset int) ([]*data.Activity, error)
+	GetPagesEditedBy(ctx context.Context, subject string, limit int) ([]*data.Page, error)
+	SetPageExpiry(ctx context.Context, pageID int64, expiresAt *time.Time) error
+	GetArchivedPages(ctx context.Context) ([]*data.Page, error)
+	GetCoEditedPages(ctx context.Context, pageID int64, limit int) ([]*data.Page, error)
+	GetPageHistory(ctx context.Context, title string) (*data.Page, []*data.Revision, error)
+	DiffAgainstCurrent(ctx context.Context, pageID, revisionID int64) (*RevisionDiff, error)
+	DiffRevisions(ctx context.Context, pageID, fromID, toID int64) (*RevisionDiff, error)
+	FindSimilarContent(ctx context.Context, content string) ([]*data.Page, error)
+	GetStubs(ctx context.Context) ([]*data.Page, error)
+	MetadataFields() []string
+	SetPageMetadata(ctx context.Context, pageID int64, metadata map[string]string) error
+	SearchPages(ctx context.Context, filter data.SearchFilter) ([]*SearchResult, error)
+	TemplateNames(ctx context.Context) ([]string, error)
+	CreateFromTemplate(ctx context.Context, templateName, title, author string) (*data.Page, error)
+	RollbackPage(ctx context.Context, pageID, revisionID int64, authorID string) (*data.Page, error)
+	CanView(ctx context.Context, page *data.Page, userInfo *middleware.UserInfo) bool
+	SetPageRequiredRole(ctx context.Context, pageID int64, role string) error
+	SetExtraCategories(ctx context.Context, pageID int64, paths []string) error
+	RenameCategory(ctx context.Context, id int64, newName string) error
+	DeleteCategory(ctx context.Context, id int64, reassignToID *int64) error
+	MovePage(ctx context.Context, title, categoryName, subcategoryName string) error
+	AppendEntry(ctx context.Context, collection string, date time.Time, body, authorID string) (*data.Page, bool, error)
 }
 
 var ErrAnonymousHome = errors.New("anonymous user viewing non-existent home page")
 
+// ErrCategoryNotFound is returned when pages are requested for a category or
+// subcategory that does not exist.
+var ErrCategoryNotFound = errors.New("category not found")
+
+// ErrPageNotFound is returned by ViewPage and GetPage when no page has the
+// title, as opposed to a failure to load it. The error also wraps sql.ErrNoRows.
+var ErrPageNotFound = errors.New("page not found")
+
+// pageTitlesCacheKey caches the list of existing page titles used for auto-linking.
+const pageTitlesCacheKey = "pages:titles"
+
+// headingIDPattern matches the heading ids generated by the markdown parser.
+var headingIDPattern = regexp.MustCompile(`^[\p{L}\p{N}_-]+$`)
+
+// missingLinkClassPattern matches the class of links to pages that do not exist.
+var missingLinkClassPattern = regexp.MustCompile(`^missing$`)
+
+// highlightClassPattern matches the classes chroma puts on highlighted code:
+// "chroma" on the <pre> and short token type names such as "kn" or "s1" on spans.
+var highlightClassPattern = regexp.MustCompile(`^[a-z0-9]+( [a-z0-9]+)*$`)
+
+// maxPageListLimit caps how many pages a single ListPages or GetAllPagesPaged call returns.
+const maxPageListLimit = 100
+
+// defaultPagesPerPage is the page size of GetAllPagesPaged when none is given.
+const defaultPagesPerPage = 50
+
+// maxPageSubscribers limits how many clients may listen for live updates
+// on a single page at once.
+const maxPageSubscribers = 50
+
 // PageService provides business logic for managing pages.
 type PageService struct {
-	repo         PageRepository
-	categoryRepo CategoryRepository
-	cache        *cache.Cache
-	sanitizer    *bluemonday.Policy
-	markdown     goldmark.Markdown
+	repo           PageRepository
+	categoryRepo   CategoryRepository
+	cache          *cache.Cache
+	sanitizer      *bluemonday.Policy
+	markdown       goldmark.Markdown
+	events         *events.Broker
+	revisions      RevisionRepository
+	retention      config.RevisionsConfig
+	content        config.ContentConfig
+	markdownConfig config.MarkdownConfig
+	log            logger.Logger
+	backups        sync.WaitGroup
+
+	// pendingEdits queues edits by users outside trustedRoles for review;
+	// nil when edits are published without review.
+	pendingEdits PendingEditRepository
+	trustedRoles []string
+
+	// pageACLs stores the roles single pages are restricted to; nil when
+	// pages cannot be restricted (see page_acl.go).
+	pageACLs     PageACLRepository
+	roleResolver RoleResolver
+
+	// trustedSanitizer and trustedMarkdown render pages last saved by an
+	// admin, keeping their raw HTML; nil when the operators allow admins
+	// nothing more than everyone else.
+	trustedSanitizer *bluemonday.Policy
+	trustedMarkdown  goldmark.Markdown
+
+	// rawHTMLSanitizer sanitizes the ```{=html} blocks of pages last saved
+	// by an admin; nil when raw HTML blocks are disabled (see raw_html.go).
+	rawHTMLSanitizer *bluemonday.Policy
+
+	// renderSlots bounds the number of pages rendered at once; nil when
+	// renders are not limited (see render_limit.go).
+	renderSlots        chan struct{}
+	renderQueueTimeout time.Duration
+
+	linkCheck       config.LinkCheckConfig
+	httpClient      HTTPDoer
+	linkCheckMu     sync.Mutex
+	linkCheckStatus LinkCheckStatus
+
+	// entryMu serializes AppendEntry so concurrent entries for the same day
+	// neither overwrite each other nor race to create the page.
+	entryMu sync.Mutex
 }
 
 // NewPageService creates a new PageService with its dependencies.
-func NewPageService(repo PageRepository, categoryRepo CategoryRepository, cache *cache.Cache) *PageService {
-	sanitizer := bluemonday.UGCPolicy()
-	sanitizer.AllowImages()
-	markdown := goldmark.New(
-		goldmark.WithRendererOptions(
-			renderer.WithNodeRenderers(
-				util.Prioritized(NewLazyLoadRenderer(), 100),
-			),
-		),
-	)
-	return &PageService{
+func NewPageService(repo PageRepository, categoryRepo CategoryRepository, cache *cache.Cache, opts ...Option) *PageService {
+	s := &PageService{
 		repo:         repo,
 		categoryRepo: categoryRepo,
 		cache:        cache,
-		sanitizer:    sanitizer,
-		markdown:     markdown,
+		sanitizer:    newSanitizer(),
+		events:       events.NewBroker(maxPageSubscribers),
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
+	s.configureTrustedSanitizer()
+	s.configureRawHTMLSanitizer()
+	s.configureRenderLimit()
+
+	parserOptions := []parser.Option{
+		parser.WithAutoHeadingID(),
+		parser.WithInlineParsers(util.Prioritized(&wikiLinkParser{}, 199)),
+	}
+	if s.markdownConfig.AutoLinkTitles {
+		parserOptions = append(parserOptions, parser.WithASTTransformers(
+			util.Prioritized(&titleLinker{mode: s.markdownConfig.AutoLinkMode}, 500),
+		))
+	}
+	var extensions []goldmark.Extender
+	if s.markdownConfig.HighlightTheme != "" {
+		extensions = append(extensions, newHighlighting())
+	}
+	newMarkdown := func(rendererOptions ...renderer.Option) goldmark.Markdown {
+		rendererOptions = append(rendererOptions, renderer.WithNodeRenderers(
+			util.Prioritized(NewLazyLoadRenderer(), 100),
+			util.Prioritized(&wikiLinkRenderer{}, 100),
+		))
+		return goldmark.New(
+			goldmark.WithExtensions(extensions...),
+			goldmark.WithParserOptions(parserOptions...),
+			goldmark.WithRendererOptions(rendererOptions...),
+		)
+	}
+	s.markdown = newMarkdown()
+	if s.trustedSanitizer != nil {
+		// Raw HTML is only rendered for trusted pages, and still sanitized.
+		s.trustedMarkdown = newMarkdown(html.WithUnsafe())
+	}
+	return s
 }
 
-// CreatePage handles the business logic for creating a new wiki page.
+// CreatePage handles the business logic for creating a new wiki page. When
+// edits by the current user need review, nothing is saved: the new page is
+// queued as an edit and returned, without an ID, with ErrPendingReview.
 func (s *PageService) CreatePage(ctx context.Context, title, content, authorID, categoryName, subcategoryName string) (*data.Page, error) {
-	sanitizedContent := s.sanitizer.Sanitize(content)
+	title, categoryName, subcategoryName, err := s.splitTitlePath(ctx, 0, title, categoryName, subcategoryName)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkCreateQuota(ctx, authorID); err != nil {
+		return nil, err
+	}
+	if err := s.checkSiteCaps(ctx, content); err != nil {
+		return nil, err
+	}
+	if s.needsReview(ctx) {
+		return s.queueNewPage(ctx, title, content, authorID, categoryName, subcategoryName)
+	}
 	categoryID, err := s.getOrCreateCategories(ctx, categoryName, subcategoryName)
 	if err != nil {
 		return nil, err
 	}
+	// Content is stored as raw markdown; it is sanitized when rendered (see processMarkdown).
 	page := &data.Page{
 		Title:      title,
-		Content:    sanitizedContent,
+		Content:    content,
 		AuthorID:   authorID,
 		CategoryID: categoryID,
+		// Admins may embed the operators' trusted HTML; the page is rendered
+		// accordingly until someone else changes it (see updatePage).
+		TrustedHTML: isAdmin(ctx),
+	}
+	// The creator owns the page, unless they are anonymous and cannot be told apart.
+	if authorID != "anonymous" {
+		page.OwnerSubject = authorID
 	}
 	if err := s.repo.CreatePage(ctx, page); err != nil {
+		// Titles are unique, so a page in the trash keeps its title taken.
+		if s.inTrash(ctx, title) {
+			return nil, ErrPageInTrash
+		}
+		return nil, err
+	}
+	if err := s.retagOwnCategory(ctx, page.ID, nil, page.CategoryID); err != nil {
+		return nil, err
+	}
+	if err := s.recordRevision(ctx, page, authorID, false); err != nil {
+		return nil, err
+	}
+	s.invalidatePageList()
+	s.backupPage(page)
+	s.recordCreation(ctx, authorID)
+	s.recordUsage(page)
+	s.recordActivity(ctx, page, data.ActivityCreate, false)
+	return page, nil
+}
+
+// GetPage loads a page by its title without rendering it, for the checks made
+// before changing it. ErrPageNotFound is returned if no page has the title.
+func (s *PageService) GetPage(ctx context.Context, title string) (*data.Page, error) {
+	page, err := s.repo.GetPageByTitle(ctx, title)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %w", ErrPageNotFound, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := s.populateRequiredRole(ctx, page); err != nil {
 		return nil, err
 	}
-	s.cache.Delete("pages:all")
 	return page, nil
 }
 
@@ -155,7 +382,11 @@ func (s *PageService) ViewPage(ctx context.Context, title string) (*data.Page, e
 	if cachedBytes, _ := s.cache.Get(cacheKey); cachedBytes != nil {
 		var page data.Page
 		if json.Unmarshal(cachedBytes, &page) == nil {
-			s.processMarkdown(&page)
+			if err := s.processMarkdown(ctx, &page); err != nil {
+				return nil, err
+			}
+			page.IsStub = s.isStub(&page)
+			setReadingTime(&page)
 			return &page, nil
 		}
 	}
@@ -171,6 +402,8 @@ func (s *PageService) ViewPage(ctx context.Context, title string) (*data.Page, e
 				Title:   "Home",
 				Content: "Welcome! This page is empty.",
 			}
+		} else if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: %w", ErrPageNotFound, err)
 		} else {
 			return nil, fmt.Errorf("failed to get page from repo: %w", err)
 		}
@@ -178,38 +411,93 @@ func (s *PageService) ViewPage(ctx context.Context, title string) (*data.Page, e
 		if err := s.populateCategoryNames(page); err != nil {
 			// Log error but don't fail the request
 		}
+		if err := s.populateExtraCategories(ctx, page); err != nil {
+			return nil, err
+		}
+		if err := s.populateMetadata(ctx, page); err != nil {
+			return nil, err
+		}
+		if err := s.populateRequiredRole(ctx, page); err != nil {
+			return nil, err
+		}
 		if bytesToCache, err := json.Marshal(page); err == nil {
 			s.cache.Set(cacheKey, bytesToCache, 5*time.Minute)
 		}
 	}
-	s.processMarkdown(page)
+	if err := s.processMarkdown(ctx, page); err != nil {
+		return nil, err
+	}
+	page.IsStub = s.isStub(page)
+	setReadingTime(page)
 	return page, nil
 }
 
-// UpdatePage handles the logic for updating an existing page.
-func (s *PageService) UpdatePage(ctx context.Context, id int64, title, content, categoryName, subcategoryName string) (*data.Page, error) {
+// UpdatePage handles the logic for updating an existing page. Minor edits are
+// flagged in the history and do not notify the page's watchers by default.
+// When edits by the current user need review, the edit is queued instead and
+// ErrPendingReview is returned (see moderation.go).
+func (s *PageService) UpdatePage(ctx context.Context, id int64, title, content, categoryName, subcategoryName string, minor bool) (*data.Page, error) {
+	return s.updatePage(ctx, id, title, content, categoryName, subcategoryName, middleware.GetUserInfo(ctx).Subject, minor, nil)
+}
+
+// updatePage saves a new version of the page on behalf of authorID, or queues
+// it for review when edits by the current user need one. The page stays
+// trusted only while every change kept in it was made by an admin, so an edit
+// by anyone else, even a minor one, ends the trust for good. Restoring an
+// earlier revision keeps nothing else, so the page takes that revision's trust.
+func (s *PageService) updatePage(ctx context.Context, id int64, title, content, categoryName, subcategoryName, authorID string, minor bool, restored *data.Revision) (*data.Page, error) {
+	if s.needsReview(ctx) {
+		return nil, s.queueEdit(ctx, id, title, content, categoryName, subcategoryName, minor)
+	}
+	title, categoryName, subcategoryName, err := s.splitTitlePath(ctx, id, title, categoryName, subcategoryName)
+	if err != nil {
+		return nil, err
+	}
 	page, err := s.repo.GetPageByID(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 	s.cache.Delete("page:" + page.Title)
-	s.cache.Delete("pages:all")
-	sanitizedContent := s.sanitizer.Sanitize(content)
+	s.invalidatePageList()
 	categoryID, err := s.getOrCreateCategories(ctx, categoryName, subcategoryName)
 	if err != nil {
 		return nil, err
 	}
+	originalTitle, previousCategoryID := page.Title, page.CategoryID
 	page.Title = title
-	page.Content = sanitizedContent
+	page.Content = content
 	page.UpdatedAt = time.Now()
 	page.CategoryID = categoryID
-	if err := s.repo.UpdatePage(ctx, page); err != nil {
+	if restored != nil {
+		page.TrustedHTML = restored.TrustedHTML
+	} else {
+		page.TrustedHTML = page.TrustedHTML && isAdmin(ctx)
+	}
+	if err := s.savePage(ctx, page, authorID, minor); err != nil {
+		return nil, err
+	}
+	if err := s.retagOwnCategory(ctx, page.ID, previousCategoryID, page.CategoryID); err != nil {
 		return nil, err
 	}
 	s.cache.Delete("page:" + page.Title)
+	s.backupPage(page)
+	s.recordActivity(ctx, page, data.ActivityUpdate, minor)
+	s.events.Publish(originalTitle, events.Event{
+		Type:      "updated",
+		Title:     page.Title,
+		Author:    authorID,
+		Minor:     minor,
+		Timestamp: page.UpdatedAt,
+	})
 	return page, nil
 }
 
+// SubscribeToPage registers a listener for live updates to the page with the given title.
+// The returned function must be called to release the subscription.
+func (s *PageService) SubscribeToPage(ctx context.Context, title string) (<-chan events.Event, func(), error) {
+	return s.events.Subscribe(title)
+}
+
 // GetAllPages retrieves all pages.
 func (s *PageService) GetAllPages(ctx context.Context) ([]*data.Page, error) {
 	pages, err := s.repo.GetAllPages(ctx)
@@ -224,32 +512,198 @@ func (s *PageService) GetAllPages(ctx context.Context) ([]*data.Page, error) {
 	return pages, nil
 }
 
-// DeletePage handles the deletion of a page by its ID.
+// GetAllPagesPaged returns the given 1-based page of the page list, ordered by
+// title and without page content, along with the total number of pages.
+// perPage defaults to defaultPagesPerPage and is capped at maxPageListLimit.
+func (s *PageService) GetAllPagesPaged(ctx context.Context, page, perPage int) ([]*data.Page, int, error) {
+	if page < 1 {
+		page = 1
+	}
+	if perPage <= 0 {
+		perPage = defaultPagesPerPage
+	}
+	if perPage > maxPageListLimit {
+		perPage = maxPageListLimit
+	}
+	total, err := s.repo.CountPages(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	pages, err := s.repo.GetPagesPaged(ctx, perPage, (page-1)*perPage)
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, p := range pages {
+		s.populateCategoryNames(p)
+	}
+	return pages, total, nil
+}
+
+// PagesLastModified returns when a page was last updated and how many pages
+// there are. Together they identify a version of views built from every page,
+// such as the page list and the sitemap: the count changes when a page is
+// deleted or restored, which leaves the newest update alone. Changes that do
+// not update a page also count: the last change to the categories or to where
+// pages are filed, and the last time a page passed its expiry date.
+func (s *PageService) PagesLastModified(ctx context.Context) (time.Time, int, error) {
+	lastModified, err := s.repo.GetMaxUpdatedAt(ctx)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	archivedAt, err := s.repo.GetLatestArchivedAt(ctx, time.Now())
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	for _, t := range []time.Time{archivedAt, s.pageListChangedAt()} {
+		if t.After(lastModified) {
+			lastModified = t
+		}
+	}
+	count, err := s.repo.CountPages(ctx)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	return lastModified, count, nil
+}
+
+// pageListChangedCacheKey holds when the page list was last invalidated, which
+// also covers changes that do not update a page, e.g. a category was renamed
+// or a page was moved.
+const pageListChangedCacheKey = "list:changed"
+
+// pageListChangedTTL is how long the time of the last change is kept. A lost
+// entry is replaced by the current time, which only makes clients fetch the
+// views again.
+const pageListChangedTTL = 24 * time.Hour
+
+// pageListChangedAt returns when the page list was last invalidated.
+func (s *PageService) pageListChangedAt() time.Time {
+	var changedAt time.Time
+	if cached, _ := s.cache.Get(pageListChangedCacheKey); cached != nil && changedAt.UnmarshalText(cached) == nil {
+		return changedAt
+	}
+	return s.markPageListChanged()
+}
+
+// markPageListChanged records the current time as the last change to the page list.
+func (s *PageService) markPageListChanged() time.Time {
+	now := time.Now().UTC()
+	if text, err := now.MarshalText(); err == nil {
+		s.cache.Set(pageListChangedCacheKey, text, pageListChangedTTL)
+	}
+	return now
+}
+
+// ListPages returns a slice of the page list, without page content, for clients
+// walking through every page. The limit defaults to, and is capped at, maxPageListLimit.
+func (s *PageService) ListPages(ctx context.Context, filter data.PageListFilter) (*data.PageList, error) {
+	if filter.Limit <= 0 || filter.Limit > maxPageListLimit {
+		filter.Limit = maxPageListLimit
+	}
+	return s.repo.ListPages(ctx, filter)
+}
+
+// GetPagesByTitles fetches the pages with the given titles at once, keyed by
+// title. Titles without a page are missing from the map.
+func (s *PageService) GetPagesByTitles(ctx context.Context, titles []string) (map[string]*data.Page, error) {
+	pages, err := s.repo.GetPagesByTitles(ctx, titles)
+	if err != nil {
+		return nil, err
+	}
+	byTitle := make(map[string]*data.Page, len(pages))
+	for _, page := range pages {
+		s.populateCategoryNames(page)
+		page.IsStub = s.isStub(page)
+		byTitle[page.Title] = page
+	}
+	return byTitle, nil
+}
+
+// DeletePage moves the page with the given ID to the trash (see trash.go).
 func (s *PageService) DeletePage(ctx context.Context, id int64) error {
-	return s.repo.DeletePage(ctx, id)
+	page, err := s.repo.GetPageByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.DeletePage(ctx, id); err != nil {
+		return err
+	}
+	s.cache.Delete("page:" + page.Title)
+	s.invalidatePageList()
+	s.recordActivity(ctx, page, data.ActivityDelete, false)
+	return nil
+}
+
+// invalidatePageList drops cached data derived from the set of pages: the page
+// lists and titles, and the dashboard's category and author counts. It also
+// records the time, so that views validated by PagesLastModified change.
+func (s *PageService) invalidatePageList() {
+	s.cache.DeleteByPrefix("pages:")
+	s.cache.DeleteByPrefix("dashboard:")
+	s.markPageListChanged()
+}
+
+// GetRecentActivity retrieves the wiki-wide activity log, newest first.
+func (s *PageService) GetRecentActivity(ctx context.Context, filter data.ActivityFilter, limit, offset int) ([]*data.Activity, error) {
+	return s.repo.GetRecentActivity(ctx, filter, limit, offset)
+}
+
+// GetPagesEditedBy retrieves the pages the given user created or edited, most recent first.
+// Anonymous edits are not attributed to anyone, so the anonymous user gets no pages.
+func (s *PageService) GetPagesEditedBy(ctx context.Context, subject string, limit int) ([]*data.Page, error) {
+	if subject == "" || subject == "anonymous" {
+		return []*data.Page{}, nil
+	}
+	return s.repo.GetPagesEditedBy(ctx, subject, limit)
+}
+
+// recordActivity appends an entry to the activity log on behalf of the current user.
+// Failures are not fatal: the activity log is informational only.
+func (s *PageService) recordActivity(ctx context.Context, page *data.Page, action string, minor bool) {
+	userInfo := middleware.GetUserInfo(ctx)
+	activity := &data.Activity{
+		PageTitle: page.Title,
+		Action:    action,
+		AuthorID:  userInfo.Subject,
+		Minor:     minor,
+	}
+	if userInfo.Subject == "anonymous" {
+		activity.AuthorIP = userInfo.IP
+	}
+	if page.ID != 0 {
+		id := page.ID
+		activity.PageID = &id
+	}
+	_ = s.repo.RecordActivity(ctx, activity)
 }
 
-// GetCategoryTree fetches all categories and organizes them into a tree structure.
+// GetCategoryTree fetches all categories and organizes them into a tree, with
+// the top-level categories as its roots.
 func (s *PageService) GetCategoryTree(ctx context.Context) ([]*CategoryNode, error) {
 	categories, err := s.categoryRepo.GetAll()
 	if err != nil {
 		return nil, err
 	}
-	var nodes []*CategoryNode
-	parentMap := make(map[int64]*CategoryNode)
+	children := make(map[int64][]*data.Category)
+	var roots []*data.Category
 	for _, c := range categories {
 		if c.ParentID == nil {
-			node := &CategoryNode{Parent: c}
-			nodes = append(nodes, node)
-			parentMap[c.ID] = node
+			roots = append(roots, c)
+		} else {
+			children[*c.ParentID] = append(children[*c.ParentID], c)
 		}
 	}
-	for _, c := range categories {
-		if c.ParentID != nil {
-			if parentNode, ok := parentMap[*c.ParentID]; ok {
-				parentNode.Children = append(parentNode.Children, c)
-			}
+	var build func(c *data.Category, path string) *CategoryNode
+	build = func(c *data.Category, path string) *CategoryNode {
+		node := &CategoryNode{Category: c, Path: path}
+		for _, child := range children[c.ID] {
+			node.Children = append(node.Children, build(child, path+"/"+child.Name))
 		}
+		return node
+	}
+	var nodes []*CategoryNode
+	for _, c := range roots {
+		nodes = append(nodes, build(c, c.Name))
 	}
 	return nodes, nil
 }
@@ -259,14 +713,15 @@ func (s *PageService) SearchCategories(ctx context.Context, query string) ([]*da
 	return s.categoryRepo.SearchByName(query)
 }
 
-// GetPagesForCategory retrieves all pages for a given category name.
+// GetPagesForCategory retrieves all pages filed under a top-level category,
+// at any depth.
 func (s *PageService) GetPagesForCategory(ctx context.Context, categoryName string) ([]*data.Page, error) {
 	parent, err := s.categoryRepo.FindByName(categoryName, nil)
 	if err != nil {
 		return nil, err
 	}
 	if parent == nil {
-		return nil, fmt.Errorf("category '%s' not found", categoryName)
+		return nil, fmt.Errorf("%w: '%s'", ErrCategoryNotFound, categoryName)
 	}
 
 	allCategories, err := s.categoryRepo.GetAll()
@@ -274,110 +729,168 @@ func (s *PageService) GetPagesForCategory(ctx context.Context, categoryName stri
 		return nil, err
 	}
 
-	var subCategoryIDs []int64
-	for _, cat := range allCategories {
-		if cat.ParentID != nil && *cat.ParentID == parent.ID {
-			subCategoryIDs = append(subCategoryIDs, cat.ID)
-		}
-	}
-
+	// A page tagged with several of the categories is listed once.
 	var allPages []*data.Page
-	for _, id := range subCategoryIDs {
+	listed := make(map[int64]bool)
+	for _, id := range categoryDescendants(allCategories, parent.ID) {
 		pages, err := s.repo.GetPagesByCategoryID(ctx, id)
 		if err != nil {
 			return nil, err
 		}
-		allPages = append(allPages, pages...)
+		for _, page := range pages {
+			if !listed[page.ID] {
+				listed[page.ID] = true
+				allPages = append(allPages, page)
+			}
+		}
 	}
 
 	return allPages, nil
 }
 
-// GetPagesForSubcategory retrieves all pages for a given subcategory name.
+// GetPagesForSubcategory retrieves the pages filed directly in a subcategory.
+// The subcategory may be a slash-delimited path, e.g. "Physics/Quantum".
 func (s *PageService) GetPagesForSubcategory(ctx context.Context, categoryName string, subcategoryName string) ([]*data.Page, error) {
 	parent, err := s.categoryRepo.FindByName(categoryName, nil)
 	if err != nil {
 		return nil, err
 	}
 	if parent == nil {
-		return nil, fmt.Errorf("category '%s' not found", categoryName)
+		return nil, fmt.Errorf("%w: '%s'", ErrCategoryNotFound, categoryName)
 	}
 
-	subCategory, err := s.categoryRepo.FindByName(subcategoryName, &parent.ID)
+	subCategory, err := s.findCategoryPath(append([]string{categoryName}, splitCategoryPath(subcategoryName)...))
 	if err != nil {
 		return nil, err
 	}
-	if subCategory == nil {
-		return nil, fmt.Errorf("subcategory '%s' not found in category '%s'", subcategoryName, categoryName)
+	if subCategory == nil || subCategory.ID == parent.ID {
+		return nil, fmt.Errorf("%w: subcategory '%s' in category '%s'", ErrCategoryNotFound, subcategoryName, categoryName)
 	}
 
 	return s.repo.GetPagesByCategoryID(ctx, subCategory.ID)
 }
 
-func (s *PageService) processMarkdown(page *data.Page) {
+// processMarkdown renders the page's markdown to HTML and sanitizes the result.
+// This is the only place content is sanitized: pages are stored as the raw
+// markdown the author wrote, because sanitizing markdown source mangles
+// legitimate text such as "a < b" or <https://autolinks>, while the rendered
+// HTML is what actually reaches the browser. Changes to the sanitizer policy
+// therefore apply to every page on its next render. Pages last saved by an
+// admin keep their raw HTML and are sanitized with the trusted policy (see renderingFor),
+// and their ```{=html} blocks with the raw HTML policy (see extractRawHTMLBlocks).
+// Renders wait for a free slot when their number is limited, returning
+// ErrRenderBusy if none frees up in time.
+func (s *PageService) processMarkdown(ctx context.Context, page *data.Page) error {
+	release, err := s.acquireRender(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	source := []byte(page.Content)
+	pc := parser.NewContext()
+	var titles map[string]bool
+	if s.markdownConfig.AutoLinkTitles || bytes.Contains(source, []byte("[[")) {
+		titles = s.pageTitles(ctx)
+	}
+	pc.Set(wikiLinkTitlesKey, titles)
+	if s.markdownConfig.AutoLinkTitles {
+		pc.Set(autoLinkTitlesKey, titles)
+		pc.Set(autoLinkCurrentKey, page.Title)
+	}
+	markdown, sanitizer := s.renderingFor(page)
+	doc := markdown.Parser().Parse(text.NewReader(source), parser.WithContext(pc))
+	rawBlocks := s.extractRawHTMLBlocks(page, doc, source)
 	var buf bytes.Buffer
-	if err := s.markdown.Convert([]byte(page.Content), &buf); err == nil {
-		sanitizedHTML := s.sanitizer.SanitizeBytes(buf.Bytes())
+	if err := markdown.Renderer().Render(&buf, source, doc); err == nil {
+		sanitizedHTML := rawBlocks.restore(sanitizer.SanitizeBytes(buf.Bytes()))
 		page.HTMLContent = template.HTML(sanitizedHTML)
+		page.TableOfContents = buildTableOfContents(doc, source)
+		if s.markdownConfig.SeeAlso {
+			page.SeeAlso = s.seeAlso(ctx, page.Content, page.Title, doc)
+		}
 	}
+	return nil
 }
 
+// getOrCreateCategories returns the ID of the category a page is filed under,
+// creating it and any missing ancestors. The subcategory may be a
+// slash-delimited path, e.g. "Physics/Quantum", and so may the category.
 func (s *PageService) getOrCreateCategories(ctx context.Context, categoryName, subcategoryName string) (*int64, error) {
-	if categoryName == "" {
-		categoryName = "NoCategory"
-	}
-	if subcategoryName == "" {
-		subcategoryName = "NoSubCategory"
-	}
-	mainCategory, err := s.categoryRepo.FindByName(categoryName, nil)
-	if err != nil {
-		return nil, err
-	}
-	if mainCategory == nil {
-		newCat := &data.Category{Name: categoryName}
-		id, err := s.categoryRepo.Save(newCat)
+	return s.getOrCreateCategoryPath(ctx, categoryPath(categoryName, subcategoryName))
+}
+
+// getOrCreateCategoryPath returns the ID of the category at the end of the
+// path of names, top-level category first, creating it and any missing
+// ancestors. The names must not be empty.
+func (s *PageService) getOrCreateCategoryPath(ctx context.Context, names []string) (*int64, error) {
+	var category *data.Category
+	for _, name := range names {
+		var parentID *int64
+		if category != nil {
+			parentID = &category.ID
+		}
+		existing, err := s.categoryRepo.FindByName(name, parentID)
 		if err != nil {
 			return nil, err
 		}
-		mainCategory = &data.Category{ID: id, Name: categoryName}
-	}
-	subCategory, err := s.categoryRepo.FindByName(subcategoryName, &mainCategory.ID)
-	if err != nil {
-		return nil, err
+		if existing == nil {
+			id, err := s.categoryRepo.Save(&data.Category{Name: name, ParentID: parentID})
+			if err != nil {
+				return nil, err
+			}
+			existing = &data.Category{ID: id, Name: name, ParentID: parentID}
+		}
+		category = existing
 	}
-	if subCategory == nil {
-		newSubCat := &data.Category{Name: subcategoryName, ParentID: &mainCategory.ID}
-		id, err := s.categoryRepo.Save(newSubCat)
+	return &category.ID, nil
+}
+
+// categoryAncestry returns the names of the category and its ancestors,
+// top-level category first.
+func (s *PageService) categoryAncestry(id int64) ([]string, error) {
+	var names []string
+	for next, depth := &id, 0; next != nil; depth++ {
+		category, err := s.categoryRepo.GetByID(*next)
+		if err == nil && (category == nil || depth == maxCategoryDepth) {
+			err = fmt.Errorf("%w: id %d", ErrCategoryNotFound, *next)
+		}
 		if err != nil {
-			return nil, err
+			return names, err
 		}
-		subCategory = &data.Category{ID: id, Name: subcategoryName, ParentID: &mainCategory.ID}
+		names = append([]string{category.Name}, names...)
+		next = category.ParentID
 	}
-	return &subCategory.ID, nil
+	return names, nil
 }
 
+// populateCategoryNames walks up from the page's category to its top-level
+// category, filling in the page's breadcrumb. The top-level category becomes
+// the page's category and the rest its slash-delimited subcategory.
 func (s *PageService) populateCategoryNames(page *data.Page) error {
+	page.Breadcrumb = nil
 	if page.CategoryID == nil {
 		page.CategoryName = "NoCategory"
 		page.SubcategoryName = "NoSubCategory"
 		return nil
 	}
-	subCategory, err := s.categoryRepo.GetByID(*page.CategoryID)
+	names, err := s.categoryAncestry(*page.CategoryID)
 	if err != nil {
 		page.CategoryName = "Unknown"
 		page.SubcategoryName = "Unknown"
+		if len(names) > 0 {
+			page.SubcategoryName = strings.Join(names, "/")
+		}
 		return err
 	}
-	page.SubcategoryName = subCategory.Name
-	if subCategory.ParentID != nil {
-		parentCategory, err := s.categoryRepo.GetByID(*subCategory.ParentID)
-		if err != nil {
-			page.CategoryName = "Unknown"
-			return err
-		}
-		page.CategoryName = parentCategory.Name
-	} else {
+	for i, name := range names {
+		page.Breadcrumb = append(page.Breadcrumb, data.CategoryCrumb{Name: name, Path: strings.Join(names[:i+1], "/")})
+	}
+	if len(names) == 1 {
 		page.CategoryName = "Uncategorized"
+		page.SubcategoryName = names[0]
+		return nil
 	}
+	page.CategoryName = names[0]
+	page.SubcategoryName = strings.Join(names[1:], "/")
 	return nil
 }
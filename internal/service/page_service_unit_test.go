@@ -4,11 +4,23 @@ package service
 
 import (
 	"context"
+	"database/sql"
 	"errors"
+	"fmt"
 	"go-wiki-app/internal/cache"
 	"go-wiki-app/internal/config"
 	"go-wiki-app/internal/data"
+	"go-wiki-app/internal/diff"
+	"go-wiki-app/internal/middleware"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 // newTestCache creates a new in-memory cache for testing.
@@ -39,6 +51,12 @@ type mockPageRepository struct {
 	updatePageCalled bool
 	deletePageCalled bool
 	lastPagePassed *data.Page
+	recordedActivity []*data.Activity
+	metadata map[int64]map[string]string
+	contentStatsCalls int
+	deadLinks []*data.DeadLink
+	deletedPages []*data.Page
+	pagesByCategory map[int64][]*data.Page
 }
 
 var _ PageRepository = (*mockPageRepository)(nil)
@@ -61,7 +79,7 @@ func (m *mockPageRepository) GetPageByTitle(ctx context.Context, title string) (
 	if m.pageToReturn != nil && m.pageToReturn.Title == title {
 		return m.pageToReturn, nil
 	}
-	return nil, errors.New("page not found")
+	return nil, fmt.Errorf("page %q: %w", title, sql.ErrNoRows)
 }
 
 func (m *mockPageRepository) GetPageByID(ctx context.Context, id int64) (*data.Page, error) {
@@ -72,9 +90,40 @@ func (m *mockPageRepository) GetPageByID(ctx context.Context, id int64) (*data.P
 	if m.pageToReturn != nil && m.pageToReturn.ID == id {
 		return m.pageToReturn, nil
 	}
+	for _, page := range append(m.pagesToReturn, m.deletedPages...) {
+		if page.ID == id {
+			return page, nil
+		}
+	}
 	return nil, errors.New("page not found")
 }
 
+func (m *mockPageRepository) ListPages(ctx context.Context, filter data.PageListFilter) (*data.PageList, error) {
+	return &data.PageList{Pages: m.pagesToReturn, Total: len(m.pagesToReturn)}, m.errToReturn
+}
+
+func (m *mockPageRepository) GetPagesPaged(ctx context.Context, limit, offset int) ([]*data.Page, error) {
+	return m.pagesToReturn, m.errToReturn
+}
+
+func (m *mockPageRepository) GetMaxUpdatedAt(ctx context.Context) (time.Time, error) {
+	var newest time.Time
+	for _, page := range m.pagesToReturn {
+		if page.UpdatedAt.After(newest) {
+			newest = page.UpdatedAt
+		}
+	}
+	return newest, m.errToReturn
+}
+
+func (m *mockPageRepository) CountPages(ctx context.Context) (int, error) {
+	return len(m.pagesToReturn), m.errToReturn
+}
+
+func (m *mockPageRepository) GetPagesByTitles(ctx context.Context, titles []string) ([]*data.Page, error) {
+	return m.pagesToReturn, m.errToReturn
+}
+
 func (m *mockPageRepository) GetAllPages(ctx context.Context) ([]*data.Page, error) {
 	m.getAllPagesCalled = true
 	if m.errToReturn != nil {
@@ -94,9 +143,135 @@ func (m *mockPageRepository) DeletePage(ctx context.Context, id int64) error {
 	return m.errToReturn
 }
 
+func (m *mockPageRepository) GetDeletedPages(ctx context.Context) ([]*data.Page, error) {
+	return m.deletedPages, nil
+}
+
+func (m *mockPageRepository) RestorePage(ctx context.Context, id int64) error {
+	return m.removeDeleted(id)
+}
+
+func (m *mockPageRepository) PurgePage(ctx context.Context, id int64) error {
+	return m.removeDeleted(id)
+}
+
+func (m *mockPageRepository) removeDeleted(id int64) error {
+	for i, page := range m.deletedPages {
+		if page.ID == id {
+			m.deletedPages = append(m.deletedPages[:i], m.deletedPages[i+1:]...)
+			return nil
+		}
+	}
+	return errors.New("page not in trash")
+}
+
+func (m *mockPageRepository) RecordActivity(ctx context.Context, activity *data.Activity) error {
+	m.recordedActivity = append(m.recordedActivity, activity)
+	return nil
+}
+
+func (m *mockPageRepository) GetPagesEditedBy(ctx context.Context, subject string, limit int) ([]*data.Page, error) {
+	return m.pagesToReturn, m.errToReturn
+}
+
+func (m *mockPageRepository) SetPageExpiry(ctx context.Context, pageID int64, expiresAt *time.Time) error {
+	return m.errToReturn
+}
+
+func (m *mockPageRepository) GetLatestArchivedAt(ctx context.Context, now time.Time) (time.Time, error) {
+	var latest time.Time
+	for _, page := range m.pagesToReturn {
+		if page.IsArchived(now) && page.ExpiresAt.After(latest) {
+			latest = *page.ExpiresAt
+		}
+	}
+	return latest, m.errToReturn
+}
+
+func (m *mockPageRepository) GetArchivedPages(ctx context.Context, now time.Time) ([]*data.Page, error) {
+	return m.pagesToReturn, m.errToReturn
+}
+
+func (m *mockPageRepository) ReplaceDeadLinks(ctx context.Context, links []*data.DeadLink) error {
+	m.deadLinks = links
+	return m.errToReturn
+}
+
+func (m *mockPageRepository) GetDeadLinks(ctx context.Context) ([]*data.DeadLink, error) {
+	return m.deadLinks, m.errToReturn
+}
+
+func (m *mockPageRepository) SetPageOwner(ctx context.Context, pageID int64, subject string) error {
+	return m.errToReturn
+}
+
+func (m *mockPageRepository) SetPageCategory(ctx context.Context, pageID int64, categoryID *int64) error {
+	if m.errToReturn != nil {
+		return m.errToReturn
+	}
+	if m.pageToReturn != nil && m.pageToReturn.ID == pageID {
+		m.pageToReturn.CategoryID = categoryID
+	}
+	return nil
+}
+
+func (m *mockPageRepository) GetRecentActivity(ctx context.Context, filter data.ActivityFilter, limit, offset int) ([]*data.Activity, error) {
+	return m.recordedActivity, nil
+}
+
+func (m *mockPageRepository) GetPageMetadata(ctx context.Context, pageID int64) (map[string]string, error) {
+	metadata := map[string]string{}
+	for key, value := range m.metadata[pageID] {
+		metadata[key] = value
+	}
+	return metadata, nil
+}
+
+func (m *mockPageRepository) SetPageMetadata(ctx context.Context, pageID int64, key, value string) error {
+	if m.metadata == nil {
+		m.metadata = map[int64]map[string]string{}
+	}
+	if m.metadata[pageID] == nil {
+		m.metadata[pageID] = map[string]string{}
+	}
+	m.metadata[pageID][key] = value
+	return nil
+}
+
+func (m *mockPageRepository) DeletePageMetadata(ctx context.Context, pageID int64, key string) error {
+	delete(m.metadata[pageID], key)
+	return nil
+}
+
+func (m *mockPageRepository) GetContentStats(ctx context.Context) (*data.ContentStats, error) {
+	m.contentStatsCalls++
+	stats := &data.ContentStats{PageCount: int64(len(m.pagesToReturn))}
+	for _, page := range m.pagesToReturn {
+		stats.ContentBytes += int64(len(page.Content))
+	}
+	return stats, nil
+}
+
+func (m *mockPageRepository) SearchPages(ctx context.Context, filter data.SearchFilter) ([]*data.Page, error) {
+	return m.pagesToReturn, m.errToReturn
+}
+
+func (m *mockPageRepository) CountAuthors(ctx context.Context) (int, error) {
+	authors := map[string]bool{}
+	for _, page := range m.pagesToReturn {
+		authors[page.AuthorID] = true
+	}
+	return len(authors), m.errToReturn
+}
+
+func (m *mockPageRepository) Ping(ctx context.Context) error {
+	return m.errToReturn
+}
+
 func (m *mockPageRepository) GetPagesByCategoryID(ctx context.Context, categoryID int64) ([]*data.Page, error) {
-	// For now, return an empty slice and no error.
-	// This can be expanded if tests need more specific behavior.
+	if pages, ok := m.pagesByCategory[categoryID]; ok {
+		return pages, nil
+	}
 	return []*data.Page{}, nil
 }
 
@@ -107,6 +282,11 @@ type mockCategoryRepository struct {
 	getByIDFunc    func(id int64) (*data.Category, error)
 	getAllFunc     func() ([]*data.Category, error)
 	searchByNameFunc func(query string) ([]*data.Category, error)
+	mergeFunc      func(ctx context.Context, sourceID, targetID int64) error
+	renameFunc     func(ctx context.Context, id int64, newName string) error
+	deleteFunc     func(ctx context.Context, id, targetID int64, retag bool) error
+	weights        map[int64]int
+	pageCategories map[int64][]int64 // page ID to tagged category IDs
 
 	findByNameCalled   int
 	saveCalled         int
@@ -159,6 +339,52 @@ func (m *mockCategoryRepository) SearchByName(query string) ([]*data.Category, e
     return nil, nil
 }
 
+func (m *mockCategoryRepository) SetPageCategories(ctx context.Context, pageID int64, categoryIDs []int64) error {
+	// Tags are only kept by tests that opt in with a pageCategories map.
+	if m.pageCategories != nil {
+		m.pageCategories[pageID] = categoryIDs
+	}
+	return nil
+}
+
+func (m *mockCategoryRepository) GetCategoriesForPage(ctx context.Context, pageID int64) ([]*data.Category, error) {
+	categories := []*data.Category{}
+	for _, id := range m.pageCategories[pageID] {
+		c, _ := m.GetByID(id)
+		if c == nil {
+			c = &data.Category{ID: id}
+		}
+		categories = append(categories, c)
+	}
+	return categories, nil
+}
+
+func (m *mockCategoryRepository) Rename(ctx context.Context, id int64, newName string) error {
+	if m.renameFunc != nil {
+		return m.renameFunc(ctx, id, newName)
+	}
+	return nil
+}
+
+func (m *mockCategoryRepository) Delete(ctx context.Context, id, targetID int64, retag bool) error {
+	if m.deleteFunc != nil {
+		return m.deleteFunc(ctx, id, targetID, retag)
+	}
+	return nil
+}
+
+func (m *mockCategoryRepository) UpdateOrder(ctx context.Context, weights map[int64]int) error {
+	m.weights = weights
+	return nil
+}
+
+func (m *mockCategoryRepository) Merge(ctx context.Context, sourceID, targetID int64) error {
+	if m.mergeFunc != nil {
+		return m.mergeFunc(ctx, sourceID, targetID)
+	}
+	return nil
+}
+
 func TestPageService_CreatePage_WithCategories(t *testing.T) {
 	t.Run("success with new categories", func(t *testing.T) {
 		mockPageRepo := &mockPageRepository{}
@@ -190,6 +416,58 @@ func TestPageService_CreatePage_WithCategories(t *testing.T) {
 	})
 }
 
+func TestPageService_CreatePage_ReturnsID(t *testing.T) {
+	testCache, teardown := newTestCache(t)
+	defer teardown()
+
+	pageService := NewPageService(&mockPageRepository{}, &mockCategoryRepository{}, testCache)
+	page, err := pageService.CreatePage(context.Background(), "title", "content", "author", "", "")
+	if err != nil {
+		t.Fatalf("CreatePage failed: %v", err)
+	}
+	if page.ID == 0 {
+		t.Error("expected the created page to carry the ID assigned by the repository")
+	}
+}
+
+func TestPageService_Trash(t *testing.T) {
+	testCache, teardown := newTestCache(t)
+	defer teardown()
+	ctx := context.Background()
+
+	trashed := &data.Page{ID: 5, Title: "Old Notes"}
+	mockPageRepo := &mockPageRepository{deletedPages: []*data.Page{trashed}}
+	pageService := NewPageService(mockPageRepo, &mockCategoryRepository{}, testCache)
+
+	// The title is still taken while the page is in the trash.
+	mockPageRepo.errToReturn = errors.New("UNIQUE constraint failed: pages.title")
+	if _, err := pageService.CreatePage(ctx, "Old Notes", "New", "alice", "", ""); !errors.Is(err, ErrPageInTrash) {
+		t.Fatalf("expected ErrPageInTrash, got %v", err)
+	}
+	mockPageRepo.errToReturn = nil
+
+	testCache.Set("page:Old Notes", []byte("{}"), time.Minute)
+	testCache.Set("pages:all", []byte("[]"), time.Minute)
+	if err := pageService.RestorePage(ctx, 5); err != nil {
+		t.Fatalf("RestorePage failed: %v", err)
+	}
+	if len(mockPageRepo.deletedPages) != 0 {
+		t.Error("expected the page to be taken out of the trash")
+	}
+	for _, key := range []string{"page:Old Notes", "pages:all"} {
+		if cached, _ := testCache.Get(key); cached != nil {
+			t.Errorf("expected %q to be invalidated on restore", key)
+		}
+	}
+	if n := len(mockPageRepo.recordedActivity); n != 1 || mockPageRepo.recordedActivity[0].Action != data.ActivityRestore {
+		t.Errorf("expected a restore to be recorded, got %v", mockPageRepo.recordedActivity)
+	}
+
+	if err := pageService.PurgePage(ctx, 5); err == nil {
+		t.Error("expected purging a page that is not in the trash to fail")
+	}
+}
+
 func TestPageService_GetCategoryTree(t *testing.T) {
 	t.Run("success", func(t *testing.T) {
 		mockPageRepo := &mockPageRepository{}
@@ -217,11 +495,11 @@ func TestPageService_GetCategoryTree(t *testing.T) {
 			t.Errorf("expected 2 root nodes, got %d", len(tree))
 		}
 		for _, node := range tree {
-			if node.Parent.Name == "Science" {
+			if node.Category.Name == "Science" {
 				if len(node.Children) != 1 {
 					t.Errorf("expected 1 child for Science, got %d", len(node.Children))
 				}
-			} else if node.Parent.Name == "Arts" {
+			} else if node.Category.Name == "Arts" {
 				if len(node.Children) != 0 {
 					t.Errorf("expected 0 children for Arts, got %d", len(node.Children))
 				}
@@ -266,3 +544,2653 @@ func TestPageService_ViewPage_PopulatesCategories(t *testing.T) {
 		}
 	})
 }
+
+func TestPageService_UpdatePage_PublishesEvent(t *testing.T) {
+	testCache, teardown := newTestCache(t)
+	defer teardown()
+
+	mockPageRepo := &mockPageRepository{
+		pageToReturn: &data.Page{ID: 1, Title: "Live Page", Content: "old"},
+	}
+	pageService := NewPageService(mockPageRepo, &mockCategoryRepository{}, testCache)
+	ctx := context.Background()
+
+	updates, unsubscribe, err := pageService.SubscribeToPage(ctx, "Live Page")
+	if err != nil {
+		t.Fatalf("SubscribeToPage failed: %v", err)
+	}
+	defer unsubscribe()
+
+	if _, err := pageService.UpdatePage(ctx, 1, "Live Page", "new", "", "", false); err != nil {
+		t.Fatalf("UpdatePage failed: %v", err)
+	}
+
+	select {
+	case ev := <-updates:
+		if ev.Type != "updated" {
+			t.Errorf("expected event type 'updated', got '%s'", ev.Type)
+		}
+		if ev.Title != "Live Page" {
+			t.Errorf("expected event title 'Live Page', got '%s'", ev.Title)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected an update event, but none was published")
+	}
+}
+
+func TestPageService_CreatePage_Quota(t *testing.T) {
+	testCache, teardown := newTestCache(t)
+	defer teardown()
+
+	pageService := NewPageService(&mockPageRepository{}, &mockCategoryRepository{}, testCache,
+		WithContentConfig(config.ContentConfig{CreateQuota: 2, CreateQuotaWindowMinutes: 60}),
+	)
+	ctx := middleware.SetUserInfo(context.Background(), &middleware.UserInfo{Subject: "spammer", Roles: []string{"editor"}})
+
+	for i := 0; i < 2; i++ {
+		if _, err := pageService.CreatePage(ctx, fmt.Sprintf("Page %d", i), "content", "spammer", "", ""); err != nil {
+			t.Fatalf("creation %d should be within quota, got %v", i+1, err)
+		}
+	}
+	if _, err := pageService.CreatePage(ctx, "Page 3", "content", "spammer", "", ""); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("expected ErrQuotaExceeded for the third creation, got %v", err)
+	}
+
+	// Other users have their own quota.
+	otherCtx := middleware.SetUserInfo(context.Background(), &middleware.UserInfo{Subject: "someone-else", Roles: []string{"editor"}})
+	if _, err := pageService.CreatePage(otherCtx, "Other Page", "content", "someone-else", "", ""); err != nil {
+		t.Errorf("expected another user to be unaffected, got %v", err)
+	}
+
+	// Admins are exempt.
+	adminCtx := middleware.SetUserInfo(context.Background(), &middleware.UserInfo{Subject: "root", Roles: []string{"admin"}})
+	for i := 0; i < 3; i++ {
+		if _, err := pageService.CreatePage(adminCtx, fmt.Sprintf("Admin Page %d", i), "content", "root", "", ""); err != nil {
+			t.Fatalf("expected admin to be exempt from the quota, got %v", err)
+		}
+	}
+}
+
+func TestPageService_FindSimilarContent(t *testing.T) {
+	testCache, teardown := newTestCache(t)
+	defer teardown()
+
+	mockPageRepo := &mockPageRepository{
+		pagesToReturn: []*data.Page{
+			{ID: 1, Title: "Deploying", Content: "To deploy the service, build the container image, push it to the registry and restart the pods in the cluster."},
+			{ID: 2, Title: "Cooking", Content: "Boil the pasta in salted water for ten minutes and serve it with fresh tomato sauce."},
+		},
+	}
+	pageService := NewPageService(mockPageRepo, &mockCategoryRepository{}, testCache)
+
+	similar, err := pageService.FindSimilarContent(context.Background(),
+		"To deploy the service: build the container image, push it to the registry, and restart the pods in the cluster!")
+	if err != nil {
+		t.Fatalf("FindSimilarContent failed: %v", err)
+	}
+	if len(similar) != 1 || similar[0].Title != "Deploying" {
+		t.Fatalf("expected only 'Deploying' to be similar, got %v", similar)
+	}
+	if mockPageRepo.getAllPagesCalled {
+		t.Error("expected candidates to be found by searching, not by loading every page")
+	}
+	if terms := similarityTerms("the container image, the registry and the cluster"); fmt.Sprint(terms) != "[container registry cluster]" {
+		t.Errorf("expected the longest words to be searched for, got %v", terms)
+	}
+
+	unrelated, err := pageService.FindSimilarContent(context.Background(), "A completely different text about astronomy and distant galaxies.")
+	if err != nil {
+		t.Fatalf("FindSimilarContent failed: %v", err)
+	}
+	if len(unrelated) != 0 {
+		t.Errorf("expected no similar pages for unrelated content, got %d", len(unrelated))
+	}
+}
+
+func TestPageService_ViewPage_HeadingsAndTableOfContents(t *testing.T) {
+	testCache, teardown := newTestCache(t)
+	defer teardown()
+
+	content := "# Guide\n\n## Install\n\n### Linux\n\n### macOS\n\n## Usage\n"
+	mockPageRepo := &mockPageRepository{
+		pageToReturn: &data.Page{ID: 1, Title: "Guide", Content: content},
+	}
+	pageService := NewPageService(mockPageRepo, &mockCategoryRepository{}, testCache)
+
+	page, err := pageService.ViewPage(context.Background(), "Guide")
+	if err != nil {
+		t.Fatalf("ViewPage failed: %v", err)
+	}
+
+	html := string(page.HTMLContent)
+	for _, want := range []string{`<h2 id="install">`, `<h3 id="linux">`, `<h2 id="usage">`} {
+		if !strings.Contains(html, want) {
+			t.Errorf("expected rendered HTML to contain %s, got %s", want, html)
+		}
+	}
+
+	toc := page.TableOfContents
+	if len(toc) != 2 {
+		t.Fatalf("expected 2 top-level TOC entries, got %d", len(toc))
+	}
+	if toc[0].ID != "install" || toc[0].Text != "Install" {
+		t.Errorf("unexpected first TOC entry: %+v", toc[0])
+	}
+	if len(toc[0].Children) != 2 || toc[0].Children[1].ID != "macos" {
+		t.Errorf("expected Install to contain Linux and macOS, got %+v", toc[0].Children)
+	}
+	if toc[1].ID != "usage" || len(toc[1].Children) != 0 {
+		t.Errorf("unexpected second TOC entry: %+v", toc[1])
+	}
+}
+
+func TestPageService_ViewPage_TableOfContentsDuplicateHeadings(t *testing.T) {
+	testCache, teardown := newTestCache(t)
+	defer teardown()
+
+	content := "# Title\n\n## Intro\n\n#### Deep\n\n##### Too deep\n\n## Intro\n\n### Intro\n"
+	mockPageRepo := &mockPageRepository{
+		pageToReturn: &data.Page{ID: 1, Title: "Reference", Content: content},
+	}
+	pageService := NewPageService(mockPageRepo, &mockCategoryRepository{}, testCache)
+
+	page, err := pageService.ViewPage(context.Background(), "Reference")
+	if err != nil {
+		t.Fatalf("ViewPage failed: %v", err)
+	}
+
+	html := string(page.HTMLContent)
+	for _, want := range []string{`<h2 id="intro">`, `<h2 id="intro-1">`, `<h3 id="intro-2">`, `<h4 id="deep">`} {
+		if !strings.Contains(html, want) {
+			t.Errorf("expected the sanitized HTML to keep the anchor %s, got %s", want, html)
+		}
+	}
+
+	toc := page.TableOfContents
+	if len(toc) != 2 || toc[0].ID != "intro" || toc[1].ID != "intro-1" {
+		t.Fatalf("expected two top-level entries with unique ids, got %+v", toc)
+	}
+	// Level 4 nests directly under level 2, and levels 1 and 5 are left out.
+	if len(toc[0].Children) != 1 || toc[0].Children[0].ID != "deep" || len(toc[0].Children[0].Children) != 0 {
+		t.Errorf("expected Deep as the only child of the first Intro, got %+v", toc[0].Children)
+	}
+	if len(toc[1].Children) != 1 || toc[1].Children[0].ID != "intro-2" || toc[1].Children[0].Level != 3 {
+		t.Errorf("expected the nested Intro under the second Intro, got %+v", toc[1].Children)
+	}
+}
+
+func TestPageService_SyntaxHighlighting(t *testing.T) {
+	testCache, teardown := newTestCache(t)
+	defer teardown()
+
+	content := "```go\npackage main\n\nfunc main() {}\n```\n"
+	mockPageRepo := &mockPageRepository{
+		pageToReturn: &data.Page{ID: 1, Title: "Snippet", Content: content},
+	}
+	pageService := NewPageService(mockPageRepo, &mockCategoryRepository{}, testCache,
+		WithMarkdownConfig(config.MarkdownConfig{HighlightTheme: "github"}))
+
+	page, err := pageService.ViewPage(context.Background(), "Snippet")
+	if err != nil {
+		t.Fatalf("ViewPage failed: %v", err)
+	}
+	html := string(page.HTMLContent)
+	for _, want := range []string{`<pre class="chroma">`, `<span class="kn">package</span>`, `<span class="nx">main</span>`} {
+		if !strings.Contains(html, want) {
+			t.Errorf("expected highlighted HTML to contain %s, got %s", want, html)
+		}
+	}
+	if strings.Contains(html, "style=") {
+		t.Errorf("expected classes instead of inline styles, got %s", html)
+	}
+
+	css, err := HighlightCSS("github")
+	if err != nil {
+		t.Fatalf("HighlightCSS failed: %v", err)
+	}
+	if !strings.Contains(string(css), ".chroma .kn") {
+		t.Errorf("expected the stylesheet to style keyword tokens, got %s", css)
+	}
+}
+
+func TestPageService_TrustedHTMLForAdminAuthors(t *testing.T) {
+	testCache, teardown := newTestCache(t)
+	defer teardown()
+
+	content := "Status:\n\n<iframe src=\"https://status.example.com/widget\" width=\"300\" onload=\"alert(1)\"></iframe>\n\n<script>alert(1)</script>\n"
+	mockPageRepo := &mockPageRepository{}
+	pageService := NewPageService(mockPageRepo, &mockCategoryRepository{}, testCache,
+		WithMarkdownConfig(config.MarkdownConfig{
+			TrustedHTMLElements:   []string{"iframe", "script"},
+			TrustedHTMLAttributes: []string{"src", "width", "onload"},
+		}))
+
+	tests := []struct {
+		role        string
+		wantTrusted bool
+	}{
+		{"admin", true},
+		{"editor", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.role, func(t *testing.T) {
+			ctx := middleware.SetUserInfo(context.Background(), &middleware.UserInfo{Subject: tt.role + "-user", Roles: []string{tt.role}})
+			title := "Status by " + tt.role
+			if _, err := pageService.CreatePage(ctx, title, content, tt.role+"-user", "", ""); err != nil {
+				t.Fatalf("CreatePage failed: %v", err)
+			}
+			if got := mockPageRepo.lastPagePassed.TrustedHTML; got != tt.wantTrusted {
+				t.Fatalf("expected TrustedHTML to be recorded as %v, got %v", tt.wantTrusted, got)
+			}
+
+			// Readers of any role see the page rendered with its author's policy.
+			saved := *mockPageRepo.lastPagePassed
+			saved.CategoryID = nil
+			mockPageRepo.pageToReturn = &saved
+			page, err := pageService.ViewPage(context.Background(), title)
+			if err != nil {
+				t.Fatalf("ViewPage failed: %v", err)
+			}
+			html := string(page.HTMLContent)
+			iframe := `<iframe src="https://status.example.com/widget" width="300"></iframe>`
+			if got := strings.Contains(html, iframe); got != tt.wantTrusted {
+				t.Errorf("expected the iframe to be kept: %v, got %s", tt.wantTrusted, html)
+			}
+			if strings.Contains(html, "<script") || strings.Contains(html, "onload") {
+				t.Errorf("expected script to be stripped whatever the allow-list, got %s", html)
+			}
+		})
+	}
+}
+
+func TestPageService_RawHTMLBlocks(t *testing.T) {
+	testCache, teardown := newTestCache(t)
+	defer teardown()
+
+	content := "Status:\n\n```{=html}\n<iframe src=\"https://sso.example.com/status\" width=\"300\" onload=\"alert(1)\"></iframe>\n<iframe src=\"http://sso.example.com/status\"></iframe>\n<script>alert(1)</script>\n```\n\n<iframe src=\"https://elsewhere.example.com\"></iframe>\n"
+	enabled := config.MarkdownConfig{
+		RawHTMLBlocks:     true,
+		RawHTMLElements:   []string{"iframe", "script"},
+		RawHTMLAttributes: []string{"src", "width", "onload"},
+	}
+	kept := `<iframe src="https://sso.example.com/status" width="300"></iframe>`
+
+	tests := []struct {
+		name     string
+		cfg      config.MarkdownConfig
+		trusted  bool
+		wantKept bool
+	}{
+		{"enabled for an admin author", enabled, true, true},
+		{"enabled for an editor author", enabled, false, false},
+		{"disabled", config.MarkdownConfig{RawHTMLElements: []string{"iframe"}, RawHTMLAttributes: []string{"src"}}, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pageService := NewPageService(&mockPageRepository{}, &mockCategoryRepository{}, testCache, WithMarkdownConfig(tt.cfg))
+			page := &data.Page{Title: "Status", Content: content, TrustedHTML: tt.trusted}
+			if err := pageService.processMarkdown(context.Background(), page); err != nil {
+				t.Fatalf("processMarkdown failed: %v", err)
+			}
+			html := string(page.HTMLContent)
+			if got := strings.Contains(html, kept); got != tt.wantKept {
+				t.Errorf("expected the raw block to be kept: %v, got %s", tt.wantKept, html)
+			}
+			if strings.Contains(html, "<script") || strings.Contains(html, "onload") || strings.Contains(html, "http://") {
+				t.Errorf("expected the raw block to be held to the allow-list and https URLs, got %s", html)
+			}
+			if strings.Contains(html, "&lt;iframe") || strings.Contains(html, "<code") {
+				t.Errorf("expected the raw block never to be shown as code, got %s", html)
+			}
+			// The block is the only way in: raw HTML elsewhere in the page is still stripped.
+			if strings.Contains(html, "elsewhere.example.com") {
+				t.Errorf("expected raw HTML outside the block to be stripped, got %s", html)
+			}
+			if !strings.Contains(html, "<p>Status:</p>") {
+				t.Errorf("expected the rest of the page to be rendered, got %s", html)
+			}
+		})
+	}
+}
+
+func TestPageService_TitlePathCategories(t *testing.T) {
+	testCache, teardown := newTestCache(t)
+	defer teardown()
+
+	var saved []*data.Category
+	categoryRepo := &mockCategoryRepository{
+		saveFunc: func(category *data.Category) (int64, error) {
+			saved = append(saved, category)
+			return int64(len(saved)), nil
+		},
+	}
+	mockPageRepo := &mockPageRepository{}
+	pageService := NewPageService(mockPageRepo, categoryRepo, testCache,
+		WithContentConfig(config.ContentConfig{TitlePathCategories: true}))
+
+	page, err := pageService.CreatePage(context.Background(), "API / Auth/Tokens", "content", "user1", "Ignored", "")
+	if err != nil {
+		t.Fatalf("CreatePage failed: %v", err)
+	}
+	if page.Title != "Tokens" {
+		t.Errorf("expected the page to be titled Tokens, got %q", page.Title)
+	}
+	if len(saved) != 2 || saved[0].Name != "API" || saved[0].ParentID != nil || saved[1].Name != "Auth" || saved[1].ParentID == nil || *saved[1].ParentID != 1 {
+		t.Fatalf("expected category API with subcategory Auth, got %+v", saved)
+	}
+	if mockPageRepo.lastPagePassed.CategoryID == nil || *mockPageRepo.lastPagePassed.CategoryID != 2 {
+		t.Errorf("expected the page to be filed under the subcategory, got %v", mockPageRepo.lastPagePassed.CategoryID)
+	}
+
+	if _, err := pageService.CreatePage(context.Background(), "A/B/C/D", "content", "user1", "", ""); !errors.Is(err, ErrTitlePathTooDeep) {
+		t.Errorf("expected ErrTitlePathTooDeep for four segments, got %v", err)
+	}
+	for _, title := range []string{"/", " / ", "Docs/Home"} {
+		if _, err := pageService.CreatePage(context.Background(), title, "content", "user1", "", ""); !errors.Is(err, ErrInvalidTitlePath) {
+			t.Errorf("%q: expected ErrInvalidTitlePath, got %v", title, err)
+		}
+	}
+
+	// "API/Auth/Tokens" was stored as "Tokens", so another path ending in Tokens
+	// may neither create a second page nor rename another page onto it.
+	mockPageRepo.pageToReturn = &data.Page{ID: 7, Title: "Tokens", Content: "content"}
+	if _, err := pageService.CreatePage(context.Background(), "Web/Auth/Tokens", "content", "user1", "", ""); !errors.Is(err, ErrTitlePathTaken) {
+		t.Errorf("expected ErrTitlePathTaken for a colliding path, got %v", err)
+	}
+	if _, err := pageService.UpdatePage(context.Background(), 8, "Web/Auth/Tokens", "content", "", "", false); !errors.Is(err, ErrTitlePathTaken) {
+		t.Errorf("expected ErrTitlePathTaken renaming onto another page, got %v", err)
+	}
+	if _, err := pageService.UpdatePage(context.Background(), 7, "API/Auth/Tokens", "edited", "", "", false); err != nil {
+		t.Errorf("expected the page to keep its own title, got %v", err)
+	}
+
+	plain := NewPageService(&mockPageRepository{}, &mockCategoryRepository{}, testCache)
+	if page, err := plain.CreatePage(context.Background(), "API/Auth", "content", "user1", "", ""); err != nil || page.Title != "API/Auth" {
+		t.Errorf("expected titles to be kept as written with the mode off, got %+v (%v)", page, err)
+	}
+}
+
+func TestPageService_MarkdownRoundTrip(t *testing.T) {
+	testCache, teardown := newTestCache(t)
+	defer teardown()
+
+	// Both of these were mangled when raw markdown was sanitized on write:
+	// the comparison was HTML-escaped and the autolink was stripped as a tag.
+	content := "Check that `a < b` holds, see <https://example.com>."
+	mockPageRepo := &mockPageRepository{}
+	pageService := NewPageService(mockPageRepo, &mockCategoryRepository{}, testCache)
+
+	created, err := pageService.CreatePage(context.Background(), "Markdown", content, "user1", "", "")
+	if err != nil {
+		t.Fatalf("CreatePage failed: %v", err)
+	}
+	if mockPageRepo.lastPagePassed.Content != content {
+		t.Errorf("expected content to be stored unchanged, got %q", mockPageRepo.lastPagePassed.Content)
+	}
+
+	mockPageRepo.pageToReturn = &data.Page{ID: created.ID, Title: "Markdown", Content: "old"}
+	if _, err := pageService.UpdatePage(context.Background(), created.ID, "Markdown", content, "", "", false); err != nil {
+		t.Fatalf("UpdatePage failed: %v", err)
+	}
+	if mockPageRepo.lastPagePassed.Content != content {
+		t.Errorf("expected updated content to be stored unchanged, got %q", mockPageRepo.lastPagePassed.Content)
+	}
+
+	mockPageRepo.pageToReturn = &data.Page{ID: 1, Title: "Markdown", Content: content}
+	page, err := pageService.ViewPage(context.Background(), "Markdown")
+	if err != nil {
+		t.Fatalf("ViewPage failed: %v", err)
+	}
+	html := string(page.HTMLContent)
+	if !strings.Contains(html, "<code>a &lt; b</code>") {
+		t.Errorf("expected comparison to render as code, got %s", html)
+	}
+	if !strings.Contains(html, `href="https://example.com"`) {
+		t.Errorf("expected autolink to render as a link, got %s", html)
+	}
+}
+
+func TestPageService_ViewPage_SanitizesRenderedHTML(t *testing.T) {
+	testCache, teardown := newTestCache(t)
+	defer teardown()
+
+	mockPageRepo := &mockPageRepository{
+		pageToReturn: &data.Page{ID: 1, Title: "Unsafe", Content: "[click](javascript:alert(1))\n\n<script>alert(1)</script>"},
+	}
+	pageService := NewPageService(mockPageRepo, &mockCategoryRepository{}, testCache)
+
+	page, err := pageService.ViewPage(context.Background(), "Unsafe")
+	if err != nil {
+		t.Fatalf("ViewPage failed: %v", err)
+	}
+	html := string(page.HTMLContent)
+	if strings.Contains(html, "<script") || strings.Contains(html, "javascript:") {
+		t.Errorf("expected rendered HTML to be sanitized, got %s", html)
+	}
+}
+
+func TestPageService_FileBackupOnSave(t *testing.T) {
+	testCache, teardown := newTestCache(t)
+	defer teardown()
+
+	backupDir := t.TempDir()
+	mockPageRepo := &mockPageRepository{}
+	pageService := NewPageService(mockPageRepo, &mockCategoryRepository{}, testCache,
+		WithContentConfig(config.ContentConfig{FileBackupDir: backupDir}))
+
+	content := "# Release Notes\n\nFirst draft."
+	if _, err := pageService.CreatePage(context.Background(), "Release Notes: 2.0", content, "user1", "", ""); err != nil {
+		t.Fatalf("CreatePage failed: %v", err)
+	}
+	if err := pageService.WaitForBackups(context.Background()); err != nil {
+		t.Fatalf("WaitForBackups failed: %v", err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(backupDir, "release-notes-2-0", "*.md"))
+	if err != nil {
+		t.Fatalf("Glob failed: %v", err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("expected 1 backup file, got %d", len(files))
+	}
+	written, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatalf("failed to read backup: %v", err)
+	}
+	if string(written) != content {
+		t.Errorf("expected backup to contain the page markdown, got %q", written)
+	}
+}
+
+func TestPageService_StubMarking(t *testing.T) {
+	testCache, teardown := newTestCache(t)
+	defer teardown()
+
+	short := &data.Page{ID: 1, Title: "Short", Content: "Just a sentence."}
+	long := &data.Page{ID: 2, Title: "Long", Content: strings.Repeat("word ", 20)}
+	mockPageRepo := &mockPageRepository{pagesToReturn: []*data.Page{short, long}}
+	pageService := NewPageService(mockPageRepo, &mockCategoryRepository{}, testCache,
+		WithContentConfig(config.ContentConfig{StubWordThreshold: 10}))
+
+	stubs, err := pageService.GetStubs(context.Background())
+	if err != nil {
+		t.Fatalf("GetStubs failed: %v", err)
+	}
+	if len(stubs) != 1 || stubs[0].Title != "Short" {
+		t.Fatalf("expected only 'Short' to be a stub, got %v", stubs)
+	}
+
+	mockPageRepo.pageToReturn = &data.Page{ID: 1, Title: "Short", Content: short.Content}
+	page, err := pageService.ViewPage(context.Background(), "Short")
+	if err != nil {
+		t.Fatalf("ViewPage failed: %v", err)
+	}
+	if !page.IsStub {
+		t.Error("expected short page to be flagged as a stub")
+	}
+
+	mockPageRepo.pageToReturn = &data.Page{ID: 2, Title: "Long", Content: long.Content}
+	page, err = pageService.ViewPage(context.Background(), "Long")
+	if err != nil {
+		t.Fatalf("ViewPage failed: %v", err)
+	}
+	if page.IsStub {
+		t.Error("expected long page not to be flagged as a stub")
+	}
+}
+
+func TestPageService_AutoLinkTitles(t *testing.T) {
+	testCache, teardown := newTestCache(t)
+	defer teardown()
+
+	mockPageRepo := &mockPageRepository{
+		pagesToReturn: []*data.Page{{ID: 1, Title: "Deploying"}, {ID: 2, Title: "Guide"}},
+		pageToReturn:  &data.Page{ID: 2, Title: "Guide", Content: "Read Deploying first, then run `Deploying` by hand. See [Deploying](/x) too."},
+	}
+	pageService := NewPageService(mockPageRepo, &mockCategoryRepository{}, testCache,
+		WithMarkdownConfig(config.MarkdownConfig{AutoLinkTitles: true, AutoLinkMode: AutoLinkExact}))
+
+	page, err := pageService.ViewPage(context.Background(), "Guide")
+	if err != nil {
+		t.Fatalf("ViewPage failed: %v", err)
+	}
+	html := string(page.HTMLContent)
+	if !strings.Contains(html, `Read <a href="/view/Deploying" rel="nofollow">Deploying</a> first`) {
+		t.Errorf("expected title in prose to be linked, got %s", html)
+	}
+	if !strings.Contains(html, "<code>Deploying</code>") {
+		t.Errorf("expected title in code span to be left alone, got %s", html)
+	}
+	if strings.Count(html, "<a ") != 2 {
+		t.Errorf("expected existing link not to be nested or duplicated, got %s", html)
+	}
+}
+
+func TestPageService_AutoLinkTitles_Unicode(t *testing.T) {
+	testCache, teardown := newTestCache(t)
+	defer teardown()
+
+	mockPageRepo := &mockPageRepository{
+		pagesToReturn: []*data.Page{{ID: 1, Title: "Café"}, {ID: 2, Title: "Über"}, {ID: 3, Title: "Guide"}},
+		pageToReturn:  &data.Page{ID: 3, Title: "Guide", Content: "Meet at Café, not the Cafés. Read Übersicht and Über."},
+	}
+	pageService := NewPageService(mockPageRepo, &mockCategoryRepository{}, testCache,
+		WithMarkdownConfig(config.MarkdownConfig{AutoLinkTitles: true, AutoLinkMode: AutoLinkExact}))
+
+	page, err := pageService.ViewPage(context.Background(), "Guide")
+	if err != nil {
+		t.Fatalf("ViewPage failed: %v", err)
+	}
+	html := string(page.HTMLContent)
+	want := `Meet at <a href="/view/Caf%C3%A9" rel="nofollow">Café</a>, not the Cafés. Read Übersicht and <a href="/view/%C3%9Cber" rel="nofollow">Über</a>.`
+	if !strings.Contains(html, want) {
+		t.Errorf("expected only whole-word mentions to be linked, got %s", html)
+	}
+
+	linker := &titleLinker{mode: AutoLinkExact}
+	titles := map[string]bool{"Café": true, "Über": true}
+	if linker.exactPattern(titles) != linker.exactPattern(map[string]bool{"Über": true, "Café": true}) {
+		t.Error("expected the compiled pattern to be reused for the same titles")
+	}
+	titles["Guide"] = true
+	if !linker.exactPattern(titles).MatchString("Guide") {
+		t.Error("expected the pattern to be rebuilt when the titles change")
+	}
+}
+
+func TestPageService_WikiLinks(t *testing.T) {
+	testCases := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"existing page", "Read [[Deploying]] first.", `Read <a href="/view/Deploying" rel="nofollow">Deploying</a> first.`},
+		{"label", "Read [[Deploying|the deploy guide]].", `Read <a href="/view/Deploying" rel="nofollow">the deploy guide</a>.`},
+		{"blank label", "Read [[Deploying| ]].", `Read <a href="/view/Deploying" rel="nofollow">Deploying</a>.`},
+		{"missing page", "Write [[Page Title]].", `Write <a href="/view/Page%20Title" class="missing" rel="nofollow">Page Title</a>.`},
+		{"special characters", "See [[C++ & <Friends>?]].", `See <a href="/view/C++%20&amp;%20%3CFriends%3E%3F" rel="nofollow">C++ &amp; &lt;Friends&gt;?</a>.`},
+		{"nested brackets", "[[[Deploying]]] and [[Outer [[Deploying]] ]]", `[<a href="/view/Deploying" rel="nofollow">Deploying</a>] and [[Outer <a href="/view/Deploying" rel="nofollow">Deploying</a> ]]`},
+		{"empty target", "Nothing [[ ]] here.", `Nothing [[ ]] here.`},
+		{"code span", "Type `[[Deploying]]`.", `Type <code>[[Deploying]]</code>.`},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			testCache, teardown := newTestCache(t)
+			defer teardown()
+			mockPageRepo := &mockPageRepository{
+				pagesToReturn: []*data.Page{{ID: 1, Title: "Deploying"}, {ID: 2, Title: "C++ & <Friends>?"}},
+				pageToReturn:  &data.Page{ID: 3, Title: "Guide", Content: tc.content},
+			}
+			pageService := NewPageService(mockPageRepo, &mockCategoryRepository{}, testCache)
+
+			page, err := pageService.ViewPage(context.Background(), "Guide")
+			if err != nil {
+				t.Fatalf("ViewPage failed: %v", err)
+			}
+			if got := strings.TrimSpace(string(page.HTMLContent)); got != "<p>"+tc.want+"</p>" {
+				t.Errorf("expected <p>%s</p>, got %s", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestPageService_PageMetadata(t *testing.T) {
+	testCache, teardown := newTestCache(t)
+	defer teardown()
+
+	mockPageRepo := &mockPageRepository{pageToReturn: &data.Page{ID: 1, Title: "Runbook", Content: "Steps"}}
+	pageService := NewPageService(mockPageRepo, &mockCategoryRepository{}, testCache,
+		WithContentConfig(config.ContentConfig{MetadataFields: []string{"owner", "status"}}))
+	ctx := context.Background()
+
+	err := pageService.SetPageMetadata(ctx, 1, map[string]string{"owner": "alice", "colour": "blue"})
+	if !errors.Is(err, ErrUnknownMetadataKey) {
+		t.Fatalf("expected ErrUnknownMetadataKey, got %v", err)
+	}
+	if len(mockPageRepo.metadata[1]) != 0 {
+		t.Fatalf("expected nothing to be saved when a key is rejected, got %v", mockPageRepo.metadata[1])
+	}
+
+	if err := pageService.SetPageMetadata(ctx, 1, map[string]string{"owner": "alice", "status": "draft"}); err != nil {
+		t.Fatalf("SetPageMetadata failed: %v", err)
+	}
+	page, err := pageService.ViewPage(ctx, "Runbook")
+	if err != nil {
+		t.Fatalf("ViewPage failed: %v", err)
+	}
+	if page.Metadata["owner"] != "alice" || page.Metadata["status"] != "draft" {
+		t.Fatalf("unexpected metadata: %v", page.Metadata)
+	}
+
+	// Updating an existing key and clearing another must not be hidden by the page cache.
+	if err := pageService.SetPageMetadata(ctx, 1, map[string]string{"status": "reviewed", "owner": " "}); err != nil {
+		t.Fatalf("SetPageMetadata update failed: %v", err)
+	}
+	page, err = pageService.ViewPage(ctx, "Runbook")
+	if err != nil {
+		t.Fatalf("ViewPage failed: %v", err)
+	}
+	if _, ok := page.Metadata["owner"]; ok || page.Metadata["status"] != "reviewed" {
+		t.Errorf("expected status to be updated and owner removed, got %v", page.Metadata)
+	}
+}
+
+func TestPageService_CreatePage_SiteCaps(t *testing.T) {
+	testCache, teardown := newTestCache(t)
+	defer teardown()
+
+	existing := []*data.Page{{ID: 1, Title: "One", Content: "a"}, {ID: 2, Title: "Two", Content: "b"}}
+	mockPageRepo := &mockPageRepository{pagesToReturn: existing}
+	pageService := NewPageService(mockPageRepo, &mockCategoryRepository{}, testCache,
+		WithContentConfig(config.ContentConfig{MaxPages: 3}),
+	)
+	ctx := middleware.SetUserInfo(context.Background(), &middleware.UserInfo{Subject: "alice", Roles: []string{"editor"}})
+
+	if _, err := pageService.CreatePage(ctx, "Three", "content", "alice", "", ""); err != nil {
+		t.Fatalf("creation below the page cap should succeed, got %v", err)
+	}
+	// The cached count includes the new page, so the cap holds without recounting.
+	_, err := pageService.CreatePage(ctx, "Four", "content", "alice", "", "")
+	if !errors.Is(err, ErrWikiFull) || !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("expected ErrWikiFull wrapping ErrQuotaExceeded past the page cap, got %v", err)
+	}
+	if mockPageRepo.contentStatsCalls != 1 {
+		t.Errorf("expected usage to be counted once and then cached, got %d counts", mockPageRepo.contentStatsCalls)
+	}
+
+	adminCtx := middleware.SetUserInfo(context.Background(), &middleware.UserInfo{Subject: "root", Roles: []string{"admin"}})
+	if _, err := pageService.CreatePage(adminCtx, "Admin Page", "content", "root", "", ""); err != nil {
+		t.Errorf("expected admins to bypass the page cap, got %v", err)
+	}
+}
+
+func TestPageService_CreatePage_StorageCap(t *testing.T) {
+	testCache, teardown := newTestCache(t)
+	defer teardown()
+
+	mockPageRepo := &mockPageRepository{pagesToReturn: []*data.Page{{ID: 1, Title: "One", Content: strings.Repeat("x", 90)}}}
+	pageService := NewPageService(mockPageRepo, &mockCategoryRepository{}, testCache,
+		WithContentConfig(config.ContentConfig{MaxContentBytes: 100}),
+	)
+	ctx := middleware.SetUserInfo(context.Background(), &middleware.UserInfo{Subject: "alice", Roles: []string{"editor"}})
+
+	if _, err := pageService.CreatePage(ctx, "Big", strings.Repeat("y", 20), "alice", "", ""); !errors.Is(err, ErrWikiFull) {
+		t.Errorf("expected ErrWikiFull when content would exceed the storage cap, got %v", err)
+	}
+	if _, err := pageService.CreatePage(ctx, "Small", "tiny", "alice", "", ""); err != nil {
+		t.Errorf("expected content within the storage cap to be accepted, got %v", err)
+	}
+}
+
+func TestPageService_SearchPages_RankingAndSnippets(t *testing.T) {
+	testCache, teardown := newTestCache(t)
+	defer teardown()
+
+	bodyOnly := &data.Page{ID: 1, Title: "Operations", Content: strings.Repeat("We deploy often. ", 20)}
+	titleMatch := &data.Page{ID: 2, Title: "Deploy Guide", Content: "Steps for shipping a <release> & then you Deploy it."}
+	mockPageRepo := &mockPageRepository{pagesToReturn: []*data.Page{bodyOnly, titleMatch}}
+	pageService := NewPageService(mockPageRepo, &mockCategoryRepository{}, testCache)
+
+	results, err := pageService.SearchPages(context.Background(), data.SearchFilter{Query: "deploy", Sort: data.SearchSortRelevance, Limit: 10})
+	if err != nil {
+		t.Fatalf("SearchPages failed: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+	if results[0].Title != "Deploy Guide" || results[0].Score <= results[1].Score {
+		t.Errorf("expected the title match to outrank the body-only match, got %q (%.2f) before %q (%.2f)",
+			results[0].Title, results[0].Score, results[1].Title, results[1].Score)
+	}
+	want := "Steps for shipping a &lt;release&gt; &amp; then you <mark>Deploy</mark> it."
+	if string(results[0].Snippet) != want {
+		t.Errorf("want snippet %q; got %q", want, results[0].Snippet)
+	}
+	if !strings.HasPrefix(string(results[1].Snippet), "We <mark>deploy</mark> often.") || !strings.HasSuffix(string(results[1].Snippet), "…") {
+		t.Errorf("expected a truncated snippet starting at the first match, got %q", results[1].Snippet)
+	}
+}
+
+func TestPageService_DashboardWidgets(t *testing.T) {
+	testCache, teardown := newTestCache(t)
+	defer teardown()
+
+	mockPageRepo := &mockPageRepository{pagesToReturn: []*data.Page{
+		{ID: 1, Title: "One", AuthorID: "alice"},
+		{ID: 2, Title: "Two", AuthorID: "bob"},
+		{ID: 3, Title: "Three", AuthorID: "alice"},
+	}}
+	pageService := NewPageService(mockPageRepo, &mockCategoryRepository{}, testCache)
+	ctx := context.Background()
+
+	authors, err := pageService.AuthorCount(ctx)
+	if err != nil || authors != 2 {
+		t.Fatalf("expected 2 distinct authors, got %d (err %v)", authors, err)
+	}
+	// A later page is not reflected until the cached count expires.
+	mockPageRepo.pagesToReturn = append(mockPageRepo.pagesToReturn, &data.Page{ID: 4, AuthorID: "carol"})
+	if authors, _ := pageService.AuthorCount(ctx); authors != 2 {
+		t.Errorf("expected the cached author count, got %d", authors)
+	}
+
+	if stats := pageService.CacheStats(); stats.Hits == 0 || stats.Misses == 0 {
+		t.Errorf("expected the author count lookups to be recorded, got %+v", stats)
+	}
+	if err := pageService.DatabaseHealth(ctx); err != nil {
+		t.Errorf("expected a healthy database, got %v", err)
+	}
+}
+
+// mockRevisionRepository is an in-memory RevisionRepository.
+type mockRevisionRepository struct {
+	revisions []*data.Revision
+}
+
+func (m *mockRevisionRepository) CreateRevision(ctx context.Context, revision *data.Revision) error {
+	revision.ID = int64(len(m.revisions) + 1)
+	m.revisions = append(m.revisions, revision)
+	return nil
+}
+
+func (m *mockRevisionRepository) UpdatePageWithRevision(ctx context.Context, page *data.Page, revision *data.Revision) error {
+	return m.CreateRevision(ctx, revision)
+}
+
+func (m *mockRevisionRepository) GetRevision(ctx context.Context, id int64) (*data.Revision, error) {
+	for _, r := range m.revisions {
+		if r.ID == id {
+			return r, nil
+		}
+	}
+	return nil, fmt.Errorf("revision %d: %w", id, sql.ErrNoRows)
+}
+
+func (m *mockRevisionRepository) GetRevisions(ctx context.Context, pageID int64) ([]*data.Revision, error) {
+	var revisions []*data.Revision
+	for i := len(m.revisions) - 1; i >= 0; i-- {
+		if m.revisions[i].PageID == pageID {
+			revisions = append(revisions, m.revisions[i])
+		}
+	}
+	return revisions, nil
+}
+
+func (m *mockRevisionRepository) GetBySubject(ctx context.Context, subject string) ([]*data.Revision, error) {
+	var revisions []*data.Revision
+	for i := len(m.revisions) - 1; i >= 0; i-- {
+		if m.revisions[i].AuthorID == subject || m.revisions[i].AuthorIP == subject {
+			revisions = append(revisions, m.revisions[i])
+		}
+	}
+	return revisions, nil
+}
+
+func (m *mockRevisionRepository) GetRevisionPageIDs(ctx context.Context) ([]int64, error) {
+	seen := map[int64]bool{}
+	var ids []int64
+	for _, r := range m.revisions {
+		if !seen[r.PageID] {
+			seen[r.PageID] = true
+			ids = append(ids, r.PageID)
+		}
+	}
+	return ids, nil
+}
+
+func (m *mockRevisionRepository) GetRecentEdits(ctx context.Context, since time.Time, limit int) ([]*data.Revision, error) {
+	var revisions []*data.Revision
+	for i := len(m.revisions) - 1; i >= 0 && len(revisions) < limit; i-- {
+		if !m.revisions[i].CreatedAt.Before(since) {
+			revisions = append(revisions, m.revisions[i])
+		}
+	}
+	return revisions, nil
+}
+
+func (m *mockRevisionRepository) DeleteRevisions(ctx context.Context, ids []int64) (int64, error) {
+	doomed := map[int64]bool{}
+	for _, id := range ids {
+		doomed[id] = true
+	}
+	kept := m.revisions[:0]
+	for _, r := range m.revisions {
+		if !doomed[r.ID] {
+			kept = append(kept, r)
+		}
+	}
+	deleted := int64(len(m.revisions) - len(kept))
+	m.revisions = kept
+	return deleted, nil
+}
+
+func TestPageService_PruneRevisions(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	t.Run("caps revisions per page", func(t *testing.T) {
+		revisionRepo := &mockRevisionRepository{}
+		for i := 1; i <= 8; i++ {
+			revisionRepo.CreateRevision(ctx, &data.Revision{PageID: 1, Content: fmt.Sprintf("v%d", i), CreatedAt: now.Add(time.Duration(i) * time.Minute)})
+		}
+		revisionRepo.CreateRevision(ctx, &data.Revision{PageID: 2, Content: "only"})
+		pageService := NewPageService(&mockPageRepository{}, &mockCategoryRepository{}, nil,
+			WithRevisions(revisionRepo),
+			WithRevisionRetention(config.RevisionsConfig{MaxPerPage: 4, KeepRecent: 2}),
+		)
+
+		pruned, err := pageService.PruneRevisions(ctx)
+		if err != nil {
+			t.Fatalf("PruneRevisions failed: %v", err)
+		}
+		if pruned != 4 {
+			t.Errorf("expected 4 revisions pruned, got %d", pruned)
+		}
+		remaining, _ := revisionRepo.GetRevisions(ctx, 1)
+		var contents []string
+		for _, r := range remaining {
+			contents = append(contents, r.Content)
+		}
+		if got := strings.Join(contents, ","); got != "v8,v7,v6,v1" {
+			t.Errorf("expected the 3 newest and the first revision to be kept, got %s", got)
+		}
+		if others, _ := revisionRepo.GetRevisions(ctx, 2); len(others) != 1 {
+			t.Errorf("expected the single revision of another page to be kept, got %d", len(others))
+		}
+	})
+
+	t.Run("prunes by age but keeps the most recent", func(t *testing.T) {
+		revisionRepo := &mockRevisionRepository{}
+		for i := 5; i >= 1; i-- {
+			revisionRepo.CreateRevision(ctx, &data.Revision{PageID: 1, Content: fmt.Sprintf("%d days old", i*10), CreatedAt: now.AddDate(0, 0, -i*10)})
+		}
+		pageService := NewPageService(&mockPageRepository{}, &mockCategoryRepository{}, nil,
+			WithRevisions(revisionRepo),
+			WithRevisionRetention(config.RevisionsConfig{MaxAgeDays: 5, KeepRecent: 1}),
+		)
+
+		if _, err := pageService.PruneRevisions(ctx); err != nil {
+			t.Fatalf("PruneRevisions failed: %v", err)
+		}
+		remaining, _ := revisionRepo.GetRevisions(ctx, 1)
+		if len(remaining) != 2 || remaining[0].Content != "10 days old" || remaining[1].Content != "50 days old" {
+			t.Errorf("expected only the current and first revisions to survive, got %d", len(remaining))
+		}
+	})
+}
+
+func TestPageService_DiffAgainstCurrent(t *testing.T) {
+	ctx := context.Background()
+	revisionRepo := &mockRevisionRepository{}
+	revisionRepo.CreateRevision(ctx, &data.Revision{PageID: 1, Content: "intro\nold"})
+	revisionRepo.CreateRevision(ctx, &data.Revision{PageID: 2, Content: "other page"})
+	mockPageRepo := &mockPageRepository{pageToReturn: &data.Page{ID: 1, Title: "Diffed", Content: "intro\nnew"}}
+	pageService := NewPageService(mockPageRepo, &mockCategoryRepository{}, nil, WithRevisions(revisionRepo))
+
+	result, err := pageService.DiffAgainstCurrent(ctx, 1, 1)
+	if err != nil {
+		t.Fatalf("DiffAgainstCurrent failed: %v", err)
+	}
+	if result.To != nil || result.From.ID != 1 {
+		t.Errorf("expected revision 1 to be compared with the current content, got %+v", result)
+	}
+	want := []diff.Line{{Op: diff.Equal, Text: "intro"}, {Op: diff.Delete, Text: "old"}, {Op: diff.Insert, Text: "new"}}
+	if fmt.Sprint(result.Lines) != fmt.Sprint(want) {
+		t.Errorf("expected lines %v, got %v", want, result.Lines)
+	}
+
+	if _, err := pageService.DiffAgainstCurrent(ctx, 1, 2); !errors.Is(err, ErrRevisionNotFound) {
+		t.Errorf("expected ErrRevisionNotFound for another page's revision, got %v", err)
+	}
+	if _, err := pageService.DiffAgainstCurrent(ctx, 1, 42); !errors.Is(err, ErrRevisionNotFound) {
+		t.Errorf("expected ErrRevisionNotFound for a missing revision, got %v", err)
+	}
+}
+
+func TestPageService_RevertContributionsBy(t *testing.T) {
+	testCache, teardown := newTestCache(t)
+	defer teardown()
+
+	ctx := middleware.SetUserInfo(context.Background(), &middleware.UserInfo{Subject: "root", Roles: []string{"admin"}})
+	pages := []*data.Page{
+		{ID: 1, Title: "First", Content: "vandalised"},
+		{ID: 2, Title: "Second", Content: "vandalised again"},
+		{ID: 3, Title: "Spam", Content: "spam"},
+		{ID: 4, Title: "Fixed", Content: "fixed by carol"},
+	}
+	revisionRepo := &mockRevisionRepository{}
+	for _, r := range []*data.Revision{
+		{PageID: 1, Title: "First", Content: "good first", AuthorID: "alice"},
+		{PageID: 2, Title: "Second", Content: "good second", AuthorID: "bob"},
+		{PageID: 1, Title: "First", Content: "vandalised", AuthorID: "vandal"},
+		{PageID: 2, Title: "Second", Content: "vandalised", AuthorID: "vandal"},
+		{PageID: 2, Title: "Second", Content: "vandalised again", AuthorID: "vandal"},
+		{PageID: 3, Title: "Spam", Content: "spam", AuthorID: "vandal"},
+		{PageID: 4, Title: "Fixed", Content: "vandalised", AuthorID: "vandal"},
+		{PageID: 4, Title: "Fixed", Content: "fixed by carol", AuthorID: "carol"},
+	} {
+		revisionRepo.CreateRevision(ctx, r)
+	}
+	mockPageRepo := &mockPageRepository{pagesToReturn: pages}
+	pageService := NewPageService(mockPageRepo, &mockCategoryRepository{}, testCache, WithRevisions(revisionRepo))
+
+	results, err := pageService.RevertContributionsBy(ctx, "vandal")
+	if err != nil {
+		t.Fatalf("RevertContributionsBy failed: %v", err)
+	}
+	if len(results) != 4 {
+		t.Fatalf("expected a result for each of the 4 pages the vandal edited, got %d", len(results))
+	}
+	if pages[0].Content != "good first" || pages[1].Content != "good second" {
+		t.Errorf("expected both vandalised pages to be restored, got %q and %q", pages[0].Content, pages[1].Content)
+	}
+	if pages[2].Content != "spam" || pages[3].Content != "fixed by carol" {
+		t.Errorf("expected pages without a good revision or with later edits to be left alone, got %q and %q", pages[2].Content, pages[3].Content)
+	}
+
+	reverts := 0
+	for _, a := range mockPageRepo.recordedActivity {
+		if a.Action == data.ActivityRevert {
+			reverts++
+			if a.AuthorID != "root" {
+				t.Errorf("expected reverts to be attributed to the admin, got %q", a.AuthorID)
+			}
+		}
+	}
+	if reverts != 2 {
+		t.Errorf("expected 2 reverts in the activity log, got %d", reverts)
+	}
+	if latest, _ := revisionRepo.GetRevisions(ctx, 1); latest[0].AuthorID != "root" || latest[0].Content != "good first" {
+		t.Errorf("expected the revert to be saved as a new revision, got %+v", latest[0])
+	}
+}
+
+func TestPageService_UpdatePage_MinorEdit(t *testing.T) {
+	testCache, teardown := newTestCache(t)
+	defer teardown()
+
+	mockPageRepo := &mockPageRepository{pageToReturn: &data.Page{ID: 1, Title: "Typo", Content: "teh"}}
+	revisionRepo := &mockRevisionRepository{}
+	pageService := NewPageService(mockPageRepo, &mockCategoryRepository{}, testCache, WithRevisions(revisionRepo))
+	ctx := context.Background()
+
+	updates, unsubscribe, err := pageService.SubscribeToPage(ctx, "Typo")
+	if err != nil {
+		t.Fatalf("SubscribeToPage failed: %v", err)
+	}
+	defer unsubscribe()
+
+	if _, err := pageService.UpdatePage(ctx, 1, "Typo", "the", "", "", true); err != nil {
+		t.Fatalf("UpdatePage failed: %v", err)
+	}
+	if len(revisionRepo.revisions) != 1 || !revisionRepo.revisions[0].Minor {
+		t.Errorf("expected a revision flagged as minor, got %+v", revisionRepo.revisions)
+	}
+	if a := mockPageRepo.recordedActivity; len(a) != 1 || !a[0].Minor {
+		t.Errorf("expected the activity entry to be flagged as minor, got %+v", a)
+	}
+	select {
+	case ev := <-updates:
+		if !ev.Minor {
+			t.Error("expected the update event to be flagged as minor")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected an update event")
+	}
+}
+
+func TestPageService_SeeAlso(t *testing.T) {
+	testCache, teardown := newTestCache(t)
+	defer teardown()
+
+	content := "Start with [[Setup]] and [[Deploying|deploy it]]. Read [Deploying](/view/Deploying) for details.\n\n" +
+		"See [[Setup]] again, [[Missing Page]], [[Guide]] and `[[Monitoring]]`.\n\n[[Monitoring]]"
+	mockPageRepo := &mockPageRepository{
+		pagesToReturn: []*data.Page{{ID: 1, Title: "Setup"}, {ID: 2, Title: "Deploying"}, {ID: 3, Title: "Guide"}, {ID: 4, Title: "Monitoring"}},
+		pageToReturn:  &data.Page{ID: 3, Title: "Guide", Content: content},
+	}
+
+	t.Run("disabled by default", func(t *testing.T) {
+		pageService := NewPageService(mockPageRepo, &mockCategoryRepository{}, testCache)
+		page, err := pageService.ViewPage(context.Background(), "Guide")
+		if err != nil {
+			t.Fatalf("ViewPage failed: %v", err)
+		}
+		if page.SeeAlso != nil {
+			t.Errorf("expected no See also section, got %v", page.SeeAlso)
+		}
+	})
+
+	t.Run("lists linked pages not otherwise linked", func(t *testing.T) {
+		pageService := NewPageService(mockPageRepo, &mockCategoryRepository{}, testCache,
+			WithMarkdownConfig(config.MarkdownConfig{SeeAlso: true}))
+		page, err := pageService.ViewPage(context.Background(), "Guide")
+		if err != nil {
+			t.Fatalf("ViewPage failed: %v", err)
+		}
+		// Deploying is already linked, Missing Page does not exist, Guide is the
+		// page itself, and the first Monitoring mention is code.
+		if got := strings.Join(page.SeeAlso, ","); got != "Setup,Monitoring" {
+			t.Errorf("expected See also to list Setup,Monitoring, got %q", got)
+		}
+		if strings.Contains(page.Content, "See also") {
+			t.Error("expected the stored content to be left unchanged")
+		}
+	})
+}
+
+func TestSlugify(t *testing.T) {
+	testCases := []struct {
+		name          string
+		title         string
+		transliterate bool
+		want          string
+	}{
+		{"latin", "Release Notes: 2.0", false, "release-notes-2-0"},
+		{"unicode kept without transliteration", "Привет Мир", false, "привет-мир"},
+		{"empty", "  !? ", true, "page"},
+		{"accents stripped", "Café Crème", true, "cafe-creme"},
+		{"cyrillic", "Привет Мир", true, "privet-mir"},
+		{"mixed", "Щука и Ёж 2", true, "shchuka-i-ezh-2"},
+		{"cjk falls back to code points", "你好 世界", true, "4f60-597d-4e16-754c"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Slugify(tc.title, tc.transliterate); got != tc.want {
+				t.Errorf("Slugify(%q, %v) = %q, want %q", tc.title, tc.transliterate, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestPageService_GetCoEditedPages(t *testing.T) {
+	ctx := context.Background()
+	c, teardown := newTestCache(t)
+	defer teardown()
+
+	now := time.Now()
+	revisionRepo := &mockRevisionRepository{}
+	for _, r := range []struct {
+		pageID int64
+		author string
+		ago    time.Duration
+	}{
+		{1, "alice", 3 * time.Hour},
+		{2, "alice", 3*time.Hour - 10*time.Minute}, // with page 1
+		{3, "alice", 3*time.Hour - 20*time.Minute}, // with page 1
+		{1, "bob", 2 * time.Hour},
+		{3, "bob", 2*time.Hour + 5*time.Minute}, // with page 1, so page 3 ranks first
+		{4, "bob", 30 * time.Minute},            // outside the window
+		{5, "anonymous", 2 * time.Hour},         // anonymous edits are ignored
+		{6, "alice", 200 * 24 * time.Hour},      // older than the lookback
+		{1, "alice", 200*24*time.Hour + time.Minute},
+	} {
+		revisionRepo.revisions = append(revisionRepo.revisions, &data.Revision{
+			ID: int64(len(revisionRepo.revisions) + 1), PageID: r.pageID, AuthorID: r.author, CreatedAt: now.Add(-r.ago),
+		})
+	}
+	sort.Slice(revisionRepo.revisions, func(i, j int) bool {
+		return revisionRepo.revisions[i].CreatedAt.Before(revisionRepo.revisions[j].CreatedAt)
+	})
+	pageRepo := &mockPageRepository{pagesToReturn: []*data.Page{
+		{ID: 2, Title: "Two"}, {ID: 3, Title: "Three"}, {ID: 4, Title: "Four"}, {ID: 5, Title: "Five"}, {ID: 6, Title: "Six"},
+	}}
+	pageService := NewPageService(pageRepo, &mockCategoryRepository{}, c,
+		WithRevisions(revisionRepo),
+		WithRevisionRetention(config.RevisionsConfig{CoEditWindowMinutes: 60, CoEditLookbackDays: 90}),
+	)
+
+	pages, err := pageService.GetCoEditedPages(ctx, 1, 5)
+	if err != nil {
+		t.Fatalf("GetCoEditedPages failed: %v", err)
+	}
+	var titles []string
+	for _, p := range pages {
+		titles = append(titles, p.Title)
+	}
+	if got := fmt.Sprint(titles); got != "[Three Two]" {
+		t.Errorf("expected [Three Two], got %s", got)
+	}
+
+	// The result is cached, so new revisions do not change it until it expires.
+	revisionRepo.revisions = append(revisionRepo.revisions, &data.Revision{ID: 100, PageID: 4, AuthorID: "bob", CreatedAt: now.Add(-2 * time.Hour)})
+	if pages, _ := pageService.GetCoEditedPages(ctx, 1, 1); len(pages) != 1 || pages[0].Title != "Three" {
+		t.Errorf("expected the cached ranking limited to [Three], got %v", pages)
+	}
+}
+
+// stubHTTPClient answers requests from canned statuses keyed by URL; other URLs get a 404.
+type stubHTTPClient struct {
+	mu       sync.Mutex
+	statuses map[string]int
+	bodies   map[string]string
+	requests []string
+}
+
+func (c *stubHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	link := req.URL.String()
+	c.requests = append(c.requests, req.Method+" "+link)
+	status, ok := c.statuses[link]
+	if !ok {
+		status = http.StatusNotFound
+	}
+	return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader(c.bodies[link]))}, nil
+}
+
+func TestPageService_CheckExternalLinks(t *testing.T) {
+	client := &stubHTTPClient{
+		statuses: map[string]int{
+			"https://example.com/ok":             http.StatusOK,
+			"https://old.example.org/robots.txt": http.StatusOK,
+		},
+		bodies: map[string]string{
+			"https://old.example.org/robots.txt": "User-agent: *\nDisallow: /private\n",
+		},
+	}
+	pageRepo := &mockPageRepository{pagesToReturn: []*data.Page{
+		{ID: 1, Title: "Links", Content: "See [the docs](https://example.com/ok) and https://example.com/missing."},
+		{ID: 2, Title: "Private", Content: "<https://old.example.org/private/page>"},
+	}}
+	pageService := NewPageService(pageRepo, &mockCategoryRepository{}, nil,
+		WithLinkCheck(config.LinkCheckConfig{Concurrency: 2}),
+		WithHTTPClient(client),
+	)
+
+	checked, err := pageService.CheckExternalLinks(context.Background())
+	if err != nil {
+		t.Fatalf("CheckExternalLinks failed: %v", err)
+	}
+	if checked != 3 {
+		t.Errorf("expected 3 links found, got %d", checked)
+	}
+	if len(pageRepo.deadLinks) != 1 {
+		t.Fatalf("expected 1 dead link, got %d", len(pageRepo.deadLinks))
+	}
+	dead := pageRepo.deadLinks[0]
+	if dead.URL != "https://example.com/missing" || dead.PageTitle != "Links" || dead.StatusCode != http.StatusNotFound {
+		t.Errorf("unexpected dead link: %+v", dead)
+	}
+	for _, request := range client.requests {
+		if strings.Contains(request, "/private/") {
+			t.Errorf("expected the link disallowed by robots.txt to be skipped, got %q", request)
+		}
+		if strings.HasSuffix(request, "/ok") && !strings.HasPrefix(request, "HEAD ") {
+			t.Errorf("expected links to be checked with HEAD, got %q", request)
+		}
+	}
+}
+
+func TestPageService_CreateFromTemplate(t *testing.T) {
+	ctx := context.Background()
+	c, teardown := newTestCache(t)
+	defer teardown()
+
+	template := &data.Page{
+		Title:   "Template:Meeting notes",
+		Content: "# {{title}}\nDate: {{ date }}\nNotes by {{author}}\nNext: {{agenda}}",
+	}
+
+	t.Run("substitutes known variables and keeps unknown ones", func(t *testing.T) {
+		pageRepo := &mockPageRepository{pageToReturn: template}
+		pageService := NewPageService(pageRepo, &mockCategoryRepository{}, c)
+
+		page, err := pageService.CreateFromTemplate(ctx, "Meeting notes", "Weekly sync {{author}}", "alice")
+		if err != nil {
+			t.Fatalf("CreateFromTemplate failed: %v", err)
+		}
+		want := "# Weekly sync {{author}}\nDate: " + time.Now().UTC().Format("2006-01-02") + "\nNotes by alice\nNext: {{agenda}}"
+		if page.Content != want {
+			t.Errorf("unexpected content:\n got %q\nwant %q", page.Content, want)
+		}
+		if page.Title != "Weekly sync {{author}}" || page.AuthorID != "alice" {
+			t.Errorf("unexpected page %q by %q", page.Title, page.AuthorID)
+		}
+	})
+
+	t.Run("rejects unknown variables when strict", func(t *testing.T) {
+		pageRepo := &mockPageRepository{pageToReturn: template}
+		pageService := NewPageService(pageRepo, &mockCategoryRepository{}, c,
+			WithContentConfig(config.ContentConfig{StrictTemplates: true}),
+		)
+
+		_, err := pageService.CreateFromTemplate(ctx, "Meeting notes", "Weekly sync", "alice")
+		if !errors.Is(err, ErrUnknownTemplateVariable) || !strings.Contains(err.Error(), "agenda") {
+			t.Errorf("expected ErrUnknownTemplateVariable naming agenda, got %v", err)
+		}
+		if pageRepo.createPageCalled {
+			t.Error("expected no page to be created")
+		}
+	})
+
+	t.Run("missing template", func(t *testing.T) {
+		pageService := NewPageService(&mockPageRepository{}, &mockCategoryRepository{}, c)
+		if _, err := pageService.CreateFromTemplate(ctx, "Nope", "Page", "alice"); !errors.Is(err, ErrTemplateNotFound) {
+			t.Errorf("expected ErrTemplateNotFound, got %v", err)
+		}
+	})
+}
+
+// mockPendingEditRepository is an in-memory PendingEditRepository.
+type mockPendingEditRepository struct {
+	edits []*data.PendingEdit
+}
+
+func (m *mockPendingEditRepository) CreatePendingEdit(ctx context.Context, edit *data.PendingEdit) error {
+	edit.ID = int64(len(m.edits) + 1)
+	if edit.CreatedAt.IsZero() {
+		edit.CreatedAt = time.Now().UTC()
+	}
+	m.edits = append(m.edits, edit)
+	return nil
+}
+
+func (m *mockPendingEditRepository) GetPendingEdit(ctx context.Context, id int64) (*data.PendingEdit, error) {
+	for _, edit := range m.edits {
+		if edit.ID == id {
+			return edit, nil
+		}
+	}
+	return nil, fmt.Errorf("pending edit %d: %w", id, sql.ErrNoRows)
+}
+
+func (m *mockPendingEditRepository) GetPendingEdits(ctx context.Context) ([]*data.PendingEdit, error) {
+	return m.edits, nil
+}
+
+func (m *mockPendingEditRepository) DeletePendingEdit(ctx context.Context, id int64) error {
+	for i, edit := range m.edits {
+		if edit.ID == id {
+			m.edits = append(m.edits[:i], m.edits[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func TestPageService_ReviewQueue(t *testing.T) {
+	moderation := config.ModerationConfig{RequireReview: true, TrustedRoles: []string{"moderator", "admin"}}
+	contributor := middleware.SetUserInfo(context.Background(), &middleware.UserInfo{Subject: "anonymous", IP: "192.0.2.7"})
+	moderator := middleware.SetUserInfo(context.Background(), &middleware.UserInfo{Subject: "mod", Roles: []string{"admin"}})
+
+	newService := func(t *testing.T) (*PageService, *mockPageRepository, *mockRevisionRepository, *mockPendingEditRepository) {
+		testCache, teardown := newTestCache(t)
+		t.Cleanup(teardown)
+		pageRepo := &mockPageRepository{pageToReturn: &data.Page{ID: 1, Title: "Runbook", Content: "original"}}
+		revisionRepo := &mockRevisionRepository{}
+		queue := &mockPendingEditRepository{}
+		pageService := NewPageService(pageRepo, &mockCategoryRepository{}, testCache,
+			WithRevisions(revisionRepo),
+			WithModeration(queue, moderation),
+		)
+		return pageService, pageRepo, revisionRepo, queue
+	}
+
+	t.Run("queue and approve", func(t *testing.T) {
+		pageService, pageRepo, revisionRepo, queue := newService(t)
+
+		if _, err := pageService.UpdatePage(contributor, 1, "Runbook", "<b>proposed</b>", "Ops", "", false); !errors.Is(err, ErrPendingReview) {
+			t.Fatalf("expected ErrPendingReview for an untrusted edit, got %v", err)
+		}
+		if pageRepo.pageToReturn.Content != "original" || len(revisionRepo.revisions) != 0 {
+			t.Fatal("expected the page to be unchanged until the edit is approved")
+		}
+		edits, _ := pageService.GetPendingEdits(moderator)
+		if len(edits) != 1 || edits[0].AuthorIP != "192.0.2.7" || edits[0].CategoryName != "Ops" {
+			t.Fatalf("expected the edit to be queued with its author's address, got %+v", edits)
+		}
+
+		page, err := pageService.ApprovePendingEdit(moderator, edits[0].ID)
+		if err != nil {
+			t.Fatalf("ApprovePendingEdit failed: %v", err)
+		}
+		if page.Content != "<b>proposed</b>" || page.TrustedHTML {
+			t.Errorf("expected the proposed content to be saved without the admin's trust, got %+v", page)
+		}
+		if len(revisionRepo.revisions) != 1 || revisionRepo.revisions[0].AuthorID != "anonymous" || revisionRepo.revisions[0].AuthorIP != "192.0.2.7" {
+			t.Errorf("expected a revision attributed to the contributor, got %+v", revisionRepo.revisions)
+		}
+		if len(queue.edits) != 0 {
+			t.Errorf("expected the approved edit to leave the queue, got %d", len(queue.edits))
+		}
+		if _, err := pageService.ApprovePendingEdit(moderator, edits[0].ID); !errors.Is(err, ErrPendingEditNotFound) {
+			t.Errorf("expected ErrPendingEditNotFound for an edit already reviewed, got %v", err)
+		}
+	})
+
+	t.Run("queue and reject", func(t *testing.T) {
+		pageService, pageRepo, revisionRepo, queue := newService(t)
+
+		if _, err := pageService.UpdatePage(contributor, 1, "Runbook", "spam", "", "", false); !errors.Is(err, ErrPendingReview) {
+			t.Fatalf("expected ErrPendingReview for an untrusted edit, got %v", err)
+		}
+		edit, err := pageService.RejectPendingEdit(moderator, queue.edits[0].ID)
+		if err != nil {
+			t.Fatalf("RejectPendingEdit failed: %v", err)
+		}
+		if edit.Content != "spam" {
+			t.Errorf("expected the rejected edit to be returned, got %+v", edit)
+		}
+		if len(queue.edits) != 0 || pageRepo.pageToReturn.Content != "original" || len(revisionRepo.revisions) != 0 {
+			t.Error("expected the rejected edit to be discarded without changing the page")
+		}
+	})
+
+	t.Run("trusted roles bypass the queue", func(t *testing.T) {
+		pageService, pageRepo, _, queue := newService(t)
+
+		if _, err := pageService.UpdatePage(moderator, 1, "Runbook", "direct", "", "", false); err != nil {
+			t.Fatalf("UpdatePage failed: %v", err)
+		}
+		if pageRepo.pageToReturn.Content != "direct" || len(queue.edits) != 0 {
+			t.Error("expected a trusted edit to be saved directly")
+		}
+	})
+
+	t.Run("reviews diff against the current version", func(t *testing.T) {
+		pageService, _, _, _ := newService(t)
+
+		if _, err := pageService.UpdatePage(contributor, 1, "Runbook", "original\nproposed", "", "", false); !errors.Is(err, ErrPendingReview) {
+			t.Fatalf("expected ErrPendingReview for an untrusted edit, got %v", err)
+		}
+		reviews, err := pageService.GetPendingEditReviews(moderator)
+		if err != nil {
+			t.Fatalf("GetPendingEditReviews failed: %v", err)
+		}
+		if len(reviews) != 1 {
+			t.Fatalf("expected one review, got %d", len(reviews))
+		}
+		want := []diff.Line{{Op: diff.Equal, Text: "original"}, {Op: diff.Insert, Text: "proposed"}}
+		if got := reviews[0].Lines; fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("expected the edit to be diffed against the current content, got %v", got)
+		}
+		if reviews[0].Added != 1 || reviews[0].Removed != 0 || reviews[0].Conflict {
+			t.Errorf("expected one added line and no conflict, got %+v", reviews[0])
+		}
+
+		if _, err := pageService.UpdatePage(moderator, 1, "Runbook", "rewritten", "", "", false); err != nil {
+			t.Fatalf("UpdatePage failed: %v", err)
+		}
+		reviews, _ = pageService.GetPendingEditReviews(moderator)
+		if len(reviews) != 1 || !reviews[0].Conflict {
+			t.Fatalf("expected a change saved after the edit was queued to be flagged, got %+v", reviews)
+		}
+		if reviews[0].Removed != 1 || reviews[0].Added != 2 {
+			t.Errorf("expected the diff to be against the newer content, got %v", reviews[0].Lines)
+		}
+	})
+	t.Run("new pages, moves and rollbacks are queued", func(t *testing.T) {
+		pageService, pageRepo, revisionRepo, queue := newService(t)
+
+		created, err := pageService.CreatePage(contributor, "Draft", "proposed", "anonymous", "Ops", "")
+		if !errors.Is(err, ErrPendingReview) {
+			t.Fatalf("expected ErrPendingReview for an untrusted new page, got %v", err)
+		}
+		if created == nil || created.Title != "Draft" || pageRepo.createPageCalled {
+			t.Fatalf("expected no page to be stored before approval, got %+v", pageRepo.lastPagePassed)
+		}
+		if len(queue.edits) != 1 || queue.edits[0].PageID != 0 || queue.edits[0].Content != "proposed" || queue.edits[0].CategoryName != "Ops" {
+			t.Fatalf("expected the new page to be queued, got %+v", queue.edits)
+		}
+
+		if err := pageService.MovePage(contributor, "Runbook", "Archive", ""); !errors.Is(err, ErrPendingReview) {
+			t.Fatalf("expected ErrPendingReview for an untrusted move, got %v", err)
+		}
+		if len(queue.edits) != 2 || queue.edits[1].Content != "original" || queue.edits[1].CategoryName != "Archive" {
+			t.Fatalf("expected the move to be queued, got %+v", queue.edits[1:])
+		}
+
+		revisionRepo.revisions = []*data.Revision{{ID: 7, PageID: 1, Content: "older"}}
+		if _, err := pageService.RollbackPage(contributor, 1, 7, "anonymous"); !errors.Is(err, ErrPendingReview) {
+			t.Fatalf("expected ErrPendingReview for an untrusted rollback, got %v", err)
+		}
+		if len(queue.edits) != 3 || queue.edits[2].Content != "older" || pageRepo.pageToReturn.Content != "original" {
+			t.Fatalf("expected the rollback to be queued without changing the page, got %+v", queue.edits[2:])
+		}
+
+		if _, err := pageService.ApprovePendingEdit(moderator, queue.edits[2].ID); err != nil {
+			t.Fatalf("ApprovePendingEdit failed: %v", err)
+		}
+		if pageRepo.pageToReturn.Content != "older" {
+			t.Errorf("expected the approved rollback to be saved, got %q", pageRepo.pageToReturn.Content)
+		}
+	})
+	t.Run("new pages are created only when approved", func(t *testing.T) {
+		pageService, pageRepo, revisionRepo, queue := newService(t)
+
+		if _, err := pageService.CreatePage(contributor, "Spam", "buy now", "anonymous", "", ""); !errors.Is(err, ErrPendingReview) {
+			t.Fatalf("expected ErrPendingReview for an untrusted new page, got %v", err)
+		}
+		reviews, err := pageService.GetPendingEditReviews(moderator)
+		if err != nil || len(reviews) != 1 {
+			t.Fatalf("expected the new page in the review queue, got %+v (%v)", reviews, err)
+		}
+		if reviews[0].Page.Title != "Spam" || reviews[0].Page.ID != 0 || reviews[0].Added != 1 || reviews[0].Conflict {
+			t.Errorf("expected the new page to be compared with an empty page, got %+v", reviews[0])
+		}
+		if _, err := pageService.RejectPendingEdit(moderator, queue.edits[0].ID); err != nil {
+			t.Fatalf("RejectPendingEdit failed: %v", err)
+		}
+		if pageRepo.createPageCalled || len(revisionRepo.revisions) != 0 || len(pageRepo.recordedActivity) != 0 || len(queue.edits) != 0 {
+			t.Fatal("expected a rejected new page to leave nothing behind")
+		}
+
+		if _, err := pageService.CreatePage(contributor, "Draft", "proposed", "anonymous", "", ""); !errors.Is(err, ErrPendingReview) {
+			t.Fatalf("expected ErrPendingReview for an untrusted new page, got %v", err)
+		}
+		page, err := pageService.ApprovePendingEdit(moderator, queue.edits[0].ID)
+		if err != nil {
+			t.Fatalf("ApprovePendingEdit failed: %v", err)
+		}
+		if !pageRepo.createPageCalled || page.Title != "Draft" || page.Content != "proposed" || page.AuthorID != "anonymous" {
+			t.Errorf("expected the approved page to be created by its author, got %+v", page)
+		}
+		if len(queue.edits) != 0 {
+			t.Errorf("expected the approved page to leave the queue, got %d", len(queue.edits))
+		}
+	})
+}
+
+// blockingRenderRepository holds renders of pages with wiki links, which look
+// up the page titles, until release is closed, and counts how many it holds at once.
+type blockingRenderRepository struct {
+	*mockPageRepository
+	entered chan struct{}
+	release chan struct{}
+
+	mu        sync.Mutex
+	active    int
+	maxActive int
+}
+
+func (m *blockingRenderRepository) GetPageByTitle(ctx context.Context, title string) (*data.Page, error) {
+	return &data.Page{ID: 1, Title: title, Content: "See [[Other]]."}, nil
+}
+
+func (m *blockingRenderRepository) GetAllPages(ctx context.Context) ([]*data.Page, error) {
+	m.mu.Lock()
+	m.active++
+	m.maxActive = max(m.maxActive, m.active)
+	m.mu.Unlock()
+	m.entered <- struct{}{}
+	<-m.release
+	m.mu.Lock()
+	m.active--
+	m.mu.Unlock()
+	return nil, nil
+}
+
+func TestPageService_MaxConcurrentRenders(t *testing.T) {
+	newService := func(t *testing.T, limit int) (*PageService, *blockingRenderRepository) {
+		testCache, teardown := newTestCache(t)
+		t.Cleanup(teardown)
+		repo := &blockingRenderRepository{
+			mockPageRepository: &mockPageRepository{},
+			entered:            make(chan struct{}, 10),
+			release:            make(chan struct{}),
+		}
+		pageService := NewPageService(repo, &mockCategoryRepository{}, testCache,
+			WithMarkdownConfig(config.MarkdownConfig{MaxConcurrentRenders: limit, RenderQueueTimeoutSeconds: 5}),
+		)
+		return pageService, repo
+	}
+
+	t.Run("renders beyond the limit wait", func(t *testing.T) {
+		pageService, repo := newService(t, 2)
+
+		var wg sync.WaitGroup
+		errs := make(chan error, 5)
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := pageService.ViewPage(context.Background(), "Linked")
+				errs <- err
+			}()
+		}
+		<-repo.entered
+		<-repo.entered
+		select {
+		case <-repo.entered:
+			t.Fatal("expected a third render to wait for a free slot")
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		close(repo.release)
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			if err != nil {
+				t.Errorf("expected every render to finish once slots freed up, got %v", err)
+			}
+		}
+		if repo.maxActive != 2 {
+			t.Errorf("expected at most 2 renders at once, got %d", repo.maxActive)
+		}
+	})
+
+	t.Run("waiting too long is ErrRenderBusy", func(t *testing.T) {
+		pageService, repo := newService(t, 1)
+		pageService.renderQueueTimeout = 20 * time.Millisecond
+		defer close(repo.release)
+
+		go pageService.ViewPage(context.Background(), "Linked")
+		<-repo.entered
+		if _, err := pageService.ViewPage(context.Background(), "Linked"); !errors.Is(err, ErrRenderBusy) {
+			t.Errorf("expected ErrRenderBusy while the only slot is taken, got %v", err)
+		}
+	})
+
+	t.Run("no limit by default", func(t *testing.T) {
+		pageService, _ := newService(t, 0)
+		if pageService.renderSlots != nil {
+			t.Error("expected renders not to be limited without MaxConcurrentRenders")
+		}
+	})
+}
+
+type mockPageACLRepository struct {
+	roles map[int64]string
+}
+
+func (m *mockPageACLRepository) GetRequiredRole(ctx context.Context, pageID int64) (string, error) {
+	return m.roles[pageID], nil
+}
+
+func (m *mockPageACLRepository) GetRequiredRoles(ctx context.Context) (map[int64]string, error) {
+	roles := map[int64]string{}
+	for pageID, role := range m.roles {
+		if role != "" {
+			roles[pageID] = role
+		}
+	}
+	return roles, nil
+}
+
+func (m *mockPageACLRepository) SetRequiredRole(ctx context.Context, pageID int64, role string) error {
+	if m.roles == nil {
+		m.roles = map[int64]string{}
+	}
+	m.roles[pageID] = role
+	return nil
+}
+
+// mockRoleResolver grants each user their roles plus the roles those inherit.
+type mockRoleResolver struct {
+	inherits map[string][]string
+	users    map[string][]string
+}
+
+func (m *mockRoleResolver) GetImplicitRolesForUser(name string, domain ...string) ([]string, error) {
+	var roles []string
+	for _, role := range m.users[name] {
+		roles = append(roles, role)
+		roles = append(roles, m.inherits[role]...)
+	}
+	return roles, nil
+}
+
+func TestPageService_PageACLs(t *testing.T) {
+	testCache, teardown := newTestCache(t)
+	defer teardown()
+	pageRepo := &mockPageRepository{pageToReturn: &data.Page{ID: 1, Title: "Salaries", Content: "secret"}}
+	acls := &mockPageACLRepository{}
+	resolver := &mockRoleResolver{
+		inherits: map[string][]string{"admin": {"editor", "moderator"}},
+		users:    map[string][]string{"hr": {"hr"}, "boss": {"admin"}, "bob": {"editor"}},
+	}
+	pageService := NewPageService(pageRepo, &mockCategoryRepository{}, testCache, WithPageACLs(acls, resolver))
+	ctx := context.Background()
+
+	// Warm the cache to make sure restricting the page invalidates it.
+	if page, err := pageService.ViewPage(ctx, "Salaries"); err != nil || page.RequiredRole != "" {
+		t.Fatalf("expected an unrestricted page, got %+v (err %v)", page, err)
+	}
+	if err := pageService.SetPageRequiredRole(ctx, 1, "hr"); err != nil {
+		t.Fatalf("SetPageRequiredRole failed: %v", err)
+	}
+	page, err := pageService.ViewPage(ctx, "Salaries")
+	if err != nil {
+		t.Fatalf("ViewPage failed: %v", err)
+	}
+	if page.RequiredRole != "hr" {
+		t.Fatalf("expected the page to require the hr role, got %q", page.RequiredRole)
+	}
+
+	cases := []struct {
+		name     string
+		userInfo *middleware.UserInfo
+		want     bool
+	}{
+		{"holder of the role", &middleware.UserInfo{Subject: "hr", Roles: []string{"hr"}}, true},
+		{"admin", &middleware.UserInfo{Subject: "boss", Roles: []string{"admin"}}, true},
+		{"other role", &middleware.UserInfo{Subject: "bob", Roles: []string{"editor"}}, false},
+		{"anonymous", &middleware.UserInfo{Subject: "anonymous"}, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := pageService.CanView(ctx, page, tc.userInfo); got != tc.want {
+				t.Errorf("CanView = %v, want %v", got, tc.want)
+			}
+		})
+	}
+
+	t.Run("inherited role", func(t *testing.T) {
+		if err := pageService.SetPageRequiredRole(ctx, 1, "moderator"); err != nil {
+			t.Fatalf("SetPageRequiredRole failed: %v", err)
+		}
+		page, _ := pageService.ViewPage(ctx, "Salaries")
+		if !pageService.CanView(ctx, page, &middleware.UserInfo{Subject: "boss", Roles: []string{"admin"}}) {
+			t.Error("expected a role inherited through admin to grant access")
+		}
+		if pageService.CanView(ctx, page, &middleware.UserInfo{Subject: "hr", Roles: []string{"hr"}}) {
+			t.Error("expected a user without the moderator role to be denied")
+		}
+	})
+
+	t.Run("pages loaded without their role", func(t *testing.T) {
+		// Listings load pages without their role; the restriction still applies.
+		listed := &data.Page{ID: 1, Title: "Salaries"}
+		if pageService.CanView(ctx, listed, &middleware.UserInfo{Subject: "bob", Roles: []string{"editor"}}) {
+			t.Error("expected a restricted page from a listing to be hidden")
+		}
+		if !pageService.CanView(ctx, &data.Page{ID: 2, Title: "Lunch"}, &middleware.UserInfo{Subject: "anonymous"}) {
+			t.Error("expected an unrestricted page from a listing to be shown")
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		plain := NewPageService(pageRepo, &mockCategoryRepository{}, testCache)
+		if err := plain.SetPageRequiredRole(ctx, 1, "hr"); !errors.Is(err, ErrPageACLsDisabled) {
+			t.Errorf("expected ErrPageACLsDisabled, got %v", err)
+		}
+	})
+}
+
+func TestPageService_MergeCategories(t *testing.T) {
+	jsID, javascriptID, frameworksID := int64(1), int64(2), int64(3)
+	categories := []*data.Category{
+		{ID: jsID, Name: "JS"},
+		{ID: javascriptID, Name: "JavaScript"},
+		{ID: 3, Name: "Frameworks", ParentID: &jsID},
+		{ID: 4, Name: "Frameworks", ParentID: &javascriptID},
+		{ID: 5, Name: "Hooks", ParentID: &frameworksID},
+	}
+	newService := func(t *testing.T) (*PageService, *mockCategoryRepository, *cache.Cache) {
+		t.Helper()
+		testCache, teardown := newTestCache(t)
+		t.Cleanup(teardown)
+		pageRepo := &mockPageRepository{pagesByCategory: map[int64][]*data.Page{
+			jsID: {{Title: "Closures"}},
+			3:    {{Title: "React"}},
+			4:    {{Title: "Vue"}},
+		}}
+		categoryRepo := &mockCategoryRepository{
+			getByIDFunc: func(id int64) (*data.Category, error) {
+				for _, c := range categories {
+					if c.ID == id {
+						return c, nil
+					}
+				}
+				return nil, nil
+			},
+			getAllFunc: func() ([]*data.Category, error) { return categories, nil },
+		}
+		return NewPageService(pageRepo, categoryRepo, testCache), categoryRepo, testCache
+	}
+
+	t.Run("merges and invalidates the moved pages", func(t *testing.T) {
+		s, categoryRepo, testCache := newService(t)
+		var merged [2]int64
+		categoryRepo.mergeFunc = func(ctx context.Context, sourceID, targetID int64) error {
+			merged = [2]int64{sourceID, targetID}
+			return nil
+		}
+		for _, key := range []string{"page:Closures", "page:React", "page:Vue", "pages:all"} {
+			testCache.Set(key, []byte("cached"), time.Hour)
+		}
+
+		if err := s.MergeCategories(context.Background(), jsID, javascriptID); err != nil {
+			t.Fatalf("MergeCategories failed: %v", err)
+		}
+		if merged != [2]int64{jsID, javascriptID} {
+			t.Errorf("expected JS to be merged into JavaScript, got %v", merged)
+		}
+		for _, key := range []string{"page:Closures", "page:React", "pages:all"} {
+			if cached, _ := testCache.Get(key); cached != nil {
+				t.Errorf("expected %q to be invalidated", key)
+			}
+		}
+		if cached, _ := testCache.Get("page:Vue"); cached == nil {
+			t.Error("expected a page already in the target to stay cached")
+		}
+	})
+
+	t.Run("rejects invalid merges", func(t *testing.T) {
+		s, categoryRepo, _ := newService(t)
+		categoryRepo.mergeFunc = func(ctx context.Context, sourceID, targetID int64) error {
+			t.Fatal("expected no merge")
+			return nil
+		}
+		if err := s.MergeCategories(context.Background(), jsID, jsID); !errors.Is(err, ErrInvalidCategoryMerge) {
+			t.Errorf("expected ErrInvalidCategoryMerge for a self-merge, got %v", err)
+		}
+		if err := s.MergeCategories(context.Background(), 3, javascriptID); !errors.Is(err, ErrInvalidCategoryMerge) {
+			t.Errorf("expected ErrInvalidCategoryMerge across levels, got %v", err)
+		}
+		if err := s.MergeCategories(context.Background(), frameworksID, 5); !errors.Is(err, ErrInvalidCategoryMerge) {
+			t.Errorf("expected ErrInvalidCategoryMerge for a merge into a subcategory, got %v", err)
+		}
+		if err := s.MergeCategories(context.Background(), 99, javascriptID); !errors.Is(err, ErrCategoryNotFound) {
+			t.Errorf("expected ErrCategoryNotFound, got %v", err)
+		}
+	})
+}
+
+func TestCategoryDescendants_Cycle(t *testing.T) {
+	aID, bID := int64(1), int64(2)
+	categories := []*data.Category{
+		{ID: aID, Name: "A", ParentID: &bID},
+		{ID: bID, Name: "B", ParentID: &aID},
+	}
+	done := make(chan []int64)
+	go func() { done <- categoryDescendants(categories, aID) }()
+	select {
+	case ids := <-done:
+		if fmt.Sprint(ids) != "[1 2]" {
+			t.Errorf("expected each category once, got %v", ids)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("categoryDescendants did not return for a cycle")
+	}
+}
+
+// newCategoryStore returns a mock category repository backed by a slice, so
+// that categories saved by the service can be found again.
+func newCategoryStore() (*mockCategoryRepository, *[]*data.Category) {
+	categories := &[]*data.Category{}
+	repo := &mockCategoryRepository{
+		findByNameFunc: func(name string, parentID *int64) (*data.Category, error) {
+			for _, c := range *categories {
+				if c.Name == name && (c.ParentID == nil) == (parentID == nil) && (parentID == nil || *c.ParentID == *parentID) {
+					return c, nil
+				}
+			}
+			return nil, nil
+		},
+		saveFunc: func(category *data.Category) (int64, error) {
+			c := *category
+			c.ID = int64(len(*categories) + 1)
+			*categories = append(*categories, &c)
+			return c.ID, nil
+		},
+		getByIDFunc: func(id int64) (*data.Category, error) {
+			for _, c := range *categories {
+				if c.ID == id {
+					return c, nil
+				}
+			}
+			return nil, nil
+		},
+		getAllFunc: func() ([]*data.Category, error) { return *categories, nil },
+	}
+	return repo, categories
+}
+
+func TestPageService_NestedCategories(t *testing.T) {
+	t.Run("three-level path", func(t *testing.T) {
+		testCache, teardown := newTestCache(t)
+		defer teardown()
+		pageRepo := &mockPageRepository{}
+		categoryRepo, categories := newCategoryStore()
+		pageService := NewPageService(pageRepo, categoryRepo, testCache)
+		ctx := context.Background()
+
+		if _, err := pageService.CreatePage(ctx, "Entanglement", "content", "alice", "Science", "Physics/Quantum"); err != nil {
+			t.Fatalf("CreatePage failed: %v", err)
+		}
+		if len(*categories) != 3 {
+			t.Fatalf("expected Science, Physics and Quantum to be created, got %d categories", len(*categories))
+		}
+		// Creating another page on the same path reuses the categories.
+		if _, err := pageService.CreatePage(ctx, "Decoherence", "content", "alice", "Science/Physics", "Quantum"); err != nil {
+			t.Fatalf("CreatePage failed: %v", err)
+		}
+		if len(*categories) != 3 {
+			t.Errorf("expected the existing path to be reused, got %d categories", len(*categories))
+		}
+
+		page := &data.Page{CategoryID: pageRepo.lastPagePassed.CategoryID}
+		if err := pageService.populateCategoryNames(page); err != nil {
+			t.Fatalf("populateCategoryNames failed: %v", err)
+		}
+		if page.CategoryName != "Science" || page.SubcategoryName != "Physics/Quantum" {
+			t.Errorf("expected Science and Physics/Quantum, got %q and %q", page.CategoryName, page.SubcategoryName)
+		}
+		want := []data.CategoryCrumb{{Name: "Science", Path: "Science"}, {Name: "Physics", Path: "Science/Physics"}, {Name: "Quantum", Path: "Science/Physics/Quantum"}}
+		if fmt.Sprint(page.Breadcrumb) != fmt.Sprint(want) {
+			t.Errorf("expected breadcrumb %v, got %v", want, page.Breadcrumb)
+		}
+
+		tree, err := pageService.GetCategoryTree(ctx)
+		if err != nil {
+			t.Fatalf("GetCategoryTree failed: %v", err)
+		}
+		if len(tree) != 1 || len(tree[0].Children) != 1 || len(tree[0].Children[0].Children) != 1 ||
+			tree[0].Children[0].Children[0].Path != "Science/Physics/Quantum" {
+			t.Errorf("expected Science > Physics > Quantum, got %+v", tree)
+		}
+	})
+
+	t.Run("category moved mid-chain", func(t *testing.T) {
+		testCache, teardown := newTestCache(t)
+		defer teardown()
+		pageRepo := &mockPageRepository{}
+		categoryRepo, categories := newCategoryStore()
+		pageService := NewPageService(pageRepo, categoryRepo, testCache)
+		ctx := context.Background()
+
+		if _, err := pageService.CreatePage(ctx, "Entanglement", "content", "alice", "Science", "Physics/Quantum"); err != nil {
+			t.Fatalf("CreatePage failed: %v", err)
+		}
+		naturalID, _ := categoryRepo.Save(&data.Category{Name: "Natural Sciences"})
+		physics, _ := categoryRepo.FindByName("Physics", &(*categories)[0].ID)
+		physics.ParentID = &naturalID
+
+		page := &data.Page{CategoryID: pageRepo.lastPagePassed.CategoryID}
+		if err := pageService.populateCategoryNames(page); err != nil {
+			t.Fatalf("populateCategoryNames failed: %v", err)
+		}
+		if page.CategoryName != "Natural Sciences" || page.SubcategoryName != "Physics/Quantum" {
+			t.Errorf("expected the page to follow Physics to Natural Sciences, got %q and %q", page.CategoryName, page.SubcategoryName)
+		}
+		if len(page.Breadcrumb) != 3 || page.Breadcrumb[1].Path != "Natural Sciences/Physics" {
+			t.Errorf("expected the breadcrumb to go through Natural Sciences, got %v", page.Breadcrumb)
+		}
+
+		pages, err := pageService.GetPagesForSubcategory(ctx, "Science", "Physics/Quantum")
+		if !errors.Is(err, ErrCategoryNotFound) {
+			t.Errorf("expected the old path to be gone, got %v and %v", pages, err)
+		}
+	})
+}
+
+func TestPageService_SetExtraCategories(t *testing.T) {
+	testCache, teardown := newTestCache(t)
+	defer teardown()
+	pageRepo := &mockPageRepository{}
+	categoryRepo, categories := newCategoryStore()
+	categoryRepo.pageCategories = make(map[int64][]int64)
+	pageService := NewPageService(pageRepo, categoryRepo, testCache)
+	ctx := context.Background()
+
+	if _, err := pageService.CreatePage(ctx, "Entanglement", "content", "alice", "Science", "Physics"); err != nil {
+		t.Fatalf("CreatePage failed: %v", err)
+	}
+	page := pageRepo.lastPagePassed
+	page.ID = 7
+	pageRepo.pageToReturn = page
+	testCache.Set("page:Entanglement", []byte("cached"), time.Hour)
+
+	if err := pageService.SetExtraCategories(ctx, page.ID, []string{"Philosophy/Metaphysics", " "}); err != nil {
+		t.Fatalf("SetExtraCategories failed: %v", err)
+	}
+	if len(*categories) != 4 {
+		t.Fatalf("expected Philosophy and Metaphysics to be created, got %d categories", len(*categories))
+	}
+	if tags := categoryRepo.pageCategories[page.ID]; len(tags) != 2 || tags[0] != *page.CategoryID {
+		t.Errorf("expected the page's own category followed by Metaphysics, got %v", tags)
+	}
+	if cached, _ := testCache.Get("page:Entanglement"); cached != nil {
+		t.Error("expected the cached page to be invalidated")
+	}
+
+	if err := pageService.populateExtraCategories(ctx, page); err != nil {
+		t.Fatalf("populateExtraCategories failed: %v", err)
+	}
+	want := []data.CategoryCrumb{{Name: "Metaphysics", Path: "Philosophy/Metaphysics"}}
+	if fmt.Sprint(page.ExtraCategories) != fmt.Sprint(want) {
+		t.Errorf("expected extra categories %v, got %v", want, page.ExtraCategories)
+	}
+
+	// Moving the page to another category keeps its extra tag.
+	physicsID := *page.CategoryID
+	chemistryID, _ := categoryRepo.Save(&data.Category{Name: "Chemistry"})
+	if err := pageService.retagOwnCategory(ctx, page.ID, &physicsID, &chemistryID); err != nil {
+		t.Fatalf("retagOwnCategory failed: %v", err)
+	}
+	if tags := categoryRepo.pageCategories[page.ID]; len(tags) != 2 || tags[0] != chemistryID || tags[1] == physicsID {
+		t.Errorf("expected Chemistry and Metaphysics, got %v", tags)
+	}
+
+	// A page tagged with a category and its subcategory is listed once.
+	scienceID := (*categories)[0].ID
+	pageRepo.pagesByCategory = map[int64][]*data.Page{scienceID: {page}, physicsID: {page}}
+	pages, err := pageService.GetPagesForCategory(ctx, "Science")
+	if err != nil {
+		t.Fatalf("GetPagesForCategory failed: %v", err)
+	}
+	if len(pages) != 1 {
+		t.Errorf("expected the page to be listed once, got %d", len(pages))
+	}
+
+	// A top-level path tags the page with that category, not one below it.
+	before := len(*categories)
+	if err := pageService.SetExtraCategories(ctx, page.ID, []string{"Ops"}); err != nil {
+		t.Fatalf("SetExtraCategories failed: %v", err)
+	}
+	if len(*categories) != before+1 || (*categories)[before].Name != "Ops" || (*categories)[before].ParentID != nil {
+		t.Fatalf("expected only the Ops category to be created, got %d categories", len(*categories)-before)
+	}
+	if tags := categoryRepo.pageCategories[page.ID]; len(tags) != 2 || tags[1] != (*categories)[before].ID {
+		t.Errorf("expected the page to be tagged with Ops itself, got %v", tags)
+	}
+}
+
+func TestPageService_SearchCategoryIDs(t *testing.T) {
+	scienceID, physicsID, opsID := int64(1), int64(2), int64(4)
+	categoryRepo := &mockCategoryRepository{
+		getAllFunc: func() ([]*data.Category, error) {
+			return []*data.Category{
+				{ID: scienceID, Name: "Science"},
+				{ID: physicsID, Name: "Physics", ParentID: &scienceID},
+				{ID: 3, Name: "Quantum", ParentID: &physicsID},
+				{ID: opsID, Name: "Ops"},
+				{ID: 5, Name: "Physics", ParentID: &opsID},
+			}, nil
+		},
+	}
+	testCache, teardown := newTestCache(t)
+	defer teardown()
+	pageService := NewPageService(&mockPageRepository{}, categoryRepo, testCache)
+
+	tests := []struct {
+		category, subcategory string
+		want                  []int64
+	}{
+		{"Science", "", []int64{1, 2, 3}},
+		{"Science", "Physics", []int64{2, 3}},
+		{"Science/Physics", "Quantum", []int64{3}},
+		{"", "Physics", []int64{2, 3, 5}},
+		{"Science", "Chemistry", []int64{}},
+	}
+	for _, tt := range tests {
+		got, err := pageService.searchCategoryIDs(tt.category, tt.subcategory)
+		if err != nil {
+			t.Fatalf("searchCategoryIDs failed: %v", err)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%q / %q: want %v; got %v", tt.category, tt.subcategory, tt.want, got)
+		}
+	}
+}
+
+func TestPageService_RenameCategory(t *testing.T) {
+	testCache, teardown := newTestCache(t)
+	defer teardown()
+	pageRepo := &mockPageRepository{}
+	categoryRepo, categories := newCategoryStore()
+	pageService := NewPageService(pageRepo, categoryRepo, testCache)
+	ctx := context.Background()
+
+	if _, err := pageService.CreatePage(ctx, "Closures", "content", "alice", "JS", "Basics"); err != nil {
+		t.Fatalf("CreatePage failed: %v", err)
+	}
+	jsID := (*categories)[0].ID
+	basicsID := (*categories)[1].ID
+	pageRepo.pagesByCategory = map[int64][]*data.Page{basicsID: {{Title: "Closures"}}}
+	var renamed string
+	categoryRepo.renameFunc = func(ctx context.Context, id int64, newName string) error {
+		if id != jsID {
+			t.Errorf("expected category %d to be renamed, got %d", jsID, id)
+		}
+		renamed = newName
+		return nil
+	}
+	for _, key := range []string{"page:Closures", "pages:all"} {
+		testCache.Set(key, []byte("cached"), time.Hour)
+	}
+
+	if err := pageService.RenameCategory(ctx, jsID, "  JavaScript "); err != nil {
+		t.Fatalf("RenameCategory failed: %v", err)
+	}
+	if renamed != "JavaScript" {
+		t.Errorf("expected the trimmed name JavaScript, got %q", renamed)
+	}
+	// Pages in subcategories show the renamed category in their breadcrumb.
+	for _, key := range []string{"page:Closures", "pages:all"} {
+		if cached, _ := testCache.Get(key); cached != nil {
+			t.Errorf("expected %q to be invalidated", key)
+		}
+	}
+
+	for _, name := range []string{"", "  ", "Java/Script"} {
+		if err := pageService.RenameCategory(ctx, jsID, name); !errors.Is(err, ErrInvalidCategoryName) {
+			t.Errorf("RenameCategory(%q): expected ErrInvalidCategoryName, got %v", name, err)
+		}
+	}
+	if err := pageService.RenameCategory(ctx, 999, "Anything"); !errors.Is(err, ErrCategoryNotFound) {
+		t.Errorf("expected ErrCategoryNotFound, got %v", err)
+	}
+	categoryRepo.renameFunc = func(ctx context.Context, id int64, newName string) error {
+		return fmt.Errorf("%w: %q", data.ErrCategoryExists, newName)
+	}
+	if err := pageService.RenameCategory(ctx, basicsID, "Advanced"); !errors.Is(err, data.ErrCategoryExists) {
+		t.Errorf("expected data.ErrCategoryExists, got %v", err)
+	}
+}
+
+func TestPageService_DeleteCategory(t *testing.T) {
+	newService := func(t *testing.T) (*PageService, *mockCategoryRepository, *[]*data.Category, *cache.Cache) {
+		testCache, teardown := newTestCache(t)
+		t.Cleanup(teardown)
+		categoryRepo, categories := newCategoryStore()
+		pageRepo := &mockPageRepository{}
+		pageService := NewPageService(pageRepo, categoryRepo, testCache)
+		if _, err := pageService.CreatePage(context.Background(), "jQuery", "content", "alice", "JS", "Legacy"); err != nil {
+			t.Fatalf("CreatePage failed: %v", err)
+		}
+		if _, err := pageService.CreatePage(context.Background(), "React", "content", "alice", "JS", "Frameworks"); err != nil {
+			t.Fatalf("CreatePage failed: %v", err)
+		}
+		pageRepo.pagesByCategory = map[int64][]*data.Page{(*categories)[1].ID: {{Title: "jQuery"}}}
+		testCache.Set("page:jQuery", []byte("cached"), time.Hour)
+		return pageService, categoryRepo, categories, testCache
+	}
+	type deletion struct {
+		id, targetID int64
+		retag        bool
+	}
+	ctx := context.Background()
+
+	t.Run("reassign", func(t *testing.T) {
+		pageService, categoryRepo, categories, testCache := newService(t)
+		legacyID, frameworksID := (*categories)[1].ID, (*categories)[2].ID
+		var got deletion
+		categoryRepo.deleteFunc = func(ctx context.Context, id, targetID int64, retag bool) error {
+			got = deletion{id, targetID, retag}
+			return nil
+		}
+		if err := pageService.DeleteCategory(ctx, legacyID, &frameworksID); err != nil {
+			t.Fatalf("DeleteCategory failed: %v", err)
+		}
+		if want := (deletion{legacyID, frameworksID, true}); got != want {
+			t.Errorf("expected deletion %+v, got %+v", want, got)
+		}
+		if cached, _ := testCache.Get("page:jQuery"); cached != nil {
+			t.Error("expected the moved page to be invalidated")
+		}
+	})
+
+	t.Run("reassign to a top-level category", func(t *testing.T) {
+		pageService, categoryRepo, categories, _ := newService(t)
+		jsID, legacyID := (*categories)[0].ID, (*categories)[1].ID
+		var got deletion
+		categoryRepo.deleteFunc = func(ctx context.Context, id, targetID int64, retag bool) error {
+			got = deletion{id, targetID, retag}
+			return nil
+		}
+		if err := pageService.DeleteCategory(ctx, legacyID, &jsID); err != nil {
+			t.Fatalf("DeleteCategory failed: %v", err)
+		}
+		noSubcategory, _ := pageService.findCategoryPath([]string{"JS", "NoSubCategory"})
+		if noSubcategory == nil {
+			t.Fatal("expected JS/NoSubCategory to be created")
+		}
+		if want := (deletion{legacyID, noSubcategory.ID, true}); got != want {
+			t.Errorf("expected deletion %+v, got %+v", want, got)
+		}
+	})
+
+	t.Run("orphan", func(t *testing.T) {
+		pageService, categoryRepo, categories, _ := newService(t)
+		legacyID := (*categories)[1].ID
+		var got deletion
+		categoryRepo.deleteFunc = func(ctx context.Context, id, targetID int64, retag bool) error {
+			got = deletion{id, targetID, retag}
+			return nil
+		}
+		if err := pageService.DeleteCategory(ctx, legacyID, nil); err != nil {
+			t.Fatalf("DeleteCategory failed: %v", err)
+		}
+		defaultCategory, _ := pageService.findCategoryPath([]string{"NoCategory", "NoSubCategory"})
+		if defaultCategory == nil {
+			t.Fatal("expected the default category to be created")
+		}
+		if want := (deletion{legacyID, defaultCategory.ID, false}); got != want {
+			t.Errorf("expected deletion %+v, got %+v", want, got)
+		}
+	})
+
+	t.Run("blocked with subcategories", func(t *testing.T) {
+		pageService, categoryRepo, categories, _ := newService(t)
+		categoryRepo.deleteFunc = func(ctx context.Context, id, targetID int64, retag bool) error {
+			return fmt.Errorf("%w: category %d has 2", data.ErrCategoryHasSubcategories, id)
+		}
+		if err := pageService.DeleteCategory(ctx, (*categories)[0].ID, nil); !errors.Is(err, data.ErrCategoryHasSubcategories) {
+			t.Errorf("expected data.ErrCategoryHasSubcategories, got %v", err)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		pageService, _, categories, _ := newService(t)
+		legacyID, missingID := (*categories)[1].ID, int64(999)
+		if err := pageService.DeleteCategory(ctx, legacyID, &legacyID); !errors.Is(err, ErrInvalidCategoryDelete) {
+			t.Errorf("expected ErrInvalidCategoryDelete, got %v", err)
+		}
+		if err := pageService.DeleteCategory(ctx, legacyID, &missingID); !errors.Is(err, ErrCategoryNotFound) {
+			t.Errorf("expected ErrCategoryNotFound for the target, got %v", err)
+		}
+		if err := pageService.DeleteCategory(ctx, missingID, nil); !errors.Is(err, ErrCategoryNotFound) {
+			t.Errorf("expected ErrCategoryNotFound, got %v", err)
+		}
+	})
+}
+
+func TestPageService_UpdateCategoryOrder(t *testing.T) {
+	testCache, teardown := newTestCache(t)
+	defer teardown()
+	categoryRepo := &mockCategoryRepository{}
+	pageService := NewPageService(&mockPageRepository{}, categoryRepo, testCache)
+	testCache.Set("pages:all", []byte("cached"), time.Hour)
+
+	weights := map[int64]int{1: -10, 2: 20}
+	if err := pageService.UpdateCategoryOrder(context.Background(), weights); err != nil {
+		t.Fatalf("UpdateCategoryOrder failed: %v", err)
+	}
+	if fmt.Sprint(categoryRepo.weights) != fmt.Sprint(weights) {
+		t.Errorf("expected weights %v to be saved, got %v", weights, categoryRepo.weights)
+	}
+	if cached, _ := testCache.Get("pages:all"); cached != nil {
+		t.Error("expected the page list to be invalidated")
+	}
+}
+
+func TestPageService_MovePage(t *testing.T) {
+	testCache, teardown := newTestCache(t)
+	defer teardown()
+	pageRepo := &mockPageRepository{}
+	categoryRepo, categories := newCategoryStore()
+	categoryRepo.pageCategories = make(map[int64][]int64)
+	pageService := NewPageService(pageRepo, categoryRepo, testCache)
+	ctx := context.Background()
+
+	if _, err := pageService.CreatePage(ctx, "Entanglement", "content", "alice", "Science", "Physics"); err != nil {
+		t.Fatalf("CreatePage failed: %v", err)
+	}
+	page := pageRepo.lastPagePassed
+	page.ID = 7
+	pageRepo.pageToReturn = page
+	physicsID := *page.CategoryID
+	metaphysicsID, _ := categoryRepo.Save(&data.Category{Name: "Metaphysics"})
+	categoryRepo.pageCategories[page.ID] = []int64{physicsID, metaphysicsID}
+	testCache.Set("page:Entanglement", []byte("cached"), time.Hour)
+	testCache.Set("pages:all", []byte("cached"), time.Hour)
+	pageRepo.updatePageCalled = false
+
+	if err := pageService.MovePage(ctx, "Entanglement", "Science/Quantum", ""); err != nil {
+		t.Fatalf("MovePage failed: %v", err)
+	}
+	if page.CategoryID == nil || *page.CategoryID == physicsID {
+		t.Fatalf("expected the page to move out of Physics, got %v", page.CategoryID)
+	}
+	if pageRepo.updatePageCalled || page.Content != "content" {
+		t.Error("expected the page content to be left alone")
+	}
+	if tags := categoryRepo.pageCategories[page.ID]; len(tags) != 2 || tags[0] != *page.CategoryID || tags[1] != metaphysicsID {
+		t.Errorf("expected Quantum and Metaphysics, got %v", tags)
+	}
+	for _, key := range []string{"page:Entanglement", "pages:all"} {
+		if cached, _ := testCache.Get(key); cached != nil {
+			t.Errorf("expected %s to be invalidated", key)
+		}
+	}
+
+	if err := pageService.MovePage(ctx, "Nowhere", "Science", ""); err == nil {
+		t.Error("expected an error moving a missing page")
+	}
+
+	// A top-level category is filed under its NoSubCategory, as on the edit
+	// form, so saving the page with the names it shows keeps it there.
+	if err := pageService.MovePage(ctx, "Entanglement", "Science", ""); err != nil {
+		t.Fatalf("MovePage failed: %v", err)
+	}
+	science, _ := pageService.findCategoryPath([]string{"Science", "NoSubCategory"})
+	if science == nil || page.CategoryID == nil || *page.CategoryID != science.ID {
+		t.Fatalf("expected the page to be filed under Science/NoSubCategory, got %v", page.CategoryID)
+	}
+	if err := pageService.populateCategoryNames(page); err != nil {
+		t.Fatalf("populateCategoryNames failed: %v", err)
+	}
+	before := len(*categories)
+	saved, err := pageService.UpdatePage(ctx, page.ID, page.Title, "edited", page.CategoryName, page.SubcategoryName, false)
+	if err != nil {
+		t.Fatalf("UpdatePage failed: %v", err)
+	}
+	if saved.CategoryID == nil || *saved.CategoryID != science.ID {
+		t.Errorf("expected the edit to keep the page under Science, got %v (%s/%s)", saved.CategoryID, page.CategoryName, page.SubcategoryName)
+	}
+	if len(*categories) != before {
+		t.Errorf("expected no category to be created by the edit, got %d categories", len(*categories)-before)
+	}
+}
+
+func TestPageService_AppendEntry(t *testing.T) {
+	testCache, teardown := newTestCache(t)
+	defer teardown()
+	pageRepo := &mockPageRepository{}
+	categoryRepo, categories := newCategoryStore()
+	pageService := NewPageService(pageRepo, categoryRepo, testCache)
+	ctx := context.Background()
+	date := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	page, created, err := pageService.AppendEntry(ctx, " Incidents ", date, "Database failover.\n", "bot")
+	if err != nil || !created {
+		t.Fatalf("expected the first entry to create the page, got created=%v, err=%v", created, err)
+	}
+	if page.Title != "Incidents 2024-06-01" || page.Content != "Database failover." {
+		t.Errorf("unexpected page %q with content %q", page.Title, page.Content)
+	}
+	if len(*categories) != 2 || (*categories)[0].Name != "Incidents" || (*categories)[1].Name != "2024" {
+		t.Errorf("expected the page to be filed under Incidents/2024, got %v", *categories)
+	}
+
+	page.ID = 3
+	pageRepo.pageToReturn = page
+	pageRepo.createPageCalled = false
+	page, created, err = pageService.AppendEntry(ctx, "Incidents", date, "Cache restarted.", "bot")
+	if err != nil || created {
+		t.Fatalf("expected the second entry to be appended, got created=%v, err=%v", created, err)
+	}
+	if pageRepo.createPageCalled {
+		t.Error("expected no second page to be created")
+	}
+	if want := "Database failover.\n\n---\n\nCache restarted."; page.Content != want {
+		t.Errorf("expected content %q, got %q", want, page.Content)
+	}
+
+	for _, collection := range []string{"", "Ops/Incidents"} {
+		if _, _, err := pageService.AppendEntry(ctx, collection, date, "text", "bot"); !errors.Is(err, ErrInvalidEntry) {
+			t.Errorf("collection %q: expected ErrInvalidEntry, got %v", collection, err)
+		}
+	}
+}
+
+// racingCreateRepository fails every page creation as if another writer had
+// created the page first, which later lookups then find.
+type racingCreateRepository struct {
+	*mockPageRepository
+	existing *data.Page
+}
+
+func (m *racingCreateRepository) CreatePage(ctx context.Context, page *data.Page) error {
+	m.pageToReturn = m.existing
+	return errors.New("UNIQUE constraint failed: pages.title")
+}
+
+func TestPageService_AppendEntry_Conflicts(t *testing.T) {
+	ctx := context.Background()
+	date := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("page created concurrently", func(t *testing.T) {
+		testCache, teardown := newTestCache(t)
+		defer teardown()
+		pageRepo := &racingCreateRepository{
+			mockPageRepository: &mockPageRepository{},
+			existing:           &data.Page{ID: 3, Title: "Incidents 2024-06-01", Content: "Database failover."},
+		}
+		categoryRepo, _ := newCategoryStore()
+		pageService := NewPageService(pageRepo, categoryRepo, testCache)
+
+		page, created, err := pageService.AppendEntry(ctx, "Incidents", date, "Cache restarted.", "bot")
+		if err != nil || created {
+			t.Fatalf("expected the entry to be appended to the new page, got created=%v, err=%v", created, err)
+		}
+		if want := "Database failover.\n\n---\n\nCache restarted."; page.Content != want {
+			t.Errorf("expected content %q, got %q", want, page.Content)
+		}
+	})
+
+	t.Run("lookup failure", func(t *testing.T) {
+		testCache, teardown := newTestCache(t)
+		defer teardown()
+		dbErr := errors.New("database is locked")
+		pageRepo := &mockPageRepository{errToReturn: dbErr}
+		categoryRepo, _ := newCategoryStore()
+		pageService := NewPageService(pageRepo, categoryRepo, testCache)
+
+		if _, _, err := pageService.AppendEntry(ctx, "Incidents", date, "Cache restarted.", "bot"); !errors.Is(err, dbErr) {
+			t.Errorf("expected the lookup error, got %v", err)
+		}
+		if pageRepo.createPageCalled {
+			t.Error("expected no page to be created when the lookup fails")
+		}
+	})
+}
+
+func TestPageService_AppendEntry_Review(t *testing.T) {
+	testCache, teardown := newTestCache(t)
+	defer teardown()
+	pageRepo := &mockPageRepository{}
+	categoryRepo, _ := newCategoryStore()
+	queue := &mockPendingEditRepository{}
+	pageService := NewPageService(pageRepo, categoryRepo, testCache,
+		WithModeration(queue, config.ModerationConfig{RequireReview: true, TrustedRoles: []string{"admin"}}),
+	)
+	bot := middleware.SetUserInfo(context.Background(), &middleware.UserInfo{Subject: "bot"})
+	moderator := middleware.SetUserInfo(context.Background(), &middleware.UserInfo{Subject: "mod", Roles: []string{"admin"}})
+	date := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, body := range []string{"Database failover.", "Cache restarted."} {
+		if _, _, err := pageService.AppendEntry(bot, "Incidents", date, body, "bot"); !errors.Is(err, ErrPendingReview) {
+			t.Fatalf("expected ErrPendingReview for an untrusted entry, got %v", err)
+		}
+	}
+	if pageRepo.createPageCalled || len(queue.edits) != 2 || !queue.edits[0].Entry || queue.edits[1].Content != "Cache restarted." {
+		t.Fatalf("expected both entries to be queued on their own, got %+v", queue.edits)
+	}
+
+	ids := []int64{queue.edits[0].ID, queue.edits[1].ID}
+	page, err := pageService.ApprovePendingEdit(moderator, ids[0])
+	if err != nil {
+		t.Fatalf("ApprovePendingEdit failed: %v", err)
+	}
+	if page.AuthorID != "bot" || page.Content != "Database failover." {
+		t.Errorf("expected the first entry to create the page as its author, got %+v", page)
+	}
+	page.ID = 3
+	pageRepo.pageToReturn = page
+	reviews, _ := pageService.GetPendingEditReviews(moderator)
+	if len(reviews) != 1 || reviews[0].Conflict || reviews[0].Removed != 0 {
+		t.Fatalf("expected the second entry to add to the page without a conflict, got %+v", reviews)
+	}
+	page, err = pageService.ApprovePendingEdit(moderator, ids[1])
+	if err != nil {
+		t.Fatalf("ApprovePendingEdit failed: %v", err)
+	}
+	if want := "Database failover.\n\n---\n\nCache restarted."; page.Content != want {
+		t.Errorf("expected both approved entries to be kept, got %q", page.Content)
+	}
+}
+
+func TestCountWords(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    int
+	}{
+		{"empty", "", 0},
+		{"whitespace only", "  \n\n\t", 0},
+		{"code blocks are skipped", "Install it:\n\n```bash\ngo install ./cmd/wiki\n```\n\n~~~\nmore code here\n~~~\nThen run it.", 5},
+		{"unterminated code block", "Intro text.\n```\nall code", 2},
+		{"html is stripped", "A <strong>bold</strong> <!-- hidden\ncomment --> claim.", 3},
+		{"multiple paragraphs", "# Heading\n\nFirst paragraph has five words.\n\n- one item\n- two items\n\nLast one.", 12},
+		{"longer fences need a matching close", "Before.\n\n````markdown\n```\nnot closed yet\n```\n````\nAfter.", 2},
+		{"fences close only with their own character", "Before.\n\n~~~\n```\nstill code\n~~~~\nAfter.", 2},
+		{"indented code is skipped", "Run this:\n\n    go install ./cmd/wiki\n\tgo test ./...\n\nDone.", 3},
+		{"indented lines continue paragraphs and lists", "A paragraph\n    continues here.\n\n- item\n\n    more of the item", 9},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := countWords(tt.content); got != tt.want {
+				t.Errorf("countWords(%q) = %d, want %d", tt.content, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPageService_ViewPage_ReadingTime(t *testing.T) {
+	testCache, teardown := newTestCache(t)
+	defer teardown()
+	content := strings.Repeat("word ", 201) + "\n\n```\n" + strings.Repeat("code ", 500) + "\n```"
+	mockPageRepo := &mockPageRepository{pageToReturn: &data.Page{ID: 1, Title: "Essay", Content: content}}
+	pageService := NewPageService(mockPageRepo, &mockCategoryRepository{}, testCache)
+
+	// The second view is served from the cache, which does not keep the counts.
+	for _, source := range []string{"repository", "cache"} {
+		page, err := pageService.ViewPage(context.Background(), "Essay")
+		if err != nil {
+			t.Fatalf("%s: ViewPage failed: %v", source, err)
+		}
+		if page.WordCount != 201 || page.ReadingMinutes != 2 {
+			t.Errorf("%s: expected 201 words and 2 minutes, got %d words and %d minutes", source, page.WordCount, page.ReadingMinutes)
+		}
+	}
+
+	mockPageRepo.pageToReturn = &data.Page{ID: 2, Title: "Blank"}
+	page, err := pageService.ViewPage(context.Background(), "Blank")
+	if err != nil {
+		t.Fatalf("ViewPage failed: %v", err)
+	}
+	if page.WordCount != 0 || page.ReadingMinutes != 0 {
+		t.Errorf("expected no words or reading time for an empty page, got %d and %d", page.WordCount, page.ReadingMinutes)
+	}
+}
+
+func TestPageService_DiffRevisions(t *testing.T) {
+	ctx := context.Background()
+	revisionRepo := &mockRevisionRepository{}
+	revisionRepo.CreateRevision(ctx, &data.Revision{PageID: 1, Content: "intro\nold\noutro"})
+	revisionRepo.CreateRevision(ctx, &data.Revision{PageID: 1, Content: "intro\nold\noutro"})
+	revisionRepo.CreateRevision(ctx, &data.Revision{PageID: 1, Content: "intro\nnew\noutro\nappendix"})
+	revisionRepo.CreateRevision(ctx, &data.Revision{PageID: 2, Content: "other page"})
+	mockPageRepo := &mockPageRepository{pageToReturn: &data.Page{ID: 1, Title: "Diffed", Content: "intro\nnew\noutro\nappendix"}}
+	pageService := NewPageService(mockPageRepo, &mockCategoryRepository{}, nil, WithRevisions(revisionRepo))
+
+	t.Run("identical content", func(t *testing.T) {
+		result, err := pageService.DiffRevisions(ctx, 1, 1, 2)
+		if err != nil {
+			t.Fatalf("DiffRevisions failed: %v", err)
+		}
+		if result.HasChanges() {
+			t.Errorf("expected no changes between identical revisions, got %v", result.Lines)
+		}
+		// The newest revision matches the current content.
+		if result, _ = pageService.DiffRevisions(ctx, 1, 3, 0); result == nil || result.HasChanges() || result.To != nil {
+			t.Errorf("expected no changes against the current content, got %+v", result)
+		}
+	})
+
+	t.Run("insertions and deletions", func(t *testing.T) {
+		result, err := pageService.DiffRevisions(ctx, 1, 1, 3)
+		if err != nil {
+			t.Fatalf("DiffRevisions failed: %v", err)
+		}
+		if result.From.ID != 1 || result.To == nil || result.To.ID != 3 {
+			t.Errorf("expected revision 1 to be compared with revision 3, got %+v", result)
+		}
+		want := []diff.Line{
+			{Op: diff.Equal, Text: "intro"},
+			{Op: diff.Delete, Text: "old"},
+			{Op: diff.Insert, Text: "new"},
+			{Op: diff.Equal, Text: "outro"},
+			{Op: diff.Insert, Text: "appendix"},
+		}
+		if fmt.Sprint(result.Lines) != fmt.Sprint(want) {
+			t.Errorf("expected lines %v, got %v", want, result.Lines)
+		}
+
+		// Comparing backwards reverses the changes.
+		result, err = pageService.DiffRevisions(ctx, 1, 3, 1)
+		if err != nil {
+			t.Fatalf("DiffRevisions failed: %v", err)
+		}
+		if last := result.Lines[len(result.Lines)-1]; last.Op != diff.Delete || last.Text != "appendix" {
+			t.Errorf("expected the appendix to be deleted, got %v", result.Lines)
+		}
+	})
+
+	t.Run("revisions must belong to the page", func(t *testing.T) {
+		for _, ids := range [][2]int64{{4, 1}, {1, 4}, {1, 42}} {
+			if _, err := pageService.DiffRevisions(ctx, 1, ids[0], ids[1]); !errors.Is(err, ErrRevisionNotFound) {
+				t.Errorf("revisions %v: expected ErrRevisionNotFound, got %v", ids, err)
+			}
+		}
+	})
+}
+
+func TestPageService_ResanitizeAll(t *testing.T) {
+	testCache, teardown := newTestCache(t)
+	defer teardown()
+
+	// Markdown that the HTML sanitizer would mangle must be left as written.
+	markdown := "Check that `a < b` holds, see <https://example.com>.\n\n```\n<script>kept as code</script>\n```\n"
+	mockPageRepo := &mockPageRepository{
+		pagesToReturn: []*data.Page{
+			{ID: 1, Title: "Markdown", Content: markdown},
+			{ID: 2, Title: "Unsafe", Content: "Hello <span onclick=\"steal()\">there</span>.\n\n<script>alert(1)</script>\n\nBye"},
+		},
+	}
+	pageService := NewPageService(mockPageRepo, &mockCategoryRepository{}, testCache)
+
+	changed, err := pageService.ResanitizeAll(context.Background())
+	if err != nil {
+		t.Fatalf("ResanitizeAll failed: %v", err)
+	}
+	if changed != 1 {
+		t.Errorf("expected 1 page to change, got %d", changed)
+	}
+	if mockPageRepo.pagesToReturn[0].Content != markdown {
+		t.Errorf("expected markdown to be preserved, got %q", mockPageRepo.pagesToReturn[0].Content)
+	}
+	if want := "Hello <span>there</span>.\n\n\n\nBye"; mockPageRepo.lastPagePassed.Content != want {
+		t.Errorf("expected the unsafe HTML to be stripped, got %q", mockPageRepo.lastPagePassed.Content)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := pageService.ResanitizeAll(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected a cancelled task to stop, got %v", err)
+	}
+}
+
+func TestPageService_PagesLastModified(t *testing.T) {
+	testCache, teardown := newTestCache(t)
+	defer teardown()
+
+	updatedAt := time.Now().Add(-time.Hour)
+	mockPageRepo := &mockPageRepository{pagesToReturn: []*data.Page{{ID: 1, Title: "Guide", UpdatedAt: updatedAt}}}
+	pageService := NewPageService(mockPageRepo, &mockCategoryRepository{}, testCache)
+	ctx := context.Background()
+
+	first, count, err := pageService.PagesLastModified(ctx)
+	if err != nil || count != 1 {
+		t.Fatalf("PagesLastModified failed: %v (count %d)", err, count)
+	}
+	if again, _, _ := pageService.PagesLastModified(ctx); !again.Equal(first) {
+		t.Errorf("expected the same time while nothing changed, got %v then %v", first, again)
+	}
+
+	// Reordering categories updates no page, but changes the page list.
+	if err := pageService.UpdateCategoryOrder(ctx, map[int64]int{1: 2}); err != nil {
+		t.Fatalf("UpdateCategoryOrder failed: %v", err)
+	}
+	reordered, _, _ := pageService.PagesLastModified(ctx)
+	if !reordered.After(first) {
+		t.Errorf("expected a category change to move the time on, got %v then %v", first, reordered)
+	}
+
+	// So does a page passing its expiry date.
+	expiresAt := time.Now()
+	mockPageRepo.pagesToReturn = append(mockPageRepo.pagesToReturn, &data.Page{ID: 2, Title: "Old", UpdatedAt: updatedAt, ExpiresAt: &expiresAt})
+	archived, _, _ := pageService.PagesLastModified(ctx)
+	if !archived.Equal(expiresAt) {
+		t.Errorf("expected the archiving time %v, got %v", expiresAt, archived)
+	}
+}
+
+func TestPageService_TrustedHTMLKeptOnlyForAdminChanges(t *testing.T) {
+	testCache, teardown := newTestCache(t)
+	defer teardown()
+
+	mockPageRepo := &mockPageRepository{}
+	revisions := &mockRevisionRepository{}
+	pageService := NewPageService(mockPageRepo, &mockCategoryRepository{}, testCache, WithRevisions(revisions),
+		WithMarkdownConfig(config.MarkdownConfig{TrustedHTMLElements: []string{"iframe"}, TrustedHTMLAttributes: []string{"src"}}))
+	admin := middleware.SetUserInfo(context.Background(), &middleware.UserInfo{Subject: "root", Roles: []string{"admin"}})
+	editor := middleware.SetUserInfo(context.Background(), &middleware.UserInfo{Subject: "bob", Roles: []string{"editor"}})
+
+	page, err := pageService.CreatePage(admin, "Status", "<iframe src=\"https://status.example.com\"></iframe>", "root", "", "")
+	if err != nil {
+		t.Fatalf("CreatePage failed: %v", err)
+	}
+	mockPageRepo.pageToReturn = page
+	trustedRevision := revisions.revisions[0]
+
+	steps := []struct {
+		name        string
+		save        func() (*data.Page, error)
+		wantTrusted bool
+	}{
+		{"minor edit by an editor", func() (*data.Page, error) {
+			return pageService.UpdatePage(editor, page.ID, "Status", page.Content+"\n\ntypo", "", "", true)
+		}, false},
+		{"admin edit keeping the editor's change", func() (*data.Page, error) {
+			return pageService.UpdatePage(admin, page.ID, "Status", page.Content+"\n\nmore", "", "", false)
+		}, false},
+		{"rollback to the admin's revision", func() (*data.Page, error) {
+			return pageService.RollbackPage(editor, page.ID, trustedRevision.ID, "bob")
+		}, true},
+		{"rollback by an admin to the editor's revision", func() (*data.Page, error) {
+			return pageService.RollbackPage(admin, page.ID, revisions.revisions[1].ID, "root")
+		}, false},
+	}
+	for _, step := range steps {
+		saved, err := step.save()
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if saved.TrustedHTML != step.wantTrusted {
+			t.Errorf("%s: expected the page to be trusted: %v, got %v", step.name, step.wantTrusted, saved.TrustedHTML)
+		}
+		if latest := revisions.revisions[len(revisions.revisions)-1]; latest.TrustedHTML != step.wantTrusted {
+			t.Errorf("%s: expected the revision to record trust %v, got %v", step.name, step.wantTrusted, latest.TrustedHTML)
+		}
+	}
+}
+
+func TestPageService_RawHTMLBlocksFollowRevisionTrust(t *testing.T) {
+	testCache, teardown := newTestCache(t)
+	defer teardown()
+
+	mockPageRepo := &mockPageRepository{}
+	pageService := NewPageService(mockPageRepo, &mockCategoryRepository{}, testCache, WithMarkdownConfig(config.MarkdownConfig{
+		RawHTMLBlocks:     true,
+		RawHTMLElements:   []string{"iframe"},
+		RawHTMLAttributes: []string{"src"},
+	}))
+	admin := middleware.SetUserInfo(context.Background(), &middleware.UserInfo{Subject: "root", Roles: []string{"admin"}})
+	editor := middleware.SetUserInfo(context.Background(), &middleware.UserInfo{Subject: "bob", Roles: []string{"editor"}})
+	block := "```{=html}\n<iframe src=\"https://sso.example.com/status\"></iframe>\n```\n"
+
+	page, err := pageService.CreatePage(admin, "Status", block, "root", "", "")
+	if err != nil {
+		t.Fatalf("CreatePage failed: %v", err)
+	}
+	page.CategoryID = nil
+	mockPageRepo.pageToReturn = page
+	hasBlock := func() bool {
+		viewed, err := pageService.ViewPage(context.Background(), "Status")
+		if err != nil {
+			t.Fatalf("ViewPage failed: %v", err)
+		}
+		return strings.Contains(string(viewed.HTMLContent), `<iframe src="https://sso.example.com/status"></iframe>`)
+	}
+	if !hasBlock() {
+		t.Fatal("expected the admin's raw block to be kept")
+	}
+
+	// An editor's block must not survive because an admin saves the page after them.
+	if _, err := pageService.UpdatePage(editor, page.ID, "Status", block+"\n"+block, "", "", false); err != nil {
+		t.Fatalf("UpdatePage failed: %v", err)
+	}
+	if hasBlock() {
+		t.Error("expected the raw blocks to be dropped once an editor changed the page")
+	}
+	if _, err := pageService.UpdatePage(admin, page.ID, "Status", page.Content+"\nChecked.", "", "", true); err != nil {
+		t.Fatalf("UpdatePage failed: %v", err)
+	}
+	if hasBlock() {
+		t.Error("expected a later admin edit to keep the editor's blocks dropped")
+	}
+}
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-wiki-app/internal/data"
+	"go-wiki-app/internal/middleware"
+	"time"
+)
+
+// ErrQuotaExceeded is returned when a user has created too many pages within the quota window.
+var ErrQuotaExceeded = errors.New("page creation quota exceeded")
+
+// ErrWikiFull is returned when the wiki has reached its configured page or
+// storage cap. It wraps ErrQuotaExceeded.
+var ErrWikiFull = fmt.Errorf("%w: wiki page or storage limit reached", ErrQuotaExceeded)
+
+// contentUsageCacheKey caches the wiki's page count and content size.
+const contentUsageCacheKey = "content:usage"
+
+// isAdmin reports whether the current user holds the admin role.
+func isAdmin(ctx context.Context) bool {
+	for _, role := range middleware.GetUserInfo(ctx).Roles {
+		if role == "admin" {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *PageService) quotaWindow() time.Duration {
+	if s.content.CreateQuotaWindowMinutes <= 0 {
+		return time.Hour
+	}
+	return time.Duration(s.content.CreateQuotaWindowMinutes) * time.Minute
+}
+
+// quotaSubject returns the key a user's creations are counted under. Anonymous
+// users are told apart by their client address.
+func quotaSubject(ctx context.Context, subject string) string {
+	if userInfo := middleware.GetUserInfo(ctx); subject == "anonymous" && userInfo.IP != "" {
+		return "anonymous@" + userInfo.IP
+	}
+	return subject
+}
+
+// recentCreations returns the creation timestamps of the subject that fall within
+// the quota window. The timestamps are kept in the cache as a sliding window.
+func (s *PageService) recentCreations(subject string, now time.Time) []int64 {
+	var stamps []int64
+	if cached, _ := s.cache.Get("quota:create:" + subject); cached != nil {
+		_ = json.Unmarshal(cached, &stamps)
+	}
+	cutoff := now.Add(-s.quotaWindow()).UnixNano()
+	recent := stamps[:0]
+	for _, ts := range stamps {
+		if ts > cutoff {
+			recent = append(recent, ts)
+		}
+	}
+	return recent
+}
+
+// checkCreateQuota returns ErrQuotaExceeded if the subject may not create another page yet.
+func (s *PageService) checkCreateQuota(ctx context.Context, subject string) error {
+	if s.content.CreateQuota <= 0 || isAdmin(ctx) {
+		return nil
+	}
+	if len(s.recentCreations(quotaSubject(ctx, subject), time.Now())) >= s.content.CreateQuota {
+		return ErrQuotaExceeded
+	}
+	return nil
+}
+
+// recordCreation adds a page creation by the subject to its sliding window.
+func (s *PageService) recordCreation(ctx context.Context, subject string) {
+	if s.content.CreateQuota <= 0 || isAdmin(ctx) {
+		return
+	}
+	subject = quotaSubject(ctx, subject)
+	now := time.Now()
+	stamps := append(s.recentCreations(subject, now), now.UnixNano())
+	if b, err := json.Marshal(stamps); err == nil {
+		s.cache.Set("quota:create:"+subject, b, s.quotaWindow())
+	}
+}
+
+// usageRefresh returns how long cached content usage is trusted before recounting.
+func (s *PageService) usageRefresh() time.Duration {
+	if s.content.UsageRefreshSeconds <= 0 {
+		return time.Minute
+	}
+	return time.Duration(s.content.UsageRefreshSeconds) * time.Second
+}
+
+// contentUsage is the cached form of the wiki's content totals.
+type contentUsage struct {
+	data.ContentStats
+	CountedAt time.Time
+}
+
+// cachedUsage returns the cached content totals, if any.
+func (s *PageService) cachedUsage() (*contentUsage, bool) {
+	cached, _ := s.cache.Get(contentUsageCacheKey)
+	if cached == nil {
+		return nil, false
+	}
+	var usage contentUsage
+	if json.Unmarshal(cached, &usage) != nil {
+		return nil, false
+	}
+	return &usage, true
+}
+
+// storeUsage caches the totals until one refresh interval after they were counted.
+func (s *PageService) storeUsage(usage *contentUsage) {
+	ttl := s.usageRefresh() - time.Since(usage.CountedAt)
+	if ttl <= 0 {
+		return
+	}
+	if b, err := json.Marshal(usage); err == nil {
+		s.cache.Set(contentUsageCacheKey, b, ttl)
+	}
+}
+
+// contentUsage returns the wiki's page count and content size. The totals are
+// aggregated at most once per refresh interval and cached in between.
+func (s *PageService) contentUsage(ctx context.Context) (*data.ContentStats, error) {
+	if usage, ok := s.cachedUsage(); ok {
+		return &usage.ContentStats, nil
+	}
+	stats, err := s.repo.GetContentStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.storeUsage(&contentUsage{ContentStats: *stats, CountedAt: time.Now()})
+	return stats, nil
+}
+
+// checkSiteCaps returns ErrWikiFull if creating a page with the given content
+// would exceed the wiki's page or storage cap.
+func (s *PageService) checkSiteCaps(ctx context.Context, content string) error {
+	if (s.content.MaxPages <= 0 && s.content.MaxContentBytes <= 0) || isAdmin(ctx) {
+		return nil
+	}
+	stats, err := s.contentUsage(ctx)
+	if err != nil {
+		return err
+	}
+	if s.content.MaxPages > 0 && stats.PageCount >= s.content.MaxPages {
+		return ErrWikiFull
+	}
+	if s.content.MaxContentBytes > 0 && stats.ContentBytes+int64(len(content)) > s.content.MaxContentBytes {
+		return ErrWikiFull
+	}
+	return nil
+}
+
+// recordUsage adds a newly created page to the cached usage totals so the caps
+// stay accurate between refreshes without recounting.
+func (s *PageService) recordUsage(page *data.Page) {
+	if s.content.MaxPages <= 0 && s.content.MaxContentBytes <= 0 {
+		return
+	}
+	usage, ok := s.cachedUsage()
+	if !ok {
+		return
+	}
+	usage.PageCount++
+	usage.ContentBytes += int64(len(page.Content))
+	s.storeUsage(usage)
+}
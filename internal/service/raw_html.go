@@ -0,0 +1,100 @@
+package service
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"go-wiki-app/internal/data"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark/ast"
+)
+
+// rawHTMLInfo is the info string of a fenced block whose content is passed
+// through as HTML instead of being shown as code:
+//
+//	```{=html}
+//	<iframe src="https://status.example.com/widget"></iframe>
+//	```
+const rawHTMLInfo = "{=html}"
+
+// newRawHTMLSanitizer returns the policy raw HTML blocks are sanitized with.
+// Unlike the page policies it starts from nothing: only the configured
+// elements, with the configured attributes, and https URLs are kept. It also
+// returns the names it refused as unsafe.
+func newRawHTMLSanitizer(elements, attributes []string) (*bluemonday.Policy, []string) {
+	allowedElements, allowedAttributes, refused := filterAllowList(elements, attributes)
+	sanitizer := bluemonday.NewPolicy()
+	sanitizer.RequireParseableURLs(true)
+	sanitizer.AllowURLSchemes("https")
+	if len(allowedElements) > 0 {
+		sanitizer.AllowElements(allowedElements...)
+		if len(allowedAttributes) > 0 {
+			sanitizer.AllowAttrs(allowedAttributes...).OnElements(allowedElements...)
+		}
+	}
+	return sanitizer, refused
+}
+
+// rawHTMLBlocks holds the sanitized raw HTML blocks of a page being rendered,
+// keyed by the placeholder text that stands in for each of them.
+type rawHTMLBlocks map[string][]byte
+
+// extractRawHTMLBlocks takes the raw HTML blocks out of the parsed page. They
+// are kept only when raw HTML blocks are enabled and the page is trusted: every
+// change kept in its current revision was made by an admin (see updatePage), so
+// no block can come from anyone else. Each is sanitized with the raw HTML policy
+// and replaced by a placeholder paragraph, swapped back in by restore after the
+// page itself is sanitized. Otherwise the blocks are dropped.
+func (s *PageService) extractRawHTMLBlocks(page *data.Page, doc ast.Node, source []byte) rawHTMLBlocks {
+	var found []*ast.FencedCodeBlock
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if block, ok := n.(*ast.FencedCodeBlock); ok && entering && string(block.Language(source)) == rawHTMLInfo {
+			found = append(found, block)
+		}
+		return ast.WalkContinue, nil
+	})
+	if len(found) == 0 {
+		return nil
+	}
+
+	keep := s.rawHTMLSanitizer != nil && page.TrustedHTML
+	// The placeholders carry a random nonce so page content cannot forge one.
+	var nonce string
+	if keep {
+		b := make([]byte, 16)
+		if _, err := rand.Read(b); err != nil {
+			keep = false
+		}
+		nonce = hex.EncodeToString(b)
+	}
+	blocks := make(rawHTMLBlocks)
+	for i, block := range found {
+		parent := block.Parent()
+		if !keep {
+			parent.RemoveChild(parent, block)
+			continue
+		}
+		var raw bytes.Buffer
+		lines := block.Lines()
+		for j := 0; j < lines.Len(); j++ {
+			segment := lines.At(j)
+			raw.Write(segment.Value(source))
+		}
+		placeholder := fmt.Sprintf("rawhtml%s%d", nonce, i)
+		blocks[placeholder] = s.rawHTMLSanitizer.SanitizeBytes(raw.Bytes())
+		paragraph := ast.NewParagraph()
+		paragraph.AppendChild(paragraph, ast.NewString([]byte(placeholder)))
+		parent.ReplaceChild(parent, block, paragraph)
+	}
+	return blocks
+}
+
+// restore puts the sanitized raw HTML blocks in place of their placeholders.
+func (b rawHTMLBlocks) restore(html []byte) []byte {
+	for placeholder, raw := range b {
+		html = bytes.Replace(html, []byte("<p>"+placeholder+"</p>"), raw, 1)
+	}
+	return html
+}
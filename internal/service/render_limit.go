@@ -0,0 +1,54 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrRenderBusy is returned when a page could not be rendered because the
+// configured number of renders kept running for longer than the render queue
+// timeout. Callers should ask the client to retry later.
+var ErrRenderBusy = errors.New("too many pages are being rendered")
+
+// defaultRenderQueueTimeout is how long a render waits for a free slot when
+// no timeout is configured.
+const defaultRenderQueueTimeout = 5 * time.Second
+
+// configureRenderLimit sets up the render slots from the markdown settings.
+// Renders are not limited unless MaxConcurrentRenders is positive.
+func (s *PageService) configureRenderLimit() {
+	if s.markdownConfig.MaxConcurrentRenders <= 0 {
+		return
+	}
+	s.renderSlots = make(chan struct{}, s.markdownConfig.MaxConcurrentRenders)
+	s.renderQueueTimeout = time.Duration(s.markdownConfig.RenderQueueTimeoutSeconds) * time.Second
+	if s.renderQueueTimeout <= 0 {
+		s.renderQueueTimeout = defaultRenderQueueTimeout
+	}
+}
+
+// acquireRender waits for a render slot and returns the function that frees
+// it. It gives up with ErrRenderBusy after the render queue timeout, or with
+// the context's error if the request ends first.
+func (s *PageService) acquireRender(ctx context.Context) (func(), error) {
+	if s.renderSlots == nil {
+		return func() {}, nil
+	}
+	release := func() { <-s.renderSlots }
+	select {
+	case s.renderSlots <- struct{}{}:
+		return release, nil
+	default:
+	}
+	timer := time.NewTimer(s.renderQueueTimeout)
+	defer timer.Stop()
+	select {
+	case s.renderSlots <- struct{}{}:
+		return release, nil
+	case <-timer.C:
+		return nil, ErrRenderBusy
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
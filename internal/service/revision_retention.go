@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"go-wiki-app/internal/data"
+	"time"
+)
+
+// PruneRevisions applies the revision retention policy to every page and
+// returns the number of revisions deleted. A page's first revision and its
+// most recent revisions are always kept; the latest revision holds the page's
+// current content, so it is never deleted.
+func (s *PageService) PruneRevisions(ctx context.Context) (int, error) {
+	if s.revisions == nil || (s.retention.MaxPerPage <= 0 && s.retention.MaxAgeDays <= 0) {
+		return 0, nil
+	}
+	pageIDs, err := s.revisions.GetRevisionPageIDs(ctx)
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	pruned := 0
+	for _, pageID := range pageIDs {
+		revisions, err := s.revisions.GetRevisions(ctx, pageID)
+		if err != nil {
+			return pruned, err
+		}
+		n, err := s.revisions.DeleteRevisions(ctx, s.revisionsToPrune(revisions, now))
+		if err != nil {
+			return pruned, err
+		}
+		pruned += int(n)
+	}
+	return pruned, nil
+}
+
+// revisionsToPrune returns the IDs of the revisions, ordered newest first, that
+// fall outside the retention policy.
+func (s *PageService) revisionsToPrune(revisions []*data.Revision, now time.Time) []int64 {
+	keepRecent := max(s.retention.KeepRecent, 1)
+	var cutoff time.Time
+	if s.retention.MaxAgeDays > 0 {
+		cutoff = now.AddDate(0, 0, -s.retention.MaxAgeDays)
+	}
+
+	var ids []int64
+	for i, revision := range revisions {
+		first := i == len(revisions)-1
+		if i < keepRecent || first {
+			continue
+		}
+		// The first revision takes one of the MaxPerPage slots.
+		overCap := s.retention.MaxPerPage > 0 && i >= s.retention.MaxPerPage-1
+		tooOld := !cutoff.IsZero() && revision.CreatedAt.Before(cutoff)
+		if overCap || tooOld {
+			ids = append(ids, revision.ID)
+		}
+	}
+	return ids
+}
+
+// StartRevisionPruning applies the retention policy every interval until ctx is done.
+func (s *PageService) StartRevisionPruning(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				n, err := s.PruneRevisions(ctx)
+				if s.log == nil {
+					continue
+				}
+				if err != nil {
+					s.log.Error(err, "Failed to prune revisions")
+				} else if n > 0 {
+					s.log.Info(fmt.Sprintf("Pruned %d old revisions", n))
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"go-wiki-app/internal/data"
+	"go-wiki-app/internal/diff"
+	"go-wiki-app/internal/middleware"
+	"time"
+)
+
+// ErrRevisionNotFound is returned when a revision does not exist or belongs to another page.
+var ErrRevisionNotFound = errors.New("revision not found")
+
+// RevisionDiff is the line-level difference between a revision and a later
+// version of the same page.
+type RevisionDiff struct {
+	Page *data.Page
+	From *data.Revision
+	// To is the revision compared against, or nil for the page's current content.
+	To    *data.Revision
+	Lines []diff.Line
+}
+
+// RevisionRepository defines the interface for database operations on page revisions.
+type RevisionRepository interface {
+	CreateRevision(ctx context.Context, revision *data.Revision) error
+	UpdatePageWithRevision(ctx context.Context, page *data.Page, revision *data.Revision) error
+	GetRevision(ctx context.Context, id int64) (*data.Revision, error)
+	GetRevisions(ctx context.Context, pageID int64) ([]*data.Revision, error)
+	GetRevisionPageIDs(ctx context.Context) ([]int64, error)
+	GetBySubject(ctx context.Context, subject string) ([]*data.Revision, error)
+	DeleteRevisions(ctx context.Context, ids []int64) (int64, error)
+	GetRecentEdits(ctx context.Context, since time.Time, limit int) ([]*data.Revision, error)
+}
+
+// GetPageHistory retrieves a page and its revisions, newest first.
+// If revision history is not enabled, the page is returned with no revisions.
+func (s *PageService) GetPageHistory(ctx context.Context, title string) (*data.Page, []*data.Revision, error) {
+	page, err := s.repo.GetPageByTitle(ctx, title)
+	if err != nil {
+		return nil, nil, err
+	}
+	if s.revisions == nil {
+		return page, []*data.Revision{}, nil
+	}
+	revisions, err := s.revisions.GetRevisions(ctx, page.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return page, revisions, nil
+}
+
+// recordRevision stores a snapshot of the page as it was just saved.
+func (s *PageService) recordRevision(ctx context.Context, page *data.Page, authorID string, minor bool) error {
+	if s.revisions == nil {
+		return nil
+	}
+	return s.revisions.CreateRevision(ctx, newRevision(ctx, page, authorID, minor))
+}
+
+// savePage updates the page and, when revision history is enabled, records the
+// new content as a revision in the same transaction.
+func (s *PageService) savePage(ctx context.Context, page *data.Page, authorID string, minor bool) error {
+	if s.revisions == nil {
+		return s.repo.UpdatePage(ctx, page)
+	}
+	return s.revisions.UpdatePageWithRevision(ctx, page, newRevision(ctx, page, authorID, minor))
+}
+
+// newRevision snapshots the page's current content as a revision by authorID.
+func newRevision(ctx context.Context, page *data.Page, authorID string, minor bool) *data.Revision {
+	revision := &data.Revision{
+		PageID:      page.ID,
+		Title:       page.Title,
+		Content:     page.Content,
+		AuthorID:    authorID,
+		Minor:       minor,
+		TrustedHTML: page.TrustedHTML,
+	}
+	if authorID == "anonymous" {
+		revision.AuthorIP = middleware.GetUserInfo(ctx).IP
+	}
+	return revision
+}
+
+// DiffAgainstCurrent compares a revision of the page with the page's current content.
+func (s *PageService) DiffAgainstCurrent(ctx context.Context, pageID, revisionID int64) (*RevisionDiff, error) {
+	return s.DiffRevisions(ctx, pageID, revisionID, 0)
+}
+
+// DiffRevisions compares two revisions of the page, from fromID to toID. A
+// toID of zero stands for the page's current content. ErrRevisionNotFound is
+// returned if either revision is not one of the page's.
+func (s *PageService) DiffRevisions(ctx context.Context, pageID, fromID, toID int64) (*RevisionDiff, error) {
+	page, err := s.repo.GetPageByID(ctx, pageID)
+	if err != nil {
+		return nil, err
+	}
+	from, err := s.getPageRevision(ctx, pageID, fromID)
+	if err != nil {
+		return nil, err
+	}
+	result := &RevisionDiff{Page: page, From: from}
+	toContent := page.Content
+	if toID != 0 {
+		if result.To, err = s.getPageRevision(ctx, pageID, toID); err != nil {
+			return nil, err
+		}
+		toContent = result.To.Content
+	}
+	result.Lines = diff.Lines(from.Content, toContent)
+	return result, nil
+}
+
+// HasChanges reports whether any line differs between the compared versions.
+func (d *RevisionDiff) HasChanges() bool {
+	return diff.HasChanges(d.Lines)
+}
+
+// RollbackPage restores the content of an earlier revision of the page. The
+// restored content is saved as a new revision by authorID, so the revisions
+// made since are kept in the history. The page keeps its current title and
+// categories. ErrRevisionNotFound is returned if the revision is not one of the page's.
+// Like an edit, the rollback is queued when edits by the current user need review.
+func (s *PageService) RollbackPage(ctx context.Context, pageID, revisionID int64, authorID string) (*data.Page, error) {
+	target, err := s.getPageRevision(ctx, pageID, revisionID)
+	if err != nil {
+		return nil, err
+	}
+	page, err := s.repo.GetPageByID(ctx, pageID)
+	if err != nil {
+		return nil, err
+	}
+	_ = s.populateCategoryNames(page)
+	page, err = s.updatePage(ctx, pageID, page.Title, target.Content, page.CategoryName, page.SubcategoryName, authorID, false, target)
+	if err != nil {
+		return nil, err
+	}
+	s.recordActivity(ctx, page, data.ActivityRollback, false)
+	return page, nil
+}
+
+// getPageRevision loads a revision, checking that it belongs to the page.
+func (s *PageService) getPageRevision(ctx context.Context, pageID, revisionID int64) (*data.Revision, error) {
+	if s.revisions == nil {
+		return nil, ErrRevisionNotFound
+	}
+	revision, err := s.revisions.GetRevision(ctx, revisionID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRevisionNotFound
+		}
+		return nil, err
+	}
+	if revision.PageID != pageID {
+		return nil, ErrRevisionNotFound
+	}
+	return revision, nil
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"fmt"
+	"go-wiki-app/internal/data"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+)
+
+// unsafeTrustedElements can run script or change how the rest of the page is
+// loaded, so they are left out of the trusted allow-list whatever is configured.
+var unsafeTrustedElements = map[string]bool{
+	"script": true, "style": true, "base": true, "meta": true, "link": true,
+	"object": true, "embed": true, "applet": true, "frame": true, "frameset": true,
+	"form": true, "noscript": true, "template": true, "svg": true, "math": true,
+}
+
+// unsafeTrustedAttributes can run script or submit data elsewhere. Event
+// handlers, the attributes starting with "on", are refused as well.
+var unsafeTrustedAttributes = map[string]bool{
+	"style": true, "srcdoc": true, "formaction": true, "action": true,
+}
+
+// newSanitizer returns the policy every page's rendered HTML is sanitized with.
+func newSanitizer() *bluemonday.Policy {
+	sanitizer := bluemonday.UGCPolicy()
+	sanitizer.AllowImages()
+	// Keep heading ids so the table of contents and skip links can target them.
+	sanitizer.AllowAttrs("id").Matching(headingIDPattern).OnElements("h1", "h2", "h3", "h4", "h5", "h6")
+	// Keep the class that marks [[WikiLinks]] to pages that do not exist yet.
+	sanitizer.AllowAttrs("class").Matching(missingLinkClassPattern).OnElements("a")
+	// Keep the token classes of highlighted code blocks.
+	sanitizer.AllowAttrs("class").Matching(highlightClassPattern).OnElements("pre", "span")
+	return sanitizer
+}
+
+// newTrustedSanitizer extends the default policy with the elements and
+// attributes operators allow in pages saved by admins. It returns nil when
+// nothing is added, and the names it refused as unsafe.
+func newTrustedSanitizer(elements, attributes []string) (*bluemonday.Policy, []string) {
+	allowedElements, allowedAttributes, refused := filterAllowList(elements, attributes)
+	if len(allowedElements) == 0 && len(allowedAttributes) == 0 {
+		return nil, refused
+	}
+
+	sanitizer := newSanitizer()
+	if len(allowedElements) > 0 {
+		sanitizer.AllowElements(allowedElements...)
+	}
+	if len(allowedAttributes) > 0 {
+		sanitizer.AllowAttrs(allowedAttributes...).Globally()
+	}
+	return sanitizer, refused
+}
+
+// filterAllowList normalizes the configured element and attribute names,
+// setting aside the unsafe ones as refused.
+func filterAllowList(elements, attributes []string) (allowedElements, allowedAttributes, refused []string) {
+	for _, element := range elements {
+		element = strings.ToLower(strings.TrimSpace(element))
+		if element == "" {
+			continue
+		}
+		if unsafeTrustedElements[element] {
+			refused = append(refused, "<"+element+">")
+			continue
+		}
+		allowedElements = append(allowedElements, element)
+	}
+	for _, attribute := range attributes {
+		attribute = strings.ToLower(strings.TrimSpace(attribute))
+		if attribute == "" {
+			continue
+		}
+		if unsafeTrustedAttributes[attribute] || strings.HasPrefix(attribute, "on") {
+			refused = append(refused, attribute)
+			continue
+		}
+		allowedAttributes = append(allowedAttributes, attribute)
+	}
+	return allowedElements, allowedAttributes, refused
+}
+
+// renderingFor returns the markdown renderer and sanitizer policy for the page:
+// the trusted ones for pages last saved by an admin, the default ones otherwise.
+func (s *PageService) renderingFor(page *data.Page) (goldmark.Markdown, *bluemonday.Policy) {
+	if page.TrustedHTML && s.trustedSanitizer != nil {
+		return s.trustedMarkdown, s.trustedSanitizer
+	}
+	return s.markdown, s.sanitizer
+}
+
+// configureTrustedSanitizer builds the trusted policy from the markdown config,
+// warning about the elements and attributes it refuses.
+func (s *PageService) configureTrustedSanitizer() {
+	var refused []string
+	s.trustedSanitizer, refused = newTrustedSanitizer(s.markdownConfig.TrustedHTMLElements, s.markdownConfig.TrustedHTMLAttributes)
+	if len(refused) > 0 && s.log != nil {
+		s.log.Warn(fmt.Sprintf("Ignoring unsafe trusted HTML allow-list entries: %s", strings.Join(refused, ", ")))
+	}
+}
+
+// configureRawHTMLSanitizer builds the policy for raw HTML blocks when they are
+// enabled, warning about the elements and attributes it refuses.
+func (s *PageService) configureRawHTMLSanitizer() {
+	if !s.markdownConfig.RawHTMLBlocks {
+		return
+	}
+	var refused []string
+	s.rawHTMLSanitizer, refused = newRawHTMLSanitizer(s.markdownConfig.RawHTMLElements, s.markdownConfig.RawHTMLAttributes)
+	if len(refused) > 0 && s.log != nil {
+		s.log.Warn(fmt.Sprintf("Ignoring unsafe raw HTML allow-list entries: %s", strings.Join(refused, ", ")))
+	}
+}
@@ -0,0 +1,209 @@
+package service
+
+import (
+	"context"
+	"go-wiki-app/internal/data"
+	"html"
+	"html/template"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// searchCandidateLimit is how many matching pages are ranked when results are
+// sorted by relevance, before the requested limit is applied.
+const searchCandidateLimit = 500
+
+// Snippet sizes, in bytes of surrounding context, around the first match.
+const (
+	snippetBefore = 60
+	snippetAfter  = 140
+)
+
+// SearchResult is a page matching a search, with an excerpt around the first
+// match and its relevance score.
+type SearchResult struct {
+	*data.Page
+	// Snippet is HTML-escaped text with the matched terms wrapped in <mark>.
+	Snippet template.HTML
+	Score   float64
+}
+
+// SearchPages finds pages matching the search terms and filters. Results sorted
+// by relevance are ranked here rather than in SQL, so that MySQL and SQLite
+// order them the same way. The category filter matches pages filed under or
+// tagged with the category or any category below it.
+func (s *PageService) SearchPages(ctx context.Context, filter data.SearchFilter) ([]*SearchResult, error) {
+	terms := strings.Fields(strings.ToLower(filter.Query))
+	byRelevance := filter.Sort == data.SearchSortRelevance && len(terms) > 0
+
+	repoFilter := filter
+	if byRelevance {
+		repoFilter.Limit = searchCandidateLimit
+	}
+	if filter.Category != "" || filter.Subcategory != "" {
+		ids, err := s.searchCategoryIDs(filter.Category, filter.Subcategory)
+		if err != nil {
+			return nil, err
+		}
+		repoFilter.CategoryIDs = ids
+	}
+	pages, err := s.repo.SearchPages(ctx, repoFilter)
+	if err != nil {
+		return nil, err
+	}
+
+	pattern := termsPattern(terms)
+	results := make([]*SearchResult, 0, len(pages))
+	for _, page := range pages {
+		if err := s.populateCategoryNames(page); err != nil {
+			// Log error but continue
+		}
+		results = append(results, &SearchResult{
+			Page:    page,
+			Snippet: snippet(page.Content, pattern),
+			Score:   relevance(page, terms),
+		})
+	}
+	if byRelevance {
+		sort.SliceStable(results, func(i, j int) bool {
+			return results[i].Score > results[j].Score
+		})
+		if filter.Limit > 0 && len(results) > filter.Limit {
+			results = results[:filter.Limit]
+		}
+	}
+	return results, nil
+}
+
+// searchCategoryIDs returns the categories a search filtered by category
+// matches: those at the subcategory path below the top-level category, or
+// below any top-level category when none is given, and all their descendants.
+// The category may itself be a slash-delimited path.
+func (s *PageService) searchCategoryIDs(categoryName, subcategoryName string) ([]int64, error) {
+	all, err := s.categoryRepo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	names := splitCategoryPath(categoryName)
+	top := ""
+	if len(names) > 0 {
+		top, names = names[0], names[1:]
+	}
+	names = append(names, splitCategoryPath(subcategoryName)...)
+
+	ids := []int64{}
+	seen := make(map[int64]bool)
+	for _, c := range all {
+		if c.ParentID != nil || (top != "" && c.Name != top) {
+			continue
+		}
+		found := c
+		for _, name := range names {
+			found = childCategory(all, found.ID, name)
+			if found == nil {
+				break
+			}
+		}
+		if found == nil {
+			continue
+		}
+		for _, id := range categoryDescendants(all, found.ID) {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids, nil
+}
+
+// childCategory returns the category with the name directly below parentID, or nil.
+func childCategory(all []*data.Category, parentID int64, name string) *data.Category {
+	for _, c := range all {
+		if c.ParentID != nil && *c.ParentID == parentID && c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// relevance scores a page with a term-frequency heuristic. Each term found in
+// the title adds 1, while body occurrences add less than 1 in total, so any
+// title match outranks pages that only mention the terms in their body.
+func relevance(page *data.Page, terms []string) float64 {
+	title := strings.ToLower(page.Title)
+	content := strings.ToLower(page.Content)
+	var titleScore float64
+	contentHits := 0
+	for _, term := range terms {
+		if strings.Contains(title, term) {
+			titleScore++
+		}
+		contentHits += strings.Count(content, term)
+	}
+	return titleScore + 1 - 1/float64(1+contentHits)
+}
+
+// termsPattern builds a case-insensitive pattern matching any of the terms.
+func termsPattern(terms []string) *regexp.Regexp {
+	if len(terms) == 0 {
+		return nil
+	}
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		quoted[i] = regexp.QuoteMeta(term)
+	}
+	return regexp.MustCompile(`(?i)` + strings.Join(quoted, "|"))
+}
+
+// snippet returns an excerpt of the content around the first match of the
+// pattern, escaped for HTML, with every match in the excerpt wrapped in <mark>.
+// Without a match, the start of the content is used.
+func snippet(content string, pattern *regexp.Regexp) template.HTML {
+	content = strings.Join(strings.Fields(content), " ")
+	start, end := 0, snippetBefore+snippetAfter
+	if pattern != nil {
+		if loc := pattern.FindStringIndex(content); loc != nil {
+			start, end = loc[0]-snippetBefore, loc[1]+snippetAfter
+		}
+	}
+	start, end = clampToRunes(content, start, end)
+	excerpt := content[start:end]
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	last := 0
+	if pattern != nil {
+		for _, loc := range pattern.FindAllStringIndex(excerpt, -1) {
+			b.WriteString(html.EscapeString(excerpt[last:loc[0]]))
+			b.WriteString("<mark>" + html.EscapeString(excerpt[loc[0]:loc[1]]) + "</mark>")
+			last = loc[1]
+		}
+	}
+	b.WriteString(html.EscapeString(excerpt[last:]))
+	if end < len(content) {
+		b.WriteString("…")
+	}
+	return template.HTML(b.String())
+}
+
+// clampToRunes limits start and end to the string and moves them onto rune boundaries.
+func clampToRunes(s string, start, end int) (int, int) {
+	if start < 0 {
+		start = 0
+	}
+	if end > len(s) {
+		end = len(s)
+	}
+	for start > 0 && !utf8.RuneStart(s[start]) {
+		start--
+	}
+	for end < len(s) && !utf8.RuneStart(s[end]) {
+		end++
+	}
+	return start, end
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"go-wiki-app/internal/data"
+	"hash/fnv"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	// shingleSize is the number of consecutive words hashed together when fingerprinting content.
+	shingleSize = 3
+	// similarityThreshold is the Jaccard similarity above which two pages are considered near-duplicates.
+	similarityThreshold = 0.6
+	// maxSimilarPages caps the number of near-duplicates reported.
+	maxSimilarPages = 5
+	// similarityQueryTerms is the number of the content's longest words searched
+	// for to find candidate near-duplicates, one search each.
+	similarityQueryTerms = 3
+	// similarityCandidateLimit caps the pages each of those searches returns.
+	similarityCandidateLimit = 50
+)
+
+// fingerprint computes the set of hashed word shingles of the given text.
+// Text is lower-cased and stripped of punctuation first, so formatting-only
+// differences do not affect the result.
+func fingerprint(text string) map[uint64]struct{} {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	set := make(map[uint64]struct{})
+	if len(words) == 0 {
+		return set
+	}
+	if len(words) < shingleSize {
+		set[hashWords(words)] = struct{}{}
+		return set
+	}
+	for i := 0; i+shingleSize <= len(words); i++ {
+		set[hashWords(words[i:i+shingleSize])] = struct{}{}
+	}
+	return set
+}
+
+func hashWords(words []string) uint64 {
+	h := fnv.New64a()
+	for _, w := range words {
+		h.Write([]byte(w))
+		h.Write([]byte{0})
+	}
+	return h.Sum64()
+}
+
+// similarity returns the Jaccard similarity of two fingerprints, between 0 and 1.
+func similarity(a, b map[uint64]struct{}) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	shared := 0
+	for h := range a {
+		if _, ok := b[h]; ok {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+// similarityTerms picks the longest distinct words of the content, which a
+// near-duplicate is all but certain to share, longest first.
+func similarityTerms(content string) []string {
+	seen := make(map[string]bool)
+	var words []string
+	for _, word := range strings.FieldsFunc(strings.ToLower(content), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}) {
+		if !seen[word] {
+			seen[word] = true
+			words = append(words, word)
+		}
+	}
+	sort.SliceStable(words, func(i, j int) bool { return len([]rune(words[i])) > len([]rune(words[j])) })
+	if len(words) > similarityQueryTerms {
+		words = words[:similarityQueryTerms]
+	}
+	return words
+}
+
+// FindSimilarContent returns existing pages whose content is a near-duplicate of
+// the given content, most similar first. Only the pages found by searching for
+// the content's longest words are compared, rather than every page.
+func (s *PageService) FindSimilarContent(ctx context.Context, content string) ([]*data.Page, error) {
+	target := fingerprint(content)
+	if len(target) == 0 {
+		return []*data.Page{}, nil
+	}
+	var pages []*data.Page
+	candidates := make(map[int64]bool)
+	for _, term := range similarityTerms(content) {
+		found, err := s.repo.SearchPages(ctx, data.SearchFilter{Query: term, Limit: similarityCandidateLimit})
+		if err != nil {
+			return nil, err
+		}
+		for _, page := range found {
+			if !candidates[page.ID] {
+				candidates[page.ID] = true
+				pages = append(pages, page)
+			}
+		}
+	}
+
+	type match struct {
+		page  *data.Page
+		score float64
+	}
+	var matches []match
+	for _, page := range pages {
+		if score := similarity(target, fingerprint(page.Content)); score >= similarityThreshold {
+			matches = append(matches, match{page: page, score: score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].score > matches[j].score })
+
+	similar := make([]*data.Page, 0, len(matches))
+	for _, m := range matches {
+		if len(similar) == maxSimilarPages {
+			break
+		}
+		similar = append(similar, m.page)
+	}
+	return similar, nil
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Slugify converts a page title into a lower-case, hyphen-separated form that is
+// safe to use in file names and URLs, e.g. "Release Notes: 2.0" -> "release-notes-2-0".
+//
+// With transliterate set, the slug is restricted to ASCII: accents are stripped and
+// Cyrillic is romanized, so "Привет Мир" becomes "privet-mir". Titles that cannot be
+// transliterated (e.g. CJK) fall back to the hex code points of their letters.
+func Slugify(title string, transliterate bool) string {
+	slug := slugify(title, transliterate)
+	if slug == "" && transliterate {
+		slug = slugifyCodePoints(title)
+	}
+	if slug == "" {
+		return "page"
+	}
+	return slug
+}
+
+// slugify joins the runs of letters and digits in title with hyphens. When
+// transliterate is set, letters without an ASCII form are dropped.
+func slugify(title string, transliterate bool) string {
+	var b strings.Builder
+	pendingHyphen := false
+	for _, r := range strings.ToLower(title) {
+		if !unicode.IsLetter(r) && !unicode.IsNumber(r) {
+			pendingHyphen = true
+			continue
+		}
+		s := string(r)
+		if transliterate {
+			if s = transliterateRune(r); s == "" {
+				continue
+			}
+		}
+		if pendingHyphen && b.Len() > 0 {
+			b.WriteByte('-')
+		}
+		pendingHyphen = false
+		b.WriteString(s)
+	}
+	return b.String()
+}
+
+// slugifyCodePoints is the ASCII fallback for titles without any transliterable
+// letters: each letter or digit is written as its hex code point, e.g. "你好" -> "4f60-597d".
+func slugifyCodePoints(title string) string {
+	var parts []string
+	for _, r := range strings.ToLower(title) {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			parts = append(parts, fmt.Sprintf("%x", r))
+		}
+	}
+	return strings.Join(parts, "-")
+}
+
+// transliterateRune returns the lower-case ASCII form of r, or "" if it has none.
+func transliterateRune(r rune) string {
+	if r <= unicode.MaxASCII {
+		return string(r)
+	}
+	if s, ok := transliterations[r]; ok {
+		return s
+	}
+	// Decompose accented letters and keep the ASCII base, e.g. "é" -> "e".
+	var b strings.Builder
+	for _, d := range norm.NFD.String(string(r)) {
+		if d <= unicode.MaxASCII {
+			b.WriteRune(d)
+		}
+	}
+	return b.String()
+}
+
+// transliterations maps lower-case letters that have no ASCII decomposition to
+// their romanized form. Cyrillic follows the common passport-style romanization.
+var transliterations = map[rune]string{
+	// Latin letters that are not composed of a base letter and an accent.
+	'ß': "ss", 'æ': "ae", 'œ': "oe", 'ø': "o", 'ł': "l", 'đ': "d", 'ð': "d", 'þ': "th", 'ı': "i",
+
+	// Russian.
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "e", 'ж': "zh",
+	'з': "z", 'и': "i", 'й': "i", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o",
+	'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts",
+	'ч': "ch", 'ш': "sh", 'щ': "shch", 'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "iu",
+	'я': "ia",
+
+	// Ukrainian, Belarusian, Serbian and Macedonian additions.
+	'є': "ie", 'і': "i", 'ї': "i", 'ґ': "g", 'ў': "u", 'ђ': "dj", 'ј': "j", 'љ': "lj",
+	'њ': "nj", 'ћ': "c", 'џ': "dz", 'ѓ': "gj", 'ќ': "kj", 'ѕ': "dz",
+}
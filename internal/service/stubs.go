@@ -0,0 +1,32 @@
+package service
+
+import (
+	"context"
+	"go-wiki-app/internal/data"
+	"strings"
+)
+
+// isStub reports whether the page is too short to be considered complete.
+// Stub detection is disabled when no threshold is configured.
+func (s *PageService) isStub(page *data.Page) bool {
+	if s.content.StubWordThreshold <= 0 || page.ID == 0 {
+		return false
+	}
+	return len(strings.Fields(page.Content)) < s.content.StubWordThreshold
+}
+
+// GetStubs returns all pages whose word count is below the configured stub threshold.
+func (s *PageService) GetStubs(ctx context.Context) ([]*data.Page, error) {
+	pages, err := s.GetAllPages(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stubs := make([]*data.Page, 0)
+	for _, page := range pages {
+		if s.isStub(page) {
+			page.IsStub = true
+			stubs = append(stubs, page)
+		}
+	}
+	return stubs, nil
+}
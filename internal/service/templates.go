@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-wiki-app/internal/data"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// defaultTemplatePrefix marks the pages that serve as templates for new pages.
+const defaultTemplatePrefix = "Template:"
+
+var (
+	// ErrTemplateNotFound is returned when no template page has the requested name.
+	ErrTemplateNotFound = errors.New("template not found")
+	// ErrUnknownTemplateVariable is returned for variables other than those listed
+	// in templateVariables when strict templates are enabled.
+	ErrUnknownTemplateVariable = errors.New("unknown template variable")
+)
+
+// templateVariablePattern matches a template variable such as {{date}} or {{ title }}.
+var templateVariablePattern = regexp.MustCompile(`\{\{\s*([A-Za-z_]+)\s*\}\}`)
+
+// TemplateNames returns the names of the available page templates, sorted.
+// A template is a page whose title starts with the template prefix; its name is
+// the rest of the title.
+func (s *PageService) TemplateNames(ctx context.Context) ([]string, error) {
+	pages, err := s.repo.GetAllPages(ctx)
+	if err != nil {
+		return nil, err
+	}
+	prefix := s.templatePrefix()
+	names := []string{}
+	for _, page := range pages {
+		if name := strings.TrimPrefix(page.Title, prefix); name != page.Title && name != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// CreateFromTemplate creates a page whose content is the named template with its
+// variables filled in: {{title}}, {{author}}, {{date}} (YYYY-MM-DD) and {{time}}
+// (HH:MM, UTC). This is plain text substitution rather than Go templates, and
+// substituted values are not expanded again, so titles or names containing braces
+// cannot inject anything. Unknown variables are left as written, or rejected with
+// ErrUnknownTemplateVariable if strict templates are enabled.
+func (s *PageService) CreateFromTemplate(ctx context.Context, templateName, title, author string) (*data.Page, error) {
+	tmpl, err := s.repo.GetPageByTitle(ctx, s.templatePrefix()+templateName)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q", ErrTemplateNotFound, templateName)
+	}
+	now := time.Now().UTC()
+	content, err := expandTemplate(tmpl.Content, map[string]string{
+		"title":  title,
+		"author": author,
+		"date":   now.Format("2006-01-02"),
+		"time":   now.Format("15:04"),
+	}, s.content.StrictTemplates)
+	if err != nil {
+		return nil, err
+	}
+	return s.CreatePage(ctx, title, content, author, "", "")
+}
+
+// expandTemplate replaces the {{name}} variables in content with their values.
+func expandTemplate(content string, vars map[string]string, strict bool) (string, error) {
+	var unknown []string
+	expanded := templateVariablePattern.ReplaceAllStringFunc(content, func(match string) string {
+		name := templateVariablePattern.FindStringSubmatch(match)[1]
+		if value, ok := vars[strings.ToLower(name)]; ok {
+			return value
+		}
+		unknown = append(unknown, name)
+		return match
+	})
+	if strict && len(unknown) > 0 {
+		return "", fmt.Errorf("%w: %s", ErrUnknownTemplateVariable, strings.Join(unknown, ", "))
+	}
+	return expanded, nil
+}
+
+func (s *PageService) templatePrefix() string {
+	if s.content.TemplatePrefix != "" {
+		return s.content.TemplatePrefix
+	}
+	return defaultTemplatePrefix
+}
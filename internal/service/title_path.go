@@ -0,0 +1,65 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// ErrTitlePathTooDeep is returned when a path-style title has more segments
+// than a category, a subcategory and the page name.
+var ErrTitlePathTooDeep = errors.New("title path has more than three segments")
+
+// ErrInvalidTitlePath is returned when a path-style title leaves no usable page
+// title, e.g. "/" or "Docs/Home".
+var ErrInvalidTitlePath = errors.New("title path has no valid page title")
+
+// ErrTitlePathTaken is returned when a path-style title names a page that
+// another page already has, e.g. "Web/Auth/Tokens" while "API/Auth/Tokens"
+// is stored as "Tokens".
+var ErrTitlePathTaken = errors.New("title path names an existing page")
+
+// maxTitlePathSegments is the number of segments a path-style title may have:
+// category, subcategory and the page's own title.
+const maxTitlePathSegments = 3
+
+// splitTitlePath maps a path-style title such as "API/Auth/Tokens" to its
+// category ("API"), subcategory ("Auth") and page title ("Tokens"), so the
+// stored title never contains a slash, which would break the /view/{title}
+// routes. Titles without a slash, or with TitlePathCategories off, are returned
+// unchanged along with the given category and subcategory.
+//
+// Since different paths may end in the same page title, the title must not
+// belong to a page other than the one with pageID, which is 0 for new pages.
+func (s *PageService) splitTitlePath(ctx context.Context, pageID int64, title, categoryName, subcategoryName string) (string, string, string, error) {
+	if !s.content.TitlePathCategories || !strings.Contains(title, "/") {
+		return title, categoryName, subcategoryName, nil
+	}
+	var segments []string
+	for _, segment := range strings.Split(title, "/") {
+		if segment = strings.TrimSpace(segment); segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	switch len(segments) {
+	case 0:
+		return "", "", "", ErrInvalidTitlePath
+	case 1:
+	case 2:
+		categoryName, subcategoryName = segments[0], ""
+	case maxTitlePathSegments:
+		categoryName, subcategoryName = segments[0], segments[1]
+	default:
+		return "", "", "", ErrTitlePathTooDeep
+	}
+	title = segments[len(segments)-1]
+	// The Home page is never saved through a path, which would bypass the
+	// checks that keep it from being edited.
+	if title == "Home" {
+		return "", "", "", ErrInvalidTitlePath
+	}
+	if existing, err := s.repo.GetPageByTitle(ctx, title); err == nil && existing.ID != pageID {
+		return "", "", "", ErrTitlePathTaken
+	}
+	return title, categoryName, subcategoryName, nil
+}
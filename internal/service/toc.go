@@ -0,0 +1,71 @@
+package service
+
+import (
+	"bytes"
+	"go-wiki-app/internal/data"
+
+	"github.com/yuin/goldmark/ast"
+)
+
+const (
+	// tocMinLevel and tocMaxLevel bound the heading levels listed in a page's table of contents.
+	tocMinLevel = 2
+	tocMaxLevel = 4
+)
+
+// buildTableOfContents collects the headings of a parsed document into a nested
+// table of contents. Headings must already carry an id attribute.
+func buildTableOfContents(doc ast.Node, source []byte) []*data.TOCEntry {
+	var roots []*data.TOCEntry
+	var stack []*data.TOCEntry
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		heading, ok := n.(*ast.Heading)
+		if !ok {
+			return ast.WalkContinue, nil
+		}
+		if heading.Level < tocMinLevel || heading.Level > tocMaxLevel {
+			return ast.WalkSkipChildren, nil
+		}
+		id, ok := heading.AttributeString("id")
+		if !ok {
+			return ast.WalkSkipChildren, nil
+		}
+		idBytes, _ := id.([]byte)
+		entry := &data.TOCEntry{
+			ID:    string(idBytes),
+			Text:  string(nodeText(heading, source)),
+			Level: heading.Level,
+		}
+		for len(stack) > 0 && stack[len(stack)-1].Level >= entry.Level {
+			stack = stack[:len(stack)-1]
+		}
+		if len(stack) == 0 {
+			roots = append(roots, entry)
+		} else {
+			parent := stack[len(stack)-1]
+			parent.Children = append(parent.Children, entry)
+		}
+		stack = append(stack, entry)
+		return ast.WalkSkipChildren, nil
+	})
+	return roots
+}
+
+// nodeText returns the plain text content of an inline node tree.
+func nodeText(n ast.Node, source []byte) []byte {
+	var buf bytes.Buffer
+	for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+		switch t := c.(type) {
+		case *ast.Text:
+			buf.Write(t.Segment.Value(source))
+		case *ast.String:
+			buf.Write(t.Value)
+		default:
+			buf.Write(nodeText(c, source))
+		}
+	}
+	return buf.Bytes()
+}
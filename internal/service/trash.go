@@ -0,0 +1,58 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"go-wiki-app/internal/data"
+)
+
+// ErrPageInTrash is returned when creating a page whose title belongs to a page in the trash.
+var ErrPageInTrash = errors.New("a page with this title is in the trash")
+
+// GetTrash returns the pages in the trash, most recently deleted first.
+func (s *PageService) GetTrash(ctx context.Context) ([]*data.Page, error) {
+	return s.repo.GetDeletedPages(ctx)
+}
+
+// RestorePage takes the page with the given ID out of the trash.
+func (s *PageService) RestorePage(ctx context.Context, id int64) error {
+	page, err := s.repo.GetPageByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.RestorePage(ctx, id); err != nil {
+		return err
+	}
+	s.cache.Delete("page:" + page.Title)
+	s.invalidatePageList()
+	s.recordActivity(ctx, page, data.ActivityRestore, false)
+	return nil
+}
+
+// PurgePage permanently deletes the page with the given ID, which must be in the trash.
+func (s *PageService) PurgePage(ctx context.Context, id int64) error {
+	page, err := s.repo.GetPageByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.PurgePage(ctx, id); err != nil {
+		return err
+	}
+	s.cache.Delete("page:" + page.Title)
+	s.invalidatePageList()
+	return nil
+}
+
+// inTrash reports whether a page with the given title is in the trash.
+func (s *PageService) inTrash(ctx context.Context, title string) bool {
+	pages, err := s.repo.GetDeletedPages(ctx)
+	if err != nil {
+		return false
+	}
+	for _, page := range pages {
+		if page.Title == title {
+			return true
+		}
+	}
+	return false
+}
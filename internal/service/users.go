@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"go-wiki-app/internal/cache"
+	"go-wiki-app/internal/data"
+	"strconv"
+	"time"
+)
+
+// userCacheTTL is how long a user's profile is cached. It is loaded on every
+// request by a logged-in user, so a short TTL still saves most queries.
+const userCacheTTL = time.Minute
+
+// sessionEpochCacheTTL is how long the session epoch is cached. It is checked on
+// every request by a logged-in user, and bounds how long other instances keep
+// accepting old sessions after a lockdown.
+const sessionEpochCacheTTL = 5 * time.Second
+
+// sessionEpochCacheKey is the cache key of the current session epoch.
+const sessionEpochCacheKey = "session:epoch"
+
+// UserRepository defines the interface for database operations on users.
+type UserRepository interface {
+	Upsert(ctx context.Context, user *data.User) error
+	GetBySubject(ctx context.Context, subject string) (*data.User, error)
+	GetSessionEpoch(ctx context.Context) (int64, error)
+	BumpSessionEpoch(ctx context.Context) (int64, error)
+}
+
+// UserService provides cached access to the profiles of users who have logged in.
+type UserService struct {
+	repo  UserRepository
+	cache *cache.Cache
+}
+
+// NewUserService creates a new UserService.
+func NewUserService(repo UserRepository, cache *cache.Cache) *UserService {
+	return &UserService{repo: repo, cache: cache}
+}
+
+// GetUser returns the stored profile of the user.
+func (s *UserService) GetUser(ctx context.Context, subject string) (*data.User, error) {
+	key := "user:" + subject
+	if cached, _ := s.cache.Get(key); cached != nil {
+		var user data.User
+		if json.Unmarshal(cached, &user) == nil {
+			return &user, nil
+		}
+	}
+	user, err := s.repo.GetBySubject(ctx, subject)
+	if err != nil {
+		return nil, err
+	}
+	if b, err := json.Marshal(user); err == nil {
+		s.cache.Set(key, b, userCacheTTL)
+	}
+	return user, nil
+}
+
+// Upsert stores the user's profile and drops the cached copy.
+func (s *UserService) Upsert(ctx context.Context, user *data.User) error {
+	if err := s.repo.Upsert(ctx, user); err != nil {
+		return err
+	}
+	s.cache.Delete("user:" + user.Subject)
+	return nil
+}
+
+// SessionEpoch returns the current session epoch.
+func (s *UserService) SessionEpoch(ctx context.Context) (int64, error) {
+	if cached, _ := s.cache.Get(sessionEpochCacheKey); cached != nil {
+		if epoch, err := strconv.ParseInt(string(cached), 10, 64); err == nil {
+			return epoch, nil
+		}
+	}
+	epoch, err := s.repo.GetSessionEpoch(ctx)
+	if err != nil {
+		return 0, err
+	}
+	s.cache.Set(sessionEpochCacheKey, []byte(strconv.FormatInt(epoch, 10)), sessionEpochCacheTTL)
+	return epoch, nil
+}
+
+// Lockdown starts a new session epoch, which forces everyone to log in again.
+func (s *UserService) Lockdown(ctx context.Context) (int64, error) {
+	epoch, err := s.repo.BumpSessionEpoch(ctx)
+	if err != nil {
+		return 0, err
+	}
+	s.cache.Delete(sessionEpochCacheKey)
+	return epoch, nil
+}
@@ -0,0 +1,160 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// wikiLinkPattern matches [[Page Title]] and [[Page Title|label]] links.
+var wikiLinkPattern = regexp.MustCompile(`\[\[([^\[\]|]+)(?:\|([^\[\]]*))?\]\]`)
+
+// codePattern matches fenced code blocks and inline code spans, whose contents are not links.
+var codePattern = regexp.MustCompile("(?s)```.*?```|~~~.*?~~~|`[^`\n]*`")
+
+// wikiLinkTitlesKey carries the existing page titles into a parse, so links to
+// missing pages can be marked.
+var wikiLinkTitlesKey = parser.NewContextKey()
+
+// KindWikiLink is the goldmark node kind of a [[WikiLink]].
+var KindWikiLink = ast.NewNodeKind("WikiLink")
+
+// WikiLink is a [[Page Title]] or [[Page Title|label]] link. Its children are the label.
+type WikiLink struct {
+	ast.BaseInline
+	Target string
+	// Missing is set when no page with the target title exists.
+	Missing bool
+}
+
+// Kind implements ast.Node.
+func (n *WikiLink) Kind() ast.NodeKind {
+	return KindWikiLink
+}
+
+// Dump implements ast.Node.
+func (n *WikiLink) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Target": n.Target}, nil)
+}
+
+// wikiLinkParser is a goldmark inline parser for [[WikiLinks]]. It runs before
+// the standard link parser, which would otherwise take the brackets.
+type wikiLinkParser struct{}
+
+// Trigger implements parser.InlineParser.
+func (p *wikiLinkParser) Trigger() []byte {
+	return []byte{'['}
+}
+
+// Parse implements parser.InlineParser. Anything that is not a complete link on
+// a single line is left to the other parsers.
+func (p *wikiLinkParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, segment := block.PeekLine()
+	loc := wikiLinkPattern.FindSubmatchIndex(line)
+	if loc == nil || loc[0] != 0 {
+		return nil
+	}
+	target := strings.TrimSpace(string(line[loc[2]:loc[3]]))
+	if target == "" {
+		return nil
+	}
+	link := &WikiLink{Target: target}
+	if titles, ok := pc.Get(wikiLinkTitlesKey).(map[string]bool); ok && titles != nil {
+		link.Missing = !titles[target]
+	}
+
+	// The label follows the "|"; without one, or if it is blank, the target is shown.
+	label := text.NewSegment(segment.Start+loc[2], segment.Start+loc[3])
+	if loc[4] >= 0 && len(bytes.TrimSpace(line[loc[4]:loc[5]])) > 0 {
+		label = text.NewSegment(segment.Start+loc[4], segment.Start+loc[5])
+	}
+	label = label.TrimLeftSpace(block.Source())
+	label = label.TrimRightSpace(block.Source())
+	link.AppendChild(link, ast.NewTextSegment(label))
+	block.Advance(loc[1])
+	return link
+}
+
+// wikiLinkRenderer renders WikiLink nodes as links to their pages.
+type wikiLinkRenderer struct{}
+
+// RegisterFuncs implements renderer.NodeRenderer.
+func (r *wikiLinkRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindWikiLink, r.renderWikiLink)
+}
+
+func (r *wikiLinkRenderer) renderWikiLink(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		_, _ = w.WriteString("</a>")
+		return ast.WalkContinue, nil
+	}
+	n := node.(*WikiLink)
+	_, _ = w.WriteString("<a href=\"")
+	_, _ = w.Write(util.EscapeHTML([]byte("/view/" + url.PathEscape(n.Target))))
+	_, _ = w.WriteString("\"")
+	if n.Missing {
+		_, _ = w.WriteString(` class="missing"`)
+	}
+	_, _ = w.WriteString(">")
+	return ast.WalkContinue, nil
+}
+
+// extractWikiLinks returns the distinct targets of the [[WikiLinks]] in the
+// markdown, in order of first appearance. Links inside code are ignored.
+func extractWikiLinks(markdown string) []string {
+	markdown = codePattern.ReplaceAllString(markdown, "")
+	var targets []string
+	seen := make(map[string]bool)
+	for _, m := range wikiLinkPattern.FindAllStringSubmatch(markdown, -1) {
+		target := strings.TrimSpace(m[1])
+		if target == "" || seen[target] {
+			continue
+		}
+		seen[target] = true
+		targets = append(targets, target)
+	}
+	return targets
+}
+
+// linkedTitles returns the titles of the wiki pages the rendered document links
+// to with markdown links. [[WikiLinks]] themselves are not counted.
+func linkedTitles(doc ast.Node) map[string]bool {
+	linked := make(map[string]bool)
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if link, ok := n.(*ast.Link); ok && entering {
+			if rest, ok := strings.CutPrefix(string(link.Destination), "/view/"); ok {
+				if title, err := url.PathUnescape(rest); err == nil {
+					linked[title] = true
+				}
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+	return linked
+}
+
+// seeAlso lists the existing pages named in the page's [[WikiLinks]] that the
+// body does not already link to.
+func (s *PageService) seeAlso(ctx context.Context, content, current string, doc ast.Node) []string {
+	targets := extractWikiLinks(content)
+	if len(targets) == 0 {
+		return nil
+	}
+	titles := s.pageTitles(ctx)
+	linked := linkedTitles(doc)
+	var pages []string
+	for _, target := range targets {
+		if target != current && titles[target] && !linked[target] {
+			pages = append(pages, target)
+		}
+	}
+	return pages
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"go-wiki-app/internal/data"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// wordsPerMinute is the reading speed behind a page's estimated reading time.
+const wordsPerMinute = 200
+
+// htmlMarkupPattern matches HTML comments and tags in markdown.
+var htmlMarkupPattern = regexp.MustCompile(`(?s)<!--.*?-->|<[^>]*>`)
+
+// listItemPattern matches the first line of a list item.
+var listItemPattern = regexp.MustCompile(`^ {0,3}([-+*]|\d{1,9}[.)])(\s|$)`)
+
+// countWords counts the words a reader sees in markdown, leaving out fenced
+// and indented code blocks, HTML markup and markdown syntax such as list
+// markers. Lines indented inside a list belong to its items, so they count.
+func countWords(markdown string) int {
+	var prose strings.Builder
+	fence := ""
+	blank, inList, indentedCode := true, false, false
+	for _, line := range strings.Split(markdown, "\n") {
+		if fence != "" {
+			if closesFence(line, fence) {
+				fence, blank = "", true
+			}
+			continue
+		}
+		if fence = openingFence(line); fence != "" {
+			continue
+		}
+		isBlank := strings.TrimSpace(line) == ""
+		indented := indentWidth(line) >= 4
+		switch {
+		case isBlank:
+		case indented && !inList && (blank || indentedCode):
+			// Indented code cannot interrupt a paragraph, so it starts
+			// after a blank line.
+			indentedCode, blank = true, false
+			continue
+		case listItemPattern.MatchString(line):
+			inList = true
+		case !indented:
+			inList = false
+		}
+		if !isBlank {
+			indentedCode = false
+		}
+		blank = isBlank
+		prose.WriteString(line)
+		prose.WriteByte('\n')
+	}
+	words := 0
+	for _, field := range strings.Fields(htmlMarkupPattern.ReplaceAllString(prose.String(), " ")) {
+		if strings.IndexFunc(field, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) >= 0 {
+			words++
+		}
+	}
+	return words
+}
+
+// openingFence returns the run of backticks or tildes that opens a fenced
+// code block on line, or "" if the line does not open one.
+func openingFence(line string) string {
+	if indentWidth(line) > 3 {
+		return ""
+	}
+	rest := strings.TrimLeft(line, " \t")
+	for _, char := range []string{"`", "~"} {
+		n := len(rest) - len(strings.TrimLeft(rest, char))
+		if n < 3 {
+			continue
+		}
+		// The info string of a backtick fence cannot contain backticks.
+		if char == "`" && strings.Contains(rest[n:], "`") {
+			return ""
+		}
+		return rest[:n]
+	}
+	return ""
+}
+
+// closesFence reports whether line closes the block opened by fence: it must
+// hold at least as many of the fence's characters and nothing else.
+func closesFence(line, fence string) bool {
+	if indentWidth(line) > 3 {
+		return false
+	}
+	rest := strings.TrimLeft(line, " \t")
+	n := len(rest) - len(strings.TrimLeft(rest, fence[:1]))
+	return n >= len(fence) && strings.TrimSpace(rest[n:]) == ""
+}
+
+// indentWidth returns the width of line's leading whitespace, with tabs
+// advancing to the next multiple of four columns.
+func indentWidth(line string) int {
+	width := 0
+	for _, r := range line {
+		switch r {
+		case ' ':
+			width++
+		case '\t':
+			width += 4 - width%4
+		default:
+			return width
+		}
+	}
+	return width
+}
+
+// setReadingTime fills in the page's word count and its reading time in
+// minutes, rounded up.
+func setReadingTime(page *data.Page) {
+	page.WordCount = countWords(page.Content)
+	page.ReadingMinutes = (page.WordCount + wordsPerMinute - 1) / wordsPerMinute
+}
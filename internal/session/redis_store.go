@@ -0,0 +1,61 @@
+package session
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// redisKeyPrefix namespaces session keys in a shared Redis database.
+const redisKeyPrefix = "scs:session:"
+
+// RedisStore is an SCS session store backed by Redis, suitable for running
+// several wiki instances behind a load balancer.
+type RedisStore struct {
+	pool *redis.Pool
+}
+
+// NewRedisStore creates a RedisStore using connections from the given pool.
+func NewRedisStore(pool *redis.Pool) *RedisStore {
+	return &RedisStore{pool: pool}
+}
+
+// Find returns the data for a session token. Expired sessions are removed by
+// Redis itself, so a missing key means the session does not exist.
+func (s *RedisStore) Find(token string) ([]byte, bool, error) {
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	b, err := redis.Bytes(conn.Do("GET", redisKeyPrefix+token))
+	if errors.Is(err, redis.ErrNil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return b, true, nil
+}
+
+// Commit stores the session data with the given expiry.
+func (s *RedisStore) Commit(token string, b []byte, expiry time.Time) error {
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	ttl := time.Until(expiry).Milliseconds()
+	if ttl <= 0 {
+		_, err := conn.Do("DEL", redisKeyPrefix+token)
+		return err
+	}
+	_, err := conn.Do("SET", redisKeyPrefix+token, b, "PX", ttl)
+	return err
+}
+
+// Delete removes the session token and its data.
+func (s *RedisStore) Delete(token string) error {
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	_, err := conn.Do("DEL", redisKeyPrefix+token)
+	return err
+}
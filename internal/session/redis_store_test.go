@@ -0,0 +1,35 @@
+//go:build unit && redis
+
+package session
+
+import (
+	"go-wiki-app/internal/config"
+	"os"
+	"testing"
+)
+
+// TestNewStore_Redis needs a running Redis server (go test -tags unit,redis); set WIKI_TEST_REDIS_URL to
+// point at one (defaults to redis://localhost:6379/0).
+func TestNewStore_Redis(t *testing.T) {
+	url := os.Getenv("WIKI_TEST_REDIS_URL")
+	if url == "" {
+		url = "redis://localhost:6379/0"
+	}
+	store, closeStore, err := NewStore(config.SessionConfig{Store: StoreRedis, RedisURL: url}, nil)
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	defer closeStore()
+
+	if _, ok := store.(*RedisStore); !ok {
+		t.Fatalf("expected a Redis store, got %T", store)
+	}
+	assertRoundTrip(t, store)
+
+	if err := store.Delete("token"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, found, _ := store.Find("token"); found {
+		t.Error("expected session to be gone after Delete")
+	}
+}
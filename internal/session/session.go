@@ -3,6 +3,7 @@ package session
 import (
 	"context"
 	"net/http"
+	"time"
 )
 
 // Manager is an interface that abstracts the session management implementation.
@@ -11,7 +12,10 @@ type Manager interface {
 	LoadAndSave(next http.Handler) http.Handler
 	Put(ctx context.Context, key string, val interface{})
 	GetString(ctx context.Context, key string) string
+	GetTime(ctx context.Context, key string) time.Time
+	GetInt64(ctx context.Context, key string) int64
 	PopString(ctx context.Context, key string) string
 	Destroy(ctx context.Context) error
 	Remove(ctx context.Context, key string)
+	RememberMe(ctx context.Context, val bool)
 }
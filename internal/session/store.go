@@ -0,0 +1,74 @@
+package session
+
+import (
+	"database/sql"
+	"fmt"
+	"go-wiki-app/internal/config"
+
+	"github.com/alexedwards/scs/mysqlstore"
+	"github.com/alexedwards/scs/postgresstore"
+	"github.com/alexedwards/scs/sqlite3store"
+	"github.com/alexedwards/scs/v2"
+	"github.com/alexedwards/scs/v2/memstore"
+	"github.com/gomodule/redigo/redis"
+	_ "modernc.org/sqlite"
+)
+
+// Supported values for config.SessionConfig.Store.
+const (
+	StoreMySQL    = "mysql"
+	StorePostgres = "postgres"
+	StoreSQLite   = "sqlite"
+	StoreMemory   = "memory"
+	StoreRedis    = "redis"
+)
+
+// sqliteSessionSchema is the table layout expected by sqlite3store.
+const sqliteSessionSchema = `
+CREATE TABLE IF NOT EXISTS sessions (
+	token TEXT PRIMARY KEY,
+	data BLOB NOT NULL,
+	expiry REAL NOT NULL
+);
+CREATE INDEX IF NOT EXISTS sessions_expiry_idx ON sessions(expiry);`
+
+// NewStore creates the session store selected by cfg.Store. The MySQL and
+// PostgreSQL stores keep sessions in the application database db; the other
+// stores manage their own resources, which are released by the returned close function.
+func NewStore(cfg config.SessionConfig, db *sql.DB) (scs.Store, func(), error) {
+	switch cfg.Store {
+	case StoreMySQL, "":
+		store := mysqlstore.New(db)
+		return store, store.StopCleanup, nil
+	case StorePostgres:
+		store := postgresstore.New(db)
+		return store, store.StopCleanup, nil
+	case StoreMemory:
+		store := memstore.New()
+		return store, store.StopCleanup, nil
+	case StoreSQLite:
+		sqliteDB, err := sql.Open("sqlite", cfg.SQLitePath)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open session database: %w", err)
+		}
+		if _, err := sqliteDB.Exec(sqliteSessionSchema); err != nil {
+			sqliteDB.Close()
+			return nil, nil, fmt.Errorf("failed to create session table: %w", err)
+		}
+		store := sqlite3store.New(sqliteDB)
+		return store, func() {
+			store.StopCleanup()
+			sqliteDB.Close()
+		}, nil
+	case StoreRedis:
+		pool := &redis.Pool{
+			MaxIdle: 10,
+			Dial: func() (redis.Conn, error) {
+				return redis.DialURL(cfg.RedisURL)
+			},
+		}
+		return NewRedisStore(pool), func() { pool.Close() }, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown session store %q", cfg.Store)
+	}
+}
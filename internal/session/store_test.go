@@ -0,0 +1,87 @@
+//go:build unit
+
+package session
+
+import (
+	"database/sql"
+	"go-wiki-app/internal/config"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/scs/mysqlstore"
+	"github.com/alexedwards/scs/sqlite3store"
+	"github.com/alexedwards/scs/v2/memstore"
+	_ "github.com/go-sql-driver/mysql"
+)
+
+// assertRoundTrip commits a session to the store and reads it back.
+func assertRoundTrip(t *testing.T, store interface {
+	Commit(string, []byte, time.Time) error
+	Find(string) ([]byte, bool, error)
+}) {
+	t.Helper()
+	if err := store.Commit("token", []byte("data"), time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	b, found, err := store.Find("token")
+	if err != nil {
+		t.Fatalf("Find failed: %v", err)
+	}
+	if !found || string(b) != "data" {
+		t.Errorf("expected to find committed session data, got found=%v data=%q", found, b)
+	}
+}
+
+func TestNewStore_Memory(t *testing.T) {
+	store, closeStore, err := NewStore(config.SessionConfig{Store: StoreMemory}, nil)
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	defer closeStore()
+
+	if _, ok := store.(*memstore.MemStore); !ok {
+		t.Fatalf("expected a memory store, got %T", store)
+	}
+	assertRoundTrip(t, store)
+}
+
+func TestNewStore_SQLite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions.db")
+	store, closeStore, err := NewStore(config.SessionConfig{Store: StoreSQLite, SQLitePath: path}, nil)
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	defer closeStore()
+
+	if _, ok := store.(*sqlite3store.SQLite3Store); !ok {
+		t.Fatalf("expected a SQLite store, got %T", store)
+	}
+	assertRoundTrip(t, store)
+}
+
+func TestNewStore_MySQL(t *testing.T) {
+	// sql.Open does not connect, so the store can be constructed without a server.
+	db, err := sql.Open("mysql", "user:pass@tcp(127.0.0.1:3306)/wiki")
+	if err != nil {
+		t.Fatalf("sql.Open failed: %v", err)
+	}
+	defer db.Close()
+
+	for _, name := range []string{StoreMySQL, ""} {
+		store, closeStore, err := NewStore(config.SessionConfig{Store: name}, db)
+		if err != nil {
+			t.Fatalf("NewStore(%q) failed: %v", name, err)
+		}
+		if _, ok := store.(*mysqlstore.MySQLStore); !ok {
+			t.Errorf("expected a MySQL store for %q, got %T", name, store)
+		}
+		closeStore()
+	}
+}
+
+func TestNewStore_Unknown(t *testing.T) {
+	if _, _, err := NewStore(config.SessionConfig{Store: "cassandra"}, nil); err == nil {
+		t.Error("expected an error for an unknown session store")
+	}
+}
@@ -2,6 +2,7 @@ package view
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"html/template"
 	"io"
@@ -11,15 +12,77 @@ import (
 	"strings"
 )
 
+// defaultSiteName is shown in page titles and the header when none is configured.
+const defaultSiteName = "Go Wiki"
+
+// csrfTokenKey is the template data key under which Render passes the getter
+// of the request's CSRF token to the csrfField and csrfToken functions.
+const csrfTokenKey = "csrfToken"
+
+// csrfFieldName is the form field the CSRF token is sent in. It must match
+// middleware.CSRFField.
+const csrfFieldName = "csrf_token"
+
 // View represents a collection of parsed HTML templates.
 type View struct {
 	templates map[string]*template.Template
+	siteName  string
+	csrfToken func(ctx context.Context) string
+}
+
+// Option configures a View.
+type Option func(*View)
+
+// WithSiteName sets the site name templates show as .SiteName.
+func WithSiteName(name string) Option {
+	return func(v *View) {
+		if name != "" {
+			v.siteName = name
+		}
+	}
+}
+
+// WithCSRFToken sets the function that returns the request's CSRF token for
+// forms to send back, usually middleware.CSRFToken. Without it, forms are
+// rendered without a token.
+func WithCSRFToken(token func(ctx context.Context) string) Option {
+	return func(v *View) {
+		v.csrfToken = token
+	}
+}
+
+// funcs are the functions available to every template. Forms that change
+// something include {{csrfField $}}, and HTMX requests send {{csrfToken $}}
+// in the X-CSRF-Token header. Both take the template's root data.
+var funcs = template.FuncMap{
+	"csrfToken": csrfToken,
+	"csrfField": func(data map[string]interface{}) template.HTML {
+		token := csrfToken(data)
+		if token == "" {
+			return ""
+		}
+		return template.HTML(`<input type="hidden" name="` + csrfFieldName + `" value="` + template.HTMLEscapeString(token) + `">`)
+	},
+}
+
+// csrfToken returns the CSRF token passed in the template data by Render.
+// The token is only looked up when a template asks for it, so pages without
+// forms do not start a session.
+func csrfToken(data map[string]interface{}) string {
+	if token, ok := data[csrfTokenKey].(func() string); ok {
+		return token()
+	}
+	return ""
 }
 
 // New creates a new View by parsing all templates from the given filesystem.
-func New(templateFS fs.FS) (*View, error) {
+func New(templateFS fs.FS, opts ...Option) (*View, error) {
 	v := &View{
 		templates: make(map[string]*template.Template),
+		siteName:  defaultSiteName,
+	}
+	for _, opt := range opts {
+		opt(v)
 	}
 
 	// First, get all the layout files
@@ -58,7 +121,7 @@ func New(templateFS fs.FS) (*View, error) {
 		// which is how we refer to it when we want to execute a specific one.
 		// We use the base name here so that in the template files, we can just
 		// define the content block, and it will be merged with the base layout.
-		ts, err := template.New(filepath.Base(page)).ParseFS(templateFS, files...)
+		ts, err := template.New(filepath.Base(page)).Funcs(funcs).ParseFS(templateFS, files...)
 		if err != nil {
 			return nil, fmt.Errorf("failed to parse template %s: %w", name, err)
 		}
@@ -81,6 +144,17 @@ func (v *View) Render(w io.Writer, r *http.Request, name string, data map[string
 		rw.Header().Set("Content-Type", "text/html; charset=utf-8")
 	}
 
+	// Every page shows the site name, so it is available without each handler setting it.
+	if data == nil {
+		data = make(map[string]interface{})
+	}
+	if _, ok := data["SiteName"]; !ok {
+		data["SiteName"] = v.siteName
+	}
+	if v.csrfToken != nil && r != nil {
+		data[csrfTokenKey] = func() string { return v.csrfToken(r.Context()) }
+	}
+
 	// Execute the template into a buffer first to catch any errors
 	// before writing to the response writer.
 	buf := new(bytes.Buffer)
@@ -0,0 +1,12 @@
+-- migrations/006_create_activity_table.up.sql
+
+CREATE TABLE IF NOT EXISTS activity (
+    id INT PRIMARY KEY AUTO_INCREMENT,
+    page_id INT,
+    page_title VARCHAR(255) NOT NULL,
+    action VARCHAR(32) NOT NULL,
+    author_id VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_activity_created_at (created_at),
+    INDEX idx_activity_author_id (author_id)
+);
@@ -0,0 +1,12 @@
+-- migrations/007_create_revisions_table.up.sql
+
+CREATE TABLE IF NOT EXISTS revisions (
+    id INT PRIMARY KEY AUTO_INCREMENT,
+    page_id INT NOT NULL,
+    title VARCHAR(255) NOT NULL,
+    content TEXT NOT NULL,
+    author_id VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_revisions_page_id (page_id),
+    FOREIGN KEY (page_id) REFERENCES pages(id) ON DELETE CASCADE
+);
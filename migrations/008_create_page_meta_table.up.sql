@@ -0,0 +1,9 @@
+-- migrations/008_create_page_meta_table.up.sql
+
+CREATE TABLE IF NOT EXISTS page_meta (
+    page_id INT NOT NULL,
+    meta_key VARCHAR(64) NOT NULL,
+    meta_value VARCHAR(1024) NOT NULL,
+    PRIMARY KEY (page_id, meta_key),
+    FOREIGN KEY (page_id) REFERENCES pages(id) ON DELETE CASCADE
+);
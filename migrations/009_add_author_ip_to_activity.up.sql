@@ -0,0 +1,3 @@
+-- migrations/009_add_author_ip_to_activity.up.sql
+
+ALTER TABLE activity ADD COLUMN author_ip VARCHAR(45) NOT NULL DEFAULT '';
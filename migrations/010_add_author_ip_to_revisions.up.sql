@@ -0,0 +1,4 @@
+-- migrations/010_add_author_ip_to_revisions.up.sql
+
+ALTER TABLE revisions ADD COLUMN author_ip VARCHAR(45) NOT NULL DEFAULT '';
+CREATE INDEX idx_revisions_author_id ON revisions (author_id);
@@ -0,0 +1,4 @@
+-- migrations/011_add_minor_to_revisions_and_activity.up.sql
+
+ALTER TABLE revisions ADD COLUMN minor BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE activity ADD COLUMN minor BOOLEAN NOT NULL DEFAULT FALSE;
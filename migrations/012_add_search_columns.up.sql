@@ -0,0 +1,6 @@
+-- migrations/012_add_search_columns.up.sql
+
+-- Case-folded, accent-stripped copies of the searchable text, filled in by the
+-- application on save so that search behaves the same under any collation.
+ALTER TABLE pages ADD COLUMN search_text MEDIUMTEXT NULL;
+ALTER TABLE categories ADD COLUMN search_name VARCHAR(255) NULL;
@@ -0,0 +1,4 @@
+-- migrations/013_add_expires_at_to_pages.up.sql
+
+-- Pages past their expiry are archived: hidden from readers but kept for editors.
+ALTER TABLE pages ADD COLUMN expires_at TIMESTAMP NULL DEFAULT NULL;
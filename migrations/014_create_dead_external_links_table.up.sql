@@ -0,0 +1,13 @@
+-- migrations/014_create_dead_external_links_table.up.sql
+
+-- The dead links found by the last external link check, replaced on every run.
+CREATE TABLE IF NOT EXISTS dead_external_links (
+    id INT PRIMARY KEY AUTO_INCREMENT,
+    url VARCHAR(2048) NOT NULL,
+    page_id INT NOT NULL,
+    page_title VARCHAR(255) NOT NULL,
+    status_code INT NOT NULL DEFAULT 0,
+    error VARCHAR(512) NOT NULL DEFAULT '',
+    checked_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (page_id) REFERENCES pages(id) ON DELETE CASCADE
+);
@@ -0,0 +1,7 @@
+-- migrations/015_add_owner_subject_to_pages.up.sql
+
+-- The subject that owns a page and may always edit it. Existing pages are owned
+-- by their author; pages created anonymously have no owner.
+ALTER TABLE pages ADD COLUMN owner_subject VARCHAR(255) NOT NULL DEFAULT '';
+UPDATE pages SET owner_subject = author_id WHERE author_id <> 'anonymous';
+CREATE INDEX idx_pages_owner_subject ON pages (owner_subject);
@@ -0,0 +1,10 @@
+-- migrations/016_create_users_table.up.sql
+
+-- Profile details of users who have logged in, refreshed from their ID token on every login.
+CREATE TABLE IF NOT EXISTS users (
+    subject VARCHAR(255) PRIMARY KEY,
+    display_name VARCHAR(255) NOT NULL DEFAULT '',
+    email VARCHAR(255) NOT NULL DEFAULT '',
+    picture VARCHAR(2048) NOT NULL DEFAULT '',
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
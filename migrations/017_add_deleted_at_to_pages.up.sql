@@ -0,0 +1,6 @@
+-- migrations/017_add_deleted_at_to_pages.up.sql
+
+-- When a page was moved to the trash, NULL if it was not. Trashed pages are
+-- hidden everywhere except the trash and can be restored until they are purged.
+ALTER TABLE pages ADD COLUMN deleted_at TIMESTAMP NULL DEFAULT NULL;
+CREATE INDEX idx_pages_deleted_at ON pages (deleted_at);
@@ -0,0 +1,5 @@
+-- migrations/018_add_updated_at_index_to_pages.up.sql
+
+-- The page list and sitemap are revalidated against the newest page update,
+-- so finding it should not need a table scan.
+CREATE INDEX idx_pages_updated_at ON pages (updated_at);
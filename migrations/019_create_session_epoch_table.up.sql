@@ -0,0 +1,11 @@
+-- migrations/019_create_session_epoch_table.up.sql
+
+-- A single row holding the current session epoch. Login sessions record the
+-- epoch they were created in and are only valid while it is current, so an
+-- administrator can end every session at once by bumping it.
+CREATE TABLE IF NOT EXISTS session_epoch (
+    id TINYINT PRIMARY KEY,
+    epoch BIGINT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+INSERT INTO session_epoch (id, epoch) VALUES (1, 0);
@@ -0,0 +1,5 @@
+-- migrations/020_add_trusted_html_to_pages.up.sql
+
+-- Whether the page was last saved by an admin. Such pages are rendered with
+-- the operators' trusted HTML allow-list rather than the default sanitizer.
+ALTER TABLE pages ADD COLUMN trusted_html BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,19 @@
+-- migrations/021_create_page_pending_edits_table.up.sql
+
+-- Edits by contributors outside the trusted roles, waiting for a moderator to
+-- approve them. Approved edits are saved as a new revision of the page; both
+-- approved and rejected edits are removed from the queue.
+CREATE TABLE IF NOT EXISTS page_pending_edits (
+    id INT PRIMARY KEY AUTO_INCREMENT,
+    page_id INT NOT NULL,
+    title VARCHAR(255) NOT NULL,
+    content TEXT NOT NULL,
+    category_name VARCHAR(255) NOT NULL DEFAULT '',
+    subcategory_name VARCHAR(255) NOT NULL DEFAULT '',
+    author_id VARCHAR(255) NOT NULL,
+    author_ip VARCHAR(45) NOT NULL DEFAULT '',
+    minor BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_page_pending_edits_page_id (page_id),
+    FOREIGN KEY (page_id) REFERENCES pages(id) ON DELETE CASCADE
+);
@@ -0,0 +1,16 @@
+-- migrations/023_create_page_categories_table.up.sql
+
+-- Every category a page is tagged with. pages.category_id remains the page's
+-- own category, shown in its breadcrumb, and is tagged here as well; the
+-- existing pages are tagged with it below.
+CREATE TABLE IF NOT EXISTS page_categories (
+    page_id INT NOT NULL,
+    category_id INT NOT NULL,
+    PRIMARY KEY (page_id, category_id),
+    INDEX idx_page_categories_category_id (category_id),
+    FOREIGN KEY (page_id) REFERENCES pages(id) ON DELETE CASCADE,
+    FOREIGN KEY (category_id) REFERENCES categories(id) ON DELETE CASCADE
+);
+
+INSERT INTO page_categories (page_id, category_id)
+SELECT id, category_id FROM pages WHERE category_id IS NOT NULL;
@@ -0,0 +1,6 @@
+-- migrations/025_add_trusted_html_to_revisions.up.sql
+
+-- Whether every change kept in the revision's content was made by an admin.
+-- A page is only rendered with the trusted HTML allow-list while this holds,
+-- so restoring a revision restores its trust as well.
+ALTER TABLE revisions ADD COLUMN trusted_html BOOLEAN NOT NULL DEFAULT FALSE;
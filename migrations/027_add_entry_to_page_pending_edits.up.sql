@@ -0,0 +1,6 @@
+-- migrations/027_add_entry_to_page_pending_edits.up.sql
+
+-- Whether the queued edit is a log entry. Its content is appended to the page
+-- when approved instead of replacing it, so entries approved one after
+-- another are all kept.
+ALTER TABLE page_pending_edits ADD COLUMN entry BOOLEAN NOT NULL DEFAULT FALSE;
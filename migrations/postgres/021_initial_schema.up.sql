@@ -0,0 +1,120 @@
+-- migrations/postgres/021_initial_schema.up.sql
+
+-- The PostgreSQL schema, equivalent to MySQL migrations 001 to 021. It is
+-- numbered 021 so both databases report the same schema version; every later
+-- migration needs a counterpart in this directory.
+
+CREATE TABLE IF NOT EXISTS casbin_rule (
+    ptype TEXT,
+    v0 TEXT,
+    v1 TEXT,
+    v2 TEXT,
+    v3 TEXT,
+    v4 TEXT,
+    v5 TEXT
+);
+
+CREATE TABLE IF NOT EXISTS sessions (
+    token TEXT PRIMARY KEY,
+    data BYTEA NOT NULL,
+    expiry TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS sessions_expiry_idx ON sessions (expiry);
+
+CREATE TABLE IF NOT EXISTS categories (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    parent_id INT REFERENCES categories(id) ON DELETE CASCADE,
+    search_name VARCHAR(255) NULL,
+    UNIQUE (name, parent_id)
+);
+
+CREATE TABLE IF NOT EXISTS pages (
+    id SERIAL PRIMARY KEY,
+    title VARCHAR(255) NOT NULL UNIQUE,
+    content TEXT NOT NULL,
+    author_id VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    category_id INT REFERENCES categories(id) ON DELETE SET NULL,
+    search_text TEXT NULL,
+    expires_at TIMESTAMP NULL DEFAULT NULL,
+    owner_subject VARCHAR(255) NOT NULL DEFAULT '',
+    deleted_at TIMESTAMP NULL DEFAULT NULL,
+    trusted_html BOOLEAN NOT NULL DEFAULT FALSE
+);
+CREATE INDEX IF NOT EXISTS idx_pages_owner_subject ON pages (owner_subject);
+CREATE INDEX IF NOT EXISTS idx_pages_deleted_at ON pages (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_pages_updated_at ON pages (updated_at);
+
+CREATE TABLE IF NOT EXISTS activity (
+    id SERIAL PRIMARY KEY,
+    page_id INT,
+    page_title VARCHAR(255) NOT NULL,
+    action VARCHAR(32) NOT NULL,
+    author_id VARCHAR(255) NOT NULL,
+    author_ip VARCHAR(45) NOT NULL DEFAULT '',
+    minor BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_activity_created_at ON activity (created_at);
+CREATE INDEX IF NOT EXISTS idx_activity_author_id ON activity (author_id);
+
+CREATE TABLE IF NOT EXISTS revisions (
+    id SERIAL PRIMARY KEY,
+    page_id INT NOT NULL REFERENCES pages(id) ON DELETE CASCADE,
+    title VARCHAR(255) NOT NULL,
+    content TEXT NOT NULL,
+    author_id VARCHAR(255) NOT NULL,
+    author_ip VARCHAR(45) NOT NULL DEFAULT '',
+    minor BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_revisions_page_id ON revisions (page_id);
+CREATE INDEX IF NOT EXISTS idx_revisions_author_id ON revisions (author_id);
+
+CREATE TABLE IF NOT EXISTS page_meta (
+    page_id INT NOT NULL REFERENCES pages(id) ON DELETE CASCADE,
+    meta_key VARCHAR(64) NOT NULL,
+    meta_value VARCHAR(1024) NOT NULL,
+    PRIMARY KEY (page_id, meta_key)
+);
+
+CREATE TABLE IF NOT EXISTS dead_external_links (
+    id SERIAL PRIMARY KEY,
+    url VARCHAR(2048) NOT NULL,
+    page_id INT NOT NULL REFERENCES pages(id) ON DELETE CASCADE,
+    page_title VARCHAR(255) NOT NULL,
+    status_code INT NOT NULL DEFAULT 0,
+    error VARCHAR(512) NOT NULL DEFAULT '',
+    checked_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS users (
+    subject VARCHAR(255) PRIMARY KEY,
+    display_name VARCHAR(255) NOT NULL DEFAULT '',
+    email VARCHAR(255) NOT NULL DEFAULT '',
+    picture VARCHAR(2048) NOT NULL DEFAULT '',
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS session_epoch (
+    id SMALLINT PRIMARY KEY,
+    epoch BIGINT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+INSERT INTO session_epoch (id, epoch) VALUES (1, 0) ON CONFLICT DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS page_pending_edits (
+    id SERIAL PRIMARY KEY,
+    page_id INT NOT NULL REFERENCES pages(id) ON DELETE CASCADE,
+    title VARCHAR(255) NOT NULL,
+    content TEXT NOT NULL,
+    category_name VARCHAR(255) NOT NULL DEFAULT '',
+    subcategory_name VARCHAR(255) NOT NULL DEFAULT '',
+    author_id VARCHAR(255) NOT NULL,
+    author_ip VARCHAR(45) NOT NULL DEFAULT '',
+    minor BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_page_pending_edits_page_id ON page_pending_edits (page_id);
@@ -0,0 +1,8 @@
+-- migrations/postgres/022_create_page_acls_table.up.sql
+
+-- Pages restricted to the users holding a role. Pages without a row are
+-- visible to everyone allowed to view pages at all.
+CREATE TABLE IF NOT EXISTS page_acls (
+    page_id INT PRIMARY KEY REFERENCES pages(id) ON DELETE CASCADE,
+    required_role VARCHAR(255) NOT NULL
+);
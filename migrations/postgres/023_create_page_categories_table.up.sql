@@ -0,0 +1,15 @@
+-- migrations/postgres/023_create_page_categories_table.up.sql
+
+-- Every category a page is tagged with. pages.category_id remains the page's
+-- own category, shown in its breadcrumb, and is tagged here as well; the
+-- existing pages are tagged with it below.
+CREATE TABLE IF NOT EXISTS page_categories (
+    page_id INT NOT NULL REFERENCES pages(id) ON DELETE CASCADE,
+    category_id INT NOT NULL REFERENCES categories(id) ON DELETE CASCADE,
+    PRIMARY KEY (page_id, category_id)
+);
+CREATE INDEX IF NOT EXISTS idx_page_categories_category_id ON page_categories (category_id);
+
+INSERT INTO page_categories (page_id, category_id)
+SELECT id, category_id FROM pages WHERE category_id IS NOT NULL
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,5 @@
+-- migrations/postgres/024_add_order_weight_to_categories.up.sql
+
+-- Categories are listed by ascending weight, then by name, so administrators
+-- can put "Getting Started" before "Advanced". All start at 0, alphabetical.
+ALTER TABLE categories ADD COLUMN order_weight INT NOT NULL DEFAULT 0;
@@ -0,0 +1,6 @@
+-- migrations/postgres/026_allow_pending_new_pages.up.sql
+
+-- New pages by contributors outside the trusted roles are queued without a
+-- page row, which is only created once a moderator approves them. Their
+-- queued edit has no page_id.
+ALTER TABLE page_pending_edits ALTER COLUMN page_id DROP NOT NULL;
@@ -0,0 +1,7 @@
+// Asks for confirmation before submitting forms with a data-confirm message.
+document.addEventListener('submit', function (e) {
+    var message = e.target.getAttribute('data-confirm');
+    if (message && !window.confirm(message)) {
+        e.preventDefault();
+    }
+});
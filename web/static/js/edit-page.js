@@ -0,0 +1,40 @@
+// Sets up the Markdown editor and the category search dialog on the edit page.
+(function () {
+    var config = document.getElementById('editor-config');
+    if (config && window.EasyMDE) {
+        var editorConfig = JSON.parse(config.textContent);
+        editorConfig.element = document.getElementById('editor');
+        window.easyMDE = new EasyMDE(editorConfig);
+    }
+
+    var dialog = document.getElementById('category-search-dialog');
+    if (!dialog) {
+        return;
+    }
+    var targetFieldId = '';
+
+    // The search results are swapped in by HTMX, so clicks are handled on the
+    // document rather than on the buttons themselves.
+    document.addEventListener('click', function (e) {
+        var search = e.target.closest('[data-category-search]');
+        if (search) {
+            targetFieldId = search.getAttribute('data-category-search');
+            dialog.showModal();
+            return;
+        }
+
+        var result = e.target.closest('.category-result');
+        if (result) {
+            if (targetFieldId) {
+                document.getElementById(targetFieldId).value = result.getAttribute('data-name');
+            }
+            dialog.close();
+            return;
+        }
+
+        if (e.target.closest('[data-close-dialog]')) {
+            e.preventDefault();
+            dialog.close();
+        }
+    });
+})();
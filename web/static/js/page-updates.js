@@ -0,0 +1,32 @@
+// Listens for live update notifications for the current page and shows a
+// banner offering a reload when someone else saves it.
+(function () {
+    var script = document.currentScript;
+    var title = script && script.getAttribute('data-title');
+    if (!title || !window.EventSource) {
+        return;
+    }
+
+    var banner = document.getElementById('page-update-banner');
+    var reload = document.getElementById('page-update-reload');
+    var source = new EventSource('/sse/page/' + encodeURIComponent(title));
+
+    source.addEventListener('page-updated', function (e) {
+        var data = {};
+        try {
+            data = JSON.parse(e.data);
+        } catch (err) {
+            // Fall back to reloading the current page.
+        }
+        if (reload && data.title) {
+            reload.setAttribute('href', '/view/' + encodeURIComponent(data.title));
+        }
+        if (banner) {
+            banner.hidden = false;
+        }
+    });
+
+    window.addEventListener('beforeunload', function () {
+        source.close();
+    });
+})();